@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"fowergram-backend/pkg/logger"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// component is a long-running part of the server managed by a lifecycle
+type component struct {
+	name string
+
+	// run starts the component. Components that serve until stopped, such as
+	// the HTTP server, block until stop is called; others return once
+	// started. Either may be nil.
+	run  func(ctx context.Context) error
+	stop func(ctx context.Context) error
+}
+
+// lifecycle starts components together and stops them in the reverse of the
+// order they were added, so a component is added after everything it
+// depends on and stopped before any of it
+type lifecycle struct {
+	components []component
+	timeout    time.Duration
+	logger     logger.Logger
+}
+
+// newLifecycle creates a lifecycle giving its components timeout in total
+// to stop
+func newLifecycle(timeout time.Duration, logger logger.Logger) *lifecycle {
+	return &lifecycle{
+		timeout: timeout,
+		logger:  logger,
+	}
+}
+
+// add registers a component
+func (l *lifecycle) add(c component) {
+	l.components = append(l.components, c)
+}
+
+// Run starts every component with a context shared between them and waits
+// until ctx ends or a component fails to run. Every component is then
+// stopped, and Run returns once all of them have stopped or the timeout
+// passed, with the first error met.
+func (l *lifecycle) Run(ctx context.Context) error {
+	g, ctx := errgroup.WithContext(ctx)
+
+	for _, c := range l.components {
+		if c.run == nil {
+			continue
+		}
+		g.Go(func() error {
+			if err := c.run(ctx); err != nil {
+				return fmt.Errorf("failed to run %s: %w", c.name, err)
+			}
+			return nil
+		})
+	}
+
+	g.Go(func() error {
+		<-ctx.Done()
+		return l.stop()
+	})
+
+	return g.Wait()
+}
+
+// stop stops the components in reverse order, each one only once the one
+// added after it has stopped or failed to
+func (l *lifecycle) stop() error {
+	l.logger.Info("Shutting down server...")
+
+	ctx, cancel := context.WithTimeout(context.Background(), l.timeout)
+	defer cancel()
+
+	var errs []error
+	for i := len(l.components) - 1; i >= 0; i-- {
+		c := l.components[i]
+		if c.stop == nil {
+			continue
+		}
+
+		if err := c.stop(ctx); err != nil {
+			l.logger.Error("Failed to stop component", "component", c.name, "error", err)
+			errs = append(errs, fmt.Errorf("failed to stop %s: %w", c.name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
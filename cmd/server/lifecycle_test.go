@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"fowergram-backend/pkg/logger"
+)
+
+// fakeComponent is run and stopped by a lifecycle, recording when it stops
+type fakeComponent struct {
+	name    string
+	serves  bool  // Runs until stopped, like the HTTP server
+	runErr  error // Returned by run when set
+	stopErr error // Returned by stop when set
+	hangs   bool  // Stop waits out the shutdown deadline
+}
+
+// shutdownRecorder records the order components stop in
+type shutdownRecorder struct {
+	mu      sync.Mutex
+	stopped []string
+}
+
+func (r *shutdownRecorder) component(fake fakeComponent) component {
+	done := make(chan struct{})
+	return component{
+		name: fake.name,
+		run: func(ctx context.Context) error {
+			if fake.runErr != nil {
+				return fake.runErr
+			}
+			if fake.serves {
+				<-done
+			}
+			return nil
+		},
+		stop: func(ctx context.Context) error {
+			if fake.serves {
+				close(done)
+			}
+			if fake.hangs {
+				<-ctx.Done()
+			}
+			r.mu.Lock()
+			r.stopped = append(r.stopped, fake.name)
+			r.mu.Unlock()
+			if fake.hangs {
+				return ctx.Err()
+			}
+			return fake.stopErr
+		},
+	}
+}
+
+func TestLifecycle(t *testing.T) {
+	errListen := errors.New("address already in use")
+	errDrain := errors.New("drain timed out")
+	const timeout = 50 * time.Millisecond
+
+	tests := []struct {
+		name        string
+		http        fakeComponent
+		worker      fakeComponent
+		nats        fakeComponent
+		wantErr     error
+		wantErrText string
+	}{
+		{name: "signalled"},
+		{
+			name:        "component fails to run",
+			http:        fakeComponent{runErr: errListen},
+			wantErr:     errListen,
+			wantErrText: "failed to run http server",
+		},
+		{
+			name:        "component fails to stop",
+			nats:        fakeComponent{stopErr: errDrain},
+			wantErr:     errDrain,
+			wantErrText: "failed to stop nats",
+		},
+		{
+			name:        "stop outlasts the timeout",
+			worker:      fakeComponent{hangs: true},
+			wantErr:     context.DeadlineExceeded,
+			wantErrText: "failed to stop worker",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &shutdownRecorder{}
+			lc := newLifecycle(timeout, logger.NewZapLogger())
+			tt.http.name, tt.http.serves = "http server", true
+			tt.worker.name, tt.nats.name = "worker", "nats"
+
+			// Added in dependency order, as main adds them
+			lc.add(r.component(fakeComponent{name: "postgres"}))
+			lc.add(r.component(fakeComponent{name: "redis"}))
+			lc.add(r.component(tt.nats))
+			lc.add(r.component(tt.worker))
+			lc.add(r.component(tt.http))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error, 1)
+			go func() { done <- lc.Run(ctx) }()
+			if tt.http.runErr == nil {
+				// Like SIGTERM; a failing component stops the rest itself
+				cancel()
+			}
+
+			var err error
+			select {
+			case err = <-done:
+			case <-time.After(timeout + time.Second):
+				t.Fatal("Run didn't return after the shutdown timeout")
+			}
+			cancel()
+
+			if !errors.Is(err, tt.wantErr) || (err != nil) != (tt.wantErr != nil) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), tt.wantErrText) {
+				t.Errorf("error = %v, want it to name the component: %s", err, tt.wantErrText)
+			}
+			want := []string{"http server", "worker", "nats", "redis", "postgres"}
+			if !slices.Equal(r.stopped, want) {
+				t.Errorf("stopped %v, want %v", r.stopped, want)
+			}
+		})
+	}
+}
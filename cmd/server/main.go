@@ -5,28 +5,41 @@ import (
 	"log"
 	"os"
 	"os/signal"
-	"strconv"
 	"syscall"
 	"time"
 
 	"github.com/gofiber/adaptor/v2"
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/compress"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/joho/godotenv"
 
 	"fowergram-backend/internal/config"
+	"fowergram-backend/internal/domain/comment"
+	"fowergram-backend/internal/domain/conversation"
+	"fowergram-backend/internal/domain/device"
+	"fowergram-backend/internal/domain/export"
+	"fowergram-backend/internal/domain/media"
+	"fowergram-backend/internal/domain/moderation"
+	"fowergram-backend/internal/domain/notification"
 	"fowergram-backend/internal/domain/post"
 	"fowergram-backend/internal/domain/user"
+	"fowergram-backend/internal/events"
 	"fowergram-backend/internal/graphql"
 	"fowergram-backend/internal/handlers"
 	"fowergram-backend/internal/infra/cache"
 	"fowergram-backend/internal/infra/database"
 	"fowergram-backend/internal/infra/messaging"
 	"fowergram-backend/internal/infra/storage"
+	"fowergram-backend/internal/realtime"
 	"fowergram-backend/internal/routes"
 	"fowergram-backend/pkg/auth"
 	"fowergram-backend/pkg/email"
+	"fowergram-backend/pkg/errreport"
+	"fowergram-backend/pkg/httperr"
 	"fowergram-backend/pkg/logger"
 	"fowergram-backend/pkg/middleware"
+	"fowergram-backend/pkg/retry"
 	"fowergram-backend/pkg/telemetry"
 )
 
@@ -35,135 +48,399 @@ func main() {
 		log.Println("No .env file found, using system environment variables")
 	}
 
-	logger := logger.NewZapLogger()
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	logger, err := logger.New(cfg.LogLevel, cfg.LogEncoding(), cfg.LogRedactKeys...)
+	if err != nil {
+		log.Fatalf("Invalid LOG_LEVEL or LOG_FORMAT: %v", err)
+	}
 	defer logger.Sync()
 
-	cfg := config.Load()
+	// The subcommands only need the database
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			if err := runMigrate(cfg.DatabaseURL, os.Args[2:], logger); err != nil {
+				logger.Fatal("Migration failed", "error", err)
+			}
+			return
+		case "seed-admin":
+			if err := runSeedAdmin(cfg.DatabaseURL, os.Args[2:], logger); err != nil {
+				logger.Fatal("Failed to seed admin", "error", err)
+			}
+			return
+		}
+	}
+	if err := cfg.Validate(); err != nil {
+		logger.Fatal("Invalid configuration", "error", err)
+	}
 
-	telemetry, err := telemetry.NewTelemetry(cfg.AppName, cfg.AppVersion)
+	telemetry, err := telemetry.NewTelemetry(context.Background(), cfg.AppName, cfg.AppVersion, telemetry.TracingConfig{
+		Endpoint:    cfg.TracingEndpoint(),
+		Headers:     cfg.OTLPHeaders,
+		Environment: cfg.Environment,
+	})
 	if err != nil {
 		logger.Fatal("Failed to initialize telemetry", "error", err)
 	}
-	defer telemetry.Shutdown()
 
-	db, err := database.NewPostgreSQLDB(cfg.DatabaseURL)
-	if err != nil {
+	errorReporter := errreport.Nop()
+	if cfg.SentryDSN != "" {
+		errorReporter, err = errreport.NewSentry(errreport.SentryConfig{
+			DSN:         cfg.SentryDSN,
+			Environment: cfg.Environment,
+			Release:     cfg.AppVersion,
+			RedactKeys:  cfg.LogRedactKeys,
+		})
+		if err != nil {
+			logger.Fatal("Failed to initialize error reporting", "error", err)
+		}
+	}
+
+	// Dependencies may still be starting alongside the server, so each one
+	// is retried with backoff for up to STARTUP_TIMEOUT before giving up
+	connect := func(name string, fn func(ctx context.Context) error) error {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.StartupTimeout.Duration)
+		defer cancel()
+
+		return retry.Do(ctx, retry.DefaultBackoff, fn, func(attempt int, err error, delay time.Duration) {
+			logger.Warn("Dependency not ready, retrying", "dependency", name, "attempt", attempt, "retry_in", delay, "error", err)
+		})
+	}
+
+	var db *pgxpool.Pool
+	if err := connect("postgres", func(ctx context.Context) (err error) {
+		db, err = database.NewPostgreSQLDB(ctx, cfg.DatabaseURL)
+		return err
+	}); err != nil {
 		logger.Fatal("Failed to connect to database", "error", err)
 	}
-	defer db.Close()
 
-	cacheClient, err := cache.NewRedisCache(cfg.RedisURL)
-	if err != nil {
+	if cfg.AutoMigrate {
+		if err := migrateUp(db, logger); err != nil {
+			logger.Fatal("Failed to migrate database", "error", err)
+		}
+	}
+	if err := telemetry.RegisterPool(db); err != nil {
+		logger.Fatal("Failed to register database pool metrics", "error", err)
+	}
+
+	var cacheClient *cache.RedisCache
+	if err := connect("redis", func(ctx context.Context) (err error) {
+		cacheClient, err = cache.NewRedisCache(ctx, cfg.RedisURL)
+		return err
+	}); err != nil {
 		logger.Fatal("Failed to connect to Redis", "error", err)
 	}
-	defer cacheClient.Close()
 
-	storageClient, err := storage.NewMinIOStorage(cfg.Storage)
-	if err != nil {
-		logger.Fatal("Failed to initialize MinIO storage", "error", err)
+	var storageClient storage.Storage
+	if err := connect("minio", func(ctx context.Context) (err error) {
+		storageClient, err = storage.NewMinIOStorage(ctx, cfg.Storage)
+		return err
+	}); err != nil {
+		if !cfg.Storage.Optional {
+			logger.Fatal("Failed to initialize MinIO storage", "error", err)
+		}
+		logger.Warn("MinIO unavailable, starting without storage", "error", err)
+		storageClient = storage.NoopStorage{}
 	}
 
-	msgClient, err := messaging.NewNATSClient(cfg.NatsURL)
-	if err != nil {
-		logger.Fatal("Failed to connect to NATS", "error", err)
+	var msgClient messaging.Client
+	if err := connect("nats", func(context.Context) (err error) {
+		msgClient, err = messaging.NewNATSClient(cfg.NatsURL, cfg.JetStream, logger)
+		return err
+	}); err != nil {
+		if !cfg.NatsOptional {
+			logger.Fatal("Failed to connect to NATS", "error", err)
+		}
+		logger.Warn("NATS unavailable, starting without messaging", "error", err)
+		msgClient = messaging.NoopClient{}
 	}
-	defer msgClient.Close()
 
 	emailService := email.NewSMTPEmailService(email.EmailConfig{
-		SMTPHost:     getEnv("SMTP_HOST", "smtp.gmail.com"),
-		SMTPPort:     getEnvAsInt("SMTP_PORT", 587),
-		SMTPUsername: getEnv("SMTP_USERNAME", ""),
-		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
-		FromEmail:    getEnv("SMTP_FROM_EMAIL", "noreply@fowergram.com"),
-		FromName:     getEnv("SMTP_FROM_NAME", "Fowergram"),
-		BaseURL:      getEnv("APP_URL", "http://localhost:3000"),
+		SMTPHost:     cfg.SMTP.Host,
+		SMTPPort:     cfg.SMTP.Port,
+		SMTPUsername: cfg.SMTP.Username,
+		SMTPPassword: cfg.SMTP.Password,
+		FromEmail:    cfg.SMTP.FromEmail,
+		FromName:     cfg.SMTP.FromName,
+		BaseURL:      cfg.SMTP.AppURL,
 	})
 
+	// Routes name the policy they are limited by; see routes.RateLimitPolicies
 	rateLimiter := middleware.NewRateLimiter(middleware.RateLimiterConfig{
 		RedisClient: cacheClient.GetClient(),
 		MaxRequests: 5,
 		Window:      time.Minute,
+		FailOpen:    cfg.RateLimitFailOpen,
+		Logger:      logger,
+		Telemetry:   telemetry,
+	})
+
+	trustedProxies, err := middleware.ParseTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		logger.Fatal("Invalid trusted proxies", "error", err)
+	}
+
+	accessLogger := middleware.NewAccessLogger(middleware.AccessLogConfig{
+		Logger:    logger,
+		SkipPaths: cfg.AccessLogSkipPaths,
+	})
+
+	// Replay responses for retried writes carrying an Idempotency-Key
+	idempotency := middleware.NewIdempotency(middleware.IdempotencyConfig{
+		RedisClient: cacheClient.GetClient(),
+		TTL:         24 * time.Hour,
+		KeyFunc:     middleware.ByUserID,
 	})
 
 	userRepo := user.NewPostgresRepository(db)
 	verificationRepo := user.NewPostgresVerificationRepository(db)
 	postRepo := post.NewRepository(db)
+	mediaRepo := media.NewRepository(db)
+	commentRepo := comment.NewRepository(db)
+	exportRepo := export.NewRepository(db)
+	notificationRepo := notification.NewRepository(db)
+	conversationRepo := conversation.NewRepository(db)
+	deviceRepo := device.NewRepository(db)
+
+	signingKeys, err := auth.NewSigningKeys(auth.SigningConfig{
+		Algorithm:      cfg.JWTSigning.Algorithm,
+		Secret:         cfg.JWTSecret,
+		PrivateKeyFile: cfg.JWTSigning.PrivateKeyFile,
+		KeyID:          cfg.JWTSigning.KeyID,
+		PublicKeyFile:  cfg.JWTSigning.PublicKeyFile,
+		JWKSURL:        cfg.JWTSigning.JWKSURL,
+		Issuer:         cfg.JWTIssuer,
+		Audience:       cfg.JWTAudience,
+		Leeway:         cfg.JWTLeeway.Duration,
+	})
+	if err != nil {
+		logger.Fatal("Failed to load JWT signing keys", "error", err)
+	}
 
 	authService := auth.NewJWTAuth(
-		getEnv("JWT_SECRET", "your-super-secret-jwt-key-change-this-in-production"),
-		time.Hour*1,
-		time.Hour*24*30,
+		signingKeys,
+		cfg.AccessTokenTTL.Duration,
+		cfg.RefreshTokenTTL.Duration,
+		cfg.PasswordHistory,
 		userRepo,
 		verificationRepo,
 		emailService,
+		cache.NewTokenDenylist(cacheClient),
+		logger,
 	)
 
-	userService := user.NewService(userRepo, cacheClient, authService, logger)
-	postService := post.NewService(postRepo, userRepo, storageClient, cacheClient, msgClient, logger)
+	publisher := events.NewNATSPublisher(msgClient, logger)
 
-	gqlServer := graphql.NewServer(userService, postService, authService, logger)
+	var moderator moderation.Moderator = moderation.NewWordlistModerator(cfg.Moderation.BannedWords, cfg.Moderation.FlaggedWords)
+	if cfg.Moderation.APIURL != "" {
+		moderator = moderation.Chain(moderator, moderation.NewHTTPModerator(cfg.Moderation.APIURL, cfg.Moderation.APITimeout.Duration))
+	}
+	moderationService := moderation.NewService(moderation.NewRepository(db), moderator, logger)
+
+	userService := user.NewService(userRepo, cacheClient, authService, storageClient, publisher, logger)
+	mediaService := media.NewService(mediaRepo, storageClient, msgClient, logger)
+	postService := post.NewService(db, postRepo, userRepo, mediaService, moderationService, storageClient, cacheClient, cacheClient.GetClient(), msgClient, publisher, logger, telemetry, cfg.SearchLanguage)
+	commentService := comment.NewService(commentRepo, postRepo, moderationService, publisher, logger)
+	exportService := export.NewService(exportRepo, logger)
+	notificationService := notification.NewService(notificationRepo, deviceRepo, userRepo, publisher, logger)
+	conversationService := conversation.NewService(conversationRepo, userRepo, publisher, logger)
+	deviceService := device.NewService(deviceRepo, logger)
+
+	var pushSender device.Sender = device.NoopSender{}
+	if cfg.Push.FCMCredentialsFile != "" {
+		fcmSender, err := device.NewFCMSender(cfg.Push.FCMCredentialsFile, cfg.Push.Timeout.Duration)
+		if err != nil {
+			logger.Fatal("Failed to initialize FCM push sender", "error", err)
+		}
+		pushSender = fcmSender
+	}
 
-	authHandler := handlers.NewAuthHandler(authService, emailService, logger)
-	healthHandler := handlers.NewHealthHandler(cfg.AppVersion)
-	postHandler := handlers.NewPostHandler(postService, logger)
+	mediaWorker := media.NewWorker(mediaService, msgClient, logger)
+	notificationWorker := notification.NewWorker(notificationService, msgClient, logger)
+	pushWorker := device.NewWorker(deviceRepo, pushSender, msgClient, logger)
+	fanoutWorker := post.NewFanoutWorker(postService, msgClient, logger)
+
+	// Each instance pushes events to the websockets connected to it
+	hub := realtime.NewHub(userRepo, logger)
+
+	// Buffered post views are written to Postgres in batches
+	viewFlusher := post.NewViewFlusher(postRepo, cacheClient, 30*time.Second, logger)
+
+	// Scheduled posts are published by whichever instance picks them up first
+	schedulePublisher := post.NewSchedulePublisher(postService, 30*time.Second, logger)
+
+	gqlServer := graphql.NewServer(userService, postService, authService, cfg.IntrospectionEnabled(), logger)
+	gqlSubscriptions := graphql.NewSubscriptionHandler(userService, postService, authService, hub, cfg.IntrospectionEnabled(), logger)
+
+	if cfg.AuthCookie.Enabled {
+		authService.EnableCookieAuth()
+	}
+	authHandler := handlers.NewAuthHandler(authService, emailService, handlers.AuthCookieConfig{
+		Enabled:     cfg.AuthCookie.Enabled,
+		Domain:      cfg.AuthCookie.Domain,
+		SameSite:    cfg.AuthCookie.SameSite,
+		AccessTTL:   cfg.AccessTokenTTL.Duration,
+		RefreshTTL:  cfg.RefreshTokenTTL.Duration,
+		RefreshPath: routes.APIPrefix + "/auth/refresh",
+	}, logger)
+	healthHandler := handlers.NewHealthHandler(cfg.AppVersion, map[string]handlers.Checker{
+		"database": handlers.CheckerFunc(db.Ping),
+		"redis":    cacheClient,
+		"nats":     msgClient,
+		"storage":  storageClient,
+	}, logger)
+	var jwksHandler *handlers.JWKSHandler
+	if signingKeys.Asymmetric() {
+		jwksHandler = handlers.NewJWKSHandler(signingKeys)
+	}
+	postHandler := handlers.NewPostHandler(postService, userService, logger)
+	mediaHandler := handlers.NewMediaHandler(mediaService, logger)
+	commentHandler := handlers.NewCommentHandler(commentService, logger)
+	userHandler := handlers.NewUserHandler(userService, logger)
+	exportHandler := handlers.NewExportHandler(exportService, logger)
+	tagHandler := handlers.NewTagHandler(postService, userService, logger)
+	feedHandler := handlers.NewFeedHandler(postService, userService, logger)
+	notificationHandler := handlers.NewNotificationHandler(notificationService, logger)
+	webSocketHandler := handlers.NewWebSocketHandler(authService, hub, logger)
+	conversationHandler := handlers.NewConversationHandler(conversationService, logger)
+	deviceHandler := handlers.NewDeviceHandler(deviceService, logger)
+	adminHandler := handlers.NewAdminHandler(userService, postService, logger)
 
 	app := fiber.New(fiber.Config{
 		EnableTrustedProxyCheck: true,
-		TrustedProxies:          []string{"127.0.0.1", "::1"},
-		ReadTimeout:             30 * time.Second,
-		WriteTimeout:            30 * time.Second,
+		TrustedProxies:          cfg.TrustedProxies,
+		ReadTimeout:             cfg.ReadTimeout.Duration,
+		WriteTimeout:            cfg.WriteTimeout.Duration,
 		IdleTimeout:             120 * time.Second,
-	})
+		BodyLimit:               cfg.BodyLimit,
+		ErrorHandler:            httperr.Handler(logger, errorReporter),
 
-	routes.SetupRoutes(app, routes.Config{
-		AuthHandler:    authHandler,
-		HealthHandler:  healthHandler,
-		PostHandler:    postHandler,
-		AuthService:    authService,
-		GQLHandler:     adaptor.HTTPHandler(gqlServer),
-		MetricsHandler: adaptor.HTTPHandler(telemetry.PrometheusHandler()),
-		AllowedOrigins: getEnv("ALLOWED_ORIGINS", "*"),
-		RateLimiter:    rateLimiter,
+		// Bodies past BodyLimit are streamed rather than rejected, so the
+		// upload routes can take larger ones; routes.SetupRoutes enforces
+		// the limits of each route
+		StreamRequestBody:            true,
+		DisablePreParseMultipartForm: true,
 	})
 
+	var playgroundHandler fiber.Handler
 	if cfg.Environment == "development" {
-		routes.SetupDevelopmentRoutes(app, adaptor.HTTPHandler(graphql.NewPlayground("/graphql")))
+		playgroundHandler = adaptor.HTTPHandler(graphql.NewPlayground("/graphql"))
 	}
 
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	routes.SetupRoutes(app, routes.Config{
+		AuthHandler:            authHandler,
+		HealthHandler:          healthHandler,
+		JWKSHandler:            jwksHandler,
+		PostHandler:            postHandler,
+		MediaHandler:           mediaHandler,
+		CommentHandler:         commentHandler,
+		UserHandler:            userHandler,
+		ExportHandler:          exportHandler,
+		TagHandler:             tagHandler,
+		FeedHandler:            feedHandler,
+		NotificationHandler:    notificationHandler,
+		WebSocketHandler:       webSocketHandler,
+		ConversationHandler:    conversationHandler,
+		DeviceHandler:          deviceHandler,
+		AdminHandler:           adminHandler,
+		AuthService:            authService,
+		GQLHandler:             adaptor.HTTPHandler(gqlServer),
+		GQLSubscriptionHandler: gqlSubscriptions,
+		MetricsHandler:         adaptor.HTTPHandler(telemetry.PrometheusHandler()),
+		Telemetry:              telemetry,
+		ErrorReporter:          errorReporter,
+		PlaygroundHandler:      playgroundHandler,
+		AllowedOrigins:         cfg.AllowedOrigins,
+		RateLimiter:            rateLimiter,
+		Idempotency:            idempotency,
+		AccessLogger:           accessLogger,
+		TrustedProxies:         trustedProxies,
+		BodyLimit:              cfg.BodyLimit,
+		UploadBodyLimit:        cfg.UploadBodyLimit,
+		AdminToken:             cfg.AdminToken,
+		CompressionLevel:       compress.Level(cfg.CompressionLevel),
+		CookieAuth:             cfg.AuthCookie.Enabled,
+		SecurityHeaders: middleware.SecurityHeadersConfig{
+			ContentTypeOptions:    cfg.SecurityHeaders.ContentTypeOptions,
+			FrameOptions:          cfg.SecurityHeaders.FrameOptions,
+			ReferrerPolicy:        cfg.SecurityHeaders.ReferrerPolicy,
+			ContentSecurityPolicy: cfg.SecurityHeaders.ContentSecurityPolicy,
+		},
+	})
 
-	go func() {
-		<-c
-		logger.Info("Shutting down server...")
+	// Components are stopped in the reverse of the order they are added:
+	// the HTTP server first, then the workers, so they finish what they hold
+	// while NATS, Redis and Postgres, closed last, are still open. Telemetry
+	// and error reporting stop after all of them, flushing the spans and
+	// errors they recorded.
+	lc := newLifecycle(shutdownTimeout, logger)
+	lc.add(component{name: "error reporter", stop: errorReporter.Flush})
+	lc.add(component{name: "telemetry", stop: telemetry.Shutdown})
+	lc.add(component{name: "postgres", stop: func(context.Context) error {
+		db.Close()
+		return nil
+	}})
+	lc.add(component{name: "redis", stop: func(context.Context) error {
+		return cacheClient.Close()
+	}})
+	lc.add(component{name: "nats", stop: msgClient.Drain})
+	lc.add(component{name: "media worker", run: start(mediaWorker.Start), stop: mediaWorker.Stop})
+	lc.add(component{name: "notification worker", run: start(notificationWorker.Start), stop: notificationWorker.Stop})
+	lc.add(component{name: "push worker", run: start(pushWorker.Start), stop: pushWorker.Stop})
+	lc.add(component{name: "timeline fan-out worker", run: start(fanoutWorker.Start), stop: fanoutWorker.Stop})
+	lc.add(component{name: "websocket hub", run: start(func() error { return hub.Start(msgClient) }), stop: hub.Stop})
+	lc.add(component{name: "post view flusher", run: start(func() error {
+		viewFlusher.Start()
+		return nil
+	}), stop: viewFlusher.Stop})
+	lc.add(component{name: "scheduled post publisher", run: start(func() error {
+		schedulePublisher.Start()
+		return nil
+	}), stop: schedulePublisher.Stop})
 
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		defer cancel()
+	port := getEnv("PORT", "8000")
+	lc.add(component{
+		name: "http server",
+		run: func(context.Context) error {
+			logger.Info("Starting server", "port", port)
+			logger.Info("CORS policy", "allowed_origins", cfg.AllowedOrigins, "allow_credentials", true)
+			return app.Listen(":" + port)
+		},
+		stop: func(ctx context.Context) error {
+			// Websockets are hijacked connections that server shutdown doesn't
+			// wait for; closing the hub ends /ws clients and GraphQL
+			// subscriptions alike
+			hub.Close()
+			return app.ShutdownWithContext(ctx)
+		},
+	})
 
-		if err := app.ShutdownWithContext(ctx); err != nil {
-			logger.Error("Server forced to shutdown", "error", err)
-		}
-	}()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	port := getEnv("PORT", "8000")
-	logger.Info("Starting server", "port", port)
-	if err := app.Listen(":" + port); err != nil {
-		logger.Fatal("Failed to start server", "error", err)
+	if err := lc.Run(ctx); err != nil {
+		logger.Fatal("Server stopped with an error", "error", err)
 	}
 }
 
-func getEnv(key, defaultValue string) string {
-	if value, exists := os.LookupEnv(key); exists {
-		return value
+// shutdownTimeout is how long components get in total to stop
+const shutdownTimeout = 30 * time.Second
+
+// start adapts a Start method that returns once started to a component's run
+func start(fn func() error) func(context.Context) error {
+	return func(context.Context) error {
+		return fn()
 	}
-	return defaultValue
 }
 
-func getEnvAsInt(key string, defaultValue int) int {
+func getEnv(key, defaultValue string) string {
 	if value, exists := os.LookupEnv(key); exists {
-		if intValue, err := strconv.Atoi(value); err == nil {
-			return intValue
-		}
+		return value
 	}
 	return defaultValue
 }
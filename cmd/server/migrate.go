@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"fowergram-backend/internal/infra/database"
+	"fowergram-backend/migrations"
+	"fowergram-backend/pkg/logger"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const migrateUsage = "usage: migrate up | down [steps] | version"
+
+// runMigrate runs the migrate subcommand against databaseURL:
+//
+//	migrate up            applies every pending migration
+//	migrate down [steps]  rolls back the last steps migrations, 1 by default
+//	migrate version       logs the version the database is at
+func runMigrate(databaseURL string, args []string, logger logger.Logger) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	ctx := context.Background()
+	db, err := database.NewPostgreSQLDB(ctx, databaseURL)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	switch args[0] {
+	case "up":
+		return migrateUp(db, logger)
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("steps must be a positive number, got %q", args[1])
+			}
+		}
+
+		migrator, err := database.NewMigrator(db, migrations.FS)
+		if err != nil {
+			return err
+		}
+		defer migrator.Close()
+
+		from, to, err := migrator.Down(steps)
+		if from != to {
+			logger.Info("Rolled back migrations", "from", from, "to", to)
+		}
+		return err
+
+	case "version":
+		migrator, err := database.NewMigrator(db, migrations.FS)
+		if err != nil {
+			return err
+		}
+		defer migrator.Close()
+
+		version, dirty, err := migrator.Version()
+		if err != nil {
+			return err
+		}
+		logger.Info("Database version", "version", version, "dirty", dirty)
+		return nil
+	}
+
+	return errors.New(migrateUsage)
+}
+
+// migrateUp applies every pending migration
+func migrateUp(db *pgxpool.Pool, logger logger.Logger) error {
+	migrator, err := database.NewMigrator(db, migrations.FS)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+
+	from, to, err := migrator.Up()
+	if from != to {
+		logger.Info("Applied migrations", "from", from, "to", to)
+	}
+	if err == nil && from == to {
+		logger.Info("Database is up to date", "version", to)
+	}
+	return err
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"net/mail"
+	"os"
+	"strings"
+
+	"fowergram-backend/internal/domain/user"
+	"fowergram-backend/internal/infra/database"
+	"fowergram-backend/pkg/logger"
+)
+
+// minAdminPasswordLength matches the minimum signup allows
+const minAdminPasswordLength = 8
+
+// runSeedAdmin runs the seed-admin subcommand, creating the first admin
+// account. The email, username and password are taken from the flags or else
+// from ADMIN_EMAIL, ADMIN_USERNAME and ADMIN_PASSWORD, so the password needn't
+// appear in the process list.
+func runSeedAdmin(databaseURL string, args []string, logger logger.Logger) error {
+	flags := flag.NewFlagSet("seed-admin", flag.ContinueOnError)
+	email := flags.String("email", os.Getenv("ADMIN_EMAIL"), "email of the admin account")
+	username := flags.String("username", getEnv("ADMIN_USERNAME", "admin"), "username of the admin account")
+	password := flags.String("password", os.Getenv("ADMIN_PASSWORD"), "password of the admin account")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	*email = strings.ToLower(strings.TrimSpace(*email))
+	if _, err := mail.ParseAddress(*email); err != nil {
+		return errors.New("a valid -email or ADMIN_EMAIL is required")
+	}
+	if *username == "" {
+		return errors.New("-username must not be empty")
+	}
+	if len(*password) < minAdminPasswordLength {
+		return errors.New("-password or ADMIN_PASSWORD must be at least 8 characters")
+	}
+
+	ctx := context.Background()
+	db, err := database.NewPostgreSQLDB(ctx, databaseURL)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	admin, created, err := user.SeedAdmin(ctx, user.NewPostgresRepository(db), *email, *username, *password)
+	if err != nil {
+		return err
+	}
+
+	if created {
+		logger.Info("Created admin account", admin.LogFields(false)...)
+	} else {
+		logger.Info("Admin account already exists, ensured it has the admin role", admin.LogFields(false)...)
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+// productionConfig returns the defaults with every production secret set
+func productionConfig() *Config {
+	cfg := defaults()
+	cfg.Environment = "production"
+	cfg.JWTSecret = "a-production-secret-nobody-can-guess"
+	cfg.SMTP.Username = "mailer"
+	cfg.SMTP.Password = "smtp-password"
+	cfg.Storage.AccessKeyID = "storage-access-key"
+	cfg.Storage.SecretAccessKey = "storage-secret-key"
+	return cfg
+}
+
+func TestValidateSecrets(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(c *Config)
+		wantErr string // Empty when the configuration is valid
+	}{
+		{
+			name:   "development accepts the defaults",
+			modify: func(c *Config) { *c = *defaults() },
+		},
+		{
+			name: "staging accepts the defaults",
+			modify: func(c *Config) {
+				*c = *defaults()
+				c.Environment = "staging"
+			},
+		},
+		{
+			name:   "production with every secret set",
+			modify: func(c *Config) {},
+		},
+		{
+			name:    "production rejects the default JWT secret",
+			modify:  func(c *Config) { c.JWTSecret = defaultJWTSecret },
+			wantErr: "JWT_SECRET must not use the default value in production",
+		},
+		{
+			name:    "production rejects an empty JWT secret",
+			modify:  func(c *Config) { c.JWTSecret = "" },
+			wantErr: "JWT_SECRET must be set in production",
+		},
+		{
+			name: "production doesn't need a JWT secret for asymmetric signing",
+			modify: func(c *Config) {
+				c.JWTSecret = ""
+				c.JWTSigning = JWTSigningConfig{Algorithm: "RS256", PrivateKeyFile: "jwt.pem"}
+			},
+		},
+		{
+			name:    "production rejects empty SMTP credentials",
+			modify:  func(c *Config) { c.SMTP.Username, c.SMTP.Password = "", "" },
+			wantErr: "SMTP_PASSWORD must be set in production",
+		},
+		{
+			name:    "production rejects the default MinIO access key",
+			modify:  func(c *Config) { c.Storage.AccessKeyID = defaultMinIOAccessKey },
+			wantErr: "MINIO_ACCESS_KEY must not use the default value in production",
+		},
+		{
+			name:    "production rejects the default MinIO secret key",
+			modify:  func(c *Config) { c.Storage.SecretAccessKey = defaultMinIOSecretKey },
+			wantErr: "MINIO_SECRET_KEY must not use the default value in production",
+		},
+		{
+			name: "production rejects every default at once",
+			modify: func(c *Config) {
+				*c = *defaults()
+				c.Environment = "production"
+			},
+			wantErr: "MINIO_SECRET_KEY must not use the default value in production",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := productionConfig()
+			tt.modify(cfg)
+
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateReportsEveryError(t *testing.T) {
+	cfg := defaults()
+	cfg.Environment = "production"
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Validate() = nil, want an error")
+	}
+	for _, name := range []string{"JWT_SECRET", "SMTP_USERNAME", "SMTP_PASSWORD", "MINIO_ACCESS_KEY", "MINIO_SECRET_KEY"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("Validate() = %v, want %s reported", err, name)
+		}
+	}
+}
+
+func TestValidateAllowedOrigins(t *testing.T) {
+	tests := []struct {
+		name        string
+		origins     []string
+		development bool
+		wantErr     string // Empty when the origins are valid
+	}{
+		{name: "origins with and without a port", origins: []string{"https://fowergram.com", "http://localhost:3000"}},
+		{name: "subdomain pattern", origins: []string{"https://*.fowergram.com"}},
+		{name: "wildcard in development", origins: []string{"*"}, development: true},
+		{name: "wildcard outside development", origins: []string{"*"}, wantErr: `wildcard origin "*" is only allowed in development`},
+		{name: "empty list", wantErr: "ALLOWED_ORIGINS must list at least one origin"},
+		{name: "no scheme", origins: []string{"fowergram.com"}, wantErr: "scheme must be http or https"},
+		{name: "other scheme", origins: []string{"ftp://fowergram.com"}, wantErr: "scheme must be http or https"},
+		{name: "path", origins: []string{"https://fowergram.com/app"}, wantErr: "must be scheme://host[:port] with no path"},
+		{name: "trailing slash", origins: []string{"https://fowergram.com/"}, wantErr: "must be scheme://host[:port] with no path"},
+		{name: "wildcard past the leftmost label", origins: []string{"https://app.*.fowergram.com"}, wantErr: "only the leftmost label may be a wildcard"},
+		{
+			name:    "every invalid origin is reported",
+			origins: []string{"https://fowergram.com", "fowergram.com", "https://fowergram.com/app"},
+			wantErr: `invalid origin "fowergram.com": scheme must be http or https` + "\n" +
+				`ALLOWED_ORIGINS: invalid origin "https://fowergram.com/app"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := productionConfig()
+			if tt.development {
+				cfg.Environment = "development"
+			}
+			cfg.AllowedOrigins = tt.origins
+
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Duration is a time.Duration that decodes from strings such as "1h" or "720h"
+// in both YAML and JSON config files
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalYAML parses a duration string
+func (d *Duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var value string
+	if err := unmarshal(&value); err != nil {
+		return err
+	}
+	return d.parse(value)
+}
+
+// UnmarshalJSON parses a duration string
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	value, err := strconv.Unquote(string(data))
+	if err != nil {
+		return fmt.Errorf("duration must be a string such as \"1h\", got %s", data)
+	}
+	return d.parse(value)
+}
+
+func (d *Duration) parse(value string) error {
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
+}
+
+// envParser reads typed environment variables, collecting parse errors
+// so that every malformed value is reported at once
+type envParser struct {
+	errs []error
+}
+
+// Bool gets a boolean environment variable with a fallback value
+func (p *envParser) Bool(key string, fallback bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		p.errs = append(p.errs, fmt.Errorf("%s: invalid boolean %q", key, value))
+		return fallback
+	}
+	return parsed
+}
+
+// Int gets an integer environment variable with a fallback value
+func (p *envParser) Int(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		p.errs = append(p.errs, fmt.Errorf("%s: invalid integer %q", key, value))
+		return fallback
+	}
+	return parsed
+}
+
+// Duration gets a duration environment variable (e.g. "1h", "720h") with a fallback value
+func (p *envParser) Duration(key string, fallback Duration) Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		p.errs = append(p.errs, fmt.Errorf("%s: invalid duration %q", key, value))
+		return fallback
+	}
+	return Duration{parsed}
+}
+
+// Map gets a comma-separated list of key=value pairs (e.g.
+// "api-key=secret,team=feed") with a fallback value. Keys and values are
+// trimmed and empty entries dropped.
+func (p *envParser) Map(key string, fallback map[string]string) map[string]string {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	parsed := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(pair, "=")
+		if k = strings.TrimSpace(k); !ok || k == "" {
+			p.errs = append(p.errs, fmt.Errorf("%s: invalid key=value pair %q", key, pair))
+			return fallback
+		}
+		parsed[k] = strings.TrimSpace(v)
+	}
+	return parsed
+}
+
+// Err returns all collected parse errors, or nil
+func (p *envParser) Err() error {
+	if len(p.errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid environment: %w", errors.Join(p.errs...))
+}
@@ -0,0 +1,190 @@
+package config
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestEnvParserDuration(t *testing.T) {
+	fallback := Duration{time.Minute}
+
+	tests := []struct {
+		name    string
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{name: "unset uses the fallback", value: "", want: time.Minute},
+		{name: "hours", value: "1h", want: time.Hour},
+		{name: "long hours", value: "720h", want: 720 * time.Hour},
+		{name: "compound", value: "1h30m", want: 90 * time.Minute},
+		{name: "milliseconds", value: "250ms", want: 250 * time.Millisecond},
+		{name: "missing unit", value: "30", want: time.Minute, wantErr: true},
+		{name: "unknown unit", value: "3 days", want: time.Minute, wantErr: true},
+		{name: "garbage", value: "soon", want: time.Minute, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TEST_DURATION", tt.value)
+			env := &envParser{}
+
+			got := env.Duration("TEST_DURATION", fallback)
+			if got.Duration != tt.want {
+				t.Errorf("Duration() = %v, want %v", got.Duration, tt.want)
+			}
+
+			err := env.Err()
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("Err() = %v, want error: %v", err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), `TEST_DURATION: invalid duration "`+tt.value+`"`) {
+				t.Errorf("Err() = %v, want the variable and value named", err)
+			}
+		})
+	}
+}
+
+func TestEnvParserInt(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{name: "unset uses the fallback", value: "", want: 587},
+		{name: "valid", value: "2525", want: 2525},
+		{name: "negative", value: "-1", want: -1},
+		{name: "decimal", value: "1.5", want: 587, wantErr: true},
+		{name: "garbage", value: "port", want: 587, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TEST_INT", tt.value)
+			env := &envParser{}
+
+			if got := env.Int("TEST_INT", 587); got != tt.want {
+				t.Errorf("Int() = %d, want %d", got, tt.want)
+			}
+			if err := env.Err(); tt.wantErr != (err != nil) {
+				t.Fatalf("Err() = %v, want error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEnvParserCollectsEveryError(t *testing.T) {
+	t.Setenv("ACCESS_TOKEN_TTL", "1 hour")
+	t.Setenv("REFRESH_TOKEN_TTL", "720h")
+	t.Setenv("SMTP_PORT", "smtp")
+
+	cfg := defaults()
+	err := cfg.applyEnv()
+	if err == nil {
+		t.Fatal("applyEnv() = nil, want an error")
+	}
+	for _, want := range []string{`ACCESS_TOKEN_TTL: invalid duration "1 hour"`, `SMTP_PORT: invalid integer "smtp"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("applyEnv() = %v, want it to contain %q", err, want)
+		}
+	}
+	if cfg.RefreshTokenTTL.Duration != 720*time.Hour {
+		t.Errorf("RefreshTokenTTL = %v, want the valid value applied", cfg.RefreshTokenTTL)
+	}
+}
+
+func TestDurationUnmarshal(t *testing.T) {
+	tests := []struct {
+		name    string
+		decode  func(data string, d *Duration) error
+		data    string
+		want    time.Duration
+		wantErr bool
+	}{
+		{name: "YAML", decode: decodeYAML, data: "720h", want: 720 * time.Hour},
+		{name: "YAML invalid", decode: decodeYAML, data: "forever", wantErr: true},
+		{name: "JSON", decode: decodeJSON, data: `"15m"`, want: 15 * time.Minute},
+		{name: "JSON invalid", decode: decodeJSON, data: `"forever"`, wantErr: true},
+		{name: "JSON number", decode: decodeJSON, data: `3600`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var d Duration
+			err := tt.decode(tt.data, &d)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("decoding %s = %v, want an error", tt.data, d.Duration)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decoding %s: %v", tt.data, err)
+			}
+			if d.Duration != tt.want {
+				t.Errorf("decoding %s = %v, want %v", tt.data, d.Duration, tt.want)
+			}
+		})
+	}
+}
+
+func decodeYAML(data string, d *Duration) error {
+	return yaml.Unmarshal([]byte(data), d)
+}
+
+func decodeJSON(data string, d *Duration) error {
+	return json.Unmarshal([]byte(data), d)
+}
+
+func TestValidateDurations(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(c *Config)
+		wantErr string
+	}{
+		{name: "defaults", modify: func(c *Config) {}},
+		{
+			name:    "zero access token TTL",
+			modify:  func(c *Config) { c.AccessTokenTTL = Duration{} },
+			wantErr: "ACCESS_TOKEN_TTL must be a positive duration",
+		},
+		{
+			name:    "negative refresh token TTL",
+			modify:  func(c *Config) { c.RefreshTokenTTL = Duration{-time.Hour} },
+			wantErr: "REFRESH_TOKEN_TTL must be a positive duration",
+		},
+		{
+			name:    "zero read timeout",
+			modify:  func(c *Config) { c.ReadTimeout = Duration{} },
+			wantErr: "READ_TIMEOUT must be a positive duration",
+		},
+		{
+			name:    "zero write timeout",
+			modify:  func(c *Config) { c.WriteTimeout = Duration{} },
+			wantErr: "WRITE_TIMEOUT must be a positive duration",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := defaults()
+			tt.modify(cfg)
+
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfigFile writes a config file named name into a temporary directory
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write %s: %v", path, err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	yamlFile := `
+app_name: from-file
+environment: staging
+read_timeout: 5s
+nats_url: nats://file:4222
+smtp:
+  host: smtp.file.example
+  port: 2525
+`
+	jsonFile := `{
+	"app_name": "from-file",
+	"environment": "staging",
+	"read_timeout": "5s",
+	"nats_url": "nats://file:4222",
+	"smtp": {"host": "smtp.file.example", "port": 2525}
+}`
+
+	tests := []struct {
+		name string
+		file string // File name and contents; no CONFIG_FILE when empty
+		body string
+		env  map[string]string
+		want func(t *testing.T, c *Config)
+	}{
+		{
+			name: "env only keeps the defaults",
+			want: func(t *testing.T, c *Config) {
+				if c.AppName != "fowergram-backend" || c.ReadTimeout.Duration != 30*time.Second {
+					t.Errorf("got app %q, read timeout %v; want the defaults", c.AppName, c.ReadTimeout)
+				}
+			},
+		},
+		{
+			name: "env only overrides the defaults",
+			env:  map[string]string{"APP_NAME": "from-env", "READ_TIMEOUT": "7s"},
+			want: func(t *testing.T, c *Config) {
+				if c.AppName != "from-env" || c.ReadTimeout.Duration != 7*time.Second {
+					t.Errorf("got app %q, read timeout %v; want from-env, 7s", c.AppName, c.ReadTimeout)
+				}
+			},
+		},
+		{
+			name: "rate limiting fails open by default",
+			want: func(t *testing.T, c *Config) {
+				if !c.RateLimitFailOpen {
+					t.Error("RateLimitFailOpen = false, want the default true")
+				}
+			},
+		},
+		{
+			name: "env makes rate limiting fail closed",
+			env:  map[string]string{"RATE_LIMIT_FAIL_OPEN": "false"},
+			want: func(t *testing.T, c *Config) {
+				if c.RateLimitFailOpen {
+					t.Error("RateLimitFailOpen = true, want false from the env")
+				}
+			},
+		},
+		{
+			name: "YAML file overrides the defaults",
+			file: "config.yaml",
+			body: yamlFile,
+			want: func(t *testing.T, c *Config) {
+				if c.AppName != "from-file" || c.Environment != "staging" || c.ReadTimeout.Duration != 5*time.Second {
+					t.Errorf("got app %q, environment %q, read timeout %v; want the file's", c.AppName, c.Environment, c.ReadTimeout)
+				}
+				if c.SMTP.Host != "smtp.file.example" || c.SMTP.Port != 2525 {
+					t.Errorf("got SMTP %s:%d, want smtp.file.example:2525", c.SMTP.Host, c.SMTP.Port)
+				}
+				if c.SMTP.FromName != "Fowergram" {
+					t.Errorf("got SMTP from name %q, want the default kept", c.SMTP.FromName)
+				}
+			},
+		},
+		{
+			name: "env overrides the YAML file",
+			file: "config.yml",
+			body: yamlFile,
+			env:  map[string]string{"APP_NAME": "from-env", "SMTP_PORT": "465"},
+			want: func(t *testing.T, c *Config) {
+				if c.AppName != "from-env" || c.SMTP.Port != 465 {
+					t.Errorf("got app %q, SMTP port %d; want from-env, 465", c.AppName, c.SMTP.Port)
+				}
+				if c.NatsURL != "nats://file:4222" || c.SMTP.Host != "smtp.file.example" {
+					t.Errorf("got NATS %q, SMTP host %q; want the file's", c.NatsURL, c.SMTP.Host)
+				}
+			},
+		},
+		{
+			name: "env lists allowed origins",
+			env:  map[string]string{"ALLOWED_ORIGINS": " https://fowergram.com, ,https://*.fowergram.com"},
+			want: func(t *testing.T, c *Config) {
+				want := []string{"https://fowergram.com", "https://*.fowergram.com"}
+				if !slices.Equal(c.AllowedOrigins, want) {
+					t.Errorf("got allowed origins %q, want %q", c.AllowedOrigins, want)
+				}
+			},
+		},
+		{
+			name: "env overrides the JSON file",
+			file: "config.json",
+			body: jsonFile,
+			env:  map[string]string{"READ_TIMEOUT": "9s"},
+			want: func(t *testing.T, c *Config) {
+				if c.ReadTimeout.Duration != 9*time.Second || c.AppName != "from-file" {
+					t.Errorf("got read timeout %v, app %q; want 9s, from-file", c.ReadTimeout, c.AppName)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t, "CONFIG_FILE", "APP_NAME", "ENVIRONMENT", "READ_TIMEOUT", "NATS_URL", "SMTP_HOST", "SMTP_PORT", "SMTP_FROM_NAME", "RATE_LIMIT_FAIL_OPEN", "ALLOWED_ORIGINS")
+			if tt.file != "" {
+				t.Setenv("CONFIG_FILE", writeConfigFile(t, tt.file, tt.body))
+			}
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			cfg, err := Load()
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			tt.want(t, cfg)
+		})
+	}
+}
+
+func TestLoadMalformedFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		body    string
+		wantErr string
+	}{
+		{
+			name:    "invalid YAML",
+			file:    "config.yaml",
+			body:    "app_name: [unterminated",
+			wantErr: "failed to parse config file",
+		},
+		{
+			name:    "invalid JSON",
+			file:    "config.json",
+			body:    `{"app_name": "x",}`,
+			wantErr: "failed to parse config file",
+		},
+		{
+			name:    "unknown YAML key",
+			file:    "config.yaml",
+			body:    "app_nmae: typo",
+			wantErr: "app_nmae",
+		},
+		{
+			name:    "unknown JSON key",
+			file:    "config.json",
+			body:    `{"app_nmae": "typo"}`,
+			wantErr: "app_nmae",
+		},
+		{
+			name:    "malformed duration",
+			file:    "config.yaml",
+			body:    "read_timeout: soon",
+			wantErr: "failed to parse config file",
+		},
+		{
+			name:    "unsupported extension",
+			file:    "config.toml",
+			body:    `app_name = "x"`,
+			wantErr: `unsupported config file extension ".toml"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CONFIG_FILE", writeConfigFile(t, tt.file, tt.body))
+
+			_, err := Load()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Load() error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	t.Setenv("CONFIG_FILE", filepath.Join(t.TempDir(), "missing.yaml"))
+
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "failed to read config file") {
+		t.Fatalf("Load() error = %v, want a read error", err)
+	}
+}
+
+// clearEnv unsets keys for the duration of the test; the config treats an
+// empty variable as unset
+func clearEnv(t *testing.T, keys ...string) {
+	t.Helper()
+	for _, key := range keys {
+		t.Setenv(key, "")
+	}
+}
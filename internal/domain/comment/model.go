@@ -0,0 +1,128 @@
+package comment
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MaxBodyLength is the maximum number of characters in a comment body
+const MaxBodyLength = 2200
+
+// Common comment errors
+var (
+	ErrCommentNotFound  = errors.New("comment not found")
+	ErrInvalidParent    = errors.New("parent comment does not belong to this post")
+	ErrInvalidBody      = errors.New("comment body must be between 1 and 2200 characters")
+	ErrInvalidCursor    = errors.New("invalid cursor")
+	ErrForbidden        = errors.New("only the comment author or post owner can delete this comment")
+	ErrCommentsDisabled = errors.New("comments are turned off for this post")
+)
+
+// Comment represents a comment on a post. Replies are one level deep:
+// ParentID always points at a top-level comment.
+type Comment struct {
+	ID         uuid.UUID  `json:"id" db:"id"`
+	PostID     uuid.UUID  `json:"post_id" db:"post_id"`
+	UserID     uuid.UUID  `json:"user_id" db:"user_id"`
+	Username   string     `json:"username" db:"username"`
+	ParentID   *uuid.UUID `json:"parent_id,omitempty" db:"parent_id"`
+	Body       string     `json:"body" db:"content"`
+	ReplyCount int        `json:"reply_count" db:"reply_count"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	DeletedAt  *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+}
+
+// CreateCommentInput represents input for creating a comment or reply
+type CreateCommentInput struct {
+	Body     string
+	ParentID *uuid.UUID
+}
+
+// Cursor marks the last comment of a page; the next page starts strictly after it
+type Cursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// Encode returns the opaque string form of the cursor
+func (c Cursor) Encode() string {
+	raw := c.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + c.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeCursor parses a cursor produced by Encode. An empty string yields nil.
+func DecodeCursor(s string) (*Cursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	createdAt, id, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, createdAt)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	parsedID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &Cursor{CreatedAt: t, ID: parsedID}, nil
+}
+
+// Page is a page of comments ordered oldest-first
+type Page struct {
+	Comments   []*Comment
+	NextCursor string
+}
+
+// newPage trims a result fetched with limit+1 rows and sets the next cursor
+func newPage(comments []*Comment, limit int) *Page {
+	page := &Page{Comments: comments}
+	if len(comments) > limit {
+		page.Comments = comments[:limit]
+		last := page.Comments[limit-1]
+		page.NextCursor = Cursor{CreatedAt: last.CreatedAt, ID: last.ID}.Encode()
+	}
+	return page
+}
+
+// Repository defines the interface for comment data persistence
+type Repository interface {
+	Create(ctx context.Context, comment *Comment) error
+	GetByID(ctx context.Context, id uuid.UUID) (*Comment, error)
+	ListTopLevel(ctx context.Context, postID uuid.UUID, after *Cursor, limit int) ([]*Comment, error)
+	ListReplies(ctx context.Context, parentID uuid.UUID, after *Cursor, limit int) ([]*Comment, error)
+	Delete(ctx context.Context, comment *Comment) error
+}
+
+// Service defines the interface for comment business logic
+type Service interface {
+	CreateComment(ctx context.Context, postID, userID uuid.UUID, input CreateCommentInput) (*Comment, error)
+	ListComments(ctx context.Context, postID, viewerID uuid.UUID, cursor string, limit int) (*Page, error)
+	ListReplies(ctx context.Context, commentID, viewerID uuid.UUID, cursor string, limit int) (*Page, error)
+	DeleteComment(ctx context.Context, commentID, userID uuid.UUID) error
+}
+
+// validateBody checks the comment body length in characters
+func validateBody(body string) error {
+	n := len([]rune(strings.TrimSpace(body)))
+	if n == 0 || n > MaxBodyLength {
+		return ErrInvalidBody
+	}
+	return nil
+}
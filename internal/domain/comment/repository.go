@@ -0,0 +1,184 @@
+package comment
+
+import (
+	"context"
+	"fmt"
+
+	"fowergram-backend/internal/infra/database"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// postgresRepository implements Repository using PostgreSQL
+type postgresRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewRepository creates a new PostgreSQL comment repository
+func NewRepository(db *pgxpool.Pool) Repository {
+	return &postgresRepository{db: db}
+}
+
+// commentColumns lists the columns read by scanComment, for comments aliased
+// as c and the author aliased as u
+const commentColumns = `
+	c.id, c.post_id, c.user_id, u.username, c.parent_id, c.content,
+	(SELECT COUNT(*) FROM comments r WHERE r.parent_id = c.id AND r.deleted_at IS NULL),
+	c.created_at, c.deleted_at`
+
+// Create inserts a comment and bumps the post's comments_count
+func (r *postgresRepository) Create(ctx context.Context, comment *Comment) error {
+	return database.WithTx(ctx, r.db, func(tx database.DB) error {
+		insertQuery := `
+			INSERT INTO comments (id, post_id, user_id, parent_id, content, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $6)
+		`
+		_, err := tx.Exec(ctx, insertQuery,
+			comment.ID, comment.PostID, comment.UserID, comment.ParentID, comment.Body, comment.CreatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to create comment: %w", err)
+		}
+
+		updateQuery := `
+			UPDATE posts SET comments_count = comments_count + 1
+			WHERE id = $1
+		`
+		if _, err := tx.Exec(ctx, updateQuery, comment.PostID); err != nil {
+			return fmt.Errorf("failed to update comments count: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// GetByID retrieves a live comment by ID
+func (r *postgresRepository) GetByID(ctx context.Context, id uuid.UUID) (*Comment, error) {
+	query := `SELECT ` + commentColumns + `
+		FROM comments c
+		JOIN users u ON u.id = c.user_id
+		WHERE c.id = $1 AND c.deleted_at IS NULL
+	`
+
+	comment, err := scanComment(r.db.QueryRow(ctx, query, id))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrCommentNotFound
+		}
+		return nil, fmt.Errorf("failed to get comment: %w", err)
+	}
+
+	return comment, nil
+}
+
+// ListTopLevel retrieves top-level comments on a post, oldest first, starting after the cursor
+func (r *postgresRepository) ListTopLevel(ctx context.Context, postID uuid.UUID, after *Cursor, limit int) ([]*Comment, error) {
+	query := `SELECT ` + commentColumns + `
+		FROM comments c
+		JOIN users u ON u.id = c.user_id
+		WHERE c.post_id = $1 AND c.parent_id IS NULL AND c.deleted_at IS NULL
+			AND ($2::timestamptz IS NULL OR (c.created_at, c.id) > ($2, $3))
+		ORDER BY c.created_at, c.id
+		LIMIT $4
+	`
+
+	return r.list(ctx, query, postID, after, limit)
+}
+
+// ListReplies retrieves replies to a comment, oldest first, starting after the cursor
+func (r *postgresRepository) ListReplies(ctx context.Context, parentID uuid.UUID, after *Cursor, limit int) ([]*Comment, error) {
+	query := `SELECT ` + commentColumns + `
+		FROM comments c
+		JOIN users u ON u.id = c.user_id
+		WHERE c.parent_id = $1 AND c.deleted_at IS NULL
+			AND ($2::timestamptz IS NULL OR (c.created_at, c.id) > ($2, $3))
+		ORDER BY c.created_at, c.id
+		LIMIT $4
+	`
+
+	return r.list(ctx, query, parentID, after, limit)
+}
+
+// Delete soft-deletes a comment together with its replies and lowers the
+// post's comments_count by the number of comments removed
+func (r *postgresRepository) Delete(ctx context.Context, comment *Comment) error {
+	return database.WithTx(ctx, r.db, func(tx database.DB) error {
+		deleteQuery := `
+			UPDATE comments SET deleted_at = NOW()
+			WHERE (id = $1 OR parent_id = $1) AND deleted_at IS NULL
+		`
+		tag, err := tx.Exec(ctx, deleteQuery, comment.ID)
+		if err != nil {
+			return fmt.Errorf("failed to delete comment: %w", err)
+		}
+
+		if tag.RowsAffected() == 0 {
+			return ErrCommentNotFound
+		}
+
+		updateQuery := `
+			UPDATE posts SET comments_count = GREATEST(comments_count - $2, 0)
+			WHERE id = $1
+		`
+		if _, err := tx.Exec(ctx, updateQuery, comment.PostID, tag.RowsAffected()); err != nil {
+			return fmt.Errorf("failed to update comments count: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// list runs a paginated comment query bound as (id, cursor time, cursor id, limit)
+func (r *postgresRepository) list(ctx context.Context, query string, id uuid.UUID, after *Cursor, limit int) ([]*Comment, error) {
+	var (
+		afterTime interface{}
+		afterID   interface{}
+	)
+	if after != nil {
+		afterTime = after.CreatedAt
+		afterID = after.ID
+	}
+
+	rows, err := r.db.Query(ctx, query, id, afterTime, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list comments: %w", err)
+	}
+	defer rows.Close()
+
+	var comments []*Comment
+	for rows.Next() {
+		comment, err := scanComment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan comment: %w", err)
+		}
+		comments = append(comments, comment)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate comments: %w", err)
+	}
+
+	return comments, nil
+}
+
+// scanComment scans a row selected with commentColumns
+func scanComment(row pgx.Row) (*Comment, error) {
+	var comment Comment
+	err := row.Scan(
+		&comment.ID,
+		&comment.PostID,
+		&comment.UserID,
+		&comment.Username,
+		&comment.ParentID,
+		&comment.Body,
+		&comment.ReplyCount,
+		&comment.CreatedAt,
+		&comment.DeletedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
@@ -0,0 +1,217 @@
+package comment
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"fowergram-backend/internal/domain/moderation"
+	"fowergram-backend/internal/domain/post"
+	"fowergram-backend/internal/events"
+	"fowergram-backend/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// service implements Service
+type service struct {
+	repo       Repository
+	postRepo   post.Repository
+	moderation moderation.Service
+	publisher  events.Publisher
+	logger     logger.Logger
+}
+
+// NewService creates a new comment service
+func NewService(repo Repository, postRepo post.Repository, moderationService moderation.Service, publisher events.Publisher, logger logger.Logger) Service {
+	return &service{
+		repo:       repo,
+		postRepo:   postRepo,
+		moderation: moderationService,
+		publisher:  publisher,
+		logger:     logger,
+	}
+}
+
+// CreateComment adds a comment or reply to a post the user can see.
+// Replying to a reply attaches the new comment to the same top-level thread.
+// Bodies the moderator rejects return moderation.ErrContentRejected; flagged
+// comments are posted and held for review.
+func (s *service) CreateComment(ctx context.Context, postID, userID uuid.UUID, input CreateCommentInput) (*Comment, error) {
+	if err := validateBody(input.Body); err != nil {
+		return nil, err
+	}
+
+	p, err := s.postRepo.GetVisibleByID(ctx, postID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if p.CommentsDisabled {
+		return nil, ErrCommentsDisabled
+	}
+
+	var parentID *uuid.UUID
+	if input.ParentID != nil {
+		parent, err := s.repo.GetByID(ctx, *input.ParentID)
+		if err != nil {
+			if err == ErrCommentNotFound {
+				return nil, ErrInvalidParent
+			}
+			return nil, err
+		}
+		if parent.PostID != postID {
+			return nil, ErrInvalidParent
+		}
+
+		parentID = &parent.ID
+		if parent.ParentID != nil {
+			parentID = parent.ParentID
+		}
+	}
+
+	verdict, err := s.moderation.Screen(ctx, input.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	comment := &Comment{
+		ID:        uuid.New(),
+		PostID:    postID,
+		UserID:    userID,
+		ParentID:  parentID,
+		Body:      strings.TrimSpace(input.Body),
+		CreatedAt: time.Now(),
+	}
+
+	if err := s.repo.Create(ctx, comment); err != nil {
+		return nil, err
+	}
+	if err := s.moderation.Hold(ctx, moderation.ContentComment, comment.ID, userID, verdict); err != nil {
+		s.logger.Error("Failed to hold comment for review", "comment_id", comment.ID, "error", err)
+	}
+
+	// Reload to pick up the author's username
+	created, err := s.repo.GetByID(ctx, comment.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.publish(ctx, userID, events.PostCommented{
+		CommentID: created.ID,
+		PostID:    p.ID,
+		AuthorID:  p.UserID,
+		UserID:    userID,
+		ParentID:  created.ParentID,
+		CreatedAt: created.CreatedAt,
+	})
+	s.publishMentions(ctx, created)
+
+	return created, nil
+}
+
+// ListComments lists top-level comments on a post the viewer can see
+func (s *service) ListComments(ctx context.Context, postID, viewerID uuid.UUID, cursor string, limit int) (*Page, error) {
+	after, err := DecodeCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := s.postRepo.GetVisibleByID(ctx, postID, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	if hiddenFrom(p, viewerID) {
+		return &Page{}, nil
+	}
+
+	comments, err := s.repo.ListTopLevel(ctx, postID, after, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	return newPage(comments, limit), nil
+}
+
+// ListReplies lists replies to a top-level comment on a post the viewer can see
+func (s *service) ListReplies(ctx context.Context, commentID, viewerID uuid.UUID, cursor string, limit int) (*Page, error) {
+	after, err := DecodeCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	parent, err := s.repo.GetByID(ctx, commentID)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := s.postRepo.GetVisibleByID(ctx, parent.PostID, viewerID)
+	if err != nil {
+		if err == post.ErrPostNotFound {
+			return nil, ErrCommentNotFound
+		}
+		return nil, err
+	}
+	if hiddenFrom(p, viewerID) {
+		return nil, ErrCommentNotFound
+	}
+
+	comments, err := s.repo.ListReplies(ctx, commentID, after, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	return newPage(comments, limit), nil
+}
+
+// DeleteComment removes a comment and its replies. Only the comment author
+// or the owner of the post may delete it.
+func (s *service) DeleteComment(ctx context.Context, commentID, userID uuid.UUID) error {
+	comment, err := s.repo.GetByID(ctx, commentID)
+	if err != nil {
+		return err
+	}
+
+	if comment.UserID != userID {
+		p, err := s.postRepo.GetByID(ctx, comment.PostID)
+		if err != nil {
+			return err
+		}
+		if p.UserID != userID {
+			return ErrForbidden
+		}
+	}
+
+	return s.repo.Delete(ctx, comment)
+}
+
+// hiddenFrom reports whether the post's comments are hidden from the viewer:
+// once comments are turned off only the post owner still sees them
+func hiddenFrom(p *post.Post, viewerID uuid.UUID) bool {
+	return p.CommentsDisabled && p.UserID != viewerID
+}
+
+// publishMentions records the users a comment mentions and notifies them.
+// Failures are logged, as the comment is already posted.
+func (s *service) publishMentions(ctx context.Context, comment *Comment) {
+	usernames := post.ExtractMentions(comment.Body)
+	userIDs, err := s.postRepo.AddMentions(ctx, comment.UserID, comment.PostID, &comment.ID, usernames, comment.CreatedAt)
+	if err != nil {
+		s.logger.Error("Failed to add mentions", "comment_id", comment.ID, "error", err)
+		return
+	}
+	for _, userID := range userIDs {
+		s.publish(ctx, comment.UserID, events.UserMentioned{
+			UserID:    userID,
+			PostID:    comment.PostID,
+			CommentID: &comment.ID,
+			CreatedAt: comment.CreatedAt,
+		})
+	}
+}
+
+// publish sends a best-effort event; failures are logged, not returned
+func (s *service) publish(ctx context.Context, actorID uuid.UUID, payload events.Payload) {
+	if err := s.publisher.Publish(ctx, actorID, payload); err != nil {
+		s.logger.Error("Failed to publish event", "type", payload.EventType(), "error", err)
+	}
+}
@@ -0,0 +1,228 @@
+package comment
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"fowergram-backend/internal/domain/moderation"
+	"fowergram-backend/internal/domain/post"
+	"fowergram-backend/internal/events"
+	"fowergram-backend/internal/infra/messaging"
+	"fowergram-backend/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// fakeRepository keeps comments in memory, oldest first
+type fakeRepository struct {
+	Repository
+
+	mu       sync.Mutex
+	comments []*Comment
+}
+
+func (r *fakeRepository) Create(ctx context.Context, comment *Comment) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := *comment
+	r.comments = append(r.comments, &stored)
+	return nil
+}
+
+func (r *fakeRepository) GetByID(ctx context.Context, id uuid.UUID) (*Comment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, comment := range r.comments {
+		if comment.ID == id && comment.DeletedAt == nil {
+			found := *comment
+			return &found, nil
+		}
+	}
+	return nil, ErrCommentNotFound
+}
+
+func (r *fakeRepository) ListTopLevel(ctx context.Context, postID uuid.UUID, after *Cursor, limit int) ([]*Comment, error) {
+	return r.list(func(c *Comment) bool { return c.PostID == postID && c.ParentID == nil }, limit), nil
+}
+
+func (r *fakeRepository) ListReplies(ctx context.Context, parentID uuid.UUID, after *Cursor, limit int) ([]*Comment, error) {
+	return r.list(func(c *Comment) bool { return c.ParentID != nil && *c.ParentID == parentID }, limit), nil
+}
+
+// Delete soft-deletes a comment with its replies
+func (r *fakeRepository) Delete(ctx context.Context, comment *Comment) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	deleted := 0
+	for _, c := range r.comments {
+		if c.DeletedAt == nil && (c.ID == comment.ID || (c.ParentID != nil && *c.ParentID == comment.ID)) {
+			c.DeletedAt = &now
+			deleted++
+		}
+	}
+	if deleted == 0 {
+		return ErrCommentNotFound
+	}
+	return nil
+}
+
+// list returns up to limit live comments matching keep
+func (r *fakeRepository) list(keep func(*Comment) bool, limit int) []*Comment {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var comments []*Comment
+	for _, comment := range r.comments {
+		if comment.DeletedAt == nil && keep(comment) && len(comments) < limit {
+			found := *comment
+			comments = append(comments, &found)
+		}
+	}
+	return comments
+}
+
+// fakePostRepository serves posts that aren't soft-deleted. Other methods
+// are left to the embedded nil post.Repository.
+type fakePostRepository struct {
+	post.Repository
+
+	mu      sync.Mutex
+	posts   map[uuid.UUID]post.Post
+	deleted map[uuid.UUID]bool
+}
+
+func (r *fakePostRepository) GetByID(ctx context.Context, id uuid.UUID) (*post.Post, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.posts[id]
+	if !ok || r.deleted[id] {
+		return nil, post.ErrPostNotFound
+	}
+	return &p, nil
+}
+
+func (r *fakePostRepository) GetVisibleByID(ctx context.Context, id, viewerID uuid.UUID) (*post.Post, error) {
+	return r.GetByID(ctx, id)
+}
+
+func (r *fakePostRepository) AddMentions(ctx context.Context, authorID, postID uuid.UUID, commentID *uuid.UUID, usernames []string, createdAt time.Time) ([]uuid.UUID, error) {
+	return nil, nil
+}
+
+type commentFixture struct {
+	service Service
+	repo    *fakeRepository
+	posts   *fakePostRepository
+}
+
+func newCommentFixture(t *testing.T) *commentFixture {
+	t.Helper()
+	log := logger.NewZapLogger()
+	f := &commentFixture{
+		repo: &fakeRepository{},
+		posts: &fakePostRepository{
+			posts:   make(map[uuid.UUID]post.Post),
+			deleted: make(map[uuid.UUID]bool),
+		},
+	}
+	f.service = NewService(
+		f.repo,
+		f.posts,
+		moderation.NewService(nil, moderation.NewWordlistModerator(nil, nil), log),
+		events.NewNATSPublisher(messaging.NewRecordingClient(), log),
+		log,
+	)
+	return f
+}
+
+// addPost stores a post by authorID
+func (f *commentFixture) addPost(authorID uuid.UUID, commentsDisabled bool) uuid.UUID {
+	f.posts.mu.Lock()
+	defer f.posts.mu.Unlock()
+	id := uuid.New()
+	f.posts.posts[id] = post.Post{ID: id, UserID: authorID, CommentsDisabled: commentsDisabled, Status: post.StatusPublished}
+	return id
+}
+
+// comment adds a comment by userID, replying to parentID when it's set
+func (f *commentFixture) comment(t *testing.T, postID, userID uuid.UUID, parentID *uuid.UUID) *Comment {
+	t.Helper()
+	comment, err := f.service.CreateComment(context.Background(), postID, userID, CreateCommentInput{
+		Body:     "Nice",
+		ParentID: parentID,
+	})
+	if err != nil {
+		t.Fatalf("CreateComment: %v", err)
+	}
+	return comment
+}
+
+func TestCommentsOnDeletedPosts(t *testing.T) {
+	f := newCommentFixture(t)
+	ctx := context.Background()
+	author, commenter := uuid.New(), uuid.New()
+	postID := f.addPost(author, false)
+	top := f.comment(t, postID, commenter, nil)
+	f.comment(t, postID, commenter, &top.ID)
+
+	f.posts.deleted[postID] = true
+
+	tests := []struct {
+		name    string
+		call    func() error
+		wantErr error
+	}{
+		{
+			name: "new comment",
+			call: func() error {
+				_, err := f.service.CreateComment(ctx, postID, commenter, CreateCommentInput{Body: "Still here?"})
+				return err
+			},
+			wantErr: post.ErrPostNotFound,
+		},
+		{
+			name: "new reply",
+			call: func() error {
+				_, err := f.service.CreateComment(ctx, postID, commenter, CreateCommentInput{Body: "Hello?", ParentID: &top.ID})
+				return err
+			},
+			wantErr: post.ErrPostNotFound,
+		},
+		{
+			name: "listing comments",
+			call: func() error {
+				_, err := f.service.ListComments(ctx, postID, author, "", 20)
+				return err
+			},
+			wantErr: post.ErrPostNotFound,
+		},
+		{
+			name: "listing replies",
+			call: func() error {
+				_, err := f.service.ListReplies(ctx, top.ID, commenter, "", 20)
+				return err
+			},
+			wantErr: ErrCommentNotFound,
+		},
+		{
+			// Left for moderation, the comment can still be removed by its author
+			name: "comment author deletes the comment",
+			call: func() error { return f.service.DeleteComment(ctx, top.ID, commenter) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(); !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if n := len(f.repo.comments); n != 2 {
+		t.Errorf("stored %d comments, want the 2 made before the delete", n)
+	}
+}
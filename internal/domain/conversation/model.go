@@ -0,0 +1,144 @@
+package conversation
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MaxBodyLength is the maximum number of characters in a message body
+const MaxBodyLength = 1000
+
+// Common conversation errors
+var (
+	ErrConversationNotFound = errors.New("conversation not found")
+	ErrCannotMessageSelf    = errors.New("you cannot message yourself")
+	ErrMessagingBlocked     = errors.New("you cannot message this account")
+	ErrInvalidBody          = errors.New("message body must be between 1 and 1000 characters")
+	ErrInvalidCursor        = errors.New("invalid cursor")
+)
+
+// Conversation is a one-to-one conversation as seen by one of its two
+// participants, the viewer
+type Conversation struct {
+	ID            uuid.UUID `json:"id" db:"id"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+	LastMessageAt time.Time `json:"last_message_at" db:"last_message_at"`
+
+	// The other participant
+	ParticipantID             uuid.UUID `json:"participant_id" db:"participant_id"`
+	ParticipantUsername       string    `json:"participant_username" db:"username"`
+	ParticipantProfilePicture string    `json:"participant_profile_picture,omitempty" db:"profile_picture"`
+
+	// Read receipts: when the viewer and the other participant last read the
+	// conversation
+	LastReadAt            *time.Time `json:"last_read_at,omitempty" db:"last_read_at"`
+	ParticipantLastReadAt *time.Time `json:"participant_last_read_at,omitempty" db:"participant_last_read_at"`
+
+	LastMessage *Message `json:"last_message,omitempty"`
+	UnreadCount int      `json:"unread_count" db:"unread_count"` // Messages from the other participant the viewer hasn't read
+}
+
+// Message is a text message in a conversation
+type Message struct {
+	ID             uuid.UUID `json:"id" db:"id"`
+	ConversationID uuid.UUID `json:"conversation_id" db:"conversation_id"`
+	SenderID       uuid.UUID `json:"sender_id" db:"sender_id"`
+	Body           string    `json:"body" db:"content"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+}
+
+// Cursor marks the last message of a page; the next page starts strictly
+// before it
+type Cursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// Encode returns the opaque string form of the cursor
+func (c Cursor) Encode() string {
+	raw := c.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + c.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeCursor parses a cursor produced by Encode. An empty string yields nil.
+func DecodeCursor(s string) (*Cursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	createdAt, id, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, createdAt)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	parsedID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &Cursor{CreatedAt: t, ID: parsedID}, nil
+}
+
+// MessagePage is a page of messages ordered newest-first
+type MessagePage struct {
+	Messages   []*Message
+	NextCursor string
+}
+
+// newMessagePage trims a result fetched with limit+1 rows and sets the next cursor
+func newMessagePage(messages []*Message, limit int) *MessagePage {
+	page := &MessagePage{Messages: messages}
+	if len(messages) > limit {
+		page.Messages = messages[:limit]
+		last := page.Messages[limit-1]
+		page.NextCursor = Cursor{CreatedAt: last.CreatedAt, ID: last.ID}.Encode()
+	}
+	return page
+}
+
+// Repository defines the interface for conversation persistence
+type Repository interface {
+	// GetOrCreateDirect returns the conversation between two users, creating
+	// it if they have none yet, and reports whether it was created
+	GetOrCreateDirect(ctx context.Context, userID, recipientID uuid.UUID) (uuid.UUID, bool, error)
+	// Get returns a conversation as seen by a participant; viewers who aren't
+	// participants get ErrConversationNotFound
+	Get(ctx context.Context, id, viewerID uuid.UUID) (*Conversation, error)
+	List(ctx context.Context, viewerID uuid.UUID, limit, offset int) ([]*Conversation, error)
+	CreateMessage(ctx context.Context, message *Message) error
+	ListMessages(ctx context.Context, conversationID uuid.UUID, before *Cursor, limit int) ([]*Message, error)
+	MarkRead(ctx context.Context, conversationID, userID uuid.UUID) (time.Time, error)
+}
+
+// Service defines the interface for direct messaging business logic
+type Service interface {
+	StartConversation(ctx context.Context, userID, recipientID uuid.UUID) (*Conversation, bool, error)
+	ListConversations(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*Conversation, error)
+	SendMessage(ctx context.Context, conversationID, senderID uuid.UUID, body string) (*Message, error)
+	ListMessages(ctx context.Context, conversationID, userID uuid.UUID, cursor string, limit int) (*MessagePage, error)
+	MarkRead(ctx context.Context, conversationID, userID uuid.UUID) (time.Time, error)
+}
+
+// validateBody checks the message body length in characters
+func validateBody(body string) error {
+	n := len([]rune(strings.TrimSpace(body)))
+	if n == 0 || n > MaxBodyLength {
+		return ErrInvalidBody
+	}
+	return nil
+}
@@ -0,0 +1,296 @@
+package conversation
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"fowergram-backend/internal/infra/database"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// postgresRepository implements Repository using PostgreSQL
+type postgresRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewRepository creates a new PostgreSQL conversation repository
+func NewRepository(db *pgxpool.Pool) Repository {
+	return &postgresRepository{db: db}
+}
+
+// conversationQuery selects the columns read by scanConversation for the
+// direct conversations of the viewer bound as $1. Conversations with a
+// deactivated participant are left out.
+const conversationQuery = `
+	SELECT c.id, c.created_at, c.last_message_at,
+		   u.id, u.username, COALESCE(u.profile_picture, ''),
+		   me.last_read_at, other.last_read_at,
+		   lm.id, lm.sender_id, lm.content, lm.created_at,
+		   (SELECT COUNT(*) FROM messages m
+			WHERE m.conversation_id = c.id AND m.deleted_at IS NULL AND m.sender_id <> me.user_id
+				AND (me.last_read_at IS NULL OR m.created_at > me.last_read_at))
+	FROM conversation_participants me
+	JOIN conversations c ON c.id = me.conversation_id AND c.direct_key IS NOT NULL
+	JOIN conversation_participants other ON other.conversation_id = c.id AND other.user_id <> me.user_id
+	JOIN users u ON u.id = other.user_id AND u.is_active = true
+	LEFT JOIN LATERAL (
+		SELECT id, sender_id, content, created_at FROM messages
+		WHERE conversation_id = c.id AND deleted_at IS NULL
+		ORDER BY created_at DESC, id DESC
+		LIMIT 1
+	) lm ON true
+	WHERE me.user_id = $1`
+
+// directKey identifies the conversation between two users regardless of
+// which of them started it
+func directKey(a, b uuid.UUID) string {
+	x, y := a.String(), b.String()
+	if x > y {
+		x, y = y, x
+	}
+	return x + ":" + y
+}
+
+// GetOrCreateDirect returns the conversation between two users, creating it
+// with both of them as participants if they have none yet
+func (r *postgresRepository) GetOrCreateDirect(ctx context.Context, userID, recipientID uuid.UUID) (uuid.UUID, bool, error) {
+	var (
+		id      uuid.UUID
+		created bool
+	)
+	err := database.WithTx(ctx, r.db, func(tx database.DB) error {
+		key := directKey(userID, recipientID)
+		id = uuid.New()
+		now := time.Now()
+
+		insertQuery := `
+			INSERT INTO conversations (id, is_group, created_by, direct_key, last_message_at, created_at)
+			VALUES ($1, false, $2, $3, $4, $4)
+			ON CONFLICT (direct_key) WHERE direct_key IS NOT NULL DO NOTHING
+		`
+		tag, err := tx.Exec(ctx, insertQuery, id, userID, key, now)
+		if err != nil {
+			return fmt.Errorf("failed to create conversation: %w", err)
+		}
+
+		if tag.RowsAffected() == 0 {
+			selectQuery := `SELECT id FROM conversations WHERE direct_key = $1`
+			if err := tx.QueryRow(ctx, selectQuery, key).Scan(&id); err != nil {
+				return fmt.Errorf("failed to get conversation: %w", err)
+			}
+			return nil
+		}
+
+		participantsQuery := `
+			INSERT INTO conversation_participants (conversation_id, user_id, joined_at)
+			VALUES ($1, $2, $4), ($1, $3, $4)
+		`
+		if _, err := tx.Exec(ctx, participantsQuery, id, userID, recipientID, now); err != nil {
+			return fmt.Errorf("failed to add conversation participants: %w", err)
+		}
+
+		created = true
+		return nil
+	})
+	if err != nil {
+		return uuid.Nil, false, err
+	}
+
+	return id, created, nil
+}
+
+// Get retrieves a conversation the viewer takes part in
+func (r *postgresRepository) Get(ctx context.Context, id, viewerID uuid.UUID) (*Conversation, error) {
+	query := conversationQuery + ` AND c.id = $2`
+
+	conversation, err := scanConversation(r.db.QueryRow(ctx, query, viewerID, id))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrConversationNotFound
+		}
+		return nil, fmt.Errorf("failed to get conversation: %w", err)
+	}
+
+	return conversation, nil
+}
+
+// List retrieves the viewer's conversations, most recently active first.
+// Conversations someone else started stay hidden until a message is sent.
+func (r *postgresRepository) List(ctx context.Context, viewerID uuid.UUID, limit, offset int) ([]*Conversation, error) {
+	query := conversationQuery + `
+			AND (lm.id IS NOT NULL OR c.created_by = $1)
+		ORDER BY c.last_message_at DESC, c.id DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	rows, err := r.db.Query(ctx, query, viewerID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list conversations: %w", err)
+	}
+	defer rows.Close()
+
+	var conversations []*Conversation
+	for rows.Next() {
+		conversation, err := scanConversation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan conversation: %w", err)
+		}
+		conversations = append(conversations, conversation)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate conversations: %w", err)
+	}
+
+	return conversations, nil
+}
+
+// CreateMessage inserts a message, bumps the conversation's activity and
+// marks the conversation read for the sender
+func (r *postgresRepository) CreateMessage(ctx context.Context, message *Message) error {
+	return database.WithTx(ctx, r.db, func(tx database.DB) error {
+		insertQuery := `
+			INSERT INTO messages (id, conversation_id, sender_id, message_type, content, created_at, updated_at)
+			VALUES ($1, $2, $3, 'text', $4, $5, $5)
+		`
+		_, err := tx.Exec(ctx, insertQuery,
+			message.ID, message.ConversationID, message.SenderID, message.Body, message.CreatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to create message: %w", err)
+		}
+
+		updateQuery := `
+			UPDATE conversations SET last_message_at = GREATEST(last_message_at, $2)
+			WHERE id = $1
+		`
+		if _, err := tx.Exec(ctx, updateQuery, message.ConversationID, message.CreatedAt); err != nil {
+			return fmt.Errorf("failed to update conversation: %w", err)
+		}
+
+		readQuery := `
+			UPDATE conversation_participants SET last_read_at = GREATEST(last_read_at, $3)
+			WHERE conversation_id = $1 AND user_id = $2
+		`
+		if _, err := tx.Exec(ctx, readQuery, message.ConversationID, message.SenderID, message.CreatedAt); err != nil {
+			return fmt.Errorf("failed to update read receipt: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// ListMessages retrieves a conversation's messages, newest first, starting
+// before the cursor
+func (r *postgresRepository) ListMessages(ctx context.Context, conversationID uuid.UUID, before *Cursor, limit int) ([]*Message, error) {
+	query := `
+		SELECT id, conversation_id, sender_id, COALESCE(content, ''), created_at
+		FROM messages
+		WHERE conversation_id = $1 AND deleted_at IS NULL
+			AND ($2::timestamptz IS NULL OR (created_at, id) < ($2, $3))
+		ORDER BY created_at DESC, id DESC
+		LIMIT $4
+	`
+
+	var (
+		beforeTime interface{}
+		beforeID   interface{}
+	)
+	if before != nil {
+		beforeTime = before.CreatedAt
+		beforeID = before.ID
+	}
+
+	rows, err := r.db.Query(ctx, query, conversationID, beforeTime, beforeID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list messages: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []*Message
+	for rows.Next() {
+		var m Message
+		if err := rows.Scan(&m.ID, &m.ConversationID, &m.SenderID, &m.Body, &m.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan message: %w", err)
+		}
+		messages = append(messages, &m)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate messages: %w", err)
+	}
+
+	return messages, nil
+}
+
+// MarkRead records that the user has read the conversation up to its latest
+// message and returns the new read timestamp
+func (r *postgresRepository) MarkRead(ctx context.Context, conversationID, userID uuid.UUID) (time.Time, error) {
+	// The latest message bounds the timestamp from below in case its
+	// created_at, set by the application, is ahead of the database clock
+	query := `
+		UPDATE conversation_participants
+		SET last_read_at = GREATEST(NOW(), (
+			SELECT MAX(created_at) FROM messages WHERE conversation_id = $1
+		))
+		WHERE conversation_id = $1 AND user_id = $2
+		RETURNING last_read_at
+	`
+
+	var readAt time.Time
+	if err := r.db.QueryRow(ctx, query, conversationID, userID).Scan(&readAt); err != nil {
+		if err == pgx.ErrNoRows {
+			return time.Time{}, ErrConversationNotFound
+		}
+		return time.Time{}, fmt.Errorf("failed to mark conversation read: %w", err)
+	}
+
+	return readAt, nil
+}
+
+// scanConversation scans a row selected with conversationQuery
+func scanConversation(row pgx.Row) (*Conversation, error) {
+	var (
+		c             Conversation
+		lastID        *uuid.UUID
+		lastSenderID  *uuid.UUID
+		lastBody      *string
+		lastCreatedAt *time.Time
+	)
+	err := row.Scan(
+		&c.ID,
+		&c.CreatedAt,
+		&c.LastMessageAt,
+		&c.ParticipantID,
+		&c.ParticipantUsername,
+		&c.ParticipantProfilePicture,
+		&c.LastReadAt,
+		&c.ParticipantLastReadAt,
+		&lastID,
+		&lastSenderID,
+		&lastBody,
+		&lastCreatedAt,
+		&c.UnreadCount,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if lastID != nil {
+		c.LastMessage = &Message{
+			ID:             *lastID,
+			ConversationID: c.ID,
+			SenderID:       *lastSenderID,
+			CreatedAt:      *lastCreatedAt,
+		}
+		if lastBody != nil {
+			c.LastMessage.Body = *lastBody
+		}
+	}
+
+	return &c, nil
+}
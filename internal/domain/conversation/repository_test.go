@@ -0,0 +1,191 @@
+package conversation
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"fowergram-backend/internal/infra/database/dbtest"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// addUser inserts an active user and returns its ID
+func addUser(t *testing.T, db *pgxpool.Pool, username string) uuid.UUID {
+	t.Helper()
+	id := uuid.New()
+	_, err := db.Exec(context.Background(), `
+		INSERT INTO users (id, email, username, full_name, bio, profile_picture)
+		VALUES ($1, $2, $3, '', '', '')
+	`, id, username+"@example.com", username)
+	if err != nil {
+		t.Fatalf("adding %s: %v", username, err)
+	}
+	return id
+}
+
+func TestRepositoryConversations(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.MigratedPool(t)
+	repo := NewRepository(db)
+	alice, bob, carol, dave := addUser(t, db, "alice"), addUser(t, db, "bob"), addUser(t, db, "carol"), addUser(t, db, "dave")
+
+	start := func(userID, recipientID uuid.UUID) uuid.UUID {
+		t.Helper()
+		id, _, err := repo.GetOrCreateDirect(ctx, userID, recipientID)
+		if err != nil {
+			t.Fatalf("GetOrCreateDirect: %v", err)
+		}
+		return id
+	}
+	// Messages are sent a second apart, in the past so reading them now
+	// reads them all
+	sentAt := time.Now().Add(-time.Hour).Truncate(time.Microsecond)
+	send := func(conversationID, senderID uuid.UUID, body string) *Message {
+		t.Helper()
+		sentAt = sentAt.Add(time.Second)
+		m := &Message{ID: uuid.New(), ConversationID: conversationID, SenderID: senderID, Body: body, CreatedAt: sentAt}
+		if err := repo.CreateMessage(ctx, m); err != nil {
+			t.Fatalf("CreateMessage: %v", err)
+		}
+		return m
+	}
+	list := func(viewerID uuid.UUID) map[uuid.UUID]*Conversation {
+		t.Helper()
+		conversations, err := repo.List(ctx, viewerID, 10, 0)
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		byParticipant := make(map[uuid.UUID]*Conversation, len(conversations))
+		for _, c := range conversations {
+			byParticipant[c.ParticipantID] = c
+		}
+		return byParticipant
+	}
+
+	withBob := start(alice, bob)
+	if again, created, err := repo.GetOrCreateDirect(ctx, bob, alice); err != nil || created || again != withBob {
+		t.Fatalf("GetOrCreateDirect from bob = %s, %v, %v; want alice's conversation", again, created, err)
+	}
+	withCarol := start(carol, alice)
+	withDave := start(alice, dave)
+
+	// A conversation someone else started is hidden until a message is sent
+	if _, ok := list(alice)[carol]; ok {
+		t.Errorf("alice lists carol's empty conversation")
+	}
+	if _, ok := list(carol)[alice]; !ok {
+		t.Errorf("carol doesn't list the conversation she started")
+	}
+
+	send(withBob, alice, "hi bob")
+	send(withBob, bob, "hi alice")
+	send(withBob, bob, "how are you?")
+	send(withCarol, carol, "hey")
+	send(withDave, dave, "yo")
+	if _, err := db.Exec(ctx, "UPDATE users SET is_active = false WHERE id = $1", dave); err != nil {
+		t.Fatalf("deactivating dave: %v", err)
+	}
+
+	// Most recently active first, without the deactivated participant, and
+	// counting only the messages from the other participant
+	conversations, err := repo.List(ctx, alice, 10, 0)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	var order []uuid.UUID
+	for _, c := range conversations {
+		order = append(order, c.ParticipantID)
+	}
+	if want := []uuid.UUID{carol, bob}; !slices.Equal(order, want) {
+		t.Errorf("alice lists conversations with %v, want %v", order, want)
+	}
+	if c := list(alice)[bob]; c == nil || c.UnreadCount != 2 || c.LastMessage == nil || c.LastMessage.Body != "how are you?" {
+		t.Errorf("alice's conversation with bob = %+v, want 2 unread ending with bob's question", c)
+	}
+	if c := list(bob)[alice]; c == nil || c.UnreadCount != 0 {
+		t.Errorf("bob's conversation with alice = %+v, want nothing unread", c)
+	}
+
+	// Reading clears the unread count, and shows as the other's read receipt
+	readAt, err := repo.MarkRead(ctx, withBob, alice)
+	if err != nil {
+		t.Fatalf("MarkRead: %v", err)
+	}
+	if c := list(alice)[bob]; c == nil || c.UnreadCount != 0 {
+		t.Errorf("after reading, alice's conversation with bob = %+v, want nothing unread", c)
+	}
+	if c := list(bob)[alice]; c == nil || c.ParticipantLastReadAt == nil || !c.ParticipantLastReadAt.Equal(readAt) {
+		t.Errorf("bob's conversation with alice = %+v, want alice's read receipt at %v", c, readAt)
+	}
+	sentAt = readAt
+	send(withBob, bob, "still there?")
+	if c := list(alice)[bob]; c == nil || c.UnreadCount != 1 {
+		t.Errorf("after bob wrote again, alice's conversation with bob = %+v, want 1 unread", c)
+	}
+
+	// Only participants see or read a conversation
+	if _, err := repo.Get(ctx, withBob, carol); !errors.Is(err, ErrConversationNotFound) {
+		t.Errorf("Get for a non-participant error = %v, want %v", err, ErrConversationNotFound)
+	}
+	if _, err := repo.MarkRead(ctx, withBob, carol); !errors.Is(err, ErrConversationNotFound) {
+		t.Errorf("MarkRead for a non-participant error = %v, want %v", err, ErrConversationNotFound)
+	}
+}
+
+func TestRepositoryListMessages(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.MigratedPool(t)
+	repo := NewRepository(db)
+	alice, bob := addUser(t, db, "alice"), addUser(t, db, "bob")
+	id, _, err := repo.GetOrCreateDirect(ctx, alice, bob)
+	if err != nil {
+		t.Fatalf("GetOrCreateDirect: %v", err)
+	}
+
+	// Two messages share a timestamp, so the cursor has to break the tie by ID
+	at := time.Now().Add(-time.Hour).Truncate(time.Microsecond)
+	times := []time.Time{at, at.Add(time.Second), at.Add(time.Second), at.Add(2 * time.Second), at.Add(3 * time.Second)}
+	var want []uuid.UUID
+	for _, createdAt := range times {
+		m := &Message{ID: uuid.New(), ConversationID: id, SenderID: alice, Body: "hi", CreatedAt: createdAt}
+		if err := repo.CreateMessage(ctx, m); err != nil {
+			t.Fatalf("CreateMessage: %v", err)
+		}
+		want = append(want, m.ID)
+	}
+	// Oldest first, and by ID within a timestamp, before reversing
+	if want[1].String() > want[2].String() {
+		want[1], want[2] = want[2], want[1]
+	}
+	slices.Reverse(want)
+
+	var got []uuid.UUID
+	var before *Cursor
+	for pages := 0; ; pages++ {
+		if pages > len(want) {
+			t.Fatalf("paged past every message")
+		}
+		// Fetched with limit+1, as the service does
+		messages, err := repo.ListMessages(ctx, id, before, 3)
+		if err != nil {
+			t.Fatalf("ListMessages: %v", err)
+		}
+		page := newMessagePage(messages, 2)
+		for _, m := range page.Messages {
+			got = append(got, m.ID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		if before, err = DecodeCursor(page.NextCursor); err != nil {
+			t.Fatalf("DecodeCursor: %v", err)
+		}
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("listed %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,153 @@
+package conversation
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"fowergram-backend/internal/domain/user"
+	"fowergram-backend/internal/events"
+	"fowergram-backend/pkg/auth"
+	"fowergram-backend/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// service implements Service
+type service struct {
+	repo      Repository
+	userRepo  user.Repository
+	publisher events.Publisher
+	logger    logger.Logger
+}
+
+// NewService creates a new conversation service
+func NewService(repo Repository, userRepo user.Repository, publisher events.Publisher, logger logger.Logger) Service {
+	return &service{
+		repo:      repo,
+		userRepo:  userRepo,
+		publisher: publisher,
+		logger:    logger,
+	}
+}
+
+// StartConversation returns the user's conversation with recipientID,
+// creating it if needed, and reports whether it was created. A block in
+// either direction returns ErrMessagingBlocked.
+func (s *service) StartConversation(ctx context.Context, userID, recipientID uuid.UUID) (*Conversation, bool, error) {
+	if userID == recipientID {
+		return nil, false, ErrCannotMessageSelf
+	}
+
+	recipient, err := s.userRepo.GetUserByID(ctx, recipientID)
+	if err != nil {
+		return nil, false, err
+	}
+	if !recipient.IsActive {
+		return nil, false, auth.ErrUserNotFound
+	}
+
+	if err := s.checkBlocked(ctx, userID, recipientID); err != nil {
+		return nil, false, err
+	}
+
+	id, created, err := s.repo.GetOrCreateDirect(ctx, userID, recipientID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	conversation, err := s.repo.Get(ctx, id, userID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return conversation, created, nil
+}
+
+// ListConversations lists the user's conversations, most recently active first
+func (s *service) ListConversations(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*Conversation, error) {
+	return s.repo.List(ctx, userID, limit, offset)
+}
+
+// SendMessage posts a text message to a conversation the sender takes part
+// in and announces it with a dm.message_created event. Once either
+// participant has blocked the other, sending returns ErrMessagingBlocked.
+func (s *service) SendMessage(ctx context.Context, conversationID, senderID uuid.UUID, body string) (*Message, error) {
+	if err := validateBody(body); err != nil {
+		return nil, err
+	}
+
+	conversation, err := s.repo.Get(ctx, conversationID, senderID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkBlocked(ctx, senderID, conversation.ParticipantID); err != nil {
+		return nil, err
+	}
+
+	message := &Message{
+		ID:             uuid.New(),
+		ConversationID: conversation.ID,
+		SenderID:       senderID,
+		Body:           strings.TrimSpace(body),
+		CreatedAt:      time.Now(),
+	}
+
+	if err := s.repo.CreateMessage(ctx, message); err != nil {
+		return nil, err
+	}
+
+	// The message is stored either way; clients that miss the event see it
+	// when they next list the conversation
+	err = s.publisher.Publish(ctx, senderID, events.DirectMessageCreated{
+		MessageID:      message.ID,
+		ConversationID: message.ConversationID,
+		SenderID:       senderID,
+		RecipientID:    conversation.ParticipantID,
+		Body:           message.Body,
+		CreatedAt:      message.CreatedAt,
+	})
+	if err != nil {
+		s.logger.Error("Failed to publish direct message event", "message_id", message.ID, "error", err)
+	}
+
+	return message, nil
+}
+
+// ListMessages lists messages in a conversation the user takes part in,
+// newest first
+func (s *service) ListMessages(ctx context.Context, conversationID, userID uuid.UUID, cursor string, limit int) (*MessagePage, error) {
+	before, err := DecodeCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.repo.Get(ctx, conversationID, userID); err != nil {
+		return nil, err
+	}
+
+	messages, err := s.repo.ListMessages(ctx, conversationID, before, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	return newMessagePage(messages, limit), nil
+}
+
+// MarkRead records that the user has read every message in the conversation
+// so far and returns when
+func (s *service) MarkRead(ctx context.Context, conversationID, userID uuid.UUID) (time.Time, error) {
+	return s.repo.MarkRead(ctx, conversationID, userID)
+}
+
+func (s *service) checkBlocked(ctx context.Context, userID, otherID uuid.UUID) error {
+	blocked, err := s.userRepo.IsBlockedEither(ctx, userID, otherID)
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return ErrMessagingBlocked
+	}
+	return nil
+}
@@ -0,0 +1,344 @@
+package conversation
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"fowergram-backend/internal/domain/user"
+	"fowergram-backend/internal/events"
+	"fowergram-backend/internal/infra/messaging"
+	"fowergram-backend/pkg/auth"
+	"fowergram-backend/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// fakeUserRepository holds the users and blocks the service checks.
+// Methods the tests don't call panic through the nil embedded interface.
+type fakeUserRepository struct {
+	user.Repository
+	users  map[uuid.UUID]*auth.User
+	blocks map[[2]uuid.UUID]bool // Blocker, blocked
+}
+
+func (r *fakeUserRepository) GetUserByID(ctx context.Context, id uuid.UUID) (*auth.User, error) {
+	u, ok := r.users[id]
+	if !ok {
+		return nil, auth.ErrUserNotFound
+	}
+	return u, nil
+}
+
+func (r *fakeUserRepository) IsBlockedEither(ctx context.Context, userID, otherID uuid.UUID) (bool, error) {
+	return r.blocks[[2]uuid.UUID{userID, otherID}] || r.blocks[[2]uuid.UUID{otherID, userID}], nil
+}
+
+// fakeConversation is a stored conversation between two participants
+type fakeConversation struct {
+	id           uuid.UUID
+	participants [2]uuid.UUID
+	messages     []*Message // In the order sent
+}
+
+// fakeRepository keeps conversations in memory, following the rules of the
+// SQL: only participants see a conversation. Methods the tests don't call
+// panic through the nil embedded interface.
+type fakeRepository struct {
+	Repository
+	conversations map[uuid.UUID]*fakeConversation
+}
+
+func newFakeRepository() *fakeRepository {
+	return &fakeRepository{conversations: make(map[uuid.UUID]*fakeConversation)}
+}
+
+func (r *fakeRepository) GetOrCreateDirect(ctx context.Context, userID, recipientID uuid.UUID) (uuid.UUID, bool, error) {
+	for id, c := range r.conversations {
+		if slices.Contains(c.participants[:], userID) && slices.Contains(c.participants[:], recipientID) {
+			return id, false, nil
+		}
+	}
+	c := &fakeConversation{id: uuid.New(), participants: [2]uuid.UUID{userID, recipientID}}
+	r.conversations[c.id] = c
+	return c.id, true, nil
+}
+
+func (r *fakeRepository) Get(ctx context.Context, id, viewerID uuid.UUID) (*Conversation, error) {
+	c, ok := r.conversations[id]
+	if !ok || !slices.Contains(c.participants[:], viewerID) {
+		return nil, ErrConversationNotFound
+	}
+	other := c.participants[0]
+	if other == viewerID {
+		other = c.participants[1]
+	}
+	return &Conversation{ID: c.id, ParticipantID: other}, nil
+}
+
+func (r *fakeRepository) CreateMessage(ctx context.Context, message *Message) error {
+	c := r.conversations[message.ConversationID]
+	c.messages = append(c.messages, message)
+	return nil
+}
+
+func (r *fakeRepository) ListMessages(ctx context.Context, conversationID uuid.UUID, before *Cursor, limit int) ([]*Message, error) {
+	var messages []*Message
+	c := r.conversations[conversationID]
+	for i := len(c.messages) - 1; i >= 0 && len(messages) < limit; i-- {
+		m := c.messages[i]
+		if before != nil && !m.CreatedAt.Before(before.CreatedAt) {
+			continue
+		}
+		messages = append(messages, m)
+	}
+	return messages, nil
+}
+
+// conversationFixture is a service with alice, bob and carol, and nobody
+// blocked
+type conversationFixture struct {
+	service  Service
+	repo     *fakeRepository
+	users    *fakeUserRepository
+	recorder *messaging.RecordingClient
+	alice    uuid.UUID
+	bob      uuid.UUID
+	carol    uuid.UUID
+	inactive uuid.UUID // Deactivated
+}
+
+func newConversationFixture(t *testing.T) *conversationFixture {
+	t.Helper()
+	f := &conversationFixture{
+		repo:     newFakeRepository(),
+		users:    &fakeUserRepository{users: make(map[uuid.UUID]*auth.User), blocks: make(map[[2]uuid.UUID]bool)},
+		recorder: messaging.NewRecordingClient(),
+		alice:    uuid.New(),
+		bob:      uuid.New(),
+		carol:    uuid.New(),
+		inactive: uuid.New(),
+	}
+	for _, id := range []uuid.UUID{f.alice, f.bob, f.carol} {
+		f.users.users[id] = &auth.User{ID: id, IsActive: true}
+	}
+	f.users.users[f.inactive] = &auth.User{ID: f.inactive}
+
+	log := logger.NewZapLogger()
+	f.service = NewService(f.repo, f.users, events.NewNATSPublisher(f.recorder, log), log)
+	return f
+}
+
+// published decodes the dm.message_created events published so far
+func (f *conversationFixture) published(t *testing.T) []events.DirectMessageCreated {
+	t.Helper()
+	var payloads []events.DirectMessageCreated
+	for _, msg := range f.recorder.PublishedTo(string(events.TypeDirectMessageCreated)) {
+		envelope, err := events.Decode(msg.Data)
+		if err != nil {
+			t.Fatalf("Decode: %v", err)
+		}
+		var payload events.DirectMessageCreated
+		if err := envelope.DecodePayload(&payload); err != nil {
+			t.Fatalf("DecodePayload: %v", err)
+		}
+		payloads = append(payloads, payload)
+	}
+	return payloads
+}
+
+// start starts a conversation between userID and recipientID
+func (f *conversationFixture) start(t *testing.T, userID, recipientID uuid.UUID) uuid.UUID {
+	t.Helper()
+	c, _, err := f.service.StartConversation(context.Background(), userID, recipientID)
+	if err != nil {
+		t.Fatalf("StartConversation: %v", err)
+	}
+	return c.ID
+}
+
+func TestStartConversation(t *testing.T) {
+	ctx := context.Background()
+	f := newConversationFixture(t)
+	f.users.blocks[[2]uuid.UUID{f.carol, f.alice}] = true
+
+	tests := []struct {
+		name        string
+		user        uuid.UUID
+		recipient   uuid.UUID
+		wantErr     error
+		wantCreated bool
+	}{
+		{name: "new conversation", user: f.alice, recipient: f.bob, wantCreated: true},
+		{name: "started again", user: f.alice, recipient: f.bob},
+		{name: "started by the recipient", user: f.bob, recipient: f.alice},
+		{name: "yourself", user: f.alice, recipient: f.alice, wantErr: ErrCannotMessageSelf},
+		{name: "unknown recipient", user: f.alice, recipient: uuid.New(), wantErr: auth.ErrUserNotFound},
+		{name: "deactivated recipient", user: f.alice, recipient: f.inactive, wantErr: auth.ErrUserNotFound},
+		{name: "blocked by the recipient", user: f.alice, recipient: f.carol, wantErr: ErrMessagingBlocked},
+		{name: "recipient blocked", user: f.carol, recipient: f.alice, wantErr: ErrMessagingBlocked},
+	}
+
+	var first uuid.UUID
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, created, err := f.service.StartConversation(ctx, tt.user, tt.recipient)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("StartConversation error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if created != tt.wantCreated {
+				t.Errorf("created = %v, want %v", created, tt.wantCreated)
+			}
+			if c.ParticipantID != tt.recipient {
+				t.Errorf("participant = %s, want the recipient %s", c.ParticipantID, tt.recipient)
+			}
+			// The pair shares one conversation whoever starts it
+			if first == uuid.Nil {
+				first = c.ID
+			} else if c.ID != first {
+				t.Errorf("conversation = %s, want %s", c.ID, first)
+			}
+		})
+	}
+
+	if len(f.repo.conversations) != 1 {
+		t.Errorf("stored %d conversations, want 1", len(f.repo.conversations))
+	}
+}
+
+func TestSendMessage(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		sender  func(f *conversationFixture) uuid.UUID
+		block   func(f *conversationFixture) [2]uuid.UUID // Blocker and blocked, set after the conversation starts
+		body    string
+		wantErr error
+	}{
+		{name: "sent", body: "  hello bob  "},
+		{name: "sent by the recipient", sender: func(f *conversationFixture) uuid.UUID { return f.bob }, body: "hi alice"},
+		{name: "empty body", body: "   ", wantErr: ErrInvalidBody},
+		{name: "too long", body: strings.Repeat("é", MaxBodyLength+1), wantErr: ErrInvalidBody},
+		{name: "longest body", body: strings.Repeat("é", MaxBodyLength)},
+		{name: "not a participant", sender: func(f *conversationFixture) uuid.UUID { return f.carol }, body: "hi", wantErr: ErrConversationNotFound},
+		{name: "recipient blocked the sender", block: func(f *conversationFixture) [2]uuid.UUID { return [2]uuid.UUID{f.bob, f.alice} }, body: "hi", wantErr: ErrMessagingBlocked},
+		{name: "sender blocked the recipient", block: func(f *conversationFixture) [2]uuid.UUID { return [2]uuid.UUID{f.alice, f.bob} }, body: "hi", wantErr: ErrMessagingBlocked},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newConversationFixture(t)
+			id := f.start(t, f.alice, f.bob)
+			if tt.block != nil {
+				f.users.blocks[tt.block(f)] = true
+			}
+			sender, recipient := f.alice, f.bob
+			if tt.sender != nil {
+				sender = tt.sender(f)
+				if sender == f.bob {
+					recipient = f.alice
+				}
+			}
+
+			message, err := f.service.SendMessage(ctx, id, sender, tt.body)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SendMessage error = %v, want %v", err, tt.wantErr)
+			}
+			stored := f.repo.conversations[id].messages
+			published := f.published(t)
+			if err != nil {
+				if len(stored) != 0 || len(published) != 0 {
+					t.Errorf("stored %d and published %d messages, want none", len(stored), len(published))
+				}
+				return
+			}
+
+			if message.Body != strings.TrimSpace(tt.body) || message.SenderID != sender || message.ConversationID != id {
+				t.Errorf("message = %+v, want %q from %s in %s", message, strings.TrimSpace(tt.body), sender, id)
+			}
+			if len(stored) != 1 || stored[0] != message {
+				t.Errorf("stored %v, want the message", stored)
+			}
+			want := events.DirectMessageCreated{
+				MessageID:      message.ID,
+				ConversationID: id,
+				SenderID:       sender,
+				RecipientID:    recipient,
+				Body:           message.Body,
+			}
+			if len(published) != 1 {
+				t.Fatalf("published %d %s events, want 1", len(published), events.TypeDirectMessageCreated)
+			}
+			got := published[0]
+			if !got.CreatedAt.Equal(message.CreatedAt) {
+				t.Errorf("event created at %v, want %v", got.CreatedAt, message.CreatedAt)
+			}
+			got.CreatedAt = time.Time{}
+			if got != want {
+				t.Errorf("event = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestListMessages(t *testing.T) {
+	ctx := context.Background()
+	f := newConversationFixture(t)
+	id := f.start(t, f.alice, f.bob)
+
+	// Messages a millisecond apart, so the cursor orders them by time
+	start := time.Now().Add(-time.Minute)
+	var sent []string
+	for i, body := range []string{"one", "two", "three", "four", "five"} {
+		f.repo.conversations[id].messages = append(f.repo.conversations[id].messages, &Message{
+			ID:             uuid.New(),
+			ConversationID: id,
+			SenderID:       f.alice,
+			Body:           body,
+			CreatedAt:      start.Add(time.Duration(i) * time.Millisecond),
+		})
+		sent = append(sent, body)
+	}
+
+	// Pages go from newest to oldest, the last one without a cursor
+	var got []string
+	cursor, pages := "", 0
+	for {
+		page, err := f.service.ListMessages(ctx, id, f.bob, cursor, 2)
+		if err != nil {
+			t.Fatalf("ListMessages: %v", err)
+		}
+		pages++
+		for _, m := range page.Messages {
+			got = append(got, m.Body)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		if pages > len(sent) {
+			t.Fatalf("paged past every message, with cursor %q", page.NextCursor)
+		}
+		cursor = page.NextCursor
+	}
+	slices.Reverse(sent)
+	if !slices.Equal(got, sent) || pages != 3 {
+		t.Errorf("listed %v in %d pages, want %v in 3", got, pages, sent)
+	}
+
+	if _, err := f.service.ListMessages(ctx, id, f.carol, "", 2); !errors.Is(err, ErrConversationNotFound) {
+		t.Errorf("non-participant listing error = %v, want %v", err, ErrConversationNotFound)
+	}
+	if _, err := f.service.ListMessages(ctx, uuid.New(), f.bob, "", 2); !errors.Is(err, ErrConversationNotFound) {
+		t.Errorf("missing conversation listing error = %v, want %v", err, ErrConversationNotFound)
+	}
+	if _, err := f.service.ListMessages(ctx, id, f.bob, "not a cursor", 2); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("invalid cursor error = %v, want %v", err, ErrInvalidCursor)
+	}
+}
@@ -0,0 +1,64 @@
+package device
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Platforms a device can be registered for
+const (
+	PlatformIOS     = "ios"
+	PlatformAndroid = "android"
+	PlatformWeb     = "web"
+)
+
+// Common device errors
+var (
+	ErrDeviceNotFound  = errors.New("device not found")
+	ErrInvalidPlatform = errors.New("platform must be one of ios, android or web")
+)
+
+// Device is a push notification token registered by a user's app install
+type Device struct {
+	ID         uuid.UUID `json:"id" db:"id"`
+	UserID     uuid.UUID `json:"user_id" db:"user_id"`
+	Token      string    `json:"token" db:"token"`
+	Platform   string    `json:"platform" db:"platform"`
+	AppVersion string    `json:"app_version,omitempty" db:"app_version"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// RegisterInput represents input for registering a device
+type RegisterInput struct {
+	Token      string
+	Platform   string
+	AppVersion string
+}
+
+// Repository defines the interface for device persistence
+type Repository interface {
+	// Register stores a device token for its user. A token registered before
+	// moves to the new user, since it identifies a single app install.
+	Register(ctx context.Context, device *Device) error
+	Unregister(ctx context.Context, userID uuid.UUID, token string) (bool, error)
+	ListByUser(ctx context.Context, userID uuid.UUID) ([]*Device, error)
+	DeleteTokens(ctx context.Context, tokens []string) error
+}
+
+// Service defines the interface for device registration
+type Service interface {
+	Register(ctx context.Context, userID uuid.UUID, input RegisterInput) (*Device, error)
+	Unregister(ctx context.Context, userID uuid.UUID, token string) error
+}
+
+func validPlatform(platform string) bool {
+	switch platform {
+	case PlatformIOS, PlatformAndroid, PlatformWeb:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,30 @@
+package device
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrTokenExpired is returned by a Sender when the provider reports a token
+// as no longer valid; such tokens are pruned
+var ErrTokenExpired = errors.New("device token expired")
+
+// Message is a rendered push notification
+type Message struct {
+	Title string
+	Body  string
+	Data  map[string]string
+}
+
+// Sender delivers push notifications to a single device
+type Sender interface {
+	Send(ctx context.Context, token, platform string, msg Message) error
+}
+
+// NoopSender drops every push. It is used when no push provider is
+// configured.
+type NoopSender struct{}
+
+func (NoopSender) Send(context.Context, string, string, Message) error {
+	return nil
+}
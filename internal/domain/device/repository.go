@@ -0,0 +1,103 @@
+package device
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// postgresRepository implements Repository using PostgreSQL
+type postgresRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewRepository creates a new PostgreSQL device repository
+func NewRepository(db *pgxpool.Pool) Repository {
+	return &postgresRepository{db: db}
+}
+
+// Register upserts a device by token, taking it over from whichever user
+// registered it before
+func (r *postgresRepository) Register(ctx context.Context, device *Device) error {
+	query := `
+		INSERT INTO devices (id, user_id, token, platform, app_version, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $6)
+		ON CONFLICT (token) DO UPDATE SET
+			user_id = EXCLUDED.user_id,
+			platform = EXCLUDED.platform,
+			app_version = EXCLUDED.app_version,
+			updated_at = EXCLUDED.updated_at
+		RETURNING id, created_at
+	`
+
+	err := r.db.QueryRow(ctx, query,
+		device.ID, device.UserID, device.Token, device.Platform, device.AppVersion, device.UpdatedAt,
+	).Scan(&device.ID, &device.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to register device: %w", err)
+	}
+
+	return nil
+}
+
+// Unregister removes one of the user's device tokens, reporting whether it existed
+func (r *postgresRepository) Unregister(ctx context.Context, userID uuid.UUID, token string) (bool, error) {
+	query := `DELETE FROM devices WHERE user_id = $1 AND token = $2`
+
+	tag, err := r.db.Exec(ctx, query, userID, token)
+	if err != nil {
+		return false, fmt.Errorf("failed to unregister device: %w", err)
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
+// ListByUser retrieves the user's registered devices, most recently registered first
+func (r *postgresRepository) ListByUser(ctx context.Context, userID uuid.UUID) ([]*Device, error) {
+	query := `
+		SELECT id, user_id, token, platform, COALESCE(app_version, ''), created_at, updated_at
+		FROM devices
+		WHERE user_id = $1
+		ORDER BY updated_at DESC
+	`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list devices: %w", err)
+	}
+	defer rows.Close()
+
+	var devices []*Device
+	for rows.Next() {
+		d := &Device{}
+		err := rows.Scan(&d.ID, &d.UserID, &d.Token, &d.Platform, &d.AppVersion, &d.CreatedAt, &d.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan device: %w", err)
+		}
+		devices = append(devices, d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate devices: %w", err)
+	}
+
+	return devices, nil
+}
+
+// DeleteTokens removes device tokens whatever user they belong to. It is
+// used to prune tokens the push provider reports as expired.
+func (r *postgresRepository) DeleteTokens(ctx context.Context, tokens []string) error {
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	query := `DELETE FROM devices WHERE token = ANY($1)`
+
+	if _, err := r.db.Exec(ctx, query, tokens); err != nil {
+		return fmt.Errorf("failed to delete device tokens: %w", err)
+	}
+
+	return nil
+}
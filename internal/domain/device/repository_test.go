@@ -0,0 +1,94 @@
+package device
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"fowergram-backend/internal/infra/database/dbtest"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// addUser inserts an active user and returns its ID
+func addUser(t *testing.T, db *pgxpool.Pool, username string) uuid.UUID {
+	t.Helper()
+	id := uuid.New()
+	_, err := db.Exec(context.Background(), `
+		INSERT INTO users (id, email, username, full_name, bio, profile_picture)
+		VALUES ($1, $2, $3, '', '', '')
+	`, id, username+"@example.com", username)
+	if err != nil {
+		t.Fatalf("adding %s: %v", username, err)
+	}
+	return id
+}
+
+func TestRepositoryDevices(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.MigratedPool(t)
+	repo := NewRepository(db)
+	alice, bob := addUser(t, db, "alice"), addUser(t, db, "bob")
+
+	registeredAt := time.Now().Add(-time.Hour)
+	register := func(userID uuid.UUID, token, platform string) *Device {
+		t.Helper()
+		registeredAt = registeredAt.Add(time.Minute)
+		d := &Device{ID: uuid.New(), UserID: userID, Token: token, Platform: platform, CreatedAt: registeredAt, UpdatedAt: registeredAt}
+		if err := repo.Register(ctx, d); err != nil {
+			t.Fatalf("Register: %v", err)
+		}
+		return d
+	}
+	tokens := func(userID uuid.UUID) []string {
+		t.Helper()
+		devices, err := repo.ListByUser(ctx, userID)
+		if err != nil {
+			t.Fatalf("ListByUser: %v", err)
+		}
+		var tokens []string
+		for _, d := range devices {
+			tokens = append(tokens, d.Token)
+		}
+		return tokens
+	}
+
+	phone := register(alice, "phone", PlatformIOS)
+	register(alice, "tablet", PlatformAndroid)
+	register(alice, "browser", PlatformWeb)
+	if got, want := tokens(alice), []string{"browser", "tablet", "phone"}; !slices.Equal(got, want) {
+		t.Errorf("alice's tokens = %v, want %v, most recently registered first", got, want)
+	}
+
+	// Registering a token again keeps its row and moves it to the new user
+	again := register(bob, "phone", PlatformIOS)
+	if again.ID != phone.ID || !again.CreatedAt.Equal(phone.CreatedAt.Truncate(time.Microsecond)) {
+		t.Errorf("registered again as %s created %v, want the original %s created %v", again.ID, again.CreatedAt, phone.ID, phone.CreatedAt)
+	}
+	if got, want := tokens(alice), []string{"browser", "tablet"}; !slices.Equal(got, want) {
+		t.Errorf("alice's tokens = %v, want %v", got, want)
+	}
+	if got, want := tokens(bob), []string{"phone"}; !slices.Equal(got, want) {
+		t.Errorf("bob's tokens = %v, want %v", got, want)
+	}
+
+	if removed, err := repo.Unregister(ctx, alice, "phone"); err != nil || removed {
+		t.Errorf("Unregister of bob's token by alice = %v, %v; want nothing removed", removed, err)
+	}
+
+	// Expired tokens are pruned whoever they belong to
+	if err := repo.DeleteTokens(ctx, []string{"phone", "tablet", "unknown"}); err != nil {
+		t.Fatalf("DeleteTokens: %v", err)
+	}
+	if err := repo.DeleteTokens(ctx, nil); err != nil {
+		t.Fatalf("DeleteTokens(nil): %v", err)
+	}
+	if got, want := tokens(alice), []string{"browser"}; !slices.Equal(got, want) {
+		t.Errorf("alice's tokens after pruning = %v, want %v", got, want)
+	}
+	if got := tokens(bob); len(got) != 0 {
+		t.Errorf("bob's tokens after pruning = %v, want none", got)
+	}
+}
@@ -0,0 +1,63 @@
+package device
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"fowergram-backend/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// service implements Service
+type service struct {
+	repo   Repository
+	logger logger.Logger
+}
+
+// NewService creates a new device service
+func NewService(repo Repository, logger logger.Logger) Service {
+	return &service{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+// Register stores a push token for the user's app install. Registering the
+// same token again refreshes its platform and app version.
+func (s *service) Register(ctx context.Context, userID uuid.UUID, input RegisterInput) (*Device, error) {
+	platform := strings.ToLower(strings.TrimSpace(input.Platform))
+	if !validPlatform(platform) {
+		return nil, ErrInvalidPlatform
+	}
+
+	now := time.Now()
+	device := &Device{
+		ID:         uuid.New(),
+		UserID:     userID,
+		Token:      strings.TrimSpace(input.Token),
+		Platform:   platform,
+		AppVersion: strings.TrimSpace(input.AppVersion),
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+
+	if err := s.repo.Register(ctx, device); err != nil {
+		return nil, err
+	}
+
+	return device, nil
+}
+
+// Unregister removes one of the user's push tokens, typically on sign-out
+func (s *service) Unregister(ctx context.Context, userID uuid.UUID, token string) error {
+	removed, err := s.repo.Unregister(ctx, userID, token)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return ErrDeviceNotFound
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package device
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"fowergram-backend/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// fakeRepository keeps devices in memory, moving a token registered again
+// to its new user as the devices table's unique token does
+type fakeRepository struct {
+	devices []*Device
+}
+
+func (r *fakeRepository) Register(ctx context.Context, device *Device) error {
+	for _, d := range r.devices {
+		if d.Token == device.Token {
+			d.UserID, d.Platform, d.AppVersion, d.UpdatedAt = device.UserID, device.Platform, device.AppVersion, device.UpdatedAt
+			device.ID, device.CreatedAt = d.ID, d.CreatedAt
+			return nil
+		}
+	}
+	copied := *device
+	r.devices = append(r.devices, &copied)
+	return nil
+}
+
+func (r *fakeRepository) Unregister(ctx context.Context, userID uuid.UUID, token string) (bool, error) {
+	before := len(r.devices)
+	r.devices = slices.DeleteFunc(r.devices, func(d *Device) bool {
+		return d.UserID == userID && d.Token == token
+	})
+	return len(r.devices) < before, nil
+}
+
+func (r *fakeRepository) ListByUser(ctx context.Context, userID uuid.UUID) ([]*Device, error) {
+	var devices []*Device
+	for _, d := range r.devices {
+		if d.UserID == userID {
+			devices = append(devices, d)
+		}
+	}
+	return devices, nil
+}
+
+func (r *fakeRepository) DeleteTokens(ctx context.Context, tokens []string) error {
+	r.devices = slices.DeleteFunc(r.devices, func(d *Device) bool {
+		return slices.Contains(tokens, d.Token)
+	})
+	return nil
+}
+
+func TestRegister(t *testing.T) {
+	ctx := context.Background()
+	alice, bob := uuid.New(), uuid.New()
+
+	tests := []struct {
+		name         string
+		user         uuid.UUID
+		input        RegisterInput
+		wantErr      error
+		wantPlatform string
+		wantDevices  map[uuid.UUID][]string // Tokens by user after registering
+	}{
+		{
+			name:         "registered",
+			user:         alice,
+			input:        RegisterInput{Token: " token-1 ", Platform: " iOS ", AppVersion: " 2.1.0 "},
+			wantPlatform: PlatformIOS,
+			wantDevices:  map[uuid.UUID][]string{alice: {"token-1"}},
+		},
+		{
+			name:         "registered again",
+			user:         alice,
+			input:        RegisterInput{Token: "token-1", Platform: "ios", AppVersion: "2.2.0"},
+			wantPlatform: PlatformIOS,
+			wantDevices:  map[uuid.UUID][]string{alice: {"token-1"}},
+		},
+		{
+			name:         "second device",
+			user:         alice,
+			input:        RegisterInput{Token: "token-2", Platform: "web"},
+			wantPlatform: PlatformWeb,
+			wantDevices:  map[uuid.UUID][]string{alice: {"token-1", "token-2"}},
+		},
+		{
+			name:         "taken over by another user",
+			user:         bob,
+			input:        RegisterInput{Token: "token-1", Platform: "android"},
+			wantPlatform: PlatformAndroid,
+			wantDevices:  map[uuid.UUID][]string{alice: {"token-2"}, bob: {"token-1"}},
+		},
+		{
+			name:        "unknown platform",
+			user:        bob,
+			input:       RegisterInput{Token: "token-3", Platform: "windows"},
+			wantErr:     ErrInvalidPlatform,
+			wantDevices: map[uuid.UUID][]string{alice: {"token-2"}, bob: {"token-1"}},
+		},
+	}
+
+	repo := &fakeRepository{}
+	service := NewService(repo, logger.NewZapLogger())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := service.Register(ctx, tt.user, tt.input)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Register error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (d.UserID != tt.user || d.Platform != tt.wantPlatform) {
+				t.Errorf("registered %+v, want a %s device of %s", d, tt.wantPlatform, tt.user)
+			}
+
+			for userID, want := range tt.wantDevices {
+				devices, _ := repo.ListByUser(ctx, userID)
+				var tokens []string
+				for _, d := range devices {
+					tokens = append(tokens, d.Token)
+				}
+				if !slices.Equal(tokens, want) {
+					t.Errorf("%s's tokens = %v, want %v", userID, tokens, want)
+				}
+			}
+		})
+	}
+
+	// Only the token's current user can unregister it
+	if err := service.Unregister(ctx, alice, "token-1"); !errors.Is(err, ErrDeviceNotFound) {
+		t.Errorf("Unregister of a token taken over error = %v, want %v", err, ErrDeviceNotFound)
+	}
+	if err := service.Unregister(ctx, bob, "token-1"); err != nil {
+		t.Errorf("Unregister: %v", err)
+	}
+	if devices, _ := repo.ListByUser(ctx, bob); len(devices) != 0 {
+		t.Errorf("bob's devices after unregistering = %v, want none", devices)
+	}
+}
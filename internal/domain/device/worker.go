@@ -0,0 +1,85 @@
+package device
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"fowergram-backend/internal/events"
+	"fowergram-backend/internal/infra/messaging"
+	"fowergram-backend/pkg/logger"
+)
+
+// pushTimeout bounds delivering one push.send event to all its devices
+const pushTimeout = 30 * time.Second
+
+// pushQueue is the durable subscription shared by every worker, so each push
+// is sent once however many instances run
+const pushQueue = "push"
+
+// Worker delivers push.send events through a Sender and prunes the tokens
+// the provider reports as expired
+type Worker struct {
+	repo      Repository
+	sender    Sender
+	messaging messaging.Client
+	logger    logger.Logger
+	subs      []*messaging.Subscription
+}
+
+// NewWorker creates a new push worker
+func NewWorker(repo Repository, sender Sender, messaging messaging.Client, logger logger.Logger) *Worker {
+	return &Worker{
+		repo:      repo,
+		sender:    sender,
+		messaging: messaging,
+		logger:    logger,
+	}
+}
+
+// Start subscribes the worker to push.send events
+func (w *Worker) Start() error {
+	dispatcher := events.NewDispatcher(pushTimeout, w.logger)
+	dispatcher.Handle(events.TypePushSend, w.handle)
+
+	subs, err := dispatcher.Subscribe(w.messaging, pushQueue)
+	w.subs = subs
+	return err
+}
+
+// Stop stops the worker taking new events and waits for the pushes it holds
+// to be sent
+func (w *Worker) Stop(ctx context.Context) error {
+	return messaging.DrainAll(ctx, w.subs)
+}
+
+// handle sends a push to each device. Failures are logged rather than
+// returned, since redelivering the event would repeat the push on the
+// devices that did get it.
+func (w *Worker) handle(ctx context.Context, e *events.Envelope) error {
+	var event events.PushSend
+	if err := e.DecodePayload(&event); err != nil {
+		return err
+	}
+
+	msg := Message{Title: event.Title, Body: event.Body, Data: event.Data}
+	log := w.logger.With("user_id", event.UserID)
+
+	var expired []string
+	for _, d := range event.Devices {
+		err := w.sender.Send(ctx, d.Token, d.Platform, msg)
+		switch {
+		case err == nil:
+		case errors.Is(err, ErrTokenExpired):
+			expired = append(expired, d.Token)
+		default:
+			log.Error("Failed to send push", "platform", d.Platform, "error", err)
+		}
+	}
+
+	if err := w.repo.DeleteTokens(ctx, expired); err != nil {
+		log.Error("Failed to prune expired device tokens", "tokens", len(expired), "error", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package export
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Export is a machine-readable copy of everything a user has stored with us.
+// It only ever carries public identifiers for other users.
+type Export struct {
+	GeneratedAt time.Time     `json:"generated_at"`
+	Profile     *Profile      `json:"profile"`
+	Posts       []*Post       `json:"posts"`
+	Comments    []*Comment    `json:"comments"`
+	Followers   []*Connection `json:"followers"`
+	Following   []*Connection `json:"following"`
+}
+
+// Profile holds the exporting user's own account data. The password hash is
+// deliberately not part of this type.
+type Profile struct {
+	ID             uuid.UUID  `json:"id"`
+	Email          string     `json:"email"`
+	Username       string     `json:"username"`
+	FullName       string     `json:"full_name,omitempty"`
+	Bio            string     `json:"bio,omitempty"`
+	ProfilePicture string     `json:"profile_picture,omitempty"`
+	IsPrivate      bool       `json:"is_private"`
+	IsVerified     bool       `json:"is_verified"`
+	CreatedAt      time.Time  `json:"created_at"`
+	LastLoginAt    *time.Time `json:"last_login_at,omitempty"`
+}
+
+// Post is one of the user's posts
+type Post struct {
+	ID            uuid.UUID `json:"id"`
+	Title         string    `json:"title,omitempty"`
+	Content       string    `json:"content,omitempty"`
+	Caption       *string   `json:"caption,omitempty"`
+	Location      *string   `json:"location,omitempty"`
+	IsPrivate     bool      `json:"is_private"`
+	MediaKeys     []string  `json:"media_keys,omitempty"`
+	LikesCount    int       `json:"likes_count"`
+	CommentsCount int       `json:"comments_count"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// Comment is a comment the user wrote
+type Comment struct {
+	ID        uuid.UUID  `json:"id"`
+	PostID    uuid.UUID  `json:"post_id"`
+	ParentID  *uuid.UUID `json:"parent_id,omitempty"`
+	Body      string     `json:"body"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Connection is another user in a follow relationship, reduced to public identifiers
+type Connection struct {
+	UserID   uuid.UUID `json:"user_id"`
+	Username string    `json:"username"`
+	Since    time.Time `json:"since"`
+}
+
+// Repository defines the queries needed to assemble an export
+type Repository interface {
+	GetProfile(ctx context.Context, userID uuid.UUID) (*Profile, error)
+	GetPosts(ctx context.Context, userID uuid.UUID) ([]*Post, error)
+	GetComments(ctx context.Context, userID uuid.UUID) ([]*Comment, error)
+	GetFollowers(ctx context.Context, userID uuid.UUID) ([]*Connection, error)
+	GetFollowing(ctx context.Context, userID uuid.UUID) ([]*Connection, error)
+}
+
+// Service defines the interface for data export
+type Service interface {
+	Export(ctx context.Context, userID uuid.UUID) (*Export, error)
+}
@@ -0,0 +1,188 @@
+package export
+
+import (
+	"context"
+	"fmt"
+
+	"fowergram-backend/pkg/auth"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// postgresRepository implements Repository using PostgreSQL
+type postgresRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewRepository creates a new PostgreSQL export repository
+func NewRepository(db *pgxpool.Pool) Repository {
+	return &postgresRepository{db: db}
+}
+
+// GetProfile retrieves the user's own account data
+func (r *postgresRepository) GetProfile(ctx context.Context, userID uuid.UUID) (*Profile, error) {
+	query := `
+		SELECT id, email, username, COALESCE(full_name, ''), COALESCE(bio, ''),
+			   COALESCE(profile_picture, ''), is_private, is_verified, created_at, last_login_at
+		FROM users
+		WHERE id = $1
+	`
+
+	var p Profile
+	err := r.db.QueryRow(ctx, query, userID).Scan(
+		&p.ID,
+		&p.Email,
+		&p.Username,
+		&p.FullName,
+		&p.Bio,
+		&p.ProfilePicture,
+		&p.IsPrivate,
+		&p.IsVerified,
+		&p.CreatedAt,
+		&p.LastLoginAt,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, auth.ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to get profile: %w", err)
+	}
+
+	return &p, nil
+}
+
+// GetPosts retrieves all of the user's live posts with their media keys
+func (r *postgresRepository) GetPosts(ctx context.Context, userID uuid.UUID) ([]*Post, error) {
+	query := `
+		SELECT p.id, COALESCE(p.title, ''), COALESCE(p.content, ''), p.caption, p.location,
+			   p.is_private, p.likes_count, p.comments_count, p.created_at,
+			   COALESCE(
+				   ARRAY(
+					   SELECT m.original_key FROM post_media pm
+					   JOIN media m ON m.id = pm.media_id
+					   WHERE pm.post_id = p.id
+					   ORDER BY pm.display_order
+				   ),
+				   '{}'
+			   )
+		FROM posts p
+		WHERE p.user_id = $1 AND p.deleted_at IS NULL
+		ORDER BY p.created_at
+	`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get posts: %w", err)
+	}
+	defer rows.Close()
+
+	posts := []*Post{}
+	for rows.Next() {
+		p := &Post{}
+		err := rows.Scan(
+			&p.ID,
+			&p.Title,
+			&p.Content,
+			&p.Caption,
+			&p.Location,
+			&p.IsPrivate,
+			&p.LikesCount,
+			&p.CommentsCount,
+			&p.CreatedAt,
+			&p.MediaKeys,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan post: %w", err)
+		}
+		posts = append(posts, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate posts: %w", err)
+	}
+
+	return posts, nil
+}
+
+// GetComments retrieves all live comments the user wrote
+func (r *postgresRepository) GetComments(ctx context.Context, userID uuid.UUID) ([]*Comment, error) {
+	query := `
+		SELECT id, post_id, parent_id, content, created_at
+		FROM comments
+		WHERE user_id = $1 AND deleted_at IS NULL
+		ORDER BY created_at
+	`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get comments: %w", err)
+	}
+	defer rows.Close()
+
+	comments := []*Comment{}
+	for rows.Next() {
+		c := &Comment{}
+		if err := rows.Scan(&c.ID, &c.PostID, &c.ParentID, &c.Body, &c.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan comment: %w", err)
+		}
+		comments = append(comments, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate comments: %w", err)
+	}
+
+	return comments, nil
+}
+
+// GetFollowers retrieves the user's followers
+func (r *postgresRepository) GetFollowers(ctx context.Context, userID uuid.UUID) ([]*Connection, error) {
+	query := `
+		SELECT u.id, u.username, f.created_at
+		FROM followers f
+		JOIN users u ON u.id = f.follower_id
+		WHERE f.following_id = $1
+		ORDER BY f.created_at
+	`
+
+	return r.connections(ctx, query, userID)
+}
+
+// GetFollowing retrieves the accounts the user follows
+func (r *postgresRepository) GetFollowing(ctx context.Context, userID uuid.UUID) ([]*Connection, error) {
+	query := `
+		SELECT u.id, u.username, f.created_at
+		FROM followers f
+		JOIN users u ON u.id = f.following_id
+		WHERE f.follower_id = $1
+		ORDER BY f.created_at
+	`
+
+	return r.connections(ctx, query, userID)
+}
+
+// connections runs a follow relationship query bound to a user ID
+func (r *postgresRepository) connections(ctx context.Context, query string, userID uuid.UUID) ([]*Connection, error) {
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connections: %w", err)
+	}
+	defer rows.Close()
+
+	connections := []*Connection{}
+	for rows.Next() {
+		c := &Connection{}
+		if err := rows.Scan(&c.UserID, &c.Username, &c.Since); err != nil {
+			return nil, fmt.Errorf("failed to scan connection: %w", err)
+		}
+		connections = append(connections, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate connections: %w", err)
+	}
+
+	return connections, nil
+}
@@ -0,0 +1,63 @@
+package export
+
+import (
+	"context"
+	"time"
+
+	"fowergram-backend/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// service implements Service
+type service struct {
+	repo   Repository
+	logger logger.Logger
+}
+
+// NewService creates a new export service
+func NewService(repo Repository, logger logger.Logger) Service {
+	return &service{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+// Export gathers the user's profile, posts, comments and follow relationships
+func (s *service) Export(ctx context.Context, userID uuid.UUID) (*Export, error) {
+	profile, err := s.repo.GetProfile(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	posts, err := s.repo.GetPosts(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	comments, err := s.repo.GetComments(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	followers, err := s.repo.GetFollowers(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	following, err := s.repo.GetFollowing(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Generated data export", "user_id", userID, "posts", len(posts), "comments", len(comments))
+
+	return &Export{
+		GeneratedAt: time.Now().UTC(),
+		Profile:     profile,
+		Posts:       posts,
+		Comments:    comments,
+		Followers:   followers,
+		Following:   following,
+	}, nil
+}
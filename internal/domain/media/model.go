@@ -0,0 +1,154 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"fowergram-backend/internal/infra/storage"
+
+	"github.com/google/uuid"
+)
+
+// Variant identifies a processed rendition of an uploaded image
+type Variant string
+
+const (
+	VariantOriginal  Variant = "original"
+	VariantFeed      Variant = "feed"
+	VariantThumbnail Variant = "thumbnail"
+)
+
+// Status represents the processing state of an upload
+type Status string
+
+const (
+	StatusAwaitingUpload Status = "awaiting_upload" // Presigned; the client hasn't confirmed the upload yet
+	StatusPending        Status = "pending"
+	StatusReady          Status = "ready"
+	StatusFailed         Status = "failed"
+)
+
+// MaxDirectUploadSize is the largest file a client may upload with a presigned URL
+const MaxDirectUploadSize = 50 << 20
+
+// SubjectMediaUploaded is published when an upload is queued for background processing
+const SubjectMediaUploaded = "media.uploaded"
+
+// Common media errors
+var (
+	ErrMediaNotFound          = errors.New("media not found")
+	ErrUnsupportedContentType = errors.New("unsupported content type")
+	ErrFileTooLarge           = errors.New("file exceeds the maximum upload size")
+	ErrUploadIncomplete       = errors.New("media has not been uploaded")
+	ErrInvalidMediaKey        = errors.New("invalid media key")
+	ErrInvalidImage           = errors.New("image could not be decoded")
+	ErrImageTooLarge          = errors.New("image exceeds the maximum of 40 megapixels")
+
+	// ErrStorageUnavailable is returned for uploads while the server runs
+	// without storage
+	ErrStorageUnavailable = storage.ErrStorageUnavailable
+)
+
+// Media represents an uploaded image and its processed variants
+type Media struct {
+	ID           uuid.UUID          `json:"id" db:"id"`
+	UserID       uuid.UUID          `json:"user_id" db:"user_id"`
+	SourceKey    string             `json:"-" db:"source_key"`
+	OriginalKey  string             `json:"original_key" db:"original_key"`
+	FeedKey      *string            `json:"feed_key,omitempty" db:"feed_key"`
+	ThumbnailKey *string            `json:"thumbnail_key,omitempty" db:"thumbnail_key"`
+	ContentType  string             `json:"content_type" db:"content_type"`
+	Width        int                `json:"width" db:"width"`
+	Height       int                `json:"height" db:"height"`
+	FileSize     int64              `json:"file_size" db:"file_size"`
+	Status       Status             `json:"status" db:"status"`
+	CreatedAt    time.Time          `json:"created_at" db:"created_at"`
+	ProcessedAt  *time.Time         `json:"processed_at,omitempty" db:"processed_at"`
+	URLs         map[Variant]string `json:"urls,omitempty" db:"-"`
+}
+
+// Keys returns the storage keys of every variant that has been produced
+func (m *Media) Keys() map[Variant]string {
+	keys := make(map[Variant]string, 3)
+	if m.Status != StatusReady {
+		return keys
+	}
+	keys[VariantOriginal] = m.OriginalKey
+	if m.FeedKey != nil {
+		keys[VariantFeed] = *m.FeedKey
+	}
+	if m.ThumbnailKey != nil {
+		keys[VariantThumbnail] = *m.ThumbnailKey
+	}
+	return keys
+}
+
+// DirectUpload is a pending upload the client sends straight to storage.
+// Media.OriginalKey is the key to reference once the upload has finished.
+type DirectUpload struct {
+	Media     *Media
+	URL       string
+	Headers   map[string]string // Headers the PUT request must carry exactly
+	ExpiresAt time.Time
+}
+
+// UploadedEvent is the payload published on SubjectMediaUploaded
+type UploadedEvent struct {
+	MediaID uuid.UUID `json:"media_id"`
+}
+
+// UserKeyPrefix returns the storage prefix every key of a user's uploads starts with
+func UserKeyPrefix(userID uuid.UUID) string {
+	return fmt.Sprintf("media/%s/", userID)
+}
+
+// ObjectKey returns the deterministic storage key for a variant
+func ObjectKey(userID, mediaID uuid.UUID, variant Variant) string {
+	return fmt.Sprintf("%s%s/%s.jpg", UserKeyPrefix(userID), mediaID, variant)
+}
+
+// SourceKey returns the storage key for the raw, unprocessed upload
+func SourceKey(userID, mediaID uuid.UUID) string {
+	return fmt.Sprintf("%s%s/source", UserKeyPrefix(userID), mediaID)
+}
+
+// ValidateKey reports whether key is a clean storage key under the user's prefix
+func ValidateKey(userID uuid.UUID, key string) error {
+	if key != path.Clean(key) || !strings.HasPrefix(key, UserKeyPrefix(userID)) {
+		return ErrInvalidMediaKey
+	}
+	return nil
+}
+
+// storedKey returns the object that must exist in storage for m: the
+// processed original once ready, the raw upload before that
+func (m *Media) storedKey() string {
+	if m.Status == StatusReady {
+		return m.OriginalKey
+	}
+	return m.SourceKey
+}
+
+// Repository defines the interface for media data persistence
+type Repository interface {
+	Create(ctx context.Context, m *Media) error
+	GetByID(ctx context.Context, id uuid.UUID) (*Media, error)
+	GetByOriginalKeys(ctx context.Context, userID uuid.UUID, keys []string) ([]*Media, error)
+	MarkUploaded(ctx context.Context, id uuid.UUID) (bool, error)
+	UpdateProcessed(ctx context.Context, m *Media) error
+	MarkFailed(ctx context.Context, id uuid.UUID) error
+}
+
+// Service defines the interface for media business logic
+type Service interface {
+	Upload(ctx context.Context, userID uuid.UUID, data []byte, contentType string) (*Media, error)
+	PresignUpload(ctx context.Context, userID uuid.UUID, contentType string, size int64) (*DirectUpload, error)
+	ConfirmUploads(ctx context.Context, items []*Media) error
+	Process(ctx context.Context, id uuid.UUID) error
+	GetUserMedia(ctx context.Context, userID uuid.UUID, keys []string) ([]*Media, error)
+	ResolveURLs(ctx context.Context, m *Media) error
+}
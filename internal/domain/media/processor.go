@@ -0,0 +1,179 @@
+package media
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+
+	// Register decoders for the accepted upload formats
+	_ "image/gif"
+	_ "image/png"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// Target sizes (longest edge, in pixels) for generated variants
+const (
+	ThumbnailSize = 256
+	FeedSize      = 1080
+)
+
+// AvatarSize is the edge length, in pixels, of processed avatars
+const AvatarSize = 320
+
+// MaxImagePixels is the largest image, in pixels, the processor decodes.
+// Headers are checked before decoding, so a small file declaring huge
+// dimensions is rejected without allocating its pixels.
+const MaxImagePixels = 40_000_000
+
+// jpegQuality is the encoder quality used for every variant
+const jpegQuality = 85
+
+// allowedContentTypes lists the upload formats the processor can decode
+var allowedContentTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// IsAllowedContentType reports whether an upload of this type can be processed
+func IsAllowedContentType(contentType string) bool {
+	return allowedContentTypes[contentType]
+}
+
+// ProcessedImage holds the encoded variants of a single upload
+type ProcessedImage struct {
+	Width    int
+	Height   int
+	Variants map[Variant][]byte
+}
+
+// ProcessImage decodes an upload and renders the original, feed and thumbnail
+// variants as JPEG. Re-encoding from decoded pixels drops all EXIF metadata,
+// including GPS coordinates, from every variant; the EXIF orientation is
+// applied to the pixels first so photos keep displaying upright.
+func ProcessImage(data []byte) (*ProcessedImage, error) {
+	src, err := decodeImage(data)
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := src.Bounds()
+	result := &ProcessedImage{
+		Width:    bounds.Dx(),
+		Height:   bounds.Dy(),
+		Variants: make(map[Variant][]byte, 3),
+	}
+
+	sizes := map[Variant]int{
+		VariantOriginal:  0,
+		VariantFeed:      FeedSize,
+		VariantThumbnail: ThumbnailSize,
+	}
+
+	for variant, size := range sizes {
+		encoded, err := encodeJPEG(resize(src, size))
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s variant: %w", variant, err)
+		}
+		result.Variants[variant] = encoded
+	}
+
+	return result, nil
+}
+
+// ProcessAvatar center-crops an image to a square, scales it down to at most
+// AvatarSize and encodes it as JPEG. Like ProcessImage it rejects images over
+// MaxImagePixels before decoding them and drops EXIF metadata once the
+// orientation is applied.
+func ProcessAvatar(data []byte) ([]byte, error) {
+	src, err := decodeImage(data)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, err := encodeJPEG(resize(cropSquare(src), AvatarSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode avatar: %w", err)
+	}
+
+	return encoded, nil
+}
+
+// decodeImage decodes an upload of at most MaxImagePixels and rotates or
+// flips it upright according to its EXIF orientation
+func decodeImage(data []byte) (image.Image, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	if config.Width <= 0 || config.Height <= 0 {
+		return nil, ErrInvalidImage
+	}
+	if int64(config.Width)*int64(config.Height) > MaxImagePixels {
+		return nil, ErrImageTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+
+	return orient(img, exifOrientation(data)), nil
+}
+
+// cropSquare returns the largest square centered in img
+func cropSquare(img image.Image) image.Image {
+	bounds := img.Bounds()
+	side := min(bounds.Dx(), bounds.Dy())
+	x := bounds.Min.X + (bounds.Dx()-side)/2
+	y := bounds.Min.Y + (bounds.Dy()-side)/2
+	square := image.Rect(x, y, x+side, y+side)
+
+	dst := image.NewRGBA(image.Rect(0, 0, side, side))
+	draw.Draw(dst, dst.Bounds(), img, square.Min, draw.Src)
+	return dst
+}
+
+// resize scales img so its longest edge is at most maxEdge, never upscaling.
+// A maxEdge of zero keeps the original dimensions.
+func resize(img image.Image, maxEdge int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	longest := width
+	if height > longest {
+		longest = height
+	}
+
+	if maxEdge > 0 && longest > maxEdge {
+		width = width * maxEdge / longest
+		height = height * maxEdge / longest
+	}
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	if width == bounds.Dx() && height == bounds.Dy() {
+		draw.Draw(dst, dst.Bounds(), img, bounds.Min, draw.Src)
+		return dst
+	}
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+	return dst
+}
+
+// encodeJPEG encodes an image as JPEG
+func encodeJPEG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
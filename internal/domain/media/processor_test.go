@@ -0,0 +1,214 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// halvesImage returns a width×height image, red on the left half and blue on
+// the right, so rotations and flips are visible after re-encoding
+func halvesImage(width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			c := color.RGBA{R: 220, B: 20, A: 255}
+			if x >= width/2 {
+				c = color.RGBA{R: 20, B: 220, A: 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+// exifJPEG encodes img as JPEG carrying an EXIF segment with the given
+// orientation and a GPS latitude
+func exifJPEG(t *testing.T, img image.Image, orientation uint16) []byte {
+	t.Helper()
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, img, nil); err != nil {
+		t.Fatalf("failed to encode image: %v", err)
+	}
+
+	// A little-endian TIFF header, IFD0 with the orientation and a pointer
+	// to the GPS IFD at offset 38, then the GPS IFD with GPSLatitudeRef "N"
+	order := binary.LittleEndian
+	tiff := []byte("II\x2a\x00")
+	tiff = order.AppendUint32(tiff, 8)
+	tiff = order.AppendUint16(tiff, 2)
+	tiff = append(order.AppendUint16(order.AppendUint16(tiff, exifOrientationTag), 3), 1, 0, 0, 0)
+	tiff = append(order.AppendUint16(tiff, orientation), 0, 0)
+	tiff = append(order.AppendUint16(order.AppendUint16(tiff, 0x8825), 4), 1, 0, 0, 0)
+	tiff = order.AppendUint32(tiff, 38)
+	tiff = order.AppendUint32(tiff, 0)
+	tiff = order.AppendUint16(tiff, 1)
+	tiff = append(order.AppendUint16(order.AppendUint16(tiff, 0x0001), 2), 2, 0, 0, 0)
+	tiff = append(tiff, 'N', 0, 0, 0)
+	tiff = order.AppendUint32(tiff, 0)
+
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	segment := binary.BigEndian.AppendUint16([]byte{0xFF, 0xE1}, uint16(len(payload)+2))
+	segment = append(segment, payload...)
+
+	data := encoded.Bytes()
+	return append(append(append([]byte{}, data[:2]...), segment...), data[2:]...)
+}
+
+// oversizedPNG returns a PNG header declaring width×height pixels, followed
+// by no image data
+func oversizedPNG(width, height uint32) []byte {
+	ihdr := binary.BigEndian.AppendUint32([]byte("IHDR"), width)
+	ihdr = binary.BigEndian.AppendUint32(ihdr, height)
+	ihdr = append(ihdr, 8, 2, 0, 0, 0) // 8-bit RGB
+
+	data := []byte("\x89PNG\r\n\x1a\n")
+	data = binary.BigEndian.AppendUint32(data, uint32(len(ihdr)-4))
+	data = append(data, ihdr...)
+	return binary.BigEndian.AppendUint32(data, crc32.ChecksumIEEE(ihdr))
+}
+
+// isRed reports whether the pixel at (x, y) is closer to red than blue
+func isRed(img image.Image, x, y int) bool {
+	r, _, b, _ := img.At(x, y).RGBA()
+	return r > b
+}
+
+func TestProcessImageVariants(t *testing.T) {
+	tests := []struct {
+		name   string
+		width  int
+		height int
+		want   map[Variant]image.Point
+	}{
+		{
+			name:   "landscape",
+			width:  2000,
+			height: 1000,
+			want: map[Variant]image.Point{
+				VariantOriginal:  {2000, 1000},
+				VariantFeed:      {FeedSize, 540},
+				VariantThumbnail: {ThumbnailSize, 128},
+			},
+		},
+		{
+			name:   "portrait",
+			width:  600,
+			height: 1200,
+			want: map[Variant]image.Point{
+				VariantOriginal:  {600, 1200},
+				VariantFeed:      {540, FeedSize},
+				VariantThumbnail: {128, ThumbnailSize},
+			},
+		},
+		{
+			name:   "smaller than the feed size is not upscaled",
+			width:  400,
+			height: 200,
+			want: map[Variant]image.Point{
+				VariantOriginal:  {400, 200},
+				VariantFeed:      {400, 200},
+				VariantThumbnail: {ThumbnailSize, 128},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processed, err := ProcessImage(exifJPEG(t, halvesImage(tt.width, tt.height), 1))
+			if err != nil {
+				t.Fatalf("ProcessImage: %v", err)
+			}
+			if processed.Width != tt.width || processed.Height != tt.height {
+				t.Errorf("dimensions = %dx%d, want %dx%d", processed.Width, processed.Height, tt.width, tt.height)
+			}
+
+			for variant, want := range tt.want {
+				data := processed.Variants[variant]
+				config, format, err := image.DecodeConfig(bytes.NewReader(data))
+				if err != nil || format != "jpeg" {
+					t.Fatalf("decoding the %s variant: format %q, %v", variant, format, err)
+				}
+				if got := image.Pt(config.Width, config.Height); got != want {
+					t.Errorf("%s variant is %v, want %v", variant, got, want)
+				}
+				if exifOrientation(data) != 1 || bytes.Contains(data, []byte("Exif")) || bytes.Contains(data, []byte{0xFF, 0xE1}) {
+					t.Errorf("%s variant keeps EXIF metadata", variant)
+				}
+			}
+		})
+	}
+}
+
+func TestProcessImageOrientation(t *testing.T) {
+	// halvesImage(40, 20) with red on the left; wantRed lists points of the
+	// upright result that should show the red half, wantBlue the blue half
+	tests := []struct {
+		orientation uint16
+		wantSize    image.Point
+		wantRed     image.Point
+		wantBlue    image.Point
+	}{
+		{orientation: 1, wantSize: image.Pt(40, 20), wantRed: image.Pt(5, 10), wantBlue: image.Pt(35, 10)},
+		{orientation: 2, wantSize: image.Pt(40, 20), wantRed: image.Pt(35, 10), wantBlue: image.Pt(5, 10)},
+		{orientation: 3, wantSize: image.Pt(40, 20), wantRed: image.Pt(35, 10), wantBlue: image.Pt(5, 10)},
+		{orientation: 4, wantSize: image.Pt(40, 20), wantRed: image.Pt(5, 10), wantBlue: image.Pt(35, 10)},
+		{orientation: 5, wantSize: image.Pt(20, 40), wantRed: image.Pt(10, 5), wantBlue: image.Pt(10, 35)},
+		{orientation: 6, wantSize: image.Pt(20, 40), wantRed: image.Pt(10, 5), wantBlue: image.Pt(10, 35)},
+		{orientation: 7, wantSize: image.Pt(20, 40), wantRed: image.Pt(10, 35), wantBlue: image.Pt(10, 5)},
+		{orientation: 8, wantSize: image.Pt(20, 40), wantRed: image.Pt(10, 35), wantBlue: image.Pt(10, 5)},
+	}
+
+	for _, tt := range tests {
+		data := exifJPEG(t, halvesImage(40, 20), tt.orientation)
+		if got := exifOrientation(data); got != int(tt.orientation) {
+			t.Fatalf("exifOrientation = %d, want %d", got, tt.orientation)
+		}
+
+		processed, err := ProcessImage(data)
+		if err != nil {
+			t.Fatalf("ProcessImage with orientation %d: %v", tt.orientation, err)
+		}
+		img, err := jpeg.Decode(bytes.NewReader(processed.Variants[VariantOriginal]))
+		if err != nil {
+			t.Fatalf("decoding orientation %d: %v", tt.orientation, err)
+		}
+		if got := img.Bounds().Size(); got != tt.wantSize {
+			t.Errorf("orientation %d gives %v, want %v", tt.orientation, got, tt.wantSize)
+		}
+		if !isRed(img, tt.wantRed.X, tt.wantRed.Y) || isRed(img, tt.wantBlue.X, tt.wantBlue.Y) {
+			t.Errorf("orientation %d is not upright: want red at %v and blue at %v", tt.orientation, tt.wantRed, tt.wantBlue)
+		}
+	}
+}
+
+func TestProcessImageTooLarge(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr error
+	}{
+		{name: "declared 50000x50000", data: oversizedPNG(50000, 50000), wantErr: ErrImageTooLarge},
+		{name: "just over the cap", data: oversizedPNG(MaxImagePixels/1000+1, 1000), wantErr: ErrImageTooLarge},
+		{name: "within the cap but truncated", data: oversizedPNG(100, 100), wantErr: ErrInvalidImage},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ProcessImage(tt.data); !errors.Is(err, tt.wantErr) {
+				t.Errorf("ProcessImage error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// The fixture is otherwise a valid header
+	if _, err := png.DecodeConfig(bytes.NewReader(oversizedPNG(50000, 50000))); err != nil {
+		t.Fatalf("DecodeConfig of the fixture: %v", err)
+	}
+}
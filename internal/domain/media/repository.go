@@ -0,0 +1,178 @@
+package media
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// postgresRepository implements Repository using PostgreSQL
+type postgresRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewRepository creates a new PostgreSQL media repository
+func NewRepository(db *pgxpool.Pool) Repository {
+	return &postgresRepository{db: db}
+}
+
+// Create inserts a new media row
+func (r *postgresRepository) Create(ctx context.Context, m *Media) error {
+	query := `
+		INSERT INTO media (
+			id, user_id, source_key, original_key, content_type,
+			file_size, status, created_at
+		) VALUES (
+			$1, $2, $3, $4, $5,
+			$6, $7, $8
+		)
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		m.ID, m.UserID, m.SourceKey, m.OriginalKey, m.ContentType,
+		m.FileSize, m.Status, m.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create media: %w", err)
+	}
+
+	return nil
+}
+
+// GetByID retrieves a media row by ID
+func (r *postgresRepository) GetByID(ctx context.Context, id uuid.UUID) (*Media, error) {
+	query := `
+		SELECT id, user_id, source_key, original_key, feed_key, thumbnail_key,
+			   content_type, COALESCE(width, 0), COALESCE(height, 0), COALESCE(file_size, 0),
+			   status, created_at, processed_at
+		FROM media
+		WHERE id = $1
+	`
+
+	m, err := scanMedia(r.db.QueryRow(ctx, query, id))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrMediaNotFound
+		}
+		return nil, fmt.Errorf("failed to get media: %w", err)
+	}
+
+	return m, nil
+}
+
+// GetByOriginalKeys retrieves a user's media rows by their original variant keys
+func (r *postgresRepository) GetByOriginalKeys(ctx context.Context, userID uuid.UUID, keys []string) ([]*Media, error) {
+	query := `
+		SELECT id, user_id, source_key, original_key, feed_key, thumbnail_key,
+			   content_type, COALESCE(width, 0), COALESCE(height, 0), COALESCE(file_size, 0),
+			   status, created_at, processed_at
+		FROM media
+		WHERE user_id = $1 AND original_key = ANY($2)
+	`
+
+	rows, err := r.db.Query(ctx, query, userID, keys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get media by keys: %w", err)
+	}
+	defer rows.Close()
+
+	var items []*Media
+	for rows.Next() {
+		m, err := scanMedia(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan media: %w", err)
+		}
+		items = append(items, m)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate media: %w", err)
+	}
+
+	return items, nil
+}
+
+// MarkUploaded moves a presigned upload to pending, reporting whether it was
+// still awaiting its upload so only one caller queues it for processing
+func (r *postgresRepository) MarkUploaded(ctx context.Context, id uuid.UUID) (bool, error) {
+	query := `
+		UPDATE media SET status = $1
+		WHERE id = $2 AND status = $3
+	`
+
+	tag, err := r.db.Exec(ctx, query, StatusPending, id, StatusAwaitingUpload)
+	if err != nil {
+		return false, fmt.Errorf("failed to mark media as uploaded: %w", err)
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
+
+// UpdateProcessed records the variant keys and dimensions produced by the processor
+func (r *postgresRepository) UpdateProcessed(ctx context.Context, m *Media) error {
+	query := `
+		UPDATE media SET
+			feed_key = $1,
+			thumbnail_key = $2,
+			width = $3,
+			height = $4,
+			status = $5,
+			processed_at = $6
+		WHERE id = $7
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		m.FeedKey, m.ThumbnailKey, m.Width, m.Height, m.Status, m.ProcessedAt,
+		m.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update media: %w", err)
+	}
+
+	return nil
+}
+
+// MarkFailed flags a media row whose processing failed
+func (r *postgresRepository) MarkFailed(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE media SET
+			status = $1,
+			processed_at = $2
+		WHERE id = $3
+	`
+
+	_, err := r.db.Exec(ctx, query, StatusFailed, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to mark media as failed: %w", err)
+	}
+
+	return nil
+}
+
+// scanMedia scans a single media row
+func scanMedia(row pgx.Row) (*Media, error) {
+	var m Media
+	err := row.Scan(
+		&m.ID,
+		&m.UserID,
+		&m.SourceKey,
+		&m.OriginalKey,
+		&m.FeedKey,
+		&m.ThumbnailKey,
+		&m.ContentType,
+		&m.Width,
+		&m.Height,
+		&m.FileSize,
+		&m.Status,
+		&m.CreatedAt,
+		&m.ProcessedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
@@ -0,0 +1,276 @@
+package media
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"fowergram-backend/internal/infra/messaging"
+	"fowergram-backend/internal/infra/storage"
+	"fowergram-backend/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// inlineProcessingLimit is the largest upload processed within the request;
+// anything bigger is handed to the background worker via NATS
+const inlineProcessingLimit = 2 << 20
+
+// service implements Service
+type service struct {
+	repo      Repository
+	storage   storage.Storage
+	messaging messaging.Client
+	logger    logger.Logger
+}
+
+// NewService creates a new media service
+func NewService(repo Repository, storage storage.Storage, messaging messaging.Client, logger logger.Logger) Service {
+	return &service{
+		repo:      repo,
+		storage:   storage,
+		messaging: messaging,
+		logger:    logger,
+	}
+}
+
+// Upload stores a raw upload and processes it inline or queues it for the worker
+func (s *service) Upload(ctx context.Context, userID uuid.UUID, data []byte, contentType string) (*Media, error) {
+	if !IsAllowedContentType(contentType) {
+		return nil, ErrUnsupportedContentType
+	}
+
+	id := uuid.New()
+	m := &Media{
+		ID:          id,
+		UserID:      userID,
+		SourceKey:   SourceKey(userID, id),
+		OriginalKey: ObjectKey(userID, id, VariantOriginal),
+		ContentType: contentType,
+		FileSize:    int64(len(data)),
+		Status:      StatusPending,
+		CreatedAt:   time.Now(),
+	}
+
+	if err := s.storage.UploadFile(ctx, m.SourceKey, data, contentType); err != nil {
+		return nil, fmt.Errorf("failed to store upload: %w", err)
+	}
+
+	if err := s.repo.Create(ctx, m); err != nil {
+		return nil, err
+	}
+
+	if len(data) <= inlineProcessingLimit {
+		if err := s.process(ctx, m, data); err != nil {
+			return nil, err
+		}
+		return m, nil
+	}
+
+	if err := s.queue(m.ID); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// PresignUpload reserves a media row and returns a URL the client uploads
+// the raw file to directly. The upload is processed once a post references
+// it; see ConfirmUploads.
+func (s *service) PresignUpload(ctx context.Context, userID uuid.UUID, contentType string, size int64) (*DirectUpload, error) {
+	if !IsAllowedContentType(contentType) {
+		return nil, ErrUnsupportedContentType
+	}
+	if size <= 0 || size > MaxDirectUploadSize {
+		return nil, ErrFileTooLarge
+	}
+
+	id := uuid.New()
+	m := &Media{
+		ID:          id,
+		UserID:      userID,
+		SourceKey:   SourceKey(userID, id),
+		OriginalKey: ObjectKey(userID, id, VariantOriginal),
+		ContentType: contentType,
+		FileSize:    size,
+		Status:      StatusAwaitingUpload,
+		CreatedAt:   time.Now(),
+	}
+
+	presigned, err := s.storage.PresignUpload(ctx, m.SourceKey, contentType, size)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Create(ctx, m); err != nil {
+		return nil, err
+	}
+
+	return &DirectUpload{
+		Media:     m,
+		URL:       presigned.URL,
+		Headers:   presigned.Headers,
+		ExpiresAt: presigned.ExpiresAt,
+	}, nil
+}
+
+// ConfirmUploads checks that every item's object exists in storage and
+// queues presigned uploads for processing now that they have arrived
+func (s *service) ConfirmUploads(ctx context.Context, items []*Media) error {
+	for _, m := range items {
+		info, err := s.storage.StatFile(ctx, m.storedKey())
+		if err != nil {
+			if errors.Is(err, storage.ErrObjectNotFound) {
+				return ErrUploadIncomplete
+			}
+			return err
+		}
+		if m.Status != StatusAwaitingUpload {
+			continue
+		}
+		if info.Size > MaxDirectUploadSize {
+			return ErrFileTooLarge
+		}
+
+		marked, err := s.repo.MarkUploaded(ctx, m.ID)
+		if err != nil {
+			return err
+		}
+		m.Status = StatusPending
+		if !marked {
+			continue
+		}
+
+		if err := s.queue(m.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// queue hands an upload to the background worker
+func (s *service) queue(id uuid.UUID) error {
+	payload, err := json.Marshal(UploadedEvent{MediaID: id})
+	if err != nil {
+		return fmt.Errorf("failed to encode upload event: %w", err)
+	}
+	if err := s.messaging.Publish(SubjectMediaUploaded, payload); err != nil {
+		return fmt.Errorf("failed to queue media processing: %w", err)
+	}
+	return nil
+}
+
+// Process generates the variants for a pending upload
+func (s *service) Process(ctx context.Context, id uuid.UUID) error {
+	m, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if m.Status != StatusPending {
+		return nil
+	}
+
+	data, err := s.storage.GetFile(ctx, m.SourceKey)
+	if err != nil {
+		return err
+	}
+
+	return s.process(ctx, m, data)
+}
+
+// GetUserMedia returns the user's uploads for the given original keys, in
+// key order. Keys outside the user's storage prefix are rejected up front.
+func (s *service) GetUserMedia(ctx context.Context, userID uuid.UUID, keys []string) ([]*Media, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	for _, key := range keys {
+		if err := ValidateKey(userID, key); err != nil {
+			return nil, err
+		}
+	}
+
+	found, err := s.repo.GetByOriginalKeys(ctx, userID, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	byKey := make(map[string]*Media, len(found))
+	for _, m := range found {
+		byKey[m.OriginalKey] = m
+	}
+
+	items := make([]*Media, 0, len(keys))
+	for _, key := range keys {
+		m, ok := byKey[key]
+		if !ok {
+			return nil, ErrMediaNotFound
+		}
+		items = append(items, m)
+	}
+
+	return items, nil
+}
+
+// ResolveURLs fills in download URLs for every available variant
+func (s *service) ResolveURLs(ctx context.Context, m *Media) error {
+	keys := m.Keys()
+	m.URLs = make(map[Variant]string, len(keys))
+	for variant, key := range keys {
+		url, err := s.storage.GetFileURL(ctx, key)
+		if err != nil {
+			return err
+		}
+		m.URLs[variant] = url
+	}
+	return nil
+}
+
+// process renders, stores and records the variants of an upload, then
+// removes the raw source so no EXIF-bearing copy is kept
+func (s *service) process(ctx context.Context, m *Media, data []byte) error {
+	processed, err := ProcessImage(data)
+	if err != nil {
+		if markErr := s.repo.MarkFailed(ctx, m.ID); markErr != nil {
+			s.logger.Error("Failed to mark media as failed", "media_id", m.ID, "error", markErr)
+		}
+		return err
+	}
+
+	feedKey := ObjectKey(m.UserID, m.ID, VariantFeed)
+	thumbnailKey := ObjectKey(m.UserID, m.ID, VariantThumbnail)
+	keys := map[Variant]string{
+		VariantOriginal:  m.OriginalKey,
+		VariantFeed:      feedKey,
+		VariantThumbnail: thumbnailKey,
+	}
+
+	for variant, key := range keys {
+		if err := s.storage.UploadFile(ctx, key, processed.Variants[variant], "image/jpeg"); err != nil {
+			return fmt.Errorf("failed to store %s variant: %w", variant, err)
+		}
+	}
+
+	now := time.Now()
+	m.FeedKey = &feedKey
+	m.ThumbnailKey = &thumbnailKey
+	m.Width = processed.Width
+	m.Height = processed.Height
+	m.Status = StatusReady
+	m.ProcessedAt = &now
+
+	if err := s.repo.UpdateProcessed(ctx, m); err != nil {
+		return err
+	}
+
+	if err := s.storage.DeleteFile(ctx, m.SourceKey); err != nil {
+		s.logger.Warn("Failed to delete media source", "media_id", m.ID, "error", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,445 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"sync"
+	"testing"
+
+	"fowergram-backend/internal/infra/messaging"
+	"fowergram-backend/internal/infra/storage"
+	"fowergram-backend/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// memoryRepository keeps media rows in memory
+type memoryRepository struct {
+	mu    sync.Mutex
+	media map[uuid.UUID]Media
+}
+
+func newMemoryRepository() *memoryRepository {
+	return &memoryRepository{media: make(map[uuid.UUID]Media)}
+}
+
+func (r *memoryRepository) Create(ctx context.Context, m *Media) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.media[m.ID] = *m
+	return nil
+}
+
+func (r *memoryRepository) GetByID(ctx context.Context, id uuid.UUID) (*Media, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m, ok := r.media[id]
+	if !ok {
+		return nil, ErrMediaNotFound
+	}
+	return &m, nil
+}
+
+func (r *memoryRepository) GetByOriginalKeys(ctx context.Context, userID uuid.UUID, keys []string) ([]*Media, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var found []*Media
+	for _, key := range keys {
+		for _, m := range r.media {
+			if m.UserID == userID && m.OriginalKey == key {
+				m := m
+				found = append(found, &m)
+			}
+		}
+	}
+	return found, nil
+}
+
+func (r *memoryRepository) MarkUploaded(ctx context.Context, id uuid.UUID) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m, ok := r.media[id]
+	if !ok || m.Status != StatusAwaitingUpload {
+		return false, nil
+	}
+	m.Status = StatusPending
+	r.media[id] = m
+	return true, nil
+}
+
+func (r *memoryRepository) UpdateProcessed(ctx context.Context, m *Media) error {
+	return r.Create(ctx, m)
+}
+
+func (r *memoryRepository) MarkFailed(ctx context.Context, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m := r.media[id]
+	m.Status = StatusFailed
+	r.media[id] = m
+	return nil
+}
+
+// testImage encodes a size×size PNG, uncompressed so its size in bytes is
+// predictable
+func testImage(t *testing.T, size int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	for x := 0; x < size; x++ {
+		for y := 0; y < size; y++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 200, A: 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	encoder := png.Encoder{CompressionLevel: png.NoCompression}
+	if err := encoder.Encode(&buf, img); err != nil {
+		t.Fatalf("failed to encode image: %v", err)
+	}
+	return buf.Bytes()
+}
+
+type mediaFixture struct {
+	service   Service
+	repo      *memoryRepository
+	storage   *storage.MemoryStorage
+	messaging *messaging.RecordingClient
+}
+
+func newMediaFixture() *mediaFixture {
+	f := &mediaFixture{
+		repo:      newMemoryRepository(),
+		storage:   storage.NewMemoryStorage(),
+		messaging: messaging.NewRecordingClient(),
+	}
+	f.service = NewService(f.repo, f.storage, f.messaging, logger.NewZapLogger())
+	return f
+}
+
+// objects returns every key stored under the user's prefix
+func (f *mediaFixture) objects(t *testing.T, userID uuid.UUID) []string {
+	t.Helper()
+	keys, err := f.storage.ListFiles(context.Background(), UserKeyPrefix(userID))
+	if err != nil {
+		t.Fatalf("ListFiles: %v", err)
+	}
+	return keys
+}
+
+func TestUpload(t *testing.T) {
+	small := testImage(t, 64)
+	large := testImage(t, 1000) // Over inlineProcessingLimit uncompressed
+
+	tests := []struct {
+		name        string
+		data        []byte
+		contentType string
+		wantErr     error
+		wantStatus  Status
+		wantQueued  bool
+		wantKeys    func(m *Media) []string // Objects left in storage
+	}{
+		{
+			name:        "small upload is processed inline and its source deleted",
+			data:        small,
+			contentType: "image/png",
+			wantStatus:  StatusReady,
+			wantKeys: func(m *Media) []string {
+				return []string{
+					ObjectKey(m.UserID, m.ID, VariantFeed),
+					ObjectKey(m.UserID, m.ID, VariantOriginal),
+					ObjectKey(m.UserID, m.ID, VariantThumbnail),
+				}
+			},
+		},
+		{
+			name:        "large upload is stored and queued",
+			data:        large,
+			contentType: "image/png",
+			wantStatus:  StatusPending,
+			wantQueued:  true,
+			wantKeys: func(m *Media) []string {
+				return []string{SourceKey(m.UserID, m.ID)}
+			},
+		},
+		{
+			name:        "undecodable upload fails and keeps its source",
+			data:        []byte("not an image"),
+			contentType: "image/png",
+			wantErr:     ErrInvalidImage,
+			wantStatus:  StatusFailed,
+		},
+		{
+			name:        "unsupported content type stores nothing",
+			data:        small,
+			contentType: "application/pdf",
+			wantErr:     ErrUnsupportedContentType,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newMediaFixture()
+			userID := uuid.New()
+
+			m, err := f.service.Upload(context.Background(), userID, tt.data, tt.contentType)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Upload() error = %v, want %v", err, tt.wantErr)
+			}
+
+			keys := f.objects(t, userID)
+			if tt.wantStatus == "" {
+				if len(keys) != 0 || len(f.repo.media) != 0 {
+					t.Errorf("stored %v and %d rows, want nothing", keys, len(f.repo.media))
+				}
+				return
+			}
+
+			var stored *Media
+			for _, row := range f.repo.media {
+				stored = &row
+			}
+			if stored == nil || stored.Status != tt.wantStatus {
+				t.Fatalf("stored media = %+v, want status %s", stored, tt.wantStatus)
+			}
+
+			if queued := len(f.messaging.PublishedTo(SubjectMediaUploaded)) == 1; queued != tt.wantQueued {
+				t.Errorf("queued = %v, want %v", queued, tt.wantQueued)
+			}
+
+			if tt.wantErr != nil {
+				if len(keys) != 1 || keys[0] != stored.SourceKey {
+					t.Errorf("objects = %v, want only the source %s", keys, stored.SourceKey)
+				}
+				return
+			}
+			if m.ID != stored.ID {
+				t.Errorf("Upload() returned %s, stored %s", m.ID, stored.ID)
+			}
+			if want := tt.wantKeys(m); !equalKeys(keys, want) {
+				t.Errorf("objects = %v, want %v", keys, want)
+			}
+		})
+	}
+}
+
+func TestUploadProcessedByWorker(t *testing.T) {
+	f := newMediaFixture()
+	worker := NewWorker(f.service, f.messaging, logger.NewZapLogger())
+	if err := worker.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	userID := uuid.New()
+
+	// The recording client hands the event to the worker before returning
+	m, err := f.service.Upload(context.Background(), userID, testImage(t, 1000), "image/png")
+	if err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+
+	stored, _ := f.repo.GetByID(context.Background(), m.ID)
+	if stored.Status != StatusReady || stored.Width != 1000 {
+		t.Errorf("stored media = %+v, want ready and 1000 wide", stored)
+	}
+	if _, err := f.storage.StatFile(context.Background(), m.SourceKey); !errors.Is(err, storage.ErrObjectNotFound) {
+		t.Errorf("source StatFile error = %v, want the source deleted", err)
+	}
+	if err := f.service.ResolveURLs(context.Background(), stored); err != nil {
+		t.Fatalf("ResolveURLs: %v", err)
+	}
+	if got, want := stored.URLs[VariantThumbnail], "memory://"+ObjectKey(userID, m.ID, VariantThumbnail); got != want {
+		t.Errorf("thumbnail URL = %q, want %q", got, want)
+	}
+}
+
+func TestPresignUpload(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		size        int64
+		wantErr     error
+	}{
+		{name: "image", contentType: "image/jpeg", size: 1 << 20},
+		{name: "largest allowed", contentType: "image/png", size: MaxDirectUploadSize},
+		{name: "too large", contentType: "image/png", size: MaxDirectUploadSize + 1, wantErr: ErrFileTooLarge},
+		{name: "empty", contentType: "image/png", wantErr: ErrFileTooLarge},
+		{name: "unsupported type", contentType: "application/pdf", size: 1 << 20, wantErr: ErrUnsupportedContentType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newMediaFixture()
+			userID := uuid.New()
+
+			direct, err := f.service.PresignUpload(context.Background(), userID, tt.contentType, tt.size)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("PresignUpload() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if len(f.repo.media) != 0 {
+					t.Error("reserved a media row for a rejected upload")
+				}
+				return
+			}
+
+			m := direct.Media
+			if m.SourceKey != SourceKey(userID, m.ID) || m.OriginalKey != ObjectKey(userID, m.ID, VariantOriginal) {
+				t.Errorf("keys = %s and %s, want the user's source and original keys", m.SourceKey, m.OriginalKey)
+			}
+			if direct.URL != "memory://"+m.SourceKey {
+				t.Errorf("URL = %s, want one for %s", direct.URL, m.SourceKey)
+			}
+			stored, err := f.repo.GetByID(context.Background(), m.ID)
+			if err != nil || stored.Status != StatusAwaitingUpload || stored.FileSize != tt.size {
+				t.Errorf("stored %+v, %v; want %d bytes awaiting upload", stored, err, tt.size)
+			}
+			if published := f.messaging.PublishedTo(SubjectMediaUploaded); len(published) != 0 {
+				t.Errorf("queued %d events before the upload was confirmed", len(published))
+			}
+		})
+	}
+}
+
+func TestWithoutStorage(t *testing.T) {
+	ctx := context.Background()
+	repo := newMemoryRepository()
+	service := NewService(repo, storage.NoopStorage{}, messaging.NoopClient{}, logger.NewZapLogger())
+	userID := uuid.New()
+
+	if _, err := service.Upload(ctx, userID, testImage(t, 64), "image/png"); !errors.Is(err, ErrStorageUnavailable) {
+		t.Errorf("Upload() error = %v, want %v", err, ErrStorageUnavailable)
+	}
+	if _, err := service.PresignUpload(ctx, userID, "image/png", 1<<20); !errors.Is(err, ErrStorageUnavailable) {
+		t.Errorf("PresignUpload() error = %v, want %v", err, ErrStorageUnavailable)
+	}
+	if len(repo.media) != 0 {
+		t.Errorf("reserved %d media rows without storage", len(repo.media))
+	}
+}
+
+func TestConfirmUploads(t *testing.T) {
+	tests := []struct {
+		name       string
+		upload     bool // Whether the client PUTs the object before confirming
+		wantErr    error
+		wantStatus Status
+		wantQueued int
+	}{
+		{
+			name:       "uploaded object is queued",
+			upload:     true,
+			wantStatus: StatusPending,
+			wantQueued: 1,
+		},
+		{
+			name:       "missing object is incomplete",
+			wantErr:    ErrUploadIncomplete,
+			wantStatus: StatusAwaitingUpload,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newMediaFixture()
+			ctx := context.Background()
+			data := testImage(t, 64)
+
+			direct, err := f.service.PresignUpload(ctx, uuid.New(), "image/png", int64(len(data)))
+			if err != nil {
+				t.Fatalf("PresignUpload: %v", err)
+			}
+			if tt.upload {
+				f.storage.UploadFile(ctx, direct.Media.SourceKey, data, "image/png")
+			}
+
+			err = f.service.ConfirmUploads(ctx, []*Media{direct.Media})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ConfirmUploads() error = %v, want %v", err, tt.wantErr)
+			}
+
+			stored, _ := f.repo.GetByID(ctx, direct.Media.ID)
+			if stored.Status != tt.wantStatus {
+				t.Errorf("status = %s, want %s", stored.Status, tt.wantStatus)
+			}
+
+			published := f.messaging.PublishedTo(SubjectMediaUploaded)
+			if len(published) != tt.wantQueued {
+				t.Fatalf("queued %d events, want %d", len(published), tt.wantQueued)
+			}
+			for _, msg := range published {
+				var event UploadedEvent
+				if err := json.Unmarshal(msg.Data, &event); err != nil || event.MediaID != direct.Media.ID {
+					t.Errorf("event = %s, want media %s", msg.Data, direct.Media.ID)
+				}
+			}
+		})
+	}
+}
+
+func TestPostMediaKeys(t *testing.T) {
+	alice, bob := uuid.New(), uuid.New()
+	uploadedID, missingID := uuid.New(), uuid.New()
+
+	tests := []struct {
+		name       string
+		key        string
+		wantErr    error // From GetUserMedia
+		wantStored error // From ConfirmUploads on what GetUserMedia found
+	}{
+		{name: "own upload", key: ObjectKey(alice, uploadedID, VariantOriginal)},
+		{name: "own upload missing from storage", key: ObjectKey(alice, missingID, VariantOriginal), wantStored: ErrUploadIncomplete},
+		{name: "own prefix without an upload", key: ObjectKey(alice, uuid.New(), VariantOriginal), wantErr: ErrMediaNotFound},
+		{name: "another user's upload", key: ObjectKey(bob, uploadedID, VariantOriginal), wantErr: ErrInvalidMediaKey},
+		{name: "escaping the prefix", key: UserKeyPrefix(alice) + "../" + bob.String() + "/a.jpg", wantErr: ErrInvalidMediaKey},
+		{name: "external URL", key: "https://example.com/" + UserKeyPrefix(alice) + "a.jpg", wantErr: ErrInvalidMediaKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newMediaFixture()
+			ctx := context.Background()
+
+			// Both of alice's uploads are processed, but only one of the
+			// originals is still in storage
+			for _, id := range []uuid.UUID{uploadedID, missingID} {
+				f.repo.Create(ctx, &Media{
+					ID:          id,
+					UserID:      alice,
+					SourceKey:   SourceKey(alice, id),
+					OriginalKey: ObjectKey(alice, id, VariantOriginal),
+					Status:      StatusReady,
+				})
+			}
+			f.storage.UploadFile(ctx, ObjectKey(alice, uploadedID, VariantOriginal), testImage(t, 8), "image/jpeg")
+
+			items, err := f.service.GetUserMedia(ctx, alice, []string{tt.key})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetUserMedia() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if err := f.service.ConfirmUploads(ctx, items); !errors.Is(err, tt.wantStored) {
+				t.Errorf("ConfirmUploads() error = %v, want %v", err, tt.wantStored)
+			}
+		})
+	}
+}
+
+// equalKeys reports whether two sorted key lists match
+func equalKeys(got, want []string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
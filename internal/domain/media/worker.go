@@ -0,0 +1,66 @@
+package media
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"fowergram-backend/internal/infra/messaging"
+	"fowergram-backend/pkg/logger"
+)
+
+// processingTimeout bounds the work done for a single queued upload
+const processingTimeout = 2 * time.Minute
+
+// Worker consumes media.uploaded events and generates variants in the background
+type Worker struct {
+	service   Service
+	messaging messaging.Client
+	logger    logger.Logger
+	sub       *messaging.Subscription
+}
+
+// NewWorker creates a new media processing worker
+func NewWorker(service Service, messaging messaging.Client, logger logger.Logger) *Worker {
+	return &Worker{
+		service:   service,
+		messaging: messaging,
+		logger:    logger,
+	}
+}
+
+// Start subscribes the worker to upload events until it is stopped or the
+// messaging client is drained or closed
+func (w *Worker) Start() error {
+	sub, err := w.messaging.Subscribe(SubjectMediaUploaded, w.handle)
+	if err != nil {
+		return err
+	}
+	w.sub = sub
+	return nil
+}
+
+// Stop stops the worker taking new uploads and waits for the ones it holds
+// to be processed
+func (w *Worker) Stop(ctx context.Context) error {
+	if w.sub == nil {
+		return nil
+	}
+	return w.sub.Drain(ctx)
+}
+
+// handle processes a single upload event
+func (w *Worker) handle(ctx context.Context, data []byte) {
+	var event UploadedEvent
+	if err := json.Unmarshal(data, &event); err != nil {
+		w.logger.Error("Failed to decode media upload event", "error", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, processingTimeout)
+	defer cancel()
+
+	if err := w.service.Process(ctx, event.MediaID); err != nil {
+		w.logger.Error("Failed to process media", "media_id", event.MediaID, "error", err)
+	}
+}
@@ -0,0 +1,95 @@
+package moderation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// HTTPModerator delegates to an external moderation API. It POSTs
+// {"text": "..."} and expects {"verdict": "allow"|"flag"|"reject", "reason": "..."}.
+type HTTPModerator struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPModerator creates a moderator calling the API at url
+func NewHTTPModerator(url string, timeout time.Duration) *HTTPModerator {
+	return &HTTPModerator{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+type moderationRequest struct {
+	Text string `json:"text"`
+}
+
+type moderationResponse struct {
+	Verdict Verdict `json:"verdict"`
+	Reason  string  `json:"reason"`
+}
+
+// Moderate asks the API for a verdict on text
+func (m *HTTPModerator) Moderate(ctx context.Context, text string) (*Result, error) {
+	body, err := json.Marshal(moderationRequest{Text: text})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode moderation request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build moderation request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call moderation API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("moderation API returned status %d", resp.StatusCode)
+	}
+
+	var decoded moderationResponse
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return nil, fmt.Errorf("failed to decode moderation response: %w", err)
+	}
+
+	switch decoded.Verdict {
+	case VerdictAllow, VerdictFlag, VerdictReject:
+		return &Result{Verdict: decoded.Verdict, Reason: decoded.Reason}, nil
+	default:
+		return nil, fmt.Errorf("moderation API returned unknown verdict %q", decoded.Verdict)
+	}
+}
+
+// Chain combines moderators into one that runs them in order. The first
+// rejection wins; otherwise the first flag does. Errors stop the chain.
+func Chain(moderators ...Moderator) Moderator {
+	return chain(moderators)
+}
+
+type chain []Moderator
+
+func (c chain) Moderate(ctx context.Context, text string) (*Result, error) {
+	result := &Result{Verdict: VerdictAllow}
+	for _, m := range c {
+		r, err := m.Moderate(ctx, text)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case r.Verdict == VerdictReject:
+			return r, nil
+		case r.Verdict == VerdictFlag && !result.Flagged():
+			result = r
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,76 @@
+package moderation
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Verdict is a moderator's decision on a piece of text
+type Verdict string
+
+const (
+	VerdictAllow  Verdict = "allow"
+	VerdictFlag   Verdict = "flag"   // Saved, but queued for review
+	VerdictReject Verdict = "reject" // Not saved
+)
+
+// Content types held in the moderation queue
+const (
+	ContentPost    = "post"
+	ContentComment = "comment"
+)
+
+// Moderation queue statuses
+const (
+	StatusPending  = "pending"
+	StatusApproved = "approved"
+	StatusRemoved  = "removed"
+)
+
+// ErrContentRejected is returned when a moderator rejects content
+var ErrContentRejected = errors.New("content violates the community guidelines")
+
+// Result is a moderator's decision along with why it was reached
+type Result struct {
+	Verdict Verdict
+	Reason  string
+}
+
+// Flagged reports whether the content should be held for review
+func (r *Result) Flagged() bool {
+	return r.Verdict == VerdictFlag
+}
+
+// Moderator decides whether text may be published
+type Moderator interface {
+	Moderate(ctx context.Context, text string) (*Result, error)
+}
+
+// QueueItem is flagged content awaiting review
+type QueueItem struct {
+	ID          uuid.UUID  `json:"id" db:"id"`
+	ContentType string     `json:"content_type" db:"content_type"`
+	ContentID   uuid.UUID  `json:"content_id" db:"content_id"`
+	AuthorID    uuid.UUID  `json:"author_id" db:"author_id"`
+	Reason      string     `json:"reason" db:"reason"`
+	Status      string     `json:"status" db:"status"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	ReviewedAt  *time.Time `json:"reviewed_at,omitempty" db:"reviewed_at"`
+}
+
+// Repository defines the interface for moderation queue persistence
+type Repository interface {
+	Enqueue(ctx context.Context, item *QueueItem) error
+}
+
+// Service defines the interface for moderation business logic
+type Service interface {
+	// Screen checks text before it is saved. Rejected text returns
+	// ErrContentRejected; a flagged result should be passed to Hold once
+	// the content has an ID.
+	Screen(ctx context.Context, text string) (*Result, error)
+	Hold(ctx context.Context, contentType string, contentID, authorID uuid.UUID, result *Result) error
+}
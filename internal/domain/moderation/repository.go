@@ -0,0 +1,37 @@
+package moderation
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// postgresRepository implements Repository using PostgreSQL
+type postgresRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewRepository creates a new PostgreSQL moderation repository
+func NewRepository(db *pgxpool.Pool) Repository {
+	return &postgresRepository{db: db}
+}
+
+// Enqueue adds flagged content to the review queue. Content already pending
+// review is left as is.
+func (r *postgresRepository) Enqueue(ctx context.Context, item *QueueItem) error {
+	query := `
+		INSERT INTO moderation_queue (id, content_type, content_id, author_id, reason, status, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (content_type, content_id) WHERE status = 'pending' DO NOTHING
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		item.ID, item.ContentType, item.ContentID, item.AuthorID, item.Reason, item.Status, item.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue content for moderation: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package moderation
+
+import (
+	"context"
+	"time"
+
+	"fowergram-backend/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// service implements Service
+type service struct {
+	repo      Repository
+	moderator Moderator
+	logger    logger.Logger
+}
+
+// NewService creates a new moderation service
+func NewService(repo Repository, moderator Moderator, logger logger.Logger) Service {
+	return &service{
+		repo:      repo,
+		moderator: moderator,
+		logger:    logger,
+	}
+}
+
+// Screen runs text through the moderator. When the moderator fails, the
+// content is let through but flagged so a person still looks at it.
+func (s *service) Screen(ctx context.Context, text string) (*Result, error) {
+	result, err := s.moderator.Moderate(ctx, text)
+	if err != nil {
+		s.logger.Error("Moderation check failed, flagging content for review", "error", err)
+		return &Result{Verdict: VerdictFlag, Reason: "automated moderation unavailable"}, nil
+	}
+
+	if result.Verdict == VerdictReject {
+		return result, ErrContentRejected
+	}
+	return result, nil
+}
+
+// Hold queues flagged content for review; results that aren't flags are ignored
+func (s *service) Hold(ctx context.Context, contentType string, contentID, authorID uuid.UUID, result *Result) error {
+	if result == nil || !result.Flagged() {
+		return nil
+	}
+
+	return s.repo.Enqueue(ctx, &QueueItem{
+		ID:          uuid.New(),
+		ContentType: contentType,
+		ContentID:   contentID,
+		AuthorID:    authorID,
+		Reason:      result.Reason,
+		Status:      StatusPending,
+		CreatedAt:   time.Now(),
+	})
+}
@@ -0,0 +1,82 @@
+package moderation
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"fowergram-backend/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// fakeRepository records the items queued for review
+type fakeRepository struct {
+	Repository
+
+	queued []*QueueItem
+}
+
+func (r *fakeRepository) Enqueue(ctx context.Context, item *QueueItem) error {
+	r.queued = append(r.queued, item)
+	return nil
+}
+
+// moderatorFunc adapts a function to Moderator
+type moderatorFunc func(ctx context.Context, text string) (*Result, error)
+
+func (f moderatorFunc) Moderate(ctx context.Context, text string) (*Result, error) {
+	return f(ctx, text)
+}
+
+func TestScreenAndHold(t *testing.T) {
+	ctx := context.Background()
+	wordlist := NewWordlistModerator([]string{"scam"}, []string{"crypto"})
+	unavailable := moderatorFunc(func(context.Context, string) (*Result, error) {
+		return nil, errors.New("moderation API down")
+	})
+
+	tests := []struct {
+		name       string
+		moderator  Moderator
+		text       string
+		wantErr    error
+		wantQueued bool
+	}{
+		{name: "allowed", moderator: wordlist, text: "hello"},
+		{name: "rejected", moderator: wordlist, text: "a scam", wantErr: ErrContentRejected},
+		{name: "flagged is held", moderator: wordlist, text: "crypto tips", wantQueued: true},
+		{name: "moderator failure is held", moderator: unavailable, text: "hello", wantQueued: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeRepository{}
+			service := NewService(repo, tt.moderator, logger.NewZapLogger())
+
+			result, err := service.Screen(ctx, tt.text)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Screen() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			contentID, authorID := uuid.New(), uuid.New()
+			if err := service.Hold(ctx, ContentPost, contentID, authorID, result); err != nil {
+				t.Fatalf("Hold: %v", err)
+			}
+			if got := len(repo.queued) == 1; got != tt.wantQueued {
+				t.Fatalf("queued %d items, want queued %v", len(repo.queued), tt.wantQueued)
+			}
+			if !tt.wantQueued {
+				return
+			}
+			item := repo.queued[0]
+			if item.ContentType != ContentPost || item.ContentID != contentID || item.AuthorID != authorID ||
+				item.Status != StatusPending || item.Reason == "" {
+				t.Errorf("queued %+v, want a pending post with a reason", item)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package moderation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// WordlistModerator rejects text containing a banned word and flags text
+// containing a flagged word. Words match case-insensitively and whole, so
+// "class" doesn't match a banned "ass".
+type WordlistModerator struct {
+	banned  map[string]struct{}
+	flagged map[string]struct{}
+}
+
+// NewWordlistModerator creates a moderator from banned and flagged word lists
+func NewWordlistModerator(banned, flagged []string) *WordlistModerator {
+	return &WordlistModerator{
+		banned:  wordSet(banned),
+		flagged: wordSet(flagged),
+	}
+}
+
+// Moderate checks every word in text against the lists
+func (m *WordlistModerator) Moderate(ctx context.Context, text string) (*Result, error) {
+	var flaggedWord string
+	for _, word := range words(text) {
+		if _, ok := m.banned[word]; ok {
+			return &Result{Verdict: VerdictReject, Reason: fmt.Sprintf("contains banned word %q", word)}, nil
+		}
+		if _, ok := m.flagged[word]; ok && flaggedWord == "" {
+			flaggedWord = word
+		}
+	}
+
+	if flaggedWord != "" {
+		return &Result{Verdict: VerdictFlag, Reason: fmt.Sprintf("contains flagged word %q", flaggedWord)}, nil
+	}
+	return &Result{Verdict: VerdictAllow}, nil
+}
+
+func wordSet(list []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(list))
+	for _, word := range list {
+		if word = strings.ToLower(strings.TrimSpace(word)); word != "" {
+			set[word] = struct{}{}
+		}
+	}
+	return set
+}
+
+// words splits text into lowercase words of letters and digits
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
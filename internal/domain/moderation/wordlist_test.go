@@ -0,0 +1,40 @@
+package moderation
+
+import (
+	"context"
+	"testing"
+)
+
+func TestWordlistModerator(t *testing.T) {
+	moderator := NewWordlistModerator([]string{"Scam", " spam "}, []string{"crypto", "giveaway"})
+
+	tests := []struct {
+		name string
+		text string
+		want Verdict
+	}{
+		{name: "clean text", text: "Sunset over the bay tonight", want: VerdictAllow},
+		{name: "empty text", text: "", want: VerdictAllow},
+		{name: "banned word", text: "This is a scam, don't buy", want: VerdictReject},
+		{name: "banned word in another case", text: "SPAM everywhere", want: VerdictReject},
+		{name: "banned word between punctuation", text: "total...scam!!!", want: VerdictReject},
+		{name: "banned word inside another word", text: "Scampi for dinner", want: VerdictAllow},
+		{name: "flagged word", text: "Join my crypto giveaway", want: VerdictFlag},
+		{name: "banned outweighs flagged", text: "crypto giveaway scam", want: VerdictReject},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := moderator.Moderate(context.Background(), tt.text)
+			if err != nil {
+				t.Fatalf("Moderate: %v", err)
+			}
+			if result.Verdict != tt.want {
+				t.Errorf("verdict = %s (%s), want %s", result.Verdict, result.Reason, tt.want)
+			}
+			if tt.want != VerdictAllow && result.Reason == "" {
+				t.Errorf("verdict %s without a reason", result.Verdict)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package notification
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Notification types. Like and follow notifications are stored at most once
+// per recipient, actor and entity, so liking a post again or re-following
+// doesn't notify twice.
+const (
+	TypeFollow  = "follow"
+	TypeLike    = "like"
+	TypeComment = "comment"
+	TypeMention = "mention"
+)
+
+// Entity types a notification can point at
+const (
+	EntityUser = "user"
+	EntityPost = "post"
+)
+
+// Notification is a persistent notice to a user about another user's action
+type Notification struct {
+	ID         uuid.UUID  `json:"id" db:"id"`
+	UserID     uuid.UUID  `json:"user_id" db:"user_id"` // Recipient
+	ActorID    *uuid.UUID `json:"actor_id,omitempty" db:"actor_id"`
+	Type       string     `json:"type" db:"type"`
+	EntityType string     `json:"entity_type,omitempty" db:"entity_type"`
+	EntityID   *uuid.UUID `json:"entity_id,omitempty" db:"entity_id"`
+	Message    string     `json:"message,omitempty" db:"message"`
+	IsRead     bool       `json:"is_read" db:"is_read"`
+	ReadAt     *time.Time `json:"read_at,omitempty" db:"read_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+
+	// Actor profile, populated when listing
+	ActorUsername       string `json:"actor_username,omitempty" db:"username"`
+	ActorProfilePicture string `json:"actor_profile_picture,omitempty" db:"profile_picture"`
+}
+
+// Repository defines the interface for notification persistence
+type Repository interface {
+	// Create stores n unless its actor is blocked or muted by the recipient,
+	// or it duplicates a collapsed notification already stored. It reports
+	// whether n was stored.
+	Create(ctx context.Context, n *Notification) (bool, error)
+	List(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*Notification, error)
+	CountUnread(ctx context.Context, userID uuid.UUID) (int, error)
+	MarkRead(ctx context.Context, userID uuid.UUID, ids []uuid.UUID) error
+}
+
+// Service defines the interface for notification business logic
+type Service interface {
+	Notify(ctx context.Context, n *Notification) error
+	List(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*Notification, int, error)
+	MarkRead(ctx context.Context, userID uuid.UUID, ids []uuid.UUID) (int, error)
+}
@@ -0,0 +1,74 @@
+package notification
+
+import (
+	"context"
+
+	"fowergram-backend/internal/events"
+
+	"github.com/google/uuid"
+)
+
+// push asks the push worker to deliver n to the recipient's registered
+// devices. Failures are logged; the notification is stored either way.
+func (s *service) push(ctx context.Context, n *Notification) {
+	devices, err := s.devices.ListByUser(ctx, n.UserID)
+	if err != nil {
+		s.logger.Error("Failed to list devices for push", "user_id", n.UserID, "error", err)
+		return
+	}
+	if len(devices) == 0 {
+		return
+	}
+
+	actor := "Someone"
+	if n.ActorID != nil {
+		if u, err := s.users.GetUserByID(ctx, *n.ActorID); err == nil {
+			actor = "@" + u.Username
+		}
+	}
+	title, body := render(n, actor)
+
+	event := events.PushSend{
+		UserID:         n.UserID,
+		NotificationID: n.ID,
+		Title:          title,
+		Body:           body,
+		Data: map[string]string{
+			"notification_id": n.ID.String(),
+			"type":            n.Type,
+		},
+	}
+	if n.EntityID != nil {
+		event.Data["entity_type"] = n.EntityType
+		event.Data["entity_id"] = n.EntityID.String()
+	}
+	for _, d := range devices {
+		event.Devices = append(event.Devices, events.PushDevice{Token: d.Token, Platform: d.Platform})
+	}
+
+	var actorID uuid.UUID
+	if n.ActorID != nil {
+		actorID = *n.ActorID
+	}
+	if err := s.publisher.Publish(ctx, actorID, event); err != nil {
+		s.logger.Error("Failed to publish push event", "notification_id", n.ID, "error", err)
+	}
+}
+
+// render returns the title and body of the push sent for n, naming the actor
+func render(n *Notification, actor string) (title, body string) {
+	switch n.Type {
+	case TypeFollow:
+		return "New follower", actor + " started following you"
+	case TypeLike:
+		return "New like", actor + " liked your post"
+	case TypeComment:
+		return "New comment", actor + " commented on your post"
+	case TypeMention:
+		return "New mention", actor + " mentioned you"
+	}
+	if n.Message != "" {
+		return "Fowergram", n.Message
+	}
+	return "Fowergram", "You have a new notification"
+}
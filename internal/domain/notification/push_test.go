@@ -0,0 +1,138 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"fowergram-backend/internal/domain/device"
+	"fowergram-backend/internal/domain/user"
+	"fowergram-backend/internal/events"
+	"fowergram-backend/internal/infra/messaging"
+	"fowergram-backend/pkg/auth"
+	"fowergram-backend/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// fakeUserRepository names the actors of pushes. Other methods are left to
+// the embedded nil Repository.
+type fakeUserRepository struct {
+	user.Repository
+	users map[uuid.UUID]*auth.User
+}
+
+func (r *fakeUserRepository) GetUserByID(ctx context.Context, id uuid.UUID) (*auth.User, error) {
+	u, ok := r.users[id]
+	if !ok {
+		return nil, auth.ErrUserNotFound
+	}
+	return u, nil
+}
+
+// fakeSender records the tokens pushed to and fails for the tokens in errs
+type fakeSender struct {
+	mu   sync.Mutex
+	sent []string
+	errs map[string]error
+}
+
+func (s *fakeSender) Send(ctx context.Context, token, platform string, msg device.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, token)
+	return s.errs[token]
+}
+
+func TestPushNotifications(t *testing.T) {
+	ctx := context.Background()
+	log := logger.NewZapLogger()
+	client := messaging.NewRecordingClient()
+	publisher := events.NewNATSPublisher(client, log)
+
+	alice, bob, carol := uuid.New(), uuid.New(), uuid.New()
+	users := &fakeUserRepository{users: map[uuid.UUID]*auth.User{alice: {ID: alice, Username: "alice"}}}
+	devices := &fakeDeviceRepository{devices: []*device.Device{
+		{UserID: bob, Token: "bob-phone", Platform: device.PlatformIOS},
+		{UserID: bob, Token: "bob-old-tablet", Platform: device.PlatformAndroid},
+		{UserID: bob, Token: "bob-browser", Platform: device.PlatformWeb},
+		{UserID: alice, Token: "alice-phone", Platform: device.PlatformIOS},
+	}}
+	service := NewService(&fakeRepository{}, devices, users, publisher, log)
+
+	// The provider reports the tablet's token expired, and fails the browser
+	sender := &fakeSender{errs: map[string]error{
+		"bob-old-tablet": device.ErrTokenExpired,
+		"bob-browser":    errors.New("provider unavailable"),
+	}}
+	worker := device.NewWorker(devices, sender, client, log)
+	if err := worker.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	postID := uuid.New()
+	notify := func(userID uuid.UUID, actorID uuid.UUID) {
+		t.Helper()
+		n := &Notification{UserID: userID, ActorID: &actorID, Type: TypeLike, EntityType: EntityPost, EntityID: &postID, CreatedAt: time.Now()}
+		if err := service.Notify(ctx, n); err != nil {
+			t.Fatalf("Notify: %v", err)
+		}
+	}
+
+	// A like from alice pushes to each of bob's devices, naming her
+	notify(bob, alice)
+	pushes := client.PublishedTo(string(events.TypePushSend))
+	if len(pushes) != 1 {
+		t.Fatalf("published %d push.send events, want 1", len(pushes))
+	}
+	envelope, err := events.Decode(pushes[0].Data)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	var push events.PushSend
+	if err := envelope.DecodePayload(&push); err != nil {
+		t.Fatalf("DecodePayload: %v", err)
+	}
+	var tokens []string
+	for _, d := range push.Devices {
+		tokens = append(tokens, d.Token)
+	}
+	if want := []string{"bob-phone", "bob-old-tablet", "bob-browser"}; push.UserID != bob || !slices.Equal(tokens, want) {
+		t.Errorf("pushed to %s's devices %v, want bob's %v", push.UserID, tokens, want)
+	}
+	if push.Title != "New like" || push.Body != "@alice liked your post" {
+		t.Errorf("push = %q: %q, want the like by @alice", push.Title, push.Body)
+	}
+	wantData := map[string]string{"type": TypeLike, "entity_type": EntityPost, "entity_id": postID.String()}
+	for key, value := range wantData {
+		if push.Data[key] != value {
+			t.Errorf("push data %s = %q, want %q", key, push.Data[key], value)
+		}
+	}
+	if push.Data["notification_id"] != push.NotificationID.String() || push.NotificationID == uuid.Nil {
+		t.Errorf("push data = %v, want the notification's ID %s", push.Data, push.NotificationID)
+	}
+
+	// The worker sent to every device, and pruned only the expired token
+	if !slices.Equal(sender.sent, tokens) {
+		t.Errorf("sent to %v, want %v", sender.sent, tokens)
+	}
+	registered := make(map[string]bool)
+	for _, d := range devices.devices {
+		registered[d.Token] = true
+	}
+	if want := []string{"alice-phone", "bob-browser", "bob-phone"}; !slices.Equal(slices.Sorted(maps.Keys(registered)), want) {
+		t.Errorf("devices left %v, want %v", slices.Sorted(maps.Keys(registered)), want)
+	}
+
+	// Users without devices, and users acting on their own posts, get no push
+	notify(carol, alice)
+	notify(alice, alice)
+	if pushes := client.PublishedTo(string(events.TypePushSend)); len(pushes) != 1 {
+		t.Errorf("published %d push.send events, want only bob's", len(pushes))
+	}
+}
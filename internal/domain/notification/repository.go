@@ -0,0 +1,132 @@
+package notification
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// postgresRepository implements Repository using PostgreSQL
+type postgresRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewRepository creates a new PostgreSQL notification repository
+func NewRepository(db *pgxpool.Pool) Repository {
+	return &postgresRepository{db: db}
+}
+
+// Create inserts a notification. Nothing is stored when a block stands
+// between the actor and the recipient in either direction, when the
+// recipient muted the actor, or when a collapsed type was already notified.
+func (r *postgresRepository) Create(ctx context.Context, n *Notification) (bool, error) {
+	query := `
+		INSERT INTO notifications (id, user_id, actor_id, type, entity_type, entity_id, message, is_read, created_at)
+		SELECT $1, $2, $3, $4, NULLIF($5, ''), $6, NULLIF($7, ''), $8, $9
+		WHERE $3::uuid IS NULL OR NOT EXISTS (
+			SELECT 1 FROM blocks b
+			WHERE (b.blocker_id = $2 AND b.blocked_id = $3)
+				OR (b.blocker_id = $3 AND b.blocked_id = $2)
+			UNION ALL
+			SELECT 1 FROM mutes m
+			WHERE m.muter_id = $2 AND m.muted_id = $3
+		)
+		ON CONFLICT (user_id, actor_id, type, entity_id) WHERE type IN ('like', 'follow') DO NOTHING
+	`
+
+	tag, err := r.db.Exec(ctx, query,
+		n.ID, n.UserID, n.ActorID, n.Type, n.EntityType, n.EntityID, n.Message, n.IsRead, n.CreatedAt,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to create notification: %w", err)
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
+// List retrieves the user's notifications, newest first, with the actor's
+// profile. Notifications from deactivated actors are left out.
+func (r *postgresRepository) List(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*Notification, error) {
+	query := `
+		SELECT n.id, n.user_id, n.actor_id, n.type, COALESCE(n.entity_type, ''), n.entity_id,
+			   COALESCE(n.message, ''), n.is_read, n.read_at, n.created_at,
+			   COALESCE(u.username, ''), COALESCE(u.profile_picture, '')
+		FROM notifications n
+		LEFT JOIN users u ON u.id = n.actor_id
+		WHERE n.user_id = $1 AND (n.actor_id IS NULL OR u.is_active = true)
+		ORDER BY n.created_at DESC, n.id DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	rows, err := r.db.Query(ctx, query, userID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list notifications: %w", err)
+	}
+	defer rows.Close()
+
+	var notifications []*Notification
+	for rows.Next() {
+		n := &Notification{}
+		err := rows.Scan(
+			&n.ID,
+			&n.UserID,
+			&n.ActorID,
+			&n.Type,
+			&n.EntityType,
+			&n.EntityID,
+			&n.Message,
+			&n.IsRead,
+			&n.ReadAt,
+			&n.CreatedAt,
+			&n.ActorUsername,
+			&n.ActorProfilePicture,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan notification: %w", err)
+		}
+		notifications = append(notifications, n)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate notifications: %w", err)
+	}
+
+	return notifications, nil
+}
+
+// CountUnread counts the user's unread notifications
+func (r *postgresRepository) CountUnread(ctx context.Context, userID uuid.UUID) (int, error) {
+	query := `
+		SELECT COUNT(*) FROM notifications
+		WHERE user_id = $1 AND is_read = false
+	`
+
+	var count int
+	if err := r.db.QueryRow(ctx, query, userID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count unread notifications: %w", err)
+	}
+
+	return count, nil
+}
+
+// MarkRead marks the given notifications of the user as read, or all of
+// them when ids is empty. IDs that aren't the user's are ignored.
+func (r *postgresRepository) MarkRead(ctx context.Context, userID uuid.UUID, ids []uuid.UUID) error {
+	query := `
+		UPDATE notifications SET is_read = true, read_at = NOW()
+		WHERE user_id = $1 AND is_read = false
+	`
+	args := []interface{}{userID}
+	if len(ids) > 0 {
+		query += ` AND id = ANY($2)`
+		args = append(args, ids)
+	}
+
+	if _, err := r.db.Exec(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to mark notifications read: %w", err)
+	}
+
+	return nil
+}
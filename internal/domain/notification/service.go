@@ -0,0 +1,102 @@
+package notification
+
+import (
+	"context"
+	"time"
+
+	"fowergram-backend/internal/domain/device"
+	"fowergram-backend/internal/domain/user"
+	"fowergram-backend/internal/events"
+	"fowergram-backend/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// service implements Service
+type service struct {
+	repo      Repository
+	devices   device.Repository
+	users     user.Repository
+	publisher events.Publisher
+	logger    logger.Logger
+}
+
+// NewService creates a new notification service
+func NewService(repo Repository, devices device.Repository, users user.Repository, publisher events.Publisher, logger logger.Logger) Service {
+	return &service{
+		repo:      repo,
+		devices:   devices,
+		users:     users,
+		publisher: publisher,
+		logger:    logger,
+	}
+}
+
+// Notify stores a notification for n.UserID, announces it with a
+// notification.created event and asks for a push to the recipient's
+// devices. Users aren't notified about their own actions.
+func (s *service) Notify(ctx context.Context, n *Notification) error {
+	if n.ActorID != nil && *n.ActorID == n.UserID {
+		return nil
+	}
+
+	if n.ID == uuid.Nil {
+		n.ID = uuid.New()
+	}
+	if n.CreatedAt.IsZero() {
+		n.CreatedAt = time.Now()
+	}
+
+	created, err := s.repo.Create(ctx, n)
+	if err != nil || !created {
+		return err
+	}
+
+	// The notification is stored either way; clients that miss the event see
+	// it on their next listing
+	var actorID uuid.UUID
+	if n.ActorID != nil {
+		actorID = *n.ActorID
+	}
+	err = s.publisher.Publish(ctx, actorID, events.NotificationCreated{
+		NotificationID: n.ID,
+		UserID:         n.UserID,
+		ActorID:        n.ActorID,
+		Type:           n.Type,
+		EntityType:     n.EntityType,
+		EntityID:       n.EntityID,
+		CreatedAt:      n.CreatedAt,
+	})
+	if err != nil {
+		s.logger.Error("Failed to publish notification created event", "notification_id", n.ID, "error", err)
+	}
+
+	s.push(ctx, n)
+
+	return nil
+}
+
+// List returns a page of the user's notifications and their unread count
+func (s *service) List(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*Notification, int, error) {
+	notifications, err := s.repo.List(ctx, userID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	unread, err := s.repo.CountUnread(ctx, userID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return notifications, unread, nil
+}
+
+// MarkRead marks notifications read, all of them when ids is empty, and
+// returns the remaining unread count
+func (s *service) MarkRead(ctx context.Context, userID uuid.UUID, ids []uuid.UUID) (int, error) {
+	if err := s.repo.MarkRead(ctx, userID, ids); err != nil {
+		return 0, err
+	}
+
+	return s.repo.CountUnread(ctx, userID)
+}
@@ -0,0 +1,128 @@
+package notification
+
+import (
+	"context"
+	"time"
+
+	"fowergram-backend/internal/events"
+	"fowergram-backend/internal/infra/messaging"
+	"fowergram-backend/pkg/logger"
+)
+
+// handleTimeout bounds the work done for a single event
+const handleTimeout = 10 * time.Second
+
+// notificationQueue is the durable subscription shared by every worker, so
+// each event produces one notification however many instances run
+const notificationQueue = "notifications"
+
+// Worker turns follow, like, comment and mention events into stored notifications
+type Worker struct {
+	service   Service
+	messaging messaging.Client
+	logger    logger.Logger
+	subs      []*messaging.Subscription
+}
+
+// NewWorker creates a new notification worker
+func NewWorker(service Service, messaging messaging.Client, logger logger.Logger) *Worker {
+	return &Worker{
+		service:   service,
+		messaging: messaging,
+		logger:    logger,
+	}
+}
+
+// Start subscribes the worker to the events that produce notifications
+func (w *Worker) Start() error {
+	dispatcher := events.NewDispatcher(handleTimeout, w.logger)
+	dispatcher.Handle(events.TypeUserFollowed, w.notify(fromFollowEvent))
+	dispatcher.Handle(events.TypePostLiked, w.notify(fromLikedEvent))
+	dispatcher.Handle(events.TypePostCommented, w.notify(fromCommentedEvent))
+	dispatcher.Handle(events.TypeUserMentioned, w.notify(fromMentionedEvent))
+
+	subs, err := dispatcher.Subscribe(w.messaging, notificationQueue)
+	w.subs = subs
+	return err
+}
+
+// Stop stops the worker taking new events and waits for the ones it holds to
+// be handled
+func (w *Worker) Stop(ctx context.Context) error {
+	return messaging.DrainAll(ctx, w.subs)
+}
+
+// notify returns a handler that builds an event's notification and stores it
+func (w *Worker) notify(build func(*events.Envelope) (*Notification, error)) events.Handler {
+	return func(ctx context.Context, e *events.Envelope) error {
+		n, err := build(e)
+		if err != nil {
+			return err
+		}
+		return w.service.Notify(ctx, n)
+	}
+}
+
+func fromFollowEvent(e *events.Envelope) (*Notification, error) {
+	var event events.UserFollowed
+	if err := e.DecodePayload(&event); err != nil {
+		return nil, err
+	}
+
+	return &Notification{
+		UserID:     event.FollowingID,
+		ActorID:    &event.FollowerID,
+		Type:       TypeFollow,
+		EntityType: EntityUser,
+		EntityID:   &event.FollowerID,
+		CreatedAt:  event.CreatedAt,
+	}, nil
+}
+
+func fromLikedEvent(e *events.Envelope) (*Notification, error) {
+	var event events.PostLiked
+	if err := e.DecodePayload(&event); err != nil {
+		return nil, err
+	}
+
+	return &Notification{
+		UserID:     event.AuthorID,
+		ActorID:    &event.UserID,
+		Type:       TypeLike,
+		EntityType: EntityPost,
+		EntityID:   &event.PostID,
+		CreatedAt:  event.LikedAt,
+	}, nil
+}
+
+func fromCommentedEvent(e *events.Envelope) (*Notification, error) {
+	var event events.PostCommented
+	if err := e.DecodePayload(&event); err != nil {
+		return nil, err
+	}
+
+	return &Notification{
+		UserID:     event.AuthorID,
+		ActorID:    &event.UserID,
+		Type:       TypeComment,
+		EntityType: EntityPost,
+		EntityID:   &event.PostID,
+		CreatedAt:  event.CreatedAt,
+	}, nil
+}
+
+func fromMentionedEvent(e *events.Envelope) (*Notification, error) {
+	var event events.UserMentioned
+	if err := e.DecodePayload(&event); err != nil {
+		return nil, err
+	}
+
+	return &Notification{
+		UserID:     event.UserID,
+		ActorID:    &e.ActorID,
+		Type:       TypeMention,
+		EntityType: EntityPost,
+		EntityID:   &event.PostID,
+		CreatedAt:  event.CreatedAt,
+	}, nil
+}
@@ -0,0 +1,172 @@
+package notification
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"fowergram-backend/internal/domain/device"
+	"fowergram-backend/internal/events"
+	"fowergram-backend/internal/infra/messaging"
+	"fowergram-backend/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// fakeRepository keeps notifications in memory, collapsing likes and follows
+// as the notifications table's unique index does
+type fakeRepository struct {
+	mu            sync.Mutex
+	notifications []*Notification
+}
+
+func (r *fakeRepository) Create(ctx context.Context, n *Notification) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if n.Type == TypeLike || n.Type == TypeFollow {
+		for _, stored := range r.notifications {
+			if stored.UserID == n.UserID && stored.Type == n.Type && *stored.ActorID == *n.ActorID && *stored.EntityID == *n.EntityID {
+				return false, nil
+			}
+		}
+	}
+	copied := *n
+	r.notifications = append(r.notifications, &copied)
+	return true, nil
+}
+
+func (r *fakeRepository) List(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*Notification, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var listed []*Notification
+	for _, n := range slices.Backward(r.notifications) {
+		if n.UserID == userID {
+			copied := *n
+			listed = append(listed, &copied)
+		}
+	}
+	if offset >= len(listed) {
+		return nil, nil
+	}
+	return listed[offset:min(offset+limit, len(listed))], nil
+}
+
+func (r *fakeRepository) CountUnread(ctx context.Context, userID uuid.UUID) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	unread := 0
+	for _, n := range r.notifications {
+		if n.UserID == userID && !n.IsRead {
+			unread++
+		}
+	}
+	return unread, nil
+}
+
+func (r *fakeRepository) MarkRead(ctx context.Context, userID uuid.UUID, ids []uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for _, n := range r.notifications {
+		if n.UserID == userID && !n.IsRead && (len(ids) == 0 || slices.Contains(ids, n.ID)) {
+			n.IsRead = true
+			n.ReadAt = &now
+		}
+	}
+	return nil
+}
+
+// fakeDeviceRepository keeps registered devices in memory; with none,
+// nothing is pushed. Other methods are left to the embedded nil Repository.
+type fakeDeviceRepository struct {
+	device.Repository
+	mu      sync.Mutex
+	devices []*device.Device
+}
+
+func (r *fakeDeviceRepository) ListByUser(ctx context.Context, userID uuid.UUID) ([]*device.Device, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var devices []*device.Device
+	for _, d := range r.devices {
+		if d.UserID == userID {
+			devices = append(devices, d)
+		}
+	}
+	return devices, nil
+}
+
+func (r *fakeDeviceRepository) DeleteTokens(ctx context.Context, tokens []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.devices = slices.DeleteFunc(r.devices, func(d *device.Device) bool {
+		return slices.Contains(tokens, d.Token)
+	})
+	return nil
+}
+
+func TestLikeNotifications(t *testing.T) {
+	ctx := context.Background()
+	log := logger.NewZapLogger()
+	client := messaging.NewRecordingClient()
+	publisher := events.NewNATSPublisher(client, log)
+	repo := &fakeRepository{}
+	service := NewService(repo, &fakeDeviceRepository{}, nil, publisher, log)
+
+	worker := NewWorker(service, client, log)
+	if err := worker.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	authorID, likerID, postID := uuid.New(), uuid.New(), uuid.New()
+	like := func(userID uuid.UUID) {
+		t.Helper()
+		liked := events.PostLiked{PostID: postID, AuthorID: authorID, UserID: userID, LikedAt: time.Now()}
+		if err := publisher.Publish(ctx, userID, liked); err != nil {
+			t.Fatalf("publishing: %v", err)
+		}
+	}
+
+	// Liking again and the author liking their own post don't notify
+	like(likerID)
+	like(likerID)
+	like(authorID)
+
+	listed, unread, err := service.List(ctx, authorID, 20, 0)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(listed) != 1 || unread != 1 {
+		t.Fatalf("listed %d notifications with %d unread, want 1 and 1", len(listed), unread)
+	}
+	n := listed[0]
+	if n.Type != TypeLike || n.ActorID == nil || *n.ActorID != likerID || n.EntityType != EntityPost || n.EntityID == nil || *n.EntityID != postID {
+		t.Errorf("notification = %+v, want %s liking post %s", n, likerID, postID)
+	}
+	if created := client.PublishedTo(string(events.TypeNotificationCreated)); len(created) != 1 {
+		t.Errorf("published %d notification.created events, want 1", len(created))
+	}
+
+	comment := events.PostCommented{CommentID: uuid.New(), PostID: postID, AuthorID: authorID, UserID: likerID, CreatedAt: time.Now()}
+	if err := publisher.Publish(ctx, likerID, comment); err != nil {
+		t.Fatalf("publishing: %v", err)
+	}
+
+	unread, err = service.MarkRead(ctx, authorID, []uuid.UUID{n.ID})
+	if err != nil {
+		t.Fatalf("MarkRead: %v", err)
+	}
+	if unread != 1 {
+		t.Errorf("unread after marking the like read = %d, want 1 for the comment", unread)
+	}
+
+	unread, err = service.MarkRead(ctx, authorID, nil)
+	if err != nil {
+		t.Fatalf("MarkRead all: %v", err)
+	}
+	if unread != 0 {
+		t.Errorf("unread after marking all read = %d, want 0", unread)
+	}
+}
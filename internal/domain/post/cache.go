@@ -0,0 +1,110 @@
+package post
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"fowergram-backend/internal/infra/cache"
+	"fowergram-backend/pkg/telemetry"
+
+	"github.com/google/uuid"
+)
+
+// postCacheKeyPrefix prefixes the serialized posts cached for GetPost. Cached
+// posts are viewer-independent; visibility is checked on every read.
+const postCacheKeyPrefix = "post:"
+
+// postCacheTTL bounds how stale a cached post can get if an invalidation is missed
+const postCacheTTL = 5 * time.Minute
+
+// postCacheName labels the post cache in telemetry
+const postCacheName = "post"
+
+func postCacheKey(id uuid.UUID) string {
+	return postCacheKeyPrefix + id.String()
+}
+
+// IsVisible reports whether the viewer may see the post
+func (r *postgresRepository) IsVisible(ctx context.Context, id, viewerID uuid.UUID) (bool, error) {
+	query := `SELECT EXISTS (
+		SELECT 1
+		FROM posts p
+		JOIN users u ON u.id = p.user_id
+		WHERE p.id = $1 AND p.deleted_at IS NULL AND u.is_active = true
+			AND ` + visibilityClause("$2") + `
+	)`
+
+	var visible bool
+	if err := r.db.QueryRow(ctx, query, id, viewerID).Scan(&visible); err != nil {
+		return false, fmt.Errorf("failed to check post visibility: %w", err)
+	}
+
+	return visible, nil
+}
+
+// loadPost returns a post from the cache, loading and caching it on a miss.
+// Concurrent misses for the same post share a single load. Each caller gets
+// its own copy to decorate for its viewer.
+func (s *service) loadPost(ctx context.Context, id uuid.UUID) (*Post, error) {
+	key := postCacheKey(id)
+
+	cached, err := s.cache.Get(ctx, key)
+	if err == nil {
+		s.telemetry.RecordCacheLookup(postCacheName, telemetry.CacheHit)
+		return decodeCachedPost([]byte(cached))
+	}
+	if !errors.Is(err, cache.ErrMiss) {
+		s.logger.Error("Failed to read cached post", "post_id", id, "error", err)
+	}
+	s.telemetry.RecordCacheLookup(postCacheName, telemetry.CacheMiss)
+
+	// The load outlives a caller that gives up, since others may be waiting on it
+	data, err, _ := s.postLoads.Do(key, func() (interface{}, error) {
+		loadCtx := context.WithoutCancel(ctx)
+
+		post, err := s.repo.GetByID(loadCtx, id)
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := json.Marshal(post)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode post: %w", err)
+		}
+
+		if err := s.cache.SetWithExpiration(loadCtx, key, string(data), postCacheTTL); err != nil {
+			s.logger.Error("Failed to cache post", "post_id", id, "error", err)
+		}
+
+		return data, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeCachedPost(data.([]byte))
+}
+
+func decodeCachedPost(data []byte) (*Post, error) {
+	var post Post
+	if err := json.Unmarshal(data, &post); err != nil {
+		return nil, fmt.Errorf("failed to decode cached post: %w", err)
+	}
+	return &post, nil
+}
+
+// invalidatePosts drops cached copies of posts that changed. Failures are
+// logged; the TTL bounds how long a stale copy can be served.
+func (s *service) invalidatePosts(ctx context.Context, ids ...uuid.UUID) {
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, postCacheKey(id))
+	}
+
+	if err := s.cache.Delete(ctx, keys...); err != nil {
+		s.logger.Error("Failed to invalidate cached posts", "posts", len(ids), "error", err)
+	}
+}
@@ -0,0 +1,198 @@
+package post
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"fowergram-backend/internal/infra/cache"
+
+	"github.com/google/uuid"
+)
+
+func TestGetPostCache(t *testing.T) {
+	tests := []struct {
+		name string
+		// between runs after the first GetPost and before the second
+		between   func(t *testing.T, f *postFixture, post *Post)
+		wantLoads int // Repository loads across both GetPost calls
+		wantTitle string
+	}{
+		{
+			name:      "second read is a hit",
+			between:   func(t *testing.T, f *postFixture, post *Post) {},
+			wantLoads: 1,
+			wantTitle: "Title",
+		},
+		{
+			name: "read after the TTL is a miss",
+			between: func(t *testing.T, f *postFixture, post *Post) {
+				f.now = f.now.Add(postCacheTTL)
+			},
+			wantLoads: 2,
+			wantTitle: "Title",
+		},
+		{
+			name: "read just before the TTL is a hit",
+			between: func(t *testing.T, f *postFixture, post *Post) {
+				f.now = f.now.Add(postCacheTTL - time.Second)
+			},
+			wantLoads: 1,
+			wantTitle: "Title",
+		},
+		{
+			name: "update invalidates",
+			between: func(t *testing.T, f *postFixture, post *Post) {
+				title := "Edited"
+				_, err := f.service.UpdatePost(context.Background(), post.ID, Actor{UserID: post.UserID}, UpdatePostInput{
+					Title:   &title,
+					Version: post.Version,
+				})
+				if err != nil {
+					t.Fatalf("UpdatePost: %v", err)
+				}
+			},
+			wantLoads: 3, // UpdatePost loads the post itself
+			wantTitle: "Edited",
+		},
+		{
+			name: "like invalidates",
+			between: func(t *testing.T, f *postFixture, post *Post) {
+				if err := f.service.LikePost(context.Background(), post.ID, uuid.New()); err != nil {
+					t.Fatalf("LikePost: %v", err)
+				}
+			},
+			wantLoads: 2,
+			wantTitle: "Title",
+		},
+		{
+			name: "unrelated write keeps the entry",
+			between: func(t *testing.T, f *postFixture, post *Post) {
+				f.createPost(t, post.UserID, "another")
+			},
+			wantLoads: 1,
+			wantTitle: "Title",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newPostFixture(t)
+			ctx := context.Background()
+			authorID := uuid.New()
+			post := f.createPost(t, authorID, "hello")
+
+			if _, err := f.service.GetPost(ctx, post.ID, authorID); err != nil {
+				t.Fatalf("first GetPost: %v", err)
+			}
+			if _, err := f.cache.Get(ctx, postCacheKey(post.ID)); err != nil {
+				t.Fatalf("post wasn't cached after a miss: %v", err)
+			}
+
+			tt.between(t, f, post)
+
+			got, err := f.service.GetPost(ctx, post.ID, authorID)
+			if err != nil {
+				t.Fatalf("second GetPost: %v", err)
+			}
+			if loads := f.repo.loadCount(); loads != tt.wantLoads {
+				t.Errorf("repository loads = %d, want %d", loads, tt.wantLoads)
+			}
+			if got.Title != tt.wantTitle {
+				t.Errorf("title = %q, want %q", got.Title, tt.wantTitle)
+			}
+		})
+	}
+}
+
+func TestDeletePostInvalidatesCache(t *testing.T) {
+	f := newPostFixture(t)
+	ctx := context.Background()
+	authorID := uuid.New()
+	post := f.createPost(t, authorID, "hello")
+
+	if _, err := f.service.GetPost(ctx, post.ID, authorID); err != nil {
+		t.Fatalf("GetPost: %v", err)
+	}
+	if err := f.service.DeletePost(ctx, post.ID, Actor{UserID: authorID}); err != nil {
+		t.Fatalf("DeletePost: %v", err)
+	}
+
+	if _, err := f.cache.Get(ctx, postCacheKey(post.ID)); !errors.Is(err, cache.ErrMiss) {
+		t.Errorf("cached post after delete: error = %v, want a miss", err)
+	}
+	if _, err := f.service.GetPost(ctx, post.ID, authorID); !errors.Is(err, ErrPostNotFound) {
+		t.Errorf("GetPost after delete = %v, want ErrPostNotFound", err)
+	}
+}
+
+func TestGetPostCachedCopiesAreIndependent(t *testing.T) {
+	f := newPostFixture(t)
+	ctx := context.Background()
+	authorID := uuid.New()
+	post := f.createPost(t, authorID, "hello")
+
+	first, err := f.service.GetPost(ctx, post.ID, authorID)
+	if err != nil {
+		t.Fatalf("GetPost: %v", err)
+	}
+	first.Title = "changed by the caller"
+
+	second, err := f.service.GetPost(ctx, post.ID, authorID)
+	if err != nil {
+		t.Fatalf("GetPost: %v", err)
+	}
+	if second.Title != "Title" {
+		t.Errorf("title = %q; a caller's change leaked into the cache", second.Title)
+	}
+}
+
+func BenchmarkGetPost(b *testing.B) {
+	benchmarks := []struct {
+		name   string
+		cached bool
+	}{
+		{name: "cached", cached: true},
+		{name: "uncached"},
+	}
+
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			f := newPostFixture(b)
+			ctx := context.Background()
+			authorID := uuid.New()
+			post := f.createPost(b, authorID, "hello")
+			key := postCacheKey(post.ID)
+
+			// Warm the cache, so the cached run only measures hits
+			if _, err := f.service.GetPost(ctx, post.ID, authorID); err != nil {
+				b.Fatalf("GetPost: %v", err)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if !bm.cached {
+					// Evicting the entry makes every read a miss that loads
+					// from the repository and fills the cache again
+					if err := f.cache.Delete(ctx, key); err != nil {
+						b.Fatalf("Delete: %v", err)
+					}
+				}
+				if _, err := f.service.GetPost(ctx, post.ID, authorID); err != nil {
+					b.Fatalf("GetPost: %v", err)
+				}
+			}
+			b.StopTimer()
+
+			wantLoads := 1
+			if !bm.cached {
+				wantLoads = b.N + 1
+			}
+			if loads := f.repo.loadCount(); loads != wantLoads {
+				b.Errorf("repository loads = %d, want %d", loads, wantLoads)
+			}
+		})
+	}
+}
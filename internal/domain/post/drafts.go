@@ -0,0 +1,319 @@
+package post
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"fowergram-backend/internal/events"
+	"fowergram-backend/internal/infra/database"
+	"fowergram-backend/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// GetUnpublished retrieves the user's drafts and scheduled posts, most recently edited first
+func (r *postgresRepository) GetUnpublished(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*Post, error) {
+	query := `SELECT ` + postColumns + `
+		FROM posts p
+		WHERE p.user_id = $1 AND p.deleted_at IS NULL AND NOT ` + publishedClause + `
+		ORDER BY p.updated_at DESC, p.id DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	rows, err := r.db.Query(ctx, query, userID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get drafts: %w", err)
+	}
+	defer rows.Close()
+
+	posts, err := scanPosts(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.loadDetails(ctx, posts); err != nil {
+		return nil, err
+	}
+
+	return posts, nil
+}
+
+// Publish marks an unpublished post as published at post.CreatedAt. The
+// post's tags take the same time so it sorts as new in tag listings too.
+// ErrAlreadyPublished is returned if it was published in the meantime.
+func (r *postgresRepository) Publish(ctx context.Context, post *Post) error {
+	err := database.WithTx(ctx, r.db, func(tx database.DB) error {
+		query := `
+			UPDATE posts SET
+				status = 'published',
+				scheduled_at = NULL,
+				created_at = $1,
+				updated_at = $1,
+				version = version + 1
+			WHERE id = $2 AND deleted_at IS NULL AND status <> 'published'
+			RETURNING version
+		`
+
+		if err := tx.QueryRow(ctx, query, post.CreatedAt, post.ID).Scan(&post.Version); err != nil {
+			if err == pgx.ErrNoRows {
+				return ErrAlreadyPublished
+			}
+			return fmt.Errorf("failed to publish post: %w", err)
+		}
+
+		if _, err := tx.Exec(ctx, `UPDATE post_tags SET created_at = $1 WHERE post_id = $2`, post.CreatedAt, post.ID); err != nil {
+			return fmt.Errorf("failed to update post tags: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	post.Status = StatusPublished
+	post.ScheduledAt = nil
+	post.UpdatedAt = post.CreatedAt
+	return nil
+}
+
+// PublishDue publishes every scheduled post whose time has come, dated at
+// its scheduled time, and returns them. Each post is returned by exactly one
+// caller even when several instances run concurrently.
+func (r *postgresRepository) PublishDue(ctx context.Context, now time.Time) ([]*Post, error) {
+	var posts []*Post
+	err := database.WithTx(ctx, r.db, func(tx database.DB) error {
+		query := `
+			WITH due AS (
+				SELECT id FROM posts
+				WHERE status = 'scheduled' AND scheduled_at <= $1 AND deleted_at IS NULL
+				FOR UPDATE SKIP LOCKED
+			)
+			UPDATE posts p SET
+				status = 'published',
+				created_at = p.scheduled_at,
+				updated_at = $1,
+				scheduled_at = NULL,
+				version = p.version + 1
+			FROM due
+			WHERE p.id = due.id
+			RETURNING ` + postColumns
+
+		rows, err := tx.Query(ctx, query, now)
+		if err != nil {
+			return fmt.Errorf("failed to publish scheduled posts: %w", err)
+		}
+		posts, err = scanPosts(rows)
+		if err != nil {
+			return err
+		}
+
+		for _, post := range posts {
+			if _, err := tx.Exec(ctx, `UPDATE post_tags SET created_at = $1 WHERE post_id = $2`, post.CreatedAt, post.ID); err != nil {
+				return fmt.Errorf("failed to update post tags: %w", err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return posts, nil
+}
+
+// GetDrafts lists the user's drafts and scheduled posts
+func (s *service) GetDrafts(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*Post, error) {
+	posts, err := s.repo.GetUnpublished(ctx, userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, post := range posts {
+		if err := s.resolveMediaURLs(ctx, post); err != nil {
+			return nil, err
+		}
+	}
+
+	return posts, nil
+}
+
+// PublishPost publishes the author's draft or scheduled post right away
+func (s *service) PublishPost(ctx context.Context, id, userID uuid.UUID) (*Post, error) {
+	post, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if post.UserID != userID {
+		return nil, ErrNotPostOwner
+	}
+	if post.Status == StatusPublished {
+		return nil, ErrAlreadyPublished
+	}
+
+	post.CreatedAt = time.Now()
+	var mentioned []uuid.UUID
+	err = database.WithTx(ctx, s.db, func(tx database.DB) error {
+		repo := s.repo.WithTx(tx)
+		if err := repo.Publish(ctx, post); err != nil {
+			return err
+		}
+		mentioned, err = addCaptionMentions(ctx, repo, post)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.invalidatePosts(ctx, post.ID)
+
+	s.publishCreated(ctx, post, mentioned)
+
+	if err := s.resolveMediaURLs(ctx, post); err != nil {
+		return nil, err
+	}
+
+	return post, nil
+}
+
+// PublishDuePosts publishes scheduled posts whose time has come and reports how many
+func (s *service) PublishDuePosts(ctx context.Context) (int, error) {
+	var posts []*Post
+	mentioned := make(map[uuid.UUID][]uuid.UUID)
+	err := database.WithTx(ctx, s.db, func(tx database.DB) error {
+		repo := s.repo.WithTx(tx)
+		var err error
+		posts, err = repo.PublishDue(ctx, time.Now())
+		if err != nil {
+			return err
+		}
+		for _, post := range posts {
+			if mentioned[post.ID], err = addCaptionMentions(ctx, repo, post); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, post := range posts {
+		s.invalidatePosts(ctx, post.ID)
+		s.publishCreated(ctx, post, mentioned[post.ID])
+	}
+
+	return len(posts), nil
+}
+
+// validateSchedule checks that a scheduled time is in the future and within MaxScheduleAhead
+func validateSchedule(scheduledAt, now time.Time) error {
+	if !scheduledAt.After(now) {
+		return ErrScheduleInPast
+	}
+	if scheduledAt.After(now.Add(MaxScheduleAhead)) {
+		return ErrScheduleTooFar
+	}
+	return nil
+}
+
+// addCaptionMentions records the users a newly published post's caption
+// mentions, through repo so it joins the transaction publishing the post
+func addCaptionMentions(ctx context.Context, repo Repository, post *Post) ([]uuid.UUID, error) {
+	usernames := ExtractMentions(derefString(post.Caption))
+	return repo.AddMentions(ctx, post.UserID, post.ID, nil, usernames, post.CreatedAt)
+}
+
+// publishCreated announces a newly published post and notifies the users
+// its caption mentions
+func (s *service) publishCreated(ctx context.Context, post *Post, mentioned []uuid.UUID) {
+	s.publish(ctx, post.UserID, events.PostCreated{
+		PostID:    post.ID,
+		AuthorID:  post.UserID,
+		IsPrivate: post.IsPrivate,
+		CreatedAt: post.CreatedAt,
+	})
+	s.notifyMentioned(ctx, post, mentioned, post.CreatedAt)
+}
+
+// notifyMentioned tells the users newly mentioned in a post's caption at
+// mentionedAt
+func (s *service) notifyMentioned(ctx context.Context, post *Post, userIDs []uuid.UUID, mentionedAt time.Time) {
+	for _, userID := range userIDs {
+		s.publish(ctx, post.UserID, events.UserMentioned{
+			UserID:    userID,
+			PostID:    post.ID,
+			CreatedAt: mentionedAt,
+		})
+	}
+}
+
+// SchedulePublisher publishes scheduled posts when they come due
+type SchedulePublisher struct {
+	service  Service
+	interval time.Duration
+	logger   logger.Logger
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// NewSchedulePublisher creates a publisher that checks for due posts every interval once started
+func NewSchedulePublisher(service Service, interval time.Duration, logger logger.Logger) *SchedulePublisher {
+	return &SchedulePublisher{
+		service:  service,
+		interval: interval,
+		logger:   logger,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start runs the publish loop in the background until Stop is called
+func (p *SchedulePublisher) Start() {
+	go func() {
+		defer close(p.done)
+
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				p.run()
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the publish loop, waiting for a run in progress to finish
+func (p *SchedulePublisher) Stop(ctx context.Context) error {
+	p.stopOnce.Do(func() { close(p.stop) })
+
+	select {
+	case <-p.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *SchedulePublisher) run() {
+	ctx, cancel := context.WithTimeout(context.Background(), p.interval)
+	defer cancel()
+
+	published, err := p.service.PublishDuePosts(ctx)
+	if err != nil {
+		p.logger.Error("Failed to publish scheduled posts", "error", err)
+		return
+	}
+	if published > 0 {
+		p.logger.Info("Published scheduled posts", "count", published)
+	}
+}
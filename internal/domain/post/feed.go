@@ -0,0 +1,49 @@
+package post
+
+import (
+	"context"
+
+	"fowergram-backend/internal/events"
+
+	"github.com/google/uuid"
+)
+
+// SubscribeFeed calls handler for every post created by an account the viewer
+// follows until cancel is called. The followed accounts are read once, so
+// follows made after subscribing take effect on the next subscription.
+func (s *service) SubscribeFeed(ctx context.Context, viewerID uuid.UUID, handler func(events.PostCreated)) (func(), error) {
+	ids, err := s.userRepo.GetFollowingIDs(ctx, viewerID)
+	if err != nil {
+		return nil, err
+	}
+
+	following := make(map[uuid.UUID]struct{}, len(ids))
+	for _, id := range ids {
+		following[id] = struct{}{}
+	}
+
+	sub, err := s.messaging.Subscribe(string(events.TypePostCreated), func(_ context.Context, data []byte) {
+		var event events.PostCreated
+		envelope, err := events.Decode(data)
+		if err == nil {
+			err = envelope.DecodePayload(&event)
+		}
+		if err != nil {
+			s.logger.Error("Failed to decode post created event", "error", err)
+			return
+		}
+
+		if _, ok := following[event.AuthorID]; ok {
+			handler(event)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return func() {
+		if err := sub.Unsubscribe(); err != nil {
+			s.logger.Error("Failed to unsubscribe feed stream", "viewer_id", viewerID, "error", err)
+		}
+	}, nil
+}
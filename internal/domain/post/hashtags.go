@@ -0,0 +1,91 @@
+package post
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Hashtag limits
+const (
+	MaxTagLength   = 100
+	MaxTagsPerPost = 30
+)
+
+// NormalizeTag converts a tag to its canonical form: NFC-normalized,
+// lowercased, without a leading '#'. It reports false if the result is
+// empty, too long or contains characters other than letters, digits,
+// combining marks and underscores.
+func NormalizeTag(tag string) (string, bool) {
+	tag = strings.TrimPrefix(strings.TrimSpace(tag), "#")
+	tag = strings.ToLower(norm.NFC.String(tag))
+
+	if tag == "" || utf8.RuneCountInString(tag) > MaxTagLength {
+		return "", false
+	}
+	for _, r := range tag {
+		if !isTagRune(r) {
+			return "", false
+		}
+	}
+
+	return tag, true
+}
+
+// ExtractHashtags returns the normalized hashtags found in text, in order of
+// first appearance and without duplicates
+func ExtractHashtags(text string) []string {
+	var tags []string
+	runes := []rune(text)
+
+	for i := 0; i < len(runes); i++ {
+		// A hashtag starts at '#' that isn't glued to a preceding word
+		if runes[i] != '#' || (i > 0 && isTagRune(runes[i-1])) {
+			continue
+		}
+
+		j := i + 1
+		for j < len(runes) && isTagRune(runes[j]) {
+			j++
+		}
+
+		if tag, ok := NormalizeTag(string(runes[i+1 : j])); ok {
+			tags = appendUnique(tags, tag)
+		}
+		i = j - 1
+	}
+
+	return tags
+}
+
+// mergeTags normalizes explicit tags and combines them with tags extracted
+// from the given texts, dropping invalid entries and duplicates
+func mergeTags(explicit []string, texts ...string) []string {
+	var tags []string
+	for _, t := range explicit {
+		if tag, ok := NormalizeTag(t); ok {
+			tags = appendUnique(tags, tag)
+		}
+	}
+	for _, text := range texts {
+		for _, tag := range ExtractHashtags(text) {
+			tags = appendUnique(tags, tag)
+		}
+	}
+	return tags
+}
+
+func isTagRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Mc, r)
+}
+
+func appendUnique(tags []string, tag string) []string {
+	for _, t := range tags {
+		if t == tag {
+			return tags
+		}
+	}
+	return append(tags, tag)
+}
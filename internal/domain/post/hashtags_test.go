@@ -0,0 +1,177 @@
+package post
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestNormalizeTag(t *testing.T) {
+	tests := []struct {
+		name   string
+		tag    string
+		want   string
+		wantOK bool
+	}{
+		{name: "plain", tag: "golang", want: "golang", wantOK: true},
+		{name: "leading hash and spaces", tag: "  #GoLang ", want: "golang", wantOK: true},
+		{name: "digits and underscores", tag: "go_1_23", want: "go_1_23", wantOK: true},
+		{name: "non-Latin letters", tag: "#ภาษาไทย", want: "ภาษาไทย", wantOK: true},
+		{name: "decomposed accents are composed", tag: "Cafe\u0301", want: "caf\u00e9", wantOK: true},
+		{name: "empty", tag: "#"},
+		{name: "punctuation", tag: "go-lang"},
+		{name: "space inside", tag: "go lang"},
+		{name: "longest", tag: strings.Repeat("a", MaxTagLength), want: strings.Repeat("a", MaxTagLength), wantOK: true},
+		{name: "too long", tag: strings.Repeat("a", MaxTagLength+1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := NormalizeTag(tt.tag)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("NormalizeTag(%q) = %q, %v; want %q, %v", tt.tag, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestExtractHashtags(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{name: "empty", text: "", want: nil},
+		{name: "order of first appearance", text: "#sunset at the #beach", want: []string{"sunset", "beach"}},
+		{name: "duplicates in any case", text: "#Beach #beach #BEACH", want: []string{"beach"}},
+		{name: "punctuation ends a tag", text: "#sunset, #beach! (#sea)", want: []string{"sunset", "beach", "sea"}},
+		{name: "glued to a word", text: "issue#42 and c#", want: nil},
+		{name: "adjacent tags", text: "#one#two", want: []string{"one"}},
+		{name: "bare hash", text: "# nothing", want: nil},
+		{name: "non-Latin", text: "วันหยุด #ทะเล", want: []string{"ทะเล"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExtractHashtags(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ExtractHashtags(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreatePostTags(t *testing.T) {
+	tooMany := make([]string, MaxTagsPerPost+1)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprintf("tag%d", i)
+	}
+
+	tests := []struct {
+		name    string
+		input   CreatePostInput
+		want    []string
+		wantErr error
+	}{
+		{
+			name:  "explicit tags come first, then the caption's",
+			input: CreatePostInput{Title: "Hi", Tags: []string{"#Travel", "bad tag"}, Caption: ptr("At the #beach #travel")},
+			want:  []string{"travel", "beach"},
+		},
+		{
+			name:  "at the limit",
+			input: CreatePostInput{Title: "Hi", Tags: tooMany[:MaxTagsPerPost]},
+			want:  tooMany[:MaxTagsPerPost],
+		},
+		{
+			name:    "over the limit",
+			input:   CreatePostInput{Title: "Hi", Tags: tooMany[:MaxTagsPerPost], Caption: ptr("#" + tooMany[MaxTagsPerPost])},
+			wantErr: ErrTooManyTags,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newPostFixture(t)
+
+			post, err := f.service.CreatePost(context.Background(), uuid.New(), tt.input)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CreatePost error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if len(f.repo.posts) != 0 {
+					t.Error("stored a post with too many tags")
+				}
+				return
+			}
+			if stored := f.repo.posts[post.ID]; !reflect.DeepEqual(stored.Tags, tt.want) {
+				t.Errorf("stored tags = %q, want %q", stored.Tags, tt.want)
+			}
+		})
+	}
+}
+
+func TestEscapeLike(t *testing.T) {
+	tests := map[string]string{
+		"beach":   "beach",
+		"100%":    `100\%`,
+		"go_lang": `go\_lang`,
+		`a\b`:     `a\\b`,
+	}
+	for in, want := range tests {
+		if got := escapeLike(in); got != want {
+			t.Errorf("escapeLike(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestListPostsByTag(t *testing.T) {
+	ctx := context.Background()
+	f := newPostFixture(t)
+	authorID, viewerID := uuid.New(), uuid.New()
+	f.createPost(t, authorID, "Morning at the #beach")
+	f.createPost(t, authorID, "Downtown #city")
+	f.createPost(t, authorID, "Sunset at the #Beach")
+
+	// captions lists the captions on a page, newest first
+	captions := func(page *Page) []string {
+		var got []string
+		for _, p := range page.Posts {
+			got = append(got, derefString(p.Caption))
+		}
+		return got
+	}
+
+	first, err := f.service.ListPosts(ctx, viewerID, ListPostsFilter{Tag: "#BEACH"}, ListPostsQuery{Limit: 1, Count: CountNone})
+	if err != nil {
+		t.Fatalf("ListPosts: %v", err)
+	}
+	if got, want := captions(first), []string{"Sunset at the #Beach"}; !reflect.DeepEqual(got, want) || first.NextCursor == "" {
+		t.Fatalf("first page = %q with cursor %q, want %q and a cursor", got, first.NextCursor, want)
+	}
+
+	cursor, err := DecodeCursor(first.NextCursor)
+	if err != nil {
+		t.Fatalf("DecodeCursor: %v", err)
+	}
+	second, err := f.service.ListPosts(ctx, viewerID, ListPostsFilter{Tag: "beach"}, ListPostsQuery{After: cursor, Limit: 1, Count: CountNone})
+	if err != nil {
+		t.Fatalf("ListPosts after the cursor: %v", err)
+	}
+	if got, want := captions(second), []string{"Morning at the #beach"}; !reflect.DeepEqual(got, want) || second.NextCursor != "" {
+		t.Errorf("second page = %q with cursor %q, want %q and no cursor", got, second.NextCursor, want)
+	}
+
+	// A tag that can't exist matches nothing without querying
+	invalid, err := f.service.ListPosts(ctx, viewerID, ListPostsFilter{Tag: "go-lang"}, ListPostsQuery{Limit: 10, Count: CountExact})
+	if err != nil {
+		t.Fatalf("ListPosts with an invalid tag: %v", err)
+	}
+	if len(invalid.Posts) != 0 || invalid.TotalCount == nil || *invalid.TotalCount != 0 {
+		t.Errorf("invalid tag listed %d posts of %v, want none of 0", len(invalid.Posts), invalid.TotalCount)
+	}
+}
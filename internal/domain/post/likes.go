@@ -0,0 +1,124 @@
+package post
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"fowergram-backend/internal/infra/database"
+
+	"github.com/google/uuid"
+)
+
+// Like records a like, bumping the post's likes_count only when the like is new
+func (r *postgresRepository) Like(ctx context.Context, postID, userID uuid.UUID) (bool, error) {
+	var liked bool
+	err := database.WithTx(ctx, r.db, func(tx database.DB) error {
+		insertQuery := `
+			INSERT INTO post_likes (post_id, user_id, created_at)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (post_id, user_id) DO NOTHING
+		`
+		tag, err := tx.Exec(ctx, insertQuery, postID, userID, time.Now())
+		if err != nil {
+			return fmt.Errorf("failed to like post: %w", err)
+		}
+
+		if tag.RowsAffected() == 0 {
+			return nil
+		}
+
+		updateQuery := `
+			UPDATE posts SET likes_count = likes_count + 1
+			WHERE id = $1
+		`
+		if _, err := tx.Exec(ctx, updateQuery, postID); err != nil {
+			return fmt.Errorf("failed to update likes count: %w", err)
+		}
+
+		liked = true
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return liked, nil
+}
+
+// Unlike removes a like, decrementing the post's likes_count only when a like existed
+func (r *postgresRepository) Unlike(ctx context.Context, postID, userID uuid.UUID) (bool, error) {
+	var unliked bool
+	err := database.WithTx(ctx, r.db, func(tx database.DB) error {
+		deleteQuery := `
+			DELETE FROM post_likes
+			WHERE post_id = $1 AND user_id = $2
+		`
+		tag, err := tx.Exec(ctx, deleteQuery, postID, userID)
+		if err != nil {
+			return fmt.Errorf("failed to unlike post: %w", err)
+		}
+
+		if tag.RowsAffected() == 0 {
+			return nil
+		}
+
+		updateQuery := `
+			UPDATE posts SET likes_count = GREATEST(likes_count - 1, 0)
+			WHERE id = $1
+		`
+		if _, err := tx.Exec(ctx, updateQuery, postID); err != nil {
+			return fmt.Errorf("failed to update likes count: %w", err)
+		}
+
+		unliked = true
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return unliked, nil
+}
+
+// GetLikers retrieves the users who liked a post, most recent first
+func (r *postgresRepository) GetLikers(ctx context.Context, postID uuid.UUID, limit, offset int) ([]*Liker, error) {
+	query := `
+		SELECT u.id, u.username, COALESCE(u.full_name, ''), COALESCE(u.profile_picture, ''),
+			   u.is_verified, pl.created_at
+		FROM post_likes pl
+		JOIN users u ON u.id = pl.user_id
+		WHERE pl.post_id = $1 AND u.is_active = true
+		ORDER BY pl.created_at DESC, pl.user_id
+		LIMIT $2 OFFSET $3
+	`
+
+	rows, err := r.db.Query(ctx, query, postID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get likers: %w", err)
+	}
+	defer rows.Close()
+
+	var likers []*Liker
+	for rows.Next() {
+		liker := &Liker{}
+		err := rows.Scan(
+			&liker.UserID,
+			&liker.Username,
+			&liker.FullName,
+			&liker.ProfilePicture,
+			&liker.IsVerified,
+			&liker.LikedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan liker: %w", err)
+		}
+		likers = append(likers, liker)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate likers: %w", err)
+	}
+
+	return likers, nil
+}
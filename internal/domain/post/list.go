@@ -0,0 +1,221 @@
+package post
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Cursor marks the last post of a page; the next page starts strictly after it
+type Cursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// Encode returns the opaque string form of the cursor
+func (c Cursor) Encode() string {
+	raw := c.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + c.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeCursor parses a cursor produced by Encode. An empty string yields nil.
+func DecodeCursor(s string) (*Cursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	createdAt, id, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, createdAt)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	parsedID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &Cursor{CreatedAt: t, ID: parsedID}, nil
+}
+
+// ListPostsQuery selects a window of the newest-first post listing. After is
+// the keyset position; Offset only backs the deprecated page/page_size params.
+type ListPostsQuery struct {
+	After  *Cursor
+	Offset int
+	Limit  int
+	Count  CountMode // How to count all the matching posts; empty doesn't
+}
+
+// CountMode selects how a listing counts all the posts it matches, trading
+// accuracy for speed
+type CountMode string
+
+const (
+	// CountExact counts the matching posts
+	CountExact CountMode = "exact"
+
+	// CountEstimate takes the planner's estimate of the matching rows, from
+	// table statistics. It costs the same on any table size but may be well
+	// off, especially for narrow filters.
+	CountEstimate CountMode = "estimate"
+
+	// CountNone doesn't count
+	CountNone CountMode = "none"
+)
+
+// ListPostsFilter narrows the post listing. Zero values mean no filter.
+type ListPostsFilter struct {
+	AuthorID   *uuid.UUID
+	Tag        string     // Normalized tag, without the leading #
+	FollowedBy *uuid.UUID // Only posts by accounts this user follows
+
+	// MinAuthorFollowers keeps only posts by authors with at least this many followers
+	MinAuthorFollowers int
+}
+
+// Page is a page of posts ordered newest-first
+type Page struct {
+	Posts      []*Post
+	NextCursor string
+	TotalCount *int64 // Posts matching the listing; nil unless counted
+
+	// TotalCountEstimated is set when TotalCount is the planner's estimate
+	TotalCountEstimated bool
+}
+
+// newPage trims a result fetched with limit+1 rows and sets the next cursor
+func newPage(posts []*Post, limit int) *Page {
+	page := &Page{Posts: posts}
+	if len(posts) > limit {
+		page.Posts = posts[:limit]
+		last := page.Posts[limit-1]
+		page.NextCursor = Cursor{CreatedAt: last.CreatedAt, ID: last.ID}.Encode()
+	}
+	return page
+}
+
+// ListVisible retrieves posts matching the filter that the viewer may see, newest first
+func (r *postgresRepository) ListVisible(ctx context.Context, viewerID uuid.UUID, filter ListPostsFilter, q ListPostsQuery) ([]*Post, error) {
+	where, args := visibleWhere(viewerID, filter)
+
+	if q.After != nil {
+		args = append(args, q.After.CreatedAt, q.After.ID)
+		where += fmt.Sprintf(` AND (p.created_at, p.id) < ($%d, $%d)`, len(args)-1, len(args))
+	}
+
+	args = append(args, q.Limit, q.Offset)
+	query := `SELECT ` + postColumns + `
+		FROM posts p
+		JOIN users u ON u.id = p.user_id
+		WHERE ` + where + fmt.Sprintf(`
+		ORDER BY p.created_at DESC, p.id DESC
+		LIMIT $%d OFFSET $%d
+	`, len(args)-1, len(args))
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list posts: %w", err)
+	}
+	defer rows.Close()
+
+	var posts []*Post
+	for rows.Next() {
+		post, err := scanPost(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan post: %w", err)
+		}
+		posts = append(posts, post)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate posts: %w", err)
+	}
+
+	if err := r.loadDetails(ctx, posts); err != nil {
+		return nil, err
+	}
+
+	return posts, nil
+}
+
+// CountVisible counts the posts matching the filter that the viewer may
+// see, exactly or by the planner's estimate
+func (r *postgresRepository) CountVisible(ctx context.Context, viewerID uuid.UUID, filter ListPostsFilter, mode CountMode) (int64, error) {
+	where, args := visibleWhere(viewerID, filter)
+	from := `
+		FROM posts p
+		JOIN users u ON u.id = p.user_id
+		WHERE ` + where
+
+	if mode == CountEstimate {
+		var raw []byte
+		if err := r.db.QueryRow(ctx, `EXPLAIN (FORMAT JSON) SELECT 1`+from, args...).Scan(&raw); err != nil {
+			return 0, fmt.Errorf("failed to estimate posts: %w", err)
+		}
+
+		var plans []struct {
+			Plan struct {
+				Rows float64 `json:"Plan Rows"`
+			} `json:"Plan"`
+		}
+		if err := json.Unmarshal(raw, &plans); err != nil {
+			return 0, fmt.Errorf("failed to read post estimate: %w", err)
+		}
+		if len(plans) == 0 {
+			return 0, fmt.Errorf("failed to read post estimate: empty plan")
+		}
+		return int64(plans[0].Plan.Rows), nil
+	}
+
+	var count int64
+	if err := r.db.QueryRow(ctx, `SELECT COUNT(*)`+from, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count posts: %w", err)
+	}
+	return count, nil
+}
+
+// visibleWhere returns the WHERE clause selecting the posts matching the
+// filter that the viewer may see, and its arguments, the viewer first
+func visibleWhere(viewerID uuid.UUID, filter ListPostsFilter) (string, []interface{}) {
+	args := []interface{}{viewerID}
+	where := `p.deleted_at IS NULL AND u.is_active = true AND ` + publishedClause + ` AND ` + visibilityClause("$1")
+
+	if filter.AuthorID != nil {
+		args = append(args, *filter.AuthorID)
+		where += fmt.Sprintf(` AND p.user_id = $%d`, len(args))
+	}
+	if filter.Tag != "" {
+		args = append(args, filter.Tag)
+		where += fmt.Sprintf(` AND EXISTS (
+			SELECT 1 FROM post_tags pt
+			WHERE pt.post_id = p.id AND pt.tag = $%d
+		)`, len(args))
+	}
+	if filter.FollowedBy != nil {
+		args = append(args, *filter.FollowedBy)
+		where += fmt.Sprintf(` AND p.user_id IN (
+			SELECT f.following_id FROM followers f WHERE f.follower_id = $%d
+		)`, len(args))
+	}
+	if filter.MinAuthorFollowers > 0 {
+		args = append(args, filter.MinAuthorFollowers)
+		where += fmt.Sprintf(` AND u.followers_count >= $%d`, len(args))
+	}
+
+	return where, args
+}
@@ -0,0 +1,117 @@
+package post
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"fowergram-backend/internal/infra/database"
+	"fowergram-backend/pkg/auth"
+
+	"github.com/google/uuid"
+)
+
+// MaxMentions caps the distinct usernames recorded from a text; later ones
+// are ignored
+const MaxMentions = 20
+
+// ExtractMentions returns the usernames @-mentioned in text, in order of
+// first appearance and without duplicates. Like a hashtag, a mention starts
+// at '@' that isn't glued to a preceding word, so email addresses aren't
+// mentions; runs too short or long to be a username are skipped.
+func ExtractMentions(text string) []string {
+	var usernames []string
+
+	for i := 0; i < len(text) && len(usernames) < MaxMentions; i++ {
+		if text[i] != '@' || (i > 0 && auth.IsUsernameByte(text[i-1])) {
+			continue
+		}
+
+		j := i + 1
+		for j < len(text) && auth.IsUsernameByte(text[j]) {
+			j++
+		}
+
+		if auth.IsValidUsername(text[i+1 : j]) {
+			usernames = appendUnique(usernames, text[i+1:j])
+		}
+		i = j - 1
+	}
+
+	return usernames
+}
+
+// AddMentions records that authorID mentioned usernames in a post's caption,
+// or in one of its comments when commentID is set, and returns the IDs of
+// the users mentioned for the first time. Unknown and inactive usernames are
+// ignored, as are the author and users either blocking or blocked by them.
+func (r *postgresRepository) AddMentions(ctx context.Context, authorID, postID uuid.UUID, commentID *uuid.UUID, usernames []string, createdAt time.Time) ([]uuid.UUID, error) {
+	if len(usernames) == 0 {
+		return nil, nil
+	}
+
+	query := `
+		INSERT INTO mentions (post_id, comment_id, user_id, created_at)
+		SELECT $2, $3, u.id, $5
+		FROM users u
+		WHERE u.username = ANY($4) AND u.id <> $1
+			AND u.is_active = true
+			AND NOT EXISTS (
+				SELECT 1 FROM blocks b
+				WHERE (b.blocker_id = u.id AND b.blocked_id = $1)
+				   OR (b.blocker_id = $1 AND b.blocked_id = u.id)
+			)
+		ON CONFLICT DO NOTHING
+		RETURNING user_id
+	`
+
+	rows, err := r.db.Query(ctx, query, authorID, postID, commentID, usernames, createdAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add mentions: %w", err)
+	}
+	defer rows.Close()
+
+	var userIDs []uuid.UUID
+	for rows.Next() {
+		var userID uuid.UUID
+		if err := rows.Scan(&userID); err != nil {
+			return nil, fmt.Errorf("failed to scan mention: %w", err)
+		}
+		userIDs = append(userIDs, userID)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate mentions: %w", err)
+	}
+
+	return userIDs, nil
+}
+
+// SyncMentions makes the users recorded as mentioned in a post's caption
+// match usernames, by the same rules as AddMentions, and returns the IDs of
+// the users mentioned for the first time
+func (r *postgresRepository) SyncMentions(ctx context.Context, authorID, postID uuid.UUID, usernames []string, createdAt time.Time) ([]uuid.UUID, error) {
+	var added []uuid.UUID
+	err := database.WithTx(ctx, r.db, func(tx database.DB) error {
+		query := `
+			DELETE FROM mentions m
+			WHERE m.post_id = $1 AND m.comment_id IS NULL
+				AND NOT EXISTS (
+					SELECT 1 FROM users u
+					WHERE u.id = m.user_id AND u.username = ANY($2)
+				)
+		`
+		if _, err := tx.Exec(ctx, query, postID, usernames); err != nil {
+			return fmt.Errorf("failed to remove mentions: %w", err)
+		}
+
+		var err error
+		added, err = r.WithTx(tx).AddMentions(ctx, authorID, postID, nil, usernames, createdAt)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return added, nil
+}
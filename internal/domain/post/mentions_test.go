@@ -0,0 +1,199 @@
+package post
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"fowergram-backend/internal/events"
+	"fowergram-backend/pkg/auth"
+
+	"github.com/google/uuid"
+)
+
+func TestExtractMentions(t *testing.T) {
+	many := make([]string, MaxMentions+5)
+	for i := range many {
+		many[i] = "@user" + string(rune('a'+i))
+	}
+
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{name: "empty", text: "", want: nil},
+		{name: "single", text: "hi @alice", want: []string{"alice"}},
+		{name: "order of first appearance", text: "@carol and @alice", want: []string{"carol", "alice"}},
+		{name: "duplicates", text: "@alice @bob @alice", want: []string{"alice", "bob"}},
+		{name: "punctuation ends a mention", text: "thanks @alice, @bob! (@carol)", want: []string{"alice", "bob", "carol"}},
+		{name: "case is kept", text: "@Alice", want: []string{"Alice"}},
+		{name: "email address", text: "mail me at alice@example.com", want: nil},
+		{name: "too short", text: "@al", want: nil},
+		{name: "longer than 30", text: "@" + strings.Repeat("a", 31), want: []string{strings.Repeat("a", 31)}},
+		{name: "longest username", text: "@" + strings.Repeat("a", auth.MaxUsernameLength), want: []string{strings.Repeat("a", auth.MaxUsernameLength)}},
+		{name: "too long", text: "@" + strings.Repeat("a", auth.MaxUsernameLength+1), want: nil},
+		{name: "bare at sign", text: "meet @ noon", want: nil},
+		{name: "underscore ends a mention", text: "@bob_smith", want: []string{"bob"}},
+		{name: "capped", text: strings.Join(many, " "), want: stripAt(many[:MaxMentions])},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExtractMentions(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ExtractMentions(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func stripAt(mentions []string) []string {
+	usernames := make([]string, len(mentions))
+	for i, m := range mentions {
+		usernames[i] = strings.TrimPrefix(m, "@")
+	}
+	return usernames
+}
+
+func TestMentionNotifications(t *testing.T) {
+	tests := []struct {
+		name    string
+		draft   bool
+		caption string
+		edit    *string // Caption edit after creating the post, if any
+		// wantNotified are the users notified by the last step, creation or edit
+		wantNotified []string
+		wantStored   []string
+	}{
+		{
+			name:         "new post notifies users the author can mention",
+			caption:      "with @alice @blocked @blocker @author @nobody",
+			wantNotified: []string{"alice"},
+			wantStored:   []string{"alice"},
+		},
+		{
+			name:         "edit notifies only added users",
+			caption:      "with @alice @carol",
+			edit:         ptr("with @carol @dave"),
+			wantNotified: []string{"dave"},
+			wantStored:   []string{"carol", "dave"},
+		},
+		{
+			name:       "edit keeping the mentions notifies nobody",
+			caption:    "with @alice",
+			edit:       ptr("still with @alice"),
+			wantStored: []string{"alice"},
+		},
+		{
+			name:       "edit mentioning blocked users notifies nobody",
+			caption:    "alone",
+			edit:       ptr("with @blocked and @blocker"),
+			wantStored: nil,
+		},
+		{
+			name:       "edit removing every mention",
+			caption:    "with @alice",
+			edit:       ptr("alone"),
+			wantStored: nil,
+		},
+		{
+			name:       "draft edit notifies nobody",
+			draft:      true,
+			caption:    "draft",
+			edit:       ptr("with @alice"),
+			wantStored: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newPostFixture(t)
+			ctx := context.Background()
+			authorID := uuid.New()
+			usernames := map[uuid.UUID]string{authorID: "author"}
+			for _, username := range []string{"alice", "carol", "dave", "blocked", "blocker"} {
+				usernames[uuid.New()] = username
+			}
+			for id, username := range usernames {
+				f.repo.users[username] = id
+			}
+			f.repo.blocks[[2]uuid.UUID{authorID, f.repo.users["blocked"]}] = true
+			f.repo.blocks[[2]uuid.UUID{f.repo.users["blocker"], authorID}] = true
+
+			post, err := f.service.CreatePost(ctx, authorID, CreatePostInput{
+				Title:   "Title",
+				Caption: &tt.caption,
+				Draft:   tt.draft,
+			})
+			if err != nil {
+				t.Fatalf("CreatePost: %v", err)
+			}
+
+			notifiedBefore := 0
+			if tt.edit != nil {
+				notifiedBefore = len(mentionEvents(t, f, post.ID))
+				_, err := f.service.UpdatePost(ctx, post.ID, Actor{UserID: authorID}, UpdatePostInput{
+					Caption: tt.edit,
+					Version: post.Version,
+				})
+				if err != nil {
+					t.Fatalf("UpdatePost: %v", err)
+				}
+			}
+
+			var notified []string
+			for _, userID := range mentionEvents(t, f, post.ID)[notifiedBefore:] {
+				notified = append(notified, usernames[userID])
+			}
+			if !sameUsernames(notified, tt.wantNotified) {
+				t.Errorf("notified %q, want %q", notified, tt.wantNotified)
+			}
+
+			var stored []string
+			for userID := range f.repo.mentions[post.ID] {
+				stored = append(stored, usernames[userID])
+			}
+			if !sameUsernames(stored, tt.wantStored) {
+				t.Errorf("stored mentions %q, want %q", stored, tt.wantStored)
+			}
+		})
+	}
+}
+
+// mentionEvents returns the users notified of a mention in the post, in the
+// order the events were published
+func mentionEvents(t *testing.T, f *postFixture, postID uuid.UUID) []uuid.UUID {
+	t.Helper()
+	var userIDs []uuid.UUID
+	for _, msg := range f.messaging.PublishedTo(string(events.TypeUserMentioned)) {
+		envelope, err := events.Decode(msg.Data)
+		if err != nil {
+			t.Fatalf("Decode: %v", err)
+		}
+		var payload events.UserMentioned
+		if err := envelope.DecodePayload(&payload); err != nil {
+			t.Fatalf("DecodePayload: %v", err)
+		}
+		if payload.PostID == postID {
+			userIDs = append(userIDs, payload.UserID)
+		}
+	}
+	return userIDs
+}
+
+func sameUsernames(got, want []string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	got = append([]string(nil), got...)
+	want = append([]string(nil), want...)
+	sort.Strings(got)
+	sort.Strings(want)
+	return reflect.DeepEqual(got, want)
+}
+
+func ptr(s string) *string {
+	return &s
+}
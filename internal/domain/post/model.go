@@ -1,31 +1,267 @@
 package post
 
 import (
+	"context"
+	"errors"
 	"time"
 
+	"fowergram-backend/internal/domain/media"
+	"fowergram-backend/internal/events"
+	"fowergram-backend/internal/infra/database"
+
 	"github.com/google/uuid"
 )
 
+// Post statuses; only published posts are shown to other users
+const (
+	StatusDraft     = "draft"
+	StatusScheduled = "scheduled"
+	StatusPublished = "published"
+)
+
+// MaxScheduleAhead is how far in the future a post may be scheduled
+const MaxScheduleAhead = 75 * 24 * time.Hour
+
+// MaxBatchSize is the most posts GetPostsByIDs retrieves in one call
+const MaxBatchSize = 100
+
+// Common post errors
+var (
+	ErrPostNotFound     = errors.New("post not found")
+	ErrMediaNotFound    = errors.New("one or more media files were not found")
+	ErrMediaNotUploaded = errors.New("one or more media files have not finished uploading")
+	ErrInvalidMediaKey  = errors.New("media files must reference your own uploads")
+	ErrTooManyTags      = errors.New("a post can have at most 30 hashtags")
+	ErrNotPostOwner     = errors.New("only the author can modify this post")
+	ErrInvalidCursor    = errors.New("invalid cursor")
+
+	// Draft and schedule errors
+	ErrScheduleInPast   = errors.New("scheduled_at must be in the future")
+	ErrScheduleTooFar   = errors.New("scheduled_at must be within 75 days")
+	ErrDraftScheduled   = errors.New("a draft cannot have scheduled_at")
+	ErrAlreadyPublished = errors.New("post is already published")
+
+	// Repost errors
+	ErrRepostPrivate   = errors.New("private posts cannot be reposted")
+	ErrRepostOwnRepost = errors.New("you cannot repost your own repost")
+	ErrAlreadyReposted = errors.New("you have already reposted this post")
+
+	// ErrInvalidCoordinates is returned for out-of-range coordinates or a
+	// latitude without a longitude
+	ErrInvalidCoordinates = errors.New("latitude must be between -90 and 90 and longitude between -180 and 180, and both must be set together")
+
+	// ErrEmptySearchQuery is returned when a search has no terms
+	ErrEmptySearchQuery = errors.New("search query must not be empty")
+
+	// ErrVersionConflict is returned when an update was based on a stale
+	// version; the client should refetch and retry
+	ErrVersionConflict = errors.New("post was modified by another request")
+
+	// ErrBatchTooLarge is returned when more than MaxBatchSize posts are
+	// requested at once
+	ErrBatchTooLarge = errors.New("at most 100 posts can be requested at once")
+)
+
 // Post represents a post in the system
 type Post struct {
-	ID        uuid.UUID `json:"id" db:"id"`
-	UserID    uuid.UUID `json:"user_id" db:"user_id"`
-	Caption   *string   `json:"caption,omitempty" db:"caption"`
-	Location  *string   `json:"location,omitempty" db:"location"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	ID               uuid.UUID      `json:"id" db:"id"`
+	UserID           uuid.UUID      `json:"user_id" db:"user_id"`
+	Title            string         `json:"title" db:"title"`
+	Content          string         `json:"content" db:"content"`
+	Caption          *string        `json:"caption,omitempty" db:"caption"`
+	Location         *string        `json:"location,omitempty" db:"location"` // Display name of the place
+	Latitude         *float64       `json:"latitude,omitempty" db:"latitude"`
+	Longitude        *float64       `json:"longitude,omitempty" db:"longitude"`
+	Distance         *float64       `json:"distance,omitempty" db:"-"` // Meters from the searched point, set by nearby search
+	IsPrivate        bool           `json:"is_private" db:"is_private"`
+	CommentsDisabled bool           `json:"comments_disabled" db:"comments_disabled"`
+	HideLikeCount    bool           `json:"hide_like_count" db:"hide_like_count"` // Only the author sees LikesCount
+	Media            []*media.Media `json:"media,omitempty" db:"-"`
+	Tags             []string       `json:"tags,omitempty" db:"-"`
+	LikesCount       *int           `json:"likes_count,omitempty" db:"likes_count"` // Nil when the author hid it from the viewer
+	CommentsCount    int            `json:"comments_count" db:"comments_count"`
+	RepostsCount     int            `json:"reposts_count" db:"reposts_count"`
+	OriginalPostID   *uuid.UUID     `json:"original_post_id,omitempty" db:"original_post_id"` // Set on reposts
+	Original         *Post          `json:"original,omitempty" db:"-"`                        // The reposted post, nil when it's no longer available
+	ViewsCount       *int64         `json:"views_count,omitempty" db:"views_count"`           // Only shown to the author
+	ViewerHasSaved   bool           `json:"viewer_has_saved" db:"-"`                          // Set only when read on behalf of a viewer
+	Status           string         `json:"status" db:"status"`
+	ScheduledAt      *time.Time     `json:"scheduled_at,omitempty" db:"scheduled_at"`
+	SearchLanguage   string         `json:"-" db:"search_language"` // Text search configuration the post is indexed with
+	Version          int            `json:"version" db:"version"`
+	CreatedAt        time.Time      `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at" db:"updated_at"`
+}
+
+// AuthorIDs returns the authors of posts and of the posts they repost, for
+// loading them in one batch
+func AuthorIDs(posts []*Post) []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(posts))
+	for _, p := range posts {
+		ids = append(ids, p.UserID)
+		if p.Original != nil {
+			ids = append(ids, p.Original.UserID)
+		}
+	}
+	return ids
+}
+
+// Actor is the user changing a post
+type Actor struct {
+	UserID uuid.UUID
+	Admin  bool // Admins may change posts they don't own
+}
+
+// canModify reports whether actor may edit or delete p
+func (a Actor) canModify(p *Post) bool {
+	return a.Admin || p.UserID == a.UserID
+}
+
+// Liker represents a user who liked a post
+type Liker struct {
+	UserID         uuid.UUID `json:"user_id" db:"user_id"`
+	Username       string    `json:"username" db:"username"`
+	FullName       string    `json:"full_name,omitempty" db:"full_name"`
+	ProfilePicture string    `json:"profile_picture,omitempty" db:"profile_picture"`
+	IsVerified     bool      `json:"is_verified" db:"is_verified"`
+	LikedAt        time.Time `json:"liked_at" db:"created_at"`
+}
+
+// CreatePostInput represents input for creating a new post
+type CreatePostInput struct {
+	Title     string
+	Content   string
+	Caption   *string
+	Location  *string
+	Latitude  *float64 // Set together with Longitude, or not at all
+	Longitude *float64
+	IsPrivate bool
+	MediaKeys []string
+
+	CommentsDisabled bool
+	HideLikeCount    bool
+
+	Tags []string // Explicit tags; hashtags in the caption are added automatically
+
+	// Draft keeps the post unpublished until PublishPost; ScheduledAt
+	// publishes it automatically at that time instead
+	Draft       bool
+	ScheduledAt *time.Time
+}
+
+// UpdatePostInput represents a partial update to a post. Version is the
+// version the client last read; the update fails if the post has moved on.
+type UpdatePostInput struct {
+	Title     *string
+	Content   *string
+	Caption   *string
+	IsPrivate *bool
+	Tags      []string // Replaces explicit tags when non-nil
+
+	CommentsDisabled *bool
+	HideLikeCount    *bool
+
+	Version int
+}
+
+// Tag represents a hashtag with its denormalized post count
+type Tag struct {
+	Name      string `json:"name" db:"name"`
+	PostCount int    `json:"post_count" db:"post_count"`
 }
 
 // Repository defines the interface for post data persistence
 type Repository interface {
-	Create(post *Post) error
-	GetByID(id uuid.UUID) (*Post, error)
-	GetByUserID(userID uuid.UUID) ([]*Post, error)
+	Create(ctx context.Context, post *Post) error
+	GetByID(ctx context.Context, id uuid.UUID) (*Post, error)
+	GetVisibleByID(ctx context.Context, id, viewerID uuid.UUID) (*Post, error)
+	IsVisible(ctx context.Context, id, viewerID uuid.UUID) (bool, error)
+	GetByUserID(ctx context.Context, userID uuid.UUID) ([]*Post, error)
+	Update(ctx context.Context, post *Post, tagsChanged bool) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	HardDelete(ctx context.Context, id uuid.UUID) error
+	ListVisible(ctx context.Context, viewerID uuid.UUID, filter ListPostsFilter, q ListPostsQuery) ([]*Post, error)
+	CountVisible(ctx context.Context, viewerID uuid.UUID, filter ListPostsFilter, mode CountMode) (int64, error)
+	ListNearby(ctx context.Context, viewerID uuid.UUID, q NearbyQuery) ([]*Post, error)
+	Search(ctx context.Context, viewerID uuid.UUID, q SearchQuery) ([]*Post, error)
+
+	// Drafts and scheduled posts
+	GetUnpublished(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*Post, error)
+	Publish(ctx context.Context, post *Post) error
+	PublishDue(ctx context.Context, now time.Time) ([]*Post, error)
+
+	// Reposts
+	CreateRepost(ctx context.Context, post *Post) (bool, error)
+	GetVisibleByIDs(ctx context.Context, ids []uuid.UUID, viewerID uuid.UUID) ([]*Post, error)
+
+	// Home timelines
+	ListTimelineEntries(ctx context.Context, authorIDs []uuid.UUID, maxAuthorFollowers, limit int) ([]TimelineEntry, error)
+
+	// Likes
+	Like(ctx context.Context, postID, userID uuid.UUID) (bool, error)
+	Unlike(ctx context.Context, postID, userID uuid.UUID) (bool, error)
+	GetLikers(ctx context.Context, postID uuid.UUID, limit, offset int) ([]*Liker, error)
+
+	// Saved posts
+	Save(ctx context.Context, postID, userID uuid.UUID) error
+	Unsave(ctx context.Context, postID, userID uuid.UUID) error
+	GetSaved(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*Post, error)
+	MarkSaved(ctx context.Context, userID uuid.UUID, posts []*Post) error
+
+	// Views
+	AddViews(ctx context.Context, counts map[uuid.UUID]int64) error
+
+	// Tags
+	GetVisibleByTag(ctx context.Context, tag string, viewerID uuid.UUID, limit, offset int) ([]*Post, error)
+	SearchTags(ctx context.Context, prefix string, limit int) ([]*Tag, error)
+
+	// Mentions
+	AddMentions(ctx context.Context, authorID, postID uuid.UUID, commentID *uuid.UUID, usernames []string, createdAt time.Time) ([]uuid.UUID, error)
+	SyncMentions(ctx context.Context, authorID, postID uuid.UUID, usernames []string, createdAt time.Time) ([]uuid.UUID, error)
+
+	// WithTx returns the repository bound to tx, to compose its writes with
+	// other repositories' in one database.WithTx transaction
+	WithTx(tx database.DB) Repository
 }
 
 // Service defines the interface for post business logic
 type Service interface {
-	CreatePost(userID uuid.UUID, caption, location *string) (*Post, error)
-	GetPost(id uuid.UUID) (*Post, error)
-	GetUserPosts(userID uuid.UUID) ([]*Post, error)
+	CreatePost(ctx context.Context, userID uuid.UUID, input CreatePostInput) (*Post, error)
+	GetPost(ctx context.Context, id, viewerID uuid.UUID) (*Post, error)
+	GetPostsByIDs(ctx context.Context, ids []uuid.UUID, viewerID uuid.UUID) ([]*Post, error)
+	UpdatePost(ctx context.Context, id uuid.UUID, actor Actor, input UpdatePostInput) (*Post, error)
+	DeletePost(ctx context.Context, id uuid.UUID, actor Actor) error
+	HardDeletePost(ctx context.Context, id uuid.UUID) error
+	ListPosts(ctx context.Context, viewerID uuid.UUID, filter ListPostsFilter, q ListPostsQuery) (*Page, error)
+	ListNearbyPosts(ctx context.Context, viewerID uuid.UUID, q NearbyQuery) ([]*Post, error)
+	SearchPosts(ctx context.Context, viewerID uuid.UUID, q SearchQuery) ([]*Post, error)
+	GetUserPosts(ctx context.Context, userID uuid.UUID) ([]*Post, error)
+
+	// Drafts and scheduled posts
+	GetDrafts(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*Post, error)
+	PublishPost(ctx context.Context, id, userID uuid.UUID) (*Post, error)
+	PublishDuePosts(ctx context.Context) (int, error)
+
+	// Reposts
+	RepostPost(ctx context.Context, postID, userID uuid.UUID, caption *string) (*Post, error)
+
+	// Feed
+	GetFeed(ctx context.Context, viewerID uuid.UUID, q ListPostsQuery) (*Page, error)
+	SubscribeFeed(ctx context.Context, viewerID uuid.UUID, handler func(events.PostCreated)) (cancel func(), err error)
+	FanOutPost(ctx context.Context, event events.PostCreated) error
+	BackfillTimeline(ctx context.Context, followerID, followingID uuid.UUID) error
+
+	// Likes
+	LikePost(ctx context.Context, postID, userID uuid.UUID) error
+	UnlikePost(ctx context.Context, postID, userID uuid.UUID) error
+	GetLikers(ctx context.Context, postID, viewerID uuid.UUID, limit, offset int) ([]*Liker, error)
+
+	// Saved posts
+	SavePost(ctx context.Context, postID, userID uuid.UUID) error
+	UnsavePost(ctx context.Context, postID, userID uuid.UUID) error
+	GetSavedPosts(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*Post, error)
+
+	// Tags
+	GetTagPosts(ctx context.Context, tag string, viewerID uuid.UUID, limit, offset int) ([]*Post, error)
+	SearchTags(ctx context.Context, query string, limit int) ([]*Tag, error)
 }
@@ -0,0 +1,119 @@
+package post
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// Nearby search radius bounds, in meters
+const (
+	DefaultNearbyRadius = 5000
+	MaxNearbyRadius     = 50000
+)
+
+// NearbyQuery selects posts within RadiusMeters of a point, closest first
+type NearbyQuery struct {
+	Latitude     float64
+	Longitude    float64
+	RadiusMeters float64
+	Limit        int
+	Offset       int
+}
+
+// ListNearby retrieves published posts with coordinates within the radius
+// that the viewer may see, closest first, with Distance set. Posts of
+// private accounts are only returned to their followers.
+func (r *postgresRepository) ListNearby(ctx context.Context, viewerID uuid.UUID, q NearbyQuery) ([]*Post, error) {
+	// earth_box narrows candidates through the GiST index; earth_distance
+	// trims the box corners to the actual radius
+	query := `SELECT ` + postColumns + `,
+			earth_distance(ll_to_earth($2, $3), ll_to_earth(p.latitude, p.longitude)) AS distance
+		FROM posts p
+		JOIN users u ON u.id = p.user_id
+		WHERE p.latitude IS NOT NULL AND p.deleted_at IS NULL AND u.is_active = true
+			AND earth_box(ll_to_earth($2, $3), $4) @> ll_to_earth(p.latitude, p.longitude)
+			AND earth_distance(ll_to_earth($2, $3), ll_to_earth(p.latitude, p.longitude)) <= $4
+			AND ` + publishedClause + ` AND ` + visibilityClause("$1") + `
+		ORDER BY distance, p.id
+		LIMIT $5 OFFSET $6
+	`
+
+	rows, err := r.db.Query(ctx, query, viewerID, q.Latitude, q.Longitude, q.RadiusMeters, q.Limit, q.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nearby posts: %w", err)
+	}
+	defer rows.Close()
+
+	var posts []*Post
+	for rows.Next() {
+		var distance float64
+		post, err := scanPost(rows, &distance)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan post: %w", err)
+		}
+		post.Distance = &distance
+		posts = append(posts, post)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate nearby posts: %w", err)
+	}
+
+	if err := r.loadDetails(ctx, posts); err != nil {
+		return nil, err
+	}
+
+	return posts, nil
+}
+
+// ListNearbyPosts lists posts near a point that the viewer can see
+func (s *service) ListNearbyPosts(ctx context.Context, viewerID uuid.UUID, q NearbyQuery) ([]*Post, error) {
+	if err := validateCoordinates(&q.Latitude, &q.Longitude); err != nil {
+		return nil, err
+	}
+	if q.RadiusMeters <= 0 {
+		q.RadiusMeters = DefaultNearbyRadius
+	}
+	if q.RadiusMeters > MaxNearbyRadius {
+		q.RadiusMeters = MaxNearbyRadius
+	}
+
+	posts, err := s.repo.ListNearby(ctx, viewerID, q)
+	if err != nil {
+		return nil, err
+	}
+	hideOwnerOnlyCounts(viewerID, posts)
+
+	if err := s.repo.MarkSaved(ctx, viewerID, posts); err != nil {
+		return nil, err
+	}
+
+	if err := s.attachOriginals(ctx, viewerID, posts); err != nil {
+		return nil, err
+	}
+
+	for _, post := range posts {
+		if err := s.resolveMediaURLs(ctx, post); err != nil {
+			return nil, err
+		}
+	}
+
+	return posts, nil
+}
+
+// validateCoordinates checks that coordinates are both set or both unset,
+// and within range
+func validateCoordinates(lat, lng *float64) error {
+	if lat == nil && lng == nil {
+		return nil
+	}
+	if lat == nil || lng == nil {
+		return ErrInvalidCoordinates
+	}
+	if *lat < -90 || *lat > 90 || *lng < -180 || *lng > 180 {
+		return ErrInvalidCoordinates
+	}
+	return nil
+}
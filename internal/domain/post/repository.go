@@ -1,13 +1,21 @@
 package post
 
 import (
+	"context"
+	"fmt"
+	"time"
+
+	"fowergram-backend/internal/domain/media"
+	"fowergram-backend/internal/infra/database"
+
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 // postgresRepository implements Repository using PostgreSQL
 type postgresRepository struct {
-	db *pgxpool.Pool
+	db database.DB
 }
 
 // NewRepository creates a new PostgreSQL post repository
@@ -15,20 +23,434 @@ func NewRepository(db *pgxpool.Pool) Repository {
 	return &postgresRepository{db: db}
 }
 
-// Create creates a new post in the database
-func (r *postgresRepository) Create(post *Post) error {
-	// Implementation would create post in database
+// WithTx returns the repository running its queries on tx
+func (r *postgresRepository) WithTx(tx database.DB) Repository {
+	return &postgresRepository{db: tx}
+}
+
+// Create creates a new post and its media attachments in the database
+func (r *postgresRepository) Create(ctx context.Context, post *Post) error {
+	return database.WithTx(ctx, r.db, func(tx database.DB) error {
+		insertPostQuery := `
+			INSERT INTO posts (
+				id, user_id, title, content, caption, location, latitude, longitude,
+				is_private, comments_disabled, hide_like_count,
+				status, scheduled_at, search_language, created_at, updated_at
+			) VALUES (
+				$1, $2, $3, $4, $5, $6, $7, $8,
+				$9, $10, $11,
+				$12, $13, $14, $15, $16
+			)
+		`
+		_, err := tx.Exec(ctx, insertPostQuery,
+			post.ID, post.UserID, post.Title, post.Content, post.Caption, post.Location, post.Latitude, post.Longitude,
+			post.IsPrivate, post.CommentsDisabled, post.HideLikeCount,
+			post.Status, post.ScheduledAt, post.SearchLanguage, post.CreatedAt, post.UpdatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to create post: %w", err)
+		}
+
+		insertMediaQuery := `
+			INSERT INTO post_media (
+				post_id, media_id, media_url, media_type, width, height,
+				file_size, display_order, created_at
+			) VALUES (
+				$1, $2, $3, 'image', $4, $5,
+				$6, $7, $8
+			)
+		`
+		for i, m := range post.Media {
+			_, err = tx.Exec(ctx, insertMediaQuery,
+				post.ID, m.ID, m.OriginalKey, m.Width, m.Height,
+				m.FileSize, i, post.CreatedAt,
+			)
+			if err != nil {
+				return fmt.Errorf("failed to attach post media: %w", err)
+			}
+		}
+
+		if err := insertTags(ctx, tx, post); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+// HardDelete permanently removes a post, soft-deleted or not, with its likes
+// and comments; uploaded media stays with its owner. Counters a live post
+// contributes to are corrected first, a soft-deleted one had them corrected
+// when it was deleted.
+func (r *postgresRepository) HardDelete(ctx context.Context, id uuid.UUID) error {
+	return database.WithTx(ctx, r.db, func(tx database.DB) error {
+		query := `
+			SELECT original_post_id, deleted_at IS NULL
+			FROM posts
+			WHERE id = $1
+			FOR UPDATE
+		`
+
+		var (
+			originalID *uuid.UUID
+			live       bool
+		)
+		if err := tx.QueryRow(ctx, query, id).Scan(&originalID, &live); err != nil {
+			if err == pgx.ErrNoRows {
+				return ErrPostNotFound
+			}
+			return fmt.Errorf("failed to get post: %w", err)
+		}
+
+		if live {
+			if err := deleteTags(ctx, tx, id); err != nil {
+				return err
+			}
+			if originalID != nil {
+				counterQuery := `
+					UPDATE posts SET reposts_count = GREATEST(reposts_count - 1, 0)
+					WHERE id = $1
+				`
+				if _, err := tx.Exec(ctx, counterQuery, *originalID); err != nil {
+					return fmt.Errorf("failed to update repost count: %w", err)
+				}
+			}
+		}
+
+		// Shared messages keep their text; the post they point at is gone
+		if _, err := tx.Exec(ctx, `UPDATE messages SET shared_post_id = NULL WHERE shared_post_id = $1`, id); err != nil {
+			return fmt.Errorf("failed to unlink shared messages: %w", err)
+		}
+
+		// Likes, comments and media links are removed by ON DELETE CASCADE, and
+		// posts_count is corrected by its trigger
+		if _, err := tx.Exec(ctx, `DELETE FROM posts WHERE id = $1`, id); err != nil {
+			return fmt.Errorf("failed to delete post: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// Update writes a post's editable fields if the row is still at post.Version,
+// then bumps the version. When tagsChanged is set, the stored tags are replaced
+// with post.Tags in the same transaction.
+func (r *postgresRepository) Update(ctx context.Context, post *Post, tagsChanged bool) error {
+	updatedAt := time.Now()
+	err := database.WithTx(ctx, r.db, func(tx database.DB) error {
+		query := `
+			UPDATE posts SET
+				title = $1,
+				content = $2,
+				caption = $3,
+				is_private = $4,
+				comments_disabled = $5,
+				hide_like_count = $6,
+				updated_at = $7,
+				version = version + 1
+			WHERE id = $8 AND version = $9 AND deleted_at IS NULL
+			RETURNING version
+		`
+
+		err := tx.QueryRow(ctx, query,
+			post.Title, post.Content, post.Caption, post.IsPrivate,
+			post.CommentsDisabled, post.HideLikeCount, updatedAt,
+			post.ID, post.Version,
+		).Scan(&post.Version)
+		if err != nil {
+			if err == pgx.ErrNoRows {
+				return ErrVersionConflict
+			}
+			return fmt.Errorf("failed to update post: %w", err)
+		}
+
+		if tagsChanged {
+			if err := deleteTags(ctx, tx, post.ID); err != nil {
+				return err
+			}
+			if err := insertTags(ctx, tx, post); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	post.UpdatedAt = updatedAt
 	return nil
 }
 
+// Delete soft-deletes a post, releasing its tags and, for a repost, the
+// original's repost count
+func (r *postgresRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	return database.WithTx(ctx, r.db, func(tx database.DB) error {
+		query := `
+			UPDATE posts SET deleted_at = NOW()
+			WHERE id = $1 AND deleted_at IS NULL
+			RETURNING original_post_id
+		`
+
+		var originalID *uuid.UUID
+		if err := tx.QueryRow(ctx, query, id).Scan(&originalID); err != nil {
+			if err == pgx.ErrNoRows {
+				return ErrPostNotFound
+			}
+			return fmt.Errorf("failed to delete post: %w", err)
+		}
+
+		if err := deleteTags(ctx, tx, id); err != nil {
+			return err
+		}
+
+		if originalID != nil {
+			counterQuery := `
+				UPDATE posts SET reposts_count = GREATEST(reposts_count - 1, 0)
+				WHERE id = $1
+			`
+			if _, err := tx.Exec(ctx, counterQuery, *originalID); err != nil {
+				return fmt.Errorf("failed to update repost count: %w", err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// postColumns lists the post columns read by scanPost, for a posts table aliased as p
+const postColumns = `
+	p.id, p.user_id, COALESCE(p.title, ''), COALESCE(p.content, ''), p.caption, p.location,
+	p.latitude, p.longitude,
+	p.is_private, p.comments_disabled, p.hide_like_count, p.likes_count, p.comments_count, p.reposts_count, p.views_count,
+	p.status, p.scheduled_at, p.original_post_id, p.version, p.created_at, p.updated_at`
+
+// publishedClause restricts posts (aliased p) to published ones; listings use
+// it so unpublished posts never show up, not even the viewer's own
+const publishedClause = `p.status = 'published'`
+
+// visibilityClause restricts posts (aliased p, author aliased u) to those the
+// viewer bound at the given placeholder may see: their own posts, or published
+// posts of authors with no block in either direction that are public or followed
+func visibilityClause(viewerParam string) string {
+	return fmt.Sprintf(`(
+		p.user_id = %[1]s OR (
+			p.status = 'published'
+			AND NOT EXISTS (
+				SELECT 1 FROM blocks b
+				WHERE (b.blocker_id = p.user_id AND b.blocked_id = %[1]s)
+				   OR (b.blocker_id = %[1]s AND b.blocked_id = p.user_id)
+			)
+			AND (
+				(NOT p.is_private AND NOT u.is_private)
+				OR EXISTS (
+					SELECT 1 FROM followers f
+					WHERE f.follower_id = %[1]s AND f.following_id = p.user_id
+				)
+			)
+		)
+	)`, viewerParam)
+}
+
 // GetByID retrieves a post by ID
-func (r *postgresRepository) GetByID(id uuid.UUID) (*Post, error) {
-	// Implementation would get post from database
-	return nil, nil
+func (r *postgresRepository) GetByID(ctx context.Context, id uuid.UUID) (*Post, error) {
+	query := `SELECT ` + postColumns + `
+		FROM posts p
+		WHERE p.id = $1 AND p.deleted_at IS NULL
+	`
+
+	post, err := scanPost(r.db.QueryRow(ctx, query, id))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrPostNotFound
+		}
+		return nil, fmt.Errorf("failed to get post: %w", err)
+	}
+
+	if err := r.loadDetails(ctx, []*Post{post}); err != nil {
+		return nil, err
+	}
+
+	return post, nil
+}
+
+// GetVisibleByID retrieves a post by ID if the viewer is allowed to see it
+func (r *postgresRepository) GetVisibleByID(ctx context.Context, id, viewerID uuid.UUID) (*Post, error) {
+	query := `SELECT ` + postColumns + `
+		FROM posts p
+		JOIN users u ON u.id = p.user_id
+		WHERE p.id = $1 AND p.deleted_at IS NULL AND u.is_active = true
+			AND ` + visibilityClause("$2")
+
+	post, err := scanPost(r.db.QueryRow(ctx, query, id, viewerID))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrPostNotFound
+		}
+		return nil, fmt.Errorf("failed to get post: %w", err)
+	}
+
+	if err := r.loadDetails(ctx, []*Post{post}); err != nil {
+		return nil, err
+	}
+
+	return post, nil
 }
 
 // GetByUserID retrieves posts by user ID
-func (r *postgresRepository) GetByUserID(userID uuid.UUID) ([]*Post, error) {
-	// Implementation would get posts from database
-	return nil, nil
+func (r *postgresRepository) GetByUserID(ctx context.Context, userID uuid.UUID) ([]*Post, error) {
+	query := `SELECT ` + postColumns + `
+		FROM posts p
+		WHERE p.user_id = $1 AND p.deleted_at IS NULL AND ` + publishedClause + `
+		ORDER BY p.created_at DESC
+	`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get posts: %w", err)
+	}
+	defer rows.Close()
+
+	var posts []*Post
+	for rows.Next() {
+		post, err := scanPost(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan post: %w", err)
+		}
+		posts = append(posts, post)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate posts: %w", err)
+	}
+
+	if err := r.loadDetails(ctx, posts); err != nil {
+		return nil, err
+	}
+
+	return posts, nil
+}
+
+// loadDetails attaches media and tags to the given posts
+func (r *postgresRepository) loadDetails(ctx context.Context, posts []*Post) error {
+	if err := r.loadMedia(ctx, posts); err != nil {
+		return err
+	}
+	return r.loadTags(ctx, posts)
+}
+
+// loadMedia attaches processed media to the given posts with a single query
+func (r *postgresRepository) loadMedia(ctx context.Context, posts []*Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+
+	byID := make(map[uuid.UUID]*Post, len(posts))
+	ids := make([]uuid.UUID, 0, len(posts))
+	for _, p := range posts {
+		byID[p.ID] = p
+		ids = append(ids, p.ID)
+	}
+
+	query := `
+		SELECT pm.post_id, m.id, m.user_id, m.original_key, m.feed_key, m.thumbnail_key,
+			   m.content_type, COALESCE(m.width, 0), COALESCE(m.height, 0), COALESCE(m.file_size, 0),
+			   m.status, m.created_at, m.processed_at
+		FROM post_media pm
+		JOIN media m ON m.id = pm.media_id
+		WHERE pm.post_id = ANY($1)
+		ORDER BY pm.post_id, pm.display_order
+	`
+
+	rows, err := r.db.Query(ctx, query, ids)
+	if err != nil {
+		return fmt.Errorf("failed to get post media: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var postID uuid.UUID
+		m := &media.Media{}
+		err := rows.Scan(
+			&postID,
+			&m.ID,
+			&m.UserID,
+			&m.OriginalKey,
+			&m.FeedKey,
+			&m.ThumbnailKey,
+			&m.ContentType,
+			&m.Width,
+			&m.Height,
+			&m.FileSize,
+			&m.Status,
+			&m.CreatedAt,
+			&m.ProcessedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to scan post media: %w", err)
+		}
+		if p, ok := byID[postID]; ok {
+			p.Media = append(p.Media, m)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to iterate post media: %w", err)
+	}
+
+	return nil
+}
+
+// scanPost scans a single row selected with postColumns, followed by any
+// extra columns into extra
+func scanPost(row pgx.Row, extra ...interface{}) (*Post, error) {
+	var post Post
+	dest := []interface{}{
+		&post.ID,
+		&post.UserID,
+		&post.Title,
+		&post.Content,
+		&post.Caption,
+		&post.Location,
+		&post.Latitude,
+		&post.Longitude,
+		&post.IsPrivate,
+		&post.CommentsDisabled,
+		&post.HideLikeCount,
+		&post.LikesCount,
+		&post.CommentsCount,
+		&post.RepostsCount,
+		&post.ViewsCount,
+		&post.Status,
+		&post.ScheduledAt,
+		&post.OriginalPostID,
+		&post.Version,
+		&post.CreatedAt,
+		&post.UpdatedAt,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	return &post, nil
+}
+
+// scanPosts scans and closes rows selected with postColumns
+func scanPosts(rows pgx.Rows) ([]*Post, error) {
+	defer rows.Close()
+
+	var posts []*Post
+	for rows.Next() {
+		post, err := scanPost(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan post: %w", err)
+		}
+		posts = append(posts, post)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate posts: %w", err)
+	}
+
+	return posts, nil
 }
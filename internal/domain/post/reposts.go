@@ -0,0 +1,215 @@
+package post
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"fowergram-backend/internal/infra/database"
+
+	"github.com/google/uuid"
+)
+
+// CreateRepost inserts a repost of post.OriginalPostID and bumps the
+// original's repost count. It reports false, writing nothing, when the user
+// already reposted that post.
+func (r *postgresRepository) CreateRepost(ctx context.Context, post *Post) (bool, error) {
+	var created bool
+	err := database.WithTx(ctx, r.db, func(tx database.DB) error {
+		query := `
+			INSERT INTO posts (
+				id, user_id, title, content, caption, is_private,
+				status, original_post_id, search_language, created_at, updated_at
+			) VALUES (
+				$1, $2, '', '', $3, false,
+				'published', $4, $5, $6, $7
+			)
+			ON CONFLICT (user_id, original_post_id)
+				WHERE original_post_id IS NOT NULL AND deleted_at IS NULL
+				DO NOTHING
+		`
+
+		tag, err := tx.Exec(ctx, query,
+			post.ID, post.UserID, post.Caption, post.OriginalPostID, post.SearchLanguage, post.CreatedAt, post.UpdatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to create repost: %w", err)
+		}
+		if tag.RowsAffected() == 0 {
+			return nil
+		}
+
+		counterQuery := `
+			UPDATE posts SET reposts_count = reposts_count + 1
+			WHERE id = $1
+		`
+		if _, err := tx.Exec(ctx, counterQuery, post.OriginalPostID); err != nil {
+			return fmt.Errorf("failed to update repost count: %w", err)
+		}
+
+		if err := insertTags(ctx, tx, post); err != nil {
+			return err
+		}
+
+		created = true
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return created, nil
+}
+
+// GetVisibleByIDs retrieves the published posts among ids that the viewer may
+// see. Missing, deleted and hidden posts are left out.
+func (r *postgresRepository) GetVisibleByIDs(ctx context.Context, ids []uuid.UUID, viewerID uuid.UUID) ([]*Post, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	query := `SELECT ` + postColumns + `
+		FROM posts p
+		JOIN users u ON u.id = p.user_id
+		WHERE p.id = ANY($1) AND p.deleted_at IS NULL AND u.is_active = true
+			AND ` + publishedClause + ` AND ` + visibilityClause("$2")
+
+	rows, err := r.db.Query(ctx, query, ids, viewerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get posts: %w", err)
+	}
+
+	posts, err := scanPosts(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.loadDetails(ctx, posts); err != nil {
+		return nil, err
+	}
+
+	return posts, nil
+}
+
+// RepostPost reposts a post the user can see, optionally quoting it with a
+// caption. Reposting someone else's repost reposts its original.
+func (s *service) RepostPost(ctx context.Context, postID, userID uuid.UUID, caption *string) (*Post, error) {
+	original, err := s.repo.GetVisibleByID(ctx, postID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if original.OriginalPostID != nil {
+		if original.UserID == userID {
+			return nil, ErrRepostOwnRepost
+		}
+		original, err = s.repo.GetVisibleByID(ctx, *original.OriginalPostID, userID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Drafts are only visible to their author, who can't repost them either
+	if original.Status != StatusPublished {
+		return nil, ErrPostNotFound
+	}
+
+	if original.IsPrivate {
+		return nil, ErrRepostPrivate
+	}
+	author, err := s.userRepo.GetUserByID(ctx, original.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if author.IsPrivate {
+		return nil, ErrRepostPrivate
+	}
+
+	tags := mergeTags(nil, derefString(caption))
+	if len(tags) > MaxTagsPerPost {
+		return nil, ErrTooManyTags
+	}
+
+	now := time.Now()
+	var likes int
+	var views int64
+	repost := &Post{
+		ID:             uuid.New(),
+		UserID:         userID,
+		Caption:        caption,
+		Tags:           tags,
+		OriginalPostID: &original.ID,
+		LikesCount:     &likes,
+		ViewsCount:     &views,
+		Status:         StatusPublished,
+		SearchLanguage: s.searchLanguage,
+		Version:        1,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+
+	var mentioned []uuid.UUID
+	err = database.WithTx(ctx, s.db, func(tx database.DB) error {
+		repo := s.repo.WithTx(tx)
+		created, err := repo.CreateRepost(ctx, repost)
+		if err != nil {
+			return err
+		}
+		if !created {
+			return ErrAlreadyReposted
+		}
+		mentioned, err = addCaptionMentions(ctx, repo, repost)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	original.RepostsCount++
+	s.invalidatePosts(ctx, original.ID)
+
+	s.publishCreated(ctx, repost, mentioned)
+
+	if err := s.resolveMediaURLs(ctx, original); err != nil {
+		return nil, err
+	}
+	hideOwnerOnlyCounts(userID, []*Post{original})
+	repost.Original = original
+
+	return repost, nil
+}
+
+// attachOriginals sets Original on reposts to the reposted post, if the
+// viewer can still see it; otherwise Original stays nil
+func (s *service) attachOriginals(ctx context.Context, viewerID uuid.UUID, posts []*Post) error {
+	var ids []uuid.UUID
+	for _, post := range posts {
+		if post.OriginalPostID != nil {
+			ids = append(ids, *post.OriginalPostID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	originals, err := s.repo.GetVisibleByIDs(ctx, ids, viewerID)
+	if err != nil {
+		return err
+	}
+	hideOwnerOnlyCounts(viewerID, originals)
+
+	byID := make(map[uuid.UUID]*Post, len(originals))
+	for _, original := range originals {
+		if err := s.resolveMediaURLs(ctx, original); err != nil {
+			return err
+		}
+		byID[original.ID] = original
+	}
+
+	for _, post := range posts {
+		if post.OriginalPostID != nil {
+			post.Original = byID[*post.OriginalPostID]
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package post
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Save bookmarks a post for the user; saving twice is a no-op
+func (r *postgresRepository) Save(ctx context.Context, postID, userID uuid.UUID) error {
+	query := `
+		INSERT INTO saved_posts (user_id, post_id, created_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, post_id) DO NOTHING
+	`
+
+	if _, err := r.db.Exec(ctx, query, userID, postID, time.Now()); err != nil {
+		return fmt.Errorf("failed to save post: %w", err)
+	}
+
+	return nil
+}
+
+// Unsave removes a bookmark; removing a missing bookmark is a no-op
+func (r *postgresRepository) Unsave(ctx context.Context, postID, userID uuid.UUID) error {
+	query := `
+		DELETE FROM saved_posts
+		WHERE user_id = $1 AND post_id = $2
+	`
+
+	if _, err := r.db.Exec(ctx, query, userID, postID); err != nil {
+		return fmt.Errorf("failed to unsave post: %w", err)
+	}
+
+	return nil
+}
+
+// GetSaved retrieves the user's saved posts, most recently saved first.
+// Posts the user can no longer see are left out rather than reported.
+func (r *postgresRepository) GetSaved(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*Post, error) {
+	query := `SELECT ` + postColumns + `
+		FROM saved_posts sp
+		JOIN posts p ON p.id = sp.post_id
+		JOIN users u ON u.id = p.user_id
+		WHERE sp.user_id = $1 AND p.deleted_at IS NULL AND u.is_active = true
+			AND ` + publishedClause + ` AND ` + visibilityClause("$1") + `
+		ORDER BY sp.created_at DESC, sp.post_id DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	rows, err := r.db.Query(ctx, query, userID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get saved posts: %w", err)
+	}
+	defer rows.Close()
+
+	var posts []*Post
+	for rows.Next() {
+		post, err := scanPost(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan post: %w", err)
+		}
+		post.ViewerHasSaved = true
+		posts = append(posts, post)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate saved posts: %w", err)
+	}
+
+	if err := r.loadDetails(ctx, posts); err != nil {
+		return nil, err
+	}
+
+	return posts, nil
+}
+
+// MarkSaved sets ViewerHasSaved on the given posts with a single query
+func (r *postgresRepository) MarkSaved(ctx context.Context, userID uuid.UUID, posts []*Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+
+	byID := make(map[uuid.UUID]*Post, len(posts))
+	ids := make([]uuid.UUID, 0, len(posts))
+	for _, p := range posts {
+		byID[p.ID] = p
+		ids = append(ids, p.ID)
+	}
+
+	query := `
+		SELECT post_id FROM saved_posts
+		WHERE user_id = $1 AND post_id = ANY($2)
+	`
+
+	rows, err := r.db.Query(ctx, query, userID, ids)
+	if err != nil {
+		return fmt.Errorf("failed to get saved status: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var postID uuid.UUID
+		if err := rows.Scan(&postID); err != nil {
+			return fmt.Errorf("failed to scan saved status: %w", err)
+		}
+		if p, ok := byID[postID]; ok {
+			p.ViewerHasSaved = true
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to iterate saved status: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,91 @@
+package post
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestSavedPosts(t *testing.T) {
+	f := newPostFixture(t)
+	ctx := context.Background()
+	alice, bob := uuid.New(), uuid.New()
+	first := f.createPost(t, alice, "first")
+	second := f.createPost(t, alice, "second")
+	liked := f.createPost(t, alice, "liked")
+	removed := f.createPost(t, alice, "removed")
+
+	steps := []struct {
+		name string
+		run  func() error
+	}{
+		{"alice saves the first post", func() error { return f.service.SavePost(ctx, first.ID, alice) }},
+		{"alice saves the second post", func() error { return f.service.SavePost(ctx, second.ID, alice) }},
+		{"alice saves the first post again", func() error { return f.service.SavePost(ctx, first.ID, alice) }},
+		{"alice saves a post", func() error { return f.service.SavePost(ctx, removed.ID, alice) }},
+		{"alice unsaves it", func() error { return f.service.UnsavePost(ctx, removed.ID, alice) }},
+		{"alice unsaves it again", func() error { return f.service.UnsavePost(ctx, removed.ID, alice) }},
+		{"alice likes a post", func() error { return f.service.LikePost(ctx, liked.ID, alice) }},
+		{"bob saves the liked post", func() error { return f.service.SavePost(ctx, liked.ID, bob) }},
+	}
+	for _, step := range steps {
+		if err := step.run(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		userID uuid.UUID
+		want   []uuid.UUID
+	}{
+		{name: "newest first, saving twice keeps the first save", userID: alice, want: []uuid.UUID{second.ID, first.ID}},
+		{name: "only the user's own saves", userID: bob, want: []uuid.UUID{liked.ID}},
+		{name: "no saves", userID: uuid.New(), want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			posts, err := f.service.GetSavedPosts(ctx, tt.userID, 20, 0)
+			if err != nil {
+				t.Fatalf("GetSavedPosts: %v", err)
+			}
+			var got []uuid.UUID
+			for _, post := range posts {
+				got = append(got, post.ID)
+				if !post.ViewerHasSaved {
+					t.Errorf("saved post %s has ViewerHasSaved unset", post.ID)
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("saved posts = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("saves are kept apart from likes", func(t *testing.T) {
+		post, err := f.service.GetPost(ctx, liked.ID, alice)
+		if err != nil {
+			t.Fatalf("GetPost: %v", err)
+		}
+		if post.ViewerHasSaved {
+			t.Error("liking a post saved it")
+		}
+		if post.LikesCount == nil || *post.LikesCount != 1 {
+			t.Errorf("likes count = %v, want 1", post.LikesCount)
+		}
+
+		saved, err := f.service.GetPost(ctx, first.ID, alice)
+		if err != nil {
+			t.Fatalf("GetPost: %v", err)
+		}
+		if !saved.ViewerHasSaved {
+			t.Error("saved post has ViewerHasSaved unset")
+		}
+		if saved.LikesCount != nil && *saved.LikesCount != 0 {
+			t.Errorf("saving a post liked it: likes count = %d", *saved.LikesCount)
+		}
+	})
+}
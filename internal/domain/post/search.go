@@ -0,0 +1,83 @@
+package post
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// SearchQuery selects posts matching free text, best match first
+type SearchQuery struct {
+	Text     string // Web search syntax: quoted phrases, OR and -exclusions
+	Language string // Text search configuration to parse Text with; defaults to the service's
+	Limit    int
+	Offset   int
+}
+
+// Search retrieves published posts matching the query that the viewer may
+// see, ranked by relevance. Private posts are only returned to their author,
+// even when the viewer follows them.
+func (r *postgresRepository) Search(ctx context.Context, viewerID uuid.UUID, q SearchQuery) ([]*Post, error) {
+	query := `SELECT ` + postColumns + `
+		FROM posts p
+		JOIN users u ON u.id = p.user_id,
+			websearch_to_tsquery($2::regconfig, $3) AS q
+		WHERE p.search_vector @@ q AND p.deleted_at IS NULL AND u.is_active = true
+			AND (p.user_id = $1 OR NOT p.is_private)
+			AND ` + publishedClause + ` AND ` + visibilityClause("$1") + `
+		ORDER BY ts_rank_cd(p.search_vector, q) DESC, p.created_at DESC, p.id
+		LIMIT $4 OFFSET $5
+	`
+
+	rows, err := r.db.Query(ctx, query, viewerID, q.Language, q.Text, q.Limit, q.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search posts: %w", err)
+	}
+	defer rows.Close()
+
+	posts, err := scanPosts(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.loadDetails(ctx, posts); err != nil {
+		return nil, err
+	}
+
+	return posts, nil
+}
+
+// SearchPosts searches posts the viewer can see by their title, caption and content
+func (s *service) SearchPosts(ctx context.Context, viewerID uuid.UUID, q SearchQuery) ([]*Post, error) {
+	q.Text = strings.TrimSpace(q.Text)
+	if q.Text == "" {
+		return nil, ErrEmptySearchQuery
+	}
+	if q.Language == "" {
+		q.Language = s.searchLanguage
+	}
+
+	posts, err := s.repo.Search(ctx, viewerID, q)
+	if err != nil {
+		return nil, err
+	}
+	hideOwnerOnlyCounts(viewerID, posts)
+
+	if err := s.repo.MarkSaved(ctx, viewerID, posts); err != nil {
+		return nil, err
+	}
+
+	if err := s.attachOriginals(ctx, viewerID, posts); err != nil {
+		return nil, err
+	}
+
+	for _, post := range posts {
+		if err := s.resolveMediaURLs(ctx, post); err != nil {
+			return nil, err
+		}
+	}
+
+	return posts, nil
+}
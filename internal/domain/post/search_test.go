@@ -0,0 +1,103 @@
+package post
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"fowergram-backend/internal/infra/database/dbtest"
+
+	"github.com/google/uuid"
+)
+
+func TestSearchPosts(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.MigratedPool(t)
+	repo := NewRepository(db)
+
+	addUser := func(username string) uuid.UUID {
+		t.Helper()
+		id := uuid.New()
+		_, err := db.Exec(ctx, `INSERT INTO users (id, email, username) VALUES ($1, $2, $3)`, id, username+"@example.com", username)
+		if err != nil {
+			t.Fatalf("adding %s: %v", username, err)
+		}
+		return id
+	}
+	authorID, viewerID := addUser("author"), addUser("viewer")
+
+	// Posts are created a minute apart, oldest first
+	created := time.Now().Add(-time.Hour)
+	addPost := func(title, caption, content, status string, private bool) uuid.UUID {
+		t.Helper()
+		created = created.Add(time.Minute)
+		p := &Post{
+			ID:             uuid.New(),
+			UserID:         authorID,
+			Title:          title,
+			Caption:        ptr(caption),
+			Content:        content,
+			IsPrivate:      private,
+			Status:         status,
+			SearchLanguage: "english",
+			CreatedAt:      created,
+			UpdatedAt:      created,
+		}
+		if err := repo.Create(ctx, p); err != nil {
+			t.Fatalf("creating %q: %v", title, err)
+		}
+		return p.ID
+	}
+	inContent := addPost("Weekend", "", "Planted tulips in the garden", StatusPublished, false)
+	inTitle := addPost("Garden tour", "Roses everywhere", "", StatusPublished, false)
+	addPost("City lights", "", "Downtown at night", StatusPublished, false)
+	private := addPost("Secret garden", "", "", StatusPublished, true)
+	addPost("Garden plans", "", "", StatusDraft, false)
+
+	// The viewer following the author doesn't reveal the private post
+	if _, err := db.Exec(ctx, `INSERT INTO followers (follower_id, following_id) VALUES ($1, $2)`, viewerID, authorID); err != nil {
+		t.Fatalf("following: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		viewerID uuid.UUID
+		text     string
+		want     []uuid.UUID
+	}{
+		{name: "title matches rank above content", viewerID: viewerID, text: "garden", want: []uuid.UUID{inTitle, inContent}},
+		{name: "stemmed with the language", viewerID: viewerID, text: "gardens", want: []uuid.UUID{inTitle, inContent}},
+		{name: "exclusion", viewerID: viewerID, text: "garden -roses", want: []uuid.UUID{inContent}},
+		{name: "phrase", viewerID: viewerID, text: `"garden tour"`, want: []uuid.UUID{inTitle}},
+		{name: "author sees their private post", viewerID: authorID, text: "garden", want: []uuid.UUID{private, inTitle, inContent}},
+		{name: "no match", viewerID: viewerID, text: "ocean"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			posts, err := repo.Search(ctx, tt.viewerID, SearchQuery{Text: tt.text, Language: "english", Limit: 10})
+			if err != nil {
+				t.Fatalf("Search: %v", err)
+			}
+			var got []uuid.UUID
+			for _, p := range posts {
+				got = append(got, p.ID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Search(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchPostsEmptyQuery(t *testing.T) {
+	f := newPostFixture(t)
+
+	// The fake repository has no Search, so reaching it would panic
+	_, err := f.service.SearchPosts(context.Background(), uuid.New(), SearchQuery{Text: "  \t", Limit: 10})
+	if !errors.Is(err, ErrEmptySearchQuery) {
+		t.Errorf("SearchPosts error = %v, want %v", err, ErrEmptySearchQuery)
+	}
+}
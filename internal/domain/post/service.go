@@ -1,51 +1,616 @@
 package post
 
 import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"fowergram-backend/internal/domain/media"
+	"fowergram-backend/internal/domain/moderation"
 	"fowergram-backend/internal/domain/user"
+	"fowergram-backend/internal/events"
 	"fowergram-backend/internal/infra/cache"
+	"fowergram-backend/internal/infra/database"
 	"fowergram-backend/internal/infra/messaging"
 	"fowergram-backend/internal/infra/storage"
 	"fowergram-backend/pkg/logger"
+	"fowergram-backend/pkg/telemetry"
 
 	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
 )
 
 // service implements Service
 type service struct {
-	repo      Repository
-	userRepo  user.Repository
-	storage   *storage.MinIOStorage
-	cache     *cache.RedisCache
-	messaging *messaging.NATSClient
-	logger    logger.Logger
+	db         database.DB // Runs writes spanning several repository calls in one transaction
+	repo       Repository
+	userRepo   user.Repository
+	media      media.Service
+	moderation moderation.Service
+	storage    storage.Storage
+	cache      cache.Cache
+	redis      *redis.Client // Timelines and view counters, which need more than Cache offers
+	messaging  messaging.Client
+	publisher  events.Publisher
+	logger     logger.Logger
+	telemetry  *telemetry.Telemetry
+
+	searchLanguage string
+	postLoads      singleflight.Group // Collapses concurrent cache misses per post
 }
 
 // NewService creates a new post service
-func NewService(repo Repository, userRepo user.Repository, storage *storage.MinIOStorage, cache *cache.RedisCache, messaging *messaging.NATSClient, logger logger.Logger) Service {
+func NewService(db database.DB, repo Repository, userRepo user.Repository, mediaService media.Service, moderationService moderation.Service, storage storage.Storage, cache cache.Cache, redis *redis.Client, messaging messaging.Client, publisher events.Publisher, logger logger.Logger, telemetry *telemetry.Telemetry, searchLanguage string) Service {
 	return &service{
-		repo:      repo,
-		userRepo:  userRepo,
-		storage:   storage,
-		cache:     cache,
-		messaging: messaging,
-		logger:    logger,
+		db:         db,
+		repo:       repo,
+		userRepo:   userRepo,
+		media:      mediaService,
+		moderation: moderationService,
+		storage:    storage,
+		cache:      cache,
+		redis:      redis,
+		messaging:  messaging,
+		publisher:  publisher,
+		logger:     logger,
+		telemetry:  telemetry,
+
+		searchLanguage: searchLanguage,
+	}
+}
+
+// CreatePost creates a new post. Text the moderator rejects returns
+// moderation.ErrContentRejected; flagged posts are published and held for review.
+func (s *service) CreatePost(ctx context.Context, userID uuid.UUID, input CreatePostInput) (*Post, error) {
+	verdict, err := s.moderation.Screen(ctx, moderationText(input.Title, input.Content, derefString(input.Caption)))
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := s.media.GetUserMedia(ctx, userID, input.MediaKeys)
+	if err != nil {
+		if errors.Is(err, media.ErrMediaNotFound) {
+			return nil, ErrMediaNotFound
+		}
+		if errors.Is(err, media.ErrInvalidMediaKey) {
+			return nil, ErrInvalidMediaKey
+		}
+		return nil, err
+	}
+	if err := s.media.ConfirmUploads(ctx, items); err != nil {
+		if errors.Is(err, media.ErrUploadIncomplete) || errors.Is(err, media.ErrFileTooLarge) {
+			return nil, ErrMediaNotUploaded
+		}
+		return nil, err
+	}
+
+	tags := mergeTags(input.Tags, derefString(input.Caption))
+	if len(tags) > MaxTagsPerPost {
+		return nil, ErrTooManyTags
+	}
+
+	if err := validateCoordinates(input.Latitude, input.Longitude); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	status := StatusPublished
+	switch {
+	case input.Draft && input.ScheduledAt != nil:
+		return nil, ErrDraftScheduled
+	case input.Draft:
+		status = StatusDraft
+	case input.ScheduledAt != nil:
+		if err := validateSchedule(*input.ScheduledAt, now); err != nil {
+			return nil, err
+		}
+		status = StatusScheduled
+	}
+
+	var likes int
+	var views int64
+	post := &Post{
+		ID:               uuid.New(),
+		UserID:           userID,
+		Title:            input.Title,
+		Content:          input.Content,
+		Caption:          input.Caption,
+		Location:         input.Location,
+		Latitude:         input.Latitude,
+		Longitude:        input.Longitude,
+		IsPrivate:        input.IsPrivate,
+		CommentsDisabled: input.CommentsDisabled,
+		HideLikeCount:    input.HideLikeCount,
+		Media:            items,
+		Tags:             tags,
+		LikesCount:       &likes,
+		ViewsCount:       &views,
+		Status:           status,
+		ScheduledAt:      input.ScheduledAt,
+		SearchLanguage:   s.searchLanguage,
+		Version:          1,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}
+
+	var mentioned []uuid.UUID
+	err = database.WithTx(ctx, s.db, func(tx database.DB) error {
+		repo := s.repo.WithTx(tx)
+		if err := repo.Create(ctx, post); err != nil {
+			return err
+		}
+		if post.Status != StatusPublished {
+			return nil // Mentions are recorded when it's published
+		}
+		mentioned, err = addCaptionMentions(ctx, repo, post)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.holdForReview(ctx, post, verdict)
+
+	if post.Status == StatusPublished {
+		s.publishCreated(ctx, post, mentioned)
+	}
+
+	if err := s.resolveMediaURLs(ctx, post); err != nil {
+		return nil, err
+	}
+
+	return post, nil
+}
+
+// GetPost retrieves a post by ID if the viewer is allowed to see it and
+// counts the view. Posts are served from the cache when possible.
+func (s *service) GetPost(ctx context.Context, id, viewerID uuid.UUID) (*Post, error) {
+	post, err := s.loadPost(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if post.UserID != viewerID {
+		visible, err := s.repo.IsVisible(ctx, id, viewerID)
+		if err != nil {
+			return nil, err
+		}
+		if !visible {
+			return nil, ErrPostNotFound
+		}
+	}
+
+	s.recordView(ctx, post, viewerID)
+	hideOwnerOnlyCounts(viewerID, []*Post{post})
+
+	if err := s.repo.MarkSaved(ctx, viewerID, []*Post{post}); err != nil {
+		return nil, err
+	}
+
+	if err := s.attachOriginals(ctx, viewerID, []*Post{post}); err != nil {
+		return nil, err
 	}
+
+	if err := s.resolveMediaURLs(ctx, post); err != nil {
+		return nil, err
+	}
+
+	return post, nil
 }
 
-// CreatePost creates a new post
-func (s *service) CreatePost(userID uuid.UUID, caption, location *string) (*Post, error) {
-	// Implementation would create post
-	return nil, nil
+// GetPostsByIDs retrieves the published posts among ids that the viewer may
+// see, in the order requested. Posts that are missing or hidden from the
+// viewer are left out, and repeated IDs are returned once.
+func (s *service) GetPostsByIDs(ctx context.Context, ids []uuid.UUID, viewerID uuid.UUID) ([]*Post, error) {
+	if len(ids) > MaxBatchSize {
+		return nil, ErrBatchTooLarge
+	}
+
+	found, err := s.repo.GetVisibleByIDs(ctx, ids, viewerID)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[uuid.UUID]*Post, len(found))
+	for _, post := range found {
+		byID[post.ID] = post
+	}
+
+	posts := make([]*Post, 0, len(found))
+	for _, id := range ids {
+		if post, ok := byID[id]; ok {
+			posts = append(posts, post)
+			delete(byID, id)
+		}
+	}
+
+	hideOwnerOnlyCounts(viewerID, posts)
+
+	if err := s.repo.MarkSaved(ctx, viewerID, posts); err != nil {
+		return nil, err
+	}
+
+	if err := s.attachOriginals(ctx, viewerID, posts); err != nil {
+		return nil, err
+	}
+
+	for _, post := range posts {
+		if err := s.resolveMediaURLs(ctx, post); err != nil {
+			return nil, err
+		}
+	}
+
+	return posts, nil
+}
+
+// UpdatePost applies an edit by the author or an admin based on
+// input.Version. If the post changed since that version, ErrVersionConflict
+// is returned and nothing is written. A new caption on a published post
+// updates who it mentions, notifying the users newly mentioned.
+func (s *service) UpdatePost(ctx context.Context, id uuid.UUID, actor Actor, input UpdatePostInput) (*Post, error) {
+	post, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !actor.canModify(post) {
+		return nil, ErrNotPostOwner
+	}
+	if post.Version != input.Version {
+		return nil, ErrVersionConflict
+	}
+
+	if input.Title != nil {
+		post.Title = *input.Title
+	}
+	if input.Content != nil {
+		post.Content = *input.Content
+	}
+	if input.IsPrivate != nil {
+		post.IsPrivate = *input.IsPrivate
+	}
+	if input.CommentsDisabled != nil {
+		post.CommentsDisabled = *input.CommentsDisabled
+	}
+	if input.HideLikeCount != nil {
+		post.HideLikeCount = *input.HideLikeCount
+	}
+
+	if input.Caption != nil {
+		post.Caption = input.Caption
+	}
+
+	var verdict *moderation.Result
+	if input.Title != nil || input.Content != nil || input.Caption != nil {
+		verdict, err = s.moderation.Screen(ctx, moderationText(post.Title, post.Content, derefString(post.Caption)))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Tags derive from explicit tags plus caption hashtags, so either change re-tags the post
+	tagsChanged := input.Tags != nil || input.Caption != nil
+	if tagsChanged {
+		explicit := input.Tags
+		if explicit == nil {
+			explicit = post.Tags
+		}
+		post.Tags = mergeTags(explicit, derefString(post.Caption))
+		if len(post.Tags) > MaxTagsPerPost {
+			return nil, ErrTooManyTags
+		}
+	}
+
+	// Drafts and scheduled posts mention users once they're published
+	syncMentions := input.Caption != nil && post.Status == StatusPublished
+	var mentioned []uuid.UUID
+	err = database.WithTx(ctx, s.db, func(tx database.DB) error {
+		repo := s.repo.WithTx(tx)
+		if err := repo.Update(ctx, post, tagsChanged); err != nil {
+			return err
+		}
+		if !syncMentions {
+			return nil
+		}
+		var err error
+		mentioned, err = repo.SyncMentions(ctx, post.UserID, post.ID, ExtractMentions(derefString(post.Caption)), post.UpdatedAt)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.invalidatePosts(ctx, post.ID)
+	s.holdForReview(ctx, post, verdict)
+	s.notifyMentioned(ctx, post, mentioned, post.UpdatedAt)
+
+	if err := s.attachOriginals(ctx, actor.UserID, []*Post{post}); err != nil {
+		return nil, err
+	}
+
+	if err := s.resolveMediaURLs(ctx, post); err != nil {
+		return nil, err
+	}
+
+	return post, nil
+}
+
+// DeletePost soft-deletes a post on behalf of its author or an admin. Reposts
+// of it remain and show the original as unavailable.
+func (s *service) DeletePost(ctx context.Context, id uuid.UUID, actor Actor) error {
+	post, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !actor.canModify(post) {
+		return ErrNotPostOwner
+	}
+
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return err
+	}
+
+	// A repost's deletion also changes the original's repost count
+	if post.OriginalPostID != nil {
+		s.invalidatePosts(ctx, id, *post.OriginalPostID)
+	} else {
+		s.invalidatePosts(ctx, id)
+	}
+
+	return nil
+}
+
+// HardDeletePost permanently removes a post, including one its author already
+// deleted, for moderation. It doesn't check ownership; callers must be admins.
+func (s *service) HardDeletePost(ctx context.Context, id uuid.UUID) error {
+	// Only a live repost's deletion changes the original's repost count
+	live, err := s.repo.GetByID(ctx, id)
+	if err != nil && !errors.Is(err, ErrPostNotFound) {
+		return err
+	}
+
+	if err := s.repo.HardDelete(ctx, id); err != nil {
+		return err
+	}
+
+	if live != nil && live.OriginalPostID != nil {
+		s.invalidatePosts(ctx, id, *live.OriginalPostID)
+	} else {
+		s.invalidatePosts(ctx, id)
+	}
+
+	return nil
 }
 
-// GetPost retrieves a post by ID
-func (s *service) GetPost(id uuid.UUID) (*Post, error) {
-	// Implementation would get post
-	return nil, nil
+// ListPosts lists posts matching the filter that the viewer can see, newest
+// first. q.Limit is the page size; one extra row is fetched to decide whether
+// there is a next page. All the matching posts are counted as q.Count asks.
+func (s *service) ListPosts(ctx context.Context, viewerID uuid.UUID, filter ListPostsFilter, q ListPostsQuery) (*Page, error) {
+	counted := q.Count == CountExact || q.Count == CountEstimate
+
+	if filter.Tag != "" {
+		normalized, ok := NormalizeTag(filter.Tag)
+		if !ok {
+			page := &Page{Posts: []*Post{}}
+			if counted {
+				page.TotalCount = new(int64)
+			}
+			return page, nil
+		}
+		filter.Tag = normalized
+	}
+
+	limit := q.Limit
+	q.Limit = limit + 1
+
+	posts, err := s.repo.ListVisible(ctx, viewerID, filter, q)
+	if err != nil {
+		return nil, err
+	}
+
+	page := newPage(posts, limit)
+	hideOwnerOnlyCounts(viewerID, page.Posts)
+
+	if counted {
+		total, err := s.repo.CountVisible(ctx, viewerID, filter, q.Count)
+		if err != nil {
+			return nil, err
+		}
+		page.TotalCount = &total
+		page.TotalCountEstimated = q.Count == CountEstimate
+	}
+
+	if err := s.repo.MarkSaved(ctx, viewerID, page.Posts); err != nil {
+		return nil, err
+	}
+
+	if err := s.attachOriginals(ctx, viewerID, page.Posts); err != nil {
+		return nil, err
+	}
+
+	for _, post := range page.Posts {
+		if err := s.resolveMediaURLs(ctx, post); err != nil {
+			return nil, err
+		}
+	}
+
+	return page, nil
 }
 
 // GetUserPosts retrieves posts by user ID
-func (s *service) GetUserPosts(userID uuid.UUID) ([]*Post, error) {
-	// Implementation would get user posts
-	return nil, nil
+func (s *service) GetUserPosts(ctx context.Context, userID uuid.UUID) ([]*Post, error) {
+	posts, err := s.repo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, post := range posts {
+		if err := s.resolveMediaURLs(ctx, post); err != nil {
+			return nil, err
+		}
+	}
+
+	return posts, nil
+}
+
+// LikePost likes a post the user can see; liking twice is a no-op
+func (s *service) LikePost(ctx context.Context, postID, userID uuid.UUID) error {
+	post, err := s.repo.GetVisibleByID(ctx, postID, userID)
+	if err != nil {
+		return err
+	}
+
+	created, err := s.repo.Like(ctx, postID, userID)
+	if err != nil {
+		return err
+	}
+
+	if created {
+		s.invalidatePosts(ctx, postID)
+		s.publish(ctx, userID, events.PostLiked{
+			PostID:   post.ID,
+			AuthorID: post.UserID,
+			UserID:   userID,
+			LikedAt:  time.Now(),
+		})
+	}
+
+	return nil
+}
+
+// UnlikePost removes a like from a post the user can see; unliking twice is a no-op
+func (s *service) UnlikePost(ctx context.Context, postID, userID uuid.UUID) error {
+	if _, err := s.repo.GetVisibleByID(ctx, postID, userID); err != nil {
+		return err
+	}
+
+	removed, err := s.repo.Unlike(ctx, postID, userID)
+	if err != nil {
+		return err
+	}
+	if removed {
+		s.invalidatePosts(ctx, postID)
+	}
+
+	return nil
+}
+
+// GetLikers lists the users who liked a post the viewer can see
+func (s *service) GetLikers(ctx context.Context, postID, viewerID uuid.UUID, limit, offset int) ([]*Liker, error) {
+	if _, err := s.repo.GetVisibleByID(ctx, postID, viewerID); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetLikers(ctx, postID, limit, offset)
+}
+
+// SavePost bookmarks a post the user can see
+func (s *service) SavePost(ctx context.Context, postID, userID uuid.UUID) error {
+	if _, err := s.repo.GetVisibleByID(ctx, postID, userID); err != nil {
+		return err
+	}
+
+	return s.repo.Save(ctx, postID, userID)
+}
+
+// UnsavePost removes a bookmark. Visibility isn't checked so that bookmarks
+// of posts the user can no longer see can still be cleared.
+func (s *service) UnsavePost(ctx context.Context, postID, userID uuid.UUID) error {
+	return s.repo.Unsave(ctx, postID, userID)
+}
+
+// GetSavedPosts lists the user's bookmarked posts that they can still see
+func (s *service) GetSavedPosts(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*Post, error) {
+	posts, err := s.repo.GetSaved(ctx, userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	hideOwnerOnlyCounts(userID, posts)
+
+	if err := s.attachOriginals(ctx, userID, posts); err != nil {
+		return nil, err
+	}
+
+	for _, post := range posts {
+		if err := s.resolveMediaURLs(ctx, post); err != nil {
+			return nil, err
+		}
+	}
+
+	return posts, nil
+}
+
+// GetTagPosts lists posts with a tag that the viewer can see
+func (s *service) GetTagPosts(ctx context.Context, tag string, viewerID uuid.UUID, limit, offset int) ([]*Post, error) {
+	normalized, ok := NormalizeTag(tag)
+	if !ok {
+		return []*Post{}, nil
+	}
+
+	posts, err := s.repo.GetVisibleByTag(ctx, normalized, viewerID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	hideOwnerOnlyCounts(viewerID, posts)
+
+	if err := s.repo.MarkSaved(ctx, viewerID, posts); err != nil {
+		return nil, err
+	}
+
+	if err := s.attachOriginals(ctx, viewerID, posts); err != nil {
+		return nil, err
+	}
+
+	for _, post := range posts {
+		if err := s.resolveMediaURLs(ctx, post); err != nil {
+			return nil, err
+		}
+	}
+
+	return posts, nil
+}
+
+// SearchTags suggests tags starting with the query for autocomplete
+func (s *service) SearchTags(ctx context.Context, query string, limit int) ([]*Tag, error) {
+	prefix, ok := NormalizeTag(query)
+	if !ok {
+		return []*Tag{}, nil
+	}
+
+	return s.repo.SearchTags(ctx, prefix, limit)
+}
+
+// holdForReview queues a flagged post for moderation. A failure is logged
+// rather than failing the request, since the post is already saved.
+func (s *service) holdForReview(ctx context.Context, post *Post, verdict *moderation.Result) {
+	if err := s.moderation.Hold(ctx, moderation.ContentPost, post.ID, post.UserID, verdict); err != nil {
+		s.logger.Error("Failed to hold post for review", "post_id", post.ID, "error", err)
+	}
+}
+
+// moderationText joins a post's text fields for screening
+func moderationText(fields ...string) string {
+	return strings.Join(fields, "\n")
+}
+
+// publish sends a best-effort event; failures are logged, not returned
+func (s *service) publish(ctx context.Context, actorID uuid.UUID, payload events.Payload) {
+	if err := s.publisher.Publish(ctx, actorID, payload); err != nil {
+		s.logger.Error("Failed to publish event", "type", payload.EventType(), "error", err)
+	}
+}
+
+// resolveMediaURLs fills in variant URLs for every media item on a post
+func (s *service) resolveMediaURLs(ctx context.Context, post *Post) error {
+	for _, m := range post.Media {
+		if err := s.media.ResolveURLs(ctx, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
 }
@@ -0,0 +1,1115 @@
+package post
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"fowergram-backend/internal/domain/media"
+	"fowergram-backend/internal/domain/moderation"
+	"fowergram-backend/internal/domain/user"
+	"fowergram-backend/internal/events"
+	"fowergram-backend/internal/infra/cache"
+	"fowergram-backend/internal/infra/database"
+	"fowergram-backend/internal/infra/database/dbtest"
+	"fowergram-backend/internal/infra/messaging"
+	"fowergram-backend/internal/infra/storage"
+	"fowergram-backend/pkg/auth"
+	"fowergram-backend/pkg/logger"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/redis/go-redis/v9"
+)
+
+// fakeDB begins fakeTxs and counts how they end
+type fakeDB struct {
+	database.DB
+
+	mu        sync.Mutex
+	commits   int
+	rollbacks int
+}
+
+func (db *fakeDB) Begin(ctx context.Context) (pgx.Tx, error) {
+	return &fakeTx{db: db}, nil
+}
+
+// ended returns how many transactions committed and rolled back
+func (db *fakeDB) ended() (commits, rollbacks int) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.commits, db.rollbacks
+}
+
+// fakeTx holds back the writes of fake repositories bound to it until it
+// commits. Like pgx, rolling back after a commit does nothing.
+type fakeTx struct {
+	pgx.Tx
+
+	db      *fakeDB
+	pending []func()
+	done    bool
+}
+
+// stage queues a write to apply on commit
+func (tx *fakeTx) stage(write func()) {
+	tx.pending = append(tx.pending, write)
+}
+
+func (tx *fakeTx) Commit(ctx context.Context) error {
+	for _, write := range tx.pending {
+		write()
+	}
+	tx.done = true
+	tx.db.mu.Lock()
+	tx.db.commits++
+	tx.db.mu.Unlock()
+	return nil
+}
+
+func (tx *fakeTx) Rollback(ctx context.Context) error {
+	if tx.done {
+		return nil
+	}
+	tx.done = true
+	tx.db.mu.Lock()
+	tx.db.rollbacks++
+	tx.db.mu.Unlock()
+	return nil
+}
+
+// fakeRepository keeps posts in memory. Methods the tests don't reach are
+// left to the embedded nil Repository and panic if called.
+type fakeRepository struct {
+	Repository
+
+	mu       sync.Mutex
+	posts    map[uuid.UUID]Post
+	deleted  map[uuid.UUID]bool // Soft-deleted posts, left out of every read
+	loads    int                // GetByID calls
+	likes    map[uuid.UUID]map[uuid.UUID]bool
+	users    map[string]uuid.UUID             // Active users by username
+	blocks   map[[2]uuid.UUID]bool            // Blocker and blocked
+	follows  map[[2]uuid.UUID]bool            // Follower and followed
+	mentions map[uuid.UUID]map[uuid.UUID]bool // Users mentioned in each post's caption
+	saves    map[uuid.UUID][]uuid.UUID        // Posts each user saved, oldest first
+
+	estimate     int64  // Answered by CountVisible for CountEstimate, as the planner's estimate
+	mentionErr   error  // Returned by AddMentions in a transaction when set
+	viewsErr     error  // Returned by AddViews when set
+	beforeUpdate func() // Runs once before the next Update writes, like a concurrent edit
+}
+
+func newFakeRepository() *fakeRepository {
+	return &fakeRepository{
+		posts:    make(map[uuid.UUID]Post),
+		deleted:  make(map[uuid.UUID]bool),
+		likes:    make(map[uuid.UUID]map[uuid.UUID]bool),
+		users:    make(map[string]uuid.UUID),
+		blocks:   make(map[[2]uuid.UUID]bool),
+		follows:  make(map[[2]uuid.UUID]bool),
+		mentions: make(map[uuid.UUID]map[uuid.UUID]bool),
+		saves:    make(map[uuid.UUID][]uuid.UUID),
+	}
+}
+
+func (r *fakeRepository) Create(ctx context.Context, post *Post) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.posts[post.ID] = *post
+	return nil
+}
+
+func (r *fakeRepository) GetByID(ctx context.Context, id uuid.UUID) (*Post, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.loads++
+	post, ok := r.liveLocked(id)
+	if !ok {
+		return nil, ErrPostNotFound
+	}
+	return &post, nil
+}
+
+func (r *fakeRepository) GetVisibleByID(ctx context.Context, id, viewerID uuid.UUID) (*Post, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	post, ok := r.liveLocked(id)
+	if !ok {
+		return nil, ErrPostNotFound
+	}
+	return &post, nil
+}
+
+func (r *fakeRepository) IsVisible(ctx context.Context, id, viewerID uuid.UUID) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.liveLocked(id)
+	return ok, nil
+}
+
+// GetVisibleByIDs returns the live posts among ids
+func (r *fakeRepository) GetVisibleByIDs(ctx context.Context, ids []uuid.UUID, viewerID uuid.UUID) ([]*Post, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var posts []*Post
+	for _, id := range ids {
+		if post, ok := r.liveLocked(id); ok {
+			posts = append(posts, &post)
+		}
+	}
+	return posts, nil
+}
+
+// ListVisible lists live published posts newest first, filtered by author,
+// tag or the accounts a user follows. Authors in the fake have no followers, so
+// a MinAuthorFollowers filter matches nothing.
+func (r *fakeRepository) ListVisible(ctx context.Context, viewerID uuid.UUID, filter ListPostsFilter, q ListPostsQuery) ([]*Post, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if filter.MinAuthorFollowers > 0 {
+		return nil, nil
+	}
+	var posts []*Post
+	for _, post := range r.newestLocked() {
+		if filter.AuthorID != nil && post.UserID != *filter.AuthorID {
+			continue
+		}
+		if filter.Tag != "" && !slices.Contains(post.Tags, filter.Tag) {
+			continue
+		}
+		if filter.FollowedBy != nil && !r.follows[[2]uuid.UUID{*filter.FollowedBy, post.UserID}] {
+			continue
+		}
+		if q.After != nil && !before(post, q.After) {
+			continue
+		}
+		posts = append(posts, post)
+	}
+	return posts[:min(q.Limit, len(posts))], nil
+}
+
+// CountVisible counts the posts ListVisible matches, or answers r.estimate
+// for CountEstimate
+func (r *fakeRepository) CountVisible(ctx context.Context, viewerID uuid.UUID, filter ListPostsFilter, mode CountMode) (int64, error) {
+	if mode == CountEstimate {
+		return r.estimate, nil
+	}
+	posts, err := r.ListVisible(ctx, viewerID, filter, ListPostsQuery{Limit: math.MaxInt})
+	return int64(len(posts)), err
+}
+
+// ListTimelineEntries lists the live published posts of the authors, newest first
+func (r *fakeRepository) ListTimelineEntries(ctx context.Context, authorIDs []uuid.UUID, maxAuthorFollowers, limit int) ([]TimelineEntry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var entries []TimelineEntry
+	for _, post := range r.newestLocked() {
+		if slices.Contains(authorIDs, post.UserID) && len(entries) < limit {
+			entries = append(entries, TimelineEntry{PostID: post.ID, CreatedAt: post.CreatedAt})
+		}
+	}
+	return entries, nil
+}
+
+// liveLocked returns the post unless it is missing or soft-deleted
+func (r *fakeRepository) liveLocked(id uuid.UUID) (Post, bool) {
+	post, ok := r.posts[id]
+	return post, ok && !r.deleted[id]
+}
+
+// newestLocked returns the live published posts, newest first
+func (r *fakeRepository) newestLocked() []*Post {
+	var posts []*Post
+	for id := range r.posts {
+		if post, ok := r.liveLocked(id); ok && post.Status == StatusPublished {
+			posts = append(posts, &post)
+		}
+	}
+	slices.SortFunc(posts, func(a, b *Post) int {
+		if before(a, &Cursor{CreatedAt: b.CreatedAt, ID: b.ID}) {
+			return 1
+		}
+		return -1
+	})
+	return posts
+}
+
+// Update writes the post only if the stored version still matches, as the
+// SQL's version check does
+func (r *fakeRepository) Update(ctx context.Context, post *Post, tagsChanged bool) error {
+	r.mu.Lock()
+	interleave := r.beforeUpdate
+	r.beforeUpdate = nil
+	r.mu.Unlock()
+	if interleave != nil {
+		interleave()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.liveLocked(post.ID)
+	if !ok || stored.Version != post.Version {
+		return ErrVersionConflict
+	}
+	post.Version++
+	r.posts[post.ID] = *post
+	return nil
+}
+
+// AddViews adds view counts to live posts, skipping missing and deleted
+// ones as the SQL does
+func (r *fakeRepository) AddViews(ctx context.Context, counts map[uuid.UUID]int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.viewsErr != nil {
+		return r.viewsErr
+	}
+	for id, n := range counts {
+		post, ok := r.liveLocked(id)
+		if !ok {
+			continue
+		}
+		views := n
+		if post.ViewsCount != nil {
+			views += *post.ViewsCount
+		}
+		post.ViewsCount = &views
+		r.posts[id] = post
+	}
+	return nil
+}
+
+// Delete soft-deletes a post, keeping it for HardDelete
+func (r *fakeRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.liveLocked(id); !ok {
+		return ErrPostNotFound
+	}
+	r.deleted[id] = true
+	return nil
+}
+
+func (r *fakeRepository) HardDelete(ctx context.Context, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.posts[id]; !ok {
+		return ErrPostNotFound
+	}
+	delete(r.posts, id)
+	delete(r.deleted, id)
+	return nil
+}
+
+func (r *fakeRepository) Like(ctx context.Context, postID, userID uuid.UUID) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.likes[postID] == nil {
+		r.likes[postID] = make(map[uuid.UUID]bool)
+	}
+	if r.likes[postID][userID] {
+		return false, nil
+	}
+	r.likes[postID][userID] = true
+	post := r.posts[postID]
+	likes := len(r.likes[postID])
+	post.LikesCount = &likes
+	r.posts[postID] = post
+	return true, nil
+}
+
+func (r *fakeRepository) Save(ctx context.Context, postID, userID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !slices.Contains(r.saves[userID], postID) {
+		r.saves[userID] = append(r.saves[userID], postID)
+	}
+	return nil
+}
+
+func (r *fakeRepository) Unsave(ctx context.Context, postID, userID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.saves[userID] = slices.DeleteFunc(r.saves[userID], func(id uuid.UUID) bool { return id == postID })
+	return nil
+}
+
+// GetSaved lists the user's saved posts that are still live, most recently
+// saved first
+func (r *fakeRepository) GetSaved(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*Post, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var posts []*Post
+	for i := len(r.saves[userID]) - 1; i >= 0; i-- {
+		post, ok := r.liveLocked(r.saves[userID][i])
+		if !ok {
+			continue
+		}
+		post.ViewerHasSaved = true
+		posts = append(posts, &post)
+	}
+	if offset >= len(posts) {
+		return nil, nil
+	}
+	return posts[offset:min(offset+limit, len(posts))], nil
+}
+
+func (r *fakeRepository) MarkSaved(ctx context.Context, userID uuid.UUID, posts []*Post) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, post := range posts {
+		post.ViewerHasSaved = slices.Contains(r.saves[userID], post.ID)
+	}
+	return nil
+}
+
+// AddMentions records caption mentions by the rules of the SQL: unknown
+// usernames, the author and users blocked either way are skipped
+func (r *fakeRepository) AddMentions(ctx context.Context, authorID, postID uuid.UUID, commentID *uuid.UUID, usernames []string, createdAt time.Time) ([]uuid.UUID, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.addMentionsLocked(authorID, postID, usernames), nil
+}
+
+func (r *fakeRepository) SyncMentions(ctx context.Context, authorID, postID uuid.UUID, usernames []string, createdAt time.Time) ([]uuid.UUID, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	kept := make(map[uuid.UUID]bool)
+	for _, username := range usernames {
+		kept[r.users[username]] = true
+	}
+	for userID := range r.mentions[postID] {
+		if !kept[userID] {
+			delete(r.mentions[postID], userID)
+		}
+	}
+	return r.addMentionsLocked(authorID, postID, usernames), nil
+}
+
+func (r *fakeRepository) addMentionsLocked(authorID, postID uuid.UUID, usernames []string) []uuid.UUID {
+	if r.mentions[postID] == nil {
+		r.mentions[postID] = make(map[uuid.UUID]bool)
+	}
+	var added []uuid.UUID
+	for _, username := range usernames {
+		userID, ok := r.users[username]
+		if !ok || userID == authorID || r.blocks[[2]uuid.UUID{userID, authorID}] || r.blocks[[2]uuid.UUID{authorID, userID}] {
+			continue
+		}
+		if !r.mentions[postID][userID] {
+			r.mentions[postID][userID] = true
+			added = append(added, userID)
+		}
+	}
+	return added
+}
+
+func (r *fakeRepository) WithTx(tx database.DB) Repository {
+	return &txRepository{fakeRepository: r, tx: tx.(*fakeTx)}
+}
+
+// txRepository is a fakeRepository bound to a transaction, whose inserts
+// show once it commits
+type txRepository struct {
+	*fakeRepository
+	tx *fakeTx
+}
+
+func (r *txRepository) Create(ctx context.Context, post *Post) error {
+	stored := *post
+	r.tx.stage(func() { r.fakeRepository.Create(ctx, &stored) })
+	return nil
+}
+
+// AddMentions reports the users it would add now and adds them on commit
+func (r *txRepository) AddMentions(ctx context.Context, authorID, postID uuid.UUID, commentID *uuid.UUID, usernames []string, createdAt time.Time) ([]uuid.UUID, error) {
+	if r.mentionErr != nil {
+		return nil, r.mentionErr
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var added []uuid.UUID
+	for _, username := range usernames {
+		userID, ok := r.users[username]
+		if ok && userID != authorID && !r.blocks[[2]uuid.UUID{userID, authorID}] &&
+			!r.blocks[[2]uuid.UUID{authorID, userID}] && !r.mentions[postID][userID] {
+			added = append(added, userID)
+		}
+	}
+	r.tx.stage(func() { r.fakeRepository.AddMentions(ctx, authorID, postID, commentID, usernames, createdAt) })
+	return added, nil
+}
+
+// loadCount returns how many times posts were loaded from the repository
+func (r *fakeRepository) loadCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.loads
+}
+
+// fakeUserRepository reads follows from the post repository. Other methods
+// are left to the embedded nil user.Repository.
+type fakeUserRepository struct {
+	user.Repository
+
+	posts *fakeRepository
+	large map[uuid.UUID]bool // Authors with more than FanoutFollowerThreshold followers
+}
+
+func (r *fakeUserRepository) GetUserByID(ctx context.Context, id uuid.UUID) (*auth.User, error) {
+	followers := len(r.followerIDs(id))
+	if r.large[id] {
+		followers = FanoutFollowerThreshold + 1
+	}
+	return &auth.User{ID: id, FollowersCount: followers}, nil
+}
+
+func (r *fakeUserRepository) GetFollowerIDs(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	return r.followerIDs(userID), nil
+}
+
+func (r *fakeUserRepository) followerIDs(userID uuid.UUID) []uuid.UUID {
+	r.posts.mu.Lock()
+	defer r.posts.mu.Unlock()
+	var ids []uuid.UUID
+	for follow := range r.posts.follows {
+		if follow[1] == userID {
+			ids = append(ids, follow[0])
+		}
+	}
+	return ids
+}
+
+func (r *fakeUserRepository) GetFollowingIDs(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	r.posts.mu.Lock()
+	defer r.posts.mu.Unlock()
+	var ids []uuid.UUID
+	for follow := range r.posts.follows {
+		if follow[0] == userID {
+			ids = append(ids, follow[1])
+		}
+	}
+	return ids, nil
+}
+
+// stubMedia is a media service for posts without media
+type stubMedia struct {
+	media.Service
+}
+
+func (stubMedia) GetUserMedia(ctx context.Context, userID uuid.UUID, keys []string) ([]*media.Media, error) {
+	return nil, nil
+}
+
+func (stubMedia) ConfirmUploads(ctx context.Context, items []*media.Media) error {
+	return nil
+}
+
+func (stubMedia) ResolveURLs(ctx context.Context, m *media.Media) error {
+	return nil
+}
+
+type postFixture struct {
+	service   Service
+	db        *fakeDB
+	repo      *fakeRepository
+	users     *fakeUserRepository
+	cache     *cache.MemoryCache
+	redis     *miniredis.Miniredis // Holds home timelines
+	messaging *messaging.RecordingClient
+	now       time.Time // The cache's clock
+}
+
+func newPostFixture(t testing.TB) *postFixture {
+	t.Helper()
+	log := logger.NewZapLogger()
+	f := &postFixture{
+		db:        &fakeDB{},
+		repo:      newFakeRepository(),
+		redis:     miniredis.RunT(t),
+		messaging: messaging.NewRecordingClient(),
+		now:       time.Now(),
+	}
+	f.users = &fakeUserRepository{posts: f.repo, large: make(map[uuid.UUID]bool)}
+	f.cache = cache.NewMemoryCacheWithClock(func() time.Time { return f.now })
+	client := redis.NewClient(&redis.Options{Addr: f.redis.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	f.service = NewService(
+		f.db,
+		f.repo,
+		f.users,
+		stubMedia{},
+		moderation.NewService(nil, moderation.NewWordlistModerator(nil, nil), log),
+		storage.NewMemoryStorage(),
+		f.cache,
+		client,
+		f.messaging,
+		events.NewNATSPublisher(f.messaging, log),
+		log,
+		nil,
+		"simple",
+	)
+	return f
+}
+
+// createPost creates a published post by authorID with caption
+func (f *postFixture) createPost(t testing.TB, authorID uuid.UUID, caption string) *Post {
+	t.Helper()
+	post, err := f.service.CreatePost(context.Background(), authorID, CreatePostInput{
+		Title:   "Title",
+		Content: "Content",
+		Caption: &caption,
+	})
+	if err != nil {
+		t.Fatalf("CreatePost: %v", err)
+	}
+	return post
+}
+
+func TestCreatePostPublishesEvent(t *testing.T) {
+	scheduled := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name        string
+		input       CreatePostInput
+		wantPublish bool
+	}{
+		{
+			name:        "published post",
+			input:       CreatePostInput{Title: "Hello"},
+			wantPublish: true,
+		},
+		{
+			name:        "private post",
+			input:       CreatePostInput{Title: "Hello", IsPrivate: true},
+			wantPublish: true,
+		},
+		{
+			name:  "draft",
+			input: CreatePostInput{Title: "Hello", Draft: true},
+		},
+		{
+			name:  "scheduled post",
+			input: CreatePostInput{Title: "Hello", ScheduledAt: &scheduled},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newPostFixture(t)
+			authorID := uuid.New()
+
+			post, err := f.service.CreatePost(context.Background(), authorID, tt.input)
+			if err != nil {
+				t.Fatalf("CreatePost: %v", err)
+			}
+
+			published := f.messaging.PublishedTo(string(events.TypePostCreated))
+			if !tt.wantPublish {
+				if len(published) != 0 {
+					t.Fatalf("published %d %s events for an unpublished post", len(published), events.TypePostCreated)
+				}
+				return
+			}
+			if len(published) != 1 {
+				t.Fatalf("published %d %s events, want 1", len(published), events.TypePostCreated)
+			}
+
+			envelope, err := events.Decode(published[0].Data)
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if envelope.ActorID != authorID {
+				t.Errorf("actor = %s, want the author %s", envelope.ActorID, authorID)
+			}
+
+			var payload events.PostCreated
+			if err := envelope.DecodePayload(&payload); err != nil {
+				t.Fatalf("DecodePayload: %v", err)
+			}
+			want := events.PostCreated{
+				PostID:    post.ID,
+				AuthorID:  authorID,
+				IsPrivate: tt.input.IsPrivate,
+				CreatedAt: post.CreatedAt,
+			}
+			if payload.PostID != want.PostID || payload.AuthorID != want.AuthorID ||
+				payload.IsPrivate != want.IsPrivate || !payload.CreatedAt.Equal(want.CreatedAt) {
+				t.Errorf("payload = %+v, want %+v", payload, want)
+			}
+		})
+	}
+}
+
+func TestCreatePostRecordsMentionsInOneTransaction(t *testing.T) {
+	errMentions := errors.New("mentions insert failed")
+
+	tests := []struct {
+		name          string
+		input         CreatePostInput
+		mentionErr    error
+		wantStored    bool
+		wantMentioned bool
+		wantRollbacks int
+	}{
+		{
+			name:          "published post records its mentions",
+			input:         CreatePostInput{Title: "Hello", Caption: ptr("hi @alice")},
+			wantStored:    true,
+			wantMentioned: true,
+		},
+		{
+			name:       "draft records them once published",
+			input:      CreatePostInput{Title: "Hello", Caption: ptr("hi @alice"), Draft: true},
+			mentionErr: errMentions, // Never reached
+			wantStored: true,
+		},
+		{
+			name:          "failed mentions insert rolls back the post",
+			input:         CreatePostInput{Title: "Hello", Caption: ptr("hi @alice")},
+			mentionErr:    errMentions,
+			wantRollbacks: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newPostFixture(t)
+			f.repo.mentionErr = tt.mentionErr
+			alice := uuid.New()
+			f.repo.users["alice"] = alice
+
+			post, err := f.service.CreatePost(context.Background(), uuid.New(), tt.input)
+			wantErr := tt.mentionErr
+			if tt.wantStored {
+				wantErr = nil
+			}
+			if !errors.Is(err, wantErr) {
+				t.Fatalf("CreatePost error = %v, want %v", err, wantErr)
+			}
+
+			f.repo.mu.Lock()
+			stored := len(f.repo.posts) == 1
+			mentioned := false
+			for _, users := range f.repo.mentions {
+				mentioned = mentioned || users[alice]
+			}
+			f.repo.mu.Unlock()
+			if stored != tt.wantStored {
+				t.Errorf("post stored = %v, want %v", stored, tt.wantStored)
+			}
+			if mentioned != tt.wantMentioned {
+				t.Errorf("mention stored = %v, want %v", mentioned, tt.wantMentioned)
+			}
+			if notified := f.messaging.PublishedTo(string(events.TypeUserMentioned)); len(notified) > 0 != tt.wantMentioned {
+				t.Errorf("published %d %s events, want them only with a stored mention", len(notified), events.TypeUserMentioned)
+			}
+
+			commits, rollbacks := f.db.ended()
+			if rollbacks != tt.wantRollbacks || commits != 1-tt.wantRollbacks {
+				t.Errorf("commits = %d, rollbacks = %d, want %d and %d", commits, rollbacks, 1-tt.wantRollbacks, tt.wantRollbacks)
+			}
+
+			if tt.wantRollbacks > 0 {
+				if post != nil {
+					t.Errorf("CreatePost returned %+v for a rolled back post", post)
+				}
+				if published := f.messaging.PublishedTo(string(events.TypePostCreated)); len(published) != 0 {
+					t.Errorf("published %d %s events for a rolled back post", len(published), events.TypePostCreated)
+				}
+			}
+		})
+	}
+}
+
+func TestPostOwnership(t *testing.T) {
+	authorID := uuid.New()
+
+	tests := []struct {
+		name    string
+		actor   Actor
+		wantErr error
+	}{
+		{name: "author", actor: Actor{UserID: authorID}},
+		{name: "another user", actor: Actor{UserID: uuid.New()}, wantErr: ErrNotPostOwner},
+		{name: "admin", actor: Actor{UserID: uuid.New(), Admin: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name+" updates", func(t *testing.T) {
+			f := newPostFixture(t)
+			post := f.createPost(t, authorID, "hello")
+
+			_, err := f.service.UpdatePost(context.Background(), post.ID, tt.actor, UpdatePostInput{
+				Title:   ptr("Edited"),
+				Version: post.Version,
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UpdatePost error = %v, want %v", err, tt.wantErr)
+			}
+
+			wantTitle := "Edited"
+			if tt.wantErr != nil {
+				wantTitle = post.Title
+			}
+			if stored := f.repo.posts[post.ID]; stored.Title != wantTitle || stored.UserID != authorID {
+				t.Errorf("stored title %q by %s, want %q by the author", stored.Title, stored.UserID, wantTitle)
+			}
+		})
+
+		t.Run(tt.name+" deletes", func(t *testing.T) {
+			f := newPostFixture(t)
+			post := f.createPost(t, authorID, "hello")
+
+			err := f.service.DeletePost(context.Background(), post.ID, tt.actor)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("DeletePost error = %v, want %v", err, tt.wantErr)
+			}
+
+			if deleted := f.repo.deleted[post.ID]; deleted != (tt.wantErr == nil) {
+				t.Errorf("post deleted = %v, want %v", deleted, tt.wantErr == nil)
+			}
+		})
+	}
+}
+
+func TestDeletedPostsLeaveReads(t *testing.T) {
+	f := newPostFixture(t)
+	ctx := context.Background()
+	alice, bob := uuid.New(), uuid.New()
+	f.repo.follows[[2]uuid.UUID{bob, alice}] = true
+	kept := f.createPost(t, alice, "kept")
+	deleted := f.createPost(t, alice, "deleted")
+
+	if err := f.service.SavePost(ctx, deleted.ID, bob); err != nil {
+		t.Fatalf("SavePost: %v", err)
+	}
+	// The first read builds bob's timeline, which still lists the post
+	// after it's deleted
+	if page, err := f.service.GetFeed(ctx, bob, ListPostsQuery{Limit: 20}); err != nil || len(page.Posts) != 2 {
+		t.Fatalf("GetFeed before the delete = %v, %v; want both posts", page, err)
+	}
+	if err := f.service.DeletePost(ctx, deleted.ID, Actor{UserID: alice}); err != nil {
+		t.Fatalf("DeletePost: %v", err)
+	}
+
+	listings := []struct {
+		name string
+		list func() ([]*Post, error)
+	}{
+		{
+			name: "author's posts",
+			list: func() ([]*Post, error) {
+				page, err := f.service.ListPosts(ctx, bob, ListPostsFilter{AuthorID: &alice}, ListPostsQuery{Limit: 20})
+				if err != nil {
+					return nil, err
+				}
+				return page.Posts, nil
+			},
+		},
+		{
+			name: "feed read from the timeline",
+			list: func() ([]*Post, error) {
+				page, err := f.service.GetFeed(ctx, bob, ListPostsQuery{Limit: 20})
+				if err != nil {
+					return nil, err
+				}
+				return page.Posts, nil
+			},
+		},
+		{
+			name: "feed read from the database",
+			list: func() ([]*Post, error) {
+				f.redis.Del(timelineKey(bob))
+				page, err := f.service.GetFeed(ctx, bob, ListPostsQuery{Limit: 20})
+				if err != nil {
+					return nil, err
+				}
+				return page.Posts, nil
+			},
+		},
+		{
+			name: "saved posts",
+			list: func() ([]*Post, error) { return f.service.GetSavedPosts(ctx, bob, 20, 0) },
+		},
+	}
+	for _, tt := range listings {
+		t.Run(tt.name, func(t *testing.T) {
+			posts, err := tt.list()
+			if err != nil {
+				t.Fatalf("listing failed: %v", err)
+			}
+			for _, post := range posts {
+				if post.ID == deleted.ID {
+					t.Errorf("listed the deleted post")
+				}
+			}
+			if want := tt.name != "saved posts"; slices.ContainsFunc(posts, func(p *Post) bool { return p.ID == kept.ID }) != want {
+				t.Errorf("listed the kept post = %v, want %v", !want, want)
+			}
+		})
+	}
+
+	reads := []struct {
+		name string
+		read func() error
+	}{
+		{"author reads it", func() error { _, err := f.service.GetPost(ctx, deleted.ID, alice); return err }},
+		{"follower reads it", func() error { _, err := f.service.GetPost(ctx, deleted.ID, bob); return err }},
+		{"follower likes it", func() error { return f.service.LikePost(ctx, deleted.ID, bob) }},
+		{"author edits it", func() error {
+			_, err := f.service.UpdatePost(ctx, deleted.ID, Actor{UserID: alice}, UpdatePostInput{Title: ptr("Edited")})
+			return err
+		}},
+		{"author deletes it again", func() error { return f.service.DeletePost(ctx, deleted.ID, Actor{UserID: alice}) }},
+	}
+	for _, tt := range reads {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.read(); !errors.Is(err, ErrPostNotFound) {
+				t.Errorf("error = %v, want ErrPostNotFound", err)
+			}
+		})
+	}
+
+	t.Run("admin hard-deletes it", func(t *testing.T) {
+		if err := f.service.HardDeletePost(ctx, deleted.ID); err != nil {
+			t.Fatalf("HardDeletePost: %v", err)
+		}
+		if _, ok := f.repo.posts[deleted.ID]; ok {
+			t.Error("post still stored after the hard delete")
+		}
+	})
+}
+
+func TestUpdatePostVersions(t *testing.T) {
+	ctx := context.Background()
+	authorID := uuid.New()
+	author := Actor{UserID: authorID}
+
+	t.Run("edits bump the version", func(t *testing.T) {
+		f := newPostFixture(t)
+		post := f.createPost(t, authorID, "hello")
+
+		updated, err := f.service.UpdatePost(ctx, post.ID, author, UpdatePostInput{Title: ptr("First"), Version: post.Version})
+		if err != nil {
+			t.Fatalf("UpdatePost: %v", err)
+		}
+		if updated.Version != post.Version+1 || f.repo.posts[post.ID].Version != post.Version+1 {
+			t.Errorf("version = %d, stored %d; want %d", updated.Version, f.repo.posts[post.ID].Version, post.Version+1)
+		}
+
+		if _, err := f.service.UpdatePost(ctx, post.ID, author, UpdatePostInput{Title: ptr("Second"), Version: updated.Version}); err != nil {
+			t.Fatalf("UpdatePost from the new version: %v", err)
+		}
+		if stored := f.repo.posts[post.ID]; stored.Title != "Second" || stored.Version != post.Version+2 {
+			t.Errorf("stored %q at version %d, want %q at %d", stored.Title, stored.Version, "Second", post.Version+2)
+		}
+	})
+
+	t.Run("an edit from a stale version conflicts", func(t *testing.T) {
+		f := newPostFixture(t)
+		post := f.createPost(t, authorID, "hello")
+
+		if _, err := f.service.UpdatePost(ctx, post.ID, author, UpdatePostInput{Title: ptr("First"), Version: post.Version}); err != nil {
+			t.Fatalf("first UpdatePost: %v", err)
+		}
+		_, err := f.service.UpdatePost(ctx, post.ID, author, UpdatePostInput{Title: ptr("Second"), Version: post.Version})
+		if !errors.Is(err, ErrVersionConflict) {
+			t.Fatalf("second UpdatePost error = %v, want %v", err, ErrVersionConflict)
+		}
+		if stored := f.repo.posts[post.ID]; stored.Title != "First" {
+			t.Errorf("stored title %q, want the first edit's", stored.Title)
+		}
+	})
+
+	t.Run("racing edits from the same version", func(t *testing.T) {
+		f := newPostFixture(t)
+		post := f.createPost(t, authorID, "hello")
+
+		// The second edit lands after the first one read the post but
+		// before it writes
+		var raced error
+		f.repo.beforeUpdate = func() {
+			_, raced = f.service.UpdatePost(ctx, post.ID, author, UpdatePostInput{Title: ptr("Second"), Version: post.Version})
+		}
+		_, err := f.service.UpdatePost(ctx, post.ID, author, UpdatePostInput{Title: ptr("First"), Version: post.Version})
+		if raced != nil {
+			t.Fatalf("edit that wrote first: %v", raced)
+		}
+		if !errors.Is(err, ErrVersionConflict) {
+			t.Fatalf("edit that wrote second: error = %v, want %v", err, ErrVersionConflict)
+		}
+		if stored := f.repo.posts[post.ID]; stored.Title != "Second" || stored.Version != post.Version+1 {
+			t.Errorf("stored %q at version %d, want %q at %d", stored.Title, stored.Version, "Second", post.Version+1)
+		}
+	})
+}
+
+func TestGetPostsByIDs(t *testing.T) {
+	f := newPostFixture(t)
+	ctx := context.Background()
+	alice, bob := uuid.New(), uuid.New()
+	first := f.createPost(t, alice, "first")
+	second := f.createPost(t, alice, "second")
+	third := f.createPost(t, alice, "third")
+	deleted := f.createPost(t, alice, "deleted")
+	if err := f.service.DeletePost(ctx, deleted.ID, Actor{UserID: alice}); err != nil {
+		t.Fatalf("DeletePost: %v", err)
+	}
+
+	tooMany := make([]uuid.UUID, MaxBatchSize+1)
+	for i := range tooMany {
+		tooMany[i] = uuid.New()
+	}
+	atLimit := append(slices.Clone(tooMany[:MaxBatchSize-1]), first.ID)
+
+	tests := []struct {
+		name    string
+		ids     []uuid.UUID
+		want    []uuid.UUID
+		wantErr error
+	}{
+		{name: "in the order requested", ids: []uuid.UUID{third.ID, first.ID, second.ID}, want: []uuid.UUID{third.ID, first.ID, second.ID}},
+		{name: "missing and deleted posts left out", ids: []uuid.UUID{uuid.New(), second.ID, deleted.ID, first.ID}, want: []uuid.UUID{second.ID, first.ID}},
+		{name: "repeated IDs returned once", ids: []uuid.UUID{first.ID, second.ID, first.ID}, want: []uuid.UUID{first.ID, second.ID}},
+		{name: "at the limit", ids: atLimit, want: []uuid.UUID{first.ID}},
+		{name: "over the limit", ids: tooMany, wantErr: ErrBatchTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			posts, err := f.service.GetPostsByIDs(ctx, tt.ids, bob)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			var got []uuid.UUID
+			for _, post := range posts {
+				got = append(got, post.ID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("posts = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetVisibleByIDs(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.MigratedPool(t)
+	repo := NewRepository(db)
+
+	addUser := func(username string, private bool) uuid.UUID {
+		t.Helper()
+		id := uuid.New()
+		_, err := db.Exec(ctx, `INSERT INTO users (id, email, username, is_private) VALUES ($1, $2, $3, $4)`, id, username+"@example.com", username, private)
+		if err != nil {
+			t.Fatalf("adding %s: %v", username, err)
+		}
+		return id
+	}
+	viewerID := addUser("viewer", false)
+	publicID, privateID, followedID, blockerID := addUser("public", false), addUser("private", true), addUser("followed", true), addUser("blocker", false)
+	if _, err := db.Exec(ctx, `INSERT INTO followers (follower_id, following_id) VALUES ($1, $2)`, viewerID, followedID); err != nil {
+		t.Fatalf("following: %v", err)
+	}
+	if _, err := db.Exec(ctx, `INSERT INTO blocks (blocker_id, blocked_id) VALUES ($1, $2)`, blockerID, viewerID); err != nil {
+		t.Fatalf("blocking: %v", err)
+	}
+
+	addPost := func(authorID uuid.UUID, status string, private bool) uuid.UUID {
+		t.Helper()
+		now := time.Now()
+		p := &Post{
+			ID:             uuid.New(),
+			UserID:         authorID,
+			Title:          "Title",
+			IsPrivate:      private,
+			Status:         status,
+			SearchLanguage: "english",
+			CreatedAt:      now,
+			UpdatedAt:      now,
+		}
+		if err := repo.Create(ctx, p); err != nil {
+			t.Fatalf("creating a post: %v", err)
+		}
+		return p.ID
+	}
+	public := addPost(publicID, StatusPublished, false)
+	privatePost := addPost(publicID, StatusPublished, true)
+	draft := addPost(publicID, StatusDraft, false)
+	privateAuthor := addPost(privateID, StatusPublished, false)
+	followed := addPost(followedID, StatusPublished, false)
+	blocked := addPost(blockerID, StatusPublished, false)
+	own := addPost(viewerID, StatusPublished, true)
+	ownDraft := addPost(viewerID, StatusDraft, false)
+
+	posts, err := repo.GetVisibleByIDs(ctx, []uuid.UUID{public, privatePost, draft, privateAuthor, followed, blocked, own, ownDraft, uuid.New()}, viewerID)
+	if err != nil {
+		t.Fatalf("GetVisibleByIDs: %v", err)
+	}
+	got := make(map[uuid.UUID]bool)
+	for _, p := range posts {
+		got[p.ID] = true
+	}
+	want := map[uuid.UUID]bool{public: true, followed: true, own: true}
+	if !maps.Equal(got, want) {
+		t.Errorf("visible posts = %v, want %v", got, want)
+	}
+}
+
+func TestListPostsCountModes(t *testing.T) {
+	tests := []struct {
+		mode          CountMode
+		wantTotal     string // As derefCount formats it
+		wantEstimated bool
+	}{
+		{mode: CountExact, wantTotal: "3"},
+		{mode: CountEstimate, wantTotal: "1000", wantEstimated: true},
+		{mode: CountNone, wantTotal: "none"},
+		{mode: "", wantTotal: "none"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			f := newPostFixture(t)
+			f.repo.estimate = 1000
+			authorID := uuid.New()
+			for range 3 {
+				f.createPost(t, authorID, "hello")
+			}
+			f.createPost(t, uuid.New(), "someone else's")
+
+			page, err := f.service.ListPosts(context.Background(), authorID, ListPostsFilter{AuthorID: &authorID}, ListPostsQuery{Limit: 2, Count: tt.mode})
+			if err != nil {
+				t.Fatalf("ListPosts: %v", err)
+			}
+			if len(page.Posts) != 2 || page.NextCursor == "" {
+				t.Errorf("listed %d posts with cursor %q, want 2 and more", len(page.Posts), page.NextCursor)
+			}
+			if got := derefCount(page.TotalCount); got != tt.wantTotal {
+				t.Errorf("total = %s, want %s", got, tt.wantTotal)
+			}
+			if page.TotalCountEstimated != tt.wantEstimated {
+				t.Errorf("estimated = %v, want %v", page.TotalCountEstimated, tt.wantEstimated)
+			}
+		})
+	}
+}
+
+// derefCount formats a total count, which may be nil
+func derefCount(count *int64) string {
+	if count == nil {
+		return "none"
+	}
+	return strconv.FormatInt(*count, 10)
+}
@@ -0,0 +1,185 @@
+package post
+
+import (
+	"context"
+	"fmt"
+
+	"fowergram-backend/internal/infra/database"
+
+	"github.com/google/uuid"
+)
+
+// insertTags stores a new post's tags and bumps each tag's post_count
+func insertTags(ctx context.Context, tx database.Querier, post *Post) error {
+	insertQuery := `
+		INSERT INTO post_tags (post_id, tag, created_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (post_id, tag) DO NOTHING
+	`
+	counterQuery := `
+		INSERT INTO hashtags (name, post_count, created_at, updated_at)
+		VALUES ($1, 1, $2, $2)
+		ON CONFLICT (name) DO UPDATE
+		SET post_count = hashtags.post_count + 1, updated_at = EXCLUDED.updated_at
+	`
+
+	for _, tag := range post.Tags {
+		if _, err := tx.Exec(ctx, insertQuery, post.ID, tag, post.CreatedAt); err != nil {
+			return fmt.Errorf("failed to tag post: %w", err)
+		}
+		if _, err := tx.Exec(ctx, counterQuery, tag, post.CreatedAt); err != nil {
+			return fmt.Errorf("failed to update tag count: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// deleteTags removes a post's tags and decrements each tag's post_count
+func deleteTags(ctx context.Context, tx database.Querier, postID uuid.UUID) error {
+	query := `
+		WITH removed AS (
+			DELETE FROM post_tags
+			WHERE post_id = $1
+			RETURNING tag
+		)
+		UPDATE hashtags h
+		SET post_count = GREATEST(h.post_count - 1, 0), updated_at = NOW()
+		FROM removed
+		WHERE h.name = removed.tag
+	`
+
+	if _, err := tx.Exec(ctx, query, postID); err != nil {
+		return fmt.Errorf("failed to remove post tags: %w", err)
+	}
+
+	return nil
+}
+
+// loadTags attaches tags to the given posts with a single query
+func (r *postgresRepository) loadTags(ctx context.Context, posts []*Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+
+	byID := make(map[uuid.UUID]*Post, len(posts))
+	ids := make([]uuid.UUID, 0, len(posts))
+	for _, p := range posts {
+		byID[p.ID] = p
+		ids = append(ids, p.ID)
+	}
+
+	query := `
+		SELECT post_id, tag
+		FROM post_tags
+		WHERE post_id = ANY($1)
+		ORDER BY post_id, created_at, tag
+	`
+
+	rows, err := r.db.Query(ctx, query, ids)
+	if err != nil {
+		return fmt.Errorf("failed to get post tags: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			postID uuid.UUID
+			tag    string
+		)
+		if err := rows.Scan(&postID, &tag); err != nil {
+			return fmt.Errorf("failed to scan post tag: %w", err)
+		}
+		if p, ok := byID[postID]; ok {
+			p.Tags = append(p.Tags, tag)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to iterate post tags: %w", err)
+	}
+
+	return nil
+}
+
+// GetVisibleByTag retrieves posts with a tag that the viewer may see, newest first
+func (r *postgresRepository) GetVisibleByTag(ctx context.Context, tag string, viewerID uuid.UUID, limit, offset int) ([]*Post, error) {
+	query := `SELECT ` + postColumns + `
+		FROM post_tags pt
+		JOIN posts p ON p.id = pt.post_id
+		JOIN users u ON u.id = p.user_id
+		WHERE pt.tag = $1 AND p.deleted_at IS NULL AND u.is_active = true
+			AND ` + publishedClause + ` AND ` + visibilityClause("$2") + `
+		ORDER BY pt.created_at DESC, pt.post_id DESC
+		LIMIT $3 OFFSET $4
+	`
+
+	rows, err := r.db.Query(ctx, query, tag, viewerID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tagged posts: %w", err)
+	}
+	defer rows.Close()
+
+	var posts []*Post
+	for rows.Next() {
+		post, err := scanPost(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan post: %w", err)
+		}
+		posts = append(posts, post)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate posts: %w", err)
+	}
+
+	if err := r.loadDetails(ctx, posts); err != nil {
+		return nil, err
+	}
+
+	return posts, nil
+}
+
+// SearchTags retrieves tags starting with prefix, most used first
+func (r *postgresRepository) SearchTags(ctx context.Context, prefix string, limit int) ([]*Tag, error) {
+	query := `
+		SELECT name, post_count
+		FROM hashtags
+		WHERE name LIKE $1 || '%' AND post_count > 0
+		ORDER BY post_count DESC, name
+		LIMIT $2
+	`
+
+	rows, err := r.db.Query(ctx, query, escapeLike(prefix), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search tags: %w", err)
+	}
+	defer rows.Close()
+
+	tags := []*Tag{}
+	for rows.Next() {
+		tag := &Tag{}
+		if err := rows.Scan(&tag.Name, &tag.PostCount); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		tags = append(tags, tag)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate tags: %w", err)
+	}
+
+	return tags, nil
+}
+
+// escapeLike escapes LIKE wildcards so user input matches literally
+func escapeLike(s string) string {
+	var b []rune
+	for _, r := range s {
+		if r == '\\' || r == '%' || r == '_' {
+			b = append(b, '\\')
+		}
+		b = append(b, r)
+	}
+	return string(b)
+}
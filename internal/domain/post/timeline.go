@@ -0,0 +1,446 @@
+package post
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"fowergram-backend/internal/events"
+	"fowergram-backend/internal/infra/messaging"
+	"fowergram-backend/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// Home timelines are built on write: a new post's ID is pushed into the
+// timeline of each follower. Accounts with more followers than
+// FanoutFollowerThreshold are skipped on write and merged in on read instead.
+const (
+	FanoutFollowerThreshold = 10000
+	TimelineCap             = 800
+)
+
+// timelineKeyPrefix prefixes the Redis sorted sets holding home timelines,
+// scored by post creation time. A sorted set rather than a list keeps
+// redelivered events and backfills from adding a post twice.
+const timelineKeyPrefix = "timeline:"
+
+// timelineTTL drops the timelines of users who stop reading them; they are
+// rebuilt from the database on the next read
+const timelineTTL = 72 * time.Hour
+
+// timelineBackfillSize is how many recent posts of a newly followed account
+// are added to the follower's timeline
+const timelineBackfillSize = 50
+
+// fanoutBatchSize bounds the commands sent in a single pipeline
+const fanoutBatchSize = 500
+
+// fanoutQueue is the NATS queue group shared by every fan-out worker
+const fanoutQueue = "timeline-fanout"
+
+// timelineAddScript adds posts to a timeline that exists and trims it to the
+// cap. Missing timelines are left alone so a partial one is never mistaken
+// for a complete timeline; they are rebuilt on read.
+// ARGV: cap, then score and member pairs.
+var timelineAddScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+for i = 2, #ARGV, 2 do
+	redis.call('ZADD', KEYS[1], ARGV[i], ARGV[i + 1])
+end
+redis.call('ZREMRANGEBYRANK', KEYS[1], 0, -tonumber(ARGV[1]) - 1)
+return 1
+`)
+
+func timelineKey(userID uuid.UUID) string {
+	return timelineKeyPrefix + userID.String()
+}
+
+func timelineScore(t time.Time) float64 {
+	return float64(t.UnixMicro())
+}
+
+// TimelineEntry is a post's place in a home timeline
+type TimelineEntry struct {
+	PostID    uuid.UUID
+	CreatedAt time.Time
+}
+
+// ListTimelineEntries retrieves the newest published posts by the given
+// authors, skipping authors with more than maxAuthorFollowers followers.
+// Only IDs and times are read; visibility is checked when the timeline is read.
+func (r *postgresRepository) ListTimelineEntries(ctx context.Context, authorIDs []uuid.UUID, maxAuthorFollowers, limit int) ([]TimelineEntry, error) {
+	if len(authorIDs) == 0 {
+		return nil, nil
+	}
+
+	query := `
+		SELECT p.id, p.created_at
+		FROM posts p
+		JOIN users u ON u.id = p.user_id
+		WHERE p.user_id = ANY($1) AND u.followers_count <= $2
+			AND p.deleted_at IS NULL AND u.is_active = true AND ` + publishedClause + `
+		ORDER BY p.created_at DESC, p.id DESC
+		LIMIT $3
+	`
+
+	rows, err := r.db.Query(ctx, query, authorIDs, maxAuthorFollowers, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list timeline entries: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []TimelineEntry
+	for rows.Next() {
+		var e TimelineEntry
+		if err := rows.Scan(&e.PostID, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan timeline entry: %w", err)
+		}
+		entries = append(entries, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate timeline entries: %w", err)
+	}
+
+	return entries, nil
+}
+
+// FanOutPost adds a new post to the timelines of the author's followers.
+// Authors above FanoutFollowerThreshold are skipped; their posts are merged
+// in when timelines are read. Adding the same post again is a no-op.
+func (s *service) FanOutPost(ctx context.Context, event events.PostCreated) error {
+	author, err := s.userRepo.GetUserByID(ctx, event.AuthorID)
+	if err != nil {
+		return err
+	}
+	if author.FollowersCount > FanoutFollowerThreshold {
+		return nil
+	}
+
+	followerIDs, err := s.userRepo.GetFollowerIDs(ctx, event.AuthorID)
+	if err != nil {
+		return err
+	}
+
+	entry := []TimelineEntry{{PostID: event.PostID, CreatedAt: event.CreatedAt}}
+	for start := 0; start < len(followerIDs); start += fanoutBatchSize {
+		end := min(start+fanoutBatchSize, len(followerIDs))
+
+		pipe := s.redis.Pipeline()
+		for _, followerID := range followerIDs[start:end] {
+			addToTimeline(ctx, pipe, followerID, entry)
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			return fmt.Errorf("failed to fan out post: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// BackfillTimeline adds recent posts of a newly followed account to the
+// follower's timeline
+func (s *service) BackfillTimeline(ctx context.Context, followerID, followingID uuid.UUID) error {
+	entries, err := s.repo.ListTimelineEntries(ctx, []uuid.UUID{followingID}, FanoutFollowerThreshold, timelineBackfillSize)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+
+	if err := addToTimeline(ctx, s.redis, followerID, entries).Err(); err != nil {
+		return fmt.Errorf("failed to backfill timeline: %w", err)
+	}
+
+	return nil
+}
+
+// addToTimeline queues the script adding entries to an existing timeline
+func addToTimeline(ctx context.Context, c redis.Scripter, userID uuid.UUID, entries []TimelineEntry) *redis.Cmd {
+	args := make([]interface{}, 0, 1+2*len(entries))
+	args = append(args, TimelineCap)
+	for _, e := range entries {
+		args = append(args, timelineScore(e.CreatedAt), e.PostID.String())
+	}
+	return timelineAddScript.Eval(ctx, c, []string{timelineKey(userID)}, args...)
+}
+
+// rebuildTimeline fills a missing timeline from the database
+func (s *service) rebuildTimeline(ctx context.Context, userID uuid.UUID) error {
+	followingIDs, err := s.userRepo.GetFollowingIDs(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	entries, err := s.repo.ListTimelineEntries(ctx, followingIDs, FanoutFollowerThreshold, TimelineCap)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+
+	members := make([]redis.Z, 0, len(entries))
+	for _, e := range entries {
+		members = append(members, redis.Z{Score: timelineScore(e.CreatedAt), Member: e.PostID.String()})
+	}
+
+	key := timelineKey(userID)
+	_, err = s.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(ctx, key, members...)
+		pipe.ZRemRangeByRank(ctx, key, 0, -TimelineCap-1)
+		pipe.Expire(ctx, key, timelineTTL)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to rebuild timeline: %w", err)
+	}
+
+	return nil
+}
+
+// GetFeed returns the viewer's home feed, newest first: posts of followed
+// accounts read from the viewer's timeline, merged with posts of followed
+// accounts too large to fan out. When the timeline is missing, or the viewer
+// pages past its end, the feed is read from the database instead.
+func (s *service) GetFeed(ctx context.Context, viewerID uuid.UUID, q ListPostsQuery) (*Page, error) {
+	limit := q.Limit
+
+	posts, ok, err := s.readTimeline(ctx, viewerID, q.After, limit)
+	if err != nil {
+		s.logger.Error("Failed to read timeline", "user_id", viewerID, "error", err)
+	}
+	if !ok {
+		posts, err = s.repo.ListVisible(ctx, viewerID, ListPostsFilter{FollowedBy: &viewerID}, ListPostsQuery{
+			After: q.After,
+			Limit: limit + 1,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	page := newPage(posts, limit)
+	hideOwnerOnlyCounts(viewerID, page.Posts)
+
+	if err := s.repo.MarkSaved(ctx, viewerID, page.Posts); err != nil {
+		return nil, err
+	}
+
+	if err := s.attachOriginals(ctx, viewerID, page.Posts); err != nil {
+		return nil, err
+	}
+
+	for _, post := range page.Posts {
+		if err := s.resolveMediaURLs(ctx, post); err != nil {
+			return nil, err
+		}
+	}
+
+	return page, nil
+}
+
+// readTimeline reads up to limit+1 feed posts after the cursor from the
+// viewer's timeline. It reports false when the timeline can't answer: it is
+// missing (and is rebuilt for next time) or the page runs past its capped end.
+func (s *service) readTimeline(ctx context.Context, viewerID uuid.UUID, after *Cursor, limit int) ([]*Post, bool, error) {
+	rdb := s.redis
+	key := timelineKey(viewerID)
+
+	exists, err := rdb.Exists(ctx, key).Result()
+	if err != nil {
+		return nil, false, err
+	}
+	if exists == 0 {
+		if err := s.rebuildTimeline(ctx, viewerID); err != nil {
+			s.logger.Error("Failed to rebuild timeline", "user_id", viewerID, "error", err)
+		}
+		return nil, false, nil
+	}
+	rdb.Expire(ctx, key, timelineTTL)
+
+	maxScore := "+inf"
+	if after != nil {
+		maxScore = strconv.FormatFloat(timelineScore(after.CreatedAt), 'f', -1, 64)
+	}
+
+	// Read extra entries: some are dropped below as deleted, no longer
+	// visible or from accounts the viewer has since unfollowed
+	want := 2 * (limit + 1)
+	members, err := rdb.ZRevRangeByScore(ctx, key, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   maxScore,
+		Count: int64(want),
+	}).Result()
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(members) < want {
+		size, err := rdb.ZCard(ctx, key).Result()
+		if err != nil {
+			return nil, false, err
+		}
+		if size >= TimelineCap {
+			return nil, false, nil
+		}
+	}
+
+	ids := make([]uuid.UUID, 0, len(members))
+	for _, member := range members {
+		id, err := uuid.Parse(member)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+
+	fannedOut, err := s.repo.GetVisibleByIDs(ctx, ids, viewerID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	followingIDs, err := s.userRepo.GetFollowingIDs(ctx, viewerID)
+	if err != nil {
+		return nil, false, err
+	}
+	following := make(map[uuid.UUID]struct{}, len(followingIDs))
+	for _, id := range followingIDs {
+		following[id] = struct{}{}
+	}
+
+	posts := make([]*Post, 0, len(fannedOut))
+	for _, post := range fannedOut {
+		if _, ok := following[post.UserID]; !ok {
+			continue
+		}
+		if after != nil && !before(post, after) {
+			continue
+		}
+		posts = append(posts, post)
+	}
+
+	large, err := s.repo.ListVisible(ctx, viewerID, ListPostsFilter{
+		FollowedBy:         &viewerID,
+		MinAuthorFollowers: FanoutFollowerThreshold + 1,
+	}, ListPostsQuery{After: after, Limit: limit + 1})
+	if err != nil {
+		return nil, false, err
+	}
+
+	return mergeNewestFirst(posts, large), true, nil
+}
+
+// before reports whether the post sorts after the cursor in a newest-first listing
+func before(post *Post, c *Cursor) bool {
+	if !post.CreatedAt.Equal(c.CreatedAt) {
+		return post.CreatedAt.Before(c.CreatedAt)
+	}
+	return post.ID.String() < c.ID.String()
+}
+
+// mergeNewestFirst combines post lists into one ordered like ListVisible,
+// dropping duplicates
+func mergeNewestFirst(lists ...[]*Post) []*Post {
+	seen := make(map[uuid.UUID]struct{})
+	var merged []*Post
+	for _, list := range lists {
+		for _, post := range list {
+			if _, ok := seen[post.ID]; ok {
+				continue
+			}
+			seen[post.ID] = struct{}{}
+			merged = append(merged, post)
+		}
+	}
+
+	sort.Slice(merged, func(i, j int) bool {
+		if !merged[i].CreatedAt.Equal(merged[j].CreatedAt) {
+			return merged[i].CreatedAt.After(merged[j].CreatedAt)
+		}
+		return merged[i].ID.String() > merged[j].ID.String()
+	})
+
+	return merged
+}
+
+// FanoutWorker consumes post and follow events to keep home timelines up to
+// date. Workers share a NATS queue group, so each event is handled once.
+type FanoutWorker struct {
+	service   Service
+	messaging messaging.Client
+	logger    logger.Logger
+	subs      []*messaging.Subscription
+}
+
+// NewFanoutWorker creates a new timeline fan-out worker
+func NewFanoutWorker(service Service, messaging messaging.Client, logger logger.Logger) *FanoutWorker {
+	return &FanoutWorker{
+		service:   service,
+		messaging: messaging,
+		logger:    logger,
+	}
+}
+
+// fanoutTimeout bounds the work done for a single event
+const fanoutTimeout = time.Minute
+
+// Start subscribes the worker to new posts and follows
+func (w *FanoutWorker) Start() error {
+	dispatcher := events.NewDispatcher(fanoutTimeout, w.logger)
+	dispatcher.Handle(events.TypePostCreated, w.handleCreated)
+	dispatcher.Handle(events.TypeUserFollowed, w.handleFollowed)
+	dispatcher.Handle(events.TypeFollowRequestApproved, w.handleApproved)
+
+	subs, err := dispatcher.Subscribe(w.messaging, fanoutQueue)
+	w.subs = subs
+	return err
+}
+
+// Stop stops the worker taking new events and waits for the ones it holds to
+// be handled
+func (w *FanoutWorker) Stop(ctx context.Context) error {
+	return messaging.DrainAll(ctx, w.subs)
+}
+
+func (w *FanoutWorker) handleCreated(ctx context.Context, e *events.Envelope) error {
+	var event events.PostCreated
+	if err := e.DecodePayload(&event); err != nil {
+		return err
+	}
+
+	if err := w.service.FanOutPost(ctx, event); err != nil {
+		return fmt.Errorf("failed to fan out post %s: %w", event.PostID, err)
+	}
+	return nil
+}
+
+func (w *FanoutWorker) handleFollowed(ctx context.Context, e *events.Envelope) error {
+	var event events.UserFollowed
+	if err := e.DecodePayload(&event); err != nil {
+		return err
+	}
+	return w.backfill(ctx, event.Follow)
+}
+
+func (w *FanoutWorker) handleApproved(ctx context.Context, e *events.Envelope) error {
+	var event events.FollowRequestApproved
+	if err := e.DecodePayload(&event); err != nil {
+		return err
+	}
+	return w.backfill(ctx, event.Follow)
+}
+
+func (w *FanoutWorker) backfill(ctx context.Context, follow events.Follow) error {
+	if err := w.service.BackfillTimeline(ctx, follow.FollowerID, follow.FollowingID); err != nil {
+		return fmt.Errorf("failed to backfill timeline of %s from %s: %w", follow.FollowerID, follow.FollowingID, err)
+	}
+	return nil
+}
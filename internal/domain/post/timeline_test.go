@@ -0,0 +1,103 @@
+package post
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"fowergram-backend/internal/events"
+	"fowergram-backend/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// timeline returns the post IDs in the user's home timeline, unordered
+func (f *postFixture) timeline(t *testing.T, userID uuid.UUID) []string {
+	t.Helper()
+	if !f.redis.Exists(timelineKey(userID)) {
+		return nil
+	}
+	members, err := f.redis.ZMembers(timelineKey(userID))
+	if err != nil {
+		t.Fatalf("reading the timeline: %v", err)
+	}
+	return members
+}
+
+// redeliver publishes the last message on the event's subject again, as
+// NATS does when an acknowledgement is lost
+func (f *postFixture) redeliver(t *testing.T, event events.Type) {
+	t.Helper()
+	published := f.messaging.PublishedTo(string(event))
+	if len(published) == 0 {
+		t.Fatalf("nothing published on %s", event)
+	}
+	if err := f.messaging.Publish(string(event), published[len(published)-1].Data); err != nil {
+		t.Fatalf("redelivering: %v", err)
+	}
+}
+
+func TestTimelineFanout(t *testing.T) {
+	ctx := context.Background()
+	f := newPostFixture(t)
+	alice, bob, carol, dave := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	f.repo.follows[[2]uuid.UUID{bob, alice}] = true
+	first := f.createPost(t, alice, "first")
+
+	// Posts are only added to timelines that exist, so bob's is built by
+	// reading his feed before the worker starts
+	if _, err := f.service.GetFeed(ctx, bob, ListPostsQuery{Limit: 20}); err != nil {
+		t.Fatalf("GetFeed: %v", err)
+	}
+	if got := f.timeline(t, bob); !slices.Equal(got, []string{first.ID.String()}) {
+		t.Fatalf("rebuilt timeline = %v, want alice's post", got)
+	}
+
+	log := logger.NewZapLogger()
+	publisher := events.NewNATSPublisher(f.messaging, log)
+	if err := NewFanoutWorker(f.service, f.messaging, log).Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	// carol's post is written before bob follows her and reaches him by
+	// backfill
+	carolsPost := f.createPost(t, carol, "carol's")
+	second := f.createPost(t, alice, "second")
+
+	// Fanning out the same post again leaves a single entry
+	f.redeliver(t, events.TypePostCreated)
+	if got := f.timeline(t, bob); len(got) != 2 || !slices.Contains(got, second.ID.String()) {
+		t.Fatalf("timeline after fan-out = %v, want alice's two posts", got)
+	}
+
+	f.repo.follows[[2]uuid.UUID{bob, carol}] = true
+	followed := events.UserFollowed{Follow: events.Follow{FollowerID: bob, FollowingID: carol, CreatedAt: time.Now()}}
+	if err := publisher.Publish(ctx, bob, followed); err != nil {
+		t.Fatalf("publishing the follow: %v", err)
+	}
+	f.redeliver(t, events.TypeUserFollowed)
+	if got := f.timeline(t, bob); len(got) != 3 || !slices.Contains(got, carolsPost.ID.String()) {
+		t.Fatalf("timeline after backfill = %v, want carol's post added once", got)
+	}
+
+	// Posts by accounts too large to fan out are left to the read path
+	f.users.large[dave] = true
+	f.repo.follows[[2]uuid.UUID{bob, dave}] = true
+	davesPost := f.createPost(t, dave, "dave's")
+	if got := f.timeline(t, bob); slices.Contains(got, davesPost.ID.String()) {
+		t.Errorf("fanned out a post by an account over the threshold")
+	}
+
+	page, err := f.service.GetFeed(ctx, bob, ListPostsQuery{Limit: 2})
+	if err != nil {
+		t.Fatalf("GetFeed: %v", err)
+	}
+	var got []uuid.UUID
+	for _, p := range page.Posts {
+		got = append(got, p.ID)
+	}
+	if want := []uuid.UUID{second.ID, carolsPost.ID}; !slices.Equal(got, want) || page.NextCursor == "" {
+		t.Errorf("feed = %v with cursor %q, want %v and a cursor", got, page.NextCursor, want)
+	}
+}
@@ -0,0 +1,247 @@
+package post
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"fowergram-backend/internal/infra/cache"
+	"fowergram-backend/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// viewKeyPrefix prefixes the Redis counters that buffer views until the next flush
+const viewKeyPrefix = "post_views:"
+
+// viewScanCount is the SCAN batch size used when collecting view counters
+const viewScanCount = 100
+
+func viewKey(postID uuid.UUID) string {
+	return viewKeyPrefix + postID.String()
+}
+
+// AddViews adds flushed view counts to posts. Posts that no longer exist
+// or were deleted are skipped.
+func (r *postgresRepository) AddViews(ctx context.Context, counts map[uuid.UUID]int64) error {
+	if len(counts) == 0 {
+		return nil
+	}
+
+	ids := make([]uuid.UUID, 0, len(counts))
+	views := make([]int64, 0, len(counts))
+	for id, n := range counts {
+		ids = append(ids, id)
+		views = append(views, n)
+	}
+
+	query := `
+		UPDATE posts p
+		SET views_count = p.views_count + v.views
+		FROM unnest($1::uuid[], $2::bigint[]) AS v(post_id, views)
+		WHERE p.id = v.post_id AND p.deleted_at IS NULL
+	`
+
+	if _, err := r.db.Exec(ctx, query, ids, views); err != nil {
+		return fmt.Errorf("failed to add post views: %w", err)
+	}
+
+	return nil
+}
+
+// recordView buffers a view of the post in Redis. The author's own views
+// aren't counted. Failures are logged, not returned.
+func (s *service) recordView(ctx context.Context, post *Post, viewerID uuid.UUID) {
+	if post.UserID == viewerID {
+		return
+	}
+
+	if err := s.redis.Incr(ctx, viewKey(post.ID)).Err(); err != nil {
+		s.logger.Error("Failed to record post view", "post_id", post.ID, "error", err)
+	}
+}
+
+// hideOwnerOnlyCounts clears the view count, and the like count when the
+// author hid it, on posts the viewer didn't author
+func hideOwnerOnlyCounts(viewerID uuid.UUID, posts []*Post) {
+	for _, post := range posts {
+		if post.UserID == viewerID {
+			continue
+		}
+		post.ViewsCount = nil
+		if post.HideLikeCount {
+			post.LikesCount = nil
+		}
+	}
+}
+
+// ViewFlusher periodically moves buffered view counts from Redis to Postgres
+type ViewFlusher struct {
+	repo     Repository
+	redis    *redis.Client
+	interval time.Duration
+	logger   logger.Logger
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// NewViewFlusher creates a flusher that runs every interval once started
+func NewViewFlusher(repo Repository, cache *cache.RedisCache, interval time.Duration, logger logger.Logger) *ViewFlusher {
+	return &ViewFlusher{
+		repo:     repo,
+		redis:    cache.GetClient(),
+		interval: interval,
+		logger:   logger,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start runs the flush loop in the background until Stop is called
+func (f *ViewFlusher) Start() {
+	go func() {
+		defer close(f.done)
+
+		ticker := time.NewTicker(f.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				f.flushAndLog(context.Background())
+			case <-f.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the flush loop and flushes once more so buffered views aren't
+// left behind until the next start
+func (f *ViewFlusher) Stop(ctx context.Context) error {
+	f.stopOnce.Do(func() { close(f.stop) })
+
+	select {
+	case <-f.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	return f.Flush(ctx)
+}
+
+func (f *ViewFlusher) flushAndLog(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, f.interval)
+	defer cancel()
+
+	if err := f.Flush(ctx); err != nil {
+		f.logger.Error("Failed to flush post views", "error", err)
+	}
+}
+
+// Flush takes every buffered counter out of Redis and adds it to the posts
+// table. Counters are read with GETDEL so views recorded during a flush land
+// in a fresh key; if the database write fails they are added back.
+func (f *ViewFlusher) Flush(ctx context.Context) error {
+	counts := make(map[uuid.UUID]int64)
+
+	var cursor uint64
+	for {
+		keys, next, err := f.redis.Scan(ctx, cursor, viewKeyPrefix+"*", viewScanCount).Result()
+		if err != nil {
+			return fmt.Errorf("failed to scan post views: %w", err)
+		}
+
+		if err := f.take(ctx, keys, counts); err != nil {
+			f.restore(counts)
+			return err
+		}
+
+		cursor = next
+		if cursor == 0 {
+			break
+		}
+	}
+
+	if err := f.repo.AddViews(ctx, counts); err != nil {
+		f.restore(counts)
+		return err
+	}
+
+	f.invalidate(ctx, counts)
+	return nil
+}
+
+// invalidate drops the cached copies of posts whose view counts were flushed
+func (f *ViewFlusher) invalidate(ctx context.Context, counts map[uuid.UUID]int64) {
+	if len(counts) == 0 {
+		return
+	}
+
+	keys := make([]string, 0, len(counts))
+	for postID := range counts {
+		keys = append(keys, postCacheKey(postID))
+	}
+	if err := f.redis.Del(ctx, keys...).Err(); err != nil {
+		f.logger.Error("Failed to invalidate cached posts", "posts", len(keys), "error", err)
+	}
+}
+
+// take reads and removes the given counters, adding them to counts. Keys that
+// don't name a post are dropped.
+func (f *ViewFlusher) take(ctx context.Context, keys []string, counts map[uuid.UUID]int64) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	pipe := f.redis.Pipeline()
+	cmds := make([]*redis.StringCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.GetDel(ctx, key)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return fmt.Errorf("failed to read post views: %w", err)
+	}
+
+	for i, key := range keys {
+		postID, err := uuid.Parse(strings.TrimPrefix(key, viewKeyPrefix))
+		if err != nil {
+			f.logger.Error("Dropping malformed post view key", "key", key)
+			continue
+		}
+
+		n, err := cmds[i].Int64()
+		if err != nil {
+			if err != redis.Nil {
+				f.logger.Error("Dropping unreadable post view count", "key", key, "error", err)
+			}
+			continue
+		}
+		counts[postID] += n
+	}
+
+	return nil
+}
+
+// restore puts counts that couldn't be written back into Redis for the next flush
+func (f *ViewFlusher) restore(counts map[uuid.UUID]int64) {
+	if len(counts) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	pipe := f.redis.Pipeline()
+	for postID, n := range counts {
+		pipe.IncrBy(ctx, viewKey(postID), n)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		f.logger.Error("Failed to restore post views", "posts", len(counts), "error", err)
+	}
+}
@@ -0,0 +1,157 @@
+package post
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"fowergram-backend/internal/infra/cache"
+	"fowergram-backend/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// newTestViewFlusher returns a flusher over the fixture's Redis and posts
+func newTestViewFlusher(t *testing.T, f *postFixture) *ViewFlusher {
+	t.Helper()
+	redisCache, err := cache.NewRedisCache(context.Background(), "redis://"+f.redis.Addr())
+	if err != nil {
+		t.Fatalf("NewRedisCache: %v", err)
+	}
+	t.Cleanup(func() { redisCache.Close() })
+	return NewViewFlusher(f.repo, redisCache, time.Hour, logger.NewZapLogger())
+}
+
+// storedViews returns the flushed view count of a post
+func (f *postFixture) storedViews(id uuid.UUID) int64 {
+	f.repo.mu.Lock()
+	defer f.repo.mu.Unlock()
+	if views := f.repo.posts[id].ViewsCount; views != nil {
+		return *views
+	}
+	return 0
+}
+
+func TestViewCounting(t *testing.T) {
+	ctx := context.Background()
+	f := newPostFixture(t)
+	authorID, viewerID := uuid.New(), uuid.New()
+	post := f.createPost(t, authorID, "hello")
+
+	for _, id := range []uuid.UUID{viewerID, viewerID, authorID} {
+		if _, err := f.service.GetPost(ctx, post.ID, id); err != nil {
+			t.Fatalf("GetPost: %v", err)
+		}
+	}
+	if got, _ := f.redis.Get(viewKey(post.ID)); got != "2" {
+		t.Errorf("buffered views = %q, want 2 without the author's", got)
+	}
+
+	if err := newTestViewFlusher(t, f).Flush(ctx); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if got := f.storedViews(post.ID); got != 2 {
+		t.Errorf("stored views = %d, want 2", got)
+	}
+	if f.redis.Exists(viewKey(post.ID)) {
+		t.Error("counter left in Redis after the flush")
+	}
+
+	// Served from the repository again, as the flush changed the count
+	f.cache.Delete(ctx, postCacheKey(post.ID))
+	seen, err := f.service.GetPost(ctx, post.ID, viewerID)
+	if err != nil {
+		t.Fatalf("GetPost: %v", err)
+	}
+	if seen.ViewsCount != nil {
+		t.Errorf("viewer sees %d views, want them hidden", *seen.ViewsCount)
+	}
+	own, err := f.service.GetPost(ctx, post.ID, authorID)
+	if err != nil {
+		t.Fatalf("GetPost: %v", err)
+	}
+	// The viewer's last view waits for the next flush
+	if own.ViewsCount == nil {
+		t.Error("author doesn't see the view count")
+	} else if *own.ViewsCount != 2 {
+		t.Errorf("author sees %d views, want 2", *own.ViewsCount)
+	}
+}
+
+func TestViewFlusher(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("counters of deleted and missing posts are dropped", func(t *testing.T) {
+		f := newPostFixture(t)
+		live := f.createPost(t, uuid.New(), "live")
+		deleted := f.createPost(t, uuid.New(), "deleted")
+		if err := f.repo.Delete(ctx, deleted.ID); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+		missing := uuid.New()
+
+		counters := map[string]string{
+			viewKey(live.ID):      "3",
+			viewKey(deleted.ID):   "5",
+			viewKey(missing):      "7",
+			viewKeyPrefix + "bad": "1",
+		}
+		for key, n := range counters {
+			f.redis.Set(key, n)
+		}
+
+		if err := newTestViewFlusher(t, f).Flush(ctx); err != nil {
+			t.Fatalf("Flush: %v", err)
+		}
+		if got := f.storedViews(live.ID); got != 3 {
+			t.Errorf("live post views = %d, want 3", got)
+		}
+		if got := f.storedViews(deleted.ID); got != 0 {
+			t.Errorf("deleted post views = %d, want none", got)
+		}
+		for key := range counters {
+			if f.redis.Exists(key) {
+				t.Errorf("%s left in Redis, to be flushed again", key)
+			}
+		}
+	})
+
+	t.Run("counters are kept when the write fails", func(t *testing.T) {
+		f := newPostFixture(t)
+		post := f.createPost(t, uuid.New(), "hello")
+		f.redis.Set(viewKey(post.ID), "4")
+		f.repo.viewsErr = errors.New("connection reset")
+
+		flusher := newTestViewFlusher(t, f)
+		if err := flusher.Flush(ctx); !errors.Is(err, f.repo.viewsErr) {
+			t.Fatalf("Flush error = %v, want %v", err, f.repo.viewsErr)
+		}
+		if got, _ := f.redis.Get(viewKey(post.ID)); got != "4" {
+			t.Fatalf("buffered views after the failure = %q, want 4", got)
+		}
+
+		f.repo.viewsErr = nil
+		if err := flusher.Flush(ctx); err != nil {
+			t.Fatalf("Flush: %v", err)
+		}
+		if got := f.storedViews(post.ID); got != 4 {
+			t.Errorf("stored views = %d, want 4", got)
+		}
+	})
+
+	t.Run("stopping flushes what is buffered", func(t *testing.T) {
+		f := newPostFixture(t)
+		post := f.createPost(t, uuid.New(), "hello")
+		f.redis.Set(viewKey(post.ID), "2")
+
+		flusher := newTestViewFlusher(t, f)
+		flusher.Start()
+		if err := flusher.Stop(ctx); err != nil {
+			t.Fatalf("Stop: %v", err)
+		}
+		if got := f.storedViews(post.ID); got != 2 {
+			t.Errorf("stored views = %d, want 2", got)
+		}
+	})
+}
@@ -0,0 +1,60 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"fowergram-backend/pkg/auth"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// SeedAdmin creates a verified account with the admin role, for bootstrapping
+// a fresh deployment. When an account with the email already exists it is
+// given the admin role rather than created again, so seeding can be repeated.
+// It reports whether the account was created.
+func SeedAdmin(ctx context.Context, repo Repository, email, username, password string) (*auth.User, bool, error) {
+	existing, err := repo.GetUserByEmail(ctx, email)
+	if err == nil {
+		if err := repo.GrantRole(ctx, existing.ID, auth.RoleAdmin); err != nil {
+			return nil, false, err
+		}
+		if !existing.HasRole(auth.RoleAdmin) {
+			existing.Roles = append(existing.Roles, auth.RoleAdmin)
+		}
+		return existing, false, nil
+	}
+	if !errors.Is(err, auth.ErrUserNotFound) {
+		return nil, false, err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	now := time.Now()
+	admin := &auth.User{
+		ID:             uuid.New(),
+		Email:          email,
+		Username:       username,
+		HashedPassword: string(hashedPassword),
+		IsActive:       true,
+		IsVerified:     true,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if err := repo.CreateUser(ctx, admin); err != nil {
+		return nil, false, err
+	}
+	if err := repo.GrantRole(ctx, admin.ID, auth.RoleAdmin); err != nil {
+		return nil, false, err
+	}
+
+	admin.HashedPassword = ""
+	admin.Roles = []string{auth.RoleAdmin}
+	return admin, true, nil
+}
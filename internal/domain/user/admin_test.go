@@ -0,0 +1,128 @@
+package user
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"fowergram-backend/internal/infra/database/dbtest"
+	"fowergram-backend/pkg/auth"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func (r *fakeRepository) GetUserByEmail(ctx context.Context, email string) (*auth.User, error) {
+	for _, user := range r.users {
+		if user.Email == email {
+			copied := *user
+			return &copied, nil
+		}
+	}
+	return nil, auth.ErrUserNotFound
+}
+
+func (r *fakeRepository) CreateUser(ctx context.Context, user *auth.User) error {
+	copied := *user
+	r.users[user.ID] = &copied
+	return nil
+}
+
+func (r *fakeRepository) GrantRole(ctx context.Context, userID uuid.UUID, role string) error {
+	user, ok := r.users[userID]
+	if ok && !slices.Contains(user.Roles, role) {
+		user.Roles = append(user.Roles, role)
+	}
+	return nil
+}
+
+func TestSeedAdmin(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeRepository()
+	existingID := repo.addUser("bob", false)
+	repo.users[existingID].Email = "bob@example.com"
+
+	tests := []struct {
+		name        string
+		email       string
+		wantCreated bool
+		wantID      uuid.UUID // Zero for a new account
+	}{
+		{name: "new account", email: "admin@example.com", wantCreated: true},
+		{name: "seeded again", email: "admin@example.com"},
+		{name: "existing user", email: "bob@example.com", wantID: existingID},
+		{name: "existing user seeded again", email: "bob@example.com", wantID: existingID},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			admin, created, err := SeedAdmin(ctx, repo, tt.email, "admin", "correct horse")
+			if err != nil {
+				t.Fatalf("SeedAdmin: %v", err)
+			}
+			if created != tt.wantCreated {
+				t.Errorf("created = %v, want %v", created, tt.wantCreated)
+			}
+			if tt.wantID != uuid.Nil && admin.ID != tt.wantID {
+				t.Errorf("seeded %s, want the existing %s", admin.ID, tt.wantID)
+			}
+			if !slices.Equal(admin.Roles, []string{auth.RoleAdmin}) {
+				t.Errorf("returned roles %v, want admin", admin.Roles)
+			}
+
+			// One stored account per email, holding the role once
+			var stored []*auth.User
+			for _, user := range repo.users {
+				if user.Email == tt.email {
+					stored = append(stored, user)
+				}
+			}
+			if len(stored) != 1 {
+				t.Fatalf("%d accounts with %s, want one", len(stored), tt.email)
+			}
+			if !slices.Equal(stored[0].Roles, []string{auth.RoleAdmin}) {
+				t.Errorf("stored roles %v, want admin", stored[0].Roles)
+			}
+			if tt.wantID != uuid.Nil {
+				return
+			}
+			if !stored[0].IsActive || !stored[0].IsVerified {
+				t.Errorf("active = %v and verified = %v, want both", stored[0].IsActive, stored[0].IsVerified)
+			}
+			if err := bcrypt.CompareHashAndPassword([]byte(stored[0].HashedPassword), []byte("correct horse")); err != nil {
+				t.Errorf("stored password doesn't match: %v", err)
+			}
+		})
+	}
+	if len(repo.users) != 2 {
+		t.Errorf("%d accounts, want the seeded admin and bob", len(repo.users))
+	}
+}
+
+func TestSeedAdminStored(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.MigratedPool(t)
+	repo := NewPostgresRepository(db)
+
+	for range 2 {
+		if _, _, err := SeedAdmin(ctx, repo, "admin@example.com", "admin", "correct horse"); err != nil {
+			t.Fatalf("SeedAdmin: %v", err)
+		}
+	}
+
+	var count int
+	var roles []string
+	var verified bool
+	err := db.QueryRow(ctx, `
+		SELECT count(*) OVER (), roles, is_verified FROM users WHERE email = $1
+	`, "admin@example.com").Scan(&count, &roles, &verified)
+	if err != nil {
+		t.Fatalf("reading the admin: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("%d accounts, want one", count)
+	}
+	if !slices.Equal(roles, []string{auth.RoleAdmin}) || !verified {
+		t.Errorf("roles = %v and verified = %v, want admin and verified", roles, verified)
+	}
+}
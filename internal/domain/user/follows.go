@@ -0,0 +1,242 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"fowergram-backend/internal/infra/database"
+	"fowergram-backend/pkg/auth"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// IsBlockedEither reports whether either user has blocked the other
+func (r *postgresRepository) IsBlockedEither(ctx context.Context, userID, otherID uuid.UUID) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM blocks
+			WHERE (blocker_id = $1 AND blocked_id = $2)
+			   OR (blocker_id = $2 AND blocked_id = $1)
+		)
+	`
+
+	var blocked bool
+	if err := r.db.QueryRow(ctx, query, userID, otherID).Scan(&blocked); err != nil {
+		return false, fmt.Errorf("failed to check block relationship: %w", err)
+	}
+
+	return blocked, nil
+}
+
+// Follow creates a follow relationship; follower counts are maintained by a
+// trigger. It reports false if the relationship already existed.
+func (r *postgresRepository) Follow(ctx context.Context, followerID, followingID uuid.UUID) (bool, error) {
+	query := `
+		INSERT INTO followers (follower_id, following_id, created_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (follower_id, following_id) DO NOTHING
+	`
+
+	tag, err := r.db.Exec(ctx, query, followerID, followingID, time.Now())
+	if err != nil {
+		return false, fmt.Errorf("failed to follow user: %w", err)
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
+// Unfollow removes a follow relationship and any pending request between the pair
+func (r *postgresRepository) Unfollow(ctx context.Context, followerID, followingID uuid.UUID) error {
+	return database.WithTx(ctx, r.db, func(tx database.DB) error {
+		if _, err := tx.Exec(ctx, `DELETE FROM followers WHERE follower_id = $1 AND following_id = $2`, followerID, followingID); err != nil {
+			return fmt.Errorf("failed to unfollow user: %w", err)
+		}
+
+		if _, err := tx.Exec(ctx, `DELETE FROM follow_requests WHERE requester_id = $1 AND target_id = $2`, followerID, followingID); err != nil {
+			return fmt.Errorf("failed to cancel follow request: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// CreateFollowRequest records a pending follow request. It reports false if
+// one was already pending.
+func (r *postgresRepository) CreateFollowRequest(ctx context.Context, requesterID, targetID uuid.UUID) (bool, error) {
+	query := `
+		INSERT INTO follow_requests (requester_id, target_id, created_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (requester_id, target_id) DO NOTHING
+	`
+
+	tag, err := r.db.Exec(ctx, query, requesterID, targetID, time.Now())
+	if err != nil {
+		return false, fmt.Errorf("failed to create follow request: %w", err)
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
+// DeleteFollowRequest removes a pending follow request, if any
+func (r *postgresRepository) DeleteFollowRequest(ctx context.Context, requesterID, targetID uuid.UUID) error {
+	query := `
+		DELETE FROM follow_requests
+		WHERE requester_id = $1 AND target_id = $2
+	`
+
+	if _, err := r.db.Exec(ctx, query, requesterID, targetID); err != nil {
+		return fmt.Errorf("failed to delete follow request: %w", err)
+	}
+
+	return nil
+}
+
+// GetIncomingFollowRequests retrieves pending requests to follow the target, newest first
+func (r *postgresRepository) GetIncomingFollowRequests(ctx context.Context, targetID uuid.UUID, limit, offset int) ([]*FollowRequest, error) {
+	query := `
+		SELECT fr.id, fr.requester_id, fr.target_id, fr.created_at,
+			   u.username, COALESCE(u.full_name, ''), COALESCE(u.profile_picture, '')
+		FROM follow_requests fr
+		JOIN users u ON u.id = fr.requester_id
+		WHERE fr.target_id = $1 AND u.is_active = true
+		ORDER BY fr.created_at DESC, fr.id DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	rows, err := r.db.Query(ctx, query, targetID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get follow requests: %w", err)
+	}
+	defer rows.Close()
+
+	var requests []*FollowRequest
+	for rows.Next() {
+		req := &FollowRequest{}
+		err := rows.Scan(
+			&req.ID,
+			&req.RequesterID,
+			&req.TargetID,
+			&req.CreatedAt,
+			&req.Username,
+			&req.FullName,
+			&req.ProfilePicture,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan follow request: %w", err)
+		}
+		requests = append(requests, req)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate follow requests: %w", err)
+	}
+
+	return requests, nil
+}
+
+// ApproveFollowRequest turns a pending request addressed to targetID into a follow
+func (r *postgresRepository) ApproveFollowRequest(ctx context.Context, requestID, targetID uuid.UUID) (*FollowRequest, error) {
+	var req *FollowRequest
+	err := database.WithTx(ctx, r.db, func(tx database.DB) (err error) {
+		req, err = deleteFollowRequest(ctx, tx, requestID, targetID)
+		if err != nil {
+			return err
+		}
+
+		followQuery := `
+			INSERT INTO followers (follower_id, following_id, created_at)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (follower_id, following_id) DO NOTHING
+		`
+		if _, err := tx.Exec(ctx, followQuery, req.RequesterID, req.TargetID, time.Now()); err != nil {
+			return fmt.Errorf("failed to follow user: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// RejectFollowRequest discards a pending request addressed to targetID
+func (r *postgresRepository) RejectFollowRequest(ctx context.Context, requestID, targetID uuid.UUID) (*FollowRequest, error) {
+	// A single statement needs no transaction
+	return deleteFollowRequest(ctx, r.db, requestID, targetID)
+}
+
+// deleteFollowRequest removes a request addressed to targetID and returns it
+func deleteFollowRequest(ctx context.Context, db database.Querier, requestID, targetID uuid.UUID) (*FollowRequest, error) {
+	query := `
+		DELETE FROM follow_requests
+		WHERE id = $1 AND target_id = $2
+		RETURNING id, requester_id, target_id, created_at
+	`
+
+	req := &FollowRequest{}
+	err := db.QueryRow(ctx, query, requestID, targetID).Scan(&req.ID, &req.RequesterID, &req.TargetID, &req.CreatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrFollowRequestNotFound
+		}
+		return nil, fmt.Errorf("failed to delete follow request: %w", err)
+	}
+
+	return req, nil
+}
+
+// GetMutualFollowers counts the active users viewerID follows who also
+// follow targetID, leaving out users blocked either way, and returns up to
+// sample of them, most followed first
+func (r *postgresRepository) GetMutualFollowers(ctx context.Context, viewerID, targetID uuid.UUID, sample int) (*MutualFollowers, error) {
+	query := `
+		SELECT COUNT(*) OVER (),
+			   u.id, u.username, COALESCE(u.full_name, ''), COALESCE(u.profile_picture, ''),
+			   u.is_verified, u.is_private
+		FROM followers f
+		JOIN followers v ON v.following_id = f.follower_id AND v.follower_id = $1
+		JOIN users u ON u.id = f.follower_id
+		WHERE f.following_id = $2 AND u.is_active = true
+			AND NOT EXISTS (
+				SELECT 1 FROM blocks b
+				WHERE (b.blocker_id = u.id AND b.blocked_id = $1)
+				   OR (b.blocker_id = $1 AND b.blocked_id = u.id)
+			)
+		ORDER BY u.followers_count DESC, u.id
+		LIMIT $3
+	`
+
+	rows, err := r.db.Query(ctx, query, viewerID, targetID, sample)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get mutual followers: %w", err)
+	}
+	defer rows.Close()
+
+	mutual := &MutualFollowers{Users: []*auth.User{}}
+	for rows.Next() {
+		user := &auth.User{}
+		err := rows.Scan(
+			&mutual.Count,
+			&user.ID,
+			&user.Username,
+			&user.FullName,
+			&user.ProfilePicture,
+			&user.IsVerified,
+			&user.IsPrivate,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan mutual follower: %w", err)
+		}
+		mutual.Users = append(mutual.Users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate mutual followers: %w", err)
+	}
+
+	return mutual, nil
+}
@@ -0,0 +1,67 @@
+package user
+
+import (
+	"context"
+
+	"fowergram-backend/pkg/auth"
+
+	"github.com/google/uuid"
+)
+
+// Loader batches the user lookups of one request, so resolving the authors
+// of a page of posts costs a single user query instead of one per post.
+// Loaders aren't safe for concurrent use; create one per request.
+type Loader struct {
+	users Service
+	cache map[uuid.UUID]*auth.User
+}
+
+// NewLoader creates an empty loader looking users up through users
+func NewLoader(users Service) *Loader {
+	return &Loader{
+		users: users,
+		cache: make(map[uuid.UUID]*auth.User),
+	}
+}
+
+// LoadMany fetches every id not loaded yet in one query. Users that don't
+// exist or are deactivated are remembered as nil.
+func (l *Loader) LoadMany(ctx context.Context, ids []uuid.UUID) error {
+	var missing []uuid.UUID
+	for _, id := range ids {
+		if _, ok := l.cache[id]; !ok {
+			l.cache[id] = nil
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	users, err := l.users.GetUsersByIDs(ctx, missing)
+	if err != nil {
+		for _, id := range missing {
+			delete(l.cache, id)
+		}
+		return err
+	}
+
+	for _, u := range users {
+		l.cache[u.ID] = u
+	}
+	return nil
+}
+
+// Load returns one user, fetching it if no earlier batch included it
+func (l *Loader) Load(ctx context.Context, id uuid.UUID) (*auth.User, error) {
+	if err := l.LoadMany(ctx, []uuid.UUID{id}); err != nil {
+		return nil, err
+	}
+	return l.cache[id], nil
+}
+
+// Get returns a user an earlier batch loaded, or nil when it wasn't loaded,
+// doesn't exist or is deactivated
+func (l *Loader) Get(id uuid.UUID) *auth.User {
+	return l.cache[id]
+}
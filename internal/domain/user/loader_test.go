@@ -0,0 +1,91 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"fowergram-backend/pkg/auth"
+
+	"github.com/google/uuid"
+)
+
+// batchingService looks users up in batches, recording each batch. Other
+// methods are left to the embedded nil Service.
+type batchingService struct {
+	Service
+
+	users   map[uuid.UUID]*auth.User
+	batches [][]uuid.UUID
+	err     error // Fails the next lookup when set
+}
+
+func (s *batchingService) GetUsersByIDs(ctx context.Context, ids []uuid.UUID) ([]*auth.User, error) {
+	s.batches = append(s.batches, ids)
+	if err := s.err; err != nil {
+		s.err = nil
+		return nil, err
+	}
+	var found []*auth.User
+	for _, id := range ids {
+		if u, ok := s.users[id]; ok {
+			found = append(found, u)
+		}
+	}
+	return found, nil
+}
+
+func TestLoader(t *testing.T) {
+	ctx := context.Background()
+	authors := []*auth.User{{ID: uuid.New(), Username: "alice"}, {ID: uuid.New(), Username: "bob"}, {ID: uuid.New(), Username: "carol"}}
+	service := &batchingService{users: make(map[uuid.UUID]*auth.User)}
+	for _, u := range authors {
+		service.users[u.ID] = u
+	}
+	loader := NewLoader(service)
+
+	// The authors of 50 posts by 3 users are looked up once, each once
+	var ids []uuid.UUID
+	for i := range 50 {
+		ids = append(ids, authors[i%len(authors)].ID)
+	}
+	if err := loader.LoadMany(ctx, ids); err != nil {
+		t.Fatalf("LoadMany: %v", err)
+	}
+	if len(service.batches) != 1 || len(service.batches[0]) != len(authors) {
+		t.Fatalf("batches = %v, want one of the %d authors", service.batches, len(authors))
+	}
+	for _, u := range authors {
+		if got := loader.Get(u.ID); got != u {
+			t.Errorf("Get(%s) = %v, want %s", u.Username, got, u.Username)
+		}
+	}
+
+	// Loaded users aren't looked up again; users that don't exist are
+	// remembered as nil
+	gone := uuid.New()
+	if got, err := loader.Load(ctx, authors[0].ID); err != nil || got != authors[0] {
+		t.Errorf("Load(alice) = %v, %v; want alice", got, err)
+	}
+	if got, err := loader.Load(ctx, gone); err != nil || got != nil {
+		t.Errorf("Load(gone) = %v, %v; want nil", got, err)
+	}
+	if err := loader.LoadMany(ctx, []uuid.UUID{gone, authors[1].ID}); err != nil {
+		t.Fatalf("LoadMany: %v", err)
+	}
+	if len(service.batches) != 2 || !slices.Equal(service.batches[1], []uuid.UUID{gone}) {
+		t.Errorf("batches = %v, want a second one of the missing user", service.batches)
+	}
+
+	// Failed lookups aren't cached, so the next load tries again
+	dave := &auth.User{ID: uuid.New(), Username: "dave"}
+	service.users[dave.ID] = dave
+	service.err = errors.New("connection reset")
+	if _, err := loader.Load(ctx, dave.ID); err == nil {
+		t.Fatal("Load() succeeded while the lookup failed")
+	}
+	if got, err := loader.Load(ctx, dave.ID); err != nil || got != dave {
+		t.Errorf("Load(dave) after a failure = %v, %v; want dave", got, err)
+	}
+}
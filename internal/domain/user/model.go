@@ -2,13 +2,63 @@ package user
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"time"
 
+	"fowergram-backend/internal/infra/database"
 	"fowergram-backend/pkg/auth"
 
 	"github.com/google/uuid"
 )
 
+// FollowStatus describes the relationship after a follow attempt
+type FollowStatus string
+
+const (
+	FollowStatusFollowing FollowStatus = "following"
+	FollowStatusRequested FollowStatus = "requested"
+)
+
+// Follow errors
+var (
+	ErrCannotFollowSelf      = errors.New("you cannot follow yourself")
+	ErrFollowBlocked         = errors.New("you cannot follow this account")
+	ErrFollowRequestNotFound = errors.New("follow request not found")
+)
+
+// FollowRequest represents a pending request to follow a private account
+type FollowRequest struct {
+	ID          uuid.UUID `json:"id" db:"id"`
+	RequesterID uuid.UUID `json:"requester_id" db:"requester_id"`
+	TargetID    uuid.UUID `json:"target_id" db:"target_id"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+
+	// Requester profile, populated when listing requests
+	Username       string `json:"username" db:"username"`
+	FullName       string `json:"full_name,omitempty" db:"full_name"`
+	ProfilePicture string `json:"profile_picture,omitempty" db:"profile_picture"`
+}
+
+// ErrVersionConflict is returned when an update was based on a stale version;
+// the client should refetch and retry
+var ErrVersionConflict = errors.New("user was modified by another request")
+
+// ErrPrivateAccount is returned when a private account's connections are
+// requested by someone who is neither the owner nor an approved follower
+var ErrPrivateAccount = errors.New("this account is private")
+
+// AvatarKeyPrefix returns the storage prefix holding a user's avatar
+func AvatarKeyPrefix(userID uuid.UUID) string {
+	return fmt.Sprintf("avatars/%s/", userID)
+}
+
+// avatarKey returns a fresh key for a new avatar. Each upload gets its own
+// key so CDNs and clients never serve a cached copy of the previous image.
+func avatarKey(userID uuid.UUID) string {
+	return fmt.Sprintf("%s%s.jpg", AvatarKeyPrefix(userID), uuid.New())
+}
+
 // Repository defines the interface for user data persistence
 type Repository interface {
 	// User CRUD operations
@@ -16,20 +66,65 @@ type Repository interface {
 	GetUserByEmail(ctx context.Context, email string) (*auth.User, error)
 	GetUserByUsername(ctx context.Context, username string) (*auth.User, error)
 	GetUserByID(ctx context.Context, id uuid.UUID) (*auth.User, error)
+	GetUsersByIDs(ctx context.Context, ids []uuid.UUID) ([]*auth.User, error)
 	UpdateUser(ctx context.Context, user *auth.User) error
-	UpdatePassword(ctx context.Context, userID uuid.UUID, hashedPassword string) error
+	UpdateProfilePicture(ctx context.Context, userID uuid.UUID, url string) error
+	UpdatePassword(ctx context.Context, userID uuid.UUID, hashedPassword string, keep int) error
+	GetPasswordHistory(ctx context.Context, userID uuid.UUID, limit int) ([]string, error)
+	GrantRole(ctx context.Context, userID uuid.UUID, role string) error
 
 	// Token management
-	StoreRefreshToken(ctx context.Context, userID uuid.UUID, tokenHash string, expiresAt time.Time) error
+	StoreRefreshToken(ctx context.Context, token *auth.RefreshToken) error
 	ValidateRefreshToken(ctx context.Context, tokenHash string) (*auth.User, error)
 	RevokeRefreshToken(ctx context.Context, tokenHash string) error
 
+	// Sessions, one per refresh token
+	ListRefreshTokens(ctx context.Context, userID uuid.UUID) ([]*auth.RefreshToken, error)
+	RevokeSession(ctx context.Context, userID, sessionID uuid.UUID) (bool, error)
+	RevokeOtherSessions(ctx context.Context, userID, keepID uuid.UUID) error
+	IsSessionActive(ctx context.Context, sessionID uuid.UUID) (bool, error)
+
+	// Account lifecycle
+	DeactivateUser(ctx context.Context, userID uuid.UUID) error
+	ReactivateUser(ctx context.Context, userID uuid.UUID) error
+	HardDeleteUser(ctx context.Context, userID uuid.UUID) error
+
 	// User activity
-	UpdateLastLogin(ctx context.Context, userID uuid.UUID) error
+	UpdateLastLogin(ctx context.Context, userID uuid.UUID, client auth.ClientInfo) error
 
 	// Social features
 	GetFollowers(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*auth.User, error)
 	GetFollowing(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*auth.User, error)
+	GetFollowingIDs(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error)
+	GetFollowerIDs(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error)
+	IsFollowing(ctx context.Context, followerID, followingID uuid.UUID) (bool, error)
+	IsBlockedEither(ctx context.Context, userID, otherID uuid.UUID) (bool, error)
+	GetMutualFollowers(ctx context.Context, viewerID, targetID uuid.UUID, sample int) (*MutualFollowers, error)
+	Follow(ctx context.Context, followerID, followingID uuid.UUID) (bool, error)
+	Unfollow(ctx context.Context, followerID, followingID uuid.UUID) error
+
+	// Follow requests
+	CreateFollowRequest(ctx context.Context, requesterID, targetID uuid.UUID) (bool, error)
+	DeleteFollowRequest(ctx context.Context, requesterID, targetID uuid.UUID) error
+	GetIncomingFollowRequests(ctx context.Context, targetID uuid.UUID, limit, offset int) ([]*FollowRequest, error)
+	ApproveFollowRequest(ctx context.Context, requestID, targetID uuid.UUID) (*FollowRequest, error)
+	RejectFollowRequest(ctx context.Context, requestID, targetID uuid.UUID) (*FollowRequest, error)
+
+	// ReconcileCounts recomputes the user's denormalized counts from the
+	// followers and posts tables
+	ReconcileCounts(ctx context.Context, userID uuid.UUID) (*Counts, error)
+
+	// WithTx returns the repository bound to tx, to compose its writes with
+	// other repositories' in one database.WithTx transaction
+	WithTx(tx database.DB) Repository
+}
+
+// Counts are the denormalized counts kept on a user. Triggers maintain them;
+// ReconcileCounts repairs any drift.
+type Counts struct {
+	Followers int `json:"followers_count" db:"followers_count"`
+	Following int `json:"following_count" db:"following_count"`
+	Posts     int `json:"posts_count" db:"posts_count"` // Published posts that aren't deleted
 }
 
 // User represents a user in the system
@@ -43,6 +138,7 @@ type User struct {
 	Website    *string    `json:"website,omitempty" db:"website"`
 	IsPrivate  bool       `json:"is_private" db:"is_private"`
 	IsVerified bool       `json:"is_verified" db:"is_verified"`
+	Version    int        `json:"version" db:"version"`
 	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
 	UpdatedAt  time.Time  `json:"updated_at" db:"updated_at"`
 	DeletedAt  *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
@@ -51,7 +147,7 @@ type User struct {
 // CreateUserInput represents input for creating a new user
 type CreateUserInput struct {
 	Email    string  `json:"email" validate:"required,email"`
-	Username string  `json:"username" validate:"required,min=3,max=30,alphanum"`
+	Username string  `json:"username" validate:"required,username"`
 	FullName *string `json:"full_name,omitempty" validate:"omitempty,max=100"`
 	Bio      *string `json:"bio,omitempty" validate:"omitempty,max=500"`
 	Website  *string `json:"website,omitempty" validate:"omitempty,url"`
@@ -59,12 +155,13 @@ type CreateUserInput struct {
 
 // UpdateUserInput represents input for updating user information
 type UpdateUserInput struct {
-	Username  *string `json:"username,omitempty" validate:"omitempty,min=3,max=30,alphanum"`
+	Username  *string `json:"username,omitempty" validate:"omitempty,username"`
 	FullName  *string `json:"full_name,omitempty" validate:"omitempty,max=100"`
 	Bio       *string `json:"bio,omitempty" validate:"omitempty,max=500"`
 	Avatar    *string `json:"avatar,omitempty"`
 	Website   *string `json:"website,omitempty" validate:"omitempty,url"`
 	IsPrivate *bool   `json:"is_private,omitempty"`
+	Version   int     `json:"version" validate:"required,min=1"` // Version the update is based on
 }
 
 // UserProfile represents a user's public profile information
@@ -82,10 +179,24 @@ type UserProfile struct {
 	FollowingCount int       `json:"following_count"`
 	IsFollowing    bool      `json:"is_following"`
 	IsFollowedBy   bool      `json:"is_followed_by"`
-	IsBlocked      bool      `json:"is_blocked"`
 	CreatedAt      time.Time `json:"created_at"`
+
+	// MutualFollowers are the accounts the viewer follows that follow this
+	// user. Unset on the viewer's own profile and on profiles whose
+	// followers the viewer may not see.
+	MutualFollowers *MutualFollowers `json:"mutual_followers,omitempty"`
 }
 
+// MutualFollowers is how many of the accounts a viewer follows also follow
+// a user, with a few of them to name, as in "followed by a, b and 3 others"
+type MutualFollowers struct {
+	Count int          `json:"count"`
+	Users []*auth.User `json:"users"` // Up to MutualFollowersSample, most followed first
+}
+
+// MutualFollowersSample is how many mutual followers a profile names
+const MutualFollowersSample = 3
+
 // Follow represents a following relationship between users
 type Follow struct {
 	ID          uuid.UUID `json:"id" db:"id"`
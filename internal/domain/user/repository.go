@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"time"
 
+	"fowergram-backend/internal/infra/database"
 	"fowergram-backend/pkg/auth"
 
 	"github.com/google/uuid"
@@ -14,14 +15,19 @@ import (
 
 // postgresRepository implements user data operations
 type postgresRepository struct {
-	db *pgxpool.Pool
+	db database.DB
 }
 
 // NewPostgresRepository creates a new PostgreSQL user repository
-func NewPostgresRepository(db *pgxpool.Pool) auth.UserRepository {
+func NewPostgresRepository(db *pgxpool.Pool) Repository {
 	return &postgresRepository{db: db}
 }
 
+// WithTx returns the repository running its queries on tx
+func (r *postgresRepository) WithTx(tx database.DB) Repository {
+	return &postgresRepository{db: tx}
+}
+
 // CreateUser creates a new user in the database
 func (r *postgresRepository) CreateUser(ctx context.Context, user *auth.User) error {
 	query := `
@@ -51,16 +57,17 @@ func (r *postgresRepository) CreateUser(ctx context.Context, user *auth.User) er
 	return nil
 }
 
-// GetUserByEmail retrieves a user by email
+// GetUserByEmail retrieves a user by email, including deactivated accounts
+// so that callers can decide whether they may be reactivated
 func (r *postgresRepository) GetUserByEmail(ctx context.Context, email string) (*auth.User, error) {
 	var user auth.User
 	query := `
 		SELECT id, email, username, hashed_password, full_name, bio,
 			   profile_picture, is_active, is_verified, is_private,
 			   followers_count, following_count, posts_count,
-			   created_at, updated_at, last_login_at
+			   created_at, updated_at, last_login_at, deactivated_at, version, roles
 		FROM users 
-		WHERE email = $1 AND is_active = true
+		WHERE email = $1
 	`
 
 	err := r.db.QueryRow(ctx, query, email).Scan(
@@ -80,6 +87,9 @@ func (r *postgresRepository) GetUserByEmail(ctx context.Context, email string) (
 		&user.CreatedAt,
 		&user.UpdatedAt,
 		&user.LastLoginAt,
+		&user.DeactivatedAt,
+		&user.Version,
+		&user.Roles,
 	)
 	if err != nil {
 		if err == pgx.ErrNoRows {
@@ -98,7 +108,7 @@ func (r *postgresRepository) GetUserByUsername(ctx context.Context, username str
 		SELECT id, email, username, hashed_password, full_name, bio,
 			   profile_picture, is_active, is_verified, is_private,
 			   followers_count, following_count, posts_count,
-			   created_at, updated_at, last_login_at
+			   created_at, updated_at, last_login_at, version, roles
 		FROM users 
 		WHERE username = $1 AND is_active = true
 	`
@@ -120,6 +130,8 @@ func (r *postgresRepository) GetUserByUsername(ctx context.Context, username str
 		&user.CreatedAt,
 		&user.UpdatedAt,
 		&user.LastLoginAt,
+		&user.Version,
+		&user.Roles,
 	)
 	if err != nil {
 		if err == pgx.ErrNoRows {
@@ -138,7 +150,7 @@ func (r *postgresRepository) GetUserByID(ctx context.Context, id uuid.UUID) (*au
 		SELECT id, email, username, hashed_password, full_name, bio,
 			   profile_picture, is_active, is_verified, is_private,
 			   followers_count, following_count, posts_count,
-			   created_at, updated_at, last_login_at
+			   created_at, updated_at, last_login_at, deactivated_at, version, roles
 		FROM users 
 		WHERE id = $1
 	`
@@ -160,6 +172,9 @@ func (r *postgresRepository) GetUserByID(ctx context.Context, id uuid.UUID) (*au
 		&user.CreatedAt,
 		&user.UpdatedAt,
 		&user.LastLoginAt,
+		&user.DeactivatedAt,
+		&user.Version,
+		&user.Roles,
 	)
 	if err != nil {
 		if err == pgx.ErrNoRows {
@@ -171,7 +186,8 @@ func (r *postgresRepository) GetUserByID(ctx context.Context, id uuid.UUID) (*au
 	return &user, nil
 }
 
-// UpdateUser updates user information
+// UpdateUser updates user information if the row is still at user.Version,
+// then bumps the version. ErrVersionConflict means someone else updated it first.
 func (r *postgresRepository) UpdateUser(ctx context.Context, user *auth.User) error {
 	query := `
 		UPDATE users SET
@@ -184,48 +200,136 @@ func (r *postgresRepository) UpdateUser(ctx context.Context, user *auth.User) er
 			is_verified = $7,
 			is_private = $8,
 			updated_at = $9,
-			last_login_at = $10
-		WHERE id = $11
+			last_login_at = $10,
+			version = version + 1
+		WHERE id = $11 AND version = $12
+		RETURNING version
 	`
 
-	user.UpdatedAt = time.Now()
-	_, err := r.db.Exec(ctx, query,
+	updatedAt := time.Now()
+	err := r.db.QueryRow(ctx, query,
 		user.Email, user.Username, user.FullName, user.Bio, user.ProfilePicture,
-		user.IsActive, user.IsVerified, user.IsPrivate, user.UpdatedAt, user.LastLoginAt,
-		user.ID,
-	)
+		user.IsActive, user.IsVerified, user.IsPrivate, updatedAt, user.LastLoginAt,
+		user.ID, user.Version,
+	).Scan(&user.Version)
 	if err != nil {
+		if err == pgx.ErrNoRows {
+			return ErrVersionConflict
+		}
 		return fmt.Errorf("failed to update user: %w", err)
 	}
 
+	user.UpdatedAt = updatedAt
 	return nil
 }
 
-// UpdatePassword updates user password
-func (r *postgresRepository) UpdatePassword(ctx context.Context, userID uuid.UUID, hashedPassword string) error {
+// UpdateProfilePicture points the user's profile picture at url and bumps the version
+func (r *postgresRepository) UpdateProfilePicture(ctx context.Context, userID uuid.UUID, url string) error {
 	query := `
-		UPDATE users SET 
-			hashed_password = $1,
-			updated_at = $2
+		UPDATE users SET
+			profile_picture = $1,
+			updated_at = $2,
+			version = version + 1
 		WHERE id = $3
 	`
 
-	_, err := r.db.Exec(ctx, query, hashedPassword, time.Now(), userID)
+	tag, err := r.db.Exec(ctx, query, url, time.Now(), userID)
 	if err != nil {
-		return fmt.Errorf("failed to update password: %w", err)
+		return fmt.Errorf("failed to update profile picture: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return auth.ErrUserNotFound
 	}
 
 	return nil
 }
 
-// StoreRefreshToken stores a refresh token for a user
-func (r *postgresRepository) StoreRefreshToken(ctx context.Context, userID uuid.UUID, tokenHash string, expiresAt time.Time) error {
+// UpdatePassword updates user password. The replaced hash is kept in the
+// password history, which is trimmed to its keep most recent entries.
+func (r *postgresRepository) UpdatePassword(ctx context.Context, userID uuid.UUID, hashedPassword string, keep int) error {
+	return database.WithTx(ctx, r.db, func(tx database.DB) error {
+		now := time.Now()
+
+		if keep > 0 {
+			historyQuery := `
+				INSERT INTO password_history (user_id, hashed_password, created_at)
+				SELECT id, hashed_password, $2 FROM users WHERE id = $1
+			`
+			if _, err := tx.Exec(ctx, historyQuery, userID, now); err != nil {
+				return fmt.Errorf("failed to record password history: %w", err)
+			}
+		}
+
+		query := `
+			UPDATE users SET 
+				hashed_password = $1,
+				updated_at = $2
+			WHERE id = $3
+		`
+		if _, err := tx.Exec(ctx, query, hashedPassword, now, userID); err != nil {
+			return fmt.Errorf("failed to update password: %w", err)
+		}
+
+		trimQuery := `
+			DELETE FROM password_history
+			WHERE user_id = $1 AND id NOT IN (
+				SELECT id FROM password_history
+				WHERE user_id = $1
+				ORDER BY created_at DESC, id DESC
+				LIMIT $2
+			)
+		`
+		if _, err := tx.Exec(ctx, trimQuery, userID, keep); err != nil {
+			return fmt.Errorf("failed to trim password history: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// GetPasswordHistory returns up to limit of the user's previous password
+// hashes, newest first
+func (r *postgresRepository) GetPasswordHistory(ctx context.Context, userID uuid.UUID, limit int) ([]string, error) {
+	query := `
+		SELECT hashed_password FROM password_history
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2
+	`
+
+	rows, err := r.db.Query(ctx, query, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get password history: %w", err)
+	}
+	defer rows.Close()
+
+	var hashes []string
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, fmt.Errorf("failed to scan password history: %w", err)
+		}
+		hashes = append(hashes, hash)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate password history: %w", err)
+	}
+
+	return hashes, nil
+}
+
+// StoreRefreshToken stores a refresh token for a user along with the client
+// it was issued to
+func (r *postgresRepository) StoreRefreshToken(ctx context.Context, token *auth.RefreshToken) error {
 	query := `
-		INSERT INTO refresh_tokens (id, user_id, token_hash, expires_at, created_at)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO refresh_tokens (id, user_id, token_hash, expires_at, created_at, ip_address, user_agent)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), NULLIF($7, ''))
 	`
 
-	_, err := r.db.Exec(ctx, query, uuid.New(), userID, tokenHash, expiresAt, time.Now())
+	_, err := r.db.Exec(ctx, query,
+		token.ID, token.UserID, token.TokenHash, token.ExpiresAt, token.CreatedAt, token.IPAddress, token.UserAgent,
+	)
 	if err != nil {
 		return fmt.Errorf("failed to store refresh token: %w", err)
 	}
@@ -293,34 +397,303 @@ func (r *postgresRepository) RevokeRefreshToken(ctx context.Context, tokenHash s
 	return nil
 }
 
-// UpdateLastLogin updates the user's last login timestamp
-func (r *postgresRepository) UpdateLastLogin(ctx context.Context, userID uuid.UUID) error {
+// ListRefreshTokens lists the user's unexpired, unrevoked sessions, newest first
+func (r *postgresRepository) ListRefreshTokens(ctx context.Context, userID uuid.UUID) ([]*auth.RefreshToken, error) {
+	query := `
+		SELECT id, user_id, expires_at, created_at, COALESCE(ip_address, ''), COALESCE(user_agent, '')
+		FROM refresh_tokens
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		ORDER BY created_at DESC
+	`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var tokens []*auth.RefreshToken
+	for rows.Next() {
+		token := &auth.RefreshToken{}
+		if err := rows.Scan(&token.ID, &token.UserID, &token.ExpiresAt, &token.CreatedAt, &token.IPAddress, &token.UserAgent); err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		tokens = append(tokens, token)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate sessions: %w", err)
+	}
+
+	return tokens, nil
+}
+
+// RevokeSession revokes one of the user's sessions, reporting whether an
+// active session was found
+func (r *postgresRepository) RevokeSession(ctx context.Context, userID, sessionID uuid.UUID) (bool, error) {
 	query := `
-		UPDATE users 
-		SET last_login_at = $1 
+		UPDATE refresh_tokens
+		SET revoked_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL AND expires_at > NOW()
+	`
+
+	tag, err := r.db.Exec(ctx, query, sessionID, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke session: %w", err)
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
+// RevokeOtherSessions revokes all of the user's sessions except keepID
+func (r *postgresRepository) RevokeOtherSessions(ctx context.Context, userID, keepID uuid.UUID) error {
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = NOW()
+		WHERE user_id = $1 AND id <> $2 AND revoked_at IS NULL
+	`
+
+	if _, err := r.db.Exec(ctx, query, userID, keepID); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+
+	return nil
+}
+
+// IsSessionActive reports whether a session exists and is neither revoked nor expired
+func (r *postgresRepository) IsSessionActive(ctx context.Context, sessionID uuid.UUID) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM refresh_tokens
+			WHERE id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		)
+	`
+
+	var active bool
+	if err := r.db.QueryRow(ctx, query, sessionID).Scan(&active); err != nil {
+		return false, fmt.Errorf("failed to check session: %w", err)
+	}
+
+	return active, nil
+}
+
+// DeactivateUser marks a user inactive and revokes all of their refresh tokens
+func (r *postgresRepository) DeactivateUser(ctx context.Context, userID uuid.UUID) error {
+	return database.WithTx(ctx, r.db, func(tx database.DB) error {
+		now := time.Now()
+
+		deactivateQuery := `
+			UPDATE users
+			SET is_active = false, deactivated_at = $1, updated_at = $1
+			WHERE id = $2 AND is_active = true
+		`
+		tag, err := tx.Exec(ctx, deactivateQuery, now, userID)
+		if err != nil {
+			return fmt.Errorf("failed to deactivate user: %w", err)
+		}
+		if tag.RowsAffected() == 0 {
+			return auth.ErrUserNotFound
+		}
+
+		revokeQuery := `
+			UPDATE refresh_tokens
+			SET revoked_at = $1
+			WHERE user_id = $2 AND revoked_at IS NULL
+		`
+		if _, err := tx.Exec(ctx, revokeQuery, now, userID); err != nil {
+			return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// ReactivateUser restores a deactivated user
+func (r *postgresRepository) ReactivateUser(ctx context.Context, userID uuid.UUID) error {
+	query := `
+		UPDATE users
+		SET is_active = true, deactivated_at = NULL, updated_at = $1
 		WHERE id = $2
 	`
 
-	now := time.Now()
-	_, err := r.db.Exec(ctx, query, now, userID)
+	_, err := r.db.Exec(ctx, query, time.Now(), userID)
 	if err != nil {
-		return fmt.Errorf("failed to update last login: %w", err)
+		return fmt.Errorf("failed to reactivate user: %w", err)
 	}
 
 	return nil
 }
 
+// HardDeleteUser permanently removes a user and everything they own in a
+// single transaction. Counters on other users' content are corrected first,
+// and references that must survive (conversations they created, reviews they
+// performed) are anonymized rather than deleted.
+func (r *postgresRepository) HardDeleteUser(ctx context.Context, userID uuid.UUID) error {
+	return database.WithTx(ctx, r.db, func(tx database.DB) error {
+		steps := []struct {
+			name  string
+			query string
+		}{
+			{"anonymize conversations", `
+				UPDATE conversations SET created_by = NULL WHERE created_by = $1
+			`},
+			{"anonymize verification reviews", `
+				UPDATE verification_requests SET reviewed_by = NULL WHERE reviewed_by = $1
+			`},
+			{"correct likes counts", `
+				UPDATE posts p SET likes_count = GREATEST(p.likes_count - 1, 0)
+				FROM post_likes pl
+				WHERE pl.post_id = p.id AND pl.user_id = $1 AND p.user_id <> $1
+			`},
+			{"correct comments counts", `
+				UPDATE posts p SET comments_count = GREATEST(p.comments_count - c.total, 0)
+				FROM (
+					SELECT post_id, COUNT(*) AS total
+					FROM comments
+					WHERE deleted_at IS NULL
+						AND (user_id = $1 OR parent_id IN (SELECT id FROM comments WHERE user_id = $1))
+					GROUP BY post_id
+				) c
+				WHERE p.id = c.post_id AND p.user_id <> $1
+			`},
+			{"correct reposts counts", `
+				UPDATE posts p SET reposts_count = GREATEST(p.reposts_count - r.total, 0)
+				FROM (
+					SELECT original_post_id, COUNT(*) AS total
+					FROM posts
+					WHERE user_id = $1 AND original_post_id IS NOT NULL AND deleted_at IS NULL
+					GROUP BY original_post_id
+				) r
+				WHERE p.id = r.original_post_id AND p.user_id <> $1
+			`},
+			{"correct tag counts", `
+				UPDATE hashtags h SET post_count = GREATEST(h.post_count - t.total, 0)
+				FROM (
+					SELECT pt.tag, COUNT(*) AS total
+					FROM post_tags pt
+					JOIN posts p ON p.id = pt.post_id
+					WHERE p.user_id = $1 AND p.deleted_at IS NULL
+					GROUP BY pt.tag
+				) t
+				WHERE h.name = t.tag
+			`},
+			{"delete likes", `DELETE FROM post_likes WHERE user_id = $1`},
+			{"delete comments", `DELETE FROM comments WHERE user_id = $1`},
+			{"delete follows", `DELETE FROM followers WHERE follower_id = $1 OR following_id = $1`},
+			{"delete refresh tokens", `DELETE FROM refresh_tokens WHERE user_id = $1`},
+			{"delete posts", `DELETE FROM posts WHERE user_id = $1`},
+			{"delete media", `DELETE FROM media WHERE user_id = $1`},
+		}
+
+		for _, step := range steps {
+			if _, err := tx.Exec(ctx, step.query, userID); err != nil {
+				return fmt.Errorf("failed to %s: %w", step.name, err)
+			}
+		}
+
+		// Remaining user-owned rows are removed by ON DELETE CASCADE
+		tag, err := tx.Exec(ctx, `DELETE FROM users WHERE id = $1`, userID)
+		if err != nil {
+			return fmt.Errorf("failed to delete user: %w", err)
+		}
+		if tag.RowsAffected() == 0 {
+			return auth.ErrUserNotFound
+		}
+
+		return nil
+	})
+}
+
+// UpdateLastLogin updates the user's last login timestamp and adds the
+// sign-in to their login history
+func (r *postgresRepository) UpdateLastLogin(ctx context.Context, userID uuid.UUID, client auth.ClientInfo) error {
+	return database.WithTx(ctx, r.db, func(tx database.DB) error {
+		query := `
+			UPDATE users 
+			SET last_login_at = $1 
+			WHERE id = $2
+		`
+
+		now := time.Now()
+		if _, err := tx.Exec(ctx, query, now, userID); err != nil {
+			return fmt.Errorf("failed to update last login: %w", err)
+		}
+
+		historyQuery := `
+			INSERT INTO login_history (user_id, ip_address, user_agent, created_at)
+			VALUES ($1, NULLIF($2, ''), NULLIF($3, ''), $4)
+		`
+		if _, err := tx.Exec(ctx, historyQuery, userID, client.IP, client.UserAgent, now); err != nil {
+			return fmt.Errorf("failed to record login history: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// GetUsersByIDs retrieves the active users among ids in a single query, in no
+// particular order. Missing and deactivated users are left out.
+func (r *postgresRepository) GetUsersByIDs(ctx context.Context, ids []uuid.UUID) ([]*auth.User, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	query := `
+		SELECT id, email, username, COALESCE(full_name, ''), COALESCE(bio, ''),
+			   COALESCE(profile_picture, ''), is_verified, is_private,
+			   followers_count, following_count, posts_count,
+			   created_at
+		FROM users
+		WHERE id = ANY($1) AND is_active = true
+	`
+
+	rows, err := r.db.Query(ctx, query, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get users by IDs: %w", err)
+	}
+	defer rows.Close()
+
+	var users []*auth.User
+	for rows.Next() {
+		user := &auth.User{}
+		err := rows.Scan(
+			&user.ID,
+			&user.Email,
+			&user.Username,
+			&user.FullName,
+			&user.Bio,
+			&user.ProfilePicture,
+			&user.IsVerified,
+			&user.IsPrivate,
+			&user.FollowersCount,
+			&user.FollowingCount,
+			&user.PostsCount,
+			&user.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate users: %w", err)
+	}
+
+	return users, nil
+}
+
 // GetFollowers retrieves user's followers
 func (r *postgresRepository) GetFollowers(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*auth.User, error) {
 	query := `
-		SELECT u.id, u.email, u.username, u.full_name, u.bio,
-			   u.profile_picture, u.is_verified, u.is_private,
+		SELECT u.id, u.email, u.username, COALESCE(u.full_name, ''), COALESCE(u.bio, ''),
+			   COALESCE(u.profile_picture, ''), u.is_verified, u.is_private,
 			   u.followers_count, u.following_count, u.posts_count,
 			   u.created_at
 		FROM users u
 		JOIN followers f ON u.id = f.follower_id
 		WHERE f.following_id = $1 AND u.is_active = true
-		ORDER BY f.created_at DESC
+		ORDER BY f.created_at DESC, f.follower_id DESC
 		LIMIT $2 OFFSET $3
 	`
 
@@ -363,14 +736,14 @@ func (r *postgresRepository) GetFollowers(ctx context.Context, userID uuid.UUID,
 // GetFollowing retrieves users that the user is following
 func (r *postgresRepository) GetFollowing(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*auth.User, error) {
 	query := `
-		SELECT u.id, u.email, u.username, u.full_name, u.bio,
-			   u.profile_picture, u.is_verified, u.is_private,
+		SELECT u.id, u.email, u.username, COALESCE(u.full_name, ''), COALESCE(u.bio, ''),
+			   COALESCE(u.profile_picture, ''), u.is_verified, u.is_private,
 			   u.followers_count, u.following_count, u.posts_count,
 			   u.created_at
 		FROM users u
 		JOIN followers f ON u.id = f.following_id
 		WHERE f.follower_id = $1 AND u.is_active = true
-		ORDER BY f.created_at DESC
+		ORDER BY f.created_at DESC, f.following_id DESC
 		LIMIT $2 OFFSET $3
 	`
 
@@ -409,3 +782,126 @@ func (r *postgresRepository) GetFollowing(ctx context.Context, userID uuid.UUID,
 
 	return users, nil
 }
+
+// GetFollowingIDs retrieves the IDs of every active user the user follows
+func (r *postgresRepository) GetFollowingIDs(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	query := `
+		SELECT f.following_id
+		FROM followers f
+		JOIN users u ON u.id = f.following_id
+		WHERE f.follower_id = $1 AND u.is_active = true
+	`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get following ids: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan following id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate following ids: %w", err)
+	}
+
+	return ids, nil
+}
+
+// GetFollowerIDs retrieves the IDs of every active user following the user
+func (r *postgresRepository) GetFollowerIDs(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	query := `
+		SELECT f.follower_id
+		FROM followers f
+		JOIN users u ON u.id = f.follower_id
+		WHERE f.following_id = $1 AND u.is_active = true
+	`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get follower ids: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan follower id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate follower ids: %w", err)
+	}
+
+	return ids, nil
+}
+
+// IsFollowing reports whether followerID follows followingID
+func (r *postgresRepository) IsFollowing(ctx context.Context, followerID, followingID uuid.UUID) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM followers
+			WHERE follower_id = $1 AND following_id = $2
+		)
+	`
+
+	var following bool
+	if err := r.db.QueryRow(ctx, query, followerID, followingID).Scan(&following); err != nil {
+		return false, fmt.Errorf("failed to check follow relationship: %w", err)
+	}
+
+	return following, nil
+}
+
+// GrantRole adds role to the user's roles unless they already have it
+func (r *postgresRepository) GrantRole(ctx context.Context, userID uuid.UUID, role string) error {
+	query := `
+		UPDATE users SET
+			roles = array_append(roles, $2),
+			updated_at = NOW()
+		WHERE id = $1 AND NOT ($2 = ANY(roles))
+	`
+
+	if _, err := r.db.Exec(ctx, query, userID, role); err != nil {
+		return fmt.Errorf("failed to grant role: %w", err)
+	}
+
+	return nil
+}
+
+// ReconcileCounts recomputes the user's follower, following and post counts.
+// The row lock taken by the update makes concurrent follows and posts apply
+// their trigger increments on top of the recomputed values.
+func (r *postgresRepository) ReconcileCounts(ctx context.Context, userID uuid.UUID) (*Counts, error) {
+	query := `
+		UPDATE users u SET
+			followers_count = (SELECT COUNT(*) FROM followers f WHERE f.following_id = u.id),
+			following_count = (SELECT COUNT(*) FROM followers f WHERE f.follower_id = u.id),
+			posts_count = (
+				SELECT COUNT(*) FROM posts p
+				WHERE p.user_id = u.id AND p.status = 'published' AND p.deleted_at IS NULL
+			)
+		WHERE u.id = $1
+		RETURNING followers_count, following_count, posts_count
+	`
+
+	var counts Counts
+	err := r.db.QueryRow(ctx, query, userID).Scan(&counts.Followers, &counts.Following, &counts.Posts)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, auth.ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to reconcile user counts: %w", err)
+	}
+
+	return &counts, nil
+}
@@ -0,0 +1,373 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"fowergram-backend/internal/infra/database/dbtest"
+	"fowergram-backend/pkg/auth"
+
+	"github.com/google/uuid"
+)
+
+func TestUpdateLastLogin(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.MigratedPool(t)
+	repo := NewPostgresRepository(db)
+	userID := addUser(t, db, "alice")
+
+	logins := []auth.ClientInfo{
+		{IP: "203.0.113.7", UserAgent: "Fowergram/1.0 (iOS)"},
+		{}, // A client that sent neither
+	}
+	for _, client := range logins {
+		before := time.Now().Add(-time.Second)
+		if err := repo.UpdateLastLogin(ctx, userID, client); err != nil {
+			t.Fatalf("UpdateLastLogin: %v", err)
+		}
+
+		var lastLogin *time.Time
+		if err := db.QueryRow(ctx, "SELECT last_login_at FROM users WHERE id = $1", userID).Scan(&lastLogin); err != nil {
+			t.Fatalf("reading last_login_at: %v", err)
+		}
+		if lastLogin == nil || lastLogin.Before(before) {
+			t.Errorf("last_login_at = %v, want the time of the login", lastLogin)
+		}
+	}
+
+	rows, err := db.Query(ctx, `
+		SELECT COALESCE(ip_address, ''), COALESCE(user_agent, ''), ip_address IS NULL AND user_agent IS NULL
+		FROM login_history WHERE user_id = $1 ORDER BY created_at
+	`, userID)
+	if err != nil {
+		t.Fatalf("reading login_history: %v", err)
+	}
+	defer rows.Close()
+
+	var history []auth.ClientInfo
+	var nulls []bool
+	for rows.Next() {
+		var client auth.ClientInfo
+		var null bool
+		if err := rows.Scan(&client.IP, &client.UserAgent, &null); err != nil {
+			t.Fatalf("scanning login_history: %v", err)
+		}
+		history = append(history, client)
+		nulls = append(nulls, null)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("reading login_history: %v", err)
+	}
+
+	if len(history) != len(logins) {
+		t.Fatalf("login_history = %+v, want a row per login", history)
+	}
+	for i, client := range logins {
+		if history[i] != client {
+			t.Errorf("login %d recorded as %+v, want %+v", i, history[i], client)
+		}
+	}
+	// Missing client details are stored as NULL rather than ''
+	if nulls[0] || !nulls[1] {
+		t.Errorf("NULL client details = %v, want only the second login's", nulls)
+	}
+}
+
+func TestUpdatePasswordHistory(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.MigratedPool(t)
+	repo := NewPostgresRepository(db)
+	userID := addUser(t, db, "alice")
+	if _, err := db.Exec(ctx, "UPDATE users SET hashed_password = 'hash-0' WHERE id = $1", userID); err != nil {
+		t.Fatalf("setting the first password: %v", err)
+	}
+
+	// Each change keeps the two passwords before it
+	for _, hash := range []string{"hash-1", "hash-2", "hash-3"} {
+		if err := repo.UpdatePassword(ctx, userID, hash, 2); err != nil {
+			t.Fatalf("UpdatePassword(%s): %v", hash, err)
+		}
+	}
+	history, err := repo.GetPasswordHistory(ctx, userID, 10)
+	if err != nil {
+		t.Fatalf("GetPasswordHistory: %v", err)
+	}
+	if want := []string{"hash-2", "hash-1"}; !slices.Equal(history, want) {
+		t.Errorf("history = %v, want %v", history, want)
+	}
+	if history, err := repo.GetPasswordHistory(ctx, userID, 1); err != nil || !slices.Equal(history, []string{"hash-2"}) {
+		t.Errorf("GetPasswordHistory(1) = %v, %v; want the newest", history, err)
+	}
+
+	// With no history kept, none is recorded and what was kept is cleared
+	if err := repo.UpdatePassword(ctx, userID, "hash-4", 0); err != nil {
+		t.Fatalf("UpdatePassword without history: %v", err)
+	}
+	if history, err := repo.GetPasswordHistory(ctx, userID, 10); err != nil || len(history) != 0 {
+		t.Errorf("history = %v, %v; want it cleared", history, err)
+	}
+	var stored string
+	if err := db.QueryRow(ctx, "SELECT hashed_password FROM users WHERE id = $1", userID).Scan(&stored); err != nil || stored != "hash-4" {
+		t.Errorf("stored password = %q, %v; want hash-4", stored, err)
+	}
+}
+
+func TestUserCounts(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.MigratedPool(t)
+	repo := NewPostgresRepository(db)
+	alice, bob, carol := addUser(t, db, "alice"), addUser(t, db, "bob"), addUser(t, db, "carol")
+
+	counts := func(userID uuid.UUID) Counts {
+		t.Helper()
+		var c Counts
+		err := db.QueryRow(ctx, "SELECT followers_count, following_count, posts_count FROM users WHERE id = $1", userID).
+			Scan(&c.Followers, &c.Following, &c.Posts)
+		if err != nil {
+			t.Fatalf("reading the counts: %v", err)
+		}
+		return c
+	}
+	exec := func(query string, args ...interface{}) {
+		t.Helper()
+		if _, err := db.Exec(ctx, query, args...); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+	}
+	follow := func(followerID, followingID uuid.UUID) {
+		t.Helper()
+		if _, err := repo.Follow(ctx, followerID, followingID); err != nil {
+			t.Fatalf("Follow: %v", err)
+		}
+	}
+	unfollow := func(followerID, followingID uuid.UUID) {
+		t.Helper()
+		if err := repo.Unfollow(ctx, followerID, followingID); err != nil {
+			t.Fatalf("Unfollow: %v", err)
+		}
+	}
+	addPost := func(status string) {
+		t.Helper()
+		exec("INSERT INTO posts (user_id, caption, status) VALUES ($1, 'hi', $2)", alice, status)
+	}
+
+	steps := []struct {
+		name string
+		do   func()
+		user uuid.UUID
+		want Counts
+	}{
+		{name: "followed", do: func() { follow(bob, alice); follow(carol, alice) }, user: alice, want: Counts{Followers: 2}},
+		{name: "followed again", do: func() { follow(bob, alice) }, user: bob, want: Counts{Following: 1}},
+		{name: "unfollowed", do: func() { unfollow(carol, alice) }, user: alice, want: Counts{Followers: 1}},
+		{name: "unfollowed again", do: func() { unfollow(carol, alice) }, user: carol, want: Counts{}},
+		{name: "posted", do: func() { addPost("published"); addPost("draft") }, user: alice, want: Counts{Followers: 1, Posts: 1}},
+		{name: "draft published", do: func() { exec("UPDATE posts SET status = 'published' WHERE user_id = $1", alice) }, user: alice, want: Counts{Followers: 1, Posts: 2}},
+		{name: "post deleted", do: func() {
+			exec("UPDATE posts SET deleted_at = NOW() WHERE id = (SELECT id FROM posts WHERE user_id = $1 LIMIT 1)", alice)
+		}, user: alice, want: Counts{Followers: 1, Posts: 1}},
+		{name: "deleted posts removed", do: func() { exec("DELETE FROM posts WHERE deleted_at IS NOT NULL") }, user: alice, want: Counts{Followers: 1, Posts: 1}},
+		{name: "post removed", do: func() { exec("DELETE FROM posts WHERE user_id = $1", alice) }, user: alice, want: Counts{Followers: 1}},
+	}
+	for _, step := range steps {
+		step.do()
+		if got := counts(step.user); got != step.want {
+			t.Fatalf("%s: counts = %+v, want %+v", step.name, got, step.want)
+		}
+	}
+
+	// Reconciling repairs counts that drifted, and stores them
+	addPost("published")
+	exec("UPDATE users SET followers_count = 42, following_count = 7, posts_count = 0 WHERE id = $1", alice)
+	want := Counts{Followers: 1, Posts: 1}
+	got, err := repo.ReconcileCounts(ctx, alice)
+	if err != nil {
+		t.Fatalf("ReconcileCounts: %v", err)
+	}
+	if *got != want || counts(alice) != want {
+		t.Errorf("reconciled counts = %+v, stored %+v; want %+v", *got, counts(alice), want)
+	}
+
+	if _, err := repo.ReconcileCounts(ctx, uuid.New()); !errors.Is(err, auth.ErrUserNotFound) {
+		t.Errorf("ReconcileCounts() for a missing user error = %v, want %v", err, auth.ErrUserNotFound)
+	}
+}
+
+func TestGetUsersByIDs(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.MigratedPool(t)
+	repo := NewPostgresRepository(db)
+	alice, bob, carol := addUser(t, db, "alice"), addUser(t, db, "bob"), addUser(t, db, "carol")
+	if _, err := db.Exec(ctx, "UPDATE users SET is_active = false WHERE id = $1", carol); err != nil {
+		t.Fatalf("deactivating carol: %v", err)
+	}
+
+	users, err := repo.GetUsersByIDs(ctx, []uuid.UUID{alice, bob, alice, carol, uuid.New()})
+	if err != nil {
+		t.Fatalf("GetUsersByIDs: %v", err)
+	}
+	var got []string
+	for _, u := range users {
+		got = append(got, u.Username)
+	}
+	slices.Sort(got)
+	// Deactivated and missing users are left out, duplicates returned once
+	if want := []string{"alice", "bob"}; !slices.Equal(got, want) {
+		t.Errorf("users = %v, want %v", got, want)
+	}
+
+	if users, err := repo.GetUsersByIDs(ctx, nil); err != nil || len(users) != 0 {
+		t.Errorf("GetUsersByIDs(nil) = %v, %v; want none", users, err)
+	}
+}
+
+func TestGetMutualFollowers(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.MigratedPool(t)
+	repo := NewPostgresRepository(db)
+
+	users := make(map[string]uuid.UUID)
+	for _, name := range []string{"viewer", "target", "bob", "carol", "dave", "erin", "frank", "grace", "heidi"} {
+		users[name] = addUser(t, db, name)
+	}
+	exec := func(query string, args ...interface{}) {
+		t.Helper()
+		if _, err := db.Exec(ctx, query, args...); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+	}
+	follow := func(follower, following string) {
+		t.Helper()
+		if _, err := repo.Follow(ctx, users[follower], users[following]); err != nil {
+			t.Fatalf("Follow(%s, %s): %v", follower, following, err)
+		}
+	}
+
+	// Bob and carol are mutual; dave is only followed by the viewer and erin
+	// only follows the target
+	for _, name := range []string{"bob", "carol", "frank", "grace", "heidi"} {
+		follow("viewer", name)
+		follow(name, "target")
+	}
+	follow("viewer", "dave")
+	follow("erin", "target")
+	// The viewer and the target following each other makes neither a
+	// mutual follower of the other
+	follow("viewer", "target")
+	follow("target", "viewer")
+
+	// Frank is deactivated, and grace and heidi are blocked either way
+	exec("UPDATE users SET is_active = false WHERE id = $1", users["frank"])
+	exec("INSERT INTO blocks (blocker_id, blocked_id) VALUES ($1, $2)", users["grace"], users["viewer"])
+	exec("INSERT INTO blocks (blocker_id, blocked_id) VALUES ($1, $2)", users["viewer"], users["heidi"])
+	// The most followed come first
+	exec("UPDATE users SET followers_count = 5 WHERE id = $1", users["bob"])
+	exec("UPDATE users SET followers_count = 9 WHERE id = $1", users["carol"])
+
+	tests := []struct {
+		name      string
+		viewer    string
+		target    string
+		sample    int
+		wantCount int
+		want      []string
+	}{
+		{name: "all", viewer: "viewer", target: "target", sample: 10, wantCount: 2, want: []string{"carol", "bob"}},
+		{name: "sampled", viewer: "viewer", target: "target", sample: 1, wantCount: 2, want: []string{"carol"}},
+		{name: "none followed", viewer: "erin", target: "target", sample: 10},
+		{name: "reversed", viewer: "target", target: "viewer", sample: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mutual, err := repo.GetMutualFollowers(ctx, users[tt.viewer], users[tt.target], tt.sample)
+			if err != nil {
+				t.Fatalf("GetMutualFollowers: %v", err)
+			}
+			var got []string
+			for _, u := range mutual.Users {
+				got = append(got, u.Username)
+			}
+			if mutual.Count != tt.wantCount || !slices.Equal(got, tt.want) {
+				t.Errorf("mutual followers = %d %v, want %d %v", mutual.Count, got, tt.wantCount, tt.want)
+			}
+		})
+	}
+}
+
+func TestHardDeleteUserCounts(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.MigratedPool(t)
+	repo := NewPostgresRepository(db)
+	alice, bob, carol := addUser(t, db, "alice"), addUser(t, db, "bob"), addUser(t, db, "carol")
+
+	addPost := func(userID uuid.UUID, originalID *uuid.UUID, deleted bool) uuid.UUID {
+		t.Helper()
+		var id uuid.UUID
+		err := db.QueryRow(ctx, `
+			INSERT INTO posts (user_id, caption, status, original_post_id, deleted_at)
+			VALUES ($1, 'hi', 'published', $2, CASE WHEN $3 THEN NOW() END)
+			RETURNING id
+		`, userID, originalID, deleted).Scan(&id)
+		if err != nil {
+			t.Fatalf("adding a post: %v", err)
+		}
+		return id
+	}
+	// repost adds a repost of originalID and counts it as CreateRepost does,
+	// or as Delete does for a deleted one
+	repost := func(userID, originalID uuid.UUID, deleted bool) {
+		t.Helper()
+		addPost(userID, &originalID, deleted)
+		if !deleted {
+			if _, err := db.Exec(ctx, "UPDATE posts SET reposts_count = reposts_count + 1 WHERE id = $1", originalID); err != nil {
+				t.Fatalf("counting the repost: %v", err)
+			}
+		}
+	}
+	repostsCount := func(postID uuid.UUID) int {
+		t.Helper()
+		var count int
+		if err := db.QueryRow(ctx, "SELECT reposts_count FROM posts WHERE id = $1", postID).Scan(&count); err != nil {
+			t.Fatalf("reading reposts_count: %v", err)
+		}
+		return count
+	}
+
+	shared := addPost(alice, nil, false)   // Reposted by bob and carol
+	unshared := addPost(alice, nil, false) // Reposted by bob, who deleted the repost
+	carolsOwn := addPost(carol, nil, false)
+	repost(bob, shared, false)
+	repost(carol, shared, false)
+	repost(bob, unshared, true)
+	repost(carol, carolsOwn, false)
+	// Bob's repost of his own post goes with it
+	bobsOwn := addPost(bob, nil, false)
+	repost(bob, bobsOwn, false)
+
+	if err := repo.HardDeleteUser(ctx, bob); err != nil {
+		t.Fatalf("HardDeleteUser: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		post uuid.UUID
+		want int
+	}{
+		{name: "live repost removed", post: shared, want: 1},
+		{name: "deleted repost already uncounted", post: unshared, want: 0},
+		{name: "other users' reposts kept", post: carolsOwn, want: 1},
+	}
+	for _, tt := range tests {
+		if got := repostsCount(tt.post); got != tt.want {
+			t.Errorf("%s: reposts_count = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+	var left int
+	if err := db.QueryRow(ctx, "SELECT COUNT(*) FROM posts WHERE user_id = $1", bob).Scan(&left); err != nil || left != 0 {
+		t.Errorf("bob has %d posts left, %v; want none", left, err)
+	}
+}
@@ -2,36 +2,73 @@ package user
 
 import (
 	"context"
+	"errors"
+	"time"
 
+	"fowergram-backend/internal/domain/media"
+	"fowergram-backend/internal/events"
 	"fowergram-backend/internal/infra/cache"
+	"fowergram-backend/internal/infra/storage"
 	"fowergram-backend/pkg/auth"
 	"fowergram-backend/pkg/logger"
 
 	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
 )
 
 // Service defines the interface for user business logic
 type Service interface {
 	CreateUser(ctx context.Context, input CreateUserInput) (*User, error)
 	GetUser(ctx context.Context, id uuid.UUID) (*User, error)
+
+	// GetUsersByIDs looks up many users at once, for batching author lookups
+	GetUsersByIDs(ctx context.Context, ids []uuid.UUID) ([]*auth.User, error)
+	GetUserByUsername(ctx context.Context, username string) (*auth.User, error)
 	UpdateUser(ctx context.Context, id uuid.UUID, input UpdateUserInput) (*User, error)
+
+	// SetAvatar replaces the user's avatar with a processed copy of an uploaded image
+	SetAvatar(ctx context.Context, id uuid.UUID, data []byte, contentType string) (*User, error)
+
+	// DeleteAccount permanently deletes the account after re-checking the password
+	DeleteAccount(ctx context.Context, id uuid.UUID, password string) error
+
+	// Social features
+	GetProfile(ctx context.Context, userID, viewerID uuid.UUID) (*UserProfile, error)
+	GetFollowers(ctx context.Context, userID, viewerID uuid.UUID, limit, offset int) ([]*auth.User, error)
+	GetFollowing(ctx context.Context, userID, viewerID uuid.UUID, limit, offset int) ([]*auth.User, error)
+	FollowUser(ctx context.Context, followerID, targetID uuid.UUID) (FollowStatus, error)
+	IsFollowing(ctx context.Context, followerID, targetID uuid.UUID) (bool, error)
+	UnfollowUser(ctx context.Context, followerID, targetID uuid.UUID) error
+
+	// Follow requests for private accounts
+	GetFollowRequests(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*FollowRequest, error)
+	ApproveFollowRequest(ctx context.Context, userID, requestID uuid.UUID) error
+	RejectFollowRequest(ctx context.Context, userID, requestID uuid.UUID) error
+
+	// ReconcileCounts recomputes the user's follower, following and post
+	// counts, for repairing drift
+	ReconcileCounts(ctx context.Context, userID uuid.UUID) (*Counts, error)
 }
 
 // service implements user service
 type service struct {
-	repo   Repository
-	cache  *cache.RedisCache
-	auth   auth.AuthService
-	logger logger.Logger
+	repo      Repository
+	cache     cache.Cache
+	auth      auth.AuthService
+	storage   storage.Storage
+	publisher events.Publisher
+	logger    logger.Logger
 }
 
 // NewService creates a new user service
-func NewService(repo Repository, cache *cache.RedisCache, auth auth.AuthService, logger logger.Logger) Service {
+func NewService(repo Repository, cache cache.Cache, auth auth.AuthService, storage storage.Storage, publisher events.Publisher, logger logger.Logger) Service {
 	return &service{
-		repo:   repo,
-		cache:  cache,
-		auth:   auth,
-		logger: logger,
+		repo:      repo,
+		cache:     cache,
+		auth:      auth,
+		storage:   storage,
+		publisher: publisher,
+		logger:    logger,
 	}
 }
 
@@ -47,8 +84,366 @@ func (s *service) GetUser(ctx context.Context, id uuid.UUID) (*User, error) {
 	return nil, nil
 }
 
-// UpdateUser updates a user
+// GetUsersByIDs returns the active users among ids
+func (s *service) GetUsersByIDs(ctx context.Context, ids []uuid.UUID) ([]*auth.User, error) {
+	return s.repo.GetUsersByIDs(ctx, ids)
+}
+
+// GetUserByUsername returns the active user with username
+func (s *service) GetUserByUsername(ctx context.Context, username string) (*auth.User, error) {
+	return s.repo.GetUserByUsername(ctx, username)
+}
+
+// UpdateUser applies a profile update based on input.Version. If the profile
+// changed since that version, ErrVersionConflict is returned and nothing is written.
 func (s *service) UpdateUser(ctx context.Context, id uuid.UUID, input UpdateUserInput) (*User, error) {
-	// Implementation would update user
-	return nil, nil
+	current, err := s.repo.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if current.Version != input.Version {
+		return nil, ErrVersionConflict
+	}
+
+	if input.Username != nil && *input.Username != current.Username {
+		existing, err := s.repo.GetUserByUsername(ctx, *input.Username)
+		if err != nil && !errors.Is(err, auth.ErrUserNotFound) {
+			return nil, err
+		}
+		if existing != nil {
+			return nil, auth.ErrUserExists
+		}
+		current.Username = *input.Username
+	}
+	if input.FullName != nil {
+		current.FullName = *input.FullName
+	}
+	if input.Bio != nil {
+		current.Bio = *input.Bio
+	}
+	if input.Avatar != nil {
+		current.ProfilePicture = *input.Avatar
+	}
+	if input.IsPrivate != nil {
+		current.IsPrivate = *input.IsPrivate
+	}
+
+	if err := s.repo.UpdateUser(ctx, current); err != nil {
+		return nil, err
+	}
+
+	return toUser(current), nil
+}
+
+// SetAvatar center-crops and resizes an uploaded image, stores it under the
+// user's avatar prefix and points profile_picture at it. Previous avatars are
+// deleted afterwards; a failure there only leaves an orphaned object behind.
+// Without a CDN base URL the stored link is presigned and eventually expires.
+func (s *service) SetAvatar(ctx context.Context, id uuid.UUID, data []byte, contentType string) (*User, error) {
+	if !media.IsAllowedContentType(contentType) {
+		return nil, media.ErrUnsupportedContentType
+	}
+
+	avatar, err := media.ProcessAvatar(data)
+	if err != nil {
+		return nil, err
+	}
+
+	key := avatarKey(id)
+	if err := s.storage.UploadFile(ctx, key, avatar, "image/jpeg"); err != nil {
+		return nil, err
+	}
+
+	url, err := s.storage.GetFileURL(ctx, key)
+	if err == nil {
+		err = s.repo.UpdateProfilePicture(ctx, id, url)
+	}
+	if err != nil {
+		if delErr := s.storage.DeleteFile(ctx, key); delErr != nil {
+			s.logger.Error("Failed to delete unused avatar", "user_id", id, "key", key, "error", delErr)
+		}
+		return nil, err
+	}
+
+	s.deleteAvatarsExcept(ctx, id, key)
+
+	updated, err := s.repo.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return toUser(updated), nil
+}
+
+// deleteAvatarsExcept removes every stored avatar of the user other than keep
+func (s *service) deleteAvatarsExcept(ctx context.Context, id uuid.UUID, keep string) {
+	log := s.logger.With("user_id", id)
+
+	keys, err := s.storage.ListFiles(ctx, AvatarKeyPrefix(id))
+	if err != nil {
+		log.Error("Failed to list previous avatars", "error", err)
+		return
+	}
+
+	for _, key := range keys {
+		if key == keep {
+			continue
+		}
+		if err := s.storage.DeleteFile(ctx, key); err != nil {
+			log.Error("Failed to delete previous avatar", "key", key, "error", err)
+		}
+	}
+}
+
+// DeleteAccount permanently deletes a user and all of their data, then
+// publishes a UserDeleted event so other services can clean up (e.g. stored media)
+func (s *service) DeleteAccount(ctx context.Context, id uuid.UUID, password string) error {
+	user, err := s.repo.GetUserByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.HashedPassword), []byte(password)); err != nil {
+		return auth.ErrInvalidCredentials
+	}
+
+	if err := s.repo.HardDeleteUser(ctx, id); err != nil {
+		return err
+	}
+
+	s.publish(ctx, id, events.UserDeleted{UserID: id, DeletedAt: time.Now()})
+
+	return nil
+}
+
+// GetFollowers lists the followers of a user the viewer is allowed to see
+func (s *service) GetFollowers(ctx context.Context, userID, viewerID uuid.UUID, limit, offset int) ([]*auth.User, error) {
+	if err := s.checkConnectionsVisible(ctx, userID, viewerID); err != nil {
+		return nil, err
+	}
+	return s.repo.GetFollowers(ctx, userID, limit, offset)
+}
+
+// GetFollowing lists the accounts a user follows, if the viewer is allowed to see them
+func (s *service) GetFollowing(ctx context.Context, userID, viewerID uuid.UUID, limit, offset int) ([]*auth.User, error) {
+	if err := s.checkConnectionsVisible(ctx, userID, viewerID); err != nil {
+		return nil, err
+	}
+	return s.repo.GetFollowing(ctx, userID, limit, offset)
+}
+
+// GetProfile retrieves a user's profile as seen by the viewer: how the two
+// are connected and, unless the user's followers are hidden from the viewer,
+// which of the accounts the viewer follows also follow the user. A user
+// blocking or blocked by the viewer isn't found.
+func (s *service) GetProfile(ctx context.Context, userID, viewerID uuid.UUID) (*UserProfile, error) {
+	target, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !target.IsActive {
+		return nil, auth.ErrUserNotFound
+	}
+
+	profile := &UserProfile{
+		ID:             target.ID,
+		Username:       target.Username,
+		FullName:       optionalString(target.FullName),
+		Bio:            optionalString(target.Bio),
+		Avatar:         optionalString(target.ProfilePicture),
+		IsPrivate:      target.IsPrivate,
+		IsVerified:     target.IsVerified,
+		PostCount:      target.PostsCount,
+		FollowerCount:  target.FollowersCount,
+		FollowingCount: target.FollowingCount,
+		CreatedAt:      target.CreatedAt,
+	}
+	if userID == viewerID {
+		return profile, nil
+	}
+
+	blocked, err := s.repo.IsBlockedEither(ctx, viewerID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if blocked {
+		return nil, auth.ErrUserNotFound
+	}
+	if profile.IsFollowing, err = s.repo.IsFollowing(ctx, viewerID, userID); err != nil {
+		return nil, err
+	}
+	if profile.IsFollowedBy, err = s.repo.IsFollowing(ctx, userID, viewerID); err != nil {
+		return nil, err
+	}
+
+	// Same rule as checkConnectionsVisible, without looking the user up again
+	if target.IsPrivate && !profile.IsFollowing {
+		return profile, nil
+	}
+	if profile.MutualFollowers, err = s.repo.GetMutualFollowers(ctx, viewerID, userID, MutualFollowersSample); err != nil {
+		return nil, err
+	}
+
+	return profile, nil
+}
+
+// optionalString returns nil for empty strings
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// checkConnectionsVisible allows the owner and, for private accounts, only
+// approved followers to see a user's follower and following lists
+func (s *service) checkConnectionsVisible(ctx context.Context, userID, viewerID uuid.UUID) error {
+	target, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !target.IsActive {
+		return auth.ErrUserNotFound
+	}
+
+	if userID == viewerID || !target.IsPrivate {
+		return nil
+	}
+
+	following, err := s.repo.IsFollowing(ctx, viewerID, userID)
+	if err != nil {
+		return err
+	}
+	if !following {
+		return ErrPrivateAccount
+	}
+
+	return nil
+}
+
+// FollowUser follows a public account directly, or files a follow request
+// for a private one. Repeating either is a no-op.
+func (s *service) FollowUser(ctx context.Context, followerID, targetID uuid.UUID) (FollowStatus, error) {
+	if followerID == targetID {
+		return "", ErrCannotFollowSelf
+	}
+
+	target, err := s.repo.GetUserByID(ctx, targetID)
+	if err != nil {
+		return "", err
+	}
+	if !target.IsActive {
+		return "", auth.ErrUserNotFound
+	}
+
+	blocked, err := s.repo.IsBlockedEither(ctx, followerID, targetID)
+	if err != nil {
+		return "", err
+	}
+	if blocked {
+		return "", ErrFollowBlocked
+	}
+
+	if target.IsPrivate {
+		following, err := s.repo.IsFollowing(ctx, followerID, targetID)
+		if err != nil {
+			return "", err
+		}
+		if following {
+			return FollowStatusFollowing, nil
+		}
+
+		created, err := s.repo.CreateFollowRequest(ctx, followerID, targetID)
+		if err != nil {
+			return "", err
+		}
+		if created {
+			s.publish(ctx, followerID, events.FollowRequested{Follow: newFollow(followerID, targetID)})
+		}
+		return FollowStatusRequested, nil
+	}
+
+	created, err := s.repo.Follow(ctx, followerID, targetID)
+	if err != nil {
+		return "", err
+	}
+	if created {
+		s.publish(ctx, followerID, events.UserFollowed{Follow: newFollow(followerID, targetID)})
+	}
+
+	return FollowStatusFollowing, nil
+}
+
+// UnfollowUser removes a follow, or withdraws a pending follow request
+func (s *service) UnfollowUser(ctx context.Context, followerID, targetID uuid.UUID) error {
+	return s.repo.Unfollow(ctx, followerID, targetID)
+}
+
+// IsFollowing reports whether followerID follows targetID. Pending follow
+// requests don't count.
+func (s *service) IsFollowing(ctx context.Context, followerID, targetID uuid.UUID) (bool, error) {
+	return s.repo.IsFollowing(ctx, followerID, targetID)
+}
+
+// GetFollowRequests lists pending requests to follow the user
+func (s *service) GetFollowRequests(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*FollowRequest, error) {
+	return s.repo.GetIncomingFollowRequests(ctx, userID, limit, offset)
+}
+
+// ApproveFollowRequest accepts a pending request and notifies the requester
+func (s *service) ApproveFollowRequest(ctx context.Context, userID, requestID uuid.UUID) error {
+	req, err := s.repo.ApproveFollowRequest(ctx, requestID, userID)
+	if err != nil {
+		return err
+	}
+
+	s.publish(ctx, userID, events.FollowRequestApproved{Follow: newFollow(req.RequesterID, userID)})
+
+	return nil
+}
+
+// RejectFollowRequest discards a pending request; the requester is not notified
+func (s *service) RejectFollowRequest(ctx context.Context, userID, requestID uuid.UUID) error {
+	_, err := s.repo.RejectFollowRequest(ctx, requestID, userID)
+	return err
+}
+
+// ReconcileCounts recomputes the user's follower, following and post counts
+func (s *service) ReconcileCounts(ctx context.Context, userID uuid.UUID) (*Counts, error) {
+	return s.repo.ReconcileCounts(ctx, userID)
+}
+
+// publish sends a best-effort event; failures are logged, not returned
+func (s *service) publish(ctx context.Context, actorID uuid.UUID, payload events.Payload) {
+	if err := s.publisher.Publish(ctx, actorID, payload); err != nil {
+		s.logger.Error("Failed to publish event", "type", payload.EventType(), "error", err)
+	}
+}
+
+func newFollow(followerID, followingID uuid.UUID) events.Follow {
+	return events.Follow{FollowerID: followerID, FollowingID: followingID, CreatedAt: time.Now()}
+}
+
+// toUser converts an auth user to the user domain model
+func toUser(u *auth.User) *User {
+	user := &User{
+		ID:         u.ID,
+		Email:      u.Email,
+		Username:   u.Username,
+		IsPrivate:  u.IsPrivate,
+		IsVerified: u.IsVerified,
+		Version:    u.Version,
+		CreatedAt:  u.CreatedAt,
+		UpdatedAt:  u.UpdatedAt,
+	}
+	if u.FullName != "" {
+		user.FullName = &u.FullName
+	}
+	if u.Bio != "" {
+		user.Bio = &u.Bio
+	}
+	if u.ProfilePicture != "" {
+		user.Avatar = &u.ProfilePicture
+	}
+	return user
 }
@@ -0,0 +1,761 @@
+package user
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"maps"
+	"slices"
+	"testing"
+
+	"fowergram-backend/internal/domain/media"
+	"fowergram-backend/internal/events"
+	"fowergram-backend/internal/infra/messaging"
+	"fowergram-backend/internal/infra/storage"
+	"fowergram-backend/pkg/auth"
+	"fowergram-backend/pkg/logger"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// fakeRepository holds a follow graph in memory. Methods the tests don't
+// reach are left to the embedded nil Repository and panic if called.
+type fakeRepository struct {
+	Repository
+
+	users    map[uuid.UUID]*auth.User
+	follows  map[[2]uuid.UUID]bool // Follower and followed
+	followed [][2]uuid.UUID        // Follows made by follow, oldest first
+	blocks   map[[2]uuid.UUID]bool // Blocker and blocked
+	requests []*FollowRequest      // Pending follow requests, oldest first
+
+	beforeUpdate func() // Runs once before the next UpdateUser writes, like a concurrent edit
+}
+
+func newFakeRepository() *fakeRepository {
+	return &fakeRepository{
+		users:   make(map[uuid.UUID]*auth.User),
+		follows: make(map[[2]uuid.UUID]bool),
+		blocks:  make(map[[2]uuid.UUID]bool),
+	}
+}
+
+// addUser adds an active user named username
+func (r *fakeRepository) addUser(username string, private bool) uuid.UUID {
+	id := uuid.New()
+	r.users[id] = &auth.User{ID: id, Username: username, IsActive: true, IsPrivate: private, Version: 1}
+	return id
+}
+
+// follow makes followerID follow followingID
+func (r *fakeRepository) follow(followerID, followingID uuid.UUID) {
+	r.follows[[2]uuid.UUID{followerID, followingID}] = true
+	r.followed = append(r.followed, [2]uuid.UUID{followerID, followingID})
+}
+
+func (r *fakeRepository) GetFollowers(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*auth.User, error) {
+	return r.connections(func(f [2]uuid.UUID) (uuid.UUID, bool) { return f[0], f[1] == userID }, limit, offset), nil
+}
+
+func (r *fakeRepository) GetFollowing(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*auth.User, error) {
+	return r.connections(func(f [2]uuid.UUID) (uuid.UUID, bool) { return f[1], f[0] == userID }, limit, offset), nil
+}
+
+// connections pages through the active users at one end of the follows
+// match picks, most recently followed first
+func (r *fakeRepository) connections(match func([2]uuid.UUID) (uuid.UUID, bool), limit, offset int) []*auth.User {
+	var users []*auth.User
+	for i := len(r.followed) - 1; i >= 0; i-- {
+		id, ok := match(r.followed[i])
+		if ok && r.users[id].IsActive {
+			users = append(users, r.users[id])
+		}
+	}
+	if offset >= len(users) {
+		return nil
+	}
+	return users[offset:min(offset+limit, len(users))]
+}
+
+func (r *fakeRepository) GetUserByID(ctx context.Context, id uuid.UUID) (*auth.User, error) {
+	user, ok := r.users[id]
+	if !ok {
+		return nil, auth.ErrUserNotFound
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (r *fakeRepository) UpdateProfilePicture(ctx context.Context, userID uuid.UUID, url string) error {
+	user, ok := r.users[userID]
+	if !ok {
+		return auth.ErrUserNotFound
+	}
+	user.ProfilePicture = url
+	user.Version++
+	return nil
+}
+
+func (r *fakeRepository) IsFollowing(ctx context.Context, followerID, followingID uuid.UUID) (bool, error) {
+	return r.follows[[2]uuid.UUID{followerID, followingID}], nil
+}
+
+func (r *fakeRepository) IsBlockedEither(ctx context.Context, userID, otherID uuid.UUID) (bool, error) {
+	return r.blocks[[2]uuid.UUID{userID, otherID}] || r.blocks[[2]uuid.UUID{otherID, userID}], nil
+}
+
+// GetMutualFollowers follows the rules of the SQL: active users the viewer
+// follows who follow the target, unless blocked either way with the viewer
+func (r *fakeRepository) GetMutualFollowers(ctx context.Context, viewerID, targetID uuid.UUID, sample int) (*MutualFollowers, error) {
+	mutual := &MutualFollowers{Users: []*auth.User{}}
+	for id, user := range r.users {
+		blocked, _ := r.IsBlockedEither(ctx, viewerID, id)
+		if !user.IsActive || blocked || !r.follows[[2]uuid.UUID{viewerID, id}] || !r.follows[[2]uuid.UUID{id, targetID}] {
+			continue
+		}
+		mutual.Count++
+		if len(mutual.Users) < sample {
+			mutual.Users = append(mutual.Users, user)
+		}
+	}
+	return mutual, nil
+}
+
+func TestGetProfile(t *testing.T) {
+	repo := newFakeRepository()
+	viewer := repo.addUser("viewer", false)
+	public := repo.addUser("public", false)
+	private := repo.addUser("private", true)
+	followedPrivate := repo.addUser("followedprivate", true)
+	blocked := repo.addUser("blocked", false)
+	blocker := repo.addUser("blocker", false)
+	inactive := repo.addUser("inactive", false)
+	repo.users[inactive].IsActive = false
+
+	// Accounts the viewer follows, all of which follow every profile
+	friend := repo.addUser("friend", false)
+	otherFriend := repo.addUser("otherfriend", false)
+	blockedFriend := repo.addUser("blockedfriend", false)
+	inactiveFriend := repo.addUser("inactivefriend", false)
+	repo.users[inactiveFriend].IsActive = false
+	stranger := repo.addUser("stranger", false) // Followed by nobody the viewer follows
+
+	for _, f := range []uuid.UUID{friend, otherFriend, blockedFriend, inactiveFriend} {
+		repo.follows[[2]uuid.UUID{viewer, f}] = true
+		for _, target := range []uuid.UUID{public, private, followedPrivate, blocked, blocker} {
+			repo.follows[[2]uuid.UUID{f, target}] = true
+		}
+	}
+	repo.follows[[2]uuid.UUID{stranger, public}] = true
+	repo.follows[[2]uuid.UUID{viewer, followedPrivate}] = true
+	repo.follows[[2]uuid.UUID{public, viewer}] = true
+	repo.blocks[[2]uuid.UUID{viewer, blocked}] = true
+	repo.blocks[[2]uuid.UUID{blocker, viewer}] = true
+	repo.blocks[[2]uuid.UUID{viewer, blockedFriend}] = true
+
+	service := NewService(repo, nil, nil, nil, nil, logger.NewZapLogger())
+
+	tests := []struct {
+		name           string
+		userID         uuid.UUID
+		wantErr        error
+		wantFollowing  bool
+		wantFollowedBy bool
+		wantMutual     []string // Nil when mutual followers are left out
+	}{
+		{name: "own profile", userID: viewer},
+		{
+			name:           "public account",
+			userID:         public,
+			wantFollowedBy: true,
+			wantMutual:     []string{"friend", "otherfriend"},
+		},
+		{name: "private account not followed", userID: private},
+		{
+			name:          "private account followed",
+			userID:        followedPrivate,
+			wantFollowing: true,
+			wantMutual:    []string{"friend", "otherfriend"},
+		},
+		{name: "blocked by the viewer", userID: blocked, wantErr: auth.ErrUserNotFound},
+		{name: "blocking the viewer", userID: blocker, wantErr: auth.ErrUserNotFound},
+		{name: "inactive account", userID: inactive, wantErr: auth.ErrUserNotFound},
+		{name: "unknown account", userID: uuid.New(), wantErr: auth.ErrUserNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			profile, err := service.GetProfile(context.Background(), tt.userID, viewer)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetProfile error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if profile != nil {
+					t.Errorf("GetProfile returned a profile with %v", err)
+				}
+				return
+			}
+
+			if profile.IsFollowing != tt.wantFollowing || profile.IsFollowedBy != tt.wantFollowedBy {
+				t.Errorf("following = %v, followed by = %v, want %v and %v",
+					profile.IsFollowing, profile.IsFollowedBy, tt.wantFollowing, tt.wantFollowedBy)
+			}
+
+			if tt.wantMutual == nil {
+				if profile.MutualFollowers != nil {
+					t.Errorf("mutual followers = %+v, want none", profile.MutualFollowers)
+				}
+				return
+			}
+			if profile.MutualFollowers == nil {
+				t.Fatalf("mutual followers left out, want %q", tt.wantMutual)
+			}
+			var got []string
+			for _, u := range profile.MutualFollowers.Users {
+				got = append(got, u.Username)
+			}
+			slices.Sort(got)
+			if profile.MutualFollowers.Count != len(tt.wantMutual) || !slices.Equal(got, tt.wantMutual) {
+				t.Errorf("mutual followers = %d %q, want %d %q",
+					profile.MutualFollowers.Count, got, len(tt.wantMutual), tt.wantMutual)
+			}
+		})
+	}
+}
+
+func TestConnectionLists(t *testing.T) {
+	repo := newFakeRepository()
+	viewer := repo.addUser("viewer", false)
+	public := repo.addUser("public", false)
+	private := repo.addUser("private", true)
+	followedPrivate := repo.addUser("followedprivate", true)
+	inactive := repo.addUser("inactive", false)
+	repo.users[inactive].IsActive = false
+
+	// Each account follows and is followed by first, second and third, in
+	// that order, and by the inactive account last
+	var others []uuid.UUID
+	for _, name := range []string{"first", "second", "third"} {
+		others = append(others, repo.addUser(name, false))
+	}
+	for _, target := range []uuid.UUID{viewer, public, private, followedPrivate} {
+		for _, other := range others {
+			repo.follow(other, target)
+			repo.follow(target, other)
+		}
+		repo.follow(inactive, target)
+	}
+	repo.follow(viewer, followedPrivate)
+
+	service := NewService(repo, nil, nil, nil, nil, logger.NewZapLogger())
+
+	newestFirst := []string{"third", "second", "first"}
+	tests := []struct {
+		name          string
+		userID        uuid.UUID
+		limit         int
+		offset        int
+		wantFollowers []string
+		wantFollowing []string
+		wantErr       error
+	}{
+		{
+			name:          "public account, newest first without inactive users",
+			userID:        public,
+			limit:         20,
+			wantFollowers: newestFirst,
+			wantFollowing: newestFirst,
+		},
+		{name: "first page", userID: public, limit: 2, wantFollowers: newestFirst[:2], wantFollowing: newestFirst[:2]},
+		{name: "second page", userID: public, limit: 2, offset: 2, wantFollowers: newestFirst[2:], wantFollowing: newestFirst[2:]},
+		{name: "past the end", userID: public, limit: 2, offset: 4},
+		{
+			name:          "own account",
+			userID:        viewer,
+			limit:         20,
+			wantFollowers: newestFirst,
+			wantFollowing: append([]string{"followedprivate"}, newestFirst...),
+		},
+		{
+			name:          "followed private account",
+			userID:        followedPrivate,
+			limit:         20,
+			wantFollowers: append([]string{"viewer"}, newestFirst...),
+			wantFollowing: newestFirst,
+		},
+		{name: "private account not followed", userID: private, limit: 20, wantErr: ErrPrivateAccount},
+		{name: "inactive account", userID: inactive, limit: 20, wantErr: auth.ErrUserNotFound},
+		{name: "unknown account", userID: uuid.New(), limit: 20, wantErr: auth.ErrUserNotFound},
+	}
+
+	for _, tt := range tests {
+		lists := []struct {
+			name string
+			list func() ([]*auth.User, error)
+			want []string
+		}{
+			{
+				name: "followers",
+				list: func() ([]*auth.User, error) {
+					return service.GetFollowers(context.Background(), tt.userID, viewer, tt.limit, tt.offset)
+				},
+				want: tt.wantFollowers,
+			},
+			{
+				name: "following",
+				list: func() ([]*auth.User, error) {
+					return service.GetFollowing(context.Background(), tt.userID, viewer, tt.limit, tt.offset)
+				},
+				want: tt.wantFollowing,
+			},
+		}
+		for _, list := range lists {
+			t.Run(tt.name+" "+list.name, func(t *testing.T) {
+				users, err := list.list()
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("error = %v, want %v", err, tt.wantErr)
+				}
+				var got []string
+				for _, u := range users {
+					got = append(got, u.Username)
+				}
+				if !slices.Equal(got, list.want) {
+					t.Errorf("users = %q, want %q", got, list.want)
+				}
+			})
+		}
+	}
+}
+
+func (r *fakeRepository) HardDeleteUser(ctx context.Context, userID uuid.UUID) error {
+	delete(r.users, userID)
+	for follow := range r.follows {
+		if follow[0] == userID || follow[1] == userID {
+			delete(r.follows, follow)
+		}
+	}
+	return nil
+}
+
+func TestDeleteAccount(t *testing.T) {
+	const password = "correct horse battery staple"
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("hashing password: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		password    string
+		unknownUser bool
+		wantErr     error
+	}{
+		{name: "confirmed with the password", password: password},
+		{name: "wrong password", password: "wrong password", wantErr: auth.ErrInvalidCredentials},
+		{name: "unknown user", password: password, unknownUser: true, wantErr: auth.ErrUserNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeRepository()
+			id := repo.addUser("leaving", false)
+			repo.users[id].HashedPassword = string(hashed)
+			friend := repo.addUser("friend", false)
+			repo.follow(friend, id)
+			recorder := messaging.NewRecordingClient()
+			log := logger.NewZapLogger()
+			service := NewService(repo, nil, nil, nil, events.NewNATSPublisher(recorder, log), log)
+
+			target := id
+			if tt.unknownUser {
+				target = uuid.New()
+			}
+			err := service.DeleteAccount(context.Background(), target, tt.password)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("DeleteAccount error = %v, want %v", err, tt.wantErr)
+			}
+
+			deleted := tt.wantErr == nil
+			if _, stored := repo.users[id]; stored == deleted {
+				t.Errorf("user still stored = %v, want %v", stored, !deleted)
+			}
+			if following := repo.follows[[2]uuid.UUID{friend, id}]; following == deleted {
+				t.Errorf("follow kept = %v, want %v", following, !deleted)
+			}
+
+			published := recorder.PublishedTo(string(events.TypeUserDeleted))
+			if !deleted {
+				if len(published) != 0 {
+					t.Errorf("published %d %s events without deleting", len(published), events.TypeUserDeleted)
+				}
+				return
+			}
+			if len(published) != 1 {
+				t.Fatalf("published %d %s events, want 1", len(published), events.TypeUserDeleted)
+			}
+			envelope, err := events.Decode(published[0].Data)
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			var payload events.UserDeleted
+			if err := envelope.DecodePayload(&payload); err != nil {
+				t.Fatalf("DecodePayload: %v", err)
+			}
+			if payload.UserID != id {
+				t.Errorf("deleted user = %s, want %s", payload.UserID, id)
+			}
+		})
+	}
+}
+
+func (r *fakeRepository) Follow(ctx context.Context, followerID, followingID uuid.UUID) (bool, error) {
+	if r.follows[[2]uuid.UUID{followerID, followingID}] {
+		return false, nil
+	}
+	r.follow(followerID, followingID)
+	return true, nil
+}
+
+func (r *fakeRepository) CreateFollowRequest(ctx context.Context, requesterID, targetID uuid.UUID) (bool, error) {
+	for _, req := range r.requests {
+		if req.RequesterID == requesterID && req.TargetID == targetID {
+			return false, nil
+		}
+	}
+	req := &FollowRequest{ID: uuid.New(), RequesterID: requesterID, TargetID: targetID, Username: r.users[requesterID].Username}
+	r.requests = append(r.requests, req)
+	return true, nil
+}
+
+func (r *fakeRepository) GetIncomingFollowRequests(ctx context.Context, targetID uuid.UUID, limit, offset int) ([]*FollowRequest, error) {
+	var requests []*FollowRequest
+	for i := len(r.requests) - 1; i >= 0; i-- {
+		if r.requests[i].TargetID == targetID {
+			requests = append(requests, r.requests[i])
+		}
+	}
+	if offset >= len(requests) {
+		return nil, nil
+	}
+	return requests[offset:min(offset+limit, len(requests))], nil
+}
+
+func (r *fakeRepository) ApproveFollowRequest(ctx context.Context, requestID, targetID uuid.UUID) (*FollowRequest, error) {
+	req, err := r.RejectFollowRequest(ctx, requestID, targetID)
+	if err != nil {
+		return nil, err
+	}
+	_, err = r.Follow(ctx, req.RequesterID, req.TargetID)
+	return req, err
+}
+
+// RejectFollowRequest removes a request addressed to targetID, as
+// deleteFollowRequest does
+func (r *fakeRepository) RejectFollowRequest(ctx context.Context, requestID, targetID uuid.UUID) (*FollowRequest, error) {
+	for i, req := range r.requests {
+		if req.ID == requestID && req.TargetID == targetID {
+			r.requests = slices.Delete(r.requests, i, i+1)
+			return req, nil
+		}
+	}
+	return nil, ErrFollowRequestNotFound
+}
+
+func TestFollowRequests(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeRepository()
+	alice := repo.addUser("alice", false)
+	bob := repo.addUser("bob", true)
+	carol := repo.addUser("carol", false)
+	dave := repo.addUser("dave", false)
+	recorder := messaging.NewRecordingClient()
+	log := logger.NewZapLogger()
+	service := NewService(repo, nil, nil, nil, events.NewNATSPublisher(recorder, log), log)
+
+	// published counts the events of each type sent so far
+	published := func() map[events.Type]int {
+		return map[events.Type]int{
+			events.TypeUserFollowed:          len(recorder.PublishedTo(string(events.TypeUserFollowed))),
+			events.TypeFollowRequested:       len(recorder.PublishedTo(string(events.TypeFollowRequested))),
+			events.TypeFollowRequestApproved: len(recorder.PublishedTo(string(events.TypeFollowRequestApproved))),
+		}
+	}
+	// requestFrom finds the pending request requesterID sent bob
+	requestFrom := func(t *testing.T, requesterID uuid.UUID) uuid.UUID {
+		t.Helper()
+		requests, err := service.GetFollowRequests(ctx, bob, 20, 0)
+		if err != nil {
+			t.Fatalf("GetFollowRequests: %v", err)
+		}
+		for _, req := range requests {
+			if req.RequesterID == requesterID {
+				return req.ID
+			}
+		}
+		t.Fatalf("no pending request from %s in %d requests", requesterID, len(requests))
+		return uuid.Nil
+	}
+	follow := func(t *testing.T, followerID, targetID uuid.UUID, want FollowStatus) {
+		t.Helper()
+		status, err := service.FollowUser(ctx, followerID, targetID)
+		if err != nil || status != want {
+			t.Fatalf("FollowUser = %q, %v; want %q", status, err, want)
+		}
+	}
+
+	t.Run("following a public account follows it", func(t *testing.T) {
+		follow(t, alice, carol, FollowStatusFollowing)
+		if !repo.follows[[2]uuid.UUID{alice, carol}] {
+			t.Error("alice doesn't follow carol")
+		}
+		if got := published()[events.TypeUserFollowed]; got != 1 {
+			t.Errorf("published %d %s events, want 1", got, events.TypeUserFollowed)
+		}
+	})
+
+	t.Run("following a private account files a request", func(t *testing.T) {
+		follow(t, alice, bob, FollowStatusRequested)
+		follow(t, alice, bob, FollowStatusRequested)
+		if repo.follows[[2]uuid.UUID{alice, bob}] {
+			t.Error("alice follows bob before approval")
+		}
+		if got := published()[events.TypeFollowRequested]; got != 1 {
+			t.Errorf("published %d %s events for a repeated request, want 1", got, events.TypeFollowRequested)
+		}
+		requestFrom(t, alice)
+	})
+
+	t.Run("only the target can answer a request", func(t *testing.T) {
+		id := requestFrom(t, alice)
+		if err := service.ApproveFollowRequest(ctx, carol, id); !errors.Is(err, ErrFollowRequestNotFound) {
+			t.Errorf("ApproveFollowRequest by carol = %v, want %v", err, ErrFollowRequestNotFound)
+		}
+		if err := service.RejectFollowRequest(ctx, carol, id); !errors.Is(err, ErrFollowRequestNotFound) {
+			t.Errorf("RejectFollowRequest by carol = %v, want %v", err, ErrFollowRequestNotFound)
+		}
+		requestFrom(t, alice)
+	})
+
+	t.Run("approval creates the follow", func(t *testing.T) {
+		if err := service.ApproveFollowRequest(ctx, bob, requestFrom(t, alice)); err != nil {
+			t.Fatalf("ApproveFollowRequest: %v", err)
+		}
+		if !repo.follows[[2]uuid.UUID{alice, bob}] {
+			t.Error("alice doesn't follow bob after approval")
+		}
+		if got := published()[events.TypeFollowRequestApproved]; got != 1 {
+			t.Errorf("published %d %s events, want 1", got, events.TypeFollowRequestApproved)
+		}
+		if requests, _ := service.GetFollowRequests(ctx, bob, 20, 0); len(requests) != 0 {
+			t.Errorf("%d requests still pending after approval", len(requests))
+		}
+		follow(t, alice, bob, FollowStatusFollowing)
+	})
+
+	t.Run("rejection discards the request", func(t *testing.T) {
+		follow(t, dave, bob, FollowStatusRequested)
+		before := published()
+		if err := service.RejectFollowRequest(ctx, bob, requestFrom(t, dave)); err != nil {
+			t.Fatalf("RejectFollowRequest: %v", err)
+		}
+		if repo.follows[[2]uuid.UUID{dave, bob}] {
+			t.Error("dave follows bob after rejection")
+		}
+		if requests, _ := service.GetFollowRequests(ctx, bob, 20, 0); len(requests) != 0 {
+			t.Errorf("%d requests still pending after rejection", len(requests))
+		}
+		if after := published(); !maps.Equal(after, before) {
+			t.Errorf("rejection published events: %v, then %v", before, after)
+		}
+	})
+}
+
+// UpdateUser writes the user only if the stored version still matches, as
+// the SQL's version check does
+func (r *fakeRepository) UpdateUser(ctx context.Context, user *auth.User) error {
+	if interleave := r.beforeUpdate; interleave != nil {
+		r.beforeUpdate = nil
+		interleave()
+	}
+	stored, ok := r.users[user.ID]
+	if !ok || stored.Version != user.Version {
+		return ErrVersionConflict
+	}
+	user.Version++
+	copied := *user
+	r.users[user.ID] = &copied
+	return nil
+}
+
+func TestUpdateUserVersions(t *testing.T) {
+	ctx := context.Background()
+	log := logger.NewZapLogger()
+
+	t.Run("an edit from a stale version conflicts", func(t *testing.T) {
+		repo := newFakeRepository()
+		id := repo.addUser("alice", false)
+		service := NewService(repo, nil, nil, nil, nil, log)
+
+		updated, err := service.UpdateUser(ctx, id, UpdateUserInput{Bio: ptr("first"), Version: 1})
+		if err != nil {
+			t.Fatalf("first UpdateUser: %v", err)
+		}
+		if updated.Version != 2 {
+			t.Errorf("version = %d, want 2", updated.Version)
+		}
+		if _, err := service.UpdateUser(ctx, id, UpdateUserInput{Bio: ptr("second"), Version: 1}); !errors.Is(err, ErrVersionConflict) {
+			t.Fatalf("second UpdateUser error = %v, want %v", err, ErrVersionConflict)
+		}
+		if stored := repo.users[id]; stored.Bio != "first" || stored.Version != 2 {
+			t.Errorf("stored bio %q at version %d, want the first edit's at 2", stored.Bio, stored.Version)
+		}
+	})
+
+	t.Run("racing edits from the same version", func(t *testing.T) {
+		repo := newFakeRepository()
+		id := repo.addUser("alice", false)
+		service := NewService(repo, nil, nil, nil, nil, log)
+
+		// The second edit lands after the first one read the profile but
+		// before it writes
+		var raced error
+		repo.beforeUpdate = func() {
+			_, raced = service.UpdateUser(ctx, id, UpdateUserInput{Bio: ptr("second"), Version: 1})
+		}
+		_, err := service.UpdateUser(ctx, id, UpdateUserInput{Bio: ptr("first"), Version: 1})
+		if raced != nil {
+			t.Fatalf("edit that wrote first: %v", raced)
+		}
+		if !errors.Is(err, ErrVersionConflict) {
+			t.Fatalf("edit that wrote second: error = %v, want %v", err, ErrVersionConflict)
+		}
+		if stored := repo.users[id]; stored.Bio != "second" || stored.Version != 2 {
+			t.Errorf("stored bio %q at version %d, want the second edit's at 2", stored.Bio, stored.Version)
+		}
+	})
+}
+
+func ptr(s string) *string {
+	return &s
+}
+
+// bannerImage encodes a width by height PNG, blue in the centered square and
+// red either side of it
+func bannerImage(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	margin := (width - height) / 2
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			c := color.NRGBA{B: 255, A: 255}
+			if x < margin || x >= width-margin {
+				c = color.NRGBA{R: 255, A: 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("encoding the image: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// oversizedPNG returns a PNG header declaring width×height pixels, followed
+// by no image data
+func oversizedPNG(width, height uint32) []byte {
+	ihdr := binary.BigEndian.AppendUint32([]byte("IHDR"), width)
+	ihdr = binary.BigEndian.AppendUint32(ihdr, height)
+	ihdr = append(ihdr, 8, 2, 0, 0, 0) // 8-bit RGB
+
+	data := []byte("\x89PNG\r\n\x1a\n")
+	data = binary.BigEndian.AppendUint32(data, uint32(len(ihdr)-4))
+	data = append(data, ihdr...)
+	return binary.BigEndian.AppendUint32(data, crc32.ChecksumIEEE(ihdr))
+}
+
+// derefAvatar returns the user's avatar URL, empty when there is none
+func derefAvatar(u *User) string {
+	if u.Avatar == nil {
+		return ""
+	}
+	return *u.Avatar
+}
+
+func TestSetAvatar(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeRepository()
+	id := repo.addUser("alice", false)
+	store := storage.NewMemoryStorage()
+	service := NewService(repo, nil, nil, store, nil, logger.NewZapLogger())
+
+	avatars := func() []string {
+		t.Helper()
+		keys, err := store.ListFiles(ctx, AvatarKeyPrefix(id))
+		if err != nil {
+			t.Fatalf("ListFiles: %v", err)
+		}
+		return keys
+	}
+
+	first, err := service.SetAvatar(ctx, id, bannerImage(t, 1280, 800), "image/png")
+	if err != nil {
+		t.Fatalf("SetAvatar: %v", err)
+	}
+	stored := avatars()
+	if len(stored) != 1 || derefAvatar(first) != "memory://"+stored[0] || repo.users[id].ProfilePicture != derefAvatar(first) {
+		t.Fatalf("avatars %v with profile picture %q, want one avatar it links to", stored, derefAvatar(first))
+	}
+
+	data, err := store.GetFile(ctx, stored[0])
+	if err != nil {
+		t.Fatalf("GetFile: %v", err)
+	}
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil || format != "jpeg" {
+		t.Fatalf("decoding the avatar: format %q, %v", format, err)
+	}
+	if got := img.Bounds().Size(); got != image.Pt(media.AvatarSize, media.AvatarSize) {
+		t.Errorf("avatar is %v, want %dx%d", got, media.AvatarSize, media.AvatarSize)
+	}
+	// Only the blue center survives the crop
+	if r, _, b, _ := img.At(0, 0).RGBA(); r > b {
+		t.Errorf("avatar corner is red, want the center of the image")
+	}
+
+	second, err := service.SetAvatar(ctx, id, bannerImage(t, 200, 200), "image/png")
+	if err != nil {
+		t.Fatalf("SetAvatar again: %v", err)
+	}
+	replaced := avatars()
+	if len(replaced) != 1 || replaced[0] == stored[0] || derefAvatar(second) != "memory://"+replaced[0] {
+		t.Errorf("avatars %v linked from %q after replacing %s, want only the new one", replaced, derefAvatar(second), stored[0])
+	}
+
+	rejected := []struct {
+		name        string
+		data        []byte
+		contentType string
+		wantErr     error
+	}{
+		{name: "unsupported type", data: []byte("%PDF-1.7"), contentType: "application/pdf", wantErr: media.ErrUnsupportedContentType},
+		{name: "not an image", data: []byte("not an image"), contentType: "image/png", wantErr: media.ErrInvalidImage},
+		{name: "oversized dimensions", data: oversizedPNG(50000, 50000), contentType: "image/png", wantErr: media.ErrImageTooLarge},
+	}
+	for _, tt := range rejected {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := service.SetAvatar(ctx, id, tt.data, tt.contentType); !errors.Is(err, tt.wantErr) {
+				t.Errorf("SetAvatar error = %v, want %v", err, tt.wantErr)
+			}
+			if got := avatars(); !slices.Equal(got, replaced) || repo.users[id].ProfilePicture != derefAvatar(second) {
+				t.Errorf("avatars %v linked from %q, want the previous avatar kept", got, repo.users[id].ProfilePicture)
+			}
+		})
+	}
+}
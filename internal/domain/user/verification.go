@@ -2,13 +2,18 @@ package user
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"time"
 
+	"fowergram-backend/internal/infra/database"
 	"fowergram-backend/pkg/auth"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -23,6 +28,10 @@ type VerificationRepository interface {
 	StorePasswordResetToken(ctx context.Context, userID uuid.UUID, token string, expiresAt time.Time) error
 	ValidatePasswordResetToken(ctx context.Context, token string) (*auth.User, error)
 	RevokePasswordResetToken(ctx context.Context, token string) error
+
+	// Email change
+	StoreEmailChangeToken(ctx context.Context, userID uuid.UUID, newEmail, token string, expiresAt time.Time) error
+	ConfirmEmailChange(ctx context.Context, token string) error
 }
 
 // postgresVerificationRepository implements verification operations
@@ -35,7 +44,15 @@ func NewPostgresVerificationRepository(db *pgxpool.Pool) auth.VerificationReposi
 	return &postgresVerificationRepository{db: db}
 }
 
-// StoreVerificationToken stores an email verification token
+// hashToken returns the form verification and reset tokens are stored in.
+// Only the emailed link carries the raw token, so a database leak exposes no
+// usable tokens.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// StoreVerificationToken stores the hash of an email verification token
 func (r *postgresVerificationRepository) StoreVerificationToken(ctx context.Context, userID uuid.UUID, token string, expiresAt time.Time) error {
 	query := `
 		INSERT INTO email_verifications (
@@ -45,7 +62,7 @@ func (r *postgresVerificationRepository) StoreVerificationToken(ctx context.Cont
 		)
 	`
 
-	_, err := r.db.Exec(ctx, query, userID, token, expiresAt, time.Now())
+	_, err := r.db.Exec(ctx, query, userID, hashToken(token), expiresAt, time.Now())
 	if err != nil {
 		return fmt.Errorf("failed to store verification token: %w", err)
 	}
@@ -66,7 +83,7 @@ func (r *postgresVerificationRepository) ValidateVerificationToken(ctx context.C
 	`
 
 	var user auth.User
-	err := r.db.QueryRow(ctx, query, token, time.Now()).Scan(
+	err := r.db.QueryRow(ctx, query, hashToken(token), time.Now()).Scan(
 		&user.ID,
 		&user.Email,
 		&user.Username,
@@ -96,58 +113,60 @@ func (r *postgresVerificationRepository) ValidateVerificationToken(ctx context.C
 
 // MarkEmailVerified marks a user's email as verified
 func (r *postgresVerificationRepository) MarkEmailVerified(ctx context.Context, userID uuid.UUID) error {
-	tx, err := r.db.Begin(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
-	}
-	defer tx.Rollback(ctx)
-
-	// Update user's verification status
-	updateUserQuery := `
-		UPDATE users SET
-			is_verified = true,
-			updated_at = $1
-		WHERE id = $2
-	`
-	_, err = tx.Exec(ctx, updateUserQuery, time.Now(), userID)
-	if err != nil {
-		return fmt.Errorf("failed to update user verification status: %w", err)
-	}
-
-	// Mark verification token as used
-	updateTokenQuery := `
-		UPDATE email_verifications SET
-			used_at = $1
-		WHERE user_id = $2 AND used_at IS NULL
-	`
-	_, err = tx.Exec(ctx, updateTokenQuery, time.Now(), userID)
-	if err != nil {
-		return fmt.Errorf("failed to mark verification token as used: %w", err)
-	}
+	return database.WithTx(ctx, r.db, func(tx database.DB) error {
+		// Update user's verification status
+		updateUserQuery := `
+			UPDATE users SET
+				is_verified = true,
+				updated_at = $1
+			WHERE id = $2
+		`
+		if _, err := tx.Exec(ctx, updateUserQuery, time.Now(), userID); err != nil {
+			return fmt.Errorf("failed to update user verification status: %w", err)
+		}
 
-	if err = tx.Commit(ctx); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
-	}
+		// Mark verification token as used
+		updateTokenQuery := `
+			UPDATE email_verifications SET
+				used_at = $1
+			WHERE user_id = $2 AND used_at IS NULL
+		`
+		if _, err := tx.Exec(ctx, updateTokenQuery, time.Now(), userID); err != nil {
+			return fmt.Errorf("failed to mark verification token as used: %w", err)
+		}
 
-	return nil
+		return nil
+	})
 }
 
-// StorePasswordResetToken stores a password reset token
+// StorePasswordResetToken stores the hash of a password reset token, revoking any unused
+// token the user was sent before so only the latest reset link works
 func (r *postgresVerificationRepository) StorePasswordResetToken(ctx context.Context, userID uuid.UUID, token string, expiresAt time.Time) error {
-	query := `
-		INSERT INTO password_resets (
-			user_id, token, expires_at, created_at
-		) VALUES (
-			$1, $2, $3, $4
-		)
-	`
+	return database.WithTx(ctx, r.db, func(tx database.DB) error {
+		now := time.Now()
 
-	_, err := r.db.Exec(ctx, query, userID, token, expiresAt, time.Now())
-	if err != nil {
-		return fmt.Errorf("failed to store password reset token: %w", err)
-	}
+		revokeQuery := `
+			UPDATE password_resets SET
+				used_at = $1
+			WHERE user_id = $2 AND used_at IS NULL
+		`
+		if _, err := tx.Exec(ctx, revokeQuery, now, userID); err != nil {
+			return fmt.Errorf("failed to revoke previous password reset tokens: %w", err)
+		}
 
-	return nil
+		query := `
+			INSERT INTO password_resets (
+				user_id, token, expires_at, created_at
+			) VALUES (
+				$1, $2, $3, $4
+			)
+		`
+		if _, err := tx.Exec(ctx, query, userID, hashToken(token), expiresAt, now); err != nil {
+			return fmt.Errorf("failed to store password reset token: %w", err)
+		}
+
+		return nil
+	})
 }
 
 // ValidatePasswordResetToken validates a password reset token
@@ -163,7 +182,7 @@ func (r *postgresVerificationRepository) ValidatePasswordResetToken(ctx context.
 	`
 
 	var user auth.User
-	err := r.db.QueryRow(ctx, query, token, time.Now()).Scan(
+	err := r.db.QueryRow(ctx, query, hashToken(token), time.Now()).Scan(
 		&user.ID,
 		&user.Email,
 		&user.Username,
@@ -199,10 +218,95 @@ func (r *postgresVerificationRepository) RevokePasswordResetToken(ctx context.Co
 		WHERE token = $2 AND used_at IS NULL
 	`
 
-	_, err := r.db.Exec(ctx, query, time.Now(), token)
+	_, err := r.db.Exec(ctx, query, time.Now(), hashToken(token))
 	if err != nil {
 		return fmt.Errorf("failed to revoke password reset token: %w", err)
 	}
 
 	return nil
 }
+
+// uniqueViolation is the PostgreSQL error code for a unique constraint violation
+const uniqueViolation = "23505"
+
+// StoreEmailChangeToken stores the hash of an email change token along with
+// the new address, revoking any change the user requested before
+func (r *postgresVerificationRepository) StoreEmailChangeToken(ctx context.Context, userID uuid.UUID, newEmail, token string, expiresAt time.Time) error {
+	return database.WithTx(ctx, r.db, func(tx database.DB) error {
+		now := time.Now()
+
+		revokeQuery := `
+			UPDATE email_changes SET
+				used_at = $1
+			WHERE user_id = $2 AND used_at IS NULL
+		`
+		if _, err := tx.Exec(ctx, revokeQuery, now, userID); err != nil {
+			return fmt.Errorf("failed to revoke previous email change tokens: %w", err)
+		}
+
+		query := `
+			INSERT INTO email_changes (
+				user_id, new_email, token, expires_at, created_at
+			) VALUES (
+				$1, $2, $3, $4, $5
+			)
+		`
+		if _, err := tx.Exec(ctx, query, userID, newEmail, hashToken(token), expiresAt, now); err != nil {
+			return fmt.Errorf("failed to store email change token: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// ConfirmEmailChange switches the user to the new email of a valid change
+// token and marks the token used. The new address counts as verified, since
+// following the link proves the user receives mail there.
+func (r *postgresVerificationRepository) ConfirmEmailChange(ctx context.Context, token string) error {
+	return database.WithTx(ctx, r.db, func(tx database.DB) error {
+		now := time.Now()
+
+		selectQuery := `
+			SELECT id, user_id, new_email FROM email_changes
+			WHERE token = $1 AND expires_at > $2 AND used_at IS NULL
+			FOR UPDATE
+		`
+		var (
+			id       uuid.UUID
+			userID   uuid.UUID
+			newEmail string
+		)
+		if err := tx.QueryRow(ctx, selectQuery, hashToken(token), now).Scan(&id, &userID, &newEmail); err != nil {
+			if err == pgx.ErrNoRows {
+				return auth.ErrInvalidToken
+			}
+			return fmt.Errorf("failed to validate email change token: %w", err)
+		}
+
+		updateUserQuery := `
+			UPDATE users SET
+				email = $1,
+				is_verified = true,
+				updated_at = $2
+			WHERE id = $3
+		`
+		if _, err := tx.Exec(ctx, updateUserQuery, newEmail, now, userID); err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+				return auth.ErrEmailTaken
+			}
+			return fmt.Errorf("failed to update email: %w", err)
+		}
+
+		updateTokenQuery := `
+			UPDATE email_changes SET
+				used_at = $1
+			WHERE id = $2
+		`
+		if _, err := tx.Exec(ctx, updateTokenQuery, now, id); err != nil {
+			return fmt.Errorf("failed to mark email change token as used: %w", err)
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,209 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"fowergram-backend/internal/infra/database/dbtest"
+	"fowergram-backend/pkg/auth"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// addUser adds a user with the columns the token queries read filled in
+func addUser(t *testing.T, db *pgxpool.Pool, username string) uuid.UUID {
+	t.Helper()
+	id := uuid.New()
+	_, err := db.Exec(context.Background(), `
+		INSERT INTO users (id, email, username, full_name, bio, profile_picture)
+		VALUES ($1, $2, $3, '', '', '')
+	`, id, username+"@example.com", username)
+	if err != nil {
+		t.Fatalf("adding %s: %v", username, err)
+	}
+	return id
+}
+
+func TestHashToken(t *testing.T) {
+	// SHA-256 of "abc", from FIPS 180-2
+	const want = "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+	if got := hashToken("abc"); got != want {
+		t.Errorf("hashToken(\"abc\") = %s, want %s", got, want)
+	}
+}
+
+func TestTokensStoredHashed(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.MigratedPool(t)
+	repo := NewPostgresVerificationRepository(db)
+	userID := addUser(t, db, "alice")
+	expiresAt := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name     string
+		table    string
+		store    func(token string) error
+		validate func(token string) error
+	}{
+		{
+			name:  "email verification",
+			table: "email_verifications",
+			store: func(token string) error { return repo.StoreVerificationToken(ctx, userID, token, expiresAt) },
+			validate: func(token string) error {
+				_, err := repo.ValidateVerificationToken(ctx, token)
+				return err
+			},
+		},
+		{
+			name:  "password reset",
+			table: "password_resets",
+			store: func(token string) error { return repo.StorePasswordResetToken(ctx, userID, token, expiresAt) },
+			validate: func(token string) error {
+				_, err := repo.ValidatePasswordResetToken(ctx, token)
+				return err
+			},
+		},
+		{
+			name:  "email change",
+			table: "email_changes",
+			store: func(token string) error {
+				return repo.StoreEmailChangeToken(ctx, userID, "new@example.com", token, expiresAt)
+			},
+			validate: func(token string) error { return repo.ConfirmEmailChange(ctx, token) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := tt.table + "-token"
+			if err := tt.store(token); err != nil {
+				t.Fatalf("storing: %v", err)
+			}
+
+			var stored string
+			if err := db.QueryRow(ctx, "SELECT token FROM "+tt.table+" WHERE user_id = $1", userID).Scan(&stored); err != nil {
+				t.Fatalf("reading the stored token: %v", err)
+			}
+			if stored != hashToken(token) {
+				t.Errorf("stored %q, want the token's hash", stored)
+			}
+
+			// A leaked hash isn't accepted in place of the token
+			if err := tt.validate(stored); !errors.Is(err, auth.ErrInvalidToken) {
+				t.Errorf("validating the stored hash: %v, want ErrInvalidToken", err)
+			}
+			if err := tt.validate(token); err != nil {
+				t.Errorf("validating the token: %v", err)
+			}
+		})
+	}
+}
+
+func TestPasswordResetTokens(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.MigratedPool(t)
+	repo := NewPostgresVerificationRepository(db)
+
+	alice, bob := addUser(t, db, "alice"), addUser(t, db, "bob")
+
+	expiresAt := time.Now().Add(time.Hour)
+	for _, reset := range []struct {
+		userID uuid.UUID
+		token  string
+	}{
+		{alice, "alice-first"},
+		{bob, "bob-only"},
+		{alice, "alice-second"},
+	} {
+		if err := repo.StorePasswordResetToken(ctx, reset.userID, reset.token, expiresAt); err != nil {
+			t.Fatalf("storing %s: %v", reset.token, err)
+		}
+	}
+
+	tests := []struct {
+		token    string
+		wantUser uuid.UUID // uuid.Nil when the token must be rejected
+	}{
+		{token: "alice-first"},
+		{token: "alice-second", wantUser: alice},
+		{token: "bob-only", wantUser: bob},
+		{token: "never-sent"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.token, func(t *testing.T) {
+			user, err := repo.ValidatePasswordResetToken(ctx, tt.token)
+			if tt.wantUser == uuid.Nil {
+				if !errors.Is(err, auth.ErrInvalidToken) {
+					t.Errorf("ValidatePasswordResetToken() = %v, %v, want ErrInvalidToken", user, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ValidatePasswordResetToken: %v", err)
+			}
+			if user.ID != tt.wantUser {
+				t.Errorf("token belongs to %s, want %s", user.ID, tt.wantUser)
+			}
+		})
+	}
+}
+
+func TestConfirmEmailChange(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.MigratedPool(t)
+	repo := NewPostgresVerificationRepository(db)
+	alice, bob := addUser(t, db, "alice"), addUser(t, db, "bob")
+	expiresAt := time.Now().Add(time.Hour)
+
+	email := func(userID uuid.UUID) (string, bool) {
+		t.Helper()
+		var address string
+		var verified bool
+		if err := db.QueryRow(ctx, "SELECT email, is_verified FROM users WHERE id = $1", userID).Scan(&address, &verified); err != nil {
+			t.Fatalf("reading the email: %v", err)
+		}
+		return address, verified
+	}
+
+	// Requesting a second change invalidates the first link
+	if err := repo.StoreEmailChangeToken(ctx, alice, "alice@example.org", "first", expiresAt); err != nil {
+		t.Fatalf("StoreEmailChangeToken: %v", err)
+	}
+	if err := repo.StoreEmailChangeToken(ctx, alice, "alice@example.net", "second", expiresAt); err != nil {
+		t.Fatalf("StoreEmailChangeToken: %v", err)
+	}
+	if err := repo.ConfirmEmailChange(ctx, "first"); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Errorf("confirming the replaced link = %v, want ErrInvalidToken", err)
+	}
+	if got, _ := email(alice); got != "alice@example.com" {
+		t.Errorf("email = %s before confirming, want the old one", got)
+	}
+
+	if err := repo.ConfirmEmailChange(ctx, "second"); err != nil {
+		t.Fatalf("ConfirmEmailChange: %v", err)
+	}
+	if got, verified := email(alice); got != "alice@example.net" || !verified {
+		t.Errorf("email = %s, verified %v; want the confirmed address, verified", got, verified)
+	}
+	if err := repo.ConfirmEmailChange(ctx, "second"); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Errorf("confirming twice = %v, want ErrInvalidToken", err)
+	}
+
+	// An address taken between the request and the confirmation is refused
+	if err := repo.StoreEmailChangeToken(ctx, bob, "shared@example.com", "bobs", expiresAt); err != nil {
+		t.Fatalf("StoreEmailChangeToken: %v", err)
+	}
+	if _, err := db.Exec(ctx, "UPDATE users SET email = 'shared@example.com' WHERE id = $1", alice); err != nil {
+		t.Fatalf("taking the address: %v", err)
+	}
+	if err := repo.ConfirmEmailChange(ctx, "bobs"); !errors.Is(err, auth.ErrEmailTaken) {
+		t.Errorf("confirming a taken address = %v, want ErrEmailTaken", err)
+	}
+	if got, _ := email(bob); got != "bob@example.com" {
+		t.Errorf("bob's email = %s, want it unchanged", got)
+	}
+}
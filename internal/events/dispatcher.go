@@ -0,0 +1,99 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"fowergram-backend/internal/infra/messaging"
+	"fowergram-backend/pkg/logger"
+)
+
+// Handler processes a single event. Handlers decode the payload they expect
+// with Envelope.DecodePayload.
+type Handler func(ctx context.Context, e *Envelope) error
+
+// Dispatcher routes incoming events to the handler registered for their type
+type Dispatcher struct {
+	handlers map[Type]Handler
+	timeout  time.Duration
+	logger   logger.Logger
+}
+
+// NewDispatcher creates a dispatcher whose handlers run with the given timeout
+func NewDispatcher(timeout time.Duration, logger logger.Logger) *Dispatcher {
+	return &Dispatcher{
+		handlers: make(map[Type]Handler),
+		timeout:  timeout,
+		logger:   logger,
+	}
+}
+
+// Handle registers handler for events of type t, replacing any previous one
+func (d *Dispatcher) Handle(t Type, handler Handler) {
+	d.handlers[t] = handler
+}
+
+// Dispatch decodes an event and runs its handler. The handler's context is
+// derived from ctx, bounded by the dispatcher's timeout, and traced as part
+// of the publisher's trace.
+func (d *Dispatcher) Dispatch(ctx context.Context, data []byte) (err error) {
+	envelope, err := Decode(data)
+	if err != nil {
+		return err
+	}
+
+	defer func() { recordEvent(eventsConsumed, envelope.Type, err) }()
+
+	handler, ok := d.handlers[envelope.Type]
+	if !ok {
+		return fmt.Errorf("no handler for %s events", envelope.Type)
+	}
+
+	ctx, span := startProcessSpan(ctx, envelope)
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, d.timeout)
+	defer cancel()
+
+	return handler(ctx, envelope)
+}
+
+// Subscribe consumes the subject of every registered type through durable
+// subscriptions named after queue, so each event is handled by one
+// subscriber sharing the name. Failures are logged, with events that can't
+// be decoded reported apart from handler errors; only handler errors are
+// worth redelivering. The subscriptions are returned for the caller to drain,
+// and also end when the client is drained or closed.
+func (d *Dispatcher) Subscribe(client messaging.Client, queue string) ([]*messaging.Subscription, error) {
+	subs := make([]*messaging.Subscription, 0, len(d.handlers))
+	for t := range d.handlers {
+		subject := string(t)
+		handle := func(ctx context.Context, data []byte) error {
+			err := d.Dispatch(ctx, data)
+			if err == nil {
+				return nil
+			}
+
+			if isDecodeError(err) {
+				d.logger.Error("Failed to decode event", "subject", subject, "error", err)
+				return fmt.Errorf("%w: %w", messaging.ErrPoisonMessage, err)
+			}
+			d.logger.Error("Failed to handle event", "subject", subject, "error", err)
+			return err
+		}
+
+		sub, err := client.SubscribeDurable(queue, subject, handle)
+		if err != nil {
+			return subs, fmt.Errorf("failed to subscribe to %s: %w", subject, err)
+		}
+		subs = append(subs, sub)
+	}
+
+	return subs, nil
+}
+
+func isDecodeError(err error) bool {
+	return errors.Is(err, ErrMalformedEvent) || errors.Is(err, ErrTypeMismatch) || errors.Is(err, ErrUnsupportedVersion)
+}
@@ -0,0 +1,99 @@
+// Package events defines the domain events services publish over NATS: their
+// versioned payloads, the envelope they travel in, and helpers for
+// publishing and dispatching them.
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Type identifies an event. It is also the NATS subject the event is published on.
+type Type string
+
+// Common event errors
+var (
+	ErrMalformedEvent     = errors.New("malformed event")
+	ErrTypeMismatch       = errors.New("event type does not match payload")
+	ErrUnsupportedVersion = errors.New("unsupported event version")
+)
+
+// Payload is the body of an event. Each payload type reports the event type
+// it belongs to and the schema version it encodes; bump the version when a
+// field changes meaning or is removed.
+type Payload interface {
+	EventType() Type
+	EventVersion() int
+}
+
+// Envelope wraps a payload with the metadata every event carries
+type Envelope struct {
+	ID         uuid.UUID       `json:"id"`
+	Type       Type            `json:"type"`
+	Version    int             `json:"version"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	ActorID    uuid.UUID       `json:"actor_id"` // User whose action produced the event
+	Payload    json.RawMessage `json:"payload"`
+
+	// Trace is the trace context of the publisher, so handling the event is
+	// traced as part of the request that produced it
+	Trace map[string]string `json:"trace,omitempty"`
+}
+
+// NewEnvelope wraps payload in a new envelope
+func NewEnvelope(actorID uuid.UUID, payload Payload) (*Envelope, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s payload: %w", payload.EventType(), err)
+	}
+
+	return &Envelope{
+		ID:         uuid.New(),
+		Type:       payload.EventType(),
+		Version:    payload.EventVersion(),
+		OccurredAt: time.Now(),
+		ActorID:    actorID,
+		Payload:    data,
+	}, nil
+}
+
+// Encode returns the wire form of the envelope
+func (e *Envelope) Encode() ([]byte, error) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s event: %w", e.Type, err)
+	}
+	return data, nil
+}
+
+// Decode parses an envelope from its wire form. The payload is left encoded
+// until DecodePayload is called.
+func Decode(data []byte) (*Envelope, error) {
+	var e Envelope
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedEvent, err)
+	}
+	if e.Type == "" || len(e.Payload) == 0 {
+		return nil, fmt.Errorf("%w: missing type or payload", ErrMalformedEvent)
+	}
+	return &e, nil
+}
+
+// DecodePayload decodes the payload into p, which must be a pointer to the
+// payload type matching the envelope's type and at least its version
+func (e *Envelope) DecodePayload(p Payload) error {
+	if p.EventType() != e.Type {
+		return fmt.Errorf("%w: got %s, want %s", ErrTypeMismatch, e.Type, p.EventType())
+	}
+	if e.Version > p.EventVersion() {
+		return fmt.Errorf("%w: %s v%d", ErrUnsupportedVersion, e.Type, e.Version)
+	}
+	if err := json.Unmarshal(e.Payload, p); err != nil {
+		return fmt.Errorf("%w: %s payload: %v", ErrMalformedEvent, e.Type, err)
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"fowergram-backend/internal/infra/messaging"
+	"fowergram-backend/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+func TestEnvelopeRoundTrip(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	commentID := uuid.New()
+
+	payloads := []Payload{
+		PostCreated{PostID: uuid.New(), AuthorID: uuid.New(), IsPrivate: true, CreatedAt: at},
+		PostLiked{PostID: uuid.New(), AuthorID: uuid.New(), UserID: uuid.New(), LikedAt: at},
+		PostCommented{CommentID: commentID, PostID: uuid.New(), AuthorID: uuid.New(), UserID: uuid.New(), ParentID: &commentID, CreatedAt: at},
+		UserFollowed{Follow: Follow{FollowerID: uuid.New(), FollowingID: uuid.New(), CreatedAt: at}},
+		UserMentioned{UserID: uuid.New(), PostID: uuid.New(), CommentID: &commentID, CreatedAt: at},
+		PushSend{UserID: uuid.New(), Devices: []PushDevice{{Token: "t", Platform: "ios"}}, Title: "Hi", Data: map[string]string{"type": "like"}},
+	}
+
+	for _, payload := range payloads {
+		t.Run(string(payload.EventType()), func(t *testing.T) {
+			actorID := uuid.New()
+			envelope, err := NewEnvelope(actorID, payload)
+			if err != nil {
+				t.Fatalf("NewEnvelope: %v", err)
+			}
+			data, err := envelope.Encode()
+			if err != nil {
+				t.Fatalf("Encode: %v", err)
+			}
+
+			decoded, err := Decode(data)
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if decoded.ID != envelope.ID || decoded.Type != payload.EventType() || decoded.Version != payload.EventVersion() ||
+				decoded.ActorID != actorID || !decoded.OccurredAt.Equal(envelope.OccurredAt) {
+				t.Errorf("decoded envelope %+v, want %+v", decoded, envelope)
+			}
+
+			got := reflect.New(reflect.TypeOf(payload))
+			if err := decoded.DecodePayload(got.Interface().(Payload)); err != nil {
+				t.Fatalf("DecodePayload: %v", err)
+			}
+			if !reflect.DeepEqual(got.Elem().Interface(), payload) {
+				t.Errorf("payload = %+v, want %+v", got.Elem().Interface(), payload)
+			}
+		})
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	liked, err := NewEnvelope(uuid.New(), PostLiked{PostID: uuid.New()})
+	if err != nil {
+		t.Fatalf("NewEnvelope: %v", err)
+	}
+	newer := *liked
+	newer.Version = 2
+	garbled := *liked
+	garbled.Payload = []byte(`{"post_id": 42}`)
+
+	tests := []struct {
+		name     string
+		data     string // Decoded when set, otherwise envelope's payload is
+		envelope *Envelope
+		into     Payload
+		want     error
+	}{
+		{name: "not JSON", data: "post.liked", want: ErrMalformedEvent},
+		{name: "no type", data: `{"payload": {}}`, want: ErrMalformedEvent},
+		{name: "no payload", data: `{"type": "post.liked"}`, want: ErrMalformedEvent},
+		{name: "other type", envelope: liked, into: &PostCreated{}, want: ErrTypeMismatch},
+		{name: "newer version", envelope: &newer, into: &PostLiked{}, want: ErrUnsupportedVersion},
+		{name: "payload of the wrong shape", envelope: &garbled, into: &PostLiked{}, want: ErrMalformedEvent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var err error
+			if tt.data != "" {
+				_, err = Decode([]byte(tt.data))
+			} else {
+				err = tt.envelope.DecodePayload(tt.into)
+			}
+			if !errors.Is(err, tt.want) {
+				t.Errorf("error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// handlerClient records the handler subscribed to each subject so a test
+// can see the error it returns. Other methods are left to the embedded nil
+// Client.
+type handlerClient struct {
+	messaging.Client
+
+	handlers map[string]func(ctx context.Context, msg []byte) error
+}
+
+func (c *handlerClient) SubscribeDurable(queue, subject string, handler func(ctx context.Context, msg []byte) error) (*messaging.Subscription, error) {
+	c.handlers[subject] = handler
+	return &messaging.Subscription{}, nil
+}
+
+func TestDispatcher(t *testing.T) {
+	var handled []Type
+	handlerErr := errors.New("handler failed")
+	dispatcher := NewDispatcher(time.Second, logger.NewZapLogger())
+	dispatcher.Handle(TypePostCreated, func(ctx context.Context, e *Envelope) error {
+		var event PostCreated
+		handled = append(handled, e.Type)
+		return e.DecodePayload(&event)
+	})
+	dispatcher.Handle(TypePostLiked, func(ctx context.Context, e *Envelope) error {
+		handled = append(handled, e.Type)
+		return handlerErr
+	})
+
+	client := &handlerClient{handlers: make(map[string]func(ctx context.Context, msg []byte) error)}
+	if _, err := dispatcher.Subscribe(client, "dispatcher-test"); err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	encode := func(payload Payload) []byte {
+		t.Helper()
+		envelope, err := NewEnvelope(uuid.New(), payload)
+		if err != nil {
+			t.Fatalf("NewEnvelope: %v", err)
+		}
+		data, err := envelope.Encode()
+		if err != nil {
+			t.Fatalf("Encode: %v", err)
+		}
+		return data
+	}
+
+	created, err := NewEnvelope(uuid.New(), PostCreated{PostID: uuid.New()})
+	if err != nil {
+		t.Fatalf("NewEnvelope: %v", err)
+	}
+	created.Payload = []byte(`{"post_id": 42}`)
+	garbled, err := created.Encode()
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		subject    Type
+		data       []byte
+		wantErr    error
+		wantPoison bool // Whether the error tells the client not to redeliver
+	}{
+		{name: "handled", subject: TypePostCreated, data: encode(PostCreated{PostID: uuid.New()})},
+		{name: "handler error is retried", subject: TypePostLiked, data: encode(PostLiked{PostID: uuid.New()}), wantErr: handlerErr},
+		{name: "undecodable event is poison", subject: TypePostCreated, data: []byte("{"), wantErr: ErrMalformedEvent, wantPoison: true},
+		{name: "routed by the envelope's type", subject: TypePostCreated, data: encode(PostLiked{PostID: uuid.New()}), wantErr: handlerErr},
+		{name: "payload a handler can't decode is poison", subject: TypePostCreated, data: garbled, wantErr: ErrMalformedEvent, wantPoison: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := client.handlers[string(tt.subject)](context.Background(), tt.data)
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && err != nil {
+				t.Errorf("error = %v, want none", err)
+			}
+			if errors.Is(err, messaging.ErrPoisonMessage) != tt.wantPoison {
+				t.Errorf("error %v is poison = %v, want %v", err, !tt.wantPoison, tt.wantPoison)
+			}
+		})
+	}
+
+	if want := []Type{TypePostCreated, TypePostLiked, TypePostLiked, TypePostCreated}; !reflect.DeepEqual(handled, want) {
+		t.Errorf("handled %v, want %v", handled, want)
+	}
+}
@@ -0,0 +1,35 @@
+package events
+
+import (
+	"fowergram-backend/pkg/telemetry"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Results of publishing and handling events, labelling their counters
+const (
+	resultOK    = "ok"
+	resultError = "error"
+)
+
+var (
+	eventsPublished = promauto.With(telemetry.Registry).NewCounterVec(prometheus.CounterOpts{
+		Name: "events_published_total",
+		Help: "Events published to NATS, by type and result (ok or error).",
+	}, []string{"type", "result"})
+
+	eventsConsumed = promauto.With(telemetry.Registry).NewCounterVec(prometheus.CounterOpts{
+		Name: "events_consumed_total",
+		Help: "Events received from NATS and handled, by type and result (ok or error).",
+	}, []string{"type", "result"})
+)
+
+// recordEvent counts an event of type t on counter, by the error it ended with
+func recordEvent(counter *prometheus.CounterVec, t Type, err error) {
+	result := resultOK
+	if err != nil {
+		result = resultError
+	}
+	counter.WithLabelValues(string(t), result).Inc()
+}
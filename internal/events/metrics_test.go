@@ -0,0 +1,86 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"fowergram-backend/internal/infra/messaging"
+	"fowergram-backend/pkg/logger"
+	"fowergram-backend/pkg/telemetry"
+
+	"github.com/google/uuid"
+)
+
+func TestEventMetrics(t *testing.T) {
+	log := logger.NewZapLogger()
+	client := messaging.NewRecordingClient()
+
+	dispatcher := NewDispatcher(time.Second, log)
+	dispatcher.Handle(TypePostCreated, func(ctx context.Context, e *Envelope) error { return nil })
+	dispatcher.Handle(TypePostLiked, func(ctx context.Context, e *Envelope) error { return errors.New("handler failed") })
+	if _, err := dispatcher.Subscribe(client, "metrics-test"); err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	publisher := NewNATSPublisher(client, log)
+	for _, payload := range []Payload{PostCreated{PostID: uuid.New()}, PostLiked{PostID: uuid.New()}} {
+		if err := publisher.Publish(context.Background(), uuid.New(), payload); err != nil {
+			t.Fatalf("Publish %s: %v", payload.EventType(), err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		metric string
+		labels map[string]string
+	}{
+		{name: "published", metric: "events_published_total", labels: map[string]string{"type": "post.created", "result": "ok"}},
+		{name: "handled", metric: "events_consumed_total", labels: map[string]string{"type": "post.created", "result": "ok"}},
+		{name: "handler failed", metric: "events_consumed_total", labels: map[string]string{"type": "post.liked", "result": "error"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !hasSeries(t, tt.metric, tt.labels) {
+				t.Errorf("telemetry.Registry has no %s series labelled %v", tt.metric, tt.labels)
+			}
+		})
+	}
+}
+
+// hasSeries reports whether telemetry.Registry holds a series of metric
+// with exactly labels
+func hasSeries(t *testing.T, metric string, labels map[string]string) bool {
+	t.Helper()
+	families, err := telemetry.Registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+
+	for _, family := range families {
+		if family.GetName() != metric {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			got := make(map[string]string, len(m.GetLabel()))
+			for _, pair := range m.GetLabel() {
+				got[pair.GetName()] = pair.GetValue()
+			}
+			if len(got) != len(labels) {
+				continue
+			}
+			match := true
+			for name, value := range labels {
+				if got[name] != value {
+					match = false
+				}
+			}
+			if match {
+				return true
+			}
+		}
+	}
+	return false
+}
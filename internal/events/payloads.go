@@ -0,0 +1,161 @@
+package events
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Event types
+const (
+	TypePostCreated           Type = "post.created"
+	TypePostLiked             Type = "post.liked"
+	TypePostCommented         Type = "post.commented"
+	TypeUserFollowed          Type = "user.followed"
+	TypeFollowRequested       Type = "user.follow_requested"
+	TypeFollowRequestApproved Type = "user.follow_request_approved"
+	TypeUserMentioned         Type = "user.mentioned"
+	TypeUserDeleted           Type = "user.deleted"
+	TypeNotificationCreated   Type = "notification.created"
+	TypeDirectMessageCreated  Type = "dm.message_created"
+	TypePushSend              Type = "push.send"
+)
+
+// PostCreated is published when a post is published, either directly or
+// once its draft or schedule is published
+type PostCreated struct {
+	PostID    uuid.UUID `json:"post_id"`
+	AuthorID  uuid.UUID `json:"author_id"`
+	IsPrivate bool      `json:"is_private"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (PostCreated) EventType() Type   { return TypePostCreated }
+func (PostCreated) EventVersion() int { return 1 }
+
+// PostLiked is published when a user likes a post
+type PostLiked struct {
+	PostID   uuid.UUID `json:"post_id"`
+	AuthorID uuid.UUID `json:"author_id"`
+	UserID   uuid.UUID `json:"user_id"`
+	LikedAt  time.Time `json:"liked_at"`
+}
+
+func (PostLiked) EventType() Type   { return TypePostLiked }
+func (PostLiked) EventVersion() int { return 1 }
+
+// PostCommented is published when a user comments on a post
+type PostCommented struct {
+	CommentID uuid.UUID  `json:"comment_id"`
+	PostID    uuid.UUID  `json:"post_id"`
+	AuthorID  uuid.UUID  `json:"author_id"`
+	UserID    uuid.UUID  `json:"user_id"`
+	ParentID  *uuid.UUID `json:"parent_id,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (PostCommented) EventType() Type   { return TypePostCommented }
+func (PostCommented) EventVersion() int { return 1 }
+
+// Follow is the relationship carried by the follow events. For requests and
+// approvals, FollowerID is the requester and FollowingID the private account.
+type Follow struct {
+	FollowerID  uuid.UUID `json:"follower_id"`
+	FollowingID uuid.UUID `json:"following_id"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// UserFollowed is published when a follow takes effect immediately
+type UserFollowed struct {
+	Follow
+}
+
+func (UserFollowed) EventType() Type   { return TypeUserFollowed }
+func (UserFollowed) EventVersion() int { return 1 }
+
+// FollowRequested is published when a user asks to follow a private account
+type FollowRequested struct {
+	Follow
+}
+
+func (FollowRequested) EventType() Type   { return TypeFollowRequested }
+func (FollowRequested) EventVersion() int { return 1 }
+
+// FollowRequestApproved is published when a private account approves a
+// request; FollowerID is the requester
+type FollowRequestApproved struct {
+	Follow
+}
+
+func (FollowRequestApproved) EventType() Type   { return TypeFollowRequestApproved }
+func (FollowRequestApproved) EventVersion() int { return 1 }
+
+// UserMentioned is published when a user is @-mentioned in a post or comment.
+// CommentID is set for mentions in comments.
+type UserMentioned struct {
+	UserID    uuid.UUID  `json:"user_id"`
+	PostID    uuid.UUID  `json:"post_id"`
+	CommentID *uuid.UUID `json:"comment_id,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (UserMentioned) EventType() Type   { return TypeUserMentioned }
+func (UserMentioned) EventVersion() int { return 1 }
+
+// UserDeleted is published after an account has been permanently deleted
+type UserDeleted struct {
+	UserID    uuid.UUID `json:"user_id"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+func (UserDeleted) EventType() Type   { return TypeUserDeleted }
+func (UserDeleted) EventVersion() int { return 1 }
+
+// NotificationCreated is published after a notification has been stored for
+// a user, so connected clients can be told about it
+type NotificationCreated struct {
+	NotificationID uuid.UUID  `json:"notification_id"`
+	UserID         uuid.UUID  `json:"user_id"` // Recipient
+	ActorID        *uuid.UUID `json:"actor_id,omitempty"`
+	Type           string     `json:"type"`
+	EntityType     string     `json:"entity_type,omitempty"`
+	EntityID       *uuid.UUID `json:"entity_id,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+func (NotificationCreated) EventType() Type   { return TypeNotificationCreated }
+func (NotificationCreated) EventVersion() int { return 1 }
+
+// DirectMessageCreated is published after a direct message has been stored,
+// so the participants' connected clients can be sent it
+type DirectMessageCreated struct {
+	MessageID      uuid.UUID `json:"message_id"`
+	ConversationID uuid.UUID `json:"conversation_id"`
+	SenderID       uuid.UUID `json:"sender_id"`
+	RecipientID    uuid.UUID `json:"recipient_id"`
+	Body           string    `json:"body"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+func (DirectMessageCreated) EventType() Type   { return TypeDirectMessageCreated }
+func (DirectMessageCreated) EventVersion() int { return 1 }
+
+// PushSend asks the push worker to deliver a rendered notification to the
+// listed devices of a user
+type PushSend struct {
+	UserID         uuid.UUID         `json:"user_id"`
+	NotificationID uuid.UUID         `json:"notification_id"`
+	Devices        []PushDevice      `json:"devices"`
+	Title          string            `json:"title"`
+	Body           string            `json:"body"`
+	Data           map[string]string `json:"data,omitempty"`
+}
+
+// PushDevice is a device token a push is sent to
+type PushDevice struct {
+	Token    string `json:"token"`
+	Platform string `json:"platform"`
+}
+
+func (PushSend) EventType() Type   { return TypePushSend }
+func (PushSend) EventVersion() int { return 1 }
@@ -0,0 +1,63 @@
+package events
+
+import (
+	"context"
+	"fmt"
+
+	"fowergram-backend/internal/infra/messaging"
+	"fowergram-backend/pkg/logger"
+	"fowergram-backend/pkg/telemetry"
+
+	"github.com/google/uuid"
+)
+
+// Publisher publishes domain events
+type Publisher interface {
+	Publish(ctx context.Context, actorID uuid.UUID, payload Payload) error
+}
+
+// NATSPublisher publishes events on the NATS subject named by their type
+type NATSPublisher struct {
+	client messaging.Client
+	logger logger.Logger
+}
+
+// NewNATSPublisher creates a new NATS event publisher
+func NewNATSPublisher(client messaging.Client, logger logger.Logger) *NATSPublisher {
+	return &NATSPublisher{
+		client: client,
+		logger: logger,
+	}
+}
+
+// Publish wraps payload in an envelope and publishes it without waiting for
+// JetStream to store it. Failed acknowledgements are logged.
+func (p *NATSPublisher) Publish(ctx context.Context, actorID uuid.UUID, payload Payload) (err error) {
+	envelope, err := NewEnvelope(actorID, payload)
+	if err != nil {
+		return err
+	}
+
+	ctx, span := startPublishSpan(ctx, envelope)
+	defer func() {
+		endSpan(span, err)
+		recordEvent(eventsPublished, envelope.Type, err)
+	}()
+	envelope.Trace = telemetry.InjectTrace(ctx)
+
+	data, err := envelope.Encode()
+	if err != nil {
+		return err
+	}
+
+	err = p.client.PublishAsync(string(envelope.Type), data, func(err error) {
+		if err != nil {
+			p.logger.Error("Event was not acknowledged", "type", envelope.Type, "event_id", envelope.ID, "error", err)
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish %s event: %w", envelope.Type, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package events
+
+import (
+	"context"
+
+	"fowergram-backend/pkg/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// messagingSystemNATS is the messaging.system of event spans
+var messagingSystemNATS = semconv.MessagingSystemKey.String("nats")
+
+// startPublishSpan starts the producer span of publishing an event
+func startPublishSpan(ctx context.Context, e *Envelope) (context.Context, trace.Span) {
+	return telemetry.StartSpan(ctx, "publish "+string(e.Type),
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(eventAttributes(e, semconv.MessagingOperationTypeSend)...),
+	)
+}
+
+// startProcessSpan starts the consumer span of handling an event, in the
+// trace of the request that published it
+func startProcessSpan(ctx context.Context, e *Envelope) (context.Context, trace.Span) {
+	return telemetry.StartSpan(telemetry.ExtractTrace(ctx, e.Trace), "process "+string(e.Type),
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(eventAttributes(e, semconv.MessagingOperationTypeProcess)...),
+	)
+}
+
+func eventAttributes(e *Envelope, operation attribute.KeyValue) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		messagingSystemNATS,
+		operation,
+		semconv.MessagingDestinationName(string(e.Type)),
+		semconv.MessagingMessageID(e.ID.String()),
+	}
+}
+
+// endSpan records err on span and ends it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
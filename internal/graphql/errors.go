@@ -0,0 +1,84 @@
+package graphql
+
+import (
+	"errors"
+
+	"fowergram-backend/pkg/auth"
+
+	"github.com/google/uuid"
+)
+
+// Error codes set in GraphQLError.Extensions["code"], so clients can tell
+// failures apart without parsing messages
+const (
+	CodeUnauthenticated       = "UNAUTHENTICATED"
+	CodeNotFound              = "NOT_FOUND"
+	CodeValidation            = "VALIDATION"
+	CodeInternal              = "INTERNAL"
+	CodeIntrospectionDisabled = "INTROSPECTION_DISABLED"
+)
+
+// internalErrorMessage is all clients learn about unexpected failures; the
+// correlation ID finds the logged cause
+const internalErrorMessage = "Internal server error"
+
+// authErrorCodes maps auth.AuthError codes to error codes; unlisted codes
+// are VALIDATION
+var authErrorCodes = map[string]string{
+	auth.ErrInvalidCredentials.Code: CodeUnauthenticated,
+	auth.ErrUnauthorized.Code:       CodeUnauthenticated,
+	auth.ErrSessionExpired.Code:     CodeUnauthenticated,
+	auth.ErrTokenRevoked.Code:       CodeUnauthenticated,
+	auth.ErrInvalidToken.Code:       CodeUnauthenticated,
+	auth.ErrEmailNotVerified.Code:   CodeUnauthenticated,
+	auth.ErrAccountDeactivated.Code: CodeUnauthenticated,
+	auth.ErrUserNotFound.Code:       CodeNotFound,
+	auth.ErrSessionNotFound.Code:    CodeNotFound,
+}
+
+// errorResponse returns a response carrying a single error on field. An
+// empty field leaves the error without a path.
+func errorResponse(field, code, message string) GraphQLResponse {
+	gqlErr := GraphQLError{
+		Message:    message,
+		Extensions: map[string]interface{}{"code": code},
+	}
+	if field != "" {
+		gqlErr.Path = []interface{}{field}
+	}
+	return GraphQLResponse{Errors: []GraphQLError{gqlErr}}
+}
+
+// unauthenticated is the error of fields that need a signed in viewer
+func unauthenticated(field string) GraphQLResponse {
+	return errorResponse(field, CodeUnauthenticated, "Not authenticated")
+}
+
+// internalError logs an unexpected failure under a new correlation ID along
+// with keysAndValues, and returns the generic error carrying that ID
+func (r *Resolver) internalError(field, message string, err error, keysAndValues ...interface{}) GraphQLResponse {
+	correlationID := uuid.New().String()
+	r.logger.Error(message, append(keysAndValues, "field", field, "correlation_id", correlationID, "error", err)...)
+
+	response := errorResponse(field, CodeInternal, internalErrorMessage)
+	response.Errors[0].Extensions["correlationId"] = correlationID
+	return response
+}
+
+// authErrorResponse returns an auth.AuthError with its message, its code
+// mapped to an error code and kept as the reason. Other errors are internal.
+func (r *Resolver) authErrorResponse(field, message string, err error) GraphQLResponse {
+	var authErr *auth.AuthError
+	if !errors.As(err, &authErr) {
+		return r.internalError(field, message, err)
+	}
+
+	code, ok := authErrorCodes[authErr.Code]
+	if !ok {
+		code = CodeValidation
+	}
+
+	response := errorResponse(field, code, authErr.Message)
+	response.Errors[0].Extensions["reason"] = authErr.Code
+	return response
+}
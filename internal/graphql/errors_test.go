@@ -0,0 +1,97 @@
+package graphql
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"fowergram-backend/pkg/auth"
+	"fowergram-backend/pkg/logger"
+)
+
+// recordingLogger keeps the correlation ID of each error logged. Other
+// methods are left to the embedded Logger.
+type recordingLogger struct {
+	logger.Logger
+
+	correlationIDs []interface{}
+	errs           []interface{}
+}
+
+func (l *recordingLogger) Error(msg string, keysAndValues ...interface{}) {
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		switch keysAndValues[i] {
+		case "correlation_id":
+			l.correlationIDs = append(l.correlationIDs, keysAndValues[i+1])
+		case "error":
+			l.errs = append(l.errs, keysAndValues[i+1])
+		}
+	}
+}
+
+func TestErrorCodes(t *testing.T) {
+	const signIn = `mutation($email: String!, $password: String!) { signIn(email: $email, password: $password) { accessToken } }`
+	credentials := map[string]interface{}{"email": "alice@example.com", "password": "secret"}
+
+	tests := []struct {
+		name       string
+		token      string
+		query      string
+		variables  map[string]interface{}
+		signInErr  error
+		postsErr   error
+		wantCode   string
+		wantReason string // Auth error code kept in extensions.reason
+	}{
+		{name: "missing credentials", query: signIn, variables: map[string]interface{}{}, wantCode: CodeValidation},
+		{name: "wrong password", query: signIn, variables: credentials, signInErr: auth.ErrInvalidCredentials, wantCode: CodeUnauthenticated, wantReason: "INVALID_CREDENTIALS"},
+		{name: "wrapped auth error", query: signIn, variables: credentials, signInErr: fmt.Errorf("signing in: %w", auth.ErrEmailNotVerified), wantCode: CodeUnauthenticated, wantReason: "EMAIL_NOT_VERIFIED"},
+		{name: "unlisted auth error", query: signIn, variables: credentials, signInErr: auth.ErrPasswordReused, wantCode: CodeValidation, wantReason: "PASSWORD_REUSED"},
+		{name: "sign in failure", query: signIn, variables: credentials, signInErr: errors.New("connection reset"), wantCode: CodeInternal},
+		{name: "listing failure", token: "alice-token", query: feedQuery, postsErr: errors.New("connection reset"), wantCode: CodeInternal},
+		{name: "anonymous", query: feedQuery, wantCode: CodeUnauthenticated},
+		{name: "unsupported query", token: "alice-token", query: `{ weather }`, wantCode: CodeValidation},
+		{name: "introspection disabled", query: `{ __schema { types { name } } }`, wantCode: CodeIntrospectionDisabled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newPostFixture(t)
+			log := &recordingLogger{Logger: logger.NewZapLogger()}
+			sessions := &fakeAuthService{sessions: map[string]*auth.User{"alice-token": f.alice}, signInErr: tt.signInErr}
+			f.posts.err = tt.postsErr
+			f.server = NewServer(f.users, f.posts, sessions, false, log)
+
+			resp := f.query(t, tt.token, tt.query, tt.variables)
+			if len(resp.Errors) != 1 {
+				t.Fatalf("errors = %+v, want one", resp.Errors)
+			}
+			extensions := resp.Errors[0].Extensions
+			if extensions["code"] != tt.wantCode {
+				t.Errorf("code = %v, want %s", extensions["code"], tt.wantCode)
+			}
+			if tt.wantReason != "" && extensions["reason"] != tt.wantReason {
+				t.Errorf("reason = %v, want %s", extensions["reason"], tt.wantReason)
+			}
+
+			if tt.wantCode != CodeInternal {
+				if len(log.correlationIDs) > 0 {
+					t.Errorf("logged %v as internal errors", log.errs)
+				}
+				return
+			}
+			// The cause is logged under the correlation ID the client gets,
+			// which is all it learns
+			if resp.Errors[0].Message != internalErrorMessage || strings.Contains(fmt.Sprint(resp.Errors), "connection reset") {
+				t.Errorf("errors = %+v, want the generic message", resp.Errors)
+			}
+			if len(log.correlationIDs) != 1 || extensions["correlationId"] != log.correlationIDs[0] {
+				t.Errorf("correlation ID = %v, logged %v", extensions["correlationId"], log.correlationIDs)
+			}
+			if len(log.errs) != 1 || !strings.Contains(fmt.Sprint(log.errs[0]), "connection reset") {
+				t.Errorf("logged errors %v, want the cause", log.errs)
+			}
+		})
+	}
+}
@@ -0,0 +1,362 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"unicode/utf8"
+
+	"fowergram-backend/internal/domain/moderation"
+	"fowergram-backend/internal/domain/post"
+	"fowergram-backend/internal/domain/user"
+	"fowergram-backend/pkg/auth"
+
+	"github.com/google/uuid"
+)
+
+// User error codes returned in payloads for expected failures
+const (
+	CodeInvalidInput     = "INVALID_INPUT"
+	CodeContentRejected  = "CONTENT_REJECTED"
+	CodePostNotFound     = "POST_NOT_FOUND"
+	CodeNotPostOwner     = "NOT_POST_OWNER"
+	CodeUserNotFound     = "USER_NOT_FOUND"
+	CodeCannotFollowSelf = "CANNOT_FOLLOW_SELF"
+	CodeFollowBlocked    = "FOLLOW_BLOCKED"
+	CodeAlreadyFollowing = "ALREADY_FOLLOWING"
+)
+
+// UserError is an expected failure of a mutation, returned in its payload
+// rather than as a top-level GraphQL error
+type UserError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Field   string `json:"field,omitempty"` // Input field the error is about, if any
+}
+
+// CreatePostInput represents the input of createPost
+type CreatePostInput struct {
+	Title            string   `json:"title"`
+	Content          string   `json:"content"`
+	Caption          string   `json:"caption"`
+	Location         string   `json:"location"`
+	MediaKeys        []string `json:"mediaKeys"`
+	Tags             []string `json:"tags"`
+	IsPrivate        bool     `json:"isPrivate"`
+	CommentsDisabled bool     `json:"commentsDisabled"`
+	HideLikeCount    bool     `json:"hideLikeCount"`
+}
+
+// PostPayload is returned by createPost, likePost and unlikePost
+type PostPayload struct {
+	Post       *Post       `json:"post"`
+	UserErrors []UserError `json:"userErrors"`
+}
+
+// DeletePostPayload is returned by deletePost
+type DeletePostPayload struct {
+	DeletedPostID *string     `json:"deletedPostId"`
+	UserErrors    []UserError `json:"userErrors"`
+}
+
+// FollowPayload is returned by followUser and unfollowUser. Status is
+// FOLLOWING, REQUESTED for private accounts, or NONE after unfollowing.
+type FollowPayload struct {
+	User       *Author     `json:"user"`
+	Status     *string     `json:"status"`
+	UserErrors []UserError `json:"userErrors"`
+}
+
+// handleCreatePost resolves createPost(input)
+func (r *Resolver) handleCreatePost(ctx context.Context, variables map[string]interface{}) GraphQLResponse {
+	viewer, err := r.authService.GetUserFromContext(ctx)
+	if err != nil {
+		return unauthenticated("createPost")
+	}
+
+	var input CreatePostInput
+	if err := decodeInput(variables["input"], &input); err != nil {
+		return errorResponse("createPost", CodeValidation, "Invalid input")
+	}
+	if userErr := validateCreatePost(input); userErr != nil {
+		return payload("createPost", PostPayload{UserErrors: []UserError{*userErr}})
+	}
+
+	p, err := r.postService.CreatePost(ctx, viewer.ID, post.CreatePostInput{
+		Title:            input.Title,
+		Content:          input.Content,
+		Caption:          optionalString(input.Caption),
+		Location:         optionalString(input.Location),
+		IsPrivate:        input.IsPrivate,
+		CommentsDisabled: input.CommentsDisabled,
+		HideLikeCount:    input.HideLikeCount,
+		MediaKeys:        input.MediaKeys,
+		Tags:             input.Tags,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, moderation.ErrContentRejected):
+			return payload("createPost", PostPayload{UserErrors: userErrors(CodeContentRejected, err)})
+		case errors.Is(err, post.ErrMediaNotFound), errors.Is(err, post.ErrMediaNotUploaded),
+			errors.Is(err, post.ErrInvalidMediaKey), errors.Is(err, post.ErrTooManyTags):
+			return payload("createPost", PostPayload{UserErrors: userErrors(CodeInvalidInput, err)})
+		}
+		return r.internalError("createPost", "Failed to create post", err, "user_id", viewer.ID)
+	}
+
+	return r.postPayload(ctx, "createPost", p)
+}
+
+// handleDeletePost resolves deletePost(id)
+func (r *Resolver) handleDeletePost(ctx context.Context, variables map[string]interface{}) GraphQLResponse {
+	viewer, err := r.authService.GetUserFromContext(ctx)
+	if err != nil {
+		return unauthenticated("deletePost")
+	}
+
+	postID, ok := parseID(variables["id"])
+	if !ok {
+		return payload("deletePost", DeletePostPayload{UserErrors: postNotFound()})
+	}
+
+	if err := r.postService.DeletePost(ctx, postID, post.Actor{UserID: viewer.ID, Admin: viewer.HasRole(auth.RoleAdmin)}); err != nil {
+		switch {
+		case errors.Is(err, post.ErrPostNotFound):
+			return payload("deletePost", DeletePostPayload{UserErrors: postNotFound()})
+		case errors.Is(err, post.ErrNotPostOwner):
+			return payload("deletePost", DeletePostPayload{UserErrors: []UserError{{
+				Code:    CodeNotPostOwner,
+				Message: "You can only delete your own posts",
+			}}})
+		}
+		return r.internalError("deletePost", "Failed to delete post", err, "post_id", postID)
+	}
+
+	id := postID.String()
+	return payload("deletePost", DeletePostPayload{DeletedPostID: &id, UserErrors: []UserError{}})
+}
+
+// handleLikePost resolves likePost(id) and, when like is false,
+// unlikePost(id). Both are idempotent, like their REST counterparts.
+func (r *Resolver) handleLikePost(ctx context.Context, variables map[string]interface{}, like bool) GraphQLResponse {
+	field, action := "likePost", r.postService.LikePost
+	if !like {
+		field, action = "unlikePost", r.postService.UnlikePost
+	}
+
+	viewer, err := r.authService.GetUserFromContext(ctx)
+	if err != nil {
+		return unauthenticated(field)
+	}
+
+	postID, ok := parseID(variables["id"])
+	if !ok {
+		return payload(field, PostPayload{UserErrors: postNotFound()})
+	}
+
+	if err := action(ctx, postID, viewer.ID); err != nil {
+		if errors.Is(err, post.ErrPostNotFound) {
+			return payload(field, PostPayload{UserErrors: postNotFound()})
+		}
+		return r.internalError(field, "Failed to update like", err, "post_id", postID, "like", like)
+	}
+
+	p, err := r.postService.GetPost(ctx, postID, viewer.ID)
+	if err != nil {
+		if errors.Is(err, post.ErrPostNotFound) {
+			return payload(field, PostPayload{UserErrors: postNotFound()})
+		}
+		return r.internalError(field, "Failed to get post", err, "post_id", postID)
+	}
+
+	return r.postPayload(ctx, field, p)
+}
+
+// handleFollowUser resolves followUser(username). Following an account
+// the viewer already follows returns ALREADY_FOLLOWING.
+func (r *Resolver) handleFollowUser(ctx context.Context, variables map[string]interface{}) GraphQLResponse {
+	viewer, err := r.authService.GetUserFromContext(ctx)
+	if err != nil {
+		return unauthenticated("followUser")
+	}
+
+	target, userErrs, err := r.lookupUsername(ctx, variables)
+	if err != nil {
+		return r.internalError("followUser", "Failed to get user", err)
+	}
+	if userErrs != nil {
+		return payload("followUser", FollowPayload{UserErrors: userErrs})
+	}
+
+	following, err := r.userService.IsFollowing(ctx, viewer.ID, target.ID)
+	if err != nil {
+		return r.internalError("followUser", "Failed to check follow", err, "user_id", target.ID)
+	}
+	if following {
+		return payload("followUser", FollowPayload{UserErrors: []UserError{{
+			Code:    CodeAlreadyFollowing,
+			Message: "You already follow this user",
+		}}})
+	}
+
+	status, err := r.userService.FollowUser(ctx, viewer.ID, target.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrUserNotFound):
+			return payload("followUser", FollowPayload{UserErrors: userNotFound()})
+		case errors.Is(err, user.ErrCannotFollowSelf):
+			return payload("followUser", FollowPayload{UserErrors: []UserError{{
+				Code:    CodeCannotFollowSelf,
+				Message: "You cannot follow yourself",
+			}}})
+		case errors.Is(err, user.ErrFollowBlocked):
+			return payload("followUser", FollowPayload{UserErrors: []UserError{{
+				Code:    CodeFollowBlocked,
+				Message: "You cannot follow this user",
+			}}})
+		}
+		return r.internalError("followUser", "Failed to follow user", err, "user_id", target.ID)
+	}
+
+	return payload("followUser", FollowPayload{
+		User:       toAuthor(target),
+		Status:     followStatus(string(status)),
+		UserErrors: []UserError{},
+	})
+}
+
+// handleUnfollowUser resolves unfollowUser(username), which also withdraws
+// a pending follow request
+func (r *Resolver) handleUnfollowUser(ctx context.Context, variables map[string]interface{}) GraphQLResponse {
+	viewer, err := r.authService.GetUserFromContext(ctx)
+	if err != nil {
+		return unauthenticated("unfollowUser")
+	}
+
+	target, userErrs, err := r.lookupUsername(ctx, variables)
+	if err != nil {
+		return r.internalError("unfollowUser", "Failed to get user", err)
+	}
+	if userErrs != nil {
+		return payload("unfollowUser", FollowPayload{UserErrors: userErrs})
+	}
+
+	if err := r.userService.UnfollowUser(ctx, viewer.ID, target.ID); err != nil {
+		return r.internalError("unfollowUser", "Failed to unfollow user", err, "user_id", target.ID)
+	}
+
+	return payload("unfollowUser", FollowPayload{
+		User:       toAuthor(target),
+		Status:     followStatus("none"),
+		UserErrors: []UserError{},
+	})
+}
+
+// lookupUsername finds the user named by the username variable. Unknown
+// users are reported as user errors.
+func (r *Resolver) lookupUsername(ctx context.Context, variables map[string]interface{}) (*auth.User, []UserError, error) {
+	username, _ := variables["username"].(string)
+	if username == "" {
+		return nil, userNotFound(), nil
+	}
+
+	target, err := r.userService.GetUserByUsername(ctx, username)
+	if err != nil {
+		if errors.Is(err, auth.ErrUserNotFound) {
+			return nil, userNotFound(), nil
+		}
+		return nil, nil, err
+	}
+	return target, nil, nil
+}
+
+// postPayload returns p with its author in a successful PostPayload
+func (r *Resolver) postPayload(ctx context.Context, field string, p *post.Post) GraphQLResponse {
+	loader := user.NewLoader(r.userService)
+	if err := loader.LoadMany(ctx, post.AuthorIDs([]*post.Post{p})); err != nil {
+		r.logger.Error("Failed to load post authors", "error", err)
+	}
+
+	return payload(field, PostPayload{Post: toPost(p, loader), UserErrors: []UserError{}})
+}
+
+// validateCreatePost applies the limits the REST endpoint validates
+func validateCreatePost(input CreatePostInput) *UserError {
+	switch {
+	case input.Title == "":
+		return &UserError{Code: CodeInvalidInput, Message: "title is required", Field: "title"}
+	case utf8.RuneCountInString(input.Title) > 200:
+		return &UserError{Code: CodeInvalidInput, Message: "title must be at most 200 characters", Field: "title"}
+	case input.Content == "":
+		return &UserError{Code: CodeInvalidInput, Message: "content is required", Field: "content"}
+	case utf8.RuneCountInString(input.Content) > 2000:
+		return &UserError{Code: CodeInvalidInput, Message: "content must be at most 2000 characters", Field: "content"}
+	}
+	return nil
+}
+
+// decodeInput converts an input object variable into out
+func decodeInput(v interface{}, out interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+// parseID reads a UUID variable
+func parseID(v interface{}) (uuid.UUID, bool) {
+	raw, _ := v.(string)
+	id, err := uuid.Parse(raw)
+	return id, err == nil
+}
+
+// optionalString returns nil for an empty string
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// followStatus renders a follow status as a GraphQL enum value
+func followStatus(status string) *string {
+	switch status {
+	case string(user.FollowStatusFollowing):
+		status = "FOLLOWING"
+	case string(user.FollowStatusRequested):
+		status = "REQUESTED"
+	default:
+		status = "NONE"
+	}
+	return &status
+}
+
+func toAuthor(u *auth.User) *Author {
+	return &Author{
+		ID:         u.ID.String(),
+		Username:   u.Username,
+		FullName:   u.FullName,
+		Avatar:     u.ProfilePicture,
+		IsVerified: u.IsVerified,
+	}
+}
+
+func userErrors(code string, err error) []UserError {
+	return []UserError{{Code: code, Message: err.Error()}}
+}
+
+func postNotFound() []UserError {
+	return []UserError{{Code: CodePostNotFound, Message: "Post not found"}}
+}
+
+func userNotFound() []UserError {
+	return []UserError{{Code: CodeUserNotFound, Message: "User not found"}}
+}
+
+// payload wraps a mutation payload in a response
+func payload(field string, v interface{}) GraphQLResponse {
+	return GraphQLResponse{
+		Data: map[string]interface{}{field: v},
+	}
+}
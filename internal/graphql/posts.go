@@ -0,0 +1,242 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"fowergram-backend/internal/domain/post"
+	"fowergram-backend/internal/domain/user"
+
+	"github.com/google/uuid"
+)
+
+// Page size bounds for connection fields, matching the REST endpoints
+const (
+	defaultFirst = 10
+	maxFirst     = 100
+)
+
+// Post represents a post in GraphQL responses
+type Post struct {
+	ID               string  `json:"id"`
+	Title            string  `json:"title"`
+	Content          string  `json:"content"`
+	Caption          *string `json:"caption"`
+	Location         *string `json:"location"`
+	IsPrivate        bool    `json:"isPrivate"`
+	CommentsDisabled bool    `json:"commentsDisabled"`
+	HideLikeCount    bool    `json:"hideLikeCount"`
+	LikeCount        *int    `json:"likeCount"` // Null when the author hid it from the viewer
+	CommentCount     int     `json:"commentCount"`
+	RepostCount      int     `json:"repostCount"`
+	IsSaved          bool    `json:"isSaved"`
+	CreatedAt        string  `json:"createdAt"`
+	UpdatedAt        string  `json:"updatedAt"`
+	Author           *Author `json:"author"`   // Null when the author's account is gone
+	Original         *Post   `json:"original"` // The reposted post, if any
+}
+
+// Author represents the public profile of a post's author
+type Author struct {
+	ID         string `json:"id"`
+	Username   string `json:"username"`
+	FullName   string `json:"fullName,omitempty"`
+	Avatar     string `json:"avatar,omitempty"`
+	IsVerified bool   `json:"isVerified"`
+}
+
+// PostConnection is a Relay-style page of posts
+type PostConnection struct {
+	Edges    []PostEdge `json:"edges"`
+	PageInfo PageInfo   `json:"pageInfo"`
+}
+
+// PostEdge wraps a post with the cursor to resume after it
+type PostEdge struct {
+	Cursor string `json:"cursor"`
+	Node   *Post  `json:"node"`
+}
+
+// PageInfo describes where a connection page ends
+type PageInfo struct {
+	EndCursor   *string `json:"endCursor"`
+	HasNextPage bool    `json:"hasNextPage"`
+}
+
+// handlePosts resolves posts(authorID, after, first), the posts visible to
+// the viewer, optionally by one author
+func (r *Resolver) handlePosts(ctx context.Context, variables map[string]interface{}) GraphQLResponse {
+	viewer, err := r.authService.GetUserFromContext(ctx)
+	if err != nil {
+		return unauthenticated("posts")
+	}
+
+	var filter post.ListPostsFilter
+	if raw, _ := variables["authorID"].(string); raw != "" {
+		authorID, err := uuid.Parse(raw)
+		if err != nil {
+			return errorResponse("posts", CodeValidation, "Invalid author ID")
+		}
+		filter.AuthorID = &authorID
+	}
+
+	query, err := connectionQuery(variables)
+	if err != nil {
+		return errorResponse("posts", CodeValidation, err.Error())
+	}
+
+	page, err := r.postService.ListPosts(ctx, viewer.ID, filter, query)
+	if err != nil {
+		return r.internalError("posts", "Failed to list posts", err, "user_id", viewer.ID)
+	}
+
+	conn, err := r.postConnection(ctx, page)
+	if err != nil {
+		return r.internalError("posts", "Failed to load post authors", err)
+	}
+
+	return GraphQLResponse{
+		Data: map[string]interface{}{"posts": conn},
+	}
+}
+
+// handlePost resolves post(id). Posts the viewer may not see resolve to
+// null, like missing ones.
+func (r *Resolver) handlePost(ctx context.Context, variables map[string]interface{}) GraphQLResponse {
+	viewer, err := r.authService.GetUserFromContext(ctx)
+	if err != nil {
+		return unauthenticated("post")
+	}
+
+	raw, _ := variables["id"].(string)
+	postID, err := uuid.Parse(raw)
+	if err != nil {
+		return errorResponse("post", CodeValidation, "Invalid post ID")
+	}
+
+	p, err := r.postService.GetPost(ctx, postID, viewer.ID)
+	if err != nil {
+		if errors.Is(err, post.ErrPostNotFound) {
+			return GraphQLResponse{
+				Data: map[string]interface{}{"post": nil},
+			}
+		}
+		return r.internalError("post", "Failed to get post", err, "post_id", postID)
+	}
+
+	loader := user.NewLoader(r.userService)
+	if err := loader.LoadMany(ctx, post.AuthorIDs([]*post.Post{p})); err != nil {
+		return r.internalError("post", "Failed to load post authors", err)
+	}
+
+	return GraphQLResponse{
+		Data: map[string]interface{}{"post": toPost(p, loader)},
+	}
+}
+
+// handleFeed resolves feed(after, first), the viewer's home timeline
+func (r *Resolver) handleFeed(ctx context.Context, variables map[string]interface{}) GraphQLResponse {
+	viewer, err := r.authService.GetUserFromContext(ctx)
+	if err != nil {
+		return unauthenticated("feed")
+	}
+
+	query, err := connectionQuery(variables)
+	if err != nil {
+		return errorResponse("feed", CodeValidation, err.Error())
+	}
+
+	page, err := r.postService.GetFeed(ctx, viewer.ID, query)
+	if err != nil {
+		return r.internalError("feed", "Failed to get feed", err, "user_id", viewer.ID)
+	}
+
+	conn, err := r.postConnection(ctx, page)
+	if err != nil {
+		return r.internalError("feed", "Failed to load post authors", err)
+	}
+
+	return GraphQLResponse{
+		Data: map[string]interface{}{"feed": conn},
+	}
+}
+
+// connectionQuery reads the after and first arguments into the same keyset
+// query the REST endpoints use
+func connectionQuery(variables map[string]interface{}) (post.ListPostsQuery, error) {
+	after, _ := variables["after"].(string)
+	cursor, err := post.DecodeCursor(after)
+	if err != nil {
+		return post.ListPostsQuery{}, err
+	}
+
+	first := defaultFirst
+	if n, ok := variables["first"].(float64); ok {
+		first = int(n)
+	}
+	if first < 1 {
+		first = defaultFirst
+	}
+	if first > maxFirst {
+		first = maxFirst
+	}
+
+	return post.ListPostsQuery{After: cursor, Limit: first}, nil
+}
+
+// postConnection turns a page into a connection, loading all its authors in
+// one batch
+func (r *Resolver) postConnection(ctx context.Context, page *post.Page) (*PostConnection, error) {
+	loader := user.NewLoader(r.userService)
+	if err := loader.LoadMany(ctx, post.AuthorIDs(page.Posts)); err != nil {
+		return nil, err
+	}
+
+	conn := &PostConnection{
+		Edges: make([]PostEdge, 0, len(page.Posts)),
+		PageInfo: PageInfo{
+			HasNextPage: page.NextCursor != "",
+		},
+	}
+	for _, p := range page.Posts {
+		conn.Edges = append(conn.Edges, PostEdge{
+			Cursor: post.Cursor{CreatedAt: p.CreatedAt, ID: p.ID}.Encode(),
+			Node:   toPost(p, loader),
+		})
+	}
+	if n := len(conn.Edges); n > 0 {
+		conn.PageInfo.EndCursor = &conn.Edges[n-1].Cursor
+	}
+
+	return conn, nil
+}
+
+// toPost converts a post, taking its author from the loader's batch
+func toPost(p *post.Post, loader *user.Loader) *Post {
+	out := &Post{
+		ID:               p.ID.String(),
+		Title:            p.Title,
+		Content:          p.Content,
+		Caption:          p.Caption,
+		Location:         p.Location,
+		IsPrivate:        p.IsPrivate,
+		CommentsDisabled: p.CommentsDisabled,
+		HideLikeCount:    p.HideLikeCount,
+		LikeCount:        p.LikesCount,
+		CommentCount:     p.CommentsCount,
+		RepostCount:      p.RepostsCount,
+		IsSaved:          p.ViewerHasSaved,
+		CreatedAt:        p.CreatedAt.UTC().Format(time.RFC3339),
+		UpdatedAt:        p.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+
+	if u := loader.Get(p.UserID); u != nil {
+		out.Author = toAuthor(u)
+	}
+	if p.Original != nil {
+		out.Original = toPost(p.Original, loader)
+	}
+
+	return out
+}
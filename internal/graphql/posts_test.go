@@ -0,0 +1,363 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"fowergram-backend/internal/domain/post"
+	"fowergram-backend/internal/domain/user"
+	"fowergram-backend/pkg/auth"
+	"fowergram-backend/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// fakePostService pages through posts, newest first, the way the post
+// service's keyset pagination does, recording the queries it gets. Other
+// methods are left to the embedded nil Service.
+type fakePostService struct {
+	post.Service
+
+	posts   []*post.Post // Newest first
+	queries []post.ListPostsQuery
+	err     error // Returned by the listings when set
+}
+
+func (s *fakePostService) page(filter post.ListPostsFilter, q post.ListPostsQuery) *post.Page {
+	s.queries = append(s.queries, q)
+	var matched []*post.Post
+	for _, p := range s.posts {
+		if filter.AuthorID != nil && p.UserID != *filter.AuthorID {
+			continue
+		}
+		if q.After != nil && !p.CreatedAt.Before(q.After.CreatedAt) {
+			continue
+		}
+		matched = append(matched, p)
+	}
+
+	page := &post.Page{Posts: matched}
+	if len(matched) > q.Limit {
+		page.Posts = matched[:q.Limit]
+		last := page.Posts[q.Limit-1]
+		page.NextCursor = post.Cursor{CreatedAt: last.CreatedAt, ID: last.ID}.Encode()
+	}
+	return page
+}
+
+func (s *fakePostService) ListPosts(ctx context.Context, viewerID uuid.UUID, filter post.ListPostsFilter, q post.ListPostsQuery) (*post.Page, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.page(filter, q), nil
+}
+
+func (s *fakePostService) GetFeed(ctx context.Context, userID uuid.UUID, q post.ListPostsQuery) (*post.Page, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.page(post.ListPostsFilter{}, q), nil
+}
+
+func (s *fakePostService) GetPost(ctx context.Context, id, viewerID uuid.UUID) (*post.Post, error) {
+	for _, p := range s.posts {
+		if p.ID == id && (!p.IsPrivate || p.UserID == viewerID) {
+			return p, nil
+		}
+	}
+	return nil, post.ErrPostNotFound
+}
+
+// fakeUserService looks users up in batches, recording each batch. Other
+// methods are left to the embedded nil Service.
+type fakeUserService struct {
+	user.Service
+
+	users   map[uuid.UUID]*auth.User
+	batches [][]uuid.UUID
+}
+
+func (s *fakeUserService) GetUsersByIDs(ctx context.Context, ids []uuid.UUID) ([]*auth.User, error) {
+	s.batches = append(s.batches, ids)
+	var found []*auth.User
+	for _, id := range ids {
+		if u, ok := s.users[id]; ok {
+			found = append(found, u)
+		}
+	}
+	return found, nil
+}
+
+// fakeAuthService signs in the user each access token belongs to. Other
+// methods are left to the embedded nil AuthService.
+type fakeAuthService struct {
+	auth.AuthService
+
+	sessions  map[string]*auth.User
+	signInErr error // SignIn always fails with it
+}
+
+func (s *fakeAuthService) SignIn(ctx context.Context, email, password string, client auth.ClientInfo) (*auth.User, *auth.Tokens, error) {
+	return nil, nil, s.signInErr
+}
+
+func (s *fakeAuthService) ValidateSession(ctx context.Context, accessToken string) (*auth.User, error) {
+	u, ok := s.sessions[accessToken]
+	if !ok {
+		return nil, auth.ErrInvalidToken
+	}
+	return u, nil
+}
+
+func (s *fakeAuthService) GetUserFromContext(ctx context.Context) (*auth.User, error) {
+	if u, ok := ctx.Value("user").(*auth.User); ok {
+		return u, nil
+	}
+	return nil, auth.ErrUnauthorized
+}
+
+// postsResponse is the shape of the post queries' data
+type postsResponse struct {
+	Data   map[string]*PostConnection `json:"data"`
+	Errors []GraphQLError             `json:"errors"`
+}
+
+// postFixture serves GraphQL over five posts, one a minute: three by alice,
+// of which the oldest is private, and two by bob, whose account is gone
+type postFixture struct {
+	server http.Handler
+	posts  *fakePostService
+	users  *fakeUserService
+	alice  *auth.User
+	bob    uuid.UUID
+}
+
+func newPostFixture(t *testing.T) *postFixture {
+	t.Helper()
+	alice := &auth.User{ID: uuid.New(), Username: "alice"}
+	bob := uuid.New()
+	posts := &fakePostService{}
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i, author := range []uuid.UUID{alice.ID, bob, alice.ID, bob, alice.ID} {
+		p := &post.Post{ID: uuid.New(), UserID: author, Title: "post", CreatedAt: start.Add(time.Duration(-i) * time.Minute), IsPrivate: i == 4}
+		posts.posts = append(posts.posts, p)
+	}
+	users := &fakeUserService{users: map[uuid.UUID]*auth.User{alice.ID: alice}}
+	sessions := &fakeAuthService{sessions: map[string]*auth.User{"alice-token": alice}}
+
+	return &postFixture{
+		server: NewServer(users, posts, sessions, false, logger.NewZapLogger()),
+		posts:  posts,
+		users:  users,
+		alice:  alice,
+		bob:    bob,
+	}
+}
+
+// do sends query with variables as the holder of token and decodes the
+// response into resp
+func (f *postFixture) do(t *testing.T, token, query string, variables map[string]interface{}, resp interface{}) {
+	t.Helper()
+	body, err := json.Marshal(GraphQLRequest{Query: query, Variables: variables})
+	if err != nil {
+		t.Fatalf("encoding the request: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body)))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	f.server.ServeHTTP(rec, req)
+
+	if err := json.NewDecoder(rec.Body).Decode(resp); err != nil {
+		t.Fatalf("decoding the response: %v", err)
+	}
+}
+
+// query sends a connection query
+func (f *postFixture) query(t *testing.T, token, query string, variables map[string]interface{}) postsResponse {
+	t.Helper()
+	var resp postsResponse
+	f.do(t, token, query, variables, &resp)
+	return resp
+}
+
+const (
+	postsQuery = `query($authorID: ID, $after: String, $first: Int) {
+		posts(authorID: $authorID, after: $after, first: $first) { edges { cursor node { id author { username } } } pageInfo { endCursor hasNextPage } }
+	}`
+	feedQuery = `query($after: String, $first: Int) {
+		feed(after: $after, first: $first) { edges { cursor node { id } } pageInfo { endCursor hasNextPage } }
+	}`
+)
+
+func TestPostConnections(t *testing.T) {
+	f := newPostFixture(t)
+
+	// Paging through the feed two at a time visits every post once, in order
+	var visited []string
+	var after interface{}
+	for page := 1; ; page++ {
+		resp := f.query(t, "alice-token", feedQuery, map[string]interface{}{"after": after, "first": 2})
+		if len(resp.Errors) > 0 {
+			t.Fatalf("page %d: %+v", page, resp.Errors)
+		}
+		conn := resp.Data["feed"]
+		for _, edge := range conn.Edges {
+			visited = append(visited, edge.Node.ID)
+		}
+
+		last := conn.Edges[len(conn.Edges)-1]
+		if conn.PageInfo.EndCursor == nil || *conn.PageInfo.EndCursor != last.Cursor {
+			t.Errorf("page %d ends at %v, want the last edge's cursor %s", page, conn.PageInfo.EndCursor, last.Cursor)
+		}
+		if wantNext := page < 3; conn.PageInfo.HasNextPage != wantNext {
+			t.Errorf("page %d hasNextPage = %v, want %v", page, conn.PageInfo.HasNextPage, wantNext)
+		}
+		if !conn.PageInfo.HasNextPage {
+			break
+		}
+		after = *conn.PageInfo.EndCursor
+	}
+	var want []string
+	for _, p := range f.posts.posts {
+		want = append(want, p.ID.String())
+	}
+	if !slices.Equal(visited, want) {
+		t.Errorf("visited %v, want %v", visited, want)
+	}
+
+	// Exactly a page left ends the connection without an empty page after it
+	resp := f.query(t, "alice-token", feedQuery, map[string]interface{}{"first": 5})
+	if conn := resp.Data["feed"]; len(conn.Edges) != 5 || conn.PageInfo.HasNextPage {
+		t.Errorf("first 5 of 5 = %d edges, hasNextPage %v; want all and no next page", len(conn.Edges), conn.PageInfo.HasNextPage)
+	}
+
+	// Past the last post the connection is empty
+	end := post.Cursor{CreatedAt: f.posts.posts[4].CreatedAt, ID: f.posts.posts[4].ID}.Encode()
+	resp = f.query(t, "alice-token", feedQuery, map[string]interface{}{"after": end})
+	if conn := resp.Data["feed"]; conn == nil || len(conn.Edges) != 0 || conn.PageInfo.EndCursor != nil || conn.PageInfo.HasNextPage {
+		t.Errorf("past the end = %+v, want an empty connection", conn)
+	}
+}
+
+func TestPostConnectionArguments(t *testing.T) {
+	tests := []struct {
+		name      string
+		token     string
+		variables map[string]interface{}
+		wantLimit int    // Page size asked of the post service
+		wantCode  string // Error code, when the query fails
+	}{
+		{name: "default page size", token: "alice-token", wantLimit: defaultFirst},
+		{name: "page size", token: "alice-token", variables: map[string]interface{}{"first": 3}, wantLimit: 3},
+		{name: "no page", token: "alice-token", variables: map[string]interface{}{"first": 0}, wantLimit: defaultFirst},
+		{name: "page too large", token: "alice-token", variables: map[string]interface{}{"first": 1000}, wantLimit: maxFirst},
+		{name: "invalid cursor", token: "alice-token", variables: map[string]interface{}{"after": "not a cursor"}, wantCode: CodeValidation},
+		{name: "invalid author", token: "alice-token", variables: map[string]interface{}{"authorID": "alice"}, wantCode: CodeValidation},
+		{name: "anonymous", wantCode: CodeUnauthenticated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newPostFixture(t)
+			resp := f.query(t, tt.token, postsQuery, tt.variables)
+
+			if tt.wantCode != "" {
+				if len(resp.Errors) != 1 || resp.Errors[0].Extensions["code"] != tt.wantCode {
+					t.Errorf("errors = %+v, want %s", resp.Errors, tt.wantCode)
+				}
+				if len(f.posts.queries) != 0 {
+					t.Errorf("listed posts for a failed query")
+				}
+				return
+			}
+			if len(resp.Errors) > 0 {
+				t.Fatalf("errors = %+v", resp.Errors)
+			}
+			if len(f.posts.queries) != 1 || f.posts.queries[0].Limit != tt.wantLimit {
+				t.Errorf("queries = %+v, want one for %d posts", f.posts.queries, tt.wantLimit)
+			}
+		})
+	}
+}
+
+func TestPostAuthorsBatched(t *testing.T) {
+	f := newPostFixture(t)
+
+	resp := f.query(t, "alice-token", postsQuery, nil)
+	if len(resp.Errors) > 0 {
+		t.Fatalf("errors = %+v", resp.Errors)
+	}
+	var authors []string
+	for _, edge := range resp.Data["posts"].Edges {
+		if edge.Node.Author == nil {
+			authors = append(authors, "")
+		} else {
+			authors = append(authors, edge.Node.Author.Username)
+		}
+	}
+	// bob's account is gone, so his posts have no author
+	if want := []string{"alice", "", "alice", "", "alice"}; !slices.Equal(authors, want) {
+		t.Errorf("authors = %q, want %q", authors, want)
+	}
+	if len(f.users.batches) != 1 || len(f.users.batches[0]) != 2 {
+		t.Errorf("user lookups = %v, want one batch of both authors", f.users.batches)
+	}
+
+	// Filtered by author
+	resp = f.query(t, "alice-token", postsQuery, map[string]interface{}{"authorID": f.bob.String()})
+	if conn := resp.Data["posts"]; conn == nil || len(conn.Edges) != 2 {
+		t.Errorf("bob's posts = %+v, want his two", conn)
+	}
+}
+
+func TestPostQuery(t *testing.T) {
+	f := newPostFixture(t)
+	const query = `query($id: ID!) { post(id: $id) { id author { username } } }`
+
+	tests := []struct {
+		name     string
+		id       string
+		wantPost bool
+		wantCode string
+	}{
+		{name: "visible post", id: f.posts.posts[0].ID.String(), wantPost: true},
+		{name: "own private post", id: f.posts.posts[4].ID.String(), wantPost: true},
+		{name: "missing post", id: uuid.NewString()},
+		{name: "invalid ID", id: "42", wantCode: CodeValidation},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp struct {
+				Data   map[string]*Post `json:"data"`
+				Errors []GraphQLError   `json:"errors"`
+			}
+			f.do(t, "alice-token", query, map[string]interface{}{"id": tt.id}, &resp)
+
+			if tt.wantCode != "" {
+				if len(resp.Errors) != 1 || resp.Errors[0].Extensions["code"] != tt.wantCode {
+					t.Errorf("errors = %+v, want %s", resp.Errors, tt.wantCode)
+				}
+				return
+			}
+			got := resp.Data["post"]
+			if !tt.wantPost {
+				if got != nil || len(resp.Errors) > 0 {
+					t.Errorf("post = %+v with errors %+v, want null", got, resp.Errors)
+				}
+				return
+			}
+			if got == nil || got.ID != tt.id || got.Author == nil || got.Author.Username != "alice" {
+				t.Errorf("post = %+v, want %s by alice", got, tt.id)
+			}
+		})
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
+	"regexp"
 	"strings"
 
 	"fowergram-backend/internal/domain/post"
@@ -19,8 +21,14 @@ type Resolver struct {
 	postService post.Service
 	authService auth.AuthService
 	logger      logger.Logger
+
+	introspection bool // Answer __schema and __type queries
 }
 
+// introspectionField matches the schema introspection fields, but not
+// __typename
+var introspectionField = regexp.MustCompile(`\b__(schema|type)\b`)
+
 // AuthResponse represents the response for authentication operations
 type AuthResponse struct {
 	User         *AuthUser `json:"user"`
@@ -55,17 +63,20 @@ type GraphQLResponse struct {
 
 // GraphQLError represents a GraphQL error
 type GraphQLError struct {
-	Message string        `json:"message"`
-	Path    []interface{} `json:"path,omitempty"`
+	Message    string                 `json:"message"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
 }
 
-// NewServer creates a new GraphQL server
-func NewServer(userService user.Service, postService post.Service, authService auth.AuthService, logger logger.Logger) http.Handler {
+// NewServer creates a new GraphQL server. Introspection queries are refused
+// unless introspection is set.
+func NewServer(userService user.Service, postService post.Service, authService auth.AuthService, introspection bool, logger logger.Logger) http.Handler {
 	resolver := &Resolver{
-		userService: userService,
-		postService: postService,
-		authService: authService,
-		logger:      logger,
+		userService:   userService,
+		postService:   postService,
+		authService:   authService,
+		logger:        logger,
+		introspection: introspection,
 	}
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -73,69 +84,154 @@ func NewServer(userService user.Service, postService post.Service, authService a
 
 		if r.Method != http.MethodPost {
 			w.WriteHeader(http.StatusMethodNotAllowed)
-			json.NewEncoder(w).Encode(GraphQLResponse{
-				Errors: []GraphQLError{{Message: "Only POST method is allowed"}},
-			})
+			json.NewEncoder(w).Encode(errorResponse("", CodeValidation, "Only POST method is allowed"))
 			return
 		}
 
 		var req GraphQLRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(GraphQLResponse{
-				Errors: []GraphQLError{{Message: "Invalid JSON"}},
-			})
+			json.NewEncoder(w).Encode(errorResponse("", CodeValidation, "Invalid JSON"))
 			return
 		}
 
 		// Simple GraphQL query routing
-		response := resolver.handleGraphQL(r.Context(), req)
+		client := auth.ClientInfo{IP: clientIP(r), UserAgent: r.UserAgent()}
+		response := resolver.handleGraphQL(resolver.withViewer(r), req, client)
 		json.NewEncoder(w).Encode(response)
 	})
 }
 
-// handleGraphQL handles GraphQL requests with basic routing
-func (r *Resolver) handleGraphQL(ctx context.Context, req GraphQLRequest) GraphQLResponse {
-	query := strings.TrimSpace(req.Query)
+// withViewer returns the request context carrying the user of a valid
+// bearer token, where GetUserFromContext finds it. Requests without one stay
+// anonymous.
+func (r *Resolver) withViewer(req *http.Request) context.Context {
+	ctx := req.Context()
 
-	// Handle mutations
-	if strings.Contains(query, "signUp") {
-		return r.handleSignUp(ctx, req.Variables)
-	}
-	if strings.Contains(query, "signIn") {
-		return r.handleSignIn(ctx, req.Variables)
+	token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return ctx
 	}
-	if strings.Contains(query, "signOut") {
-		return r.handleSignOut(ctx)
-	}
-	if strings.Contains(query, "refreshToken") {
-		return r.handleRefreshToken(ctx, req.Variables)
+
+	user, err := r.authService.ValidateSession(ctx, token)
+	if err != nil {
+		return ctx
 	}
+	return context.WithValue(ctx, "user", user)
+}
 
-	// Handle queries
-	if strings.Contains(query, "me") {
-		return r.handleMe(ctx)
+// clientIP returns the address a request came from, without its port
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
 	}
+	return host
+}
+
+// Root post and social mutations, matched by name followed by their arguments
+var (
+	createPostField   = regexp.MustCompile(`\bcreatePost\s*\(`)
+	deletePostField   = regexp.MustCompile(`\bdeletePost\s*\(`)
+	likePostField     = regexp.MustCompile(`\blikePost\s*\(`)
+	unlikePostField   = regexp.MustCompile(`\bunlikePost\s*\(`)
+	followUserField   = regexp.MustCompile(`\bfollowUser\s*\(`)
+	unfollowUserField = regexp.MustCompile(`\bunfollowUser\s*\(`)
+)
 
-	// Default introspection query
-	if strings.Contains(query, "__schema") || strings.Contains(query, "__type") {
+// Root post fields, matched by name followed by their arguments or selection
+var (
+	feedField  = regexp.MustCompile(`\bfeed\s*[({]`)
+	postsField = regexp.MustCompile(`\bposts\s*[({]`)
+	postField  = regexp.MustCompile(`\bpost\s*[({]`)
+)
+
+// handleGraphQL handles GraphQL requests with basic routing
+func (r *Resolver) handleGraphQL(ctx context.Context, req GraphQLRequest, client auth.ClientInfo) GraphQLResponse {
+	query := strings.TrimSpace(req.Query)
+
+	// Introspection goes first, since its selections contain field names
+	// the routing below matches, such as "name"
+	if introspectionField.MatchString(query) {
+		if !r.introspection {
+			return errorResponse("", CodeIntrospectionDisabled, "Introspection is disabled")
+		}
 		return GraphQLResponse{
 			Data: map[string]interface{}{
 				"__schema": map[string]interface{}{
 					"types": []map[string]interface{}{
 						{"name": "Query"},
 						{"name": "Mutation"},
+						{"name": "Subscription"},
 						{"name": "User"},
 						{"name": "AuthResponse"},
+						{"name": "Post"},
+						{"name": "Author"},
+						{"name": "PostConnection"},
+						{"name": "PostEdge"},
+						{"name": "PageInfo"},
+						{"name": "UserError"},
+						{"name": "PostPayload"},
+						{"name": "DeletePostPayload"},
+						{"name": "FollowPayload"},
+						{"name": "NotificationEvent"},
 					},
 				},
 			},
 		}
 	}
 
-	return GraphQLResponse{
-		Errors: []GraphQLError{{Message: "Query not supported"}},
+	if subscriptionOperation.MatchString(query) {
+		return errorResponse("", CodeValidation, "Subscriptions require a WebSocket connection")
+	}
+
+	// Handle mutations
+	if strings.Contains(query, "signUp") {
+		return r.handleSignUp(ctx, req.Variables)
 	}
+	if strings.Contains(query, "signIn") {
+		return r.handleSignIn(ctx, req.Variables, client)
+	}
+	if strings.Contains(query, "signOut") {
+		return r.handleSignOut(ctx)
+	}
+	if strings.Contains(query, "refreshToken") {
+		return r.handleRefreshToken(ctx, req.Variables)
+	}
+
+	// Post and social mutations, before the queries since their selections
+	// mention post fields
+	switch {
+	case createPostField.MatchString(query):
+		return r.handleCreatePost(ctx, req.Variables)
+	case deletePostField.MatchString(query):
+		return r.handleDeletePost(ctx, req.Variables)
+	case likePostField.MatchString(query):
+		return r.handleLikePost(ctx, req.Variables, true)
+	case unlikePostField.MatchString(query):
+		return r.handleLikePost(ctx, req.Variables, false)
+	case followUserField.MatchString(query):
+		return r.handleFollowUser(ctx, req.Variables)
+	case unfollowUserField.MatchString(query):
+		return r.handleUnfollowUser(ctx, req.Variables)
+	}
+
+	// Handle queries. Post queries go first, since "me" also matches field
+	// names such as username.
+	if feedField.MatchString(query) {
+		return r.handleFeed(ctx, req.Variables)
+	}
+	if postsField.MatchString(query) {
+		return r.handlePosts(ctx, req.Variables)
+	}
+	if postField.MatchString(query) {
+		return r.handlePost(ctx, req.Variables)
+	}
+	if strings.Contains(query, "me") {
+		return r.handleMe(ctx)
+	}
+
+	return errorResponse("", CodeValidation, "Query not supported")
 }
 
 // handleSignUp handles user sign up
@@ -145,18 +241,13 @@ func (r *Resolver) handleSignUp(ctx context.Context, variables map[string]interf
 	username, _ := variables["username"].(string)
 
 	if email == "" || password == "" || username == "" {
-		return GraphQLResponse{
-			Errors: []GraphQLError{{Message: "Email, password, and username are required"}},
-		}
+		return errorResponse("signUp", CodeValidation, "Email, password, and username are required")
 	}
 
 	// Create user with SuperTokens
 	user, err := r.authService.CreateUser(ctx, email, password, username)
 	if err != nil {
-		r.logger.Error("Failed to create user", "error", err)
-		return GraphQLResponse{
-			Errors: []GraphQLError{{Message: err.Error()}},
-		}
+		return r.authErrorResponse("signUp", "Failed to create user", err)
 	}
 
 	// TODO: Store additional user info (username) in your database
@@ -178,23 +269,18 @@ func (r *Resolver) handleSignUp(ctx context.Context, variables map[string]interf
 }
 
 // handleSignIn handles user sign in
-func (r *Resolver) handleSignIn(ctx context.Context, variables map[string]interface{}) GraphQLResponse {
+func (r *Resolver) handleSignIn(ctx context.Context, variables map[string]interface{}, client auth.ClientInfo) GraphQLResponse {
 	email, _ := variables["email"].(string)
 	password, _ := variables["password"].(string)
 
 	if email == "" || password == "" {
-		return GraphQLResponse{
-			Errors: []GraphQLError{{Message: "Email and password are required"}},
-		}
+		return errorResponse("signIn", CodeValidation, "Email and password are required")
 	}
 
 	// Sign in with SuperTokens
-	user, token, err := r.authService.SignIn(ctx, email, password)
+	user, tokens, err := r.authService.SignIn(ctx, email, password, client)
 	if err != nil {
-		r.logger.Error("Failed to sign in", "error", err)
-		return GraphQLResponse{
-			Errors: []GraphQLError{{Message: err.Error()}},
-		}
+		return r.authErrorResponse("signIn", "Failed to sign in", err)
 	}
 
 	return GraphQLResponse{
@@ -204,8 +290,8 @@ func (r *Resolver) handleSignIn(ctx context.Context, variables map[string]interf
 					ID:    user.ID.String(),
 					Email: user.Email,
 				},
-				AccessToken:  token,
-				RefreshToken: "refresh-placeholder",
+				AccessToken:  tokens.AccessToken,
+				RefreshToken: tokens.RefreshToken,
 			},
 		},
 	}
@@ -229,17 +315,12 @@ func (r *Resolver) handleRefreshToken(ctx context.Context, variables map[string]
 	refreshToken, _ := variables["refreshToken"].(string)
 
 	if refreshToken == "" {
-		return GraphQLResponse{
-			Errors: []GraphQLError{{Message: "Refresh token is required"}},
-		}
+		return errorResponse("refreshToken", CodeValidation, "Refresh token is required")
 	}
 
 	user, newToken, err := r.authService.RefreshSession(ctx, refreshToken)
 	if err != nil {
-		r.logger.Error("Failed to refresh token", "error", err)
-		return GraphQLResponse{
-			Errors: []GraphQLError{{Message: err.Error()}},
-		}
+		return r.authErrorResponse("refreshToken", "Failed to refresh token", err)
 	}
 
 	return GraphQLResponse{
@@ -260,9 +341,7 @@ func (r *Resolver) handleRefreshToken(ctx context.Context, variables map[string]
 func (r *Resolver) handleMe(ctx context.Context) GraphQLResponse {
 	user, err := r.authService.GetUserFromContext(ctx)
 	if err != nil {
-		return GraphQLResponse{
-			Errors: []GraphQLError{{Message: "Not authenticated"}},
-		}
+		return unauthenticated("me")
 	}
 
 	return GraphQLResponse{
@@ -275,7 +354,12 @@ func (r *Resolver) handleMe(ctx context.Context) GraphQLResponse {
 	}
 }
 
-// NewPlayground creates a GraphQL playground handler
+// playgroundVersion pins the graphql-playground-react release the playground
+// loads from the CDN
+const playgroundVersion = "1.7.26"
+
+// NewPlayground creates a GraphQL playground handler. The access token
+// entered above the editor is sent as a bearer token with every request.
 func NewPlayground(endpoint string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/html")
@@ -286,26 +370,42 @@ func NewPlayground(endpoint string) http.Handler {
 <html>
 <head>
     <title>GraphQL Playground</title>
-    <link rel="stylesheet" href="https://cdn.jsdelivr.net/npm/graphql-playground-react/build/static/css/index.css" />
+    <link rel="stylesheet" href="https://cdn.jsdelivr.net/npm/graphql-playground-react@%[2]s/build/static/css/index.css" />
 </head>
 <body>
-    <div id="root">
-        <style>
-            body { margin: 0; font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', 'Roboto', sans-serif; }
-            #root { height: 100vh; }
-        </style>
-    </div>
-    <script src="https://cdn.jsdelivr.net/npm/graphql-playground-react/build/static/js/middleware.js"></script>
+    <style>
+        body { margin: 0; font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', 'Roboto', sans-serif; }
+        #auth { display: flex; gap: 8px; align-items: center; height: 40px; padding: 0 12px; background: #172a3a; color: #fff; }
+        #auth input { flex: 1; max-width: 480px; }
+        #root { height: calc(100vh - 40px); }
+    </style>
+    <form id="auth">
+        <label for="token">Access token</label>
+        <input id="token" type="password" autocomplete="off" />
+        <button type="submit">Use token</button>
+    </form>
+    <div id="root"></div>
+    <script src="https://cdn.jsdelivr.net/npm/graphql-playground-react@%[2]s/build/static/js/middleware.js"></script>
     <script>
+        const tokenKey = 'playground-token';
+        const token = sessionStorage.getItem(tokenKey) || '';
+        document.getElementById('token').value = token;
+        document.getElementById('auth').addEventListener('submit', (event) => {
+            event.preventDefault();
+            sessionStorage.setItem(tokenKey, document.getElementById('token').value.trim());
+            location.reload();
+        });
+
         window.GraphQLPlayground.init(document.getElementById('root'), {
-            endpoint: '%s',
+            endpoint: '%[1]s',
+            headers: token ? { Authorization: 'Bearer ' + token } : {},
             settings: {
                 'request.credentials': 'include',
             }
         })
     </script>
 </body>
-</html>`, endpoint)
+</html>`, endpoint, playgroundVersion)
 
 		w.Write([]byte(playground))
 	})
@@ -0,0 +1,508 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"fowergram-backend/internal/domain/post"
+	"fowergram-backend/internal/domain/user"
+	"fowergram-backend/internal/events"
+	"fowergram-backend/internal/realtime"
+	"fowergram-backend/pkg/auth"
+	"fowergram-backend/pkg/logger"
+	"fowergram-backend/pkg/middleware"
+
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+)
+
+// subprotocol is the GraphQL over WebSocket protocol served on /graphql, as
+// implemented by the graphql-ws client library
+const subprotocol = "graphql-transport-ws"
+
+// Message types of the protocol
+const (
+	msgConnectionInit = "connection_init"
+	msgConnectionAck  = "connection_ack"
+	msgPing           = "ping"
+	msgPong           = "pong"
+	msgSubscribe      = "subscribe"
+	msgNext           = "next"
+	msgError          = "error"
+	msgComplete       = "complete"
+)
+
+// Close codes the protocol defines for misbehaving clients
+const (
+	closeBadRequest          = 4400
+	closeUnauthorized        = 4401
+	closeForbidden           = 4403
+	closeBadSubprotocol      = 4406
+	closeInitTimeout         = 4408
+	closeDuplicateSubscriber = 4409
+	closeTooManyInits        = 4429
+)
+
+const (
+	// initTimeout is how long a client has to send connection_init
+	initTimeout = 10 * time.Second
+
+	// wsWriteWait bounds writing a single message
+	wsWriteWait = 10 * time.Second
+
+	// wsPongWait is how long a client may stay silent before it is
+	// considered gone. Pings are sent often enough for a live client to
+	// answer in time.
+	wsPongWait   = 60 * time.Second
+	wsPingPeriod = wsPongWait * 9 / 10
+
+	// wsMaxMessageSize limits the operations clients may send
+	wsMaxMessageSize = 64 << 10
+
+	// eventTimeout bounds resolving the payload of one event
+	eventTimeout = 5 * time.Second
+)
+
+// Subscription root fields, matched by name within a subscription operation
+var (
+	subscriptionOperation  = regexp.MustCompile(`^subscription\b`)
+	notificationAddedField = regexp.MustCompile(`\bnotificationAdded\b`)
+	feedPostAddedField     = regexp.MustCompile(`\bfeedPostAdded\b`)
+)
+
+// errInvalidMessage is returned by read for messages that aren't valid JSON
+var errInvalidMessage = errors.New("invalid message")
+
+// NotificationEvent represents a notification pushed by notificationAdded
+type NotificationEvent struct {
+	ID         string  `json:"id"`
+	Type       string  `json:"type"`
+	EntityType *string `json:"entityType"`
+	EntityID   *string `json:"entityId"`
+	Actor      *Author `json:"actor"` // Null for system notifications and deactivated actors
+	CreatedAt  string  `json:"createdAt"`
+}
+
+// wsMessage is a message of the protocol, in either direction
+type wsMessage struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// connectionInitPayload carries the access token, as either field
+type connectionInitPayload struct {
+	Token         string `json:"token"`
+	Authorization string `json:"authorization"` // "Bearer <token>"
+}
+
+// NewSubscriptionHandler serves GraphQL over WebSocket. Clients authenticate
+// with the access token in the connection_init payload, then subscribe to
+// notificationAdded and feedPostAdded; queries and mutations are answered
+// over the connection too. Events come from the realtime hub, which ends the
+// connections when it closes on shutdown.
+func NewSubscriptionHandler(userService user.Service, postService post.Service, authService auth.AuthService, hub *realtime.Hub, introspection bool, logger logger.Logger) fiber.Handler {
+	resolver := &Resolver{
+		userService:   userService,
+		postService:   postService,
+		authService:   authService,
+		logger:        logger,
+		introspection: introspection,
+	}
+
+	upgrade := websocket.New(func(conn *websocket.Conn) {
+		resolver.serveWebSocket(conn, hub)
+	}, websocket.Config{Subprotocols: []string{subprotocol}})
+
+	return func(c *fiber.Ctx) error {
+		if !websocket.IsWebSocketUpgrade(c) {
+			return fiber.ErrUpgradeRequired
+		}
+		return upgrade(c)
+	}
+}
+
+// wsConnection is one GraphQL over WebSocket connection
+type wsConnection struct {
+	resolver *Resolver
+	conn     *websocket.Conn
+	client   auth.ClientInfo
+
+	viewer *auth.User
+	ctx    context.Context // Carries the viewer for the resolvers
+
+	writeMu sync.Mutex
+
+	mu            sync.Mutex
+	subscriptions map[string]string // Root field by subscription id
+}
+
+// serveWebSocket runs the protocol until either side closes the connection
+// or the hub ends its subscription
+func (r *Resolver) serveWebSocket(conn *websocket.Conn, hub *realtime.Hub) {
+	clientIP, ok := conn.Locals(middleware.ClientIPKey).(string)
+	if !ok {
+		clientIP = conn.IP()
+	}
+
+	c := &wsConnection{
+		resolver:      r,
+		conn:          conn,
+		client:        auth.ClientInfo{IP: clientIP, UserAgent: conn.Headers(fiber.HeaderUserAgent)},
+		subscriptions: make(map[string]string),
+	}
+
+	if conn.Subprotocol() != subprotocol {
+		c.close(closeBadSubprotocol, "Subprotocol not acceptable")
+		return
+	}
+	conn.SetReadLimit(wsMaxMessageSize)
+
+	if !c.init() {
+		return
+	}
+
+	sub, err := hub.Subscribe(c.ctx, c.viewer.ID)
+	if err != nil {
+		if errors.Is(err, realtime.ErrHubClosed) {
+			c.close(websocket.CloseGoingAway, "server shutting down")
+			return
+		}
+		r.logger.Error("Failed to subscribe GraphQL connection", "user_id", c.viewer.ID, "error", err)
+		c.close(websocket.CloseInternalServerErr, "internal error")
+		return
+	}
+	defer hub.Unsubscribe(sub)
+
+	if err := c.write(wsMessage{Type: msgConnectionAck}); err != nil {
+		return
+	}
+
+	readDone := make(chan struct{})
+	go func() {
+		defer close(readDone)
+		c.readLoop()
+	}()
+
+	c.pushLoop(sub, readDone)
+
+	// Unblock the reader if the push side ended the connection
+	conn.Close()
+	<-readDone
+}
+
+// init reads the connection_init message and authenticates its token,
+// closing the connection and returning false when that fails
+func (c *wsConnection) init() bool {
+	c.conn.SetReadDeadline(time.Now().Add(initTimeout))
+
+	msg, err := c.read()
+	if err != nil {
+		var netErr interface{ Timeout() bool }
+		switch {
+		case errors.Is(err, errInvalidMessage):
+			c.close(closeBadRequest, "Invalid message")
+		case errors.As(err, &netErr) && netErr.Timeout():
+			c.close(closeInitTimeout, "Connection initialisation timeout")
+		}
+		return false
+	}
+
+	switch msg.Type {
+	case msgConnectionInit:
+	case msgSubscribe:
+		c.close(closeUnauthorized, "Unauthorized")
+		return false
+	default:
+		c.close(closeBadRequest, "Expected connection_init")
+		return false
+	}
+
+	var payload connectionInitPayload
+	if len(msg.Payload) > 0 {
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			c.close(closeBadRequest, "Invalid connection_init payload")
+			return false
+		}
+	}
+
+	token := payload.Token
+	if token == "" {
+		token, _ = strings.CutPrefix(payload.Authorization, "Bearer ")
+	}
+	if token == "" {
+		c.close(closeForbidden, "Forbidden")
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), initTimeout)
+	defer cancel()
+
+	viewer, err := c.resolver.authService.ValidateSession(ctx, token)
+	if err != nil {
+		c.close(closeForbidden, "Forbidden")
+		return false
+	}
+
+	c.viewer = viewer
+	c.ctx = context.WithValue(context.Background(), "user", viewer)
+	return true
+}
+
+// readLoop handles client messages until the connection fails, is closed or
+// breaks the protocol
+func (c *wsConnection) readLoop() {
+	c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		msg, err := c.read()
+		if err != nil {
+			if errors.Is(err, errInvalidMessage) {
+				c.close(closeBadRequest, "Invalid message")
+			}
+			return
+		}
+		c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+
+		switch msg.Type {
+		case msgPing:
+			err = c.write(wsMessage{Type: msgPong})
+		case msgPong:
+		case msgSubscribe:
+			err = c.subscribe(msg)
+		case msgComplete:
+			c.mu.Lock()
+			delete(c.subscriptions, msg.ID)
+			c.mu.Unlock()
+		case msgConnectionInit:
+			c.close(closeTooManyInits, "Too many initialisation requests")
+			return
+		default:
+			c.close(closeBadRequest, "Unknown message type")
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// subscribe starts a subscription operation, or executes a query or mutation
+// and completes it right away
+func (c *wsConnection) subscribe(msg wsMessage) error {
+	var req GraphQLRequest
+	if msg.ID == "" || json.Unmarshal(msg.Payload, &req) != nil {
+		c.close(closeBadRequest, "Invalid subscribe message")
+		return errors.New("invalid subscribe message")
+	}
+
+	c.mu.Lock()
+	_, exists := c.subscriptions[msg.ID]
+	c.mu.Unlock()
+	if exists {
+		c.close(closeDuplicateSubscriber, "Subscriber for "+msg.ID+" already exists")
+		return errors.New("duplicate subscriber")
+	}
+
+	query := strings.TrimSpace(req.Query)
+	if !subscriptionOperation.MatchString(query) {
+		response := c.resolver.handleGraphQL(c.ctx, req, c.client)
+		if err := c.send(msgNext, msg.ID, response); err != nil {
+			return err
+		}
+		return c.write(wsMessage{ID: msg.ID, Type: msgComplete})
+	}
+
+	var field string
+	switch {
+	case notificationAddedField.MatchString(query):
+		field = "notificationAdded"
+	case feedPostAddedField.MatchString(query):
+		field = "feedPostAdded"
+	default:
+		return c.send(msgError, msg.ID, errorResponse("", CodeValidation, "Subscription not supported").Errors)
+	}
+
+	c.mu.Lock()
+	c.subscriptions[msg.ID] = field
+	c.mu.Unlock()
+	return nil
+}
+
+// pushLoop sends subscribed events and keepalive pings until the hub ends
+// the subscription, a write fails or the reader stops
+func (c *wsConnection) pushLoop(sub *realtime.Subscription, readDone <-chan struct{}) {
+	ticker := time.NewTicker(wsPingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case frame := <-sub.Events():
+			if err := c.publish(frame); err != nil {
+				return
+			}
+		case <-ticker.C:
+			c.writeMu.Lock()
+			err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait))
+			c.writeMu.Unlock()
+			if err != nil {
+				return
+			}
+		case <-sub.Done():
+			c.close(sub.CloseReason())
+			return
+		case <-readDone:
+			return
+		}
+	}
+}
+
+// publish sends an event to the subscriptions of its root field, resolving
+// its payload once for all of them
+func (c *wsConnection) publish(frame realtime.Frame) error {
+	var field string
+	switch frame.Type {
+	case realtime.FrameNotification:
+		field = "notificationAdded"
+	case realtime.FramePost:
+		field = "feedPostAdded"
+	default:
+		return nil
+	}
+
+	c.mu.Lock()
+	var ids []string
+	for id, f := range c.subscriptions {
+		if f == field {
+			ids = append(ids, id)
+		}
+	}
+	c.mu.Unlock()
+	if len(ids) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(c.ctx, eventTimeout)
+	defer cancel()
+
+	var data interface{}
+	switch event := frame.Data.(type) {
+	case events.NotificationCreated:
+		data = c.resolveNotification(ctx, event)
+	case events.PostCreated:
+		p := c.resolveFeedPost(ctx, event)
+		if p == nil {
+			return nil
+		}
+		data = p
+	default:
+		return nil
+	}
+
+	response := GraphQLResponse{Data: map[string]interface{}{field: data}}
+	for _, id := range ids {
+		if err := c.send(msgNext, id, response); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveNotification converts a notification event, loading its actor
+func (c *wsConnection) resolveNotification(ctx context.Context, event events.NotificationCreated) *NotificationEvent {
+	n := &NotificationEvent{
+		ID:        event.NotificationID.String(),
+		Type:      event.Type,
+		CreatedAt: event.CreatedAt.UTC().Format(time.RFC3339),
+	}
+	if event.EntityType != "" {
+		n.EntityType = &event.EntityType
+	}
+	if event.EntityID != nil {
+		id := event.EntityID.String()
+		n.EntityID = &id
+	}
+
+	if event.ActorID != nil {
+		actor, err := user.NewLoader(c.resolver.userService).Load(ctx, *event.ActorID)
+		if err != nil {
+			c.resolver.logger.Warn("Failed to load notification actor", "actor_id", *event.ActorID, "error", err)
+		} else if actor != nil {
+			n.Actor = toAuthor(actor)
+		}
+	}
+
+	return n
+}
+
+// resolveFeedPost loads a new post of a followed account as the viewer sees
+// it, returning nil for posts the viewer may not see
+func (c *wsConnection) resolveFeedPost(ctx context.Context, event events.PostCreated) *Post {
+	p, err := c.resolver.postService.GetPost(ctx, event.PostID, c.viewer.ID)
+	if err != nil {
+		if !errors.Is(err, post.ErrPostNotFound) {
+			c.resolver.logger.Error("Failed to load feed post", "post_id", event.PostID, "error", err)
+		}
+		return nil
+	}
+
+	loader := user.NewLoader(c.resolver.userService)
+	if err := loader.LoadMany(ctx, post.AuthorIDs([]*post.Post{p})); err != nil {
+		c.resolver.logger.Error("Failed to load post authors", "error", err)
+		return nil
+	}
+	return toPost(p, loader)
+}
+
+// read reads the next protocol message
+func (c *wsConnection) read() (wsMessage, error) {
+	var msg wsMessage
+	_, data, err := c.conn.ReadMessage()
+	if err != nil {
+		return msg, err
+	}
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return msg, errInvalidMessage
+	}
+	return msg, nil
+}
+
+// send writes a message of type typ for operation id with payload
+func (c *wsConnection) send(typ, id string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return c.write(wsMessage{ID: id, Type: typ, Payload: data})
+}
+
+// write writes one message, serialized with the other writers
+func (c *wsConnection) write(msg wsMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	return c.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+// close sends a close frame, ignoring failures since the connection is going
+// away regardless
+func (c *wsConnection) close(code int, text string) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	_ = c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, text), time.Now().Add(wsWriteWait))
+}
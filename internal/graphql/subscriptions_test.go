@@ -0,0 +1,375 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"fowergram-backend/internal/domain/device"
+	"fowergram-backend/internal/domain/notification"
+	"fowergram-backend/internal/domain/post"
+	"fowergram-backend/internal/events"
+	"fowergram-backend/internal/handlers"
+	"fowergram-backend/internal/infra/cache"
+	"fowergram-backend/internal/infra/messaging"
+	"fowergram-backend/internal/realtime"
+	"fowergram-backend/pkg/auth"
+	"fowergram-backend/pkg/errreport"
+	"fowergram-backend/pkg/httperr"
+	"fowergram-backend/pkg/logger"
+
+	"github.com/fasthttp/websocket"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// fakeFollowing lists the accounts each user follows
+type fakeFollowing map[uuid.UUID][]uuid.UUID
+
+func (f fakeFollowing) GetFollowingIDs(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	return f[userID], nil
+}
+
+// likesRepository holds alice's post and records likes of it. Other methods
+// are left to the embedded nil Repository.
+type likesRepository struct {
+	post.Repository
+
+	mu    sync.Mutex
+	post  *post.Post
+	likes map[uuid.UUID]bool // By user
+}
+
+func (r *likesRepository) GetVisibleByID(ctx context.Context, id, viewerID uuid.UUID) (*post.Post, error) {
+	if id != r.post.ID {
+		return nil, post.ErrPostNotFound
+	}
+	return r.post, nil
+}
+
+func (r *likesRepository) Like(ctx context.Context, postID, userID uuid.UUID) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.likes[userID] {
+		return false, nil
+	}
+	r.likes[userID] = true
+	return true, nil
+}
+
+// storedNotifications stores every notification. Other methods are left to
+// the embedded nil Repository.
+type storedNotifications struct {
+	notification.Repository
+}
+
+func (storedNotifications) Create(ctx context.Context, n *notification.Notification) (bool, error) {
+	return true, nil
+}
+
+// noDevices lists no devices to push to. Other methods are left to the
+// embedded nil Repository.
+type noDevices struct {
+	device.Repository
+}
+
+func (noDevices) ListByUser(ctx context.Context, userID uuid.UUID) ([]*device.Device, error) {
+	return nil, nil
+}
+
+// subscriptionFixture serves GraphQL over WebSocket to alice, who follows
+// bob, with events published through a recording client. The REST API it
+// serves to bob likes alice's post through the post service, whose event the
+// notification worker turns into alice's notification.
+type subscriptionFixture struct {
+	addr      string
+	hub       *realtime.Hub
+	publisher events.Publisher
+	posts     *fakePostService
+	rest      *fiber.App
+	alicePost *post.Post
+	alice     *auth.User
+	bob       *auth.User
+}
+
+func newSubscriptionFixture(t *testing.T) *subscriptionFixture {
+	t.Helper()
+	log := logger.NewZapLogger()
+	alice := &auth.User{ID: uuid.New(), Username: "alice"}
+	bob := &auth.User{ID: uuid.New(), Username: "bob"}
+	posts := &fakePostService{}
+	users := &fakeUserService{users: map[uuid.UUID]*auth.User{alice.ID: alice, bob.ID: bob}}
+	sessions := &fakeAuthService{sessions: map[string]*auth.User{"alice-token": alice}}
+
+	client := messaging.NewRecordingClient()
+	hub := realtime.NewHub(fakeFollowing{alice.ID: {bob.ID}}, log)
+	if err := hub.Start(client); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	publisher := events.NewNATSPublisher(client, log)
+	alicePost := &post.Post{ID: uuid.New(), UserID: alice.ID, CreatedAt: time.Now()}
+	likes := &likesRepository{post: alicePost, likes: make(map[uuid.UUID]bool)}
+	postService := post.NewService(nil, likes, nil, nil, nil, nil, cache.NewMemoryCache(), nil, client, publisher, log, nil, "english")
+	notifications := notification.NewService(storedNotifications{}, noDevices{}, nil, publisher, log)
+	if err := notification.NewWorker(notifications, client, log).Start(); err != nil {
+		t.Fatalf("starting the notification worker: %v", err)
+	}
+
+	rest := fiber.New(fiber.Config{ErrorHandler: httperr.Handler(log, errreport.Nop())})
+	rest.Use(func(c *fiber.Ctx) error {
+		c.Locals("user", bob)
+		return c.Next()
+	})
+	rest.Post("/api/v1/posts/:id/like", handlers.NewPostHandler(postService, users, log).LikePost)
+
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Get("/graphql", NewSubscriptionHandler(users, posts, sessions, hub, false, log))
+
+	// Websockets hijack their connection, which app.Test can't serve
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening: %v", err)
+	}
+	go app.Listener(listener)
+	t.Cleanup(func() { app.ShutdownWithTimeout(time.Second) })
+	t.Cleanup(hub.Close)
+
+	return &subscriptionFixture{
+		addr:      listener.Addr().String(),
+		hub:       hub,
+		publisher: publisher,
+		posts:     posts,
+		rest:      rest,
+		alicePost: alicePost,
+		alice:     alice,
+		bob:       bob,
+	}
+}
+
+// dial opens a connection speaking the given subprotocols
+func (f *subscriptionFixture) dial(t *testing.T, subprotocols ...string) *websocket.Conn {
+	t.Helper()
+	dialer := websocket.Dialer{Subprotocols: subprotocols, HandshakeTimeout: 5 * time.Second}
+	conn, _, err := dialer.Dial("ws://"+f.addr+"/graphql", nil)
+	if err != nil {
+		t.Fatalf("dialing: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	return conn
+}
+
+// connect opens a connection acknowledged for alice
+func (f *subscriptionFixture) connect(t *testing.T) *websocket.Conn {
+	t.Helper()
+	conn := f.dial(t, subprotocol)
+	send(t, conn, `{"type": "connection_init", "payload": {"token": "alice-token"}}`)
+	if msg := receive(t, conn); msg.Type != msgConnectionAck {
+		t.Fatalf("received %+v, want connection_ack", msg)
+	}
+	return conn
+}
+
+// publish publishes an event as actorID
+func (f *subscriptionFixture) publish(t *testing.T, actorID uuid.UUID, payload events.Payload) {
+	t.Helper()
+	if err := f.publisher.Publish(context.Background(), actorID, payload); err != nil {
+		t.Fatalf("publishing: %v", err)
+	}
+}
+
+// like likes a post as bob through the REST API
+func (f *subscriptionFixture) like(t *testing.T, postID uuid.UUID) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/posts/"+postID.String()+"/like", nil)
+	resp, err := f.rest.Test(req, -1)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("liking: status = %d, want 200", resp.StatusCode)
+	}
+}
+
+// send writes a raw protocol message
+func send(t *testing.T, conn *websocket.Conn, msg string) {
+	t.Helper()
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
+		t.Fatalf("sending %s: %v", msg, err)
+	}
+}
+
+// receive reads the next protocol message
+func receive(t *testing.T, conn *websocket.Conn) wsMessage {
+	t.Helper()
+	var msg wsMessage
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("reading: %v", err)
+	}
+	if err := json.Unmarshal(data, &msg); err != nil {
+		t.Fatalf("decoding %s: %v", data, err)
+	}
+	return msg
+}
+
+// roundTrip waits for the server to handle the messages sent so far, which it
+// does in order, by exchanging a ping
+func roundTrip(t *testing.T, conn *websocket.Conn) {
+	t.Helper()
+	send(t, conn, `{"type": "ping"}`)
+	if msg := receive(t, conn); msg.Type != msgPong {
+		t.Fatalf("received %+v, want pong", msg)
+	}
+}
+
+// wantClose reads until the server closes the connection with code
+func wantClose(t *testing.T, conn *websocket.Conn, code int) {
+	t.Helper()
+	for {
+		_, data, err := conn.ReadMessage()
+		if err == nil {
+			t.Logf("ignoring %s", data)
+			continue
+		}
+		if !websocket.IsCloseError(err, code) {
+			t.Errorf("read error = %v, want close code %d", err, code)
+		}
+		return
+	}
+}
+
+func TestSubscriptionInit(t *testing.T) {
+	f := newSubscriptionFixture(t)
+
+	tests := []struct {
+		name          string
+		noSubprotocol bool
+		message       string // Sent first when set
+		wantClose     int    // Zero when the connection is acknowledged
+	}{
+		{name: "token", message: `{"type": "connection_init", "payload": {"token": "alice-token"}}`},
+		{name: "authorization", message: `{"type": "connection_init", "payload": {"authorization": "Bearer alice-token"}}`},
+		{name: "no subprotocol", noSubprotocol: true, wantClose: closeBadSubprotocol},
+		{name: "subscribe first", message: `{"id": "1", "type": "subscribe", "payload": {"query": "subscription { notificationAdded { id } }"}}`, wantClose: closeUnauthorized},
+		{name: "no token", message: `{"type": "connection_init"}`, wantClose: closeForbidden},
+		{name: "invalid token", message: `{"type": "connection_init", "payload": {"token": "stolen"}}`, wantClose: closeForbidden},
+		{name: "not JSON", message: `alice-token`, wantClose: closeBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subprotocols := []string{subprotocol}
+			if tt.noSubprotocol {
+				subprotocols = nil
+			}
+			conn := f.dial(t, subprotocols...)
+			if tt.message != "" {
+				send(t, conn, tt.message)
+			}
+
+			if tt.wantClose != 0 {
+				wantClose(t, conn, tt.wantClose)
+				return
+			}
+			if msg := receive(t, conn); msg.Type != msgConnectionAck {
+				t.Errorf("received %+v, want connection_ack", msg)
+			}
+		})
+	}
+
+	t.Run("second connection_init", func(t *testing.T) {
+		conn := f.connect(t)
+		send(t, conn, `{"type": "connection_init", "payload": {"token": "alice-token"}}`)
+		wantClose(t, conn, closeTooManyInits)
+	})
+}
+
+func TestSubscriptionEvents(t *testing.T) {
+	f := newSubscriptionFixture(t)
+	conn := f.connect(t)
+	send(t, conn, `{"id": "n", "type": "subscribe", "payload": {"query": "subscription { notificationAdded { id type actor { username } } }"}}`)
+	send(t, conn, `{"id": "p", "type": "subscribe", "payload": {"query": "subscription { feedPostAdded { id author { username } } }"}}`)
+	roundTrip(t, conn)
+
+	// A like by bob through the REST API reaches alice as a notification
+	// with its actor
+	f.like(t, f.alicePost.ID)
+	msg := receive(t, conn)
+	var added struct {
+		Data struct {
+			NotificationAdded NotificationEvent `json:"notificationAdded"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(msg.Payload, &added); err != nil {
+		t.Fatalf("decoding %s: %v", msg.Payload, err)
+	}
+	got := added.Data.NotificationAdded
+	if msg.ID != "n" || msg.Type != msgNext || got.Type != "like" || got.Actor == nil || got.Actor.Username != "bob" {
+		t.Errorf("received %s for %s, want the like by bob for n", msg.Payload, msg.ID)
+	}
+	like := events.NotificationCreated{NotificationID: uuid.MustParse(got.ID), UserID: f.alice.ID, ActorID: &f.bob.ID, Type: "like", CreatedAt: time.Now()}
+
+	// Of the new posts, only the visible one by bob is pushed: posts by
+	// accounts alice doesn't follow and private posts are skipped
+	hidden := &post.Post{ID: uuid.New(), UserID: f.bob.ID, IsPrivate: true, CreatedAt: time.Now()}
+	visible := &post.Post{ID: uuid.New(), UserID: f.bob.ID, CreatedAt: time.Now()}
+	f.posts.posts = []*post.Post{hidden, visible}
+	f.publish(t, uuid.New(), events.PostCreated{PostID: uuid.New(), AuthorID: uuid.New(), CreatedAt: time.Now()})
+	f.publish(t, f.bob.ID, events.PostCreated{PostID: hidden.ID, AuthorID: f.bob.ID, IsPrivate: true, CreatedAt: time.Now()})
+	f.publish(t, f.bob.ID, events.PostCreated{PostID: visible.ID, AuthorID: f.bob.ID, CreatedAt: time.Now()})
+	msg = receive(t, conn)
+	var feed struct {
+		Data struct {
+			FeedPostAdded Post `json:"feedPostAdded"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(msg.Payload, &feed); err != nil {
+		t.Fatalf("decoding %s: %v", msg.Payload, err)
+	}
+	if p := feed.Data.FeedPostAdded; msg.ID != "p" || p.ID != visible.ID.String() || p.Author == nil || p.Author.Username != "bob" {
+		t.Errorf("received %s for %s, want bob's visible post for p", msg.Payload, msg.ID)
+	}
+
+	// Completed subscriptions receive nothing more, so the next message is
+	// the post's
+	send(t, conn, `{"id": "n", "type": "complete"}`)
+	roundTrip(t, conn)
+	f.publish(t, f.bob.ID, like)
+	f.publish(t, f.bob.ID, events.PostCreated{PostID: visible.ID, AuthorID: f.bob.ID, CreatedAt: time.Now()})
+	if msg := receive(t, conn); msg.ID != "p" {
+		t.Errorf("received %+v after completing n, want the post for p", msg)
+	}
+
+	// Queries are answered once and completed
+	send(t, conn, `{"id": "q", "type": "subscribe", "payload": {"query": "query { post(id: \"`+visible.ID.String()+`\") { id } }"}}`)
+	if msg := receive(t, conn); msg.ID != "q" || msg.Type != msgNext {
+		t.Errorf("received %+v, want the query result", msg)
+	}
+	if msg := receive(t, conn); msg.ID != "q" || msg.Type != msgComplete {
+		t.Errorf("received %+v, want the query completed", msg)
+	}
+
+	send(t, conn, `{"id": "p", "type": "subscribe", "payload": {"query": "subscription { feedPostAdded { id } }"}}`)
+	wantClose(t, conn, closeDuplicateSubscriber)
+}
+
+func TestSubscriptionShutdown(t *testing.T) {
+	f := newSubscriptionFixture(t)
+	conn := f.connect(t)
+
+	f.hub.Close()
+	wantClose(t, conn, websocket.CloseGoingAway)
+
+	// New connections are turned away once the hub is closed
+	conn = f.dial(t, subprotocol)
+	send(t, conn, `{"type": "connection_init", "payload": {"token": "alice-token"}}`)
+	wantClose(t, conn, websocket.CloseGoingAway)
+}
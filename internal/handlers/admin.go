@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"errors"
+
+	"fowergram-backend/internal/domain/post"
+	"fowergram-backend/internal/domain/user"
+	"fowergram-backend/pkg/httperr"
+	"fowergram-backend/pkg/logger"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// AdminHandler serves maintenance endpoints guarded by the admin token
+type AdminHandler struct {
+	userService user.Service
+	postService post.Service
+	logger      logger.Logger
+}
+
+func NewAdminHandler(userService user.Service, postService post.Service, logger logger.Logger) *AdminHandler {
+	return &AdminHandler{
+		userService: userService,
+		postService: postService,
+		logger:      logger,
+	}
+}
+
+// UserCountsResponse represents a user's recomputed counts
+type UserCountsResponse struct {
+	UserID         string `json:"user_id"`
+	FollowersCount int    `json:"followers_count"`
+	FollowingCount int    `json:"following_count"`
+	PostsCount     int    `json:"posts_count"`
+}
+
+// ReconcileUserCounts recomputes a user's denormalized counts
+// @Summary Reconcile user counts
+// @Description Recompute a user's followers, following and posts counts from the followers and posts tables, repairing any drift. Requires the admin role, or the admin token when enabled.
+// @Tags Admin
+// @Produce json
+// @Param id path string true "User ID"
+// @Success 200 {object} UserCountsResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Security BearerAuth
+// @Security AdminToken
+// @Router /api/admin/users/{id}/reconcile-counts [post]
+func (h *AdminHandler) ReconcileUserCounts(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return httperr.BadRequest("Invalid user ID")
+	}
+
+	counts, err := h.userService.ReconcileCounts(c.Context(), userID)
+	if err != nil {
+		if isAuthError(err) {
+			return err
+		}
+		return httperr.Internal("Failed to reconcile user counts", err, "user_id", userID)
+	}
+
+	h.logger.Info("Reconciled user counts", "user_id", userID,
+		"followers", counts.Followers, "following", counts.Following, "posts", counts.Posts)
+
+	return c.JSON(UserCountsResponse{
+		UserID:         userID.String(),
+		FollowersCount: counts.Followers,
+		FollowingCount: counts.Following,
+		PostsCount:     counts.Posts,
+	})
+}
+
+// HardDeletePost permanently deletes a post
+// @Summary Hard delete post
+// @Description Permanently delete a post with its likes and comments, including one its author already deleted. Authors' own deletes only hide posts; this is for moderation and legal removal. Requires the admin role, or the admin token when enabled.
+// @Tags Admin
+// @Param id path string true "Post ID"
+// @Success 204 "No Content"
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Security BearerAuth
+// @Security AdminToken
+// @Router /api/admin/posts/{id} [delete]
+func (h *AdminHandler) HardDeletePost(c *fiber.Ctx) error {
+	postID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return httperr.BadRequest("Invalid post ID")
+	}
+
+	if err := h.postService.HardDeletePost(c.Context(), postID); err != nil {
+		if errors.Is(err, post.ErrPostNotFound) {
+			return httperr.NotFound("Post not found")
+		}
+		return httperr.Internal("Failed to hard delete post", err, "post_id", postID)
+	}
+
+	h.logger.Info("Hard deleted post", "post_id", postID)
+
+	return c.SendStatus(204)
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"fowergram-backend/internal/domain/user"
+	"fowergram-backend/pkg/errreport"
+	"fowergram-backend/pkg/httperr"
+	"fowergram-backend/pkg/logger"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+func TestReconcileUserCounts(t *testing.T) {
+	log := logger.NewZapLogger()
+	alice := uuid.New()
+	users := &fakeUserService{counts: map[uuid.UUID]*user.Counts{alice: {Followers: 3, Following: 2, Posts: 1}}}
+	handler := NewAdminHandler(users, nil, log)
+
+	app := fiber.New(fiber.Config{
+		ErrorHandler:          httperr.Handler(log, errreport.Nop()),
+		DisableStartupMessage: true,
+	})
+	app.Post("/api/admin/users/:id/reconcile-counts", handler.ReconcileUserCounts)
+
+	tests := []struct {
+		name       string
+		id         string
+		wantStatus int
+		wantBody   UserCountsResponse
+	}{
+		{
+			name:       "user",
+			id:         alice.String(),
+			wantStatus: fiber.StatusOK,
+			wantBody:   UserCountsResponse{UserID: alice.String(), FollowersCount: 3, FollowingCount: 2, PostsCount: 1},
+		},
+		{name: "missing user", id: uuid.NewString(), wantStatus: fiber.StatusNotFound},
+		{name: "invalid ID", id: "alice", wantStatus: fiber.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/admin/users/"+tt.id+"/reconcile-counts", nil)
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantStatus != fiber.StatusOK {
+				return
+			}
+
+			var body UserCountsResponse
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("decoding the response: %v", err)
+			}
+			if body != tt.wantBody {
+				t.Errorf("body = %+v, want %+v", body, tt.wantBody)
+			}
+		})
+	}
+}
@@ -1,23 +1,33 @@
 package handlers
 
 import (
+	"encoding/json"
+	"strings"
+	"time"
+
 	"fowergram-backend/pkg/auth"
 	"fowergram-backend/pkg/email"
+	"fowergram-backend/pkg/httperr"
 	"fowergram-backend/pkg/logger"
+	"fowergram-backend/pkg/middleware"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/google/uuid"
 )
 
 type AuthHandler struct {
 	authService  auth.AuthService
 	emailService email.EmailService
+	cookies      AuthCookieConfig
 	logger       logger.Logger
 }
 
-func NewAuthHandler(authService auth.AuthService, emailService email.EmailService, logger logger.Logger) *AuthHandler {
+func NewAuthHandler(authService auth.AuthService, emailService email.EmailService, cookies AuthCookieConfig, logger logger.Logger) *AuthHandler {
 	return &AuthHandler{
 		authService:  authService,
 		emailService: emailService,
+		cookies:      cookies,
 		logger:       logger,
 	}
 }
@@ -26,7 +36,7 @@ func NewAuthHandler(authService auth.AuthService, emailService email.EmailServic
 type SignupRequest struct {
 	Email    string `json:"email" validate:"required,email"`
 	Password string `json:"password" validate:"required,min=8"`
-	Username string `json:"username" validate:"required,min=3,max=50,alphanum"`
+	Username string `json:"username" validate:"required,username"`
 }
 
 // SigninRequest represents the signin request payload
@@ -40,6 +50,7 @@ type UserResponse struct {
 	ID       string `json:"id"`
 	Email    string `json:"email"`
 	Username string `json:"username"`
+	Version  int    `json:"version,omitempty"` // Profile version, sent back when updating the profile
 }
 
 // SignupResponse represents the signup response
@@ -48,17 +59,67 @@ type SignupResponse struct {
 	Message string       `json:"message"`
 }
 
-// SigninResponse represents the signin response
+// SigninResponse represents the signin response. In cookie mode the tokens
+// are set as cookies and left out.
 type SigninResponse struct {
-	User        UserResponse `json:"user"`
-	AccessToken string       `json:"accessToken"`
-	Message     string       `json:"message"`
+	User         UserResponse `json:"user"`
+	AccessToken  string       `json:"accessToken,omitempty"`
+	RefreshToken string       `json:"refreshToken,omitempty"`
+	Message      string       `json:"message"`
+}
+
+// RefreshRequest represents the token refresh request. In cookie mode the
+// refresh token cookie is used instead.
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
 }
 
-// ErrorResponse represents an error response
+// RefreshResponse represents the token refresh response. In cookie mode the
+// access token is set as a cookie and left out.
+type RefreshResponse struct {
+	AccessToken string `json:"accessToken,omitempty"`
+	Message     string `json:"message"`
+}
+
+// ErrorResponse documents the body of error responses, which
+// httperr.Handler writes as an httperr.Response
 type ErrorResponse struct {
-	Error   string      `json:"error"`
-	Details interface{} `json:"details,omitempty"`
+	Error     string      `json:"error"`
+	Code      string      `json:"code,omitempty"` // Stable machine-readable error code
+	Details   interface{} `json:"details,omitempty"`
+	RequestID string      `json:"request_id,omitempty"` // Set on 5xx responses to find the logged cause
+}
+
+// ChangePasswordRequest represents the request to change a signed-in user's password
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required,min=8"`
+}
+
+// ChangeEmailRequest represents the request to change a signed-in user's email
+type ChangeEmailRequest struct {
+	Password string `json:"password" validate:"required"`
+	NewEmail string `json:"new_email" validate:"required,email"`
+}
+
+// ConfirmEmailChangeRequest represents the request to confirm a new email
+type ConfirmEmailChangeRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// SessionResponse represents a signed-in session
+type SessionResponse struct {
+	ID        string `json:"id"`
+	IPAddress string `json:"ip_address,omitempty"`
+	UserAgent string `json:"user_agent,omitempty"`
+	CreatedAt string `json:"created_at"`
+	ExpiresAt string `json:"expires_at"`
+	Current   bool   `json:"current"` // The session making this request
+}
+
+// SessionListResponse represents the current user's active sessions
+type SessionListResponse struct {
+	Sessions []SessionResponse `json:"sessions"`
 }
 
 // VerifyEmailRequest represents the email verification request
@@ -86,28 +147,18 @@ type ResetPasswordRequest struct {
 // @Param request body SignupRequest true "Signup request"
 // @Success 200 {object} SignupResponse
 // @Failure 400 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
 // @Router /api/auth/signup [post]
 func (h *AuthHandler) Signup(c *fiber.Ctx) error {
 	var req SignupRequest
 
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(400).JSON(ErrorResponse{
-			Error: "Invalid request body",
-		})
-	}
-
-	if req.Email == "" || req.Password == "" || req.Username == "" {
-		return c.Status(400).JSON(ErrorResponse{
-			Error: "Email, password, and username are required",
-		})
+	if err := parseBody(c, &req); err != nil {
+		return err
 	}
 
 	user, err := h.authService.CreateUser(c.Context(), req.Email, req.Password, req.Username)
 	if err != nil {
-		h.logger.Error("Failed to create user", "error", err)
-		return c.Status(400).JSON(ErrorResponse{
-			Error: err.Error(),
-		})
+		return err
 	}
 
 	return c.JSON(SignupResponse{
@@ -122,56 +173,123 @@ func (h *AuthHandler) Signup(c *fiber.Ctx) error {
 
 // Signin handles user authentication
 // @Summary User login
-// @Description Authenticate user and return access token
+// @Description Authenticate user and return access and refresh tokens. In cookie mode (AUTH_COOKIE_MODE) they are set as httpOnly cookies instead, along with a csrf_token cookie whose value state-changing requests must send in X-CSRF-Token. Signing in to a deactivated account within the grace period reactivates it.
 // @Tags Authentication
 // @Accept json
 // @Produce json
 // @Param request body SigninRequest true "Signin request"
 // @Success 200 {object} SigninResponse
 // @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
 // @Router /api/auth/signin [post]
 func (h *AuthHandler) Signin(c *fiber.Ctx) error {
 	var req SigninRequest
 
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(400).JSON(ErrorResponse{
-			Error: "Invalid request body",
-		})
+	if err := parseBody(c, &req); err != nil {
+		return err
 	}
 
-	if req.Email == "" || req.Password == "" {
-		return c.Status(400).JSON(ErrorResponse{
-			Error: "Email and password are required",
-		})
+	// Copied, since the login is recorded after the request's buffers are reused
+	client := auth.ClientInfo{
+		IP:        utils.CopyString(middleware.ClientIP(c)),
+		UserAgent: utils.CopyString(c.Get(fiber.HeaderUserAgent)),
 	}
 
-	user, token, err := h.authService.SignIn(c.Context(), req.Email, req.Password)
+	user, tokens, err := h.authService.SignIn(c.Context(), req.Email, req.Password, client)
 	if err != nil {
-		h.logger.Error("Failed to sign in", "error", err)
-		return c.Status(401).JSON(ErrorResponse{
-			Error: err.Error(),
-		})
+		return err
 	}
 
-	return c.JSON(SigninResponse{
+	resp := SigninResponse{
 		User: UserResponse{
 			ID:    user.ID.String(),
 			Email: user.Email,
 		},
-		AccessToken: token,
-		Message:     "Signed in successfully",
-	})
+		Message: "Signed in successfully",
+	}
+	if h.cookies.Enabled {
+		if err := h.setAuthCookies(c, tokens); err != nil {
+			return httperr.Internal("Failed to sign in", err)
+		}
+	} else {
+		resp.AccessToken = tokens.AccessToken
+		resp.RefreshToken = tokens.RefreshToken
+	}
+
+	return c.JSON(resp)
+}
+
+// Refresh issues a new access token
+// @Summary Refresh access token
+// @Description Issue a new access token for the session of a refresh token. In cookie mode the refresh token cookie is used and the new access token is set as a cookie.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param request body RefreshRequest false "Refresh request; not needed in cookie mode"
+// @Success 200 {object} RefreshResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Router /api/auth/refresh [post]
+func (h *AuthHandler) Refresh(c *fiber.Ctx) error {
+	var refreshToken string
+	if h.cookies.Enabled {
+		refreshToken = c.Cookies(auth.RefreshTokenCookie)
+	}
+	if refreshToken == "" && len(c.Body()) > 0 {
+		var req RefreshRequest
+		if err := parseBody(c, &req); err != nil {
+			return err
+		}
+		refreshToken = req.RefreshToken
+	}
+	if refreshToken == "" {
+		return httperr.BadRequest("Refresh token is required")
+	}
+
+	_, accessToken, err := h.authService.RefreshSession(c.Context(), refreshToken)
+	if err != nil {
+		if isAuthError(err) {
+			return err
+		}
+		return httperr.Internal("Failed to refresh token", err)
+	}
+
+	resp := RefreshResponse{Message: "Token refreshed successfully"}
+	if h.cookies.Enabled {
+		h.setCookie(c, auth.AccessTokenCookie, accessToken, h.cookies.AccessTTL, true)
+	} else {
+		resp.AccessToken = accessToken
+	}
+	return c.JSON(resp)
 }
 
 // Signout handles user logout
 // @Summary User logout
-// @Description Sign out the current user
+// @Description Sign out the current user. The access token is rejected from then on, before it expires, and its session ends. Expired tokens are accepted so clients can always sign out. In cookie mode the cookies are cleared.
 // @Tags Authentication
 // @Produce json
 // @Security BearerAuth
 // @Success 200 {object} map[string]string
+// @Failure 401 {object} ErrorResponse
 // @Router /api/auth/signout [post]
 func (h *AuthHandler) Signout(c *fiber.Ctx) error {
+	token := bearerToken(c)
+	if token == "" && h.cookies.Enabled {
+		token = c.Cookies(auth.AccessTokenCookie)
+	}
+	if h.cookies.Enabled {
+		h.clearAuthCookies(c)
+	}
+
+	if token != "" {
+		if err := h.authService.RevokeAccessToken(c.Context(), token); err != nil {
+			if isAuthError(err) {
+				return err
+			}
+			return httperr.Internal("Failed to sign out", err)
+		}
+	}
+
 	return c.JSON(fiber.Map{
 		"message": "Signed out successfully",
 	})
@@ -190,9 +308,7 @@ func (h *AuthHandler) Me(c *fiber.Ctx) error {
 	// Get user directly from Fiber context locals
 	user, ok := c.Locals("user").(*auth.User)
 	if !ok {
-		return c.Status(401).JSON(ErrorResponse{
-			Error: "Not authenticated",
-		})
+		return httperr.Unauthenticated("Not authenticated")
 	}
 
 	return c.JSON(fiber.Map{
@@ -200,10 +316,231 @@ func (h *AuthHandler) Me(c *fiber.Ctx) error {
 			ID:       user.ID.String(),
 			Email:    user.Email,
 			Username: user.Username,
+			Version:  user.Version,
 		},
 	})
 }
 
+// Deactivate deactivates the current user's account
+// @Summary Deactivate account
+// @Description Deactivate the current account and sign out of all sessions. Signing in again within 30 days reactivates it.
+// @Tags Authentication
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]string
+// @Failure 401 {object} ErrorResponse
+// @Router /api/auth/me/deactivate [post]
+func (h *AuthHandler) Deactivate(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*auth.User)
+	if !ok {
+		return httperr.Unauthenticated("Not authenticated")
+	}
+
+	if err := h.authService.DeleteUser(c.Context(), user.ID); err != nil {
+		return httperr.Internal("Failed to deactivate account", err)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Account deactivated. Sign in within 30 days to reactivate it",
+	})
+}
+
+// ChangePassword changes the current user's password
+// @Summary Change password
+// @Description Change the current user's password. The current password must be given; recently used passwords are rejected with code PASSWORD_REUSED.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param request body ChangePasswordRequest true "Password change request"
+// @Security BearerAuth
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 429 {object} ErrorResponse
+// @Router /api/auth/change-password [post]
+func (h *AuthHandler) ChangePassword(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*auth.User)
+	if !ok {
+		return httperr.Unauthenticated("Not authenticated")
+	}
+
+	var req ChangePasswordRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	if err := h.authService.ChangePassword(c.Context(), user.ID, req.CurrentPassword, req.NewPassword); err != nil {
+		if isAuthError(err) {
+			return err
+		}
+		return httperr.Internal("Failed to change password", err)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Password changed successfully",
+	})
+}
+
+// ChangeEmail starts changing the current user's email
+// @Summary Change email
+// @Description Send a confirmation link to a new email address. The current password must be given. The account keeps its current email until the link is confirmed; a new request invalidates earlier links. Addresses already in use are rejected with code EMAIL_TAKEN.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param request body ChangeEmailRequest true "Email change request"
+// @Security BearerAuth
+// @Success 202 {object} map[string]string
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 429 {object} ErrorResponse
+// @Router /api/auth/me/email [post]
+func (h *AuthHandler) ChangeEmail(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*auth.User)
+	if !ok {
+		return httperr.Unauthenticated("Not authenticated")
+	}
+
+	var req ChangeEmailRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	newEmail := strings.ToLower(strings.TrimSpace(req.NewEmail))
+	if err := h.authService.RequestEmailChange(c.Context(), user.ID, req.Password, newEmail); err != nil {
+		if isAuthError(err) {
+			return err
+		}
+		return httperr.Internal("Failed to request email change", err)
+	}
+
+	return c.Status(202).JSON(fiber.Map{
+		"message": "Check your new email for a confirmation link",
+	})
+}
+
+// ConfirmEmailChange completes an email change
+// @Summary Confirm email change
+// @Description Replace the account's email with the new address using the token sent to it
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param request body ConfirmEmailChangeRequest true "Email change confirmation"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /api/auth/confirm-email-change [post]
+func (h *AuthHandler) ConfirmEmailChange(c *fiber.Ctx) error {
+	var req ConfirmEmailChangeRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	if err := h.authService.ConfirmEmailChange(c.Context(), req.Token); err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Email changed successfully",
+	})
+}
+
+// GetSessions lists the current user's active sessions
+// @Summary List sessions
+// @Description List the current user's signed-in sessions, newest first, with the IP address and user agent they signed in from
+// @Tags Authentication
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} SessionListResponse
+// @Failure 401 {object} ErrorResponse
+// @Router /api/auth/sessions [get]
+func (h *AuthHandler) GetSessions(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*auth.User)
+	if !ok {
+		return httperr.Unauthenticated("Not authenticated")
+	}
+
+	sessions, err := h.authService.ListSessions(c.Context(), user.ID)
+	if err != nil {
+		return httperr.Internal("Failed to get sessions", err)
+	}
+
+	items := make([]SessionResponse, 0, len(sessions))
+	for _, s := range sessions {
+		items = append(items, SessionResponse{
+			ID:        s.ID.String(),
+			IPAddress: s.IPAddress,
+			UserAgent: s.UserAgent,
+			CreatedAt: s.CreatedAt.UTC().Format(time.RFC3339),
+			ExpiresAt: s.ExpiresAt.UTC().Format(time.RFC3339),
+			Current:   s.ID == user.SessionID,
+		})
+	}
+
+	return c.JSON(SessionListResponse{
+		Sessions: items,
+	})
+}
+
+// RevokeSession signs out one of the current user's sessions
+// @Summary Revoke session
+// @Description Sign out one of the current user's sessions. Its tokens stop working immediately.
+// @Tags Authentication
+// @Produce json
+// @Param id path string true "Session ID"
+// @Security BearerAuth
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/auth/sessions/{id} [delete]
+func (h *AuthHandler) RevokeSession(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*auth.User)
+	if !ok {
+		return httperr.Unauthenticated("Not authenticated")
+	}
+
+	sessionID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return httperr.BadRequest("Invalid session ID")
+	}
+
+	if err := h.authService.RevokeSession(c.Context(), user.ID, sessionID); err != nil {
+		if isAuthError(err) {
+			return err
+		}
+		return httperr.Internal("Failed to revoke session", err, "session_id", sessionID)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Session revoked",
+	})
+}
+
+// RevokeOtherSessions signs out every session but the current one
+// @Summary Revoke other sessions
+// @Description Sign out every session of the current user except the one making this request
+// @Tags Authentication
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]string
+// @Failure 401 {object} ErrorResponse
+// @Router /api/auth/sessions [delete]
+func (h *AuthHandler) RevokeOtherSessions(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*auth.User)
+	if !ok {
+		return httperr.Unauthenticated("Not authenticated")
+	}
+
+	if err := h.authService.RevokeOtherSessions(c.Context(), user.ID, user.SessionID); err != nil {
+		return httperr.Internal("Failed to revoke sessions", err)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Other sessions revoked",
+	})
+}
+
 // VerifyEmail handles email verification
 // @Summary Verify email address
 // @Description Verify user's email address using verification token
@@ -216,17 +553,12 @@ func (h *AuthHandler) Me(c *fiber.Ctx) error {
 // @Router /api/auth/verify-email [post]
 func (h *AuthHandler) VerifyEmail(c *fiber.Ctx) error {
 	var req VerifyEmailRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(400).JSON(ErrorResponse{
-			Error: "Invalid request body",
-		})
+	if err := parseBody(c, &req); err != nil {
+		return err
 	}
 
 	if err := h.authService.VerifyEmail(c.Context(), req.Token); err != nil {
-		h.logger.Error("Failed to verify email", "error", err)
-		return c.Status(400).JSON(ErrorResponse{
-			Error: err.Error(),
-		})
+		return err
 	}
 
 	return c.JSON(fiber.Map{
@@ -236,20 +568,19 @@ func (h *AuthHandler) VerifyEmail(c *fiber.Ctx) error {
 
 // RequestPasswordReset handles password reset request
 // @Summary Request password reset
-// @Description Send password reset email to user
+// @Description Send password reset email to user. Each address can request one reset every two minutes; a new link invalidates the ones sent before it.
 // @Tags Authentication
 // @Accept json
 // @Produce json
 // @Param request body RequestPasswordResetRequest true "Password reset request"
 // @Success 200 {object} map[string]string
 // @Failure 400 {object} ErrorResponse
+// @Failure 429 {object} ErrorResponse
 // @Router /api/auth/request-password-reset [post]
 func (h *AuthHandler) RequestPasswordReset(c *fiber.Ctx) error {
 	var req RequestPasswordResetRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(400).JSON(ErrorResponse{
-			Error: "Invalid request body",
-		})
+	if err := parseBody(c, &req); err != nil {
+		return err
 	}
 
 	if err := h.authService.RequestPasswordReset(c.Context(), req.Email); err != nil {
@@ -265,9 +596,29 @@ func (h *AuthHandler) RequestPasswordReset(c *fiber.Ctx) error {
 	})
 }
 
+// bearerToken returns the token of a "Bearer" Authorization header, or ""
+func bearerToken(c *fiber.Ctx) string {
+	token, ok := strings.CutPrefix(c.Get("Authorization"), "Bearer ")
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// PasswordResetEmail identifies password reset requests by the normalized
+// email in their body, for rate limiting per address. It returns "" when the
+// body carries no email.
+func PasswordResetEmail(c *fiber.Ctx) string {
+	var req RequestPasswordResetRequest
+	if err := json.Unmarshal(c.Body(), &req); err != nil {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(req.Email))
+}
+
 // ResetPassword handles password reset
 // @Summary Reset password
-// @Description Reset user's password using reset token
+// @Description Reset user's password using reset token. All of the user's sessions are signed out, and an access token sent with the request is revoked. Recently used passwords are rejected with code PASSWORD_REUSED.
 // @Tags Authentication
 // @Accept json
 // @Produce json
@@ -277,17 +628,20 @@ func (h *AuthHandler) RequestPasswordReset(c *fiber.Ctx) error {
 // @Router /api/auth/reset-password [post]
 func (h *AuthHandler) ResetPassword(c *fiber.Ctx) error {
 	var req ResetPasswordRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(400).JSON(ErrorResponse{
-			Error: "Invalid request body",
-		})
+	if err := parseBody(c, &req); err != nil {
+		return err
 	}
 
 	if err := h.authService.ResetPassword(c.Context(), req.Token, req.Password); err != nil {
-		h.logger.Error("Failed to reset password", "error", err)
-		return c.Status(400).JSON(ErrorResponse{
-			Error: err.Error(),
-		})
+		return err
+	}
+
+	// Signing out every session already rejects its tokens; this also covers
+	// tokens issued before sessions had IDs
+	if token := bearerToken(c); token != "" {
+		if err := h.authService.RevokeAccessToken(c.Context(), token); err != nil && !isAuthError(err) {
+			h.logger.Error("Failed to revoke access token", "error", err)
+		}
 	}
 
 	return c.JSON(fiber.Map{
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"time"
+
+	"fowergram-backend/pkg/auth"
+	"fowergram-backend/pkg/middleware"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// AuthCookieConfig configures cookie mode, in which sign in sets the tokens as
+// Secure, httpOnly cookies for browser clients instead of returning them, so
+// scripts on the page can't read them
+type AuthCookieConfig struct {
+	Enabled     bool
+	Domain      string        // Empty for the API's own host
+	SameSite    string        // fiber.CookieSameSiteLaxMode, StrictMode or NoneMode
+	AccessTTL   time.Duration // Lifetime of the access token cookie
+	RefreshTTL  time.Duration // Lifetime of the refresh token and CSRF cookies
+	RefreshPath string        // The refresh route, the only path the refresh token cookie is sent on
+}
+
+// setAuthCookies sets the access and refresh token cookies, and a new CSRF
+// token cookie for the client to echo in the CSRF header
+func (h *AuthHandler) setAuthCookies(c *fiber.Ctx, tokens *auth.Tokens) error {
+	csrfToken, err := newCSRFToken()
+	if err != nil {
+		return err
+	}
+
+	h.setCookie(c, auth.AccessTokenCookie, tokens.AccessToken, h.cookies.AccessTTL, true)
+	h.setCookie(c, auth.RefreshTokenCookie, tokens.RefreshToken, h.cookies.RefreshTTL, true)
+	h.setCookie(c, middleware.CSRFCookie, csrfToken, h.cookies.RefreshTTL, false)
+	return nil
+}
+
+// cookiePath returns the path a cookie is sent on. The long-lived refresh
+// token is only sent to the refresh route, not on every request.
+func (h *AuthHandler) cookiePath(name string) string {
+	if name == auth.RefreshTokenCookie {
+		return h.cookies.RefreshPath
+	}
+	return "/"
+}
+
+// clearAuthCookies expires every cookie setAuthCookies sets. The refresh
+// token cookie isn't sent to sign out, so it's cleared without being read;
+// revoking the access token's session revokes the refresh token too.
+func (h *AuthHandler) clearAuthCookies(c *fiber.Ctx) {
+	for _, name := range []string{auth.AccessTokenCookie, auth.RefreshTokenCookie, middleware.CSRFCookie} {
+		c.Cookie(&fiber.Cookie{
+			Name:     name,
+			Path:     h.cookiePath(name),
+			Domain:   h.cookies.Domain,
+			Expires:  time.Unix(0, 0),
+			MaxAge:   -1,
+			Secure:   true,
+			SameSite: h.cookies.SameSite,
+		})
+	}
+}
+
+func (h *AuthHandler) setCookie(c *fiber.Ctx, name, value string, ttl time.Duration, httpOnly bool) {
+	c.Cookie(&fiber.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     h.cookiePath(name),
+		Domain:   h.cookies.Domain,
+		MaxAge:   int(ttl.Seconds()),
+		Expires:  time.Now().Add(ttl),
+		Secure:   true,
+		HTTPOnly: httpOnly,
+		SameSite: h.cookies.SameSite,
+	})
+}
+
+// newCSRFToken returns a random token for the double-submit CSRF check
+func newCSRFToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
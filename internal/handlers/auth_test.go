@@ -0,0 +1,261 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"fowergram-backend/pkg/auth"
+	"fowergram-backend/pkg/errreport"
+	"fowergram-backend/pkg/httperr"
+	"fowergram-backend/pkg/logger"
+	"fowergram-backend/pkg/middleware"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// fakeAuthService records the addresses password resets are requested for
+// and the access tokens revoked, and signs alice in with fixed tokens. Other
+// methods are left to the embedded nil AuthService.
+type fakeAuthService struct {
+	auth.AuthService
+
+	resets  []string
+	revoked []string
+}
+
+func (s *fakeAuthService) SignIn(ctx context.Context, email, password string, client auth.ClientInfo) (*auth.User, *auth.Tokens, error) {
+	return &auth.User{ID: uuid.New(), Email: email}, &auth.Tokens{AccessToken: "access-1", RefreshToken: "refresh-1"}, nil
+}
+
+func (s *fakeAuthService) RefreshSession(ctx context.Context, refreshToken string) (*auth.User, string, error) {
+	if refreshToken != "refresh-1" {
+		return nil, "", auth.ErrInvalidToken
+	}
+	return &auth.User{ID: uuid.New()}, "access-2", nil
+}
+
+func (s *fakeAuthService) RevokeAccessToken(ctx context.Context, accessToken string) error {
+	s.revoked = append(s.revoked, accessToken)
+	return nil
+}
+
+func (s *fakeAuthService) RequestPasswordReset(ctx context.Context, email string) error {
+	s.resets = append(s.resets, email)
+	return nil
+}
+
+func TestPasswordResetThrottle(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	// Limited as cmd/server limits password resets
+	limiter := middleware.NewRateLimiter(middleware.RateLimiterConfig{
+		RedisClient: client,
+		MaxRequests: 1,
+		Window:      2 * time.Minute,
+		KeyPrefix:   "rate_limit:password_reset",
+		KeyFunc:     PasswordResetEmail,
+	})
+	service := &fakeAuthService{}
+	handler := NewAuthHandler(service, nil, AuthCookieConfig{}, logger.NewZapLogger())
+
+	app := fiber.New(fiber.Config{ErrorHandler: httperr.Handler(logger.NewZapLogger(), errreport.Nop())})
+	app.Post("/request-password-reset", limiter.Middleware(), handler.RequestPasswordReset)
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{name: "first request", body: `{"email": "alice@example.com"}`, wantStatus: fiber.StatusOK},
+		{name: "again right away", body: `{"email": "alice@example.com"}`, wantStatus: fiber.StatusTooManyRequests},
+		{name: "same address spelled differently", body: `{"email": " Alice@Example.com"}`, wantStatus: fiber.StatusTooManyRequests},
+		{name: "another address", body: `{"email": "bob@example.com"}`, wantStatus: fiber.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/request-password-reset", strings.NewReader(tt.body))
+			req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantStatus == fiber.StatusTooManyRequests && resp.Header.Get(fiber.HeaderRetryAfter) == "" {
+				t.Errorf("throttled without a Retry-After")
+			}
+		})
+	}
+
+	if want := []string{"alice@example.com", "bob@example.com"}; !slices.Equal(service.resets, want) {
+		t.Errorf("resets requested for %v, want %v", service.resets, want)
+	}
+}
+
+func TestAuthTokenModes(t *testing.T) {
+	cookieMode := AuthCookieConfig{
+		Enabled:     true,
+		SameSite:    fiber.CookieSameSiteStrictMode,
+		AccessTTL:   15 * time.Minute,
+		RefreshTTL:  24 * time.Hour,
+		RefreshPath: "/refresh",
+	}
+
+	tests := []struct {
+		name    string
+		cookies AuthCookieConfig
+	}{
+		{name: "header mode"},
+		{name: "cookie mode", cookies: cookieMode},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &fakeAuthService{}
+			handler := NewAuthHandler(service, nil, tt.cookies, logger.NewZapLogger())
+			app := fiber.New(fiber.Config{ErrorHandler: httperr.Handler(logger.NewZapLogger(), errreport.Nop())})
+			app.Post("/signin", handler.Signin)
+			app.Post("/refresh", handler.Refresh)
+			app.Post("/signout", handler.Signout)
+
+			// post sends body, with the cookies the client holds in cookie mode,
+			// returning the response's body and cookies
+			jar := make(map[string]string)
+			post := func(t *testing.T, path, body, bearer string) (map[string]interface{}, map[string]*http.Cookie) {
+				t.Helper()
+				req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+				if body != "" {
+					req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+				}
+				if bearer != "" {
+					req.Header.Set(fiber.HeaderAuthorization, "Bearer "+bearer)
+				}
+				for name, value := range jar {
+					req.AddCookie(&http.Cookie{Name: name, Value: value})
+				}
+				resp, err := app.Test(req, -1)
+				if err != nil {
+					t.Fatalf("request failed: %v", err)
+				}
+				defer resp.Body.Close()
+				if resp.StatusCode != fiber.StatusOK {
+					t.Fatalf("status = %d, want 200", resp.StatusCode)
+				}
+
+				var decoded map[string]interface{}
+				if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+					t.Fatalf("decoding the response: %v", err)
+				}
+				set := make(map[string]*http.Cookie)
+				for _, cookie := range resp.Cookies() {
+					set[cookie.Name] = cookie
+					if cookie.MaxAge < 0 || (!cookie.Expires.IsZero() && cookie.Expires.Before(time.Now())) {
+						delete(jar, cookie.Name)
+					} else {
+						jar[cookie.Name] = cookie.Value
+					}
+				}
+				return decoded, set
+			}
+
+			body, cookies := post(t, "/signin", `{"email": "alice@example.com", "password": "secret"}`, "")
+			if !tt.cookies.Enabled {
+				if body["accessToken"] != "access-1" || body["refreshToken"] != "refresh-1" || len(cookies) != 0 {
+					t.Errorf("signed in with %v and cookies %v, want the tokens in the body only", body, cookies)
+				}
+			} else {
+				if _, ok := body["accessToken"]; ok {
+					t.Errorf("signed in with %v, want the tokens left out", body)
+				}
+				if _, ok := body["refreshToken"]; ok {
+					t.Errorf("signed in with %v, want the tokens left out", body)
+				}
+				want := map[string]struct {
+					value    string
+					httpOnly bool
+					maxAge   time.Duration
+					path     string
+				}{
+					auth.AccessTokenCookie:  {"access-1", true, cookieMode.AccessTTL, "/"},
+					auth.RefreshTokenCookie: {"refresh-1", true, cookieMode.RefreshTTL, "/refresh"}, // Not sent on every request
+					middleware.CSRFCookie:   {"", false, cookieMode.RefreshTTL, "/"},                // Random, read by the page's scripts
+				}
+				for name, w := range want {
+					cookie, ok := cookies[name]
+					if !ok {
+						t.Errorf("%s cookie not set", name)
+						continue
+					}
+					if (w.value != "" && cookie.Value != w.value) || cookie.Value == "" {
+						t.Errorf("%s = %q, want %q", name, cookie.Value, w.value)
+					}
+					if cookie.HttpOnly != w.httpOnly || !cookie.Secure || cookie.SameSite != http.SameSiteStrictMode {
+						t.Errorf("%s has httpOnly %v, secure %v and SameSite %v; want httpOnly %v, secure and strict", name, cookie.HttpOnly, cookie.Secure, cookie.SameSite, w.httpOnly)
+					}
+					if time.Duration(cookie.MaxAge)*time.Second != w.maxAge {
+						t.Errorf("%s lasts %ds, want %v", name, cookie.MaxAge, w.maxAge)
+					}
+					if cookie.Path != w.path {
+						t.Errorf("%s path = %q, want %q", name, cookie.Path, w.path)
+					}
+				}
+			}
+
+			// The refresh token comes from the body, or the cookie in cookie mode
+			refreshBody := `{"refreshToken": "refresh-1"}`
+			if tt.cookies.Enabled {
+				refreshBody = ""
+			}
+			body, cookies = post(t, "/refresh", refreshBody, "")
+			if !tt.cookies.Enabled {
+				if body["accessToken"] != "access-2" || len(cookies) != 0 {
+					t.Errorf("refreshed with %v and cookies %v, want the new token in the body", body, cookies)
+				}
+			} else if _, ok := body["accessToken"]; ok || cookies[auth.AccessTokenCookie] == nil || cookies[auth.AccessTokenCookie].Value != "access-2" {
+				t.Errorf("refreshed with %v and cookies %v, want the new token in the cookie", body, cookies)
+			}
+
+			// Signing out revokes the header's token, or the cookie's, and
+			// clears the cookies
+			bearer := ""
+			if !tt.cookies.Enabled {
+				bearer = "access-2"
+			}
+			_, cookies = post(t, "/signout", "", bearer)
+			if !slices.Equal(service.revoked, []string{"access-2"}) {
+				t.Errorf("revoked %v, want the current access token", service.revoked)
+			}
+			if !tt.cookies.Enabled {
+				if len(cookies) != 0 {
+					t.Errorf("signed out setting cookies %v, want none", cookies)
+				}
+				return
+			}
+			// Each is cleared on the path it was set on, or the browser keeps it
+			paths := map[string]string{auth.AccessTokenCookie: "/", auth.RefreshTokenCookie: "/refresh", middleware.CSRFCookie: "/"}
+			for name, path := range paths {
+				if cookie, ok := cookies[name]; !ok || cookie.Value != "" || cookie.Expires.IsZero() || cookie.Expires.After(time.Now()) || cookie.Path != path {
+					t.Errorf("%s cookie = %+v, want it cleared on %s", name, cookie, path)
+				}
+			}
+			if len(jar) != 0 {
+				t.Errorf("client left holding cookies %v", jar)
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"fowergram-backend/internal/domain/comment"
+	"fowergram-backend/internal/domain/moderation"
+	"fowergram-backend/internal/domain/post"
+	"fowergram-backend/pkg/auth"
+	"fowergram-backend/pkg/httperr"
+	"fowergram-backend/pkg/logger"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type CommentHandler struct {
+	commentService comment.Service
+	logger         logger.Logger
+}
+
+func NewCommentHandler(commentService comment.Service, logger logger.Logger) *CommentHandler {
+	return &CommentHandler{
+		commentService: commentService,
+		logger:         logger,
+	}
+}
+
+// CreateCommentRequest represents the request to create a comment or reply
+type CreateCommentRequest struct {
+	Body     string  `json:"body" validate:"required,min=1,max=2200"`
+	ParentID *string `json:"parent_id,omitempty"`
+}
+
+// CommentResponse represents a comment in API responses
+type CommentResponse struct {
+	ID         string  `json:"id"`
+	PostID     string  `json:"post_id"`
+	AuthorID   string  `json:"author_id"`
+	Username   string  `json:"username"`
+	ParentID   *string `json:"parent_id,omitempty"`
+	Body       string  `json:"body"`
+	ReplyCount int     `json:"reply_count"`
+	CreatedAt  string  `json:"created_at"`
+}
+
+// CommentListResponse represents a page of comments ordered oldest-first
+type CommentListResponse struct {
+	Comments   []CommentResponse `json:"comments"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+	HasMore    bool              `json:"has_more"`
+}
+
+// CreateComment adds a comment to a post
+// @Summary Create comment
+// @Description Comment on a post, or reply to a comment by setting parent_id. Bodies the moderator rejects are answered with 400 and code CONTENT_REJECTED.
+// @Tags Comments
+// @Accept json
+// @Produce json
+// @Param id path string true "Post ID"
+// @Param request body CreateCommentRequest true "Comment creation request"
+// @Success 201 {object} CommentResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/posts/{id}/comments [post]
+func (h *CommentHandler) CreateComment(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*auth.User)
+	if !ok {
+		return httperr.Unauthenticated("Not authenticated")
+	}
+
+	postID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return httperr.BadRequest("Invalid post ID")
+	}
+
+	var req CreateCommentRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	input := comment.CreateCommentInput{Body: req.Body}
+	if req.ParentID != nil {
+		parentID, err := uuid.Parse(*req.ParentID)
+		if err != nil {
+			return httperr.BadRequest("Invalid parent comment ID")
+		}
+		input.ParentID = &parentID
+	}
+
+	created, err := h.commentService.CreateComment(c.Context(), postID, user.ID, input)
+	if err != nil {
+		return commentError("Failed to create comment", err)
+	}
+
+	return c.Status(201).JSON(toCommentResponse(created))
+}
+
+// GetComments lists top-level comments on a post
+// @Summary Get post comments
+// @Description Retrieve top-level comments on a post, oldest first, with reply counts. Once the author turns comments off, only they still see them.
+// @Tags Comments
+// @Produce json
+// @Param id path string true "Post ID"
+// @Param cursor query string false "Cursor from a previous page"
+// @Param limit query int false "Page size" default(10)
+// @Success 200 {object} CommentListResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/posts/{id}/comments [get]
+func (h *CommentHandler) GetComments(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*auth.User)
+	if !ok {
+		return httperr.Unauthenticated("Not authenticated")
+	}
+
+	postID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return httperr.BadRequest("Invalid post ID")
+	}
+
+	page, err := h.commentService.ListComments(c.Context(), postID, user.ID, c.Query("cursor"), parseLimit(c))
+	if err != nil {
+		return commentError("Failed to get comments", err)
+	}
+
+	return c.JSON(toCommentListResponse(page))
+}
+
+// GetReplies lists replies to a comment
+// @Summary Get comment replies
+// @Description Retrieve replies to a top-level comment, oldest first
+// @Tags Comments
+// @Produce json
+// @Param id path string true "Comment ID"
+// @Param cursor query string false "Cursor from a previous page"
+// @Param limit query int false "Page size" default(10)
+// @Success 200 {object} CommentListResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/comments/{id}/replies [get]
+func (h *CommentHandler) GetReplies(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*auth.User)
+	if !ok {
+		return httperr.Unauthenticated("Not authenticated")
+	}
+
+	commentID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return httperr.BadRequest("Invalid comment ID")
+	}
+
+	page, err := h.commentService.ListReplies(c.Context(), commentID, user.ID, c.Query("cursor"), parseLimit(c))
+	if err != nil {
+		return commentError("Failed to get replies", err)
+	}
+
+	return c.JSON(toCommentListResponse(page))
+}
+
+// DeleteComment deletes a comment and its replies
+// @Summary Delete comment
+// @Description Delete a comment; allowed for the comment author and the post owner
+// @Tags Comments
+// @Produce json
+// @Param id path string true "Comment ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/comments/{id} [delete]
+// @Router /api/posts/{id}/comments/{commentId} [delete]
+func (h *CommentHandler) DeleteComment(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*auth.User)
+	if !ok {
+		return httperr.Unauthenticated("Not authenticated")
+	}
+
+	// Nested routes carry the comment ID as :commentId, top-level ones as :id
+	commentID, err := uuid.Parse(c.Params("commentId", c.Params("id")))
+	if err != nil {
+		return httperr.BadRequest("Invalid comment ID")
+	}
+
+	if err := h.commentService.DeleteComment(c.Context(), commentID, user.ID); err != nil {
+		return commentError("Failed to delete comment", err)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Comment deleted successfully",
+	})
+}
+
+// commentError maps comment service errors to responses
+func commentError(message string, err error) error {
+	switch {
+	case errors.Is(err, post.ErrPostNotFound):
+		return httperr.NotFound("Post not found")
+	case errors.Is(err, comment.ErrCommentNotFound):
+		return httperr.NotFound("Comment not found")
+	case errors.Is(err, comment.ErrForbidden):
+		return httperr.Forbidden(err.Error())
+	case errors.Is(err, comment.ErrCommentsDisabled):
+		return httperr.New(fiber.StatusForbidden, httperr.CodeCommentsDisabled, err.Error())
+	case errors.Is(err, moderation.ErrContentRejected):
+		return httperr.New(fiber.StatusBadRequest, httperr.CodeContentRejected, err.Error())
+	case errors.Is(err, comment.ErrInvalidBody),
+		errors.Is(err, comment.ErrInvalidParent),
+		errors.Is(err, comment.ErrInvalidCursor):
+		return httperr.BadRequest(err.Error())
+	}
+
+	return httperr.Internal(message, err)
+}
+
+func toCommentResponse(cm *comment.Comment) CommentResponse {
+	resp := CommentResponse{
+		ID:         cm.ID.String(),
+		PostID:     cm.PostID.String(),
+		AuthorID:   cm.UserID.String(),
+		Username:   cm.Username,
+		Body:       cm.Body,
+		ReplyCount: cm.ReplyCount,
+		CreatedAt:  cm.CreatedAt.UTC().Format(time.RFC3339),
+	}
+	if cm.ParentID != nil {
+		parentID := cm.ParentID.String()
+		resp.ParentID = &parentID
+	}
+	return resp
+}
+
+func toCommentListResponse(page *comment.Page) CommentListResponse {
+	comments := make([]CommentResponse, 0, len(page.Comments))
+	for _, cm := range page.Comments {
+		comments = append(comments, toCommentResponse(cm))
+	}
+
+	return CommentListResponse{
+		Comments:   comments,
+		NextCursor: page.NextCursor,
+		HasMore:    page.NextCursor != "",
+	}
+}
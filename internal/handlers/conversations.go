@@ -0,0 +1,330 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"fowergram-backend/internal/domain/conversation"
+	"fowergram-backend/pkg/auth"
+	"fowergram-backend/pkg/httperr"
+	"fowergram-backend/pkg/logger"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type ConversationHandler struct {
+	conversationService conversation.Service
+	logger              logger.Logger
+}
+
+func NewConversationHandler(conversationService conversation.Service, logger logger.Logger) *ConversationHandler {
+	return &ConversationHandler{
+		conversationService: conversationService,
+		logger:              logger,
+	}
+}
+
+// StartConversationRequest represents the request to open a conversation
+type StartConversationRequest struct {
+	RecipientID string `json:"recipient_id" validate:"required"`
+}
+
+// SendMessageRequest represents the request to send a direct message
+type SendMessageRequest struct {
+	Body string `json:"body" validate:"required,min=1,max=1000"`
+}
+
+// MessageResponse represents a direct message in API responses
+type MessageResponse struct {
+	ID             string `json:"id"`
+	ConversationID string `json:"conversation_id"`
+	SenderID       string `json:"sender_id"`
+	Body           string `json:"body"`
+	CreatedAt      string `json:"created_at"`
+}
+
+// ConversationResponse represents a conversation as seen by the current user
+type ConversationResponse struct {
+	ID                        string           `json:"id"`
+	ParticipantID             string           `json:"participant_id"`
+	ParticipantUsername       string           `json:"participant_username"`
+	ParticipantProfilePicture string           `json:"participant_profile_picture,omitempty"`
+	LastMessage               *MessageResponse `json:"last_message,omitempty"`
+	UnreadCount               int              `json:"unread_count"`
+	LastReadAt                string           `json:"last_read_at,omitempty"`             // When the current user last read the conversation
+	ParticipantLastReadAt     string           `json:"participant_last_read_at,omitempty"` // When the other participant last read it
+	LastMessageAt             string           `json:"last_message_at"`
+	CreatedAt                 string           `json:"created_at"`
+}
+
+// ConversationListResponse represents a page of conversations
+type ConversationListResponse struct {
+	Conversations []ConversationResponse `json:"conversations"`
+	Page          int                    `json:"page"`
+	PageSize      int                    `json:"page_size"`
+	HasMore       bool                   `json:"has_more"`
+}
+
+// MessageListResponse represents a page of messages ordered newest-first
+type MessageListResponse struct {
+	Messages   []MessageResponse `json:"messages"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+	HasMore    bool              `json:"has_more"`
+}
+
+// MarkConversationReadResponse reports the current user's new read receipt
+type MarkConversationReadResponse struct {
+	LastReadAt string `json:"last_read_at"`
+}
+
+// StartConversation opens a conversation with another user
+// @Summary Start conversation
+// @Description Open a one-to-one conversation with another user, or return the existing one. The recipient only sees the conversation once a message is sent. Fails with 403 when either user has blocked the other.
+// @Tags Messages
+// @Accept json
+// @Produce json
+// @Param request body StartConversationRequest true "Recipient"
+// @Success 200 {object} ConversationResponse
+// @Success 201 {object} ConversationResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/conversations [post]
+func (h *ConversationHandler) StartConversation(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*auth.User)
+	if !ok {
+		return httperr.Unauthenticated("Not authenticated")
+	}
+
+	var req StartConversationRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	recipientID, err := uuid.Parse(req.RecipientID)
+	if err != nil {
+		return httperr.BadRequest("Invalid recipient ID")
+	}
+
+	conv, created, err := h.conversationService.StartConversation(c.Context(), user.ID, recipientID)
+	if err != nil {
+		return conversationError("Failed to start conversation", err)
+	}
+
+	status := 200
+	if created {
+		status = 201
+	}
+	return c.Status(status).JSON(toConversationResponse(conv))
+}
+
+// GetConversations lists the current user's conversations
+// @Summary Get conversations
+// @Description Retrieve the current user's conversations, most recently active first, with the last message and the number of unread messages in each
+// @Tags Messages
+// @Produce json
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(10)
+// @Success 200 {object} ConversationListResponse
+// @Failure 401 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/conversations [get]
+func (h *ConversationHandler) GetConversations(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*auth.User)
+	if !ok {
+		return httperr.Unauthenticated("Not authenticated")
+	}
+
+	page, pageSize := parsePagination(c)
+
+	// Fetch one extra row to know whether another page exists
+	conversations, err := h.conversationService.ListConversations(c.Context(), user.ID, pageSize+1, (page-1)*pageSize)
+	if err != nil {
+		return httperr.Internal("Failed to get conversations", err)
+	}
+
+	hasMore := len(conversations) > pageSize
+	if hasMore {
+		conversations = conversations[:pageSize]
+	}
+
+	items := make([]ConversationResponse, 0, len(conversations))
+	for _, conv := range conversations {
+		items = append(items, toConversationResponse(conv))
+	}
+
+	return c.JSON(ConversationListResponse{
+		Conversations: items,
+		Page:          page,
+		PageSize:      pageSize,
+		HasMore:       hasMore,
+	})
+}
+
+// SendMessage sends a message in a conversation
+// @Summary Send message
+// @Description Send a text message in a conversation. Fails with 403 when either participant has blocked the other.
+// @Tags Messages
+// @Accept json
+// @Produce json
+// @Param id path string true "Conversation ID"
+// @Param request body SendMessageRequest true "Message"
+// @Success 201 {object} MessageResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/conversations/{id}/messages [post]
+func (h *ConversationHandler) SendMessage(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*auth.User)
+	if !ok {
+		return httperr.Unauthenticated("Not authenticated")
+	}
+
+	conversationID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return httperr.BadRequest("Invalid conversation ID")
+	}
+
+	var req SendMessageRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	message, err := h.conversationService.SendMessage(c.Context(), conversationID, user.ID, req.Body)
+	if err != nil {
+		return conversationError("Failed to send message", err)
+	}
+
+	return c.Status(201).JSON(toMessageResponse(message))
+}
+
+// GetMessages lists messages in a conversation
+// @Summary Get messages
+// @Description Retrieve messages in a conversation, newest first, using cursor pagination
+// @Tags Messages
+// @Produce json
+// @Param id path string true "Conversation ID"
+// @Param cursor query string false "Cursor from a previous page"
+// @Param limit query int false "Page size" default(10)
+// @Success 200 {object} MessageListResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/conversations/{id}/messages [get]
+func (h *ConversationHandler) GetMessages(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*auth.User)
+	if !ok {
+		return httperr.Unauthenticated("Not authenticated")
+	}
+
+	conversationID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return httperr.BadRequest("Invalid conversation ID")
+	}
+
+	page, err := h.conversationService.ListMessages(c.Context(), conversationID, user.ID, c.Query("cursor"), parseLimit(c))
+	if err != nil {
+		return conversationError("Failed to get messages", err)
+	}
+
+	messages := make([]MessageResponse, 0, len(page.Messages))
+	for _, m := range page.Messages {
+		messages = append(messages, toMessageResponse(m))
+	}
+
+	return c.JSON(MessageListResponse{
+		Messages:   messages,
+		NextCursor: page.NextCursor,
+		HasMore:    page.NextCursor != "",
+	})
+}
+
+// MarkConversationRead marks a conversation as read
+// @Summary Mark conversation read
+// @Description Record that the current user has read every message in the conversation so far. The other participant sees the timestamp as participant_last_read_at.
+// @Tags Messages
+// @Produce json
+// @Param id path string true "Conversation ID"
+// @Success 200 {object} MarkConversationReadResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/conversations/{id}/read [post]
+func (h *ConversationHandler) MarkConversationRead(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*auth.User)
+	if !ok {
+		return httperr.Unauthenticated("Not authenticated")
+	}
+
+	conversationID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return httperr.BadRequest("Invalid conversation ID")
+	}
+
+	readAt, err := h.conversationService.MarkRead(c.Context(), conversationID, user.ID)
+	if err != nil {
+		return conversationError("Failed to mark conversation read", err)
+	}
+
+	return c.JSON(MarkConversationReadResponse{
+		LastReadAt: readAt.UTC().Format(time.RFC3339),
+	})
+}
+
+// conversationError maps conversation service errors to responses
+func conversationError(message string, err error) error {
+	switch {
+	case isAuthError(err):
+		return err
+	case errors.Is(err, conversation.ErrConversationNotFound):
+		return httperr.NotFound("Conversation not found")
+	case errors.Is(err, conversation.ErrMessagingBlocked):
+		return httperr.Forbidden(err.Error())
+	case errors.Is(err, conversation.ErrCannotMessageSelf),
+		errors.Is(err, conversation.ErrInvalidBody),
+		errors.Is(err, conversation.ErrInvalidCursor):
+		return httperr.BadRequest(err.Error())
+	}
+
+	return httperr.Internal(message, err)
+}
+
+func toMessageResponse(m *conversation.Message) MessageResponse {
+	return MessageResponse{
+		ID:             m.ID.String(),
+		ConversationID: m.ConversationID.String(),
+		SenderID:       m.SenderID.String(),
+		Body:           m.Body,
+		CreatedAt:      m.CreatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+func toConversationResponse(conv *conversation.Conversation) ConversationResponse {
+	resp := ConversationResponse{
+		ID:                        conv.ID.String(),
+		ParticipantID:             conv.ParticipantID.String(),
+		ParticipantUsername:       conv.ParticipantUsername,
+		ParticipantProfilePicture: conv.ParticipantProfilePicture,
+		UnreadCount:               conv.UnreadCount,
+		LastMessageAt:             conv.LastMessageAt.UTC().Format(time.RFC3339),
+		CreatedAt:                 conv.CreatedAt.UTC().Format(time.RFC3339),
+	}
+	if conv.LastMessage != nil {
+		last := toMessageResponse(conv.LastMessage)
+		resp.LastMessage = &last
+	}
+	if conv.LastReadAt != nil {
+		resp.LastReadAt = conv.LastReadAt.UTC().Format(time.RFC3339)
+	}
+	if conv.ParticipantLastReadAt != nil {
+		resp.ParticipantLastReadAt = conv.ParticipantLastReadAt.UTC().Format(time.RFC3339)
+	}
+	return resp
+}
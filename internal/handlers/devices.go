@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"errors"
+	"net/url"
+	"time"
+
+	"fowergram-backend/internal/domain/device"
+	"fowergram-backend/pkg/auth"
+	"fowergram-backend/pkg/httperr"
+	"fowergram-backend/pkg/logger"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type DeviceHandler struct {
+	deviceService device.Service
+	logger        logger.Logger
+}
+
+func NewDeviceHandler(deviceService device.Service, logger logger.Logger) *DeviceHandler {
+	return &DeviceHandler{
+		deviceService: deviceService,
+		logger:        logger,
+	}
+}
+
+// RegisterDeviceRequest represents the request to register a push token
+type RegisterDeviceRequest struct {
+	Token      string `json:"token" validate:"required,max=4096"`
+	Platform   string `json:"platform" validate:"required"` // ios, android or web
+	AppVersion string `json:"app_version,omitempty" validate:"max=50"`
+}
+
+// DeviceResponse represents a registered device in API responses
+type DeviceResponse struct {
+	ID         string `json:"id"`
+	Platform   string `json:"platform"`
+	AppVersion string `json:"app_version,omitempty"`
+	CreatedAt  string `json:"created_at"`
+	UpdatedAt  string `json:"updated_at"`
+}
+
+// RegisterDevice registers a push notification token
+// @Summary Register device
+// @Description Register the APNs/FCM push token of an app install so the current user receives push notifications on it. Registering a token again updates its platform and app version; a token registered by another account moves to the current user.
+// @Tags Devices
+// @Accept json
+// @Produce json
+// @Param request body RegisterDeviceRequest true "Device registration request"
+// @Success 201 {object} DeviceResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/devices [post]
+func (h *DeviceHandler) RegisterDevice(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*auth.User)
+	if !ok {
+		return httperr.Unauthenticated("Not authenticated")
+	}
+
+	var req RegisterDeviceRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	d, err := h.deviceService.Register(c.Context(), user.ID, device.RegisterInput{
+		Token:      req.Token,
+		Platform:   req.Platform,
+		AppVersion: req.AppVersion,
+	})
+	if err != nil {
+		if errors.Is(err, device.ErrInvalidPlatform) {
+			return httperr.BadRequest(err.Error())
+		}
+		return httperr.Internal("Failed to register device", err)
+	}
+
+	return c.Status(201).JSON(DeviceResponse{
+		ID:         d.ID.String(),
+		Platform:   d.Platform,
+		AppVersion: d.AppVersion,
+		CreatedAt:  d.CreatedAt.UTC().Format(time.RFC3339),
+		UpdatedAt:  d.UpdatedAt.UTC().Format(time.RFC3339),
+	})
+}
+
+// UnregisterDevice removes a push notification token
+// @Summary Unregister device
+// @Description Stop sending push notifications to a token of the current user, for example on sign-out. The token must be URL-encoded.
+// @Tags Devices
+// @Produce json
+// @Param token path string true "Push token"
+// @Success 204
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/devices/{token} [delete]
+func (h *DeviceHandler) UnregisterDevice(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*auth.User)
+	if !ok {
+		return httperr.Unauthenticated("Not authenticated")
+	}
+
+	token, err := url.PathUnescape(c.Params("token"))
+	if err != nil || token == "" {
+		return httperr.BadRequest("Invalid device token")
+	}
+
+	if err := h.deviceService.Unregister(c.Context(), user.ID, token); err != nil {
+		if errors.Is(err, device.ErrDeviceNotFound) {
+			return httperr.NotFound("Device not found")
+		}
+		return httperr.Internal("Failed to unregister device", err)
+	}
+
+	return c.SendStatus(204)
+}
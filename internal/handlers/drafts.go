@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"errors"
+
+	"fowergram-backend/internal/domain/post"
+	"fowergram-backend/pkg/auth"
+	"fowergram-backend/pkg/httperr"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// GetDrafts lists the current user's unpublished posts
+// @Summary Get drafts
+// @Description Retrieve the current user's drafts and scheduled posts, most recently edited first. These are never shown to other users.
+// @Tags Posts
+// @Produce json
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(10)
+// @Success 200 {object} PostListResponse
+// @Failure 401 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/users/me/drafts [get]
+func (h *PostHandler) GetDrafts(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*auth.User)
+	if !ok {
+		return httperr.Unauthenticated("Not authenticated")
+	}
+
+	page, pageSize := parsePagination(c)
+
+	// Fetch one extra row to know whether another page exists
+	posts, err := h.postService.GetDrafts(c.Context(), user.ID, pageSize+1, (page-1)*pageSize)
+	if err != nil {
+		return httperr.Internal("Failed to get drafts", err)
+	}
+
+	hasMore := len(posts) > pageSize
+	if hasMore {
+		posts = posts[:pageSize]
+	}
+
+	items, err := postResponses(c.Context(), h.userService, posts)
+	if err != nil {
+		return httperr.Internal("Failed to load post authors", err)
+	}
+
+	return c.JSON(PostListResponse{
+		Posts:      items,
+		TotalCount: countOf(len(items)),
+		Page:       page,
+		PageSize:   pageSize,
+		HasMore:    hasMore,
+	})
+}
+
+// PublishPost publishes a draft or scheduled post immediately
+// @Summary Publish post
+// @Description Publish the caller's draft or scheduled post right away. The post is dated at the time of publishing.
+// @Tags Posts
+// @Produce json
+// @Param id path string true "Post ID"
+// @Success 200 {object} PostResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/posts/{id}/publish [post]
+func (h *PostHandler) PublishPost(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*auth.User)
+	if !ok {
+		return httperr.Unauthenticated("Not authenticated")
+	}
+
+	postID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return httperr.BadRequest("Invalid post ID")
+	}
+
+	p, err := h.postService.PublishPost(c.Context(), postID, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, post.ErrPostNotFound):
+			return httperr.NotFound("Post not found")
+		case errors.Is(err, post.ErrNotPostOwner):
+			return httperr.New(fiber.StatusForbidden, httperr.CodeNotPostOwner, "You can only publish your own posts")
+		case errors.Is(err, post.ErrAlreadyPublished):
+			return httperr.Conflict("Post is already published")
+		}
+		return httperr.Internal("Failed to publish post", err, "post_id", postID)
+	}
+
+	resp, err := postResponse(c.Context(), h.userService, p)
+	if err != nil {
+		return httperr.Internal("Failed to load post author", err, "post_id", p.ID)
+	}
+
+	return c.JSON(resp)
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"fowergram-backend/pkg/auth"
+	"fowergram-backend/pkg/httperr"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+// isAuthError reports whether err is an auth.AuthError, which handlers
+// return as-is for httperr.Handler to render
+func isAuthError(err error) bool {
+	var authErr *auth.AuthError
+	return errors.As(err, &authErr)
+}
+
+// FieldError describes one failed validation rule in ErrorResponse.Details
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// validate checks the `validate` struct tags on request bodies, reporting
+// fields by their JSON names
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+	// username applies the limits @-mentions share. Registering only fails
+	// on a malformed tag name.
+	if err := v.RegisterValidation("username", func(fl validator.FieldLevel) bool {
+		return auth.IsValidUsername(fl.Field().String())
+	}); err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// parseBody decodes the request body into out and validates it. Failures
+// are returned as *httperr.AppError for httperr.Handler to render.
+func parseBody(c *fiber.Ctx, out interface{}) error {
+	if err := c.BodyParser(out); err != nil {
+		return httperr.New(fiber.StatusBadRequest, httperr.CodeInvalidBody, "Invalid request body")
+	}
+
+	if err := validate.Struct(out); err != nil {
+		var invalid validator.ValidationErrors
+		if !errors.As(err, &invalid) {
+			return err
+		}
+
+		details := make([]FieldError, 0, len(invalid))
+		for _, fe := range invalid {
+			details = append(details, FieldError{
+				Field:   fe.Field(),
+				Rule:    fe.Tag(),
+				Message: fieldErrorMessage(fe),
+			})
+		}
+		return httperr.New(fiber.StatusBadRequest, httperr.CodeValidationFailed, details[0].Message).WithDetails(details)
+	}
+
+	return nil
+}
+
+// fieldErrorMessage renders a validation failure as a sentence
+func fieldErrorMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return fmt.Sprintf("%s is required", fe.Field())
+	case "email":
+		return fmt.Sprintf("%s must be a valid email address", fe.Field())
+	case "url":
+		return fmt.Sprintf("%s must be a valid URL", fe.Field())
+	case "alphanum":
+		return fmt.Sprintf("%s may only contain letters and digits", fe.Field())
+	case "username":
+		return fmt.Sprintf("%s must be %d to %d letters and digits", fe.Field(), auth.MinUsernameLength, auth.MaxUsernameLength)
+	case "uuid":
+		return fmt.Sprintf("%s must be a valid UUID", fe.Field())
+	case "min":
+		if isNumber(fe.Kind()) {
+			return fmt.Sprintf("%s must be at least %s", fe.Field(), fe.Param())
+		}
+		if isCollection(fe.Kind()) {
+			return fmt.Sprintf("%s must have at least %s items", fe.Field(), fe.Param())
+		}
+		return fmt.Sprintf("%s must be at least %s characters", fe.Field(), fe.Param())
+	case "max":
+		if isNumber(fe.Kind()) {
+			return fmt.Sprintf("%s must be at most %s", fe.Field(), fe.Param())
+		}
+		if isCollection(fe.Kind()) {
+			return fmt.Sprintf("%s must have at most %s items", fe.Field(), fe.Param())
+		}
+		return fmt.Sprintf("%s must be at most %s characters", fe.Field(), fe.Param())
+	}
+	return fmt.Sprintf("%s is invalid", fe.Field())
+}
+
+func isCollection(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return true
+	}
+	return false
+}
+
+func isNumber(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"fowergram-backend/internal/domain/post"
+	"fowergram-backend/pkg/errreport"
+	"fowergram-backend/pkg/httperr"
+	"fowergram-backend/pkg/logger"
+	"fowergram-backend/pkg/middleware"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestUsernameValidation(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		want     bool
+	}{
+		{name: "shortest", username: "abc", want: true},
+		{name: "too short", username: "ab"},
+		{name: "longer than 30", username: strings.Repeat("a", 31), want: true},
+		{name: "longest", username: strings.Repeat("a", 50), want: true},
+		{name: "too long", username: strings.Repeat("a", 51)},
+		{name: "underscore", username: "bob_smith"},
+		{name: "non-ASCII letter", username: "zoë1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signup := SignupRequest{Email: "user@example.com", Password: "password123", Username: tt.username}
+			if err := validate.Struct(signup); (err == nil) != tt.want {
+				t.Errorf("signup as %q: error = %v, want valid = %v", tt.username, err, tt.want)
+			}
+
+			update := UpdateProfileRequest{Username: &tt.username, Version: 1}
+			if err := validate.Struct(update); (err == nil) != tt.want {
+				t.Errorf("rename to %q: error = %v, want valid = %v", tt.username, err, tt.want)
+			}
+
+			// Any username someone can sign up with can be mentioned
+			got := post.ExtractMentions("hi @" + tt.username)
+			mentioned := len(got) == 1 && got[0] == tt.username
+			if mentioned != tt.want {
+				t.Errorf("@%s mentioned = %v, want %v", tt.username, mentioned, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseBody(t *testing.T) {
+	const limit = 256
+
+	// Configured as cmd/server configures the app and routes.SetupRoutes
+	// limits bodies
+	app := fiber.New(fiber.Config{
+		BodyLimit:             limit,
+		StreamRequestBody:     true,
+		ErrorHandler:          httperr.Handler(logger.NewZapLogger(), errreport.Nop()),
+		DisableStartupMessage: true,
+	})
+	app.Use(middleware.BodyLimit(limit, nil))
+	app.Post("/signup", func(c *fiber.Ctx) error {
+		var req SignupRequest
+		if err := parseBody(c, &req); err != nil {
+			return err
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+
+	// Rejected bodies are left unread, which app.Test can't recover from,
+	// so requests go to a listening server
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening: %v", err)
+	}
+	go app.Listener(listener)
+	t.Cleanup(func() { app.Shutdown() })
+	server := "http://" + listener.Addr().String()
+
+	valid := `{"email":"user@example.com","password":"password123","username":"alice"}`
+	oversized := `{"email":"user@example.com","password":"password123","username":"alice","bio":"` + strings.Repeat("a", limit) + `"}`
+
+	tests := []struct {
+		name        string
+		body        string
+		chunked     bool // Sent without a Content-Length
+		wantStatus  int
+		wantCode    string
+		wantDetails []FieldError
+	}{
+		{name: "valid", body: valid, wantStatus: fiber.StatusNoContent},
+		{name: "malformed JSON", body: `{"email":`, wantStatus: fiber.StatusBadRequest, wantCode: httperr.CodeInvalidBody},
+		{name: "wrong field type", body: `{"email":"user@example.com","password":"password123","username":5}`, wantStatus: fiber.StatusBadRequest, wantCode: httperr.CodeInvalidBody},
+		{
+			name:       "failed validation",
+			body:       `{"email":"not an email","password":"password123"}`,
+			wantStatus: fiber.StatusBadRequest,
+			wantCode:   httperr.CodeValidationFailed,
+			wantDetails: []FieldError{
+				{Field: "email", Rule: "email", Message: "email must be a valid email address"},
+				{Field: "username", Rule: "required", Message: "username is required"},
+			},
+		},
+		{name: "oversized", body: oversized, wantStatus: fiber.StatusRequestEntityTooLarge, wantCode: httperr.CodeBodyTooLarge},
+		{name: "oversized and chunked", body: oversized, chunked: true, wantStatus: fiber.StatusRequestEntityTooLarge, wantCode: httperr.CodeBodyTooLarge},
+		{name: "valid and chunked", body: valid, chunked: true, wantStatus: fiber.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, server+"/signup", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatalf("NewRequest: %v", err)
+			}
+			req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+			if tt.chunked {
+				req.ContentLength = -1
+			}
+			req.Close = true
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantCode == "" {
+				return
+			}
+
+			var got struct {
+				httperr.Response
+				Details []FieldError `json:"details"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+				t.Fatalf("decoding the error: %v", err)
+			}
+			if got.Code != tt.wantCode || got.Error == "" {
+				t.Errorf("error = %q with code %q, want a message with code %q", got.Error, got.Code, tt.wantCode)
+			}
+			if !reflect.DeepEqual(got.Details, tt.wantDetails) {
+				t.Errorf("details = %+v, want %+v", got.Details, tt.wantDetails)
+			}
+		})
+	}
+}
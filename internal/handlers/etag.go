@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+)
+
+// postETag returns a weak ETag of a post response, so it changes on edits,
+// count changes and anything else shown to the viewer. Media URLs are left
+// out, as presigned ones differ on every request.
+func postETag(resp PostResponse) (string, error) {
+	body, err := json.Marshal(withoutMediaURLs(resp))
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(body)
+	return `W/"` + hex.EncodeToString(sum[:16]) + `"`, nil
+}
+
+// withoutMediaURLs copies resp with the URLs of its media, and of its
+// original's, cleared
+func withoutMediaURLs(resp PostResponse) PostResponse {
+	if len(resp.Media) > 0 {
+		media := make([]MediaResponse, len(resp.Media))
+		for i, m := range resp.Media {
+			m.URLs = nil
+			media[i] = m
+		}
+		resp.Media = media
+	}
+
+	if resp.Original != nil && resp.Original.PostResponse != nil {
+		original := withoutMediaURLs(*resp.Original.PostResponse)
+		resp.Original = &OriginalPostResponse{PostResponse: &original}
+	}
+
+	return resp
+}
+
+// etagMatches reports whether an If-None-Match header lists etag, comparing
+// weakly as RFC 9110 asks of If-None-Match
+func etagMatches(ifNoneMatch, etag string) bool {
+	if strings.TrimSpace(ifNoneMatch) == "*" {
+		return true
+	}
+
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"fowergram-backend/internal/domain/post"
+	"fowergram-backend/pkg/auth"
+	"fowergram-backend/pkg/logger"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+func TestGetPostETag(t *testing.T) {
+	alice := &auth.User{ID: uuid.New(), Username: "alice"}
+	likes := 3
+	p := &post.Post{ID: uuid.New(), UserID: alice.ID, Title: "Tulips", LikesCount: &likes, UpdatedAt: time.Now()}
+	app := postsApp(NewPostHandler(&fakePostService{posts: []*post.Post{p}}, &fakeUserService{users: []*auth.User{alice}}, logger.NewZapLogger()), alice)
+
+	get := func(t *testing.T, ifNoneMatch string) (*http.Response, string) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/posts/"+p.ID.String(), nil)
+		if ifNoneMatch != "" {
+			req.Header.Set(fiber.HeaderIfNoneMatch, ifNoneMatch)
+		}
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("reading the body: %v", err)
+		}
+		if resp.Header.Get(fiber.HeaderCacheControl) != "private, no-cache" {
+			t.Errorf("Cache-Control = %q, want private, no-cache", resp.Header.Get(fiber.HeaderCacheControl))
+		}
+		return resp, string(body)
+	}
+
+	first, _ := get(t, "")
+	etag := first.Header.Get(fiber.HeaderETag)
+	if first.StatusCode != fiber.StatusOK || etag == "" {
+		t.Fatalf("status = %d with ETag %q, want 200 with one", first.StatusCode, etag)
+	}
+
+	unchanged := []struct {
+		name        string
+		ifNoneMatch string
+	}{
+		{name: "matching", ifNoneMatch: etag},
+		{name: "among others", ifNoneMatch: `"other", ` + etag},
+		{name: "strong form", ifNoneMatch: etag[len("W/"):]},
+		{name: "any", ifNoneMatch: "*"},
+	}
+	for _, tt := range unchanged {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := get(t, tt.ifNoneMatch)
+			if resp.StatusCode != fiber.StatusNotModified || body != "" {
+				t.Errorf("status = %d with body %q, want 304 and none", resp.StatusCode, body)
+			}
+			if resp.Header.Get(fiber.HeaderETag) != etag {
+				t.Errorf("ETag = %q, want %q", resp.Header.Get(fiber.HeaderETag), etag)
+			}
+		})
+	}
+
+	changes := []struct {
+		name   string
+		change func()
+	}{
+		{"edited", func() { p.Title, p.UpdatedAt = "Tulips in bloom", p.UpdatedAt.Add(time.Minute) }},
+		{"liked", func() { likes++ }},
+		{"author renamed", func() { alice.Username = "alice2" }},
+	}
+	for _, tt := range changes {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.change()
+			resp, body := get(t, etag)
+			newETag := resp.Header.Get(fiber.HeaderETag)
+			if resp.StatusCode != fiber.StatusOK || body == "" {
+				t.Fatalf("status = %d with body %q, want 200 with the post", resp.StatusCode, body)
+			}
+			if newETag == "" || newETag == etag {
+				t.Errorf("ETag = %q, want a new one", newETag)
+			}
+			etag = newETag
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"fmt"
+	"time"
+
+	"fowergram-backend/internal/domain/export"
+	"fowergram-backend/pkg/auth"
+	"fowergram-backend/pkg/httperr"
+	"fowergram-backend/pkg/logger"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type ExportHandler struct {
+	exportService export.Service
+	logger        logger.Logger
+}
+
+func NewExportHandler(exportService export.Service, logger logger.Logger) *ExportHandler {
+	return &ExportHandler{
+		exportService: exportService,
+		logger:        logger,
+	}
+}
+
+// Export returns a downloadable copy of the current user's data
+// @Summary Export my data
+// @Description Download the current user's profile, posts, comments and follow relationships as JSON. Limited to once per day.
+// @Tags Authentication
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} export.Export
+// @Failure 401 {object} ErrorResponse
+// @Failure 429 {object} ErrorResponse
+// @Router /api/auth/me/export [get]
+func (h *ExportHandler) Export(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*auth.User)
+	if !ok {
+		return httperr.Unauthenticated("Not authenticated")
+	}
+
+	data, err := h.exportService.Export(c.Context(), user.ID)
+	if err != nil {
+		return httperr.Internal("Failed to export data", err)
+	}
+
+	filename := fmt.Sprintf("fowergram-export-%s-%s.json", user.Username, time.Now().UTC().Format("2006-01-02"))
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename))
+
+	return c.JSON(data)
+}
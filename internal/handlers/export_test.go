@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"fowergram-backend/internal/domain/export"
+	"fowergram-backend/pkg/auth"
+	"fowergram-backend/pkg/errreport"
+	"fowergram-backend/pkg/httperr"
+	"fowergram-backend/pkg/logger"
+	"fowergram-backend/pkg/middleware"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// fakeExportService exports a profile for each user, or fails with err
+type fakeExportService struct {
+	err error
+}
+
+func (s *fakeExportService) Export(ctx context.Context, userID uuid.UUID) (*export.Export, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &export.Export{Profile: &export.Profile{ID: userID}}, nil
+}
+
+func TestExport(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	limiter := middleware.NewRateLimiter(middleware.RateLimiterConfig{RedisClient: client})
+	limiter.SetPolicy("export", middleware.RateLimitPolicy{MaxRequests: 1, Window: 24 * time.Hour})
+	service := &fakeExportService{}
+	handler := NewExportHandler(service, logger.NewZapLogger())
+
+	// Requests are signed in as the user X-User names and limited as
+	// routes.SetupRoutes limits exports
+	app := fiber.New(fiber.Config{ErrorHandler: httperr.Handler(logger.NewZapLogger(), errreport.Nop())})
+	users := map[string]*auth.User{
+		"alice": {ID: uuid.New(), Username: "alice"},
+		"bob":   {ID: uuid.New(), Username: "bob"},
+		"carol": {ID: uuid.New(), Username: "carol"},
+	}
+	app.Use(func(c *fiber.Ctx) error {
+		if user, ok := users[c.Get("X-User")]; ok {
+			c.Locals("user", user)
+		}
+		return c.Next()
+	})
+	app.Get("/me/export", limiter.Handle("export", middleware.ByUserID), handler.Export)
+
+	tests := []struct {
+		name       string
+		user       string
+		serviceErr error
+		wantStatus int
+	}{
+		{name: "first export of the day", user: "alice", wantStatus: fiber.StatusOK},
+		{name: "second export of the day", user: "alice", wantStatus: fiber.StatusTooManyRequests},
+		{name: "another user", user: "bob", wantStatus: fiber.StatusOK},
+		{name: "not signed in", wantStatus: fiber.StatusUnauthorized},
+		{name: "export fails", user: "carol", serviceErr: errors.New("connection reset"), wantStatus: fiber.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service.err = tt.serviceErr
+			req := httptest.NewRequest(http.MethodGet, "/me/export", nil)
+			req.Header.Set("X-User", tt.user)
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantStatus != fiber.StatusOK {
+				return
+			}
+
+			disposition := resp.Header.Get(fiber.HeaderContentDisposition)
+			wantName := "fowergram-export-" + tt.user + "-" + time.Now().UTC().Format("2006-01-02") + ".json"
+			if !strings.HasPrefix(disposition, "attachment;") || !strings.Contains(disposition, wantName) {
+				t.Errorf("Content-Disposition = %q, want an attachment named %s", disposition, wantName)
+			}
+			var body export.Export
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("decoding the export: %v", err)
+			}
+			if body.Profile == nil || body.Profile.ID != users[tt.user].ID {
+				t.Errorf("exported profile %+v, want %s's", body.Profile, tt.user)
+			}
+		})
+	}
+}
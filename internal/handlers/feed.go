@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"fowergram-backend/internal/domain/post"
+	"fowergram-backend/internal/domain/user"
+	"fowergram-backend/internal/events"
+	"fowergram-backend/pkg/auth"
+	"fowergram-backend/pkg/httperr"
+	"fowergram-backend/pkg/logger"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// feedHeartbeatInterval is how often an idle stream sends a comment so
+// proxies and clients don't time the connection out
+const feedHeartbeatInterval = 15 * time.Second
+
+// feedStreamBuffer bounds the events queued for a slow client; further
+// events are dropped until it catches up
+const feedStreamBuffer = 32
+
+type FeedHandler struct {
+	postService post.Service
+	userService user.Service // Resolves post authors
+	logger      logger.Logger
+}
+
+func NewFeedHandler(postService post.Service, userService user.Service, logger logger.Logger) *FeedHandler {
+	return &FeedHandler{
+		postService: postService,
+		userService: userService,
+		logger:      logger,
+	}
+}
+
+// GetFeed retrieves the caller's home feed
+// @Summary Get home feed
+// @Description Retrieve posts from accounts the caller follows, newest first, using cursor pagination
+// @Tags Feed
+// @Produce json
+// @Param cursor query string false "Cursor from a previous page"
+// @Param limit query int false "Page size" default(10)
+// @Success 200 {object} PostListResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/feed [get]
+func (h *FeedHandler) GetFeed(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*auth.User)
+	if !ok {
+		return httperr.Unauthenticated("Not authenticated")
+	}
+
+	cursor, err := post.DecodeCursor(c.Query("cursor"))
+	if err != nil {
+		return httperr.BadRequest("Invalid cursor")
+	}
+
+	result, err := h.postService.GetFeed(c.Context(), user.ID, post.ListPostsQuery{
+		After: cursor,
+		Limit: parseLimit(c),
+	})
+	if err != nil {
+		return httperr.Internal("Failed to get feed", err)
+	}
+
+	items, err := postResponses(c.Context(), h.userService, result.Posts)
+	if err != nil {
+		return httperr.Internal("Failed to load post authors", err)
+	}
+
+	return c.JSON(PostListResponse{
+		Posts:      items,
+		TotalCount: countOf(len(items)),
+		NextCursor: result.NextCursor,
+		HasMore:    result.NextCursor != "",
+	})
+}
+
+// FeedEventResponse is the data of a post.created feed stream event
+type FeedEventResponse struct {
+	PostID    string `json:"post_id"`
+	AuthorID  string `json:"author_id"`
+	CreatedAt string `json:"created_at"`
+}
+
+// Stream sends new posts from followed accounts as Server-Sent Events
+// @Summary Stream feed updates
+// @Description Server-Sent Events stream of posts created by accounts the caller follows. Each post is sent as a post.created event; comment lines are sent as heartbeats while idle.
+// @Tags Feed
+// @Produce text/event-stream
+// @Success 200 {object} FeedEventResponse
+// @Failure 401 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/feed/stream [get]
+func (h *FeedHandler) Stream(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*auth.User)
+	if !ok {
+		return httperr.Unauthenticated("Not authenticated")
+	}
+
+	log := h.logger.WithContext(c.Context())
+	created := make(chan events.PostCreated, feedStreamBuffer)
+	cancel, err := h.postService.SubscribeFeed(c.Context(), user.ID, func(event events.PostCreated) {
+		select {
+		case created <- event:
+		default:
+			log.Error("Dropping feed event for slow client", "post_id", event.PostID)
+		}
+	})
+	if err != nil {
+		return httperr.Internal("Failed to open feed stream", err)
+	}
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		// The writer outlives the handler; a failed flush means the client is gone
+		defer cancel()
+
+		heartbeat := time.NewTicker(feedHeartbeatInterval)
+		defer heartbeat.Stop()
+
+		fmt.Fprint(w, ": connected\n\n")
+		if err := w.Flush(); err != nil {
+			return
+		}
+
+		for {
+			select {
+			case event := <-created:
+				if err := writeFeedEvent(w, event); err != nil {
+					h.logger.Error("Failed to encode feed event", "post_id", event.PostID, "error", err)
+					continue
+				}
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": heartbeat\n\n")
+			}
+
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	})
+
+	return nil
+}
+
+// writeFeedEvent writes a post.created event in SSE framing
+func writeFeedEvent(w *bufio.Writer, event events.PostCreated) error {
+	data, err := json.Marshal(FeedEventResponse{
+		PostID:    event.PostID.String(),
+		AuthorID:  event.AuthorID.String(),
+		CreatedAt: event.CreatedAt.UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %s\nevent: post.created\ndata: %s\n\n", event.PostID, data)
+	return err
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"fowergram-backend/internal/config"
+	"fowergram-backend/internal/domain/post"
+	"fowergram-backend/internal/domain/user"
+	"fowergram-backend/internal/events"
+	"fowergram-backend/internal/infra/messaging"
+	"fowergram-backend/pkg/auth"
+	"fowergram-backend/pkg/errreport"
+	"fowergram-backend/pkg/httperr"
+	"fowergram-backend/pkg/logger"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/nats-io/nats-server/v2/server"
+)
+
+// fakeFollowingRepository lists the accounts each user follows. Other
+// methods are left to the embedded nil Repository.
+type fakeFollowingRepository struct {
+	user.Repository
+
+	following map[uuid.UUID][]uuid.UUID
+}
+
+func (r *fakeFollowingRepository) GetFollowingIDs(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	return r.following[userID], nil
+}
+
+// runNATSServer starts an in-process NATS server on a free port, shut down
+// when the test ends
+func runNATSServer(t *testing.T) *server.Server {
+	t.Helper()
+	ns, err := server.NewServer(&server.Options{Host: "127.0.0.1", Port: -1, NoLog: true, NoSigs: true})
+	if err != nil {
+		t.Fatalf("creating the NATS server: %v", err)
+	}
+	go ns.Start()
+	if !ns.ReadyForConnections(5 * time.Second) {
+		t.Fatal("NATS server didn't start")
+	}
+	t.Cleanup(ns.Shutdown)
+	return ns
+}
+
+// readFeedEvent reads lines up to the next SSE event and returns its name
+// and data, skipping comments
+func readFeedEvent(t *testing.T, r *bufio.Reader) (string, string) {
+	t.Helper()
+	var name, data string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading the stream: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && name != "":
+			return name, data
+		case strings.HasPrefix(line, "event: "):
+			name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func TestFeedStream(t *testing.T) {
+	ns := runNATSServer(t)
+	log := logger.NewZapLogger()
+	client, err := messaging.NewNATSClient(ns.ClientURL(), config.JetStreamConfig{}, log)
+	if err != nil {
+		t.Fatalf("NewNATSClient: %v", err)
+	}
+	t.Cleanup(client.Close)
+	publisher := events.NewNATSPublisher(client, log)
+
+	viewer := &auth.User{ID: uuid.New(), Username: "viewer"}
+	followed, stranger := uuid.New(), uuid.New()
+	users := &fakeFollowingRepository{following: map[uuid.UUID][]uuid.UUID{viewer.ID: {followed}}}
+	postService := post.NewService(nil, nil, users, nil, nil, nil, nil, nil, client, publisher, log, nil, "simple")
+
+	app := fiber.New(fiber.Config{
+		ErrorHandler:          httperr.Handler(log, errreport.Nop()),
+		DisableStartupMessage: true,
+	})
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("user", viewer)
+		return c.Next()
+	})
+	app.Get("/feed/stream", NewFeedHandler(postService, nil, log).Stream)
+
+	// The stream never ends, which app.Test can't serve, so it comes from
+	// a listening server
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening: %v", err)
+	}
+	go app.Listener(listener)
+	t.Cleanup(func() { app.ShutdownWithTimeout(time.Second) })
+
+	subscriptions := ns.NumSubscriptions()
+	resp, err := http.Get("http://" + listener.Addr().String() + "/feed/stream")
+	if err != nil {
+		t.Fatalf("opening the stream: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != fiber.StatusOK || resp.Header.Get(fiber.HeaderContentType) != "text/event-stream" {
+		t.Fatalf("status %d with content type %q, want an event stream", resp.StatusCode, resp.Header.Get(fiber.HeaderContentType))
+	}
+	stream := bufio.NewReader(resp.Body)
+	if line, err := stream.ReadString('\n'); err != nil || line != ": connected\n" {
+		t.Fatalf("first line = %q, %v; want the connected comment", line, err)
+	}
+	waitForSubscriptions(t, ns, subscriptions+1, nil)
+
+	publish := func(authorID uuid.UUID) uuid.UUID {
+		t.Helper()
+		created := events.PostCreated{PostID: uuid.New(), AuthorID: authorID, CreatedAt: time.Now()}
+		if err := publisher.Publish(context.Background(), authorID, created); err != nil {
+			t.Fatalf("publishing: %v", err)
+		}
+		return created.PostID
+	}
+
+	// Posts by accounts the viewer doesn't follow are left out, so the
+	// first event is the followed account's post
+	publish(stranger)
+	postID := publish(followed)
+
+	name, data := readFeedEvent(t, stream)
+	if name != "post.created" {
+		t.Errorf("event = %q, want post.created", name)
+	}
+	var event FeedEventResponse
+	if err := json.Unmarshal([]byte(data), &event); err != nil {
+		t.Fatalf("decoding %q: %v", data, err)
+	}
+	if event.PostID != postID.String() || event.AuthorID != followed.String() {
+		t.Errorf("event = %+v, want post %s by %s", event, postID, followed)
+	}
+
+	// The stream notices the client left on its next write and unsubscribes
+	resp.Body.Close()
+	waitForSubscriptions(t, ns, subscriptions, func() { publish(followed) })
+}
+
+// waitForSubscriptions waits for the server to count want subscriptions,
+// which it learns of asynchronously, calling poke between checks if set
+func waitForSubscriptions(t *testing.T, ns *server.Server, want uint32, poke func()) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for ns.NumSubscriptions() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d NATS subscriptions, want %d", ns.NumSubscriptions(), want)
+		}
+		if poke != nil {
+			poke()
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
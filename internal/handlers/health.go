@@ -1,18 +1,46 @@
 package handlers
 
 import (
+	"context"
+	"sync"
 	"time"
 
+	"fowergram-backend/pkg/logger"
+
 	"github.com/gofiber/fiber/v2"
 )
 
+// readinessTimeout bounds each dependency check of the readiness probe, so a
+// hung dependency can't hold the probe past the orchestrator's own timeout
+const readinessTimeout = 2 * time.Second
+
+// Checker reports whether a dependency can serve requests. The infra clients
+// implement it.
+type Checker interface {
+	HealthCheck(ctx context.Context) error
+}
+
+// CheckerFunc adapts a function, such as pgxpool.Pool.Ping, to a Checker
+type CheckerFunc func(ctx context.Context) error
+
+// HealthCheck calls f
+func (f CheckerFunc) HealthCheck(ctx context.Context) error {
+	return f(ctx)
+}
+
 type HealthHandler struct {
 	version string
+	checks  map[string]Checker
+	logger  logger.Logger
 }
 
-func NewHealthHandler(version string) *HealthHandler {
+// NewHealthHandler creates a handler whose readiness probe runs checks, keyed
+// by the dependency name reported in the response
+func NewHealthHandler(version string, checks map[string]Checker, logger logger.Logger) *HealthHandler {
 	return &HealthHandler{
 		version: version,
+		checks:  checks,
+		logger:  logger,
 	}
 }
 
@@ -23,9 +51,16 @@ type HealthResponse struct {
 	Version   string    `json:"version"`
 }
 
+// ReadinessResponse represents the readiness probe response
+type ReadinessResponse struct {
+	Status    string            `json:"status"` // "ready" or "not_ready"
+	Checks    map[string]string `json:"checks"` // "ok" or "unavailable" by dependency
+	Timestamp time.Time         `json:"timestamp"`
+}
+
 // Health handles health check requests
 // @Summary Health check
-// @Description Check if the API is running and healthy
+// @Description Liveness check: the API process is up. Dependencies aren't checked, see /ready.
 // @Tags Health
 // @Produce json
 // @Success 200 {object} HealthResponse
@@ -37,3 +72,52 @@ func (h *HealthHandler) Health(c *fiber.Ctx) error {
 		Version:   h.version,
 	})
 }
+
+// Ready handles readiness probe requests
+// @Summary Readiness probe
+// @Description Check that the database, Redis, NATS and object storage are reachable, so traffic can be routed to this instance
+// @Tags Health
+// @Produce json
+// @Success 200 {object} ReadinessResponse
+// @Failure 503 {object} ReadinessResponse
+// @Router /ready [get]
+func (h *HealthHandler) Ready(c *fiber.Ctx) error {
+	resp := ReadinessResponse{
+		Status:    "ready",
+		Checks:    make(map[string]string, len(h.checks)),
+		Timestamp: time.Now().UTC(),
+	}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for name, check := range h.checks {
+		wg.Add(1)
+		go func(name string, check Checker) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(c.UserContext(), readinessTimeout)
+			defer cancel()
+
+			status := "ok"
+			if err := check.HealthCheck(ctx); err != nil {
+				h.logger.Warn("Readiness check failed", "dependency", name, "error", err)
+				status = "unavailable"
+			}
+
+			mu.Lock()
+			resp.Checks[name] = status
+			mu.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+
+	for _, status := range resp.Checks {
+		if status != "ok" {
+			resp.Status = "not_ready"
+			return c.Status(fiber.StatusServiceUnavailable).JSON(resp)
+		}
+	}
+	return c.JSON(resp)
+}
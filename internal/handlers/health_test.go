@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"fowergram-backend/pkg/logger"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestReady(t *testing.T) {
+	ok := CheckerFunc(func(ctx context.Context) error { return nil })
+	down := CheckerFunc(func(ctx context.Context) error { return errors.New("connection refused") })
+
+	tests := []struct {
+		name       string
+		checks     map[string]Checker
+		wantStatus int
+		wantBody   ReadinessResponse
+	}{
+		{
+			name:       "all reachable",
+			checks:     map[string]Checker{"database": ok, "storage": ok},
+			wantStatus: fiber.StatusOK,
+			wantBody:   ReadinessResponse{Status: "ready", Checks: map[string]string{"database": "ok", "storage": "ok"}},
+		},
+		{
+			name:       "storage down",
+			checks:     map[string]Checker{"database": ok, "storage": down},
+			wantStatus: fiber.StatusServiceUnavailable,
+			wantBody:   ReadinessResponse{Status: "not_ready", Checks: map[string]string{"database": "ok", "storage": "unavailable"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewHealthHandler("test", tt.checks, logger.NewZapLogger())
+			app := fiber.New(fiber.Config{DisableStartupMessage: true})
+			app.Get("/ready", handler.Ready)
+
+			resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/ready", nil), -1)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+
+			var body ReadinessResponse
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("decoding the response: %v", err)
+			}
+			if body.Status != tt.wantBody.Status || !reflect.DeepEqual(body.Checks, tt.wantBody.Checks) {
+				t.Errorf("body = %+v, want %+v", body, tt.wantBody)
+			}
+		})
+	}
+}
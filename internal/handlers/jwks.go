@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"fowergram-backend/pkg/auth"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type JWKSHandler struct {
+	keys *auth.SigningKeys
+}
+
+func NewJWKSHandler(keys *auth.SigningKeys) *JWKSHandler {
+	return &JWKSHandler{
+		keys: keys,
+	}
+}
+
+// JWKS publishes the public key tokens are signed with
+// @Summary JSON Web Key Set
+// @Description Public key that access and refresh tokens are signed with, for services verifying tokens themselves. Only served when RS256 or ES256 signing is configured.
+// @Tags Authentication
+// @Produce json
+// @Success 200 {object} auth.JWKS
+// @Router /.well-known/jwks.json [get]
+func (h *JWKSHandler) JWKS(c *fiber.Ctx) error {
+	c.Set("Cache-Control", "public, max-age=300")
+	return c.JSON(h.keys.JWKS())
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"fowergram-backend/internal/domain/post"
+	"fowergram-backend/pkg/auth"
+	"fowergram-backend/pkg/httperr"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// LikerResponse represents a user who liked a post
+type LikerResponse struct {
+	ID             string `json:"id"`
+	Username       string `json:"username"`
+	FullName       string `json:"full_name,omitempty"`
+	ProfilePicture string `json:"profile_picture,omitempty"`
+	IsVerified     bool   `json:"is_verified"`
+	LikedAt        string `json:"liked_at"`
+}
+
+// LikerListResponse represents a page of likers
+type LikerListResponse struct {
+	Users    []LikerResponse `json:"users"`
+	Page     int             `json:"page"`
+	PageSize int             `json:"page_size"`
+	HasMore  bool            `json:"has_more"`
+}
+
+// LikePost likes a post
+// @Summary Like post
+// @Description Like a post; liking an already liked post has no effect
+// @Tags Posts
+// @Produce json
+// @Param id path string true "Post ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/posts/{id}/like [post]
+func (h *PostHandler) LikePost(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*auth.User)
+	if !ok {
+		return httperr.Unauthenticated("Not authenticated")
+	}
+
+	postID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return httperr.BadRequest("Invalid post ID")
+	}
+
+	if err := h.postService.LikePost(c.Context(), postID, user.ID); err != nil {
+		return likeError("Failed to like post", postID, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Post liked",
+	})
+}
+
+// UnlikePost removes a like from a post
+// @Summary Unlike post
+// @Description Remove a like from a post; unliking a post that isn't liked has no effect
+// @Tags Posts
+// @Produce json
+// @Param id path string true "Post ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/posts/{id}/like [delete]
+func (h *PostHandler) UnlikePost(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*auth.User)
+	if !ok {
+		return httperr.Unauthenticated("Not authenticated")
+	}
+
+	postID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return httperr.BadRequest("Invalid post ID")
+	}
+
+	if err := h.postService.UnlikePost(c.Context(), postID, user.ID); err != nil {
+		return likeError("Failed to unlike post", postID, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Post unliked",
+	})
+}
+
+// GetLikes lists the users who liked a post
+// @Summary Get post likes
+// @Description Retrieve a paginated list of users who liked a post
+// @Tags Posts
+// @Produce json
+// @Param id path string true "Post ID"
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(10)
+// @Success 200 {object} LikerListResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/posts/{id}/likes [get]
+func (h *PostHandler) GetLikes(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*auth.User)
+	if !ok {
+		return httperr.Unauthenticated("Not authenticated")
+	}
+
+	postID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return httperr.BadRequest("Invalid post ID")
+	}
+
+	page, pageSize := parsePagination(c)
+
+	// Fetch one extra row to know whether another page exists
+	likers, err := h.postService.GetLikers(c.Context(), postID, user.ID, pageSize+1, (page-1)*pageSize)
+	if err != nil {
+		return likeError("Failed to get likes", postID, err)
+	}
+
+	hasMore := len(likers) > pageSize
+	if hasMore {
+		likers = likers[:pageSize]
+	}
+
+	users := make([]LikerResponse, 0, len(likers))
+	for _, l := range likers {
+		users = append(users, LikerResponse{
+			ID:             l.UserID.String(),
+			Username:       l.Username,
+			FullName:       l.FullName,
+			ProfilePicture: l.ProfilePicture,
+			IsVerified:     l.IsVerified,
+			LikedAt:        l.LikedAt.UTC().Format(time.RFC3339),
+		})
+	}
+
+	return c.JSON(LikerListResponse{
+		Users:    users,
+		Page:     page,
+		PageSize: pageSize,
+		HasMore:  hasMore,
+	})
+}
+
+// likeError maps like and save service errors to responses
+func likeError(message string, postID uuid.UUID, err error) error {
+	if errors.Is(err, post.ErrPostNotFound) {
+		return httperr.NotFound("Post not found")
+	}
+
+	return httperr.Internal(message, err, "post_id", postID)
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"fowergram-backend/internal/domain/media"
+	"fowergram-backend/pkg/auth"
+	"fowergram-backend/pkg/httperr"
+	"fowergram-backend/pkg/logger"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type MediaHandler struct {
+	mediaService media.Service
+	logger       logger.Logger
+}
+
+func NewMediaHandler(mediaService media.Service, logger logger.Logger) *MediaHandler {
+	return &MediaHandler{
+		mediaService: mediaService,
+		logger:       logger,
+	}
+}
+
+// MediaResponse represents an uploaded media file in API responses
+type MediaResponse struct {
+	ID     string            `json:"id"`
+	Key    string            `json:"key"`
+	Status string            `json:"status"`
+	Width  int               `json:"width,omitempty"`
+	Height int               `json:"height,omitempty"`
+	URLs   map[string]string `json:"urls,omitempty"`
+}
+
+// PresignUploadRequest represents the request for a direct upload URL
+type PresignUploadRequest struct {
+	ContentType string `json:"content_type" validate:"required,oneof=image/jpeg image/png image/gif image/webp"`
+	Size        int64  `json:"size" validate:"required,min=1,max=52428800"` // File size in bytes
+}
+
+// PresignUploadResponse represents a direct upload URL. The client PUTs the
+// file to UploadURL with exactly Headers, then references Key in CreatePost.
+type PresignUploadResponse struct {
+	ID        string            `json:"id"`
+	Key       string            `json:"key"`
+	UploadURL string            `json:"upload_url"`
+	Method    string            `json:"method"`
+	Headers   map[string]string `json:"headers"`
+	ExpiresAt string            `json:"expires_at"`
+}
+
+// PresignUpload returns a URL for uploading media straight to storage
+// @Summary Presign media upload
+// @Description Reserve an upload and return a presigned URL to PUT the file to directly, bypassing the API. The content type and size are signed, so the upload must match them. Reference the returned key in media_files when creating a post; variants are generated once the post is created.
+// @Tags Media
+// @Accept json
+// @Produce json
+// @Param request body PresignUploadRequest true "File to upload"
+// @Success 201 {object} PresignUploadResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/media/presign [post]
+func (h *MediaHandler) PresignUpload(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*auth.User)
+	if !ok {
+		return httperr.Unauthenticated("Not authenticated")
+	}
+
+	var req PresignUploadRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	upload, err := h.mediaService.PresignUpload(c.Context(), user.ID, req.ContentType, req.Size)
+	if err != nil {
+		if errors.Is(err, media.ErrUnsupportedContentType) || errors.Is(err, media.ErrFileTooLarge) {
+			return httperr.BadRequest(err.Error())
+		}
+		if errors.Is(err, media.ErrStorageUnavailable) {
+			return httperr.Unavailable("Media uploads are unavailable")
+		}
+		return httperr.Internal("Failed to presign upload", err)
+	}
+
+	return c.Status(201).JSON(PresignUploadResponse{
+		ID:        upload.Media.ID.String(),
+		Key:       upload.Media.OriginalKey,
+		UploadURL: upload.URL,
+		Method:    http.MethodPut,
+		Headers:   upload.Headers,
+		ExpiresAt: upload.ExpiresAt.UTC().Format(time.RFC3339),
+	})
+}
+
+// Upload uploads an image and generates its variants
+// @Summary Upload media
+// @Description Upload an image; thumbnail (256px), feed (1080px) and original variants are generated with EXIF metadata stripped
+// @Tags Media
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "Image file"
+// @Success 201 {object} MediaResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/media [post]
+func (h *MediaHandler) Upload(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*auth.User)
+	if !ok {
+		return httperr.Unauthenticated("Not authenticated")
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return httperr.BadRequest("File is required")
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return httperr.BadRequest("Invalid file")
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return httperr.BadRequest("Invalid file")
+	}
+
+	m, err := h.mediaService.Upload(c.Context(), user.ID, data, http.DetectContentType(data))
+	if err != nil {
+		if errors.Is(err, media.ErrImageTooLarge) {
+			return httperr.BadRequest(err.Error())
+		}
+		if errors.Is(err, media.ErrUnsupportedContentType) || errors.Is(err, media.ErrInvalidImage) {
+			return httperr.BadRequest("Unsupported image format")
+		}
+		if errors.Is(err, media.ErrStorageUnavailable) {
+			return httperr.Unavailable("Media uploads are unavailable")
+		}
+		return httperr.Internal("Failed to upload media", err)
+	}
+
+	if err := h.mediaService.ResolveURLs(c.Context(), m); err != nil {
+		h.logger.Error("Failed to resolve media URLs", "media_id", m.ID, "error", err)
+	}
+
+	return c.Status(201).JSON(toMediaResponse(m))
+}
+
+// toMediaResponse converts a media domain model to its API representation
+func toMediaResponse(m *media.Media) MediaResponse {
+	resp := MediaResponse{
+		ID:     m.ID.String(),
+		Key:    m.OriginalKey,
+		Status: string(m.Status),
+		Width:  m.Width,
+		Height: m.Height,
+	}
+
+	if len(m.URLs) > 0 {
+		resp.URLs = make(map[string]string, len(m.URLs))
+		for variant, url := range m.URLs {
+			resp.URLs[string(variant)] = url
+		}
+	}
+
+	return resp
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"fowergram-backend/internal/domain/post"
+	"fowergram-backend/pkg/auth"
+	"fowergram-backend/pkg/httperr"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// NearbyPostsResponse represents posts near a point, closest first
+type NearbyPostsResponse struct {
+	Posts    []PostResponse `json:"posts"`
+	Page     int            `json:"page"`
+	PageSize int            `json:"page_size"`
+	HasMore  bool           `json:"has_more"`
+}
+
+// GetNearbyPosts lists posts near a point
+// @Summary Get nearby posts
+// @Description Retrieve posts tagged within radius meters of a point, closest first. Posts of private accounts are only included for their followers.
+// @Tags Posts
+// @Produce json
+// @Param lat query number true "Latitude, -90 to 90"
+// @Param lng query number true "Longitude, -180 to 180"
+// @Param radius query number false "Search radius in meters, at most 50000" default(5000)
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(10)
+// @Success 200 {object} NearbyPostsResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/posts/nearby [get]
+func (h *PostHandler) GetNearbyPosts(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*auth.User)
+	if !ok {
+		return httperr.Unauthenticated("Not authenticated")
+	}
+
+	lat, latErr := strconv.ParseFloat(c.Query("lat"), 64)
+	lng, lngErr := strconv.ParseFloat(c.Query("lng"), 64)
+	if latErr != nil || lngErr != nil {
+		return httperr.BadRequest("lat and lng are required numbers")
+	}
+
+	radius := float64(post.DefaultNearbyRadius)
+	if raw := c.Query("radius"); raw != "" {
+		parsed, err := strconv.ParseFloat(raw, 64)
+		if err != nil || parsed <= 0 {
+			return httperr.BadRequest("radius must be a positive number of meters")
+		}
+		radius = parsed
+	}
+
+	page, pageSize := parsePagination(c)
+
+	// Fetch one extra row to know whether another page exists
+	posts, err := h.postService.ListNearbyPosts(c.Context(), user.ID, post.NearbyQuery{
+		Latitude:     lat,
+		Longitude:    lng,
+		RadiusMeters: radius,
+		Limit:        pageSize + 1,
+		Offset:       (page - 1) * pageSize,
+	})
+	if err != nil {
+		if errors.Is(err, post.ErrInvalidCoordinates) {
+			return httperr.BadRequest(err.Error())
+		}
+		return httperr.Internal("Failed to get nearby posts", err)
+	}
+
+	hasMore := len(posts) > pageSize
+	if hasMore {
+		posts = posts[:pageSize]
+	}
+
+	items, err := postResponses(c.Context(), h.userService, posts)
+	if err != nil {
+		return httperr.Internal("Failed to load post authors", err)
+	}
+
+	return c.JSON(NearbyPostsResponse{
+		Posts:    items,
+		Page:     page,
+		PageSize: pageSize,
+		HasMore:  hasMore,
+	})
+}
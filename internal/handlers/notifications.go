@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"time"
+
+	"fowergram-backend/internal/domain/notification"
+	"fowergram-backend/pkg/auth"
+	"fowergram-backend/pkg/httperr"
+	"fowergram-backend/pkg/logger"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type NotificationHandler struct {
+	notificationService notification.Service
+	logger              logger.Logger
+}
+
+func NewNotificationHandler(notificationService notification.Service, logger logger.Logger) *NotificationHandler {
+	return &NotificationHandler{
+		notificationService: notificationService,
+		logger:              logger,
+	}
+}
+
+// NotificationResponse represents a notification in API responses
+type NotificationResponse struct {
+	ID                  string `json:"id"`
+	Type                string `json:"type"`
+	ActorID             string `json:"actor_id,omitempty"`
+	ActorUsername       string `json:"actor_username,omitempty"`
+	ActorProfilePicture string `json:"actor_profile_picture,omitempty"`
+	EntityType          string `json:"entity_type,omitempty"`
+	EntityID            string `json:"entity_id,omitempty"`
+	Message             string `json:"message,omitempty"`
+	IsRead              bool   `json:"is_read"`
+	ReadAt              string `json:"read_at,omitempty"`
+	CreatedAt           string `json:"created_at"`
+}
+
+// NotificationListResponse represents a page of notifications
+type NotificationListResponse struct {
+	Notifications []NotificationResponse `json:"notifications"`
+	UnreadCount   int                    `json:"unread_count"`
+	Page          int                    `json:"page"`
+	PageSize      int                    `json:"page_size"`
+	HasMore       bool                   `json:"has_more"`
+}
+
+// MarkNotificationsReadRequest selects the notifications to mark read
+type MarkNotificationsReadRequest struct {
+	IDs []uuid.UUID `json:"ids,omitempty"` // All notifications when empty
+}
+
+// MarkNotificationsReadResponse reports the unread count after marking
+type MarkNotificationsReadResponse struct {
+	UnreadCount int `json:"unread_count"`
+}
+
+// GetNotifications lists the current user's notifications
+// @Summary Get notifications
+// @Description Retrieve the current user's notifications, newest first, along with the number of unread notifications. Likes and follows from the same account are notified once; blocked and muted accounts don't notify.
+// @Tags Notifications
+// @Produce json
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(10)
+// @Success 200 {object} NotificationListResponse
+// @Failure 401 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/notifications [get]
+func (h *NotificationHandler) GetNotifications(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*auth.User)
+	if !ok {
+		return httperr.Unauthenticated("Not authenticated")
+	}
+
+	page, pageSize := parsePagination(c)
+
+	// Fetch one extra row to know whether another page exists
+	notifications, unread, err := h.notificationService.List(c.Context(), user.ID, pageSize+1, (page-1)*pageSize)
+	if err != nil {
+		return httperr.Internal("Failed to get notifications", err)
+	}
+
+	hasMore := len(notifications) > pageSize
+	if hasMore {
+		notifications = notifications[:pageSize]
+	}
+
+	items := make([]NotificationResponse, 0, len(notifications))
+	for _, n := range notifications {
+		items = append(items, toNotificationResponse(n))
+	}
+
+	return c.JSON(NotificationListResponse{
+		Notifications: items,
+		UnreadCount:   unread,
+		Page:          page,
+		PageSize:      pageSize,
+		HasMore:       hasMore,
+	})
+}
+
+// MarkNotificationsRead marks notifications as read
+// @Summary Mark notifications read
+// @Description Mark the given notifications as read, or all of the current user's notifications when no IDs are sent
+// @Tags Notifications
+// @Accept json
+// @Produce json
+// @Param request body MarkNotificationsReadRequest false "Notifications to mark"
+// @Success 200 {object} MarkNotificationsReadResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/notifications/read [post]
+func (h *NotificationHandler) MarkNotificationsRead(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*auth.User)
+	if !ok {
+		return httperr.Unauthenticated("Not authenticated")
+	}
+
+	// The body is optional; without one everything is marked read
+	var req MarkNotificationsReadRequest
+	if len(c.Body()) > 0 {
+		if err := parseBody(c, &req); err != nil {
+			return err
+		}
+	}
+
+	unread, err := h.notificationService.MarkRead(c.Context(), user.ID, req.IDs)
+	if err != nil {
+		return httperr.Internal("Failed to mark notifications read", err)
+	}
+
+	return c.JSON(MarkNotificationsReadResponse{
+		UnreadCount: unread,
+	})
+}
+
+func toNotificationResponse(n *notification.Notification) NotificationResponse {
+	resp := NotificationResponse{
+		ID:                  n.ID.String(),
+		Type:                n.Type,
+		ActorUsername:       n.ActorUsername,
+		ActorProfilePicture: n.ActorProfilePicture,
+		EntityType:          n.EntityType,
+		Message:             n.Message,
+		IsRead:              n.IsRead,
+		CreatedAt:           n.CreatedAt.UTC().Format(time.RFC3339),
+	}
+	if n.ActorID != nil {
+		resp.ActorID = n.ActorID.String()
+	}
+	if n.EntityID != nil {
+		resp.EntityID = n.EntityID.String()
+	}
+	if n.ReadAt != nil {
+		resp.ReadAt = n.ReadAt.UTC().Format(time.RFC3339)
+	}
+	return resp
+}
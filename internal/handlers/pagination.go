@@ -0,0 +1,39 @@
+package handlers
+
+import "github.com/gofiber/fiber/v2"
+
+// Page size bounds for paginated endpoints
+const (
+	defaultPageSize = 10
+	maxPageSize     = 100
+)
+
+// parsePagination reads page/page_size query params, clamped to sane bounds
+func parsePagination(c *fiber.Ctx) (page, pageSize int) {
+	page = c.QueryInt("page", 1)
+	if page < 1 {
+		page = 1
+	}
+
+	pageSize = c.QueryInt("page_size", defaultPageSize)
+	if pageSize < 1 {
+		pageSize = defaultPageSize
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+
+	return page, pageSize
+}
+
+// parseLimit reads the limit query param for cursor-paginated endpoints
+func parseLimit(c *fiber.Ctx) int {
+	limit := c.QueryInt("limit", defaultPageSize)
+	if limit < 1 {
+		return defaultPageSize
+	}
+	if limit > maxPageSize {
+		return maxPageSize
+	}
+	return limit
+}
@@ -1,8 +1,15 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"time"
+
+	"fowergram-backend/internal/domain/moderation"
 	"fowergram-backend/internal/domain/post"
+	"fowergram-backend/internal/domain/user"
 	"fowergram-backend/pkg/auth"
+	"fowergram-backend/pkg/httperr"
 	"fowergram-backend/pkg/logger"
 
 	"github.com/gofiber/fiber/v2"
@@ -11,12 +18,14 @@ import (
 
 type PostHandler struct {
 	postService post.Service
+	userService user.Service // Resolves post authors
 	logger      logger.Logger
 }
 
-func NewPostHandler(postService post.Service, logger logger.Logger) *PostHandler {
+func NewPostHandler(postService post.Service, userService user.Service, logger logger.Logger) *PostHandler {
 	return &PostHandler{
 		postService: postService,
+		userService: userService,
 		logger:      logger,
 	}
 }
@@ -28,48 +37,95 @@ type CreatePostRequest struct {
 	MediaFiles []string `json:"media_files,omitempty"`
 	Tags       []string `json:"tags,omitempty"`
 	IsPrivate  bool     `json:"is_private"`
-	Location   string   `json:"location,omitempty"`
+	Location   string   `json:"location,omitempty"` // Place name shown with the post
 	Caption    string   `json:"caption,omitempty"`
+
+	CommentsDisabled bool `json:"comments_disabled"`
+	HideLikeCount    bool `json:"hide_like_count"` // Show the like count to the author only
+
+	// Coordinates of the place, for nearby search; set both or neither
+	Latitude  *float64 `json:"latitude,omitempty" validate:"omitempty,min=-90,max=90"`
+	Longitude *float64 `json:"longitude,omitempty" validate:"omitempty,min=-180,max=180"`
+
+	// Status is "draft" to keep the post unpublished; ScheduledAt publishes it later instead
+	Status      string     `json:"status,omitempty" validate:"omitempty,oneof=draft published"`
+	ScheduledAt *time.Time `json:"scheduled_at,omitempty"`
 }
 
 // UpdatePostRequest represents the request to update a post
 type UpdatePostRequest struct {
-	Title     *string  `json:"title,omitempty" validate:"omitempty,min=1,max=200"`
-	Content   *string  `json:"content,omitempty" validate:"omitempty,min=1,max=2000"`
-	Tags      []string `json:"tags,omitempty"`
-	IsPrivate *bool    `json:"is_private,omitempty"`
-	Caption   *string  `json:"caption,omitempty"`
+	Title            *string  `json:"title,omitempty" validate:"omitempty,min=1,max=200"`
+	Content          *string  `json:"content,omitempty" validate:"omitempty,min=1,max=2000"`
+	Tags             []string `json:"tags,omitempty"`
+	IsPrivate        *bool    `json:"is_private,omitempty"`
+	Caption          *string  `json:"caption,omitempty"`
+	CommentsDisabled *bool    `json:"comments_disabled,omitempty"`
+	HideLikeCount    *bool    `json:"hide_like_count,omitempty"`
+	Version          int      `json:"version" validate:"required,min=1"` // Version the edit is based on
+}
+
+// BatchGetPostsRequest represents the request to fetch posts by ID
+type BatchGetPostsRequest struct {
+	IDs []string `json:"ids" validate:"required,min=1,max=100,dive,uuid"`
 }
 
 // PostResponse represents a post in API responses
 type PostResponse struct {
-	ID            string   `json:"id"`
-	Title         string   `json:"title"`
-	Content       string   `json:"content"`
-	MediaFiles    []string `json:"media_files"`
-	Tags          []string `json:"tags"`
-	IsPrivate     bool     `json:"is_private"`
-	Location      string   `json:"location,omitempty"`
-	Caption       string   `json:"caption,omitempty"`
-	AuthorID      string   `json:"author_id"`
-	LikesCount    int      `json:"likes_count"`
-	CommentsCount int      `json:"comments_count"`
-	CreatedAt     string   `json:"created_at"`
-	UpdatedAt     string   `json:"updated_at"`
+	ID               string               `json:"id"`
+	Title            string               `json:"title"`
+	Content          string               `json:"content"`
+	MediaFiles       []string             `json:"media_files"`
+	Media            []MediaResponse      `json:"media,omitempty"`
+	Tags             []string             `json:"tags"`
+	IsPrivate        bool                 `json:"is_private"`
+	CommentsDisabled bool                 `json:"comments_disabled"`
+	HideLikeCount    bool                 `json:"hide_like_count"`
+	Location         string               `json:"location,omitempty"`
+	Latitude         *float64             `json:"latitude,omitempty"`
+	Longitude        *float64             `json:"longitude,omitempty"`
+	DistanceMeters   *float64             `json:"distance_meters,omitempty"` // Set by nearby search
+	Caption          string               `json:"caption,omitempty"`
+	AuthorID         string               `json:"author_id"`
+	Author           *UserSummaryResponse `json:"author,omitempty"`      // Omitted when the author is deactivated
+	LikesCount       *int                 `json:"likes_count,omitempty"` // Omitted when the author hid it
+	CommentsCount    int                  `json:"comments_count"`
+	RepostsCount     int                  `json:"reposts_count"`
+	ViewsCount       *int64               `json:"views_count,omitempty"` // Only set for the author
+	ViewerHasSaved   bool                 `json:"viewer_has_saved"`
+	Status           string               `json:"status"`
+	ScheduledAt      string               `json:"scheduled_at,omitempty"`
+	Version          int                  `json:"version"`
+	CreatedAt        string               `json:"created_at"`
+	UpdatedAt        string               `json:"updated_at"`
+
+	// Set on reposts
+	OriginalPostID string                `json:"original_post_id,omitempty"`
+	Original       *OriginalPostResponse `json:"original,omitempty"`
 }
 
-// PostListResponse represents a list of posts with pagination
+// OriginalPostResponse embeds the post a repost refers to. When the original
+// was deleted or can no longer be seen, only Unavailable and Message are set.
+type OriginalPostResponse struct {
+	*PostResponse
+	Unavailable bool   `json:"unavailable,omitempty"`
+	Message     string `json:"message,omitempty"`
+}
+
+// PostListResponse represents a list of posts with pagination. TotalCount is
+// left out when the posts weren't counted.
 type PostListResponse struct {
-	Posts      []PostResponse `json:"posts"`
-	TotalCount int            `json:"total_count"`
-	Page       int            `json:"page"`
-	PageSize   int            `json:"page_size"`
-	HasMore    bool           `json:"has_more"`
+	Posts               []PostResponse `json:"posts"`
+	TotalCount          *int64         `json:"total_count,omitempty"`
+	TotalCountEstimated bool           `json:"total_count_estimated,omitempty"` // Set when total_count is an estimate
+	Page                int            `json:"page,omitempty"`                  // Set for page/page_size pagination
+	PageSize            int            `json:"page_size,omitempty"`             // Set for page/page_size pagination
+	NextCursor          string         `json:"next_cursor,omitempty"`
+	HasMore             bool           `json:"has_more"`
 }
 
 // CreatePost creates a new post
 // @Summary Create a new post
-// @Description Create a new post with title, content, and optional media
+// @Description Create a new post with title, content, and optional media. Set status to "draft" to keep it unpublished, or scheduled_at to publish it automatically at that time (at most 75 days ahead). Text the moderator rejects is answered with 400 and code CONTENT_REJECTED; flagged text is published and queued for review.
 // @Tags Posts
 // @Accept json
 // @Produce json
@@ -82,133 +138,241 @@ type PostListResponse struct {
 func (h *PostHandler) CreatePost(c *fiber.Ctx) error {
 	user, ok := c.Locals("user").(*auth.User)
 	if !ok {
-		return c.Status(401).JSON(ErrorResponse{
-			Error: "Not authenticated",
-		})
+		return httperr.Unauthenticated("Not authenticated")
 	}
 
 	var req CreatePostRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(400).JSON(ErrorResponse{
-			Error: "Invalid request body",
-		})
-	}
-
-	if req.Title == "" || req.Content == "" {
-		return c.Status(400).JSON(ErrorResponse{
-			Error: "Title and content are required",
-		})
-	}
-
-	// This would call the actual post service
-	// For now, return a mock response
-	postID := uuid.New().String()
-
-	h.logger.Info("Creating post", "user_id", user.ID, "title", req.Title)
-
-	return c.Status(201).JSON(PostResponse{
-		ID:            postID,
-		Title:         req.Title,
-		Content:       req.Content,
-		MediaFiles:    req.MediaFiles,
-		Tags:          req.Tags,
-		IsPrivate:     req.IsPrivate,
-		Location:      req.Location,
-		Caption:       req.Caption,
-		AuthorID:      user.ID.String(),
-		LikesCount:    0,
-		CommentsCount: 0,
-		CreatedAt:     "2024-01-01T00:00:00Z",
-		UpdatedAt:     "2024-01-01T00:00:00Z",
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	p, err := h.postService.CreatePost(c.Context(), user.ID, post.CreatePostInput{
+		Title:            req.Title,
+		Content:          req.Content,
+		Caption:          optionalString(req.Caption),
+		Location:         optionalString(req.Location),
+		Latitude:         req.Latitude,
+		Longitude:        req.Longitude,
+		IsPrivate:        req.IsPrivate,
+		CommentsDisabled: req.CommentsDisabled,
+		HideLikeCount:    req.HideLikeCount,
+		MediaKeys:        req.MediaFiles,
+		Tags:             req.Tags,
+		Draft:            req.Status == post.StatusDraft,
+		ScheduledAt:      req.ScheduledAt,
 	})
+	if err != nil {
+		if errors.Is(err, moderation.ErrContentRejected) {
+			return httperr.New(fiber.StatusBadRequest, httperr.CodeContentRejected, err.Error())
+		}
+		if errors.Is(err, post.ErrMediaNotFound) || errors.Is(err, post.ErrMediaNotUploaded) ||
+			errors.Is(err, post.ErrInvalidMediaKey) ||
+			errors.Is(err, post.ErrTooManyTags) || errors.Is(err, post.ErrScheduleInPast) ||
+			errors.Is(err, post.ErrScheduleTooFar) || errors.Is(err, post.ErrDraftScheduled) ||
+			errors.Is(err, post.ErrInvalidCoordinates) {
+			return httperr.BadRequest(err.Error())
+		}
+		return httperr.Internal("Failed to create post", err)
+	}
+
+	resp, err := postResponse(c.Context(), h.userService, p)
+	if err != nil {
+		return httperr.Internal("Failed to load post author", err, "post_id", p.ID)
+	}
+
+	return c.Status(201).JSON(resp)
 }
 
 // GetPosts retrieves a list of posts
 // @Summary Get posts list
-// @Description Retrieve a paginated list of posts
+// @Description Retrieve posts visible to the caller, newest first, optionally filtered by author and tag, using cursor pagination. page/page_size are deprecated and answered with a Deprecation header; they will be removed in the next release.
 // @Tags Posts
 // @Produce json
-// @Param page query int false "Page number" default(1)
-// @Param page_size query int false "Page size" default(10)
+// @Param cursor query string false "Cursor from a previous page"
+// @Param limit query int false "Page size" default(10)
+// @Param page query int false "Deprecated: page number" default(1)
+// @Param page_size query int false "Deprecated: page size" default(10)
 // @Param author_id query string false "Filter by author ID"
-// @Param tag query string false "Filter by tag"
+// @Param tag query string false "Filter by tag, with or without the leading #"
+// @Param count query string false "How to count all the matching posts for total_count: exact, estimate (fast on large tables, but approximate) or none (total_count left out)" default(exact)
 // @Success 200 {object} PostListResponse
 // @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
 // @Security BearerAuth
 // @Router /api/posts [get]
 func (h *PostHandler) GetPosts(c *fiber.Ctx) error {
-	page := c.QueryInt("page", 1)
-	pageSize := c.QueryInt("page_size", 10)
-	authorID := c.Query("author_id")
-	tag := c.Query("tag")
-
-	h.logger.Info("Getting posts", "page", page, "page_size", pageSize, "author_id", authorID, "tag", tag)
-
-	// Mock response
-	posts := []PostResponse{
-		{
-			ID:            uuid.New().String(),
-			Title:         "Sample Post 1",
-			Content:       "This is a sample post content",
-			MediaFiles:    []string{"image1.jpg"},
-			Tags:          []string{"sample", "test"},
-			IsPrivate:     false,
-			AuthorID:      uuid.New().String(),
-			LikesCount:    10,
-			CommentsCount: 5,
-			CreatedAt:     "2024-01-01T00:00:00Z",
-			UpdatedAt:     "2024-01-01T00:00:00Z",
-		},
+	user, ok := c.Locals("user").(*auth.User)
+	if !ok {
+		return httperr.Unauthenticated("Not authenticated")
+	}
+
+	cursor, err := post.DecodeCursor(c.Query("cursor"))
+	if err != nil {
+		return httperr.BadRequest("Invalid cursor")
+	}
+
+	filter := post.ListPostsFilter{
+		Tag: c.Query("tag"),
+	}
+	if raw := c.Query("author_id"); raw != "" {
+		authorID, err := uuid.Parse(raw)
+		if err != nil {
+			return httperr.BadRequest("Invalid author ID")
+		}
+		filter.AuthorID = &authorID
+	}
+
+	query := post.ListPostsQuery{
+		After: cursor,
+		Limit: parseLimit(c),
+		Count: post.CountMode(c.Query("count", string(post.CountExact))),
+	}
+	switch query.Count {
+	case post.CountExact, post.CountEstimate, post.CountNone:
+	default:
+		return httperr.BadRequest("count must be exact, estimate or none")
+	}
+
+	// Translate legacy page/page_size into an offset until clients move to cursors
+	var page, pageSize int
+	legacy := cursor == nil && (c.Query("page") != "" || c.Query("page_size") != "")
+	if legacy {
+		page, pageSize = parsePagination(c)
+		query.Offset = (page - 1) * pageSize
+		query.Limit = pageSize
+		c.Set("Deprecation", "true")
+		c.Set("Warning", `299 - "page and page_size are deprecated, use cursor and limit"`)
+	}
+
+	result, err := h.postService.ListPosts(c.Context(), user.ID, filter, query)
+	if err != nil {
+		return httperr.Internal("Failed to list posts", err)
+	}
+
+	items, err := postResponses(c.Context(), h.userService, result.Posts)
+	if err != nil {
+		return httperr.Internal("Failed to load post authors", err)
 	}
 
 	return c.JSON(PostListResponse{
-		Posts:      posts,
-		TotalCount: 1,
-		Page:       page,
-		PageSize:   pageSize,
-		HasMore:    false,
+		Posts:               items,
+		TotalCount:          result.TotalCount,
+		TotalCountEstimated: result.TotalCountEstimated,
+		Page:                page,
+		PageSize:            pageSize,
+		NextCursor:          result.NextCursor,
+		HasMore:             result.NextCursor != "",
 	})
 }
 
 // GetPost retrieves a specific post by ID
 // @Summary Get post by ID
-// @Description Retrieve a specific post by its ID
+// @Description Retrieve a specific post by its ID. The response carries an ETag that changes whenever the post, its counts or its author's profile do; sending it back in If-None-Match answers 304 with no body while nothing changed. Media URLs are not part of the ETag, so a client keeping a copy past the URLs' expiry should fetch it again without If-None-Match.
 // @Tags Posts
 // @Produce json
 // @Param id path string true "Post ID"
+// @Param If-None-Match header string false "ETag of the copy the client holds"
 // @Success 200 {object} PostResponse
+// @Success 304 "Not Modified"
 // @Failure 404 {object} ErrorResponse
 // @Security BearerAuth
 // @Router /api/posts/{id} [get]
 func (h *PostHandler) GetPost(c *fiber.Ctx) error {
-	postID := c.Params("id")
-	if postID == "" {
-		return c.Status(400).JSON(ErrorResponse{
-			Error: "Post ID is required",
-		})
-	}
-
-	h.logger.Info("Getting post", "post_id", postID)
-
-	// Mock response
-	return c.JSON(PostResponse{
-		ID:            postID,
-		Title:         "Sample Post",
-		Content:       "This is a sample post content",
-		MediaFiles:    []string{"image1.jpg"},
-		Tags:          []string{"sample", "test"},
-		IsPrivate:     false,
-		AuthorID:      uuid.New().String(),
-		LikesCount:    10,
-		CommentsCount: 5,
-		CreatedAt:     "2024-01-01T00:00:00Z",
-		UpdatedAt:     "2024-01-01T00:00:00Z",
+	user, ok := c.Locals("user").(*auth.User)
+	if !ok {
+		return httperr.Unauthenticated("Not authenticated")
+	}
+
+	postID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return httperr.BadRequest("Invalid post ID")
+	}
+
+	p, err := h.postService.GetPost(c.Context(), postID, user.ID)
+	if err != nil {
+		if errors.Is(err, post.ErrPostNotFound) {
+			return httperr.NotFound("Post not found")
+		}
+		return httperr.Internal("Failed to get post", err, "post_id", postID)
+	}
+
+	resp, err := postResponse(c.Context(), h.userService, p)
+	if err != nil {
+		return httperr.Internal("Failed to load post author", err, "post_id", p.ID)
+	}
+
+	etag, err := postETag(resp)
+	if err != nil {
+		return httperr.Internal("Failed to compute post ETag", err, "post_id", p.ID)
+	}
+
+	// Responses depend on the viewer, so shared caches must not keep them,
+	// and clients revalidate before every use
+	c.Set(fiber.HeaderETag, etag)
+	c.Set(fiber.HeaderCacheControl, "private, no-cache")
+	c.Vary(fiber.HeaderAuthorization)
+
+	if etagMatches(c.Get(fiber.HeaderIfNoneMatch), etag) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	return c.JSON(resp)
+}
+
+// BatchGetPosts retrieves several posts by ID
+// @Summary Get posts by IDs
+// @Description Retrieve up to 100 posts by ID in one call, in the order requested. Posts that don't exist, aren't published or that the caller can't see, such as private or blocked authors' posts, are left out rather than failing the request; repeated IDs are returned once.
+// @Tags Posts
+// @Accept json
+// @Produce json
+// @Param request body BatchGetPostsRequest true "Post IDs"
+// @Success 200 {object} PostListResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/posts/batch [post]
+func (h *PostHandler) BatchGetPosts(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*auth.User)
+	if !ok {
+		return httperr.Unauthenticated("Not authenticated")
+	}
+
+	var req BatchGetPostsRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	ids := make([]uuid.UUID, 0, len(req.IDs))
+	for _, raw := range req.IDs {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			return httperr.BadRequest("Invalid post ID")
+		}
+		ids = append(ids, id)
+	}
+
+	posts, err := h.postService.GetPostsByIDs(c.Context(), ids, user.ID)
+	if err != nil {
+		if errors.Is(err, post.ErrBatchTooLarge) {
+			return httperr.BadRequest(err.Error())
+		}
+		return httperr.Internal("Failed to get posts", err)
+	}
+
+	items, err := postResponses(c.Context(), h.userService, posts)
+	if err != nil {
+		return httperr.Internal("Failed to load post authors", err)
+	}
+
+	return c.JSON(PostListResponse{
+		Posts:      items,
+		TotalCount: countOf(len(items)),
 	})
 }
 
 // UpdatePost updates an existing post
 // @Summary Update post
-// @Description Update an existing post by ID
+// @Description Update an existing post by ID. Only its author, or a user with the admin role, may edit it; others get 403 with code NOT_POST_OWNER. The request must carry the version the client last read; if the post changed since, 409 is returned and the client should refetch.
 // @Tags Posts
 // @Accept json
 // @Produce json
@@ -219,62 +383,66 @@ func (h *PostHandler) GetPost(c *fiber.Ctx) error {
 // @Failure 401 {object} ErrorResponse
 // @Failure 403 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
 // @Security BearerAuth
 // @Router /api/posts/{id} [put]
 func (h *PostHandler) UpdatePost(c *fiber.Ctx) error {
 	user, ok := c.Locals("user").(*auth.User)
 	if !ok {
-		return c.Status(401).JSON(ErrorResponse{
-			Error: "Not authenticated",
-		})
+		return httperr.Unauthenticated("Not authenticated")
 	}
 
-	postID := c.Params("id")
-	if postID == "" {
-		return c.Status(400).JSON(ErrorResponse{
-			Error: "Post ID is required",
-		})
+	postID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return httperr.BadRequest("Invalid post ID")
 	}
 
 	var req UpdatePostRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(400).JSON(ErrorResponse{
-			Error: "Invalid request body",
-		})
+	if err := parseBody(c, &req); err != nil {
+		return err
 	}
 
-	h.logger.Info("Updating post", "post_id", postID, "user_id", user.ID)
-
-	// Mock response
-	title := "Updated Post Title"
-	if req.Title != nil {
-		title = *req.Title
+	p, err := h.postService.UpdatePost(c.Context(), postID, postActor(user), post.UpdatePostInput{
+		Title:            req.Title,
+		Content:          req.Content,
+		Caption:          req.Caption,
+		IsPrivate:        req.IsPrivate,
+		Tags:             req.Tags,
+		CommentsDisabled: req.CommentsDisabled,
+		HideLikeCount:    req.HideLikeCount,
+		Version:          req.Version,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, post.ErrPostNotFound):
+			return httperr.NotFound("Post not found")
+		case errors.Is(err, post.ErrNotPostOwner):
+			return httperr.New(fiber.StatusForbidden, httperr.CodeNotPostOwner, "You can only edit your own posts")
+		case errors.Is(err, post.ErrVersionConflict):
+			return httperr.Conflict("Post was modified by another request, refetch and retry")
+		case errors.Is(err, post.ErrTooManyTags):
+			return httperr.BadRequest(err.Error())
+		case errors.Is(err, moderation.ErrContentRejected):
+			return httperr.New(fiber.StatusBadRequest, httperr.CodeContentRejected, err.Error())
+		}
+		return httperr.Internal("Failed to update post", err, "post_id", postID)
 	}
 
-	content := "Updated post content"
-	if req.Content != nil {
-		content = *req.Content
+	resp, err := postResponse(c.Context(), h.userService, p)
+	if err != nil {
+		return httperr.Internal("Failed to load post author", err, "post_id", p.ID)
 	}
 
-	return c.JSON(PostResponse{
-		ID:            postID,
-		Title:         title,
-		Content:       content,
-		Tags:          req.Tags,
-		AuthorID:      user.ID.String(),
-		LikesCount:    10,
-		CommentsCount: 5,
-		CreatedAt:     "2024-01-01T00:00:00Z",
-		UpdatedAt:     "2024-01-01T01:00:00Z",
-	})
+	return c.JSON(resp)
 }
 
 // DeletePost deletes a post
 // @Summary Delete post
-// @Description Delete a post by ID
+// @Description Delete one of the caller's posts by ID; users with the admin role may delete any post, others get 403 with code NOT_POST_OWNER. The post is hidden from every listing and its author's post count, while its comments are kept for moderation. Reposts of it remain and show the original as unavailable.
 // @Tags Posts
 // @Param id path string true "Post ID"
 // @Success 204 "No Content"
+// @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
 // @Failure 403 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
@@ -283,20 +451,133 @@ func (h *PostHandler) UpdatePost(c *fiber.Ctx) error {
 func (h *PostHandler) DeletePost(c *fiber.Ctx) error {
 	user, ok := c.Locals("user").(*auth.User)
 	if !ok {
-		return c.Status(401).JSON(ErrorResponse{
-			Error: "Not authenticated",
-		})
+		return httperr.Unauthenticated("Not authenticated")
 	}
 
-	postID := c.Params("id")
-	if postID == "" {
-		return c.Status(400).JSON(ErrorResponse{
-			Error: "Post ID is required",
-		})
+	postID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return httperr.BadRequest("Invalid post ID")
 	}
 
-	h.logger.Info("Deleting post", "post_id", postID, "user_id", user.ID)
+	if err := h.postService.DeletePost(c.Context(), postID, postActor(user)); err != nil {
+		switch {
+		case errors.Is(err, post.ErrPostNotFound):
+			return httperr.NotFound("Post not found")
+		case errors.Is(err, post.ErrNotPostOwner):
+			return httperr.New(fiber.StatusForbidden, httperr.CodeNotPostOwner, "You can only delete your own posts")
+		}
+		return httperr.Internal("Failed to delete post", err, "post_id", postID)
+	}
 
-	// Return 204 No Content for successful deletion
 	return c.SendStatus(204)
 }
+
+// postResponses converts posts, loading the authors of the posts and of the
+// posts they repost in a single query
+func postResponses(ctx context.Context, users user.Service, posts []*post.Post) ([]PostResponse, error) {
+	authors := user.NewLoader(users)
+	if err := authors.LoadMany(ctx, post.AuthorIDs(posts)); err != nil {
+		return nil, err
+	}
+
+	items := make([]PostResponse, 0, len(posts))
+	for _, p := range posts {
+		items = append(items, toPostResponse(p, authors))
+	}
+	return items, nil
+}
+
+// postResponse converts a single post along with its author
+func postResponse(ctx context.Context, users user.Service, p *post.Post) (PostResponse, error) {
+	items, err := postResponses(ctx, users, []*post.Post{p})
+	if err != nil {
+		return PostResponse{}, err
+	}
+	return items[0], nil
+}
+
+// toPostResponse converts a post, taking its author from a loaded batch
+func toPostResponse(p *post.Post, authors *user.Loader) PostResponse {
+	resp := PostResponse{
+		ID:               p.ID.String(),
+		Title:            p.Title,
+		Content:          p.Content,
+		MediaFiles:       make([]string, 0, len(p.Media)),
+		Tags:             append([]string{}, p.Tags...),
+		IsPrivate:        p.IsPrivate,
+		CommentsDisabled: p.CommentsDisabled,
+		HideLikeCount:    p.HideLikeCount,
+		Latitude:         p.Latitude,
+		Longitude:        p.Longitude,
+		DistanceMeters:   p.Distance,
+		AuthorID:         p.UserID.String(),
+		LikesCount:       p.LikesCount,
+		CommentsCount:    p.CommentsCount,
+		RepostsCount:     p.RepostsCount,
+		ViewsCount:       p.ViewsCount,
+		ViewerHasSaved:   p.ViewerHasSaved,
+		Status:           p.Status,
+		Version:          p.Version,
+		CreatedAt:        p.CreatedAt.UTC().Format(time.RFC3339),
+		UpdatedAt:        p.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+
+	if u := authors.Get(p.UserID); u != nil {
+		resp.Author = toUserSummary(u)
+	}
+	if p.Caption != nil {
+		resp.Caption = *p.Caption
+	}
+	if p.Location != nil {
+		resp.Location = *p.Location
+	}
+	if p.ScheduledAt != nil {
+		resp.ScheduledAt = p.ScheduledAt.UTC().Format(time.RFC3339)
+	}
+	if p.OriginalPostID != nil {
+		resp.OriginalPostID = p.OriginalPostID.String()
+		if p.Original != nil {
+			original := toPostResponse(p.Original, authors)
+			resp.Original = &OriginalPostResponse{PostResponse: &original}
+		} else {
+			resp.Original = &OriginalPostResponse{
+				Unavailable: true,
+				Message:     "This post is unavailable",
+			}
+		}
+	}
+
+	for _, m := range p.Media {
+		resp.MediaFiles = append(resp.MediaFiles, m.OriginalKey)
+		resp.Media = append(resp.Media, toMediaResponse(m))
+	}
+
+	return resp
+}
+
+// postActor returns user as the actor of a post change
+func postActor(user *auth.User) post.Actor {
+	return post.Actor{UserID: user.ID, Admin: user.HasRole(auth.RoleAdmin)}
+}
+
+// optionalString returns nil for empty strings
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// derefString returns "" for nil strings
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// countOf returns n as the total_count of a list response
+func countOf(n int) *int64 {
+	total := int64(n)
+	return &total
+}
@@ -0,0 +1,367 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"testing"
+
+	"fowergram-backend/internal/domain/post"
+	"fowergram-backend/internal/domain/user"
+	"fowergram-backend/pkg/auth"
+	"fowergram-backend/pkg/errreport"
+	"fowergram-backend/pkg/httperr"
+	"fowergram-backend/pkg/logger"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// fakePostService lets the author and admins delete a post, recording the
+// actor of each call, and gets and lists the posts it holds. Other methods are left
+// to the embedded nil Service.
+type fakePostService struct {
+	post.Service
+
+	authorID uuid.UUID
+	actors   []post.Actor
+	posts    []*post.Post
+	lists    []listCall
+}
+
+func (s *fakePostService) DeletePost(ctx context.Context, id uuid.UUID, actor post.Actor) error {
+	s.actors = append(s.actors, actor)
+	if !actor.Admin && actor.UserID != s.authorID {
+		return post.ErrNotPostOwner
+	}
+	return nil
+}
+
+func (s *fakePostService) GetPost(ctx context.Context, id, viewerID uuid.UUID) (*post.Post, error) {
+	for _, p := range s.posts {
+		if p.ID == id {
+			return p, nil
+		}
+	}
+	return nil, post.ErrPostNotFound
+}
+
+// postsApp serves the post routes of handler as user
+func postsApp(handler *PostHandler, user *auth.User) *fiber.App {
+	app := fiber.New(fiber.Config{ErrorHandler: httperr.Handler(logger.NewZapLogger(), errreport.Nop())})
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("user", user)
+		return c.Next()
+	})
+	app.Get("/posts", handler.GetPosts)
+	app.Get("/posts/:id", handler.GetPost)
+	app.Delete("/posts/:id", handler.DeletePost)
+	return app
+}
+
+func TestDeletePostAuthorization(t *testing.T) {
+	authorID := uuid.New()
+
+	tests := []struct {
+		name       string
+		user       *auth.User
+		wantStatus int
+		wantCode   string
+		wantAdmin  bool
+	}{
+		{name: "author", user: &auth.User{ID: authorID}, wantStatus: fiber.StatusNoContent},
+		{
+			name:       "another user",
+			user:       &auth.User{ID: uuid.New()},
+			wantStatus: fiber.StatusForbidden,
+			wantCode:   httperr.CodeNotPostOwner,
+		},
+		{
+			name:       "admin",
+			user:       &auth.User{ID: uuid.New(), Roles: []string{auth.RoleAdmin}},
+			wantStatus: fiber.StatusNoContent,
+			wantAdmin:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &fakePostService{authorID: authorID}
+			app := postsApp(NewPostHandler(service, nil, logger.NewZapLogger()), tt.user)
+
+			resp, err := app.Test(httptest.NewRequest(http.MethodDelete, "/posts/"+uuid.NewString(), nil), -1)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantCode != "" {
+				var body httperr.Response
+				if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+					t.Fatalf("decoding the error: %v", err)
+				}
+				if body.Code != tt.wantCode {
+					t.Errorf("code = %q, want %q", body.Code, tt.wantCode)
+				}
+			}
+
+			want := post.Actor{UserID: tt.user.ID, Admin: tt.wantAdmin}
+			if len(service.actors) != 1 || service.actors[0] != want {
+				t.Errorf("actors = %+v, want %+v", service.actors, want)
+			}
+		})
+	}
+}
+
+// listCall records the arguments of a ListPosts call
+type listCall struct {
+	filter post.ListPostsFilter
+	query  post.ListPostsQuery
+}
+
+func (s *fakePostService) ListPosts(ctx context.Context, viewerID uuid.UUID, filter post.ListPostsFilter, q post.ListPostsQuery) (*post.Page, error) {
+	s.lists = append(s.lists, listCall{filter: filter, query: q})
+	page := &post.Page{Posts: []*post.Post{}}
+	for _, p := range s.posts {
+		if (filter.AuthorID == nil || p.UserID == *filter.AuthorID) && (filter.Tag == "" || slices.Contains(p.Tags, filter.Tag)) {
+			page.Posts = append(page.Posts, p)
+		}
+	}
+	switch q.Count {
+	case post.CountExact:
+		total := int64(len(page.Posts))
+		page.TotalCount = &total
+	case post.CountEstimate:
+		total := int64(fakeEstimate)
+		page.TotalCount = &total
+		page.TotalCountEstimated = true
+	}
+	return page, nil
+}
+
+// fakeEstimate is the total fakePostService gives for count=estimate, as the
+// planner's estimate can be well off the exact count
+const fakeEstimate = 1000
+
+// fakeUserService looks up the users it holds. Other methods are left to
+// the embedded nil Service.
+type fakeUserService struct {
+	user.Service
+
+	users   []*auth.User
+	counts  map[uuid.UUID]*user.Counts // Recomputed by ReconcileCounts
+	lookups int                        // Calls to GetUsersByIDs
+}
+
+func (s *fakeUserService) ReconcileCounts(ctx context.Context, userID uuid.UUID) (*user.Counts, error) {
+	counts, ok := s.counts[userID]
+	if !ok {
+		return nil, auth.ErrUserNotFound
+	}
+	return counts, nil
+}
+
+func (s *fakeUserService) GetUsersByIDs(ctx context.Context, ids []uuid.UUID) ([]*auth.User, error) {
+	s.lookups++
+	var found []*auth.User
+	for _, u := range s.users {
+		if slices.Contains(ids, u.ID) {
+			found = append(found, u)
+		}
+	}
+	return found, nil
+}
+
+func TestGetPostsFilters(t *testing.T) {
+	alice := &auth.User{ID: uuid.New(), Username: "alice"}
+	bob := &auth.User{ID: uuid.New(), Username: "bob"}
+	beach := &post.Post{ID: uuid.New(), UserID: alice.ID, Title: "Beach", Tags: []string{"beach"}}
+	city := &post.Post{ID: uuid.New(), UserID: bob.ID, Title: "City", Tags: []string{"city"}}
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantFilter post.ListPostsFilter // Passed to the service when the request is valid
+		wantLimit  int
+		wantTitles []string
+	}{
+		{
+			name:       "no filters",
+			wantStatus: fiber.StatusOK,
+			wantLimit:  defaultPageSize,
+			wantTitles: []string{"Beach", "City"},
+		},
+		{
+			name:       "by author",
+			query:      "author_id=" + bob.ID.String(),
+			wantStatus: fiber.StatusOK,
+			wantFilter: post.ListPostsFilter{AuthorID: &bob.ID},
+			wantLimit:  defaultPageSize,
+			wantTitles: []string{"City"},
+		},
+		{
+			name:       "by tag with a limit",
+			query:      "tag=beach&limit=5",
+			wantStatus: fiber.StatusOK,
+			wantFilter: post.ListPostsFilter{Tag: "beach"},
+			wantLimit:  5,
+			wantTitles: []string{"Beach"},
+		},
+		{
+			name:       "filters without matches",
+			query:      "author_id=" + bob.ID.String() + "&tag=beach",
+			wantStatus: fiber.StatusOK,
+			wantFilter: post.ListPostsFilter{AuthorID: &bob.ID, Tag: "beach"},
+			wantLimit:  defaultPageSize,
+			wantTitles: []string{},
+		},
+		{name: "author ID that isn't a UUID", query: "author_id=alice", wantStatus: fiber.StatusBadRequest},
+		{name: "malformed cursor", query: "cursor=not-a-cursor", wantStatus: fiber.StatusBadRequest},
+		{name: "unknown count mode", query: "count=roughly", wantStatus: fiber.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &fakePostService{posts: []*post.Post{beach, city}}
+			users := &fakeUserService{users: []*auth.User{alice, bob}}
+			app := postsApp(NewPostHandler(service, users, logger.NewZapLogger()), alice)
+
+			resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/posts?"+tt.query, nil), -1)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantStatus != fiber.StatusOK {
+				if len(service.lists) != 0 {
+					t.Errorf("listed posts for an invalid request: %+v", service.lists)
+				}
+				return
+			}
+
+			if len(service.lists) != 1 {
+				t.Fatalf("ListPosts called %d times, want once", len(service.lists))
+			}
+			if got := service.lists[0]; !reflect.DeepEqual(got.filter, tt.wantFilter) || got.query.Limit != tt.wantLimit {
+				t.Errorf("listed with %+v, limit %d; want %+v, limit %d", got.filter, got.query.Limit, tt.wantFilter, tt.wantLimit)
+			}
+
+			var body PostListResponse
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("decoding the posts: %v", err)
+			}
+			titles := []string{}
+			for _, p := range body.Posts {
+				titles = append(titles, p.Title)
+			}
+			if !slices.Equal(titles, tt.wantTitles) || body.Posts == nil || body.HasMore {
+				t.Errorf("posts = %q, has more %v; want %q and no more", titles, body.HasMore, tt.wantTitles)
+			}
+		})
+	}
+}
+
+func TestGetPostsCountModes(t *testing.T) {
+	alice := &auth.User{ID: uuid.New(), Username: "alice"}
+	posts := []*post.Post{{ID: uuid.New(), UserID: alice.ID}, {ID: uuid.New(), UserID: alice.ID}}
+
+	tests := []struct {
+		name          string
+		query         string
+		wantMode      post.CountMode
+		wantTotal     interface{} // Decoded total_count; nil when left out
+		wantEstimated interface{} // Decoded total_count_estimated; nil when left out
+	}{
+		{name: "default", wantMode: post.CountExact, wantTotal: float64(len(posts))},
+		{name: "exact", query: "count=exact", wantMode: post.CountExact, wantTotal: float64(len(posts))},
+		{name: "estimate", query: "count=estimate", wantMode: post.CountEstimate, wantTotal: float64(fakeEstimate), wantEstimated: true},
+		{name: "none", query: "count=none", wantMode: post.CountNone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &fakePostService{posts: posts}
+			users := &fakeUserService{users: []*auth.User{alice}}
+			app := postsApp(NewPostHandler(service, users, logger.NewZapLogger()), alice)
+
+			resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/posts?"+tt.query, nil), -1)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != fiber.StatusOK {
+				t.Fatalf("status = %d, want 200", resp.StatusCode)
+			}
+			if len(service.lists) != 1 || service.lists[0].query.Count != tt.wantMode {
+				t.Fatalf("listed with %+v, want count mode %q", service.lists, tt.wantMode)
+			}
+
+			var body map[string]interface{}
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("decoding the posts: %v", err)
+			}
+			if got := body["total_count"]; got != tt.wantTotal {
+				t.Errorf("total_count = %v, want %v", got, tt.wantTotal)
+			}
+			if got := body["total_count_estimated"]; got != tt.wantEstimated {
+				t.Errorf("total_count_estimated = %v, want %v", got, tt.wantEstimated)
+			}
+		})
+	}
+}
+
+func TestPostAuthorsBatched(t *testing.T) {
+	alice := &auth.User{ID: uuid.New(), Username: "alice"}
+	bob := &auth.User{ID: uuid.New(), Username: "bob"}
+	carol := uuid.New() // Deactivated
+	authorIDs := []uuid.UUID{alice.ID, bob.ID, carol}
+	var posts []*post.Post
+	for i := range 50 {
+		posts = append(posts, &post.Post{ID: uuid.New(), UserID: authorIDs[i%len(authorIDs)], Title: "post"})
+	}
+
+	users := &fakeUserService{users: []*auth.User{alice, bob}}
+	app := postsApp(NewPostHandler(&fakePostService{posts: posts}, users, logger.NewZapLogger()), alice)
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/posts", nil), -1)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var body PostListResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("decoding the posts: %v", err)
+	}
+	if len(body.Posts) != len(posts) {
+		t.Fatalf("%d posts, want %d", len(body.Posts), len(posts))
+	}
+	if users.lookups != 1 {
+		t.Errorf("looked authors up %d times, want once", users.lookups)
+	}
+	for i, p := range body.Posts {
+		var want string
+		switch posts[i].UserID {
+		case alice.ID:
+			want = "alice"
+		case bob.ID:
+			want = "bob"
+		}
+		var got string
+		if p.Author != nil {
+			got = p.Author.Username
+		}
+		if got != want {
+			t.Errorf("post %d by %q, want %q", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"errors"
+
+	"fowergram-backend/internal/domain/post"
+	"fowergram-backend/pkg/auth"
+	"fowergram-backend/pkg/httperr"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// RepostRequest represents the optional quote caption of a repost
+type RepostRequest struct {
+	Caption string `json:"caption,omitempty" validate:"max=2200"`
+}
+
+// RepostPost reposts a post
+// @Summary Repost a post
+// @Description Repost a post the caller can see, optionally with a quote caption. Reposting a repost by someone else reposts its original. Private posts, the caller's own reposts and posts already reposted are rejected.
+// @Tags Posts
+// @Accept json
+// @Produce json
+// @Param id path string true "Post ID"
+// @Param request body RepostRequest false "Quote caption"
+// @Success 201 {object} PostResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/posts/{id}/repost [post]
+func (h *PostHandler) RepostPost(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*auth.User)
+	if !ok {
+		return httperr.Unauthenticated("Not authenticated")
+	}
+
+	postID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return httperr.BadRequest("Invalid post ID")
+	}
+
+	// The body is optional; a plain repost carries none
+	var req RepostRequest
+	if len(c.Body()) > 0 {
+		if err := parseBody(c, &req); err != nil {
+			return err
+		}
+	}
+
+	p, err := h.postService.RepostPost(c.Context(), postID, user.ID, optionalString(req.Caption))
+	if err != nil {
+		switch {
+		case errors.Is(err, post.ErrPostNotFound):
+			return httperr.NotFound("Post not found")
+		case errors.Is(err, post.ErrRepostPrivate):
+			return httperr.Forbidden(err.Error())
+		case errors.Is(err, post.ErrRepostOwnRepost), errors.Is(err, post.ErrTooManyTags):
+			return httperr.BadRequest(err.Error())
+		case errors.Is(err, post.ErrAlreadyReposted):
+			return httperr.Conflict(err.Error())
+		}
+		return httperr.Internal("Failed to repost post", err, "post_id", postID)
+	}
+
+	resp, err := postResponse(c.Context(), h.userService, p)
+	if err != nil {
+		return httperr.Internal("Failed to load post author", err, "post_id", p.ID)
+	}
+
+	return c.Status(201).JSON(resp)
+}
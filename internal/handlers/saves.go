@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"fowergram-backend/pkg/auth"
+	"fowergram-backend/pkg/httperr"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// SavePost bookmarks a post
+// @Summary Save post
+// @Description Bookmark a post; saving an already saved post has no effect
+// @Tags Posts
+// @Produce json
+// @Param id path string true "Post ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/posts/{id}/save [post]
+func (h *PostHandler) SavePost(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*auth.User)
+	if !ok {
+		return httperr.Unauthenticated("Not authenticated")
+	}
+
+	postID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return httperr.BadRequest("Invalid post ID")
+	}
+
+	if err := h.postService.SavePost(c.Context(), postID, user.ID); err != nil {
+		return likeError("Failed to save post", postID, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Post saved",
+	})
+}
+
+// UnsavePost removes a bookmark from a post
+// @Summary Unsave post
+// @Description Remove a bookmark; unsaving a post that isn't saved has no effect
+// @Tags Posts
+// @Produce json
+// @Param id path string true "Post ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/posts/{id}/save [delete]
+func (h *PostHandler) UnsavePost(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*auth.User)
+	if !ok {
+		return httperr.Unauthenticated("Not authenticated")
+	}
+
+	postID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return httperr.BadRequest("Invalid post ID")
+	}
+
+	if err := h.postService.UnsavePost(c.Context(), postID, user.ID); err != nil {
+		return likeError("Failed to unsave post", postID, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Post unsaved",
+	})
+}
+
+// GetSavedPosts lists the current user's saved posts
+// @Summary Get saved posts
+// @Description Retrieve the current user's bookmarked posts, most recently saved first. Posts that are no longer visible are left out.
+// @Tags Posts
+// @Produce json
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(10)
+// @Success 200 {object} PostListResponse
+// @Failure 401 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/users/me/saved [get]
+func (h *PostHandler) GetSavedPosts(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*auth.User)
+	if !ok {
+		return httperr.Unauthenticated("Not authenticated")
+	}
+
+	page, pageSize := parsePagination(c)
+
+	// Fetch one extra row to know whether another page exists
+	posts, err := h.postService.GetSavedPosts(c.Context(), user.ID, pageSize+1, (page-1)*pageSize)
+	if err != nil {
+		return httperr.Internal("Failed to get saved posts", err)
+	}
+
+	hasMore := len(posts) > pageSize
+	if hasMore {
+		posts = posts[:pageSize]
+	}
+
+	items, err := postResponses(c.Context(), h.userService, posts)
+	if err != nil {
+		return httperr.Internal("Failed to load post authors", err)
+	}
+
+	return c.JSON(PostListResponse{
+		Posts:      items,
+		TotalCount: countOf(len(items)),
+		Page:       page,
+		PageSize:   pageSize,
+		HasMore:    hasMore,
+	})
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"errors"
+
+	"fowergram-backend/internal/domain/post"
+	"fowergram-backend/pkg/auth"
+	"fowergram-backend/pkg/httperr"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// SearchPostsResponse represents posts matching a search, best match first
+type SearchPostsResponse struct {
+	Query    string         `json:"query"`
+	Posts    []PostResponse `json:"posts"`
+	Page     int            `json:"page"`
+	PageSize int            `json:"page_size"`
+	HasMore  bool           `json:"has_more"`
+}
+
+// SearchPosts searches posts by text
+// @Summary Search posts
+// @Description Full-text search over post titles, captions and content, ranked by relevance. Supports quoted phrases, OR and -exclusions. Private posts are only included for their author.
+// @Tags Posts
+// @Produce json
+// @Param q query string true "Search text"
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(10)
+// @Success 200 {object} SearchPostsResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/posts/search [get]
+func (h *PostHandler) SearchPosts(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*auth.User)
+	if !ok {
+		return httperr.Unauthenticated("Not authenticated")
+	}
+
+	text := c.Query("q")
+	page, pageSize := parsePagination(c)
+
+	// Fetch one extra row to know whether another page exists
+	posts, err := h.postService.SearchPosts(c.Context(), user.ID, post.SearchQuery{
+		Text:   text,
+		Limit:  pageSize + 1,
+		Offset: (page - 1) * pageSize,
+	})
+	if err != nil {
+		if errors.Is(err, post.ErrEmptySearchQuery) {
+			return httperr.BadRequest(err.Error())
+		}
+		return httperr.Internal("Failed to search posts", err)
+	}
+
+	hasMore := len(posts) > pageSize
+	if hasMore {
+		posts = posts[:pageSize]
+	}
+
+	items, err := postResponses(c.Context(), h.userService, posts)
+	if err != nil {
+		return httperr.Internal("Failed to load post authors", err)
+	}
+
+	return c.JSON(SearchPostsResponse{
+		Query:    text,
+		Posts:    items,
+		Page:     page,
+		PageSize: pageSize,
+		HasMore:  hasMore,
+	})
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"net/url"
+
+	"fowergram-backend/internal/domain/post"
+	"fowergram-backend/internal/domain/user"
+	"fowergram-backend/pkg/auth"
+	"fowergram-backend/pkg/httperr"
+	"fowergram-backend/pkg/logger"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type TagHandler struct {
+	postService post.Service
+	userService user.Service // Resolves post authors
+	logger      logger.Logger
+}
+
+func NewTagHandler(postService post.Service, userService user.Service, logger logger.Logger) *TagHandler {
+	return &TagHandler{
+		postService: postService,
+		userService: userService,
+		logger:      logger,
+	}
+}
+
+// TagResponse represents a hashtag in API responses
+type TagResponse struct {
+	Name      string `json:"name"`
+	PostCount int    `json:"post_count"`
+}
+
+// TagSearchResponse represents tag autocomplete suggestions
+type TagSearchResponse struct {
+	Tags []TagResponse `json:"tags"`
+}
+
+// GetTagPosts lists posts with a hashtag
+// @Summary Get tagged posts
+// @Description Retrieve a paginated list of posts with a hashtag, newest first. Only posts visible to the caller are included.
+// @Tags Tags
+// @Produce json
+// @Param tag path string true "Hashtag, with or without the leading #"
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(10)
+// @Success 200 {object} PostListResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/tags/{tag}/posts [get]
+func (h *TagHandler) GetTagPosts(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*auth.User)
+	if !ok {
+		return httperr.Unauthenticated("Not authenticated")
+	}
+
+	// Non-ASCII tags arrive percent-encoded in the path
+	tag, err := url.PathUnescape(c.Params("tag"))
+	if err != nil {
+		return httperr.BadRequest("Invalid tag")
+	}
+
+	page, pageSize := parsePagination(c)
+
+	// Fetch one extra row to know whether another page exists
+	posts, err := h.postService.GetTagPosts(c.Context(), tag, user.ID, pageSize+1, (page-1)*pageSize)
+	if err != nil {
+		return httperr.Internal("Failed to get tagged posts", err, "tag", tag)
+	}
+
+	hasMore := len(posts) > pageSize
+	if hasMore {
+		posts = posts[:pageSize]
+	}
+
+	items, err := postResponses(c.Context(), h.userService, posts)
+	if err != nil {
+		return httperr.Internal("Failed to load post authors", err)
+	}
+
+	return c.JSON(PostListResponse{
+		Posts:      items,
+		TotalCount: countOf(len(items)),
+		Page:       page,
+		PageSize:   pageSize,
+		HasMore:    hasMore,
+	})
+}
+
+// SearchTags suggests hashtags for autocomplete
+// @Summary Search tags
+// @Description Suggest hashtags starting with the query, most used first
+// @Tags Tags
+// @Produce json
+// @Param q query string true "Tag prefix"
+// @Param limit query int false "Maximum number of suggestions" default(10)
+// @Success 200 {object} TagSearchResponse
+// @Failure 401 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/tags/search [get]
+func (h *TagHandler) SearchTags(c *fiber.Ctx) error {
+	tags, err := h.postService.SearchTags(c.Context(), c.Query("q"), parseLimit(c))
+	if err != nil {
+		return httperr.Internal("Failed to search tags", err, "query", c.Query("q"))
+	}
+
+	items := make([]TagResponse, 0, len(tags))
+	for _, t := range tags {
+		items = append(items, TagResponse{
+			Name:      t.Name,
+			PostCount: t.PostCount,
+		})
+	}
+
+	return c.JSON(TagSearchResponse{
+		Tags: items,
+	})
+}
@@ -0,0 +1,600 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"fowergram-backend/internal/domain/media"
+	"fowergram-backend/internal/domain/user"
+	"fowergram-backend/pkg/auth"
+	"fowergram-backend/pkg/httperr"
+	"fowergram-backend/pkg/logger"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type UserHandler struct {
+	userService user.Service
+	logger      logger.Logger
+}
+
+func NewUserHandler(userService user.Service, logger logger.Logger) *UserHandler {
+	return &UserHandler{
+		userService: userService,
+		logger:      logger,
+	}
+}
+
+// UserSummaryResponse represents a user in list responses
+type UserSummaryResponse struct {
+	ID             string `json:"id"`
+	Username       string `json:"username"`
+	FullName       string `json:"full_name,omitempty"`
+	ProfilePicture string `json:"profile_picture,omitempty"`
+	IsVerified     bool   `json:"is_verified"`
+	IsPrivate      bool   `json:"is_private"`
+}
+
+// UserListResponse represents a page of users
+type UserListResponse struct {
+	Users    []UserSummaryResponse `json:"users"`
+	Page     int                   `json:"page"`
+	PageSize int                   `json:"page_size"`
+	HasMore  bool                  `json:"has_more"`
+}
+
+// UpdateProfileRequest represents the request to update the current user's profile
+type UpdateProfileRequest struct {
+	Username       *string `json:"username,omitempty" validate:"omitempty,username"`
+	FullName       *string `json:"full_name,omitempty" validate:"omitempty,max=100"`
+	Bio            *string `json:"bio,omitempty" validate:"omitempty,max=500"`
+	ProfilePicture *string `json:"profile_picture,omitempty"`
+	IsPrivate      *bool   `json:"is_private,omitempty"`
+	Version        int     `json:"version" validate:"required,min=1"` // Version the edit is based on
+}
+
+// ProfileResponse represents the current user's profile
+type ProfileResponse struct {
+	ID             string `json:"id"`
+	Username       string `json:"username"`
+	FullName       string `json:"full_name,omitempty"`
+	Bio            string `json:"bio,omitempty"`
+	ProfilePicture string `json:"profile_picture,omitempty"`
+	IsPrivate      bool   `json:"is_private"`
+	Version        int    `json:"version"`
+	UpdatedAt      string `json:"updated_at"`
+}
+
+// DeleteAccountRequest represents the request to permanently delete an account
+type DeleteAccountRequest struct {
+	Password string `json:"password" validate:"required"`
+}
+
+// UpdateProfile updates the current user's profile
+// @Summary Update profile
+// @Description Update the current user's profile. The request must carry the version the client last read; if the profile changed since, 409 is returned and the client should refetch.
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param request body UpdateProfileRequest true "Profile update"
+// @Success 200 {object} ProfileResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/users/me [put]
+func (h *UserHandler) UpdateProfile(c *fiber.Ctx) error {
+	current, ok := c.Locals("user").(*auth.User)
+	if !ok {
+		return httperr.Unauthenticated("Not authenticated")
+	}
+
+	var req UpdateProfileRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	updated, err := h.userService.UpdateUser(c.Context(), current.ID, user.UpdateUserInput{
+		Username:  req.Username,
+		FullName:  req.FullName,
+		Bio:       req.Bio,
+		Avatar:    req.ProfilePicture,
+		IsPrivate: req.IsPrivate,
+		Version:   req.Version,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, user.ErrVersionConflict):
+			return httperr.Conflict("Profile was modified by another request, refetch and retry")
+		case isAuthError(err):
+			return err
+		}
+		return httperr.Internal("Failed to update profile", err)
+	}
+
+	return c.JSON(toProfileResponse(updated))
+}
+
+// UploadAvatar sets the current user's profile picture from an uploaded image
+// @Summary Upload avatar
+// @Description Upload an image to use as the profile picture. It is center-cropped to a square and resized to 320px; the previous avatar is deleted.
+// @Tags Users
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "Image file"
+// @Success 200 {object} ProfileResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/users/me/avatar [post]
+func (h *UserHandler) UploadAvatar(c *fiber.Ctx) error {
+	current, ok := c.Locals("user").(*auth.User)
+	if !ok {
+		return httperr.Unauthenticated("Not authenticated")
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return httperr.BadRequest("File is required")
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return httperr.BadRequest("Invalid file")
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return httperr.BadRequest("Invalid file")
+	}
+
+	updated, err := h.userService.SetAvatar(c.Context(), current.ID, data, http.DetectContentType(data))
+	if err != nil {
+		if errors.Is(err, media.ErrImageTooLarge) {
+			return httperr.BadRequest(err.Error())
+		}
+		if errors.Is(err, media.ErrUnsupportedContentType) || errors.Is(err, media.ErrInvalidImage) {
+			return httperr.BadRequest("Unsupported image format")
+		}
+		if errors.Is(err, media.ErrStorageUnavailable) {
+			return httperr.Unavailable("Avatar uploads are unavailable")
+		}
+		return httperr.Internal("Failed to set avatar", err)
+	}
+
+	return c.JSON(toProfileResponse(updated))
+}
+
+// toProfileResponse converts a user to the current user's profile representation
+func toProfileResponse(u *user.User) ProfileResponse {
+	return ProfileResponse{
+		ID:             u.ID.String(),
+		Username:       u.Username,
+		FullName:       derefString(u.FullName),
+		Bio:            derefString(u.Bio),
+		ProfilePicture: derefString(u.Avatar),
+		IsPrivate:      u.IsPrivate,
+		Version:        u.Version,
+		UpdatedAt:      u.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+// DeleteAccount permanently deletes the current user's account
+// @Summary Delete account
+// @Description Permanently delete the current account with its posts, comments, likes, follows and sessions. Requires the account password. This cannot be undone.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param request body DeleteAccountRequest true "Password confirmation"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/auth/me [delete]
+func (h *UserHandler) DeleteAccount(c *fiber.Ctx) error {
+	current, ok := c.Locals("user").(*auth.User)
+	if !ok {
+		return httperr.Unauthenticated("Not authenticated")
+	}
+
+	var req DeleteAccountRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	if err := h.userService.DeleteAccount(c.Context(), current.ID, req.Password); err != nil {
+		if isAuthError(err) {
+			return err
+		}
+		return httperr.Internal("Failed to delete account", err)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Account deleted permanently",
+	})
+}
+
+// UserProfileResponse represents a user's profile as seen by the viewer
+type UserProfileResponse struct {
+	ID              string                   `json:"id"`
+	Username        string                   `json:"username"`
+	FullName        string                   `json:"full_name,omitempty"`
+	Bio             string                   `json:"bio,omitempty"`
+	ProfilePicture  string                   `json:"profile_picture,omitempty"`
+	IsPrivate       bool                     `json:"is_private"`
+	IsVerified      bool                     `json:"is_verified"`
+	PostsCount      int                      `json:"posts_count"`
+	FollowersCount  int                      `json:"followers_count"`
+	FollowingCount  int                      `json:"following_count"`
+	IsFollowing     bool                     `json:"is_following"`
+	IsFollowedBy    bool                     `json:"is_followed_by"`
+	MutualFollowers *MutualFollowersResponse `json:"mutual_followers,omitempty"` // Left out on your own profile and when the user's followers are hidden from you
+	CreatedAt       string                   `json:"created_at"`
+}
+
+// MutualFollowersResponse counts the accounts you follow that follow a user
+// and names a few of them, for "followed by a, b and 3 others"
+type MutualFollowersResponse struct {
+	Count int                   `json:"count"`
+	Users []UserSummaryResponse `json:"users"`
+}
+
+// GetProfile retrieves a user's profile
+// @Summary Get user profile
+// @Description Retrieve a user's profile with their counts, how they are connected to you and, unless their followers are hidden from you, how many of the accounts you follow also follow them, naming up to three. Users blocking you or blocked by you are not found.
+// @Tags Users
+// @Produce json
+// @Param id path string true "User ID"
+// @Success 200 {object} UserProfileResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/users/{id} [get]
+func (h *UserHandler) GetProfile(c *fiber.Ctx) error {
+	viewer, ok := c.Locals("user").(*auth.User)
+	if !ok {
+		return httperr.Unauthenticated("Not authenticated")
+	}
+
+	userID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return httperr.BadRequest("Invalid user ID")
+	}
+
+	profile, err := h.userService.GetProfile(c.Context(), userID, viewer.ID)
+	if err != nil {
+		if isAuthError(err) {
+			return err
+		}
+		return httperr.Internal("Failed to get profile", err, "target_id", userID)
+	}
+
+	return c.JSON(toUserProfileResponse(profile))
+}
+
+func toUserProfileResponse(p *user.UserProfile) UserProfileResponse {
+	resp := UserProfileResponse{
+		ID:             p.ID.String(),
+		Username:       p.Username,
+		FullName:       derefString(p.FullName),
+		Bio:            derefString(p.Bio),
+		ProfilePicture: derefString(p.Avatar),
+		IsPrivate:      p.IsPrivate,
+		IsVerified:     p.IsVerified,
+		PostsCount:     p.PostCount,
+		FollowersCount: p.FollowerCount,
+		FollowingCount: p.FollowingCount,
+		IsFollowing:    p.IsFollowing,
+		IsFollowedBy:   p.IsFollowedBy,
+		CreatedAt:      p.CreatedAt.UTC().Format(time.RFC3339),
+	}
+	if p.MutualFollowers != nil {
+		resp.MutualFollowers = &MutualFollowersResponse{
+			Count: p.MutualFollowers.Count,
+			Users: toUserSummaries(p.MutualFollowers.Users),
+		}
+	}
+	return resp
+}
+
+// GetFollowers lists a user's followers
+// @Summary Get followers
+// @Description Retrieve a paginated list of a user's followers, newest first. Private accounts are only visible to the owner and approved followers.
+// @Tags Users
+// @Produce json
+// @Param id path string true "User ID"
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(10)
+// @Success 200 {object} UserListResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/users/{id}/followers [get]
+func (h *UserHandler) GetFollowers(c *fiber.Ctx) error {
+	return h.listConnections(c, "Failed to get followers", h.userService.GetFollowers)
+}
+
+// GetFollowing lists the accounts a user follows
+// @Summary Get following
+// @Description Retrieve a paginated list of accounts a user follows, newest first. Private accounts are only visible to the owner and approved followers.
+// @Tags Users
+// @Produce json
+// @Param id path string true "User ID"
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(10)
+// @Success 200 {object} UserListResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/users/{id}/following [get]
+func (h *UserHandler) GetFollowing(c *fiber.Ctx) error {
+	return h.listConnections(c, "Failed to get following", h.userService.GetFollowing)
+}
+
+// listConnections serves a paginated follower/following list using the given lookup
+func (h *UserHandler) listConnections(c *fiber.Ctx, message string, lookup func(ctx context.Context, userID, viewerID uuid.UUID, limit, offset int) ([]*auth.User, error)) error {
+	viewer, ok := c.Locals("user").(*auth.User)
+	if !ok {
+		return httperr.Unauthenticated("Not authenticated")
+	}
+
+	userID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return httperr.BadRequest("Invalid user ID")
+	}
+
+	page, pageSize := parsePagination(c)
+
+	// Fetch one extra row to know whether another page exists
+	users, err := lookup(c.Context(), userID, viewer.ID, pageSize+1, (page-1)*pageSize)
+	if err != nil {
+		switch {
+		case isAuthError(err):
+			return err
+		case errors.Is(err, user.ErrPrivateAccount):
+			return httperr.Forbidden("This account is private")
+		}
+		return httperr.Internal(message, err, "target_id", userID)
+	}
+
+	hasMore := len(users) > pageSize
+	if hasMore {
+		users = users[:pageSize]
+	}
+
+	return c.JSON(UserListResponse{
+		Users:    toUserSummaries(users),
+		Page:     page,
+		PageSize: pageSize,
+		HasMore:  hasMore,
+	})
+}
+
+func toUserSummaries(users []*auth.User) []UserSummaryResponse {
+	summaries := make([]UserSummaryResponse, 0, len(users))
+	for _, u := range users {
+		summaries = append(summaries, *toUserSummary(u))
+	}
+	return summaries
+}
+
+func toUserSummary(u *auth.User) *UserSummaryResponse {
+	return &UserSummaryResponse{
+		ID:             u.ID.String(),
+		Username:       u.Username,
+		FullName:       u.FullName,
+		ProfilePicture: u.ProfilePicture,
+		IsVerified:     u.IsVerified,
+		IsPrivate:      u.IsPrivate,
+	}
+}
+
+// FollowResponse reports the outcome of a follow
+type FollowResponse struct {
+	Status string `json:"status"`
+}
+
+// FollowRequestResponse represents a pending follow request
+type FollowRequestResponse struct {
+	ID        string              `json:"id"`
+	Requester UserSummaryResponse `json:"requester"`
+	CreatedAt string              `json:"created_at"`
+}
+
+// FollowRequestListResponse represents a page of follow requests
+type FollowRequestListResponse struct {
+	Requests []FollowRequestResponse `json:"requests"`
+	Page     int                     `json:"page"`
+	PageSize int                     `json:"page_size"`
+	HasMore  bool                    `json:"has_more"`
+}
+
+// FollowUser follows a user
+// @Summary Follow user
+// @Description Follow a user. Following a private account creates a pending follow request instead; the returned status is "following" or "requested".
+// @Tags Users
+// @Produce json
+// @Param id path string true "User ID"
+// @Success 200 {object} FollowResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/users/{id}/follow [post]
+func (h *UserHandler) FollowUser(c *fiber.Ctx) error {
+	current, ok := c.Locals("user").(*auth.User)
+	if !ok {
+		return httperr.Unauthenticated("Not authenticated")
+	}
+
+	targetID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return httperr.BadRequest("Invalid user ID")
+	}
+
+	status, err := h.userService.FollowUser(c.Context(), current.ID, targetID)
+	if err != nil {
+		switch {
+		case isAuthError(err):
+			return err
+		case errors.Is(err, user.ErrCannotFollowSelf):
+			return httperr.BadRequest("You cannot follow yourself")
+		case errors.Is(err, user.ErrFollowBlocked):
+			return httperr.Forbidden("You cannot follow this user")
+		}
+		return httperr.Internal("Failed to follow user", err, "target_id", targetID)
+	}
+
+	return c.JSON(FollowResponse{
+		Status: string(status),
+	})
+}
+
+// UnfollowUser unfollows a user
+// @Summary Unfollow user
+// @Description Unfollow a user, or withdraw a pending follow request
+// @Tags Users
+// @Produce json
+// @Param id path string true "User ID"
+// @Success 204
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/users/{id}/follow [delete]
+func (h *UserHandler) UnfollowUser(c *fiber.Ctx) error {
+	current, ok := c.Locals("user").(*auth.User)
+	if !ok {
+		return httperr.Unauthenticated("Not authenticated")
+	}
+
+	targetID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return httperr.BadRequest("Invalid user ID")
+	}
+
+	if err := h.userService.UnfollowUser(c.Context(), current.ID, targetID); err != nil {
+		return httperr.Internal("Failed to unfollow user", err, "target_id", targetID)
+	}
+
+	return c.SendStatus(204)
+}
+
+// GetFollowRequests lists pending follow requests for the current user
+// @Summary Get follow requests
+// @Description Retrieve pending requests to follow the current account, newest first
+// @Tags Users
+// @Produce json
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(10)
+// @Success 200 {object} FollowRequestListResponse
+// @Failure 401 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/users/me/follow-requests [get]
+func (h *UserHandler) GetFollowRequests(c *fiber.Ctx) error {
+	current, ok := c.Locals("user").(*auth.User)
+	if !ok {
+		return httperr.Unauthenticated("Not authenticated")
+	}
+
+	page, pageSize := parsePagination(c)
+
+	// Fetch one extra row to know whether another page exists
+	requests, err := h.userService.GetFollowRequests(c.Context(), current.ID, pageSize+1, (page-1)*pageSize)
+	if err != nil {
+		return httperr.Internal("Failed to get follow requests", err)
+	}
+
+	hasMore := len(requests) > pageSize
+	if hasMore {
+		requests = requests[:pageSize]
+	}
+
+	response := FollowRequestListResponse{
+		Requests: make([]FollowRequestResponse, 0, len(requests)),
+		Page:     page,
+		PageSize: pageSize,
+		HasMore:  hasMore,
+	}
+	for _, r := range requests {
+		response.Requests = append(response.Requests, FollowRequestResponse{
+			ID: r.ID.String(),
+			Requester: UserSummaryResponse{
+				ID:             r.RequesterID.String(),
+				Username:       r.Username,
+				FullName:       r.FullName,
+				ProfilePicture: r.ProfilePicture,
+			},
+			CreatedAt: r.CreatedAt.Format(time.RFC3339),
+		})
+	}
+
+	return c.JSON(response)
+}
+
+// ApproveFollowRequest approves a pending follow request
+// @Summary Approve follow request
+// @Description Approve a pending follow request, making the requester a follower
+// @Tags Users
+// @Produce json
+// @Param requestId path string true "Follow request ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/users/me/follow-requests/{requestId}/approve [post]
+func (h *UserHandler) ApproveFollowRequest(c *fiber.Ctx) error {
+	return h.resolveFollowRequest(c, "Follow request approved", "Failed to approve follow request", h.userService.ApproveFollowRequest)
+}
+
+// RejectFollowRequest rejects a pending follow request
+// @Summary Reject follow request
+// @Description Reject a pending follow request
+// @Tags Users
+// @Produce json
+// @Param requestId path string true "Follow request ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/users/me/follow-requests/{requestId}/reject [post]
+func (h *UserHandler) RejectFollowRequest(c *fiber.Ctx) error {
+	return h.resolveFollowRequest(c, "Follow request rejected", "Failed to reject follow request", h.userService.RejectFollowRequest)
+}
+
+// resolveFollowRequest applies an approve/reject action to one of the current user's requests
+func (h *UserHandler) resolveFollowRequest(c *fiber.Ctx, success, failure string, resolve func(ctx context.Context, userID, requestID uuid.UUID) error) error {
+	current, ok := c.Locals("user").(*auth.User)
+	if !ok {
+		return httperr.Unauthenticated("Not authenticated")
+	}
+
+	requestID, err := uuid.Parse(c.Params("requestId"))
+	if err != nil {
+		return httperr.BadRequest("Invalid follow request ID")
+	}
+
+	if err := resolve(c.Context(), current.ID, requestID); err != nil {
+		if errors.Is(err, user.ErrFollowRequestNotFound) {
+			return httperr.NotFound("Follow request not found")
+		}
+		return httperr.Internal(failure, err, "follow_request_id", requestID)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": success,
+	})
+}
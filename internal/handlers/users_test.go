@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"fowergram-backend/internal/domain/media"
+	"fowergram-backend/internal/domain/user"
+	"fowergram-backend/pkg/auth"
+	"fowergram-backend/pkg/errreport"
+	"fowergram-backend/pkg/httperr"
+	"fowergram-backend/pkg/logger"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// SetAvatar processes the upload like the real service, without storing it
+func (s *fakeUserService) SetAvatar(ctx context.Context, id uuid.UUID, data []byte, contentType string) (*user.User, error) {
+	if !media.IsAllowedContentType(contentType) {
+		return nil, media.ErrUnsupportedContentType
+	}
+	if _, err := media.ProcessAvatar(data); err != nil {
+		return nil, err
+	}
+	return &user.User{ID: id}, nil
+}
+
+func TestUploadAvatar(t *testing.T) {
+	log := logger.NewZapLogger()
+	alice := &auth.User{ID: uuid.New(), Username: "alice"}
+	handler := NewUserHandler(&fakeUserService{}, log)
+
+	app := fiber.New(fiber.Config{ErrorHandler: httperr.Handler(log, errreport.Nop())})
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("user", alice)
+		return c.Next()
+	})
+	app.Post("/api/users/me/avatar", handler.UploadAvatar)
+
+	var small bytes.Buffer
+	if err := png.Encode(&small, image.NewRGBA(image.Rect(0, 0, 64, 64))); err != nil {
+		t.Fatalf("encoding the image: %v", err)
+	}
+
+	// A PNG header declaring 50000x50000 pixels, which must be rejected
+	// before decoding
+	ihdr := binary.BigEndian.AppendUint32([]byte("IHDR"), 50000)
+	ihdr = binary.BigEndian.AppendUint32(ihdr, 50000)
+	ihdr = append(ihdr, 8, 2, 0, 0, 0)
+	oversized := binary.BigEndian.AppendUint32([]byte("\x89PNG\r\n\x1a\n"), uint32(len(ihdr)-4))
+	oversized = binary.BigEndian.AppendUint32(append(oversized, ihdr...), crc32.ChecksumIEEE(ihdr))
+
+	tests := []struct {
+		name       string
+		data       []byte
+		wantStatus int
+		wantError  string
+	}{
+		{name: "image", data: small.Bytes(), wantStatus: fiber.StatusOK},
+		{name: "oversized dimensions", data: oversized, wantStatus: fiber.StatusBadRequest, wantError: media.ErrImageTooLarge.Error()},
+		{name: "not an image", data: []byte("not an image"), wantStatus: fiber.StatusBadRequest, wantError: "Unsupported image format"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body bytes.Buffer
+			form := multipart.NewWriter(&body)
+			part, err := form.CreateFormFile("file", "avatar.png")
+			if err != nil {
+				t.Fatalf("CreateFormFile: %v", err)
+			}
+			part.Write(tt.data)
+			form.Close()
+
+			req := httptest.NewRequest(http.MethodPost, "/api/users/me/avatar", &body)
+			req.Header.Set(fiber.HeaderContentType, form.FormDataContentType())
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantError == "" {
+				return
+			}
+
+			var decoded httperr.Response
+			if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+				t.Fatalf("decoding the error: %v", err)
+			}
+			if decoded.Error != tt.wantError || decoded.Code != httperr.CodeBadRequest {
+				t.Errorf("error = %q (%s), want %q", decoded.Error, decoded.Code, tt.wantError)
+			}
+		})
+	}
+}
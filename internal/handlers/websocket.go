@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"fowergram-backend/internal/realtime"
+	"fowergram-backend/pkg/auth"
+	"fowergram-backend/pkg/httperr"
+	"fowergram-backend/pkg/logger"
+
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+)
+
+// wsAuthTimeout is how long a client connecting without a token query
+// parameter has to send its auth message
+const wsAuthTimeout = 10 * time.Second
+
+// wsCloseUnauthorized closes connections that fail to authenticate
+const wsCloseUnauthorized = 4401
+
+type WebSocketHandler struct {
+	authService auth.AuthService
+	hub         *realtime.Hub
+	logger      logger.Logger
+}
+
+func NewWebSocketHandler(authService auth.AuthService, hub *realtime.Hub, logger logger.Logger) *WebSocketHandler {
+	return &WebSocketHandler{
+		authService: authService,
+		hub:         hub,
+		logger:      logger,
+	}
+}
+
+// WebSocketAuthMessage authenticates a connection opened without a token
+type WebSocketAuthMessage struct {
+	Type  string `json:"type"` // Always "auth"
+	Token string `json:"token"`
+}
+
+// Upgrade rejects requests that aren't websocket upgrades and authenticates
+// the ones carrying a token query parameter before upgrading them
+func (h *WebSocketHandler) Upgrade(c *fiber.Ctx) error {
+	if !websocket.IsWebSocketUpgrade(c) {
+		return fiber.ErrUpgradeRequired
+	}
+
+	if token := c.Query("token"); token != "" {
+		user, err := h.authService.ValidateSession(c.Context(), token)
+		if err != nil {
+			return httperr.Unauthenticated("Invalid token")
+		}
+		c.Locals("user", user)
+		c.Locals(logger.UserIDKey, user.ID.String())
+	}
+
+	return c.Next()
+}
+
+// Connect streams notifications, new posts from followed accounts and direct messages
+// @Summary Real-time updates
+// @Description Upgrade to a websocket that pushes JSON frames {"type": "notification"|"post"|"message", "data": {...}} as notifications are created, followed accounts publish posts and direct messages are sent. Authenticate with the token query parameter, or send {"type": "auth", "token": "..."} as the first message within 10 seconds; failed authentication closes the connection with code 4401. The server pings every 54 seconds and drops clients that stop answering or fall behind.
+// @Tags Realtime
+// @Param token query string false "Access token"
+// @Success 101
+// @Failure 401 {object} ErrorResponse
+// @Failure 426 {object} ErrorResponse
+// @Router /ws [get]
+func (h *WebSocketHandler) Connect() fiber.Handler {
+	return websocket.New(func(conn *websocket.Conn) {
+		user, ok := conn.Locals("user").(*auth.User)
+		if !ok {
+			user = h.authenticate(conn)
+			if user == nil {
+				_ = conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(wsCloseUnauthorized, "authentication failed"),
+					time.Now().Add(time.Second))
+				return
+			}
+		}
+
+		h.hub.Serve(conn, user.ID)
+	})
+}
+
+// authenticate reads the auth message a client sends first, returning nil
+// when it is missing, malformed or carries an invalid token
+func (h *WebSocketHandler) authenticate(conn *websocket.Conn) *auth.User {
+	conn.SetReadDeadline(time.Now().Add(wsAuthTimeout))
+	defer conn.SetReadDeadline(time.Time{})
+
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		return nil
+	}
+
+	var msg WebSocketAuthMessage
+	if err := json.Unmarshal(data, &msg); err != nil || msg.Type != "auth" || msg.Token == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), wsAuthTimeout)
+	defer cancel()
+
+	user, err := h.authService.ValidateSession(ctx, msg.Token)
+	if err != nil {
+		return nil
+	}
+	return user
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"fowergram-backend/internal/events"
+	"fowergram-backend/internal/infra/messaging"
+	"fowergram-backend/internal/realtime"
+	"fowergram-backend/pkg/auth"
+	"fowergram-backend/pkg/errreport"
+	"fowergram-backend/pkg/httperr"
+	"fowergram-backend/pkg/logger"
+
+	"github.com/fasthttp/websocket"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// fakeSessionService accepts the access tokens in sessions. Other methods
+// are left to the embedded nil AuthService.
+type fakeSessionService struct {
+	auth.AuthService
+
+	sessions map[string]*auth.User
+}
+
+func (s *fakeSessionService) ValidateSession(ctx context.Context, accessToken string) (*auth.User, error) {
+	user, ok := s.sessions[accessToken]
+	if !ok {
+		return nil, auth.ErrInvalidToken
+	}
+	return user, nil
+}
+
+func TestWebSocketAuth(t *testing.T) {
+	log := logger.NewZapLogger()
+	alice := &auth.User{ID: uuid.New(), Username: "alice"}
+	sessions := &fakeSessionService{sessions: map[string]*auth.User{"alice-token": alice}}
+
+	client := messaging.NewRecordingClient()
+	publisher := events.NewNATSPublisher(client, log)
+	hub := realtime.NewHub(&fakeFollowingRepository{}, log)
+	if err := hub.Start(client); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	handler := NewWebSocketHandler(sessions, hub, log)
+
+	app := fiber.New(fiber.Config{
+		ErrorHandler:          httperr.Handler(log, errreport.Nop()),
+		DisableStartupMessage: true,
+	})
+	app.Get("/ws", handler.Upgrade, handler.Connect())
+
+	// Websockets hijack their connection, which app.Test can't serve
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening: %v", err)
+	}
+	go app.Listener(listener)
+	t.Cleanup(func() { app.ShutdownWithTimeout(time.Second) })
+	t.Cleanup(hub.Close)
+
+	t.Run("not an upgrade", func(t *testing.T) {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/ws", nil), -1)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != fiber.StatusUpgradeRequired {
+			t.Errorf("status = %d, want %d", resp.StatusCode, fiber.StatusUpgradeRequired)
+		}
+	})
+
+	t.Run("invalid token parameter", func(t *testing.T) {
+		conn, resp, err := websocket.DefaultDialer.Dial("ws://"+listener.Addr().String()+"/ws?token=stolen", nil)
+		if err == nil {
+			conn.Close()
+			t.Fatal("upgraded a connection with an invalid token")
+		}
+		if !errors.Is(err, websocket.ErrBadHandshake) || resp.StatusCode != fiber.StatusUnauthorized {
+			t.Errorf("dial error = %v with response %v, want a 401 handshake", err, resp)
+		}
+	})
+
+	tests := []struct {
+		name    string
+		query   string
+		message string // Sent first when set
+		wantErr bool   // Whether authentication fails, closing with 4401
+	}{
+		{name: "token parameter", query: "?token=alice-token"},
+		{name: "auth message", message: `{"type": "auth", "token": "alice-token"}`},
+		{name: "invalid token in the auth message", message: `{"type": "auth", "token": "stolen"}`, wantErr: true},
+		{name: "other message first", message: `{"type": "subscribe", "token": "alice-token"}`, wantErr: true},
+		{name: "malformed auth message", message: `alice-token`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, _, err := websocket.DefaultDialer.Dial("ws://"+listener.Addr().String()+"/ws"+tt.query, nil)
+			if err != nil {
+				t.Fatalf("dialing: %v", err)
+			}
+			defer conn.Close()
+			conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+			if tt.message != "" {
+				if err := conn.WriteMessage(websocket.TextMessage, []byte(tt.message)); err != nil {
+					t.Fatalf("sending the auth message: %v", err)
+				}
+			}
+
+			if tt.wantErr {
+				_, _, err := conn.ReadMessage()
+				if !websocket.IsCloseError(err, wsCloseUnauthorized) {
+					t.Errorf("read error = %v, want close code %d", err, wsCloseUnauthorized)
+				}
+				return
+			}
+
+			// The hub registers the connection after the handshake, so the
+			// notification is published until a frame arrives
+			received := make(chan []byte, 1)
+			go func() {
+				if _, data, err := conn.ReadMessage(); err == nil {
+					received <- data
+				}
+				close(received)
+			}()
+			notification := events.NotificationCreated{NotificationID: uuid.New(), UserID: alice.ID, Type: "like", CreatedAt: time.Now()}
+			for {
+				if err := publisher.Publish(context.Background(), uuid.New(), notification); err != nil {
+					t.Fatalf("publishing: %v", err)
+				}
+				select {
+				case data, ok := <-received:
+					if !ok {
+						t.Fatal("connection closed before a frame arrived")
+					}
+					want := `{"type":"notification","data":{"notification_id":"` + notification.NotificationID.String() + `"`
+					if !strings.HasPrefix(string(data), want) {
+						t.Errorf("frame = %s, want the notification", data)
+					}
+					return
+				case <-time.After(20 * time.Millisecond):
+				}
+			}
+		})
+	}
+}
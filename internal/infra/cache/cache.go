@@ -0,0 +1,51 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrMiss is returned by Get and GetJSON for keys that aren't cached. It is
+// redis.Nil, so checks against either work.
+var ErrMiss = redis.Nil
+
+// Cache stores string and JSON values by key. RedisCache implements it, and
+// MemoryCache serves tests. A zero expiration keeps a value until deleted.
+type Cache interface {
+	Get(ctx context.Context, key string) (string, error)
+	Set(ctx context.Context, key, value string) error
+	SetWithExpiration(ctx context.Context, key, value string, expiration time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+	GetJSON(ctx context.Context, key string, dest interface{}) error
+	SetJSON(ctx context.Context, key string, value interface{}, expiration time.Duration) error
+}
+
+var (
+	_ Cache = (*RedisCache)(nil)
+	_ Cache = (*MemoryCache)(nil)
+)
+
+// getJSON decodes the value c holds for key into dest
+func getJSON(ctx context.Context, c Cache, key string, dest interface{}) error {
+	value, err := c.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal([]byte(value), dest); err != nil {
+		return fmt.Errorf("failed to decode cached %s: %w", key, err)
+	}
+	return nil
+}
+
+// setJSON stores value in c as JSON under key
+func setJSON(ctx context.Context, c Cache, key string, value interface{}, expiration time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s for caching: %w", key, err)
+	}
+	return c.SetWithExpiration(ctx, key, string(data), expiration)
+}
@@ -0,0 +1,41 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// denylistKeyPrefix namespaces revoked access token IDs
+const denylistKeyPrefix = "auth:denylist:"
+
+// TokenDenylist keeps the IDs of revoked access tokens in Redis until the
+// tokens would have expired anyway
+type TokenDenylist struct {
+	client *redis.Client
+}
+
+// NewTokenDenylist creates a denylist stored in cache
+func NewTokenDenylist(cache *RedisCache) *TokenDenylist {
+	return &TokenDenylist{
+		client: cache.GetClient(),
+	}
+}
+
+// Deny revokes the token with ID jti for ttl, its remaining lifetime
+func (d *TokenDenylist) Deny(ctx context.Context, jti string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil
+	}
+	return d.client.Set(ctx, denylistKeyPrefix+jti, "1", ttl).Err()
+}
+
+// IsDenied reports whether the token with ID jti was revoked
+func (d *TokenDenylist) IsDenied(ctx context.Context, jti string) (bool, error) {
+	n, err := d.client.Exists(ctx, denylistKeyPrefix+jti).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
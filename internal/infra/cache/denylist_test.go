@@ -0,0 +1,54 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestTokenDenylist(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	cache, err := NewRedisCache(ctx, "redis://"+server.Addr())
+	if err != nil {
+		t.Fatalf("NewRedisCache: %v", err)
+	}
+	t.Cleanup(func() { cache.Close() })
+	denylist := NewTokenDenylist(cache)
+
+	isDenied := func(jti string) bool {
+		t.Helper()
+		denied, err := denylist.IsDenied(ctx, jti)
+		if err != nil {
+			t.Fatalf("IsDenied: %v", err)
+		}
+		return denied
+	}
+
+	if err := denylist.Deny(ctx, "signed-out", 10*time.Minute); err != nil {
+		t.Fatalf("Deny: %v", err)
+	}
+	// A token past its lifetime is already rejected, so it isn't stored
+	if err := denylist.Deny(ctx, "expired", 0); err != nil {
+		t.Fatalf("Deny: %v", err)
+	}
+
+	if !isDenied("signed-out") || isDenied("expired") || isDenied("other") {
+		t.Errorf("denied signed-out %v, expired %v, other %v; want only signed-out", isDenied("signed-out"), isDenied("expired"), isDenied("other"))
+	}
+	if keys := server.Keys(); len(keys) != 1 {
+		t.Errorf("stored keys %v, want only the signed-out token's", keys)
+	}
+
+	// The entry lasts as long as the token would have
+	server.FastForward(10*time.Minute - time.Second)
+	if !isDenied("signed-out") {
+		t.Errorf("token allowed again before it expired")
+	}
+	server.FastForward(time.Second)
+	if isDenied("signed-out") || len(server.Keys()) != 0 {
+		t.Errorf("denylist entry kept after the token expired")
+	}
+}
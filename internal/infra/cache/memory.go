@@ -0,0 +1,89 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryCache keeps values in process memory, for tests and single-instance
+// runs without Redis. Expired values are dropped when next read.
+type MemoryCache struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+	now     func() time.Time
+}
+
+type memoryEntry struct {
+	value     string
+	expiresAt time.Time // Zero for values that never expire
+}
+
+// NewMemoryCache creates an empty in-memory cache
+func NewMemoryCache() *MemoryCache {
+	return NewMemoryCacheWithClock(time.Now)
+}
+
+// NewMemoryCacheWithClock creates an empty in-memory cache that reads the
+// time from now, so tests can expire values without sleeping
+func NewMemoryCacheWithClock(now func() time.Time) *MemoryCache {
+	return &MemoryCache{
+		entries: make(map[string]memoryEntry),
+		now:     now,
+	}
+}
+
+// Get retrieves a value by key, returning ErrMiss once it has expired
+func (m *MemoryCache) Get(ctx context.Context, key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.entries[key]
+	if !ok {
+		return "", ErrMiss
+	}
+	if !entry.expiresAt.IsZero() && !m.now().Before(entry.expiresAt) {
+		delete(m.entries, key)
+		return "", ErrMiss
+	}
+	return entry.value, nil
+}
+
+// Set stores a value by key
+func (m *MemoryCache) Set(ctx context.Context, key, value string) error {
+	return m.SetWithExpiration(ctx, key, value, 0)
+}
+
+// SetWithExpiration stores a value by key with expiration
+func (m *MemoryCache) SetWithExpiration(ctx context.Context, key, value string, expiration time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry := memoryEntry{value: value}
+	if expiration > 0 {
+		entry.expiresAt = m.now().Add(expiration)
+	}
+	m.entries[key] = entry
+	return nil
+}
+
+// Delete removes keys
+func (m *MemoryCache) Delete(ctx context.Context, keys ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, key := range keys {
+		delete(m.entries, key)
+	}
+	return nil
+}
+
+// GetJSON decodes the JSON value stored under key into dest
+func (m *MemoryCache) GetJSON(ctx context.Context, key string, dest interface{}) error {
+	return getJSON(ctx, m, key, dest)
+}
+
+// SetJSON stores value as JSON under key
+func (m *MemoryCache) SetJSON(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	return setJSON(ctx, m, key, value, expiration)
+}
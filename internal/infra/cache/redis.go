@@ -5,6 +5,9 @@ import (
 	"fmt"
 	"time"
 
+	"fowergram-backend/pkg/retry"
+	"fowergram-backend/pkg/telemetry"
+
 	"github.com/redis/go-redis/v9"
 )
 
@@ -13,17 +16,20 @@ type RedisCache struct {
 	client *redis.Client
 }
 
-// NewRedisCache creates a new Redis cache client
-func NewRedisCache(redisURL string) (*RedisCache, error) {
+// NewRedisCache creates a new Redis cache client, checking the connection
+// within ctx
+func NewRedisCache(ctx context.Context, redisURL string) (*RedisCache, error) {
 	opt, err := redis.ParseURL(redisURL)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse Redis URL: %w", err)
+		return nil, retry.Permanent(fmt.Errorf("failed to parse Redis URL: %w", err))
 	}
 
 	client := redis.NewClient(opt)
+	client.AddHook(telemetry.RedisHook{})
 
 	// Test connection
-	if err := client.Ping(context.Background()).Err(); err != nil {
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
 		return nil, fmt.Errorf("failed to ping Redis: %w", err)
 	}
 
@@ -32,6 +38,14 @@ func NewRedisCache(redisURL string) (*RedisCache, error) {
 	}, nil
 }
 
+// HealthCheck checks that Redis answers a PING
+func (r *RedisCache) HealthCheck(ctx context.Context) error {
+	if err := r.client.Ping(ctx).Err(); err != nil {
+		return fmt.Errorf("failed to ping Redis: %w", err)
+	}
+	return nil
+}
+
 // GetClient returns the underlying Redis client
 func (r *RedisCache) GetClient() *redis.Client {
 	return r.client
@@ -56,3 +70,21 @@ func (r *RedisCache) Set(ctx context.Context, key, value string) error {
 func (r *RedisCache) SetWithExpiration(ctx context.Context, key, value string, expiration time.Duration) error {
 	return r.client.Set(ctx, key, value, expiration).Err()
 }
+
+// Delete removes keys
+func (r *RedisCache) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return r.client.Del(ctx, keys...).Err()
+}
+
+// GetJSON decodes the JSON value stored under key into dest
+func (r *RedisCache) GetJSON(ctx context.Context, key string, dest interface{}) error {
+	return getJSON(ctx, r, key, dest)
+}
+
+// SetJSON stores value as JSON under key
+func (r *RedisCache) SetJSON(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	return setJSON(ctx, r, key, value, expiration)
+}
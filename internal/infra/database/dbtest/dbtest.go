@@ -0,0 +1,65 @@
+// Package dbtest gives tests that need Postgres a database of their own
+package dbtest
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"fowergram-backend/internal/infra/database"
+	"fowergram-backend/migrations"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// MigratedPool connects to TEST_DATABASE_URL in a schema of its own with
+// every migration applied, dropped when the test ends. The test is skipped
+// without a database.
+func MigratedPool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	ctx := context.Background()
+
+	admin, err := pgxpool.New(ctx, url)
+	if err != nil {
+		t.Fatalf("connecting: %v", err)
+	}
+	t.Cleanup(admin.Close)
+
+	schema := "test_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	if _, err := admin.Exec(ctx, "CREATE SCHEMA "+schema); err != nil {
+		t.Fatalf("creating schema: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec(context.Background(), "DROP SCHEMA "+schema+" CASCADE"); err != nil {
+			t.Errorf("dropping schema: %v", err)
+		}
+	})
+
+	config, err := pgxpool.ParseConfig(url)
+	if err != nil {
+		t.Fatalf("parsing TEST_DATABASE_URL: %v", err)
+	}
+	// Public stays on the path for extensions already installed there
+	config.ConnConfig.RuntimeParams["search_path"] = schema + ", public"
+	db, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		t.Fatalf("connecting: %v", err)
+	}
+	t.Cleanup(db.Close)
+
+	migrator, err := database.NewMigrator(db, migrations.FS)
+	if err != nil {
+		t.Fatalf("NewMigrator: %v", err)
+	}
+	defer migrator.Close()
+	if _, _, err := migrator.Up(); err != nil {
+		t.Fatalf("migrating: %v", err)
+	}
+	return db
+}
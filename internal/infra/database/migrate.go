@@ -0,0 +1,147 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/golang-migrate/migrate/v4"
+	pgxmigrate "github.com/golang-migrate/migrate/v4/database/pgx/v5"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// Migrator applies and rolls back the migrations in a file system with
+// golang-migrate, which keeps the version reached in schema_migrations and
+// holds a Postgres advisory lock while migrating, so instances starting
+// together take turns
+type Migrator struct {
+	migrate *migrate.Migrate
+	source  source.Driver
+}
+
+// NewMigrator creates a migrator for the NNN_name.up.sql and
+// NNN_name.down.sql files in fsys, run over a connection from db. Close
+// returns the connection.
+func NewMigrator(db *pgxpool.Pool, fsys fs.FS) (*Migrator, error) {
+	src, err := iofs.New(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	conn := stdlib.OpenDBFromPool(db)
+	driver, err := pgxmigrate.WithInstance(conn, &pgxmigrate.Config{})
+	if err != nil {
+		src.Close()
+		conn.Close()
+		return nil, fmt.Errorf("failed to prepare database for migrations: %w", err)
+	}
+
+	m, err := migrate.NewWithInstance("iofs", src, "pgx5", driver)
+	if err != nil {
+		src.Close()
+		driver.Close()
+		return nil, fmt.Errorf("failed to create migrator: %w", err)
+	}
+
+	return &Migrator{migrate: m, source: src}, nil
+}
+
+// Close releases the migrator's connection
+func (m *Migrator) Close() error {
+	sourceErr, databaseErr := m.migrate.Close()
+	return errors.Join(sourceErr, databaseErr)
+}
+
+// Version returns the version the database was migrated to, 0 before any
+// migration. A dirty version was left by a migration that failed halfway
+// and has to be fixed by hand, then forced.
+func (m *Migrator) Version() (version uint, dirty bool, err error) {
+	version, dirty, err = m.migrate.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to read version: %w", err)
+	}
+	return version, dirty, nil
+}
+
+// Up applies every migration after the current version, returning the
+// version it started from and the one reached. They are the same when there
+// was nothing to apply.
+func (m *Migrator) Up() (from, to uint, err error) {
+	if from, _, err = m.Version(); err != nil {
+		return 0, 0, err
+	}
+
+	err = m.migrate.Up()
+	to, _, versionErr := m.Version()
+	if errors.Is(err, migrate.ErrNoChange) {
+		// Another instance may have applied them while this one waited
+		return to, to, versionErr
+	}
+	if err != nil {
+		return from, to, fmt.Errorf("failed to apply migrations: %w", err)
+	}
+	return from, to, versionErr
+}
+
+// Down rolls back the last steps migrations applied, returning the version
+// it started from and the one reached. It refuses before rolling back
+// anything when one of them has no down file, where golang-migrate would
+// only lower the version and leave the schema as it was.
+func (m *Migrator) Down(steps int) (from, to uint, err error) {
+	from, dirty, err := m.Version()
+	if err != nil {
+		return 0, 0, err
+	}
+	if dirty {
+		return from, from, migrate.ErrDirty{Version: int(from)}
+	}
+
+	versions, err := rollbacks(m.source, from, steps)
+	if err != nil {
+		return from, from, err
+	}
+	if len(versions) == 0 {
+		return from, from, nil
+	}
+
+	err = m.migrate.Steps(-len(versions))
+	to, _, versionErr := m.Version()
+	if err != nil {
+		return from, to, fmt.Errorf("failed to roll back migrations: %w", err)
+	}
+	return from, to, versionErr
+}
+
+// rollbacks lists the versions rolling back steps migrations from version
+// rolls back, newest first, or fails when one of them has no down file
+func rollbacks(src source.Driver, version uint, steps int) ([]uint, error) {
+	var versions []uint
+	for current := version; current != 0 && len(versions) < steps; {
+		r, _, err := src.ReadDown(current)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("migration %d has no down file: at most %d of the %d steps can be rolled back, down to version %d",
+				current, len(versions), steps, current)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %d: %w", current, err)
+		}
+		r.Close()
+		versions = append(versions, current)
+
+		previous, err := src.Prev(current)
+		if errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to find the migration before %d: %w", current, err)
+		}
+		current = previous
+	}
+	return versions, nil
+}
@@ -0,0 +1,393 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"fowergram-backend/migrations"
+
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// widgetMigrations create a table and add a column to it. With irreversible
+// set, the table can't be dropped again.
+func widgetMigrations(irreversible bool) fstest.MapFS {
+	fsys := fstest.MapFS{
+		"001_widgets.up.sql":        {Data: []byte("CREATE TABLE widgets (id INT PRIMARY KEY);")},
+		"001_widgets.down.sql":      {Data: []byte("DROP TABLE widgets;")},
+		"003_widget_names.up.sql":   {Data: []byte("ALTER TABLE widgets ADD COLUMN name TEXT;")},
+		"003_widget_names.down.sql": {Data: []byte("ALTER TABLE widgets DROP COLUMN name;")},
+	}
+	if irreversible {
+		delete(fsys, "001_widgets.down.sql")
+	}
+	return fsys
+}
+
+// migrationVersions lists the versions in src, oldest first
+func migrationVersions(t *testing.T, src source.Driver) []uint {
+	t.Helper()
+	version, err := src.First()
+	if err != nil {
+		t.Fatalf("First: %v", err)
+	}
+	versions := []uint{version}
+	for {
+		version, err = src.Next(version)
+		if errors.Is(err, fs.ErrNotExist) {
+			return versions
+		}
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		versions = append(versions, version)
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	src, err := iofs.New(migrations.FS, ".")
+	if err != nil {
+		t.Fatalf("reading the embedded migrations: %v", err)
+	}
+	defer src.Close()
+
+	// golang-migrate skips files it can't parse the name of, which would
+	// leave their migration out without a word
+	files, err := fs.Glob(migrations.FS, "*.sql")
+	if err != nil {
+		t.Fatalf("listing the embedded migrations: %v", err)
+	}
+	read := 0
+	for _, version := range migrationVersions(t, src) {
+		for _, readFile := range []func(uint) (io.ReadCloser, string, error){src.ReadUp, src.ReadDown} {
+			r, _, err := readFile(version)
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				t.Fatalf("reading migration %d: %v", version, err)
+			}
+			r.Close()
+			read++
+		}
+	}
+	if read != len(files) {
+		t.Errorf("golang-migrate reads %d of the %d migration files", read, len(files))
+	}
+}
+
+func TestRollbacks(t *testing.T) {
+	embedded, err := iofs.New(migrations.FS, ".")
+	if err != nil {
+		t.Fatalf("reading the embedded migrations: %v", err)
+	}
+	defer embedded.Close()
+	versions := migrationVersions(t, embedded)
+	latest := versions[len(versions)-1]
+
+	tests := []struct {
+		name    string
+		fsys    fstest.MapFS
+		version uint
+		steps   int
+		want    []uint
+		wantErr string
+	}{
+		{name: "last migration", fsys: widgetMigrations(false), version: 3, steps: 1, want: []uint{3}},
+		{name: "every migration", fsys: widgetMigrations(false), version: 3, steps: 2, want: []uint{3, 1}},
+		{name: "more steps than migrations", fsys: widgetMigrations(false), version: 3, steps: 5, want: []uint{3, 1}},
+		{name: "newer migrations not yet applied", fsys: widgetMigrations(false), version: 1, steps: 1, want: []uint{1}},
+		{name: "nothing applied", fsys: widgetMigrations(false), version: 0, steps: 1},
+		{name: "down to an irreversible migration", fsys: widgetMigrations(true), version: 3, steps: 1, want: []uint{3}},
+		{
+			name:    "past an irreversible migration",
+			fsys:    widgetMigrations(true),
+			version: 3,
+			steps:   2,
+			wantErr: "migration 1 has no down file: at most 1 of the 2 steps can be rolled back, down to version 1",
+		},
+		{
+			name:    "past the embedded baseline",
+			version: latest,
+			steps:   len(versions),
+			wantErr: "migration 5 has no down file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := embedded
+			if tt.fsys != nil {
+				if src, err = iofs.New(tt.fsys, "."); err != nil {
+					t.Fatalf("reading migrations: %v", err)
+				}
+				defer src.Close()
+			}
+
+			got, err := rollbacks(src, tt.version, tt.steps)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				if got != nil {
+					t.Errorf("rollbacks = %v alongside the error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("rollbacks: %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("rollbacks = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// testPool connects to TEST_DATABASE_URL in a schema of its own, dropped
+// when the test ends. The test is skipped without a database.
+func testPool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	ctx := context.Background()
+
+	admin, err := pgxpool.New(ctx, url)
+	if err != nil {
+		t.Fatalf("connecting: %v", err)
+	}
+	t.Cleanup(admin.Close)
+
+	schema := "migrate_test_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	if _, err := admin.Exec(ctx, "CREATE SCHEMA "+schema); err != nil {
+		t.Fatalf("creating schema: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec(context.Background(), "DROP SCHEMA "+schema+" CASCADE"); err != nil {
+			t.Errorf("dropping schema: %v", err)
+		}
+	})
+
+	config, err := pgxpool.ParseConfig(url)
+	if err != nil {
+		t.Fatalf("parsing TEST_DATABASE_URL: %v", err)
+	}
+	// Public stays on the path for extensions already installed there
+	config.ConnConfig.RuntimeParams["search_path"] = schema + ", public"
+	db, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		t.Fatalf("connecting: %v", err)
+	}
+	t.Cleanup(db.Close)
+	return db
+}
+
+// widgetColumns lists the columns of the widgets table, nil when it doesn't exist
+func widgetColumns(t *testing.T, db *pgxpool.Pool) []string {
+	t.Helper()
+	rows, err := db.Query(context.Background(), `
+		SELECT column_name FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = 'widgets'
+		ORDER BY ordinal_position
+	`)
+	if err != nil {
+		t.Fatalf("reading columns: %v", err)
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			t.Fatalf("scanning column: %v", err)
+		}
+		columns = append(columns, column)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("reading columns: %v", err)
+	}
+	return columns
+}
+
+// newMigrator creates a migrator for fsys, closed when the test ends
+func newMigrator(t *testing.T, db *pgxpool.Pool, fsys fs.FS) *Migrator {
+	t.Helper()
+	migrator, err := NewMigrator(db, fsys)
+	if err != nil {
+		t.Fatalf("NewMigrator: %v", err)
+	}
+	t.Cleanup(func() { migrator.Close() })
+	return migrator
+}
+
+func TestMigratorRoundTrip(t *testing.T) {
+	t.Run("up, down and up again", func(t *testing.T) {
+		db := testPool(t)
+		migrator := newMigrator(t, db, widgetMigrations(false))
+
+		steps := []struct {
+			name        string
+			run         func() (uint, uint, error)
+			wantFrom    uint
+			wantVersion uint
+			wantColumns []string
+		}{
+			{name: "up", run: migrator.Up, wantFrom: 0, wantVersion: 3, wantColumns: []string{"id", "name"}},
+			{name: "up when up to date", run: migrator.Up, wantFrom: 3, wantVersion: 3, wantColumns: []string{"id", "name"}},
+			{name: "down a step", run: func() (uint, uint, error) { return migrator.Down(1) }, wantFrom: 3, wantVersion: 1, wantColumns: []string{"id"}},
+			{name: "down the rest", run: func() (uint, uint, error) { return migrator.Down(5) }, wantFrom: 1, wantVersion: 0},
+			{name: "down when nothing is applied", run: func() (uint, uint, error) { return migrator.Down(1) }, wantFrom: 0, wantVersion: 0},
+			{name: "up again", run: migrator.Up, wantFrom: 0, wantVersion: 3, wantColumns: []string{"id", "name"}},
+		}
+		for _, step := range steps {
+			from, to, err := step.run()
+			if err != nil {
+				t.Fatalf("%s: %v", step.name, err)
+			}
+			if from != step.wantFrom || to != step.wantVersion {
+				t.Errorf("%s migrated from %d to %d, want %d to %d", step.name, from, to, step.wantFrom, step.wantVersion)
+			}
+			version, dirty, err := migrator.Version()
+			if err != nil {
+				t.Fatalf("%s: Version: %v", step.name, err)
+			}
+			if version != step.wantVersion || dirty {
+				t.Errorf("%s: version = %d, dirty = %v; want %d, clean", step.name, version, dirty, step.wantVersion)
+			}
+			if columns := widgetColumns(t, db); !slices.Equal(columns, step.wantColumns) {
+				t.Errorf("%s: widgets columns = %v, want %v", step.name, columns, step.wantColumns)
+			}
+		}
+	})
+
+	t.Run("down past an irreversible migration changes nothing", func(t *testing.T) {
+		db := testPool(t)
+		migrator := newMigrator(t, db, widgetMigrations(true))
+		if _, _, err := migrator.Up(); err != nil {
+			t.Fatalf("Up: %v", err)
+		}
+
+		from, to, err := migrator.Down(2)
+		if err == nil || from != to {
+			t.Fatalf("Down = %d, %d, %v; want an error and nothing rolled back", from, to, err)
+		}
+		if version, _, err := migrator.Version(); err != nil || version != 3 {
+			t.Errorf("version = %d, %v; want 3", version, err)
+		}
+		if columns := widgetColumns(t, db); !slices.Equal(columns, []string{"id", "name"}) {
+			t.Errorf("widgets columns = %v, want [id name]", columns)
+		}
+	})
+
+	t.Run("embedded migrations", func(t *testing.T) {
+		db := testPool(t)
+		migrator := newMigrator(t, db, migrations.FS)
+		versions := migrationVersions(t, migrator.source)
+		latest := versions[len(versions)-1]
+
+		if _, _, err := migrator.Up(); err != nil {
+			t.Fatalf("Up: %v", err)
+		}
+
+		// Roll back every migration with a down file, newest first, down to
+		// the last one without
+		reversible := 0
+		for i := len(versions) - 1; i >= 0; i-- {
+			r, _, err := migrator.source.ReadDown(versions[i])
+			if err != nil {
+				break
+			}
+			r.Close()
+			reversible++
+		}
+		if _, _, err := migrator.Down(reversible); err != nil {
+			t.Fatalf("Down(%d): %v", reversible, err)
+		}
+		floor := versions[len(versions)-1-reversible]
+		if version, _, err := migrator.Version(); err != nil || version != floor {
+			t.Errorf("version after down = %d, %v; want %d", version, err, floor)
+		}
+
+		if _, _, err := migrator.Up(); err != nil {
+			t.Fatalf("Up again: %v", err)
+		}
+		if version, _, err := migrator.Version(); err != nil || version != latest {
+			t.Errorf("version after up again = %d, %v; want %d", version, err, latest)
+		}
+	})
+}
+
+// upInstance migrates db up as a starting server instance does
+func upInstance(db *pgxpool.Pool) (from, to uint, err error) {
+	migrator, err := NewMigrator(db, widgetMigrations(false))
+	if err != nil {
+		return 0, 0, err
+	}
+	defer migrator.Close()
+	return migrator.Up()
+}
+
+func TestMigratorConcurrentUp(t *testing.T) {
+	ctx := context.Background()
+	db := testPool(t)
+
+	// Instances starting together each run Up; the advisory lock lets one
+	// apply the migrations and the others find them applied. Each holds a
+	// connection, so they're created as they start, as the server's are.
+	const instances = 5
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		ranges []string
+		errs   []error
+	)
+	for i := 0; i < instances; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			from, to, err := upInstance(db)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+				return
+			}
+			ranges = append(ranges, fmt.Sprintf("%d>%d", from, to))
+		}()
+	}
+	wg.Wait()
+
+	if len(errs) != 0 {
+		t.Fatalf("Up failed: %v", errs)
+	}
+	slices.Sort(ranges)
+	if want := []string{"0>3", "3>3", "3>3", "3>3", "3>3"}; !slices.Equal(ranges, want) {
+		t.Errorf("instances migrated %v, want one to migrate and the others to find nothing to do", ranges)
+	}
+
+	migrator := newMigrator(t, db, widgetMigrations(false))
+	if version, dirty, err := migrator.Version(); err != nil || version != 3 || dirty {
+		t.Errorf("version = %d, dirty = %v, %v; want 3, clean", version, dirty, err)
+	}
+	if columns := widgetColumns(t, db); !slices.Equal(columns, []string{"id", "name"}) {
+		t.Errorf("widgets columns = %v, want [id name]", columns)
+	}
+	var rows int
+	if err := db.QueryRow(ctx, "SELECT count(*) FROM schema_migrations").Scan(&rows); err != nil || rows != 1 {
+		t.Errorf("schema_migrations has %d rows, %v; want 1", rows, err)
+	}
+}
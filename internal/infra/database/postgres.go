@@ -4,27 +4,35 @@ import (
 	"context"
 	"fmt"
 
+	"fowergram-backend/pkg/retry"
+	"fowergram-backend/pkg/telemetry"
+
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-// NewPostgreSQLDB creates a new PostgreSQL database connection
-func NewPostgreSQLDB(databaseURL string) (*pgxpool.Pool, error) {
+// NewPostgreSQLDB creates a new PostgreSQL database connection, checking it
+// within ctx
+func NewPostgreSQLDB(ctx context.Context, databaseURL string) (*pgxpool.Pool, error) {
 	config, err := pgxpool.ParseConfig(databaseURL)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse database URL: %w", err)
+		return nil, retry.Permanent(fmt.Errorf("failed to parse database URL: %w", err))
 	}
 
 	// Configure connection pool
 	config.MaxConns = 30
 	config.MinConns = 5
 
+	// Queries are traced as children of the request's span
+	config.ConnConfig.Tracer = telemetry.QueryTracer{}
+
 	pool, err := pgxpool.NewWithConfig(context.Background(), config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create connection pool: %w", err)
 	}
 
 	// Test connection
-	if err := pool.Ping(context.Background()); err != nil {
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
@@ -0,0 +1,54 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Querier runs queries on a pool, a connection or a transaction, so a query
+// helper can be shared between them
+type Querier interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// DB is a pool or a transaction for a repository to run its queries on, so
+// repositories bound to one transaction commit or roll back together.
+// Beginning a transaction on a transaction starts a savepoint, so repository
+// methods running their own transaction still work inside a caller's.
+// *pgxpool.Pool and pgx.Tx satisfy it.
+type DB interface {
+	Querier
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+var (
+	_ DB = (*pgxpool.Pool)(nil)
+	_ DB = pgx.Tx(nil)
+)
+
+// WithTx runs fn in a transaction, committing it when fn returns nil and
+// rolling it back when fn returns an error or panics. The error fn returns
+// is passed through as is. On a transaction, fn runs in a savepoint.
+func WithTx(ctx context.Context, db DB, fn func(tx DB) error) error {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	// Rolling back after a commit does nothing
+	defer tx.Rollback(ctx)
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// fakeTx records how a transaction ended. Like pgx, rolling back after a
+// commit does nothing. Query methods are left to the embedded nil pgx.Tx.
+type fakeTx struct {
+	pgx.Tx
+
+	commitErr  error
+	committed  bool
+	rolledBack bool
+}
+
+func (tx *fakeTx) Commit(ctx context.Context) error {
+	if tx.commitErr != nil {
+		return tx.commitErr
+	}
+	tx.committed = true
+	return nil
+}
+
+func (tx *fakeTx) Rollback(ctx context.Context) error {
+	if !tx.committed {
+		tx.rolledBack = true
+	}
+	return nil
+}
+
+// fakeDB begins tx, or fails with beginErr
+type fakeDB struct {
+	DB
+
+	tx       *fakeTx
+	beginErr error
+}
+
+func (db *fakeDB) Begin(ctx context.Context) (pgx.Tx, error) {
+	if db.beginErr != nil {
+		return nil, db.beginErr
+	}
+	return db.tx, nil
+}
+
+func TestWithTx(t *testing.T) {
+	errWrite := errors.New("write failed")
+	errBegin := errors.New("connection refused")
+	errCommit := errors.New("serialization failure")
+
+	tests := []struct {
+		name         string
+		db           *fakeDB
+		fn           func(tx DB) error
+		wantErr      error
+		wantPanic    bool
+		wantCommit   bool
+		wantRollback bool
+		wantSkipFn   bool
+	}{
+		{
+			name:       "success commits",
+			db:         &fakeDB{tx: &fakeTx{}},
+			fn:         func(tx DB) error { return nil },
+			wantCommit: true,
+		},
+		{
+			name:         "error rolls back",
+			db:           &fakeDB{tx: &fakeTx{}},
+			fn:           func(tx DB) error { return errWrite },
+			wantErr:      errWrite,
+			wantRollback: true,
+		},
+		{
+			name:         "panic rolls back",
+			db:           &fakeDB{tx: &fakeTx{}},
+			fn:           func(tx DB) error { panic("boom") },
+			wantPanic:    true,
+			wantRollback: true,
+		},
+		{
+			name:         "failed commit",
+			db:           &fakeDB{tx: &fakeTx{commitErr: errCommit}},
+			fn:           func(tx DB) error { return nil },
+			wantErr:      errCommit,
+			wantRollback: true,
+		},
+		{
+			name:       "failed begin",
+			db:         &fakeDB{beginErr: errBegin},
+			fn:         func(tx DB) error { return nil },
+			wantErr:    errBegin,
+			wantSkipFn: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			fn := func(tx DB) error {
+				called = true
+				if tx != DB(tt.db.tx) {
+					t.Errorf("fn ran on %v, want the begun transaction", tx)
+				}
+				return tt.fn(tx)
+			}
+
+			var err error
+			panicked := func() (panicked bool) {
+				defer func() {
+					panicked = recover() != nil
+				}()
+				err = WithTx(context.Background(), tt.db, fn)
+				return false
+			}()
+
+			if panicked != tt.wantPanic {
+				t.Fatalf("panicked = %v, want %v", panicked, tt.wantPanic)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+			if called == tt.wantSkipFn {
+				t.Errorf("fn called = %v, want %v", called, !tt.wantSkipFn)
+			}
+			if tt.db.tx == nil {
+				return
+			}
+			if tt.db.tx.committed != tt.wantCommit {
+				t.Errorf("committed = %v, want %v", tt.db.tx.committed, tt.wantCommit)
+			}
+			if tt.db.tx.rolledBack != tt.wantRollback {
+				t.Errorf("rolled back = %v, want %v", tt.db.tx.rolledBack, tt.wantRollback)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package messaging
+
+import "context"
+
+// Client publishes and subscribes to messages. NATSClient implements it,
+// RecordingClient serves tests, and NoopClient stands in when messaging is
+// unavailable.
+type Client interface {
+	Publish(subject string, data []byte) error
+	PublishAsync(subject string, data []byte, onAck func(error)) error
+	Subscribe(subject string, handler func(ctx context.Context, msg []byte)) (*Subscription, error)
+	QueueSubscribe(subject, queue string, handler func(ctx context.Context, msg []byte)) (*Subscription, error)
+	SubscribeDurable(queue, subject string, handler func(ctx context.Context, msg []byte) error) (*Subscription, error)
+	HealthCheck(ctx context.Context) error
+	Drain(ctx context.Context) error
+	Close()
+}
+
+var (
+	_ Client = (*NATSClient)(nil)
+	_ Client = NoopClient{}
+)
+
+// NoopClient drops published messages and never delivers any. It lets the
+// server run without NATS, without events reaching workers or realtime
+// clients.
+type NoopClient struct{}
+
+// Publish discards the message
+func (NoopClient) Publish(subject string, data []byte) error {
+	return nil
+}
+
+// PublishAsync discards the message and acknowledges it right away
+func (NoopClient) PublishAsync(subject string, data []byte, onAck func(error)) error {
+	if onAck != nil {
+		onAck(nil)
+	}
+	return nil
+}
+
+// Subscribe returns a subscription that receives nothing
+func (NoopClient) Subscribe(subject string, handler func(ctx context.Context, msg []byte)) (*Subscription, error) {
+	return &Subscription{}, nil
+}
+
+// QueueSubscribe returns a subscription that receives nothing
+func (NoopClient) QueueSubscribe(subject, queue string, handler func(ctx context.Context, msg []byte)) (*Subscription, error) {
+	return &Subscription{}, nil
+}
+
+// SubscribeDurable returns a subscription that receives nothing
+func (NoopClient) SubscribeDurable(queue, subject string, handler func(ctx context.Context, msg []byte) error) (*Subscription, error) {
+	return &Subscription{}, nil
+}
+
+// HealthCheck always succeeds, as there is no server to lose
+func (NoopClient) HealthCheck(ctx context.Context) error {
+	return nil
+}
+
+// Drain has nothing to wait for
+func (NoopClient) Drain(ctx context.Context) error {
+	return nil
+}
+
+// Close has nothing to close
+func (NoopClient) Close() {}
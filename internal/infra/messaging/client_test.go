@@ -0,0 +1,65 @@
+package messaging
+
+import (
+	"context"
+	"testing"
+)
+
+func TestNoopClient(t *testing.T) {
+	ctx := context.Background()
+	var client Client = NoopClient{}
+
+	if err := client.Publish("post.created", []byte("event")); err != nil {
+		t.Errorf("Publish() = %v, want nil", err)
+	}
+	acked := false
+	if err := client.PublishAsync("post.created", []byte("event"), func(err error) { acked = err == nil }); err != nil || !acked {
+		t.Errorf("PublishAsync() = %v with ack %v, want an immediate ack", err, acked)
+	}
+	if err := client.PublishAsync("post.created", []byte("event"), nil); err != nil {
+		t.Errorf("PublishAsync() without a callback = %v, want nil", err)
+	}
+
+	// Subscriptions receive nothing and stop like any other
+	var subs []*Subscription
+	sub, err := client.Subscribe("post.created", func(ctx context.Context, msg []byte) {
+		t.Errorf("delivered %q", msg)
+	})
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	subs = append(subs, sub)
+	sub, err = client.QueueSubscribe("post.created", "workers", func(ctx context.Context, msg []byte) {
+		t.Errorf("delivered %q", msg)
+	})
+	if err != nil {
+		t.Fatalf("QueueSubscribe: %v", err)
+	}
+	subs = append(subs, sub)
+	sub, err = client.SubscribeDurable("workers", "post.created", func(ctx context.Context, msg []byte) error {
+		t.Errorf("delivered %q", msg)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("SubscribeDurable: %v", err)
+	}
+	subs = append(subs, sub)
+
+	if err := client.Publish("post.created", []byte("event")); err != nil {
+		t.Errorf("Publish() = %v, want nil", err)
+	}
+	if err := subs[0].Unsubscribe(); err != nil {
+		t.Errorf("Unsubscribe() = %v, want nil", err)
+	}
+	if err := DrainAll(ctx, subs); err != nil {
+		t.Errorf("DrainAll() = %v, want nil", err)
+	}
+
+	if err := client.HealthCheck(ctx); err != nil {
+		t.Errorf("HealthCheck() = %v, want nil", err)
+	}
+	if err := client.Drain(ctx); err != nil {
+		t.Errorf("Drain() = %v, want nil", err)
+	}
+	client.Close()
+}
@@ -0,0 +1,212 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"fowergram-backend/internal/config"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// DeadLetterPrefix prefixes the subject a message is moved to once it has
+// failed MaxDeliver times, e.g. dlq.post.created
+const DeadLetterPrefix = "dlq."
+
+// Headers set on dead-lettered messages
+const (
+	HeaderDeadLetterError      = "Dead-Letter-Error"
+	HeaderDeadLetterDeliveries = "Dead-Letter-Deliveries"
+)
+
+// ErrPoisonMessage marks a handler error that retrying can't fix, such as an
+// undecodable payload. Such messages are dead-lettered without redelivery.
+var ErrPoisonMessage = errors.New("poison message")
+
+// streams are declared when JetStream is enabled. Messages are kept for
+// streamMaxAge so durable consumers catch up on what they missed while down.
+var streams = []jetstream.StreamConfig{
+	{Name: "POSTS", Subjects: []string{"post.*"}},
+	{Name: "USERS", Subjects: []string{"user.*"}},
+	{Name: "NOTIFICATIONS", Subjects: []string{"notification.*"}},
+	{Name: "DIRECT_MESSAGES", Subjects: []string{"dm.*"}},
+	{Name: "PUSH", Subjects: []string{"push.*"}},
+	{Name: "DEAD_LETTERS", Subjects: []string{DeadLetterPrefix + ">"}},
+}
+
+const streamMaxAge = 7 * 24 * time.Hour
+
+// jetStreamTimeout bounds stream and consumer management calls and
+// acknowledgements of published messages
+const jetStreamTimeout = 10 * time.Second
+
+// redeliveryDelay is how long a failed message waits before it is redelivered
+const redeliveryDelay = 5 * time.Second
+
+func (n *NATSClient) enableJetStream(cfg config.JetStreamConfig) error {
+	js, err := jetstream.New(n.conn, jetstream.WithPublishAsyncTimeout(jetStreamTimeout))
+	if err != nil {
+		return fmt.Errorf("failed to create JetStream context: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), jetStreamTimeout)
+	defer cancel()
+
+	for _, stream := range streams {
+		stream.MaxAge = streamMaxAge
+		stream.Storage = jetstream.FileStorage
+		if _, err := js.CreateOrUpdateStream(ctx, stream); err != nil {
+			return fmt.Errorf("failed to declare stream %s: %w", stream.Name, err)
+		}
+	}
+
+	n.js = js
+	n.maxDeliver = cfg.MaxDeliver
+	n.ackWait = cfg.AckWait.Duration
+	return nil
+}
+
+// PublishAsync publishes a message without waiting for it to be stored.
+// With JetStream, onAck is called once the stream acknowledges the message or
+// the acknowledgement times out. Plain NATS has no acknowledgement, so onAck
+// is called as soon as the message is sent. onAck may be nil.
+func (n *NATSClient) PublishAsync(subject string, data []byte, onAck func(error)) error {
+	if n.js == nil {
+		if err := n.conn.Publish(subject, data); err != nil {
+			return err
+		}
+		if onAck != nil {
+			onAck(nil)
+		}
+		return nil
+	}
+
+	future, err := n.js.PublishAsync(subject, data)
+	if err != nil {
+		return err
+	}
+
+	if onAck != nil {
+		go func() {
+			select {
+			case <-future.Ok():
+				onAck(nil)
+			case err := <-future.Err():
+				onAck(err)
+			}
+		}()
+	}
+
+	return nil
+}
+
+// SubscribeDurable consumes subject through a durable consumer shared by
+// every subscriber with the same queue name, so each message is handled once
+// and messages published while no subscriber was running are not lost. A
+// message is acked when handler succeeds and redelivered when it fails, until
+// MaxDeliver attempts or an ErrPoisonMessage move it to the dead-letter
+// subject. Without JetStream this is a plain queue subscription and failed
+// messages are dropped. Handlers get a context that is cancelled when the
+// client shuts down.
+func (n *NATSClient) SubscribeDurable(queue, subject string, handler func(ctx context.Context, msg []byte) error) (*Subscription, error) {
+	if n.js == nil {
+		return n.QueueSubscribe(subject, queue, func(ctx context.Context, data []byte) {
+			_ = handler(ctx, data)
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), jetStreamTimeout)
+	defer cancel()
+
+	stream, err := n.js.StreamNameBySubject(ctx, subject)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find stream for %s: %w", subject, err)
+	}
+
+	consumer, err := n.js.CreateOrUpdateConsumer(ctx, stream, jetstream.ConsumerConfig{
+		Durable:       durableName(queue, subject),
+		FilterSubject: subject,
+		DeliverPolicy: jetstream.DeliverNewPolicy,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		AckWait:       n.ackWait,
+		// Redelivery is unlimited on the server; handleDurable dead-letters
+		// after maxDeliver attempts, so a failed dead-letter publish is retried
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create consumer for %s: %w", subject, err)
+	}
+
+	consume, err := consumer.Consume(func(msg jetstream.Msg) {
+		n.handleDurable(msg, handler)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to consume %s: %w", subject, err)
+	}
+
+	n.mu.Lock()
+	n.consumers = append(n.consumers, consume)
+	n.mu.Unlock()
+
+	return &Subscription{consume: consume}, nil
+}
+
+// handleDurable runs handler for a single message and settles it
+func (n *NATSClient) handleDurable(msg jetstream.Msg, handler func(ctx context.Context, msg []byte) error) {
+	err := handler(n.ctx, msg.Data())
+	if err == nil {
+		if err := msg.Ack(); err != nil {
+			n.logger.Error("Failed to ack message", "subject", msg.Subject(), "error", err)
+		}
+		return
+	}
+
+	var deliveries uint64
+	if meta, metaErr := msg.Metadata(); metaErr == nil {
+		deliveries = meta.NumDelivered
+	}
+
+	// A handler cut short by shutdown is retried by another subscriber
+	if !errors.Is(err, ErrPoisonMessage) && (deliveries < uint64(n.maxDeliver) || n.ctx.Err() != nil) {
+		if err := msg.NakWithDelay(redeliveryDelay); err != nil {
+			n.logger.Error("Failed to nak message", "subject", msg.Subject(), "error", err)
+		}
+		return
+	}
+
+	n.deadLetter(msg, deliveries, err)
+}
+
+// deadLetter moves a message that keeps failing to the dead-letter subject
+func (n *NATSClient) deadLetter(msg jetstream.Msg, deliveries uint64, cause error) {
+	dead := nats.NewMsg(DeadLetterPrefix + msg.Subject())
+	dead.Data = msg.Data()
+	dead.Header.Set(HeaderDeadLetterError, cause.Error())
+	dead.Header.Set(HeaderDeadLetterDeliveries, strconv.FormatUint(deliveries, 10))
+
+	ctx, cancel := context.WithTimeout(context.Background(), jetStreamTimeout)
+	defer cancel()
+
+	if _, err := n.js.PublishMsg(ctx, dead); err != nil {
+		n.logger.Error("Failed to dead-letter message", "subject", msg.Subject(), "error", err)
+		if err := msg.NakWithDelay(redeliveryDelay); err != nil {
+			n.logger.Error("Failed to nak message", "subject", msg.Subject(), "error", err)
+		}
+		return
+	}
+
+	n.logger.Warn("Moved message to dead-letter subject", "subject", msg.Subject(), "deliveries", deliveries, "error", cause)
+	if err := msg.Term(); err != nil {
+		n.logger.Error("Failed to terminate message", "subject", msg.Subject(), "error", err)
+	}
+}
+
+// durableName derives a consumer name from a queue and subject; consumer
+// names can't contain the subject separators and wildcards
+func durableName(queue, subject string) string {
+	return queue + "_" + strings.NewReplacer(".", "_", "*", "any", ">", "all").Replace(subject)
+}
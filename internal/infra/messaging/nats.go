@@ -1,42 +1,250 @@
 package messaging
 
 import (
+	"context"
 	"fmt"
+	"sync"
+	"time"
+
+	"fowergram-backend/internal/config"
+	"fowergram-backend/pkg/logger"
 
 	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
 )
 
-// NATSClient implements messaging using NATS
+// reconnectWait is how long the client waits between attempts to reconnect
+// to a lost server. Reconnection is retried until the client is closed.
+const reconnectWait = 2 * time.Second
+
+// drainPollInterval is how often Subscription.Drain checks whether a core
+// NATS subscription finished draining
+const drainPollInterval = 50 * time.Millisecond
+
+// NATSClient implements messaging using NATS, optionally backed by JetStream
 type NATSClient struct {
-	conn *nats.Conn
+	conn   *nats.Conn
+	logger logger.Logger
+
+	// ctx is passed to message handlers and cancelled when the client stops
+	ctx    context.Context
+	cancel context.CancelFunc
+	closed chan struct{}
+
+	// Set only when JetStream is enabled
+	js         jetstream.JetStream
+	maxDeliver int
+	ackWait    time.Duration
+
+	mu        sync.Mutex
+	consumers []jetstream.ConsumeContext
 }
 
-// NewNATSClient creates a new NATS client
-func NewNATSClient(natsURL string) (*NATSClient, error) {
-	conn, err := nats.Connect(natsURL)
+// NewNATSClient creates a new NATS client. With JetStream enabled in cfg, the
+// event streams are declared before it returns.
+func NewNATSClient(natsURL string, cfg config.JetStreamConfig, logger logger.Logger) (*NATSClient, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	client := &NATSClient{
+		logger: logger,
+		ctx:    ctx,
+		cancel: cancel,
+		closed: make(chan struct{}),
+	}
+
+	conn, err := nats.Connect(natsURL,
+		nats.MaxReconnects(-1),
+		nats.ReconnectWait(reconnectWait),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			if err != nil {
+				logger.Warn("Disconnected from NATS", "error", err)
+			}
+		}),
+		nats.ReconnectHandler(func(conn *nats.Conn) {
+			logger.Info("Reconnected to NATS", "url", conn.ConnectedUrlRedacted())
+		}),
+		nats.ErrorHandler(func(_ *nats.Conn, sub *nats.Subscription, err error) {
+			if sub != nil {
+				logger.Error("NATS subscription error", "subject", sub.Subject, "error", err)
+				return
+			}
+			logger.Error("NATS error", "error", err)
+		}),
+		nats.ClosedHandler(func(*nats.Conn) {
+			close(client.closed)
+		}),
+	)
 	if err != nil {
+		cancel()
 		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
 	}
+	client.conn = conn
+
+	if cfg.Enabled {
+		if err := client.enableJetStream(cfg); err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+
+	return client, nil
+}
+
+// HealthCheck reports whether the connection to NATS is up. It fails while
+// the client is reconnecting, so readiness reflects lost connections.
+func (n *NATSClient) HealthCheck(ctx context.Context) error {
+	if status := n.conn.Status(); status != nats.CONNECTED {
+		return fmt.Errorf("NATS connection is %s", status)
+	}
+	return nil
+}
 
-	return &NATSClient{
-		conn: conn,
-	}, nil
+// Drain stops every subscription from receiving new messages, waits for the
+// messages already received to be handled and closes the connection. Messages
+// published after Drain starts are still sent. If ctx ends first, the context
+// given to handlers is cancelled and the connection is closed without waiting.
+func (n *NATSClient) Drain(ctx context.Context) error {
+	n.mu.Lock()
+	consumers := n.consumers
+	n.consumers = nil
+	n.mu.Unlock()
+
+	for _, consumer := range consumers {
+		consumer.Drain()
+	}
+	for _, consumer := range consumers {
+		select {
+		case <-consumer.Closed():
+		case <-ctx.Done():
+			n.Close()
+			return fmt.Errorf("failed to drain NATS consumers: %w", ctx.Err())
+		}
+	}
+
+	if err := n.conn.Drain(); err != nil {
+		n.Close()
+		return fmt.Errorf("failed to drain NATS connection: %w", err)
+	}
+
+	select {
+	case <-n.closed:
+		n.cancel()
+		return nil
+	case <-ctx.Done():
+		n.Close()
+		return fmt.Errorf("failed to drain NATS connection: %w", ctx.Err())
+	}
 }
 
-// Close closes the NATS connection
+// Close cancels in-flight handlers, stops durable consumers and closes the
+// NATS connection without waiting for pending messages. It is safe to call
+// after Drain.
 func (n *NATSClient) Close() {
+	n.cancel()
+
+	n.mu.Lock()
+	for _, consumer := range n.consumers {
+		consumer.Stop()
+	}
+	n.consumers = nil
+	n.mu.Unlock()
+
 	n.conn.Close()
 }
 
+// Subscription is a handle on a subscription made through a Client. The
+// subscriptions of NoopClient have nothing to stop.
+type Subscription struct {
+	sub     *nats.Subscription
+	consume jetstream.ConsumeContext
+}
+
+// Unsubscribe stops the subscription, discarding messages not yet handled
+func (s *Subscription) Unsubscribe() error {
+	if s.consume != nil {
+		s.consume.Stop()
+		return nil
+	}
+	if s.sub == nil {
+		return nil
+	}
+	return s.sub.Unsubscribe()
+}
+
+// Drain stops the subscription from receiving new messages and waits for the
+// messages already received to be handled, or for ctx to end
+func (s *Subscription) Drain(ctx context.Context) error {
+	if s.consume != nil {
+		s.consume.Drain()
+		select {
+		case <-s.consume.Closed():
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if s.sub == nil {
+		return nil
+	}
+
+	if err := s.sub.Drain(); err != nil && s.sub.IsValid() {
+		return err
+	}
+
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+	for s.sub.IsValid() {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// DrainAll drains every subscription in subs, stopping them all from
+// receiving before waiting on any, and returns the first error
+func DrainAll(ctx context.Context, subs []*Subscription) error {
+	errs := make(chan error, len(subs))
+	for _, sub := range subs {
+		go func() { errs <- sub.Drain(ctx) }()
+	}
+
+	var first error
+	for range subs {
+		if err := <-errs; err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
 // Publish publishes a message to a subject
 func (n *NATSClient) Publish(subject string, data []byte) error {
 	return n.conn.Publish(subject, data)
 }
 
-// Subscribe subscribes to a subject
-func (n *NATSClient) Subscribe(subject string, handler func(msg []byte)) error {
-	_, err := n.conn.Subscribe(subject, func(m *nats.Msg) {
-		handler(m.Data)
+// Subscribe subscribes to a subject. Handlers get a context that is
+// cancelled when the client shuts down.
+func (n *NATSClient) Subscribe(subject string, handler func(ctx context.Context, msg []byte)) (*Subscription, error) {
+	sub, err := n.conn.Subscribe(subject, func(m *nats.Msg) {
+		handler(n.ctx, m.Data)
 	})
-	return err
+	if err != nil {
+		return nil, err
+	}
+	return &Subscription{sub: sub}, nil
+}
+
+// QueueSubscribe subscribes to a subject as a member of a queue group, so each
+// message is handled by only one subscriber in the group
+func (n *NATSClient) QueueSubscribe(subject, queue string, handler func(ctx context.Context, msg []byte)) (*Subscription, error) {
+	sub, err := n.conn.QueueSubscribe(subject, queue, func(m *nats.Msg) {
+		handler(n.ctx, m.Data)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Subscription{sub: sub}, nil
 }
@@ -0,0 +1,257 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"fowergram-backend/internal/config"
+	"fowergram-backend/pkg/logger"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+// deliveryTimeout bounds the wait for a message, which covers one
+// redeliveryDelay
+const deliveryTimeout = 3 * redeliveryDelay
+
+// runServer starts an in-process NATS server on a free port, with JetStream
+// storing in a temporary directory when enabled, shut down when the test ends
+func runServer(t *testing.T, jetStream bool) *server.Server {
+	t.Helper()
+	opts := &server.Options{Host: "127.0.0.1", Port: -1, NoLog: true, NoSigs: true}
+	if jetStream {
+		opts.JetStream = true
+		opts.StoreDir = t.TempDir()
+	}
+	ns, err := server.NewServer(opts)
+	if err != nil {
+		t.Fatalf("creating the NATS server: %v", err)
+	}
+	go ns.Start()
+	if !ns.ReadyForConnections(5 * time.Second) {
+		t.Fatal("NATS server didn't start")
+	}
+	t.Cleanup(ns.Shutdown)
+	return ns
+}
+
+// newTestClient connects a client to ns, closed when the test ends
+func newTestClient(t *testing.T, ns *server.Server, cfg config.JetStreamConfig) *NATSClient {
+	t.Helper()
+	client, err := NewNATSClient(ns.ClientURL(), cfg, logger.NewZapLogger())
+	if err != nil {
+		t.Fatalf("NewNATSClient: %v", err)
+	}
+	t.Cleanup(client.Close)
+	return client
+}
+
+// receive waits for the next message on ch
+func receive[T any](t *testing.T, ch <-chan T) T {
+	t.Helper()
+	select {
+	case msg := <-ch:
+		return msg
+	case <-time.After(deliveryTimeout):
+		t.Fatalf("nothing received within %v", deliveryTimeout)
+	}
+	panic("unreachable")
+}
+
+// publishAcked publishes data and waits for the stream to store it
+func publishAcked(t *testing.T, client *NATSClient, subject, data string) {
+	t.Helper()
+	acked := make(chan error, 1)
+	if err := client.PublishAsync(subject, []byte(data), func(err error) { acked <- err }); err != nil {
+		t.Fatalf("PublishAsync: %v", err)
+	}
+	if err := receive(t, acked); err != nil {
+		t.Fatalf("publishing %s: %v", subject, err)
+	}
+}
+
+func TestDurableConsumerCatchesUp(t *testing.T) {
+	ns := runServer(t, true)
+	cfg := config.JetStreamConfig{Enabled: true, MaxDeliver: 3}
+	publisher := newTestClient(t, ns, cfg)
+
+	received := make(chan string, 1)
+	handler := func(ctx context.Context, msg []byte) error {
+		received <- string(msg)
+		return nil
+	}
+
+	// The first worker registers the durable consumer, then goes away
+	worker := newTestClient(t, ns, cfg)
+	if _, err := worker.SubscribeDurable("catch-up", "post.created", handler); err != nil {
+		t.Fatalf("SubscribeDurable: %v", err)
+	}
+	worker.Close()
+
+	publishAcked(t, publisher, "post.created", "published while down")
+
+	restarted := newTestClient(t, ns, cfg)
+	if _, err := restarted.SubscribeDurable("catch-up", "post.created", handler); err != nil {
+		t.Fatalf("SubscribeDurable after the restart: %v", err)
+	}
+	if got := receive(t, received); got != "published while down" {
+		t.Errorf("received %q, want the message published while no worker ran", got)
+	}
+}
+
+func TestDurableRedeliveryAndDeadLetters(t *testing.T) {
+	ns := runServer(t, true)
+	client := newTestClient(t, ns, config.JetStreamConfig{Enabled: true, MaxDeliver: 2})
+
+	// Dead letters are watched on a plain connection, which sees their headers
+	conn, err := nats.Connect(ns.ClientURL())
+	if err != nil {
+		t.Fatalf("connecting: %v", err)
+	}
+	t.Cleanup(conn.Close)
+
+	tests := []struct {
+		name           string
+		fail           func(delivery int64) error // Handler result for each delivery, counted from 1
+		wantDeliveries int64
+		wantDeadLetter bool
+	}{
+		{
+			name:           "poison",
+			fail:           func(int64) error { return fmt.Errorf("%w: bad payload", ErrPoisonMessage) },
+			wantDeliveries: 1,
+			wantDeadLetter: true,
+		},
+		{
+			name:           "failing",
+			fail:           func(int64) error { return errors.New("database down") },
+			wantDeliveries: 2,
+			wantDeadLetter: true,
+		},
+		{
+			name: "recovering",
+			fail: func(delivery int64) error {
+				if delivery == 1 {
+					return errors.New("database down")
+				}
+				return nil
+			},
+			wantDeliveries: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			subject := "post." + tt.name
+
+			deadLetters := make(chan *nats.Msg, 1)
+			sub, err := conn.ChanSubscribe(DeadLetterPrefix+subject, deadLetters)
+			if err != nil {
+				t.Fatalf("subscribing to dead letters: %v", err)
+			}
+			t.Cleanup(func() { sub.Unsubscribe() })
+
+			var deliveries atomic.Int64
+			settled := make(chan error, tt.wantDeliveries)
+			_, err = client.SubscribeDurable("redelivery", subject, func(ctx context.Context, msg []byte) error {
+				err := tt.fail(deliveries.Add(1))
+				settled <- err
+				return err
+			})
+			if err != nil {
+				t.Fatalf("SubscribeDurable: %v", err)
+			}
+
+			publishAcked(t, client, subject, "event")
+			for range tt.wantDeliveries {
+				receive(t, settled)
+			}
+
+			if !tt.wantDeadLetter {
+				select {
+				case msg := <-deadLetters:
+					t.Errorf("dead-lettered %q after the handler recovered", msg.Data)
+				case <-time.After(100 * time.Millisecond):
+				}
+				return
+			}
+			msg := receive(t, deadLetters)
+			if string(msg.Data) != "event" || msg.Header.Get(HeaderDeadLetterError) == "" {
+				t.Errorf("dead letter %q with headers %v, want the event and its error", msg.Data, msg.Header)
+			}
+			if got := msg.Header.Get(HeaderDeadLetterDeliveries); got != strconv.FormatInt(tt.wantDeliveries, 10) {
+				t.Errorf("dead-lettered after %s deliveries, want %d", got, tt.wantDeliveries)
+			}
+
+			// A dead-lettered message isn't delivered again
+			select {
+			case <-settled:
+				t.Errorf("delivered again after being dead-lettered")
+			case <-time.After(redeliveryDelay + time.Second):
+			}
+			if got := deliveries.Load(); got != tt.wantDeliveries {
+				t.Errorf("delivered %d times, want %d", got, tt.wantDeliveries)
+			}
+		})
+	}
+}
+
+func TestCoreNATSFallback(t *testing.T) {
+	ns := runServer(t, false)
+
+	// Both workers share the queue, so each message is handled once
+	var handled atomic.Int64
+	received := make(chan struct{}, 10)
+	for range 2 {
+		worker := newTestClient(t, ns, config.JetStreamConfig{})
+		_, err := worker.SubscribeDurable("fallback", "post.created", func(ctx context.Context, msg []byte) error {
+			handled.Add(1)
+			received <- struct{}{}
+			return errors.New("not redelivered without JetStream")
+		})
+		if err != nil {
+			t.Fatalf("SubscribeDurable: %v", err)
+		}
+	}
+	// Subscriptions reach the server asynchronously; a round trip waits for them
+	waitForSubscribers(t, ns, 2)
+
+	publisher := newTestClient(t, ns, config.JetStreamConfig{})
+	for i := range 5 {
+		acked := false
+		if err := publisher.PublishAsync("post.created", []byte(strconv.Itoa(i)), func(err error) { acked = err == nil }); err != nil || !acked {
+			t.Fatalf("PublishAsync = %v with ack %v, want an immediate ack", err, acked)
+		}
+	}
+
+	for range 5 {
+		receive(t, received)
+	}
+	select {
+	case <-received:
+		t.Errorf("a message was handled twice")
+	case <-time.After(100 * time.Millisecond):
+	}
+	if got := handled.Load(); got != 5 {
+		t.Errorf("handled %d messages, want 5", got)
+	}
+}
+
+// waitForSubscribers waits for the server to count want subscriptions
+func waitForSubscribers(t *testing.T, ns *server.Server, want uint32) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for ns.NumSubscriptions() < want {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d subscriptions, want %d", ns.NumSubscriptions(), want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,113 @@
+package messaging
+
+import (
+	"context"
+	"sync"
+)
+
+// Message is a message published through a RecordingClient
+type Message struct {
+	Subject string
+	Data    []byte
+}
+
+// RecordingClient is an in-process Client for tests. It records every
+// published message and hands it straight to the handlers subscribed to
+// exactly its subject; wildcards and queue groups aren't supported, and
+// unsubscribing has no effect.
+type RecordingClient struct {
+	mu        sync.Mutex
+	published []Message
+	handlers  map[string][]func(ctx context.Context, msg []byte) error
+}
+
+var _ Client = (*RecordingClient)(nil)
+
+// NewRecordingClient creates a RecordingClient with nothing published
+func NewRecordingClient() *RecordingClient {
+	return &RecordingClient{
+		handlers: make(map[string][]func(ctx context.Context, msg []byte) error),
+	}
+}
+
+// Published returns the messages published so far, oldest first
+func (r *RecordingClient) Published() []Message {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]Message(nil), r.published...)
+}
+
+// PublishedTo returns the messages published on subject, oldest first
+func (r *RecordingClient) PublishedTo(subject string) []Message {
+	var messages []Message
+	for _, msg := range r.Published() {
+		if msg.Subject == subject {
+			messages = append(messages, msg)
+		}
+	}
+	return messages
+}
+
+// Publish records the message and delivers it to subject's handlers before
+// returning
+func (r *RecordingClient) Publish(subject string, data []byte) error {
+	r.mu.Lock()
+	r.published = append(r.published, Message{
+		Subject: subject,
+		Data:    append([]byte(nil), data...),
+	})
+	handlers := append([]func(ctx context.Context, msg []byte) error(nil), r.handlers[subject]...)
+	r.mu.Unlock()
+
+	for _, handler := range handlers {
+		_ = handler(context.Background(), data)
+	}
+	return nil
+}
+
+// PublishAsync publishes like Publish and acknowledges right away
+func (r *RecordingClient) PublishAsync(subject string, data []byte, onAck func(error)) error {
+	if err := r.Publish(subject, data); err != nil {
+		return err
+	}
+	if onAck != nil {
+		onAck(nil)
+	}
+	return nil
+}
+
+// Subscribe delivers the messages later published on subject to handler
+func (r *RecordingClient) Subscribe(subject string, handler func(ctx context.Context, msg []byte)) (*Subscription, error) {
+	return r.SubscribeDurable("", subject, func(ctx context.Context, msg []byte) error {
+		handler(ctx, msg)
+		return nil
+	})
+}
+
+// QueueSubscribe subscribes like Subscribe; every subscriber gets each message
+func (r *RecordingClient) QueueSubscribe(subject, queue string, handler func(ctx context.Context, msg []byte)) (*Subscription, error) {
+	return r.Subscribe(subject, handler)
+}
+
+// SubscribeDurable subscribes like Subscribe; handler errors are ignored
+func (r *RecordingClient) SubscribeDurable(queue, subject string, handler func(ctx context.Context, msg []byte) error) (*Subscription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.handlers[subject] = append(r.handlers[subject], handler)
+	return &Subscription{}, nil
+}
+
+// HealthCheck always succeeds
+func (r *RecordingClient) HealthCheck(ctx context.Context) error {
+	return nil
+}
+
+// Drain has nothing to wait for
+func (r *RecordingClient) Drain(ctx context.Context) error {
+	return nil
+}
+
+// Close has nothing to close
+func (r *RecordingClient) Close() {}
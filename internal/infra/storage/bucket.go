@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"fowergram-backend/internal/config"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/lifecycle"
+)
+
+// TmpPrefix is where short-lived objects such as orphaned uploads are kept
+const TmpPrefix = "tmp/"
+
+// tmpExpiryRuleID identifies the lifecycle rule this service manages, so it
+// can be updated without touching rules configured by operators
+const tmpExpiryRuleID = "fowergram-tmp-expiry"
+
+// ensureBucket checks that the bucket exists, creating it when cfg allows,
+// and applies the tmp/ expiry rule when one is configured
+func ensureBucket(ctx context.Context, client *minio.Client, cfg config.StorageConfig) error {
+	exists, err := client.BucketExists(ctx, cfg.BucketName)
+	if err != nil {
+		return fmt.Errorf("failed to check bucket existence: %w", err)
+	}
+
+	if !exists {
+		if !cfg.AutoCreateBucket {
+			return fmt.Errorf("bucket %s does not exist", cfg.BucketName)
+		}
+		if err := client.MakeBucket(ctx, cfg.BucketName, minio.MakeBucketOptions{Region: cfg.Region}); err != nil {
+			return fmt.Errorf("failed to create bucket %s: %w", cfg.BucketName, err)
+		}
+	}
+
+	if cfg.TmpExpiryDays > 0 {
+		return applyTmpExpiry(ctx, client, cfg.BucketName, cfg.TmpExpiryDays)
+	}
+
+	return nil
+}
+
+// applyTmpExpiry sets the rule expiring objects under TmpPrefix after days,
+// keeping any other lifecycle rules on the bucket
+func applyTmpExpiry(ctx context.Context, client *minio.Client, bucket string, days int) error {
+	current, err := client.GetBucketLifecycle(ctx, bucket)
+	if err != nil {
+		if minio.ToErrorResponse(err).Code != "NoSuchLifecycleConfiguration" {
+			return fmt.Errorf("failed to get bucket lifecycle: %w", err)
+		}
+		current = lifecycle.NewConfiguration()
+	}
+
+	rule := lifecycle.Rule{
+		ID:         tmpExpiryRuleID,
+		Status:     "Enabled",
+		RuleFilter: lifecycle.Filter{Prefix: TmpPrefix},
+		Expiration: lifecycle.Expiration{Days: lifecycle.ExpirationDays(days)},
+	}
+
+	rules := make([]lifecycle.Rule, 0, len(current.Rules)+1)
+	for _, r := range current.Rules {
+		if r.ID != tmpExpiryRuleID {
+			rules = append(rules, r)
+		}
+	}
+	current.Rules = append(rules, rule)
+
+	if err := client.SetBucketLifecycle(ctx, bucket, current); err != nil {
+		return fmt.Errorf("failed to set bucket lifecycle: %w", err)
+	}
+
+	return nil
+}
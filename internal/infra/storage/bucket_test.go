@@ -0,0 +1,289 @@
+package storage
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"fowergram-backend/internal/config"
+
+	"github.com/google/uuid"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/lifecycle"
+)
+
+// fakeS3 serves the bucket and lifecycle calls ensureBucket makes for a
+// single bucket, in path style
+type fakeS3 struct {
+	mu        sync.Mutex
+	bucket    string
+	exists    bool
+	lifecycle []byte // Stored configuration, nil when none is set
+	created   int    // Times the bucket was created
+}
+
+func (s *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.URL.Path != "/"+s.bucket && r.URL.Path != "/"+s.bucket+"/" {
+		http.Error(w, "unexpected path "+r.URL.Path, http.StatusBadRequest)
+		return
+	}
+	if !s.exists && r.Method != http.MethodPut {
+		s.writeError(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+
+	_, lifecycleQuery := r.URL.Query()["lifecycle"]
+	switch {
+	case r.Method == http.MethodHead:
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodPut && !lifecycleQuery:
+		s.exists = true
+		s.created++
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodGet && lifecycleQuery:
+		if s.lifecycle == nil {
+			s.writeError(w, http.StatusNotFound, "NoSuchLifecycleConfiguration")
+			return
+		}
+		w.Write(s.lifecycle)
+	case r.Method == http.MethodPut && lifecycleQuery:
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.lifecycle = body
+		w.WriteHeader(http.StatusOK)
+	default:
+		http.Error(w, "unexpected "+r.Method+" "+r.URL.String(), http.StatusBadRequest)
+	}
+}
+
+func (s *fakeS3) writeError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?><Error><Code>` + code + `</Code><BucketName>` + s.bucket + `</BucketName></Error>`))
+}
+
+// rules returns the stored lifecycle rules
+func (s *fakeS3) rules(t *testing.T) []lifecycle.Rule {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lifecycle == nil {
+		return nil
+	}
+	var cfg lifecycle.Configuration
+	if err := xml.Unmarshal(s.lifecycle, &cfg); err != nil {
+		t.Fatalf("decoding the lifecycle %s: %v", s.lifecycle, err)
+	}
+	return cfg.Rules
+}
+
+// operatorRule is a lifecycle rule set up outside the service
+const operatorRule = `<LifecycleConfiguration><Rule><ID>archive</ID><Status>Enabled</Status>` +
+	`<Filter><Prefix>archive/</Prefix></Filter><Expiration><Days>365</Days></Expiration></Rule></LifecycleConfiguration>`
+
+func TestEnsureBucket(t *testing.T) {
+	tests := []struct {
+		name        string
+		exists      bool
+		lifecycle   string
+		autoCreate  bool
+		expiryDays  []int // Startups, each with this TmpExpiryDays
+		wantErr     bool
+		wantCreated int
+		wantRules   map[string]int // Days each rule expires objects after, by ID
+	}{
+		{name: "missing bucket", expiryDays: []int{0}, wantErr: true},
+		{name: "missing bucket created", autoCreate: true, expiryDays: []int{0}, wantCreated: 1},
+		{
+			name:        "created with tmp expiry",
+			autoCreate:  true,
+			expiryDays:  []int{7},
+			wantCreated: 1,
+			wantRules:   map[string]int{tmpExpiryRuleID: 7},
+		},
+		{name: "existing bucket left alone", exists: true, autoCreate: true, expiryDays: []int{0}},
+		{
+			name:       "expiry changed, other rules kept",
+			exists:     true,
+			lifecycle:  operatorRule,
+			expiryDays: []int{7, 3},
+			wantRules:  map[string]int{"archive": 365, tmpExpiryRuleID: 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s3 := &fakeS3{bucket: "media", exists: tt.exists}
+			if tt.lifecycle != "" {
+				s3.lifecycle = []byte(tt.lifecycle)
+			}
+			server := httptest.NewServer(s3)
+			t.Cleanup(server.Close)
+
+			var err error
+			for _, days := range tt.expiryDays {
+				cfg := config.StorageConfig{
+					Endpoint:         strings.TrimPrefix(server.URL, "http://"),
+					AccessKeyID:      "access",
+					SecretAccessKey:  "secret",
+					BucketName:       "media",
+					Region:           "us-east-1",
+					ForcePathStyle:   true,
+					AutoCreateBucket: tt.autoCreate,
+					TmpExpiryDays:    days,
+				}
+				client, clientErr := newClient(cfg)
+				if clientErr != nil {
+					t.Fatalf("newClient: %v", clientErr)
+				}
+				if err = ensureBucket(context.Background(), client, cfg); err != nil {
+					break
+				}
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ensureBucket() error = %v, want error %v", err, tt.wantErr)
+			}
+			if s3.created != tt.wantCreated {
+				t.Errorf("bucket created %d times, want %d", s3.created, tt.wantCreated)
+			}
+
+			rules := s3.rules(t)
+			if tt.wantRules == nil {
+				if tt.lifecycle == "" && rules != nil {
+					t.Errorf("lifecycle set to %+v, want it untouched", rules)
+				}
+				return
+			}
+			got := make(map[string]int, len(rules))
+			for _, rule := range rules {
+				got[rule.ID] = int(rule.Expiration.Days)
+				if rule.ID == tmpExpiryRuleID && (rule.RuleFilter.Prefix != TmpPrefix || rule.Status != "Enabled") {
+					t.Errorf("tmp rule = %+v, want it enabled for %s", rule, TmpPrefix)
+				}
+			}
+			if len(got) != len(tt.wantRules) {
+				t.Fatalf("rules = %v, want %v", got, tt.wantRules)
+			}
+			for id, days := range tt.wantRules {
+				if got[id] != days {
+					t.Errorf("rule %s expires after %d days, want %d", id, got[id], days)
+				}
+			}
+		})
+	}
+}
+
+// testMinIOConfig configures a bucket of its own on TEST_MINIO_ENDPOINT,
+// removed when the test ends. The test is skipped without a server.
+func testMinIOConfig(t *testing.T) config.StorageConfig {
+	t.Helper()
+	endpoint := os.Getenv("TEST_MINIO_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("TEST_MINIO_ENDPOINT is not set")
+	}
+	getEnv := func(key, fallback string) string {
+		if value := os.Getenv(key); value != "" {
+			return value
+		}
+		return fallback
+	}
+
+	cfg := config.StorageConfig{
+		Endpoint:        endpoint,
+		AccessKeyID:     getEnv("TEST_MINIO_ACCESS_KEY", "minioadmin"),
+		SecretAccessKey: getEnv("TEST_MINIO_SECRET_KEY", "minioadmin"),
+		BucketName:      "test-" + uuid.NewString(),
+		Region:          "us-east-1",
+		ForcePathStyle:  true,
+	}
+	client, err := newClient(cfg)
+	if err != nil {
+		t.Fatalf("newClient: %v", err)
+	}
+	t.Cleanup(func() {
+		ctx := context.Background()
+		if exists, err := client.BucketExists(ctx, cfg.BucketName); err != nil || !exists {
+			return
+		}
+		if err := client.RemoveBucketWithOptions(ctx, cfg.BucketName, minio.RemoveBucketOptions{ForceDelete: true}); err != nil {
+			t.Errorf("removing bucket %s: %v", cfg.BucketName, err)
+		}
+	})
+	return cfg
+}
+
+func TestEnsureBucketMinIO(t *testing.T) {
+	ctx := context.Background()
+	cfg := testMinIOConfig(t)
+	client, err := newClient(cfg)
+	if err != nil {
+		t.Fatalf("newClient: %v", err)
+	}
+
+	rules := func() map[string]lifecycle.Rule {
+		t.Helper()
+		current, err := client.GetBucketLifecycle(ctx, cfg.BucketName)
+		if err != nil {
+			t.Fatalf("GetBucketLifecycle: %v", err)
+		}
+		byID := make(map[string]lifecycle.Rule, len(current.Rules))
+		for _, rule := range current.Rules {
+			byID[rule.ID] = rule
+		}
+		return byID
+	}
+
+	// Without AutoCreateBucket a missing bucket is an error, and left missing
+	if _, err := NewMinIOStorage(ctx, cfg); err == nil {
+		t.Fatal("NewMinIOStorage() for a missing bucket succeeded, want an error")
+	}
+	if exists, err := client.BucketExists(ctx, cfg.BucketName); err != nil || exists {
+		t.Fatalf("BucketExists = %v, %v; want the bucket still missing", exists, err)
+	}
+
+	cfg.AutoCreateBucket = true
+	cfg.TmpExpiryDays = 7
+	s, err := NewMinIOStorage(ctx, cfg)
+	if err != nil {
+		t.Fatalf("NewMinIOStorage: %v", err)
+	}
+	if err := s.HealthCheck(ctx); err != nil {
+		t.Fatalf("HealthCheck after creating the bucket: %v", err)
+	}
+	tmp, ok := rules()[tmpExpiryRuleID]
+	if !ok || tmp.Status != "Enabled" || tmp.RuleFilter.Prefix != TmpPrefix || tmp.Expiration.Days != 7 {
+		t.Errorf("tmp rule = %+v, want it enabled for %s after 7 days", tmp, TmpPrefix)
+	}
+
+	// A restart with a new expiry updates the rule and keeps the operator's
+	operator := lifecycle.NewConfiguration()
+	operator.Rules = append(operator.Rules, tmp, lifecycle.Rule{
+		ID:         "archive",
+		Status:     "Enabled",
+		RuleFilter: lifecycle.Filter{Prefix: "archive/"},
+		Expiration: lifecycle.Expiration{Days: 365},
+	})
+	if err := client.SetBucketLifecycle(ctx, cfg.BucketName, operator); err != nil {
+		t.Fatalf("adding the operator rule: %v", err)
+	}
+	cfg.TmpExpiryDays = 3
+	if _, err := NewMinIOStorage(ctx, cfg); err != nil {
+		t.Fatalf("NewMinIOStorage on restart: %v", err)
+	}
+	got := rules()
+	if len(got) != 2 || got[tmpExpiryRuleID].Expiration.Days != 3 || got["archive"].Expiration.Days != 365 {
+		t.Errorf("rules = %+v, want the tmp rule after 3 days and the archive rule kept", got)
+	}
+}
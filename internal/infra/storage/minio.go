@@ -1,55 +1,250 @@
 package storage
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 
 	"fowergram-backend/internal/config"
+	"fowergram-backend/pkg/retry"
 
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
 )
 
-// MinIOStorage implements storage using MinIO
+// Supported server-side encryption modes
+const (
+	SSEModeS3  = "AES256"
+	SSEModeKMS = "aws:kms"
+)
+
+// ErrObjectNotFound is returned when the requested object doesn't exist
+var ErrObjectNotFound = errors.New("object not found")
+
+// MinIOStorage implements storage using MinIO or any S3-compatible service
 type MinIOStorage struct {
-	client *minio.Client
-	bucket string
+	client     *minio.Client
+	bucket     string
+	sse        encrypt.ServerSide
+	cdnBaseURL string
 }
 
-// NewMinIOStorage creates a new MinIO storage client
-func NewMinIOStorage(cfg config.StorageConfig) (*MinIOStorage, error) {
+// NewMinIOStorage creates a new MinIO storage client, checking the bucket
+// within ctx
+func NewMinIOStorage(ctx context.Context, cfg config.StorageConfig) (*MinIOStorage, error) {
+	sse, err := newServerSideEncryption(cfg)
+	if err != nil {
+		return nil, retry.Permanent(err)
+	}
+
+	client, err := newClient(cfg)
+	if err != nil {
+		return nil, retry.Permanent(err)
+	}
+
+	if err := ensureBucket(ctx, client, cfg); err != nil {
+		return nil, err
+	}
+
+	return &MinIOStorage{
+		client:     client,
+		bucket:     cfg.BucketName,
+		sse:        sse,
+		cdnBaseURL: cfg.CDNBaseURL,
+	}, nil
+}
+
+// newClient creates a client for the configured endpoint without connecting
+func newClient(cfg config.StorageConfig) (*minio.Client, error) {
+	bucketLookup := minio.BucketLookupDNS
+	if cfg.ForcePathStyle {
+		bucketLookup = minio.BucketLookupPath
+	}
+
 	client, err := minio.New(cfg.Endpoint, &minio.Options{
-		Creds:  credentials.NewStaticV4(cfg.AccessKeyID, cfg.SecretAccessKey, ""),
-		Secure: cfg.UseSSL,
+		Creds:        credentials.NewStaticV4(cfg.AccessKeyID, cfg.SecretAccessKey, ""),
+		Secure:       cfg.UseSSL,
+		Region:       cfg.Region,
+		BucketLookup: bucketLookup,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create MinIO client: %w", err)
 	}
+	return client, nil
+}
 
-	// Check if bucket exists
-	exists, err := client.BucketExists(context.Background(), cfg.BucketName)
+// HealthCheck checks that MinIO is reachable and the bucket still exists
+func (s *MinIOStorage) HealthCheck(ctx context.Context) error {
+	exists, err := s.client.BucketExists(ctx, s.bucket)
 	if err != nil {
-		return nil, fmt.Errorf("failed to check bucket existence: %w", err)
+		return fmt.Errorf("failed to reach storage: %w", err)
 	}
-
 	if !exists {
-		return nil, fmt.Errorf("bucket %s does not exist", cfg.BucketName)
+		return fmt.Errorf("bucket %s does not exist", s.bucket)
 	}
+	return nil
+}
 
-	return &MinIOStorage{
-		client: client,
-		bucket: cfg.BucketName,
-	}, nil
+// newServerSideEncryption builds the SSE settings applied to every upload
+func newServerSideEncryption(cfg config.StorageConfig) (encrypt.ServerSide, error) {
+	switch cfg.SSE {
+	case "":
+		return nil, nil
+	case SSEModeS3:
+		return encrypt.NewSSE(), nil
+	case SSEModeKMS:
+		if cfg.SSEKMSKeyID == "" {
+			return nil, fmt.Errorf("MINIO_SSE_KMS_KEY_ID is required for %s encryption", SSEModeKMS)
+		}
+		return encrypt.NewSSEKMS(cfg.SSEKMSKeyID, nil)
+	default:
+		return nil, fmt.Errorf("unsupported server-side encryption mode %q", cfg.SSE)
+	}
+}
+
+// presignedURLExpiry is how long presigned download URLs stay valid
+const presignedURLExpiry = 24 * time.Hour
+
+// presignedUploadExpiry is how long presigned upload URLs stay valid
+const presignedUploadExpiry = 15 * time.Minute
+
+// PresignedUpload is a URL a client can PUT an object to directly. The
+// request must carry exactly the listed headers.
+type PresignedUpload struct {
+	URL       string
+	Headers   map[string]string
+	ExpiresAt time.Time
+}
+
+// ObjectInfo describes a stored object
+type ObjectInfo struct {
+	Size        int64
+	ContentType string
 }
 
 // UploadFile uploads a file to storage
 func (s *MinIOStorage) UploadFile(ctx context.Context, objectName string, data []byte, contentType string) error {
-	// Implementation would handle file upload
+	_, err := s.client.PutObject(ctx, s.bucket, objectName, bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{
+		ContentType:          contentType,
+		ServerSideEncryption: s.sse,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload %s: %w", objectName, err)
+	}
+
+	return nil
+}
+
+// GetFile downloads a file from storage
+func (s *MinIOStorage) GetFile(ctx context.Context, objectName string) ([]byte, error) {
+	obj, err := s.client.GetObject(ctx, s.bucket, objectName, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s: %w", objectName, err)
+	}
+	defer obj.Close()
+
+	data, err := io.ReadAll(obj)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", objectName, err)
+	}
+
+	return data, nil
+}
+
+// PresignUpload returns a URL for uploading exactly size bytes of contentType
+// to objectName. Both are part of the signature, so storage rejects a body of
+// any other length or type.
+func (s *MinIOStorage) PresignUpload(ctx context.Context, objectName, contentType string, size int64) (*PresignedUpload, error) {
+	header := http.Header{}
+	header.Set("Content-Type", contentType)
+	header.Set("Content-Length", strconv.FormatInt(size, 10))
+	if s.sse != nil {
+		s.sse.Marshal(header)
+	}
+
+	expiresAt := time.Now().Add(presignedUploadExpiry)
+	u, err := s.client.PresignHeader(ctx, http.MethodPut, s.bucket, objectName, presignedUploadExpiry, nil, header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to presign upload of %s: %w", objectName, err)
+	}
+
+	headers := make(map[string]string, len(header))
+	for name := range header {
+		headers[name] = header.Get(name)
+	}
+
+	return &PresignedUpload{
+		URL:       u.String(),
+		Headers:   headers,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// StatFile returns the size and content type of a stored file
+func (s *MinIOStorage) StatFile(ctx context.Context, objectName string) (*ObjectInfo, error) {
+	info, err := s.client.StatObject(ctx, s.bucket, objectName, minio.StatObjectOptions{})
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, ErrObjectNotFound
+		}
+		return nil, fmt.Errorf("failed to stat %s: %w", objectName, err)
+	}
+
+	return &ObjectInfo{
+		Size:        info.Size,
+		ContentType: info.ContentType,
+	}, nil
+}
+
+// ListFiles returns the keys of every file under prefix
+func (s *MinIOStorage) ListFiles(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	for obj := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if obj.Err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", prefix, obj.Err)
+		}
+		keys = append(keys, obj.Key)
+	}
+
+	return keys, nil
+}
+
+// DeleteFile removes a file from storage
+func (s *MinIOStorage) DeleteFile(ctx context.Context, objectName string) error {
+	if err := s.client.RemoveObject(ctx, s.bucket, objectName, minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to delete %s: %w", objectName, err)
+	}
+
 	return nil
 }
 
-// GetFileURL returns a presigned URL for file access
+// GetFileURL returns a URL for file access: a CDN URL when a CDN base URL is
+// configured, otherwise a presigned URL
 func (s *MinIOStorage) GetFileURL(ctx context.Context, objectName string) (string, error) {
-	// Implementation would return presigned URL
-	return "", nil
+	if s.cdnBaseURL != "" {
+		return cdnURL(s.cdnBaseURL, objectName)
+	}
+
+	u, err := s.client.PresignedGetObject(ctx, s.bucket, objectName, presignedURLExpiry, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to presign %s: %w", objectName, err)
+	}
+
+	return u.String(), nil
+}
+
+// cdnURL joins an object key onto the CDN base URL
+func cdnURL(baseURL, objectName string) (string, error) {
+	u, err := url.JoinPath(baseURL, objectName)
+	if err != nil {
+		return "", fmt.Errorf("failed to build CDN URL for %s: %w", objectName, err)
+	}
+	return u, nil
 }
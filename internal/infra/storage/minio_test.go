@@ -0,0 +1,304 @@
+package storage
+
+import (
+	"context"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"fowergram-backend/internal/config"
+	"fowergram-backend/pkg/retry"
+
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+)
+
+func TestGetFileURL(t *testing.T) {
+	tests := []struct {
+		name       string
+		cfg        config.StorageConfig
+		wantURL    string // Without the query of a presigned URL
+		wantRegion string // In the presigned URL's credential; empty for CDN URLs
+	}{
+		{
+			name: "MinIO with path-style addressing",
+			cfg: config.StorageConfig{
+				Endpoint:       "minio:9000",
+				BucketName:     "media",
+				Region:         "us-east-1",
+				ForcePathStyle: true,
+			},
+			wantURL:    "http://minio:9000/media/posts/a.jpg",
+			wantRegion: "us-east-1",
+		},
+		{
+			name: "S3-compatible service with virtual-hosted addressing",
+			cfg: config.StorageConfig{
+				Endpoint:   "objects.example.com",
+				UseSSL:     true,
+				BucketName: "media",
+				Region:     "eu-west-1",
+			},
+			wantURL:    "https://media.objects.example.com/posts/a.jpg",
+			wantRegion: "eu-west-1",
+		},
+		{
+			name: "CDN replaces presigned URLs",
+			cfg: config.StorageConfig{
+				Endpoint:   "s3.eu-west-1.amazonaws.com",
+				UseSSL:     true,
+				BucketName: "media",
+				Region:     "eu-west-1",
+				CDNBaseURL: "https://cdn.example.com/assets/",
+			},
+			wantURL: "https://cdn.example.com/assets/posts/a.jpg",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.AccessKeyID, tt.cfg.SecretAccessKey = "access", "secret"
+			client, err := newClient(tt.cfg)
+			if err != nil {
+				t.Fatalf("newClient: %v", err)
+			}
+			s := &MinIOStorage{client: client, bucket: tt.cfg.BucketName, cdnBaseURL: tt.cfg.CDNBaseURL}
+
+			got, err := s.GetFileURL(context.Background(), "posts/a.jpg")
+			if err != nil {
+				t.Fatalf("GetFileURL: %v", err)
+			}
+			u, err := url.Parse(got)
+			if err != nil {
+				t.Fatalf("parsing %s: %v", got, err)
+			}
+			query := u.Query()
+			u.RawQuery = ""
+			if u.String() != tt.wantURL {
+				t.Errorf("URL = %s, want %s", u, tt.wantURL)
+			}
+
+			credential := query.Get("X-Amz-Credential")
+			if tt.wantRegion == "" {
+				if credential != "" {
+					t.Errorf("CDN URL is presigned: %s", got)
+				}
+				return
+			}
+			if !strings.Contains(credential, "/"+tt.wantRegion+"/s3/") {
+				t.Errorf("credential = %q, want it scoped to %s", credential, tt.wantRegion)
+			}
+		})
+	}
+}
+
+func TestServerSideEncryption(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      config.StorageConfig
+		wantType encrypt.Type // Empty for no encryption
+		wantErr  bool
+	}{
+		{name: "off", cfg: config.StorageConfig{}},
+		{name: "S3-managed keys", cfg: config.StorageConfig{SSE: SSEModeS3}, wantType: encrypt.S3},
+		{name: "KMS key", cfg: config.StorageConfig{SSE: SSEModeKMS, SSEKMSKeyID: "alias/media"}, wantType: encrypt.KMS},
+		{name: "KMS without a key", cfg: config.StorageConfig{SSE: SSEModeKMS}, wantErr: true},
+		{name: "unknown mode", cfg: config.StorageConfig{SSE: "rot13"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sse, err := newServerSideEncryption(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			var got encrypt.Type
+			if sse != nil {
+				got = sse.Type()
+			}
+			if got != tt.wantType {
+				t.Errorf("encryption = %q, want %q", got, tt.wantType)
+			}
+		})
+	}
+}
+
+func TestPresignUpload(t *testing.T) {
+	tests := []struct {
+		name        string
+		sse         encrypt.ServerSide
+		wantHeaders map[string]string
+	}{
+		{
+			name:        "unencrypted",
+			wantHeaders: map[string]string{"Content-Type": "image/png", "Content-Length": "2048"},
+		},
+		{
+			name: "S3-managed encryption",
+			sse:  encrypt.NewSSE(),
+			wantHeaders: map[string]string{
+				"Content-Type":                 "image/png",
+				"Content-Length":               "2048",
+				"X-Amz-Server-Side-Encryption": "AES256",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.StorageConfig{
+				Endpoint:        "minio:9000",
+				BucketName:      "media",
+				Region:          "us-east-1",
+				ForcePathStyle:  true,
+				AccessKeyID:     "access",
+				SecretAccessKey: "secret",
+			}
+			client, err := newClient(cfg)
+			if err != nil {
+				t.Fatalf("newClient: %v", err)
+			}
+			s := &MinIOStorage{client: client, bucket: cfg.BucketName, sse: tt.sse}
+
+			before := time.Now()
+			upload, err := s.PresignUpload(context.Background(), "media/u/m/source", "image/png", 2048)
+			if err != nil {
+				t.Fatalf("PresignUpload: %v", err)
+			}
+
+			u, err := url.Parse(upload.URL)
+			if err != nil {
+				t.Fatalf("parsing %s: %v", upload.URL, err)
+			}
+			if u.Host != "minio:9000" || u.Path != "/media/media/u/m/source" {
+				t.Errorf("URL = %s, want the key in the media bucket", upload.URL)
+			}
+
+			query := u.Query()
+			if got := query.Get("X-Amz-Expires"); got != "900" {
+				t.Errorf("X-Amz-Expires = %q, want 900", got)
+			}
+			if upload.ExpiresAt.Before(before.Add(presignedUploadExpiry)) || upload.ExpiresAt.After(time.Now().Add(presignedUploadExpiry)) {
+				t.Errorf("expires at %v, want %v from now", upload.ExpiresAt, presignedUploadExpiry)
+			}
+
+			// Every header the client must send is signed, so storage
+			// rejects a body of another size or type
+			signed := strings.Split(query.Get("X-Amz-SignedHeaders"), ";")
+			for name := range tt.wantHeaders {
+				if !slices.Contains(signed, strings.ToLower(name)) {
+					t.Errorf("signed headers %v don't include %s", signed, name)
+				}
+			}
+			if !maps.Equal(upload.Headers, tt.wantHeaders) {
+				t.Errorf("headers = %v, want %v", upload.Headers, tt.wantHeaders)
+			}
+		})
+	}
+}
+
+// testBackoff keeps retries quick
+var testBackoff = retry.Backoff{Initial: time.Millisecond, Max: 5 * time.Millisecond}
+
+// storageConfig configures a client for the fake S3 at url
+func storageConfig(url string) config.StorageConfig {
+	return config.StorageConfig{
+		Endpoint:        strings.TrimPrefix(url, "http://"),
+		AccessKeyID:     "access",
+		SecretAccessKey: "secret",
+		BucketName:      "media",
+		Region:          "us-east-1",
+		ForcePathStyle:  true,
+	}
+}
+
+func TestNewMinIOStorageRetried(t *testing.T) {
+	tests := []struct {
+		name         string
+		createdAfter int // Bucket checks answered before another service creates the bucket, -1 for never
+		sse          string
+		wantErr      bool
+		wantAttempts int
+	}{
+		{name: "bucket ready", createdAfter: 0, wantAttempts: 1},
+		{name: "bucket created while retrying", createdAfter: 2, wantAttempts: 3},
+		{name: "gives up at the timeout", createdAfter: -1, wantErr: true},
+		{name: "bad config isn't retried", createdAfter: 0, sse: "rot13", wantErr: true, wantAttempts: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s3 := &fakeS3{bucket: "media"}
+			checks := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				s3.mu.Lock()
+				if r.Method == http.MethodHead {
+					s3.exists = tt.createdAfter >= 0 && checks >= tt.createdAfter
+					checks++
+				}
+				s3.mu.Unlock()
+				s3.ServeHTTP(w, r)
+			}))
+			t.Cleanup(server.Close)
+
+			cfg := storageConfig(server.URL)
+			cfg.SSE = tt.sse
+
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+			attempts := 0
+			err := retry.Do(ctx, testBackoff, func(ctx context.Context) error {
+				attempts++
+				_, err := NewMinIOStorage(ctx, cfg)
+				return err
+			}, nil)
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantAttempts != 0 && attempts != tt.wantAttempts {
+				t.Errorf("%d attempts, want %d", attempts, tt.wantAttempts)
+			}
+			if tt.createdAfter < 0 && attempts < 2 {
+				t.Errorf("%d attempts, want retries until the timeout", attempts)
+			}
+		})
+	}
+}
+
+func TestHealthCheck(t *testing.T) {
+	s3 := &fakeS3{bucket: "media", exists: true}
+	server := httptest.NewServer(s3)
+	t.Cleanup(server.Close)
+
+	storage, err := NewMinIOStorage(context.Background(), storageConfig(server.URL))
+	if err != nil {
+		t.Fatalf("NewMinIOStorage: %v", err)
+	}
+	if err := storage.HealthCheck(context.Background()); err != nil {
+		t.Errorf("HealthCheck() = %v, want nil", err)
+	}
+
+	s3.mu.Lock()
+	s3.exists = false
+	s3.mu.Unlock()
+	if err := storage.HealthCheck(context.Background()); err == nil {
+		t.Error("HealthCheck() = nil after the bucket was removed")
+	}
+
+	// The client retries network errors itself until the context ends
+	server.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if err := storage.HealthCheck(ctx); err == nil {
+		t.Error("HealthCheck() = nil with MinIO down")
+	}
+}
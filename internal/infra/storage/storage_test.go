@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestNoopStorage(t *testing.T) {
+	ctx := context.Background()
+	var s Storage = NoopStorage{}
+	const key = "media/u/m/original"
+
+	if err := s.HealthCheck(ctx); err != nil {
+		t.Errorf("HealthCheck() = %v, want nil", err)
+	}
+
+	// Nothing can be stored
+	if err := s.UploadFile(ctx, key, []byte("image"), "image/png"); !errors.Is(err, ErrStorageUnavailable) {
+		t.Errorf("UploadFile() = %v, want %v", err, ErrStorageUnavailable)
+	}
+	if upload, err := s.PresignUpload(ctx, key, "image/png", 5); upload != nil || !errors.Is(err, ErrStorageUnavailable) {
+		t.Errorf("PresignUpload() = %+v, %v; want %v", upload, err, ErrStorageUnavailable)
+	}
+
+	// So lookups find nothing, as for missing objects
+	if data, err := s.GetFile(ctx, key); data != nil || !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("GetFile() = %q, %v; want %v", data, err, ErrObjectNotFound)
+	}
+	if info, err := s.StatFile(ctx, key); info != nil || !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("StatFile() = %+v, %v; want %v", info, err, ErrObjectNotFound)
+	}
+	if keys, err := s.ListFiles(ctx, "media/"); len(keys) != 0 || err != nil {
+		t.Errorf("ListFiles() = %v, %v; want no keys", keys, err)
+	}
+	if url, err := s.GetFileURL(ctx, key); url != "" || err != nil {
+		t.Errorf("GetFileURL() = %q, %v; want an empty URL", url, err)
+	}
+
+	// Cleanup has nothing to do and succeeds
+	if err := s.DeleteFile(ctx, key); err != nil {
+		t.Errorf("DeleteFile() = %v, want nil", err)
+	}
+}
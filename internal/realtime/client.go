@@ -0,0 +1,148 @@
+package realtime
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gofiber/contrib/websocket"
+	"github.com/google/uuid"
+)
+
+const (
+	// sendBufferSize is how many frames may wait for a client before it is
+	// considered too slow and disconnected
+	sendBufferSize = 32
+
+	// writeWait bounds writing a single frame
+	writeWait = 10 * time.Second
+
+	// pongWait is how long a client may stay silent before it is considered
+	// gone. Pings are sent often enough for a live client to answer in time.
+	pongWait   = 60 * time.Second
+	pingPeriod = pongWait * 9 / 10
+
+	// maxMessageSize limits what clients may send; they only answer pings
+	maxMessageSize = 512
+)
+
+// client is one websocket connection of a user
+type client struct {
+	userID    uuid.UUID
+	following map[uuid.UUID]struct{}
+	send      chan []byte
+
+	closeOnce sync.Once
+	done      chan struct{}
+	closeCode int
+	closeText string
+}
+
+func newClient(userID uuid.UUID, following []uuid.UUID) *client {
+	c := &client{
+		userID:    userID,
+		following: make(map[uuid.UUID]struct{}, len(following)),
+		send:      make(chan []byte, sendBufferSize),
+		done:      make(chan struct{}),
+	}
+	for _, id := range following {
+		c.following[id] = struct{}{}
+	}
+	return c
+}
+
+func (c *client) follows(authorID uuid.UUID) bool {
+	_, ok := c.following[authorID]
+	return ok
+}
+
+// close asks the connection to shut down with the given close frame. Only
+// the first call has an effect.
+func (c *client) close(code int, text string) {
+	c.closeOnce.Do(func() {
+		c.closeCode = code
+		c.closeText = text
+		close(c.done)
+	})
+}
+
+// Serve pushes frames for userID over conn until either side closes it. The
+// accounts the user follows are read once, so follows made while connected
+// take effect on the next connection.
+func (h *Hub) Serve(conn *websocket.Conn, userID uuid.UUID) {
+	ctx, cancel := context.WithTimeout(context.Background(), followingTimeout)
+	following, err := h.following.GetFollowingIDs(ctx, userID)
+	cancel()
+	if err != nil {
+		h.logger.Error("Failed to load follows for websocket client", "user_id", userID, "error", err)
+		writeClose(conn, websocket.CloseInternalServerErr, "internal error")
+		return
+	}
+
+	c := newClient(userID, following)
+	if !h.register(c) {
+		writeClose(conn, websocket.CloseGoingAway, "server shutting down")
+		return
+	}
+	defer h.unregister(c)
+
+	readDone := make(chan struct{})
+	go func() {
+		defer close(readDone)
+		readPump(conn)
+	}()
+
+	writePump(conn, c, readDone)
+
+	// Unblock the reader if the write side ended the connection
+	conn.Close()
+	<-readDone
+}
+
+// readPump discards what the client sends and keeps the connection alive
+// while pongs arrive. It returns once the connection fails or is closed.
+func readPump(conn *websocket.Conn) {
+	conn.SetReadLimit(maxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+// writePump writes queued frames and pings until the client is closed, a
+// write fails or the reader stops
+func writePump(conn *websocket.Conn, c *client, readDone <-chan struct{}) {
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case frame := <-c.send:
+			conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := conn.WriteMessage(websocket.TextMessage, frame); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+				return
+			}
+		case <-c.done:
+			writeClose(conn, c.closeCode, c.closeText)
+			return
+		case <-readDone:
+			return
+		}
+	}
+}
+
+// writeClose sends a close frame, ignoring failures since the connection is
+// going away regardless
+func writeClose(conn *websocket.Conn, code int, text string) {
+	_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, text), time.Now().Add(writeWait))
+}
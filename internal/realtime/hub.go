@@ -0,0 +1,253 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"fowergram-backend/internal/events"
+	"fowergram-backend/internal/infra/messaging"
+	"fowergram-backend/pkg/logger"
+
+	"github.com/gofiber/contrib/websocket"
+	"github.com/google/uuid"
+)
+
+// Frame types pushed to clients
+const (
+	FrameNotification = "notification"
+	FramePost         = "post"
+	FrameMessage      = "message"
+)
+
+// Frame is a JSON message pushed to a connected client
+type Frame struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+}
+
+// FollowingLister looks up the accounts a user follows, whose new posts are
+// pushed to the user
+type FollowingLister interface {
+	GetFollowingIDs(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error)
+}
+
+// followingTimeout bounds loading a connecting user's follows
+const followingTimeout = 5 * time.Second
+
+// Hub keeps the websocket connections open on this instance by user and
+// pushes them the events they care about. Every instance subscribes to the
+// events itself, so no registry is shared between instances.
+type Hub struct {
+	following FollowingLister
+	logger    logger.Logger
+
+	mu            sync.RWMutex
+	clients       map[uuid.UUID]map[*client]struct{}
+	subscriptions map[uuid.UUID]map[*Subscription]struct{}
+	closed        bool
+
+	// Event subscriptions made by Start
+	events []*messaging.Subscription
+}
+
+// NewHub creates a new hub
+func NewHub(following FollowingLister, logger logger.Logger) *Hub {
+	return &Hub{
+		following:     following,
+		logger:        logger,
+		clients:       make(map[uuid.UUID]map[*client]struct{}),
+		subscriptions: make(map[uuid.UUID]map[*Subscription]struct{}),
+	}
+}
+
+// Start subscribes the hub to notification, new post and direct message events. The
+// subscriptions end when the hub is stopped or the messaging client is
+// drained or closed.
+func (h *Hub) Start(client messaging.Client) error {
+	subscriptions := []struct {
+		subject events.Type
+		handler func(ctx context.Context, msg []byte)
+	}{
+		{events.TypeNotificationCreated, h.handleNotification},
+		{events.TypePostCreated, h.handlePost},
+		{events.TypeDirectMessageCreated, h.handleDirectMessage},
+	}
+
+	for _, s := range subscriptions {
+		sub, err := client.Subscribe(string(s.subject), s.handler)
+		if err != nil {
+			return fmt.Errorf("failed to subscribe to %s: %w", s.subject, err)
+		}
+		h.events = append(h.events, sub)
+	}
+	return nil
+}
+
+// Stop closes the hub and stops its event subscriptions, waiting for events
+// already received to be handled
+func (h *Hub) Stop(ctx context.Context) error {
+	h.Close()
+	return messaging.DrainAll(ctx, h.events)
+}
+
+// Close disconnects every client with a going away close frame, ends every
+// subscription and refuses new ones. It is called on shutdown.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for _, set := range h.clients {
+		for c := range set {
+			c.close(websocket.CloseGoingAway, "server shutting down")
+		}
+	}
+	h.clients = make(map[uuid.UUID]map[*client]struct{})
+
+	for _, set := range h.subscriptions {
+		for s := range set {
+			s.close(websocket.CloseGoingAway, "server shutting down")
+		}
+	}
+	h.subscriptions = make(map[uuid.UUID]map[*Subscription]struct{})
+}
+
+func (h *Hub) handleNotification(_ context.Context, data []byte) {
+	var event events.NotificationCreated
+	if err := decode(data, &event); err != nil {
+		h.logger.Error("Failed to decode notification created event", "error", err)
+		return
+	}
+
+	payload := Frame{Type: FrameNotification, Data: event}
+	frame, err := json.Marshal(payload)
+	if err != nil {
+		h.logger.Error("Failed to encode notification frame", "error", err)
+		return
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for c := range h.clients[event.UserID] {
+		h.deliver(c, frame)
+	}
+	for s := range h.subscriptions[event.UserID] {
+		h.publish(s, payload)
+	}
+}
+
+func (h *Hub) handlePost(_ context.Context, data []byte) {
+	var event events.PostCreated
+	if err := decode(data, &event); err != nil {
+		h.logger.Error("Failed to decode post created event", "error", err)
+		return
+	}
+
+	payload := Frame{Type: FramePost, Data: event}
+	frame, err := json.Marshal(payload)
+	if err != nil {
+		h.logger.Error("Failed to encode post frame", "error", err)
+		return
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for _, set := range h.clients {
+		for c := range set {
+			if c.follows(event.AuthorID) {
+				h.deliver(c, frame)
+			}
+		}
+	}
+	for _, set := range h.subscriptions {
+		for s := range set {
+			if s.follows(event.AuthorID) {
+				h.publish(s, payload)
+			}
+		}
+	}
+}
+
+// handleDirectMessage pushes a message to the recipient and to the sender's
+// other connections
+func (h *Hub) handleDirectMessage(_ context.Context, data []byte) {
+	var event events.DirectMessageCreated
+	if err := decode(data, &event); err != nil {
+		h.logger.Error("Failed to decode direct message event", "error", err)
+		return
+	}
+
+	payload := Frame{Type: FrameMessage, Data: event}
+	frame, err := json.Marshal(payload)
+	if err != nil {
+		h.logger.Error("Failed to encode message frame", "error", err)
+		return
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for _, userID := range []uuid.UUID{event.RecipientID, event.SenderID} {
+		for c := range h.clients[userID] {
+			h.deliver(c, frame)
+		}
+		for s := range h.subscriptions[userID] {
+			h.publish(s, payload)
+		}
+	}
+}
+
+// deliver queues a frame for a client without blocking. A client whose send
+// buffer is full is too slow to keep up and is disconnected; its connection
+// unregisters it once the close frame is written.
+func (h *Hub) deliver(c *client, frame []byte) {
+	select {
+	case c.send <- frame:
+	default:
+		h.logger.Warn("Disconnecting slow websocket client", "user_id", c.userID)
+		c.close(websocket.CloseTryAgainLater, "client too slow")
+	}
+}
+
+func (h *Hub) register(c *client) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return false
+	}
+
+	set, ok := h.clients[c.userID]
+	if !ok {
+		set = make(map[*client]struct{})
+		h.clients[c.userID] = set
+	}
+	set[c] = struct{}{}
+	return true
+}
+
+func (h *Hub) unregister(c *client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if set, ok := h.clients[c.userID]; ok {
+		delete(set, c)
+		if len(set) == 0 {
+			delete(h.clients, c.userID)
+		}
+	}
+	c.close(websocket.CloseNormalClosure, "")
+}
+
+func decode(data []byte, payload events.Payload) error {
+	envelope, err := events.Decode(data)
+	if err != nil {
+		return err
+	}
+	return envelope.DecodePayload(payload)
+}
@@ -0,0 +1,120 @@
+package realtime
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/gofiber/contrib/websocket"
+	"github.com/google/uuid"
+)
+
+// ErrHubClosed is returned when subscribing to a hub that has shut down
+var ErrHubClosed = errors.New("realtime hub closed")
+
+// Subscription receives the events pushed to a user, for connections that
+// speak their own protocol instead of the /ws frames, such as GraphQL
+// subscriptions. Frames carry the decoded event as their data.
+type Subscription struct {
+	userID    uuid.UUID
+	following map[uuid.UUID]struct{}
+	events    chan Frame
+
+	closeOnce sync.Once
+	done      chan struct{}
+	closeCode int
+	closeText string
+}
+
+// Events returns the frames delivered to the subscription
+func (s *Subscription) Events() <-chan Frame {
+	return s.events
+}
+
+// Done is closed once the hub ends the subscription, when it shuts down or
+// the consumer falls behind. CloseReason then tells which.
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// CloseReason returns the websocket close code and text the subscription
+// ended with. It is only set once Done is closed.
+func (s *Subscription) CloseReason() (int, string) {
+	return s.closeCode, s.closeText
+}
+
+func (s *Subscription) follows(authorID uuid.UUID) bool {
+	_, ok := s.following[authorID]
+	return ok
+}
+
+// close ends the subscription. Only the first call has an effect.
+func (s *Subscription) close(code int, text string) {
+	s.closeOnce.Do(func() {
+		s.closeCode = code
+		s.closeText = text
+		close(s.done)
+	})
+}
+
+// Subscribe starts delivering userID's notifications, new posts from the
+// accounts they follow and direct messages. As with /ws clients, follows are
+// read once. Callers must Unsubscribe when done.
+func (h *Hub) Subscribe(ctx context.Context, userID uuid.UUID) (*Subscription, error) {
+	ctx, cancel := context.WithTimeout(ctx, followingTimeout)
+	following, err := h.following.GetFollowingIDs(ctx, userID)
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Subscription{
+		userID:    userID,
+		following: make(map[uuid.UUID]struct{}, len(following)),
+		events:    make(chan Frame, sendBufferSize),
+		done:      make(chan struct{}),
+	}
+	for _, id := range following {
+		s.following[id] = struct{}{}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return nil, ErrHubClosed
+	}
+
+	set, ok := h.subscriptions[userID]
+	if !ok {
+		set = make(map[*Subscription]struct{})
+		h.subscriptions[userID] = set
+	}
+	set[s] = struct{}{}
+	return s, nil
+}
+
+// Unsubscribe stops delivering events to s
+func (h *Hub) Unsubscribe(s *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if set, ok := h.subscriptions[s.userID]; ok {
+		delete(set, s)
+		if len(set) == 0 {
+			delete(h.subscriptions, s.userID)
+		}
+	}
+	s.close(websocket.CloseNormalClosure, "")
+}
+
+// publish queues a frame for a subscription without blocking, ending
+// subscriptions that fall behind like slow clients
+func (h *Hub) publish(s *Subscription, frame Frame) {
+	select {
+	case s.events <- frame:
+	default:
+		h.logger.Warn("Ending slow realtime subscription", "user_id", s.userID)
+		s.close(websocket.CloseTryAgainLater, "client too slow")
+	}
+}
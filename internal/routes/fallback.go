@@ -0,0 +1,79 @@
+package routes
+
+import (
+	"sort"
+	"strings"
+
+	"fowergram-backend/pkg/httperr"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// notFound answers requests no route handled. When the path exists under
+// other methods it is a 405 listing them in Allow, otherwise a 404. It must
+// be registered last, so it only sees requests everything else passed on.
+func notFound(c *fiber.Ctx) error {
+	allowed := allowedMethods(c.App(), c.Path())
+	if len(allowed) == 0 {
+		return httperr.NotFound("Route not found")
+	}
+
+	c.Set(fiber.HeaderAllow, strings.Join(allowed, ", "))
+	return httperr.New(fiber.StatusMethodNotAllowed, httperr.CodeMethodNotAllowed, "Method not allowed")
+}
+
+// allowedMethods returns the methods that have a route matching path
+func allowedMethods(app *fiber.App, path string) []string {
+	seen := make(map[string]struct{})
+	for _, route := range app.GetRoutes(true) {
+		if _, ok := seen[route.Method]; ok {
+			continue
+		}
+		if matchPath(route.Path, path) {
+			seen[route.Method] = struct{}{}
+		}
+	}
+
+	methods := make([]string, 0, len(seen))
+	for method := range seen {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// matchPath reports whether path matches a route pattern, with the default
+// non-strict, case-insensitive routing: ":name" matches one segment, ":name?"
+// one or none, and "*" or "+" the rest of the path
+func matchPath(pattern, path string) bool {
+	patternSegments := splitPath(pattern)
+	pathSegments := splitPath(path)
+
+	for i, segment := range patternSegments {
+		switch {
+		case segment == "*":
+			return true
+		case segment == "+":
+			return i < len(pathSegments)
+		case strings.HasPrefix(segment, ":") && strings.HasSuffix(segment, "?"):
+			if i >= len(pathSegments) {
+				return i == len(patternSegments)-1
+			}
+		case i >= len(pathSegments):
+			return false
+		case strings.HasPrefix(segment, ":"):
+		case !strings.EqualFold(segment, pathSegments[i]):
+			return false
+		}
+	}
+	return len(patternSegments) == len(pathSegments)
+}
+
+// splitPath splits a path into its segments, ignoring a trailing slash
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
@@ -1,40 +1,145 @@
 package routes
 
 import (
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+
 	"fowergram-backend/internal/handlers"
 	"fowergram-backend/pkg/auth"
+	"fowergram-backend/pkg/errreport"
+	"fowergram-backend/pkg/httperr"
 	"fowergram-backend/pkg/middleware"
+	"fowergram-backend/pkg/telemetry"
 
+	"github.com/gofiber/contrib/websocket"
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/compress"
 	"github.com/gofiber/fiber/v2/middleware/cors"
-	"github.com/gofiber/fiber/v2/middleware/recover"
 )
 
+// APIVersion is the current version of the REST API, served under APIPrefix
+const APIVersion = "v1"
+
+// APIPrefix is the path the current version of the REST API is served under
+const APIPrefix = "/api/" + APIVersion
+
 // Config holds dependencies for route setup
 type Config struct {
-	AuthHandler    *handlers.AuthHandler
-	HealthHandler  *handlers.HealthHandler
-	PostHandler    *handlers.PostHandler
-	AuthService    auth.AuthService
-	GQLHandler     fiber.Handler
-	MetricsHandler fiber.Handler
-	AllowedOrigins string
-	RateLimiter    *middleware.RateLimiter
+	AuthHandler            *handlers.AuthHandler
+	HealthHandler          *handlers.HealthHandler
+	JWKSHandler            *handlers.JWKSHandler // Nil unless tokens are signed with a private key
+	PostHandler            *handlers.PostHandler
+	MediaHandler           *handlers.MediaHandler
+	CommentHandler         *handlers.CommentHandler
+	UserHandler            *handlers.UserHandler
+	ExportHandler          *handlers.ExportHandler
+	TagHandler             *handlers.TagHandler
+	FeedHandler            *handlers.FeedHandler
+	NotificationHandler    *handlers.NotificationHandler
+	WebSocketHandler       *handlers.WebSocketHandler
+	ConversationHandler    *handlers.ConversationHandler
+	DeviceHandler          *handlers.DeviceHandler
+	AdminHandler           *handlers.AdminHandler
+	AuthService            auth.AuthService
+	GQLHandler             fiber.Handler
+	GQLSubscriptionHandler fiber.Handler // GraphQL over WebSocket on GET /graphql
+	MetricsHandler         fiber.Handler
+	PlaygroundHandler      fiber.Handler // Only set in development
+	AllowedOrigins         []string
+	RateLimiter            *middleware.RateLimiter
+	Idempotency            *middleware.Idempotency
+	AccessLogger           *middleware.AccessLogger
+	BodyLimit              int    // Request body limit in bytes; 0 leaves bodies to the server's limit
+	UploadBodyLimit        int    // Body limit of the upload routes
+	AdminToken             string // Accepted on admin routes in place of an admin user's session; empty disables the fallback
+
+	// TrustedProxies are the proxies whose X-Forwarded-For gives the client IP
+	TrustedProxies []netip.Prefix
+
+	// Telemetry records the count and duration of requests when set
+	Telemetry *telemetry.Telemetry
+
+	// ErrorReporter is sent recovered panics; errreport.Nop() when no
+	// error tracker is configured
+	ErrorReporter errreport.Reporter
+
+	SecurityHeaders  middleware.SecurityHeadersConfig
+	CompressionLevel compress.Level
+
+	// CookieAuth requires a CSRF token on state-changing requests carrying
+	// the token cookies
+	CookieAuth bool
+}
+
+// RateLimitPolicies are the limits of the rate limited routes, by policy name
+var RateLimitPolicies = map[string]middleware.RateLimitPolicy{
+	"signup":               {MaxRequests: 5, Window: time.Minute},
+	"signin":               {MaxRequests: 5, Window: time.Minute},
+	"verify_email":         {MaxRequests: 5, Window: time.Minute},
+	"confirm_email_change": {MaxRequests: 5, Window: time.Minute},
+	"refresh":              {MaxRequests: 30, Window: time.Minute},
+
+	// Password resets send email and guess at tokens, so they are held to a
+	// few an hour per IP, and one email per address every two minutes whether
+	// or not the address is registered
+	"request_password_reset": {MaxRequests: 3, Window: time.Hour},
+	"password_reset":         {MaxRequests: 1, Window: 2 * time.Minute},
+	"reset_password":         {MaxRequests: 5, Window: 15 * time.Minute},
+
+	// Per user
+	"change_password": {MaxRequests: 5, Window: time.Minute},
+	"change_email":    {MaxRequests: 5, Window: time.Minute},
+	"export":          {MaxRequests: 1, Window: 24 * time.Hour}, // Data exports are expensive
+	"follow":          {MaxRequests: 60, Window: time.Hour},
+	"like":            {MaxRequests: 60, Window: time.Minute},
+	"comment":         {MaxRequests: 30, Window: time.Minute},
 }
 
 // SetupRoutes configures all application routes
 func SetupRoutes(app *fiber.App, cfg Config) {
+	for name, policy := range RateLimitPolicies {
+		cfg.RateLimiter.SetPolicy(name, policy)
+	}
+
 	// Middleware
-	app.Use(recover.New())
-	app.Use(cors.New(cors.Config{
-		AllowOrigins:     cfg.AllowedOrigins,
-		AllowMethods:     "GET,POST,HEAD,PUT,DELETE,PATCH,OPTIONS",
-		AllowHeaders:     "Origin,Content-Type,Accept,Authorization,X-Requested-With",
-		AllowCredentials: true,
+	app.Use(httperr.RequestID())
+	app.Use(middleware.RealIP(cfg.TrustedProxies))
+	app.Use(middleware.Tracing())
+	if cfg.Telemetry != nil {
+		app.Use(middleware.Metrics(cfg.Telemetry))
+	}
+	if cfg.AccessLogger != nil {
+		app.Use(cfg.AccessLogger.Middleware())
+	}
+	// Inside tracing, metrics and the access log, so they record the 500 a
+	// panic ends in
+	app.Use(httperr.Recover(cfg.ErrorReporter))
+	app.Use(middleware.SecurityHeaders(cfg.SecurityHeaders))
+	app.Use(compress.New(compress.Config{
+		Level: cfg.CompressionLevel,
+		// WebSocket upgrades have no body to compress
+		Next: func(c *fiber.Ctx) bool {
+			return websocket.IsWebSocketUpgrade(c)
+		},
 	}))
+	if cfg.BodyLimit > 0 {
+		app.Use(middleware.BodyLimit(cfg.BodyLimit, uploadBodyLimits(cfg.UploadBodyLimit)))
+	}
+	app.Use("/api", middleware.RequireJSON(uploadPaths()))
+	app.Use(cors.New(corsConfig(cfg.AllowedOrigins)))
+	if cfg.CookieAuth {
+		app.Use(middleware.CSRF(auth.AccessTokenCookie, auth.RefreshTokenCookie))
+	}
 
 	// Health check endpoint
 	app.Get("/health", cfg.HealthHandler.Health)
+	app.Get("/ready", cfg.HealthHandler.Ready)
+	if cfg.JWKSHandler != nil {
+		app.Get("/.well-known/jwks.json", cfg.JWKSHandler.JWKS)
+	}
 
 	// API Documentation (Stoplight Elements) - static files
 	app.Static("/docs", "./api", fiber.Static{
@@ -42,56 +147,251 @@ func SetupRoutes(app *fiber.App, cfg Config) {
 		Browse: true,
 	})
 
+	// Real-time updates; the handler authenticates the connection itself
+	if cfg.WebSocketHandler != nil {
+		app.Get("/ws", cfg.WebSocketHandler.Upgrade, cfg.WebSocketHandler.Connect())
+	}
+
 	// Root redirect to docs
 	app.Get("/", func(c *fiber.Ctx) error {
 		return c.Redirect("/docs/")
 	})
 
-	// API routes
-	api := app.Group("/api")
+	// Retried writes are deduplicated when an idempotency store is configured
+	idempotent := func(mount string) fiber.Handler {
+		if cfg.Idempotency == nil {
+			return func(c *fiber.Ctx) error { return c.Next() }
+		}
+		return cfg.Idempotency.Middleware(mount)
+	}
+
+	// API routes, versioned under /api/v1. The unversioned /api paths clients
+	// integrated against first stay as deprecated aliases of the same routes.
+	registerAPI(app.Group(APIPrefix), cfg, idempotent(APIPrefix))
+	registerAPI(app.Group("/api", legacyAPI), cfg, idempotent("/api"))
+
+	// GraphQL endpoint
+	if cfg.GQLHandler != nil {
+		app.Post("/graphql", cfg.GQLHandler)
+	}
+	if cfg.GQLSubscriptionHandler != nil {
+		app.Get("/graphql", cfg.GQLSubscriptionHandler)
+	}
+
+	// Metrics endpoint
+	if cfg.MetricsHandler != nil {
+		app.Get("/metrics", cfg.MetricsHandler)
+	}
+
+	// GraphQL playground (development only)
+	if cfg.PlaygroundHandler != nil {
+		app.Get("/playground", cfg.PlaygroundHandler)
+	}
+
+	// JSON 404 and 405 for everything no route above handled
+	app.Use(notFound)
+}
 
+// registerAPI adds the REST API routes to api
+func registerAPI(api fiber.Router, cfg Config, idempotent fiber.Handler) {
 	// Authentication routes
 	auth := api.Group("/auth")
 
 	// Public auth routes with rate limiting
-	auth.Post("/signup", cfg.RateLimiter.Middleware(), cfg.AuthHandler.Signup)
-	auth.Post("/signin", cfg.RateLimiter.Middleware(), cfg.AuthHandler.Signin)
+	auth.Post("/signup", cfg.RateLimiter.Handle("signup", middleware.ByIP), cfg.AuthHandler.Signup)
+	auth.Post("/signin", cfg.RateLimiter.Handle("signin", middleware.ByIP), cfg.AuthHandler.Signin)
+	auth.Post("/refresh", cfg.RateLimiter.Handle("refresh", middleware.ByIP), cfg.AuthHandler.Refresh)
 	auth.Post("/signout", cfg.AuthHandler.Signout)
 
 	// Email verification routes
-	auth.Post("/verify-email", cfg.RateLimiter.Middleware(), cfg.AuthHandler.VerifyEmail)
-	auth.Post("/request-password-reset", cfg.RateLimiter.Middleware(), cfg.AuthHandler.RequestPasswordReset)
-	auth.Post("/reset-password", cfg.RateLimiter.Middleware(), cfg.AuthHandler.ResetPassword)
+	auth.Post("/verify-email", cfg.RateLimiter.Handle("verify_email", middleware.ByIP), cfg.AuthHandler.VerifyEmail)
+	auth.Post("/request-password-reset", cfg.RateLimiter.Handle("request_password_reset", middleware.ByIP), cfg.RateLimiter.Handle("password_reset", handlers.PasswordResetEmail), cfg.AuthHandler.RequestPasswordReset)
+	auth.Post("/reset-password", cfg.RateLimiter.Handle("reset_password", middleware.ByIP), cfg.AuthHandler.ResetPassword)
+	auth.Post("/confirm-email-change", cfg.RateLimiter.Handle("confirm_email_change", middleware.ByIP), cfg.AuthHandler.ConfirmEmailChange)
 
 	// Protected routes
 	protected := api.Group("/auth")
 	protected.Use(cfg.AuthService.Middleware())
 	protected.Get("/me", cfg.AuthHandler.Me)
+	protected.Post("/me/deactivate", cfg.AuthHandler.Deactivate)
+	protected.Post("/change-password", cfg.RateLimiter.Handle("change_password", middleware.ByUserID), cfg.AuthHandler.ChangePassword)
+	protected.Post("/me/email", cfg.RateLimiter.Handle("change_email", middleware.ByUserID), cfg.AuthHandler.ChangeEmail)
+	protected.Get("/sessions", cfg.AuthHandler.GetSessions)
+	protected.Delete("/sessions", cfg.AuthHandler.RevokeOtherSessions)
+	protected.Delete("/sessions/:id", cfg.AuthHandler.RevokeSession)
+	if cfg.UserHandler != nil {
+		protected.Delete("/me", cfg.UserHandler.DeleteAccount)
+	}
+	if cfg.ExportHandler != nil {
+		protected.Get("/me/export", cfg.RateLimiter.Handle("export", middleware.ByUserID), cfg.ExportHandler.Export)
+	}
+
+	// User routes (protected)
+	users := api.Group("/users")
+	users.Use(cfg.AuthService.Middleware())
+	if cfg.PostHandler != nil {
+		users.Get("/me/saved", cfg.PostHandler.GetSavedPosts)
+		users.Get("/me/drafts", cfg.PostHandler.GetDrafts)
+	}
+	if cfg.UserHandler != nil {
+		users.Put("/me", cfg.UserHandler.UpdateProfile)
+		users.Post("/me/avatar", cfg.UserHandler.UploadAvatar)
+		users.Get("/:id", cfg.UserHandler.GetProfile)
+		users.Get("/:id/followers", cfg.UserHandler.GetFollowers)
+		users.Get("/:id/following", cfg.UserHandler.GetFollowing)
+		users.Post("/:id/follow", cfg.RateLimiter.Handle("follow", middleware.ByUserID), cfg.UserHandler.FollowUser)
+		users.Delete("/:id/follow", cfg.UserHandler.UnfollowUser)
+		users.Get("/me/follow-requests", cfg.UserHandler.GetFollowRequests)
+		users.Post("/me/follow-requests/:requestId/approve", cfg.UserHandler.ApproveFollowRequest)
+		users.Post("/me/follow-requests/:requestId/reject", cfg.UserHandler.RejectFollowRequest)
+	}
 
 	// Posts routes (protected)
 	if cfg.PostHandler != nil {
 		posts := api.Group("/posts")
 		posts.Use(cfg.AuthService.Middleware())
-		posts.Post("/", cfg.PostHandler.CreatePost)
+		posts.Post("/", idempotent, cfg.PostHandler.CreatePost)
 		posts.Get("/", cfg.PostHandler.GetPosts)
+		posts.Get("/nearby", cfg.PostHandler.GetNearbyPosts)
+		posts.Get("/search", cfg.PostHandler.SearchPosts)
+		posts.Post("/batch", cfg.PostHandler.BatchGetPosts)
 		posts.Get("/:id", cfg.PostHandler.GetPost)
 		posts.Put("/:id", cfg.PostHandler.UpdatePost)
 		posts.Delete("/:id", cfg.PostHandler.DeletePost)
+		posts.Post("/:id/publish", cfg.PostHandler.PublishPost)
+		posts.Post("/:id/repost", idempotent, cfg.PostHandler.RepostPost)
+		posts.Post("/:id/like", cfg.RateLimiter.Handle("like", middleware.ByUserID), idempotent, cfg.PostHandler.LikePost)
+		posts.Delete("/:id/like", idempotent, cfg.PostHandler.UnlikePost)
+		posts.Get("/:id/likes", cfg.PostHandler.GetLikes)
+		posts.Post("/:id/save", cfg.PostHandler.SavePost)
+		posts.Delete("/:id/save", cfg.PostHandler.UnsavePost)
+
+		if cfg.CommentHandler != nil {
+			posts.Post("/:id/comments", cfg.RateLimiter.Handle("comment", middleware.ByUserID), idempotent, cfg.CommentHandler.CreateComment)
+			posts.Get("/:id/comments", cfg.CommentHandler.GetComments)
+			posts.Delete("/:id/comments/:commentId", cfg.CommentHandler.DeleteComment)
+		}
 	}
 
-	// GraphQL endpoint
-	if cfg.GQLHandler != nil {
-		app.Post("/graphql", cfg.GQLHandler)
+	// Comment routes (protected)
+	if cfg.CommentHandler != nil {
+		comments := api.Group("/comments")
+		comments.Use(cfg.AuthService.Middleware())
+		comments.Get("/:id/replies", cfg.CommentHandler.GetReplies)
+		comments.Delete("/:id", cfg.CommentHandler.DeleteComment)
 	}
 
-	// Metrics endpoint
-	if cfg.MetricsHandler != nil {
-		app.Get("/metrics", cfg.MetricsHandler)
+	// Tag routes (protected)
+	if cfg.TagHandler != nil {
+		tags := api.Group("/tags")
+		tags.Use(cfg.AuthService.Middleware())
+		tags.Get("/search", cfg.TagHandler.SearchTags)
+		tags.Get("/:tag/posts", cfg.TagHandler.GetTagPosts)
+	}
+
+	// Feed routes (protected)
+	if cfg.FeedHandler != nil {
+		feed := api.Group("/feed")
+		feed.Use(cfg.AuthService.Middleware())
+		feed.Get("/", cfg.FeedHandler.GetFeed)
+		feed.Get("/stream", cfg.FeedHandler.Stream)
+	}
+
+	// Notification routes (protected)
+	if cfg.NotificationHandler != nil {
+		notifications := api.Group("/notifications")
+		notifications.Use(cfg.AuthService.Middleware())
+		notifications.Get("/", cfg.NotificationHandler.GetNotifications)
+		notifications.Post("/read", cfg.NotificationHandler.MarkNotificationsRead)
+	}
+
+	// Direct message routes (protected)
+	if cfg.ConversationHandler != nil {
+		conversations := api.Group("/conversations")
+		conversations.Use(cfg.AuthService.Middleware())
+		conversations.Post("/", cfg.ConversationHandler.StartConversation)
+		conversations.Get("/", cfg.ConversationHandler.GetConversations)
+		conversations.Post("/:id/messages", idempotent, cfg.ConversationHandler.SendMessage)
+		conversations.Get("/:id/messages", cfg.ConversationHandler.GetMessages)
+		conversations.Post("/:id/read", cfg.ConversationHandler.MarkConversationRead)
+	}
+
+	// Push device routes (protected)
+	if cfg.DeviceHandler != nil {
+		devices := api.Group("/devices")
+		devices.Use(cfg.AuthService.Middleware())
+		devices.Post("/", cfg.DeviceHandler.RegisterDevice)
+		devices.Delete("/:token", cfg.DeviceHandler.UnregisterDevice)
+	}
+
+	// Admin routes, for users with the admin role or, when configured, the
+	// admin token
+	if cfg.AdminHandler != nil {
+		admin := api.Group("/admin")
+		admin.Use(middleware.AuthenticateAdmin(cfg.AuthService.Middleware(), cfg.AdminToken))
+		admin.Use(middleware.RequireAdmin())
+		admin.Post("/users/:id/reconcile-counts", cfg.AdminHandler.ReconcileUserCounts)
+		admin.Delete("/posts/:id", cfg.AdminHandler.HardDeletePost)
+	}
+
+	// Media routes (protected)
+	if cfg.MediaHandler != nil {
+		mediaRoutes := api.Group("/media")
+		mediaRoutes.Use(cfg.AuthService.Middleware())
+		// Not idempotent: hashing the body would buffer the streamed upload
+		mediaRoutes.Post("/", cfg.MediaHandler.Upload)
+		mediaRoutes.Post("/presign", cfg.MediaHandler.PresignUpload)
 	}
 }
 
-// SetupDevelopmentRoutes adds development-only routes
-func SetupDevelopmentRoutes(app *fiber.App, playgroundHandler fiber.Handler) {
-	// GraphQL playground (development only)
-	app.Get("/playground", playgroundHandler)
+// corsConfig allows credentialed requests from the allowed origins, which
+// may be subdomain patterns such as https://*.fowergram.com. "*", accepted in
+// development only, echoes every origin back instead, as browsers refuse a
+// literal "*" on credentialed responses.
+func corsConfig(origins []string) cors.Config {
+	config := cors.Config{
+		AllowMethods:     "GET,POST,HEAD,PUT,DELETE,PATCH,OPTIONS",
+		AllowHeaders:     "Origin,Content-Type,Accept,Authorization,X-Requested-With,Idempotency-Key,If-None-Match," + middleware.CSRFHeader,
+		ExposeHeaders:    "Deprecation,Link,ETag",
+		AllowCredentials: true,
+	}
+	if slices.Contains(origins, "*") {
+		config.AllowOriginsFunc = func(string) bool { return true }
+	} else {
+		config.AllowOrigins = strings.Join(origins, ",")
+	}
+	return config
+}
+
+// uploadBodyLimits returns limit for the upload routes, under /api/v1 and
+// their unversioned aliases
+func uploadBodyLimits(limit int) map[string]int {
+	limits := make(map[string]int)
+	for _, path := range uploadPaths() {
+		limits[path] = limit
+	}
+	return limits
+}
+
+// uploadPaths are the routes taking multipart uploads rather than JSON
+func uploadPaths() []string {
+	var paths []string
+	for _, prefix := range []string{APIPrefix, "/api"} {
+		paths = append(paths, prefix+"/media", prefix+"/users/me/avatar")
+	}
+	return paths
+}
+
+// legacyAPI marks requests to the unversioned /api aliases as deprecated,
+// linking to the versioned route that replaces them. Requests under APIPrefix
+// that no versioned route handled pass through unmarked.
+func legacyAPI(c *fiber.Ctx) error {
+	path := c.Path()
+	if path == APIPrefix || strings.HasPrefix(path, APIPrefix+"/") {
+		return c.Next()
+	}
+
+	c.Set("Deprecation", "true")
+	c.Set(fiber.HeaderLink, fmt.Sprintf(`<%s>; rel="successor-version"`, APIPrefix+strings.TrimPrefix(path, "/api")))
+	return c.Next()
 }
@@ -0,0 +1,358 @@
+package routes
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"fowergram-backend/internal/handlers"
+	"fowergram-backend/pkg/auth"
+	"fowergram-backend/pkg/errreport"
+	"fowergram-backend/pkg/httperr"
+	"fowergram-backend/pkg/logger"
+	"fowergram-backend/pkg/middleware"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/cors"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// fakeAuthService signs in alice with "Bearer alice-token". Other methods
+// are left to the embedded nil AuthService.
+type fakeAuthService struct {
+	auth.AuthService
+
+	alice *auth.User
+}
+
+func (s *fakeAuthService) Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if c.Get(fiber.HeaderAuthorization) != "Bearer alice-token" {
+			return httperr.Unauthenticated("Invalid token")
+		}
+		c.Locals("user", s.alice)
+		return c.Next()
+	}
+}
+
+// newTestApp sets up the routes with the auth and health handlers, and
+// whatever else configure, when not nil, adds
+func newTestApp(t *testing.T, configure func(*Config)) *fiber.App {
+	t.Helper()
+	log := logger.NewZapLogger()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	sessions := &fakeAuthService{alice: &auth.User{ID: uuid.New(), Username: "alice"}}
+	app := fiber.New(fiber.Config{
+		ErrorHandler:          httperr.Handler(log, errreport.Nop()),
+		DisableStartupMessage: true,
+	})
+	cfg := Config{
+		AuthHandler:    handlers.NewAuthHandler(sessions, nil, handlers.AuthCookieConfig{}, log),
+		HealthHandler:  handlers.NewHealthHandler("test", nil, log),
+		AuthService:    sessions,
+		AllowedOrigins: []string{"https://fowergram.example"},
+		RateLimiter: middleware.NewRateLimiter(middleware.RateLimiterConfig{
+			RedisClient: client,
+			MaxRequests: 100,
+			Window:      time.Minute,
+			KeyPrefix:   "rate_limit",
+		}),
+		ErrorReporter: errreport.Nop(),
+	}
+	if configure != nil {
+		configure(&cfg)
+	}
+	SetupRoutes(app, cfg)
+	return app
+}
+
+func TestLegacyRoutesAliasV1(t *testing.T) {
+	app := newTestApp(t, nil)
+
+	// Every versioned route has an unversioned alias ending in the same
+	// handler, and the other way round
+	handlerOf := func(route fiber.Route) uintptr {
+		return reflect.ValueOf(route.Handlers[len(route.Handlers)-1]).Pointer()
+	}
+	v1 := make(map[string]uintptr)
+	legacy := make(map[string]uintptr)
+	for _, route := range app.GetRoutes(true) {
+		switch {
+		case strings.HasPrefix(route.Path, APIPrefix+"/"):
+			v1[route.Method+" "+strings.TrimPrefix(route.Path, APIPrefix)] = handlerOf(route)
+		case strings.HasPrefix(route.Path, "/api/"):
+			legacy[route.Method+" "+strings.TrimPrefix(route.Path, "/api")] = handlerOf(route)
+		}
+	}
+	if len(v1) == 0 {
+		t.Fatal("no routes under " + APIPrefix)
+	}
+	if !reflect.DeepEqual(v1, legacy) {
+		t.Errorf("versioned routes %v, want the same as the legacy routes %v", v1, legacy)
+	}
+}
+
+func TestLegacyRoutesDeprecated(t *testing.T) {
+	app := newTestApp(t, nil)
+
+	tests := []struct {
+		name           string
+		path           string
+		token          string
+		wantStatus     int
+		wantDeprecated bool
+	}{
+		{name: "versioned", path: APIPrefix + "/auth/me", token: "alice-token", wantStatus: fiber.StatusOK},
+		{name: "legacy", path: "/api/auth/me", token: "alice-token", wantStatus: fiber.StatusOK, wantDeprecated: true},
+		{name: "versioned error", path: APIPrefix + "/auth/me", wantStatus: fiber.StatusUnauthorized},
+		{name: "legacy error", path: "/api/auth/me", wantStatus: fiber.StatusUnauthorized, wantDeprecated: true},
+		{name: "outside the API", path: "/health", wantStatus: fiber.StatusOK},
+	}
+
+	bodies := make(map[int]string) // By status, the same for both paths
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.token != "" {
+				req.Header.Set(fiber.HeaderAuthorization, "Bearer "+tt.token)
+			}
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+
+			deprecation, link := resp.Header.Get("Deprecation"), resp.Header.Get(fiber.HeaderLink)
+			if !tt.wantDeprecated {
+				if deprecation != "" || link != "" {
+					t.Errorf("Deprecation = %q and Link = %q, want neither", deprecation, link)
+				}
+			} else {
+				wantLink := `<` + APIPrefix + strings.TrimPrefix(tt.path, "/api") + `>; rel="successor-version"`
+				if deprecation != "true" || link != wantLink {
+					t.Errorf("Deprecation = %q and Link = %q, want true and %s", deprecation, link, wantLink)
+				}
+			}
+
+			if !strings.HasPrefix(tt.path, "/api") || tt.wantStatus != fiber.StatusOK {
+				return
+			}
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("reading the body: %v", err)
+			}
+			if want, ok := bodies[resp.StatusCode]; ok && want != string(body) {
+				t.Errorf("body = %s, want %s as on the other path", body, want)
+			}
+			bodies[resp.StatusCode] = string(body)
+		})
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	tests := []struct {
+		name        string
+		origins     []string
+		origin      string
+		wantAllowed bool
+	}{
+		{name: "listed origin", origins: []string{"https://fowergram.example"}, origin: "https://fowergram.example", wantAllowed: true},
+		{name: "unlisted origin", origins: []string{"https://fowergram.example"}, origin: "https://evil.example"},
+		{name: "other scheme", origins: []string{"https://fowergram.example"}, origin: "http://fowergram.example"},
+		{name: "subdomain of a pattern", origins: []string{"https://*.fowergram.com"}, origin: "https://app.fowergram.com", wantAllowed: true},
+		{name: "nested subdomain of a pattern", origins: []string{"https://*.fowergram.com"}, origin: "https://eu.app.fowergram.com", wantAllowed: true},
+		{name: "domain of a pattern", origins: []string{"https://*.fowergram.com"}, origin: "https://fowergram.com"},
+		{name: "suffix of a pattern", origins: []string{"https://*.fowergram.com"}, origin: "https://evilfowergram.com"},
+		{name: "pattern with another scheme", origins: []string{"https://*.fowergram.com"}, origin: "http://app.fowergram.com"},
+		{name: "wildcard in development", origins: []string{"*"}, origin: "http://localhost:5173", wantAllowed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Use(cors.New(corsConfig(tt.origins)))
+
+			req := httptest.NewRequest(http.MethodOptions, APIPrefix+"/posts", nil)
+			req.Header.Set(fiber.HeaderOrigin, tt.origin)
+			req.Header.Set(fiber.HeaderAccessControlRequestMethod, http.MethodPost)
+			req.Header.Set(fiber.HeaderAccessControlRequestHeaders, "Authorization, Content-Type")
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			defer resp.Body.Close()
+
+			// The origin is echoed rather than "*", which browsers refuse on
+			// credentialed responses
+			allowOrigin := resp.Header.Get(fiber.HeaderAccessControlAllowOrigin)
+			if !tt.wantAllowed {
+				if allowOrigin != "" {
+					t.Errorf("Access-Control-Allow-Origin = %q, want none", allowOrigin)
+				}
+				return
+			}
+			if allowOrigin != tt.origin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", allowOrigin, tt.origin)
+			}
+			if got := resp.Header.Get(fiber.HeaderAccessControlAllowCredentials); got != "true" {
+				t.Errorf("Access-Control-Allow-Credentials = %q, want true", got)
+			}
+			if got := resp.Header.Get(fiber.HeaderAccessControlAllowMethods); !strings.Contains(got, http.MethodPost) {
+				t.Errorf("Access-Control-Allow-Methods = %q, want POST listed", got)
+			}
+		})
+	}
+}
+
+func TestResponsesCompressed(t *testing.T) {
+	// Bodies under 200 bytes, like most in the test app, are sent as they are
+	app := newTestApp(t, func(cfg *Config) {
+		cfg.MetricsHandler = func(c *fiber.Ctx) error {
+			return c.SendString(strings.Repeat("http_requests_total 1\n", 50))
+		}
+	})
+
+	for _, encoding := range []string{"gzip", ""} {
+		t.Run("Accept-Encoding "+encoding, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if encoding != "" {
+				req.Header.Set(fiber.HeaderAcceptEncoding, encoding)
+			}
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			defer resp.Body.Close()
+			if got := resp.Header.Get(fiber.HeaderContentEncoding); got != encoding {
+				t.Errorf("Content-Encoding = %q, want %q", got, encoding)
+			}
+		})
+	}
+}
+
+func TestFallback(t *testing.T) {
+	// The docs are served from ./api, relative to the working directory
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "api"), 0o755); err != nil {
+		t.Fatalf("creating the docs: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "api", "stoplight.html"), []byte("<html>docs</html>"), 0o644); err != nil {
+		t.Fatalf("creating the docs: %v", err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Getwd: %v", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("Chdir: %v", err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	app := newTestApp(t, nil)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+		wantAllow  string // Only checked on 405s
+		wantBody   string // Only checked on 200s
+	}{
+		{name: "unknown path", method: http.MethodGet, path: APIPrefix + "/nope", wantStatus: fiber.StatusNotFound},
+		{name: "unknown path outside the API", method: http.MethodPost, path: "/nope/deeper", wantStatus: fiber.StatusNotFound},
+		{name: "wrong method", method: http.MethodPost, path: APIPrefix + "/auth/me", wantStatus: fiber.StatusMethodNotAllowed, wantAllow: "GET, HEAD"},
+		{name: "wrong method with a trailing slash", method: http.MethodPut, path: APIPrefix + "/auth/sessions/", wantStatus: fiber.StatusMethodNotAllowed, wantAllow: "DELETE, GET, HEAD"},
+		{name: "wrong method on a param route", method: http.MethodGet, path: APIPrefix + "/auth/sessions/" + uuid.NewString(), wantStatus: fiber.StatusMethodNotAllowed, wantAllow: "DELETE"},
+		{name: "param route with another case", method: http.MethodPatch, path: "/API/V1/Auth/Sessions/abc", wantStatus: fiber.StatusMethodNotAllowed, wantAllow: "DELETE"},
+		{name: "segment past a param", method: http.MethodGet, path: APIPrefix + "/auth/sessions/abc/extra", wantStatus: fiber.StatusNotFound},
+		{name: "docs", method: http.MethodGet, path: "/docs/", wantStatus: fiber.StatusOK, wantBody: "<html>docs</html>"},
+		{name: "missing docs file", method: http.MethodGet, path: "/docs/missing.html", wantStatus: fiber.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set(fiber.HeaderAuthorization, "Bearer alice-token")
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+
+			if tt.wantStatus == fiber.StatusOK {
+				body, err := io.ReadAll(resp.Body)
+				if err != nil {
+					t.Fatalf("reading the body: %v", err)
+				}
+				if string(body) != tt.wantBody {
+					t.Errorf("body = %q, want %q", body, tt.wantBody)
+				}
+				return
+			}
+
+			if got := resp.Header.Get(fiber.HeaderAllow); got != tt.wantAllow {
+				t.Errorf("Allow = %q, want %q", got, tt.wantAllow)
+			}
+			if got := resp.Header.Get(fiber.HeaderContentType); !strings.HasPrefix(got, fiber.MIMEApplicationJSON) {
+				t.Errorf("Content-Type = %q, want JSON", got)
+			}
+			var body httperr.Response
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("decoding the error: %v", err)
+			}
+			wantCode := httperr.CodeNotFound
+			if tt.wantStatus == fiber.StatusMethodNotAllowed {
+				wantCode = httperr.CodeMethodNotAllowed
+			}
+			if body.Code != wantCode || body.Error == "" {
+				t.Errorf("body = %+v, want code %s", body, wantCode)
+			}
+		})
+	}
+}
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{pattern: "/api/v1/posts/:id", path: "/api/v1/posts/42", want: true},
+		{pattern: "/api/v1/posts/:id", path: "/api/v1/posts/42/", want: true},
+		{pattern: "/api/v1/posts/:id", path: "/api/v1/posts", want: false},
+		{pattern: "/api/v1/posts/:id", path: "/api/v1/posts/42/like", want: false},
+		{pattern: "/api/v1/posts/:id/like", path: "/API/v1/Posts/42/LIKE", want: true},
+		{pattern: "/api/v1/posts/:id?", path: "/api/v1/posts", want: true},
+		{pattern: "/api/v1/posts/:id?", path: "/api/v1/posts/42", want: true},
+		{pattern: "/docs/*", path: "/docs/a/b.html", want: true},
+		{pattern: "/docs/*", path: "/docs", want: true},
+		{pattern: "/files/+", path: "/files", want: false},
+		{pattern: "/files/+", path: "/files/a/b", want: true},
+		{pattern: "/", path: "/", want: true},
+		{pattern: "/", path: "/nope", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := matchPath(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("matchPath(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
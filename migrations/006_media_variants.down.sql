@@ -0,0 +1,15 @@
+-- Rollback media variants migration
+
+-- Drop indexes
+DROP INDEX IF EXISTS idx_post_media_media_id;
+DROP INDEX IF EXISTS idx_media_status;
+DROP INDEX IF EXISTS idx_media_user_id;
+
+-- Drop columns and tables
+ALTER TABLE post_media DROP COLUMN IF EXISTS media_id;
+DROP TABLE IF EXISTS media;
+
+ALTER TABLE posts
+DROP COLUMN IF EXISTS is_private,
+DROP COLUMN IF EXISTS content,
+DROP COLUMN IF EXISTS title;
@@ -0,0 +1,34 @@
+-- Media Variants Migration
+-- This migration adds uploaded media tracking with processed image variants
+
+-- 1. Add API-facing post fields
+ALTER TABLE posts
+ADD COLUMN IF NOT EXISTS title VARCHAR(200),
+ADD COLUMN IF NOT EXISTS content TEXT,
+ADD COLUMN IF NOT EXISTS is_private BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- 2. Create media table (one row per upload, variants filled in by the processor)
+CREATE TABLE IF NOT EXISTS media (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    source_key TEXT NOT NULL, -- raw upload, removed once processed
+    original_key TEXT NOT NULL UNIQUE, -- EXIF-stripped full resolution
+    feed_key TEXT, -- 1080px
+    thumbnail_key TEXT, -- 256px
+    content_type VARCHAR(100) NOT NULL,
+    width INTEGER,
+    height INTEGER,
+    file_size BIGINT,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'ready', 'failed')),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    processed_at TIMESTAMP WITH TIME ZONE
+);
+
+-- 3. Link post media to processed uploads
+ALTER TABLE post_media
+ADD COLUMN IF NOT EXISTS media_id UUID REFERENCES media(id) ON DELETE SET NULL;
+
+-- 4. Indexes
+CREATE INDEX IF NOT EXISTS idx_media_user_id ON media(user_id);
+CREATE INDEX IF NOT EXISTS idx_media_status ON media(status) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_post_media_media_id ON post_media(media_id) WHERE media_id IS NOT NULL;
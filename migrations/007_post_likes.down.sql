@@ -0,0 +1,8 @@
+-- Rollback post likes migration
+
+DROP INDEX IF EXISTS idx_post_likes_user_id;
+DROP INDEX IF EXISTS idx_post_likes_post_created;
+
+DROP TABLE IF EXISTS post_likes;
+
+ALTER TABLE posts DROP COLUMN IF EXISTS likes_count;
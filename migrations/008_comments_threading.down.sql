@@ -0,0 +1,6 @@
+-- Rollback comments threading migration
+
+DROP INDEX IF EXISTS idx_comments_parent_created;
+DROP INDEX IF EXISTS idx_comments_post_top_level;
+
+ALTER TABLE posts DROP COLUMN IF EXISTS comments_count;
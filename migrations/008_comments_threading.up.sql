@@ -0,0 +1,24 @@
+-- Comments Threading Migration
+-- This migration adds a denormalized comment counter on posts and
+-- indexes for oldest-first cursor pagination of comments and replies
+
+-- 1. Add comments counter to posts
+ALTER TABLE posts
+ADD COLUMN IF NOT EXISTS comments_count INTEGER NOT NULL DEFAULT 0;
+
+-- 2. Backfill counter from existing comments
+UPDATE posts p
+SET comments_count = c.total
+FROM (
+    SELECT post_id, COUNT(*) AS total
+    FROM comments
+    WHERE deleted_at IS NULL
+    GROUP BY post_id
+) c
+WHERE c.post_id = p.id;
+
+-- 3. Indexes for top-level listing and reply listing
+CREATE INDEX IF NOT EXISTS idx_comments_post_top_level ON comments(post_id, created_at, id)
+    WHERE deleted_at IS NULL AND parent_id IS NULL;
+CREATE INDEX IF NOT EXISTS idx_comments_parent_created ON comments(parent_id, created_at, id)
+    WHERE deleted_at IS NULL AND parent_id IS NOT NULL;
@@ -0,0 +1,5 @@
+-- Rollback account deactivation migration
+
+DROP INDEX IF EXISTS idx_users_deactivated_at;
+
+ALTER TABLE users DROP COLUMN IF EXISTS deactivated_at;
@@ -0,0 +1,16 @@
+-- Account Deactivation Migration
+-- This migration records when an account was deactivated so it can be
+-- reactivated by signing in within the grace period
+
+-- 1. Add deactivation timestamp to users
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS deactivated_at TIMESTAMP WITH TIME ZONE;
+
+-- 2. Backfill already deactivated accounts
+UPDATE users
+SET deactivated_at = updated_at
+WHERE is_active = false AND deactivated_at IS NULL;
+
+-- 3. Index for finding accounts past the grace period
+CREATE INDEX IF NOT EXISTS idx_users_deactivated_at ON users(deactivated_at)
+    WHERE deactivated_at IS NOT NULL;
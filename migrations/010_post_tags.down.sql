@@ -0,0 +1,6 @@
+-- Rollback post tags migration
+
+DROP INDEX IF EXISTS idx_hashtags_name_prefix;
+DROP INDEX IF EXISTS idx_post_tags_tag_created;
+
+DROP TABLE IF EXISTS post_tags;
@@ -0,0 +1,17 @@
+-- Post Tags Migration
+-- This migration stores normalized hashtags per post and reuses the
+-- hashtags table as the denormalized tag counter for autocomplete
+
+-- 1. Create post_tags table keyed by normalized tag text
+CREATE TABLE IF NOT EXISTS post_tags (
+    post_id UUID NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    tag VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (post_id, tag)
+);
+
+-- 2. Indexes
+CREATE INDEX IF NOT EXISTS idx_post_tags_tag_created ON post_tags(tag, created_at DESC, post_id);
+
+-- Prefix index for tag autocomplete
+CREATE INDEX IF NOT EXISTS idx_hashtags_name_prefix ON hashtags(name text_pattern_ops);
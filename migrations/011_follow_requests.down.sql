@@ -0,0 +1,5 @@
+-- Rollback follow requests migration
+
+DROP INDEX IF EXISTS idx_follow_requests_target_created;
+
+DROP TABLE IF EXISTS follow_requests;
@@ -0,0 +1,15 @@
+-- Follow Requests Migration
+-- This migration adds pending follow requests for private accounts
+
+-- 1. Create follow_requests table
+CREATE TABLE IF NOT EXISTS follow_requests (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    requester_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    target_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT check_no_self_follow_request CHECK (requester_id != target_id),
+    CONSTRAINT unique_follow_request UNIQUE (requester_id, target_id)
+);
+
+-- 2. Indexes
+CREATE INDEX IF NOT EXISTS idx_follow_requests_target_created ON follow_requests(target_id, created_at DESC, id);
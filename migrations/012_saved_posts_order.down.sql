@@ -0,0 +1,3 @@
+-- Rollback saved posts order migration
+
+DROP INDEX IF EXISTS idx_saved_posts_user_created;
@@ -0,0 +1,5 @@
+-- Saved Posts Order Migration
+-- This migration indexes saved posts for newest-first listing per user
+
+-- 1. Indexes
+CREATE INDEX IF NOT EXISTS idx_saved_posts_user_created ON saved_posts(user_id, created_at DESC, post_id DESC);
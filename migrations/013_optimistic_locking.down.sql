@@ -0,0 +1,5 @@
+-- Rollback optimistic locking migration
+
+ALTER TABLE posts DROP COLUMN IF EXISTS version;
+
+ALTER TABLE users DROP COLUMN IF EXISTS version;
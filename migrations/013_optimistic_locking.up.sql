@@ -0,0 +1,10 @@
+-- Optimistic Locking Migration
+-- This migration adds row versions so concurrent edits can be detected
+
+-- 1. Add version to users
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+
+-- 2. Add version to posts
+ALTER TABLE posts
+ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
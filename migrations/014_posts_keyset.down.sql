@@ -0,0 +1,3 @@
+-- Rollback posts keyset pagination migration
+
+DROP INDEX IF EXISTS idx_posts_created_id;
@@ -0,0 +1,5 @@
+-- Posts Keyset Pagination Migration
+-- This migration indexes posts for newest-first cursor pagination
+
+-- 1. Indexes
+CREATE INDEX IF NOT EXISTS idx_posts_created_id ON posts(created_at DESC, id DESC) WHERE deleted_at IS NULL;
@@ -0,0 +1,3 @@
+-- Rollback post views migration
+
+ALTER TABLE posts DROP COLUMN IF EXISTS views_count;
@@ -0,0 +1,5 @@
+-- Post Views Migration
+-- This migration adds a view counter to posts, flushed in batches from Redis
+
+-- 1. Columns
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS views_count BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,11 @@
+-- Rollback post drafts migration
+
+DROP INDEX IF EXISTS idx_posts_unpublished;
+DROP INDEX IF EXISTS idx_posts_scheduled;
+
+ALTER TABLE posts DROP CONSTRAINT IF EXISTS posts_scheduled_at_check;
+ALTER TABLE posts DROP CONSTRAINT IF EXISTS posts_status_check;
+
+ALTER TABLE posts
+DROP COLUMN IF EXISTS scheduled_at,
+DROP COLUMN IF EXISTS status;
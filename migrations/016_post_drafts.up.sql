@@ -0,0 +1,20 @@
+-- Post Drafts Migration
+-- This migration adds draft and scheduled post states; only published posts appear to other users
+
+-- 1. Columns
+ALTER TABLE posts
+ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'published',
+ADD COLUMN IF NOT EXISTS scheduled_at TIMESTAMP WITH TIME ZONE;
+
+-- 2. Constraints
+ALTER TABLE posts DROP CONSTRAINT IF EXISTS posts_status_check;
+ALTER TABLE posts ADD CONSTRAINT posts_status_check
+    CHECK (status IN ('draft', 'scheduled', 'published'));
+
+ALTER TABLE posts DROP CONSTRAINT IF EXISTS posts_scheduled_at_check;
+ALTER TABLE posts ADD CONSTRAINT posts_scheduled_at_check
+    CHECK (status <> 'scheduled' OR scheduled_at IS NOT NULL);
+
+-- 3. Indexes
+CREATE INDEX IF NOT EXISTS idx_posts_scheduled ON posts(scheduled_at) WHERE status = 'scheduled' AND deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_posts_unpublished ON posts(user_id, updated_at DESC) WHERE status <> 'published' AND deleted_at IS NULL;
@@ -0,0 +1,8 @@
+-- Rollback reposts migration
+
+DROP INDEX IF EXISTS idx_posts_original_post_id;
+DROP INDEX IF EXISTS idx_posts_repost_unique;
+
+ALTER TABLE posts
+DROP COLUMN IF EXISTS reposts_count,
+DROP COLUMN IF EXISTS original_post_id;
@@ -0,0 +1,15 @@
+-- Reposts Migration
+-- This migration lets a post reference another post as a repost
+
+-- 1. Columns
+-- original_post_id has no foreign key: reposts outlive a hard-deleted original
+-- and render it as unavailable
+ALTER TABLE posts
+ADD COLUMN IF NOT EXISTS original_post_id UUID,
+ADD COLUMN IF NOT EXISTS reposts_count INTEGER NOT NULL DEFAULT 0;
+
+-- 2. Indexes
+CREATE UNIQUE INDEX IF NOT EXISTS idx_posts_repost_unique ON posts(user_id, original_post_id)
+    WHERE original_post_id IS NOT NULL AND deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_posts_original_post_id ON posts(original_post_id)
+    WHERE original_post_id IS NOT NULL;
@@ -0,0 +1,3 @@
+-- Rollback notifications listing migration
+
+DROP INDEX IF EXISTS idx_notifications_user_created;
@@ -0,0 +1,5 @@
+-- Notifications Listing Migration
+-- This migration indexes notifications for newest-first listing per recipient
+
+-- 1. Indexes
+CREATE INDEX IF NOT EXISTS idx_notifications_user_created ON notifications(user_id, created_at DESC, id DESC);
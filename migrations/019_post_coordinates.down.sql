@@ -0,0 +1,9 @@
+-- Rollback post coordinates migration
+
+DROP INDEX IF EXISTS idx_posts_earth;
+
+ALTER TABLE posts DROP CONSTRAINT IF EXISTS posts_coordinates_check;
+
+ALTER TABLE posts
+DROP COLUMN IF EXISTS longitude,
+DROP COLUMN IF EXISTS latitude;
@@ -0,0 +1,23 @@
+-- Post Coordinates Migration
+-- This migration adds optional coordinates to posts for nearby search;
+-- the free-text location stays as the display name
+
+-- 1. Extensions
+CREATE EXTENSION IF NOT EXISTS cube;
+CREATE EXTENSION IF NOT EXISTS earthdistance;
+
+-- 2. Columns
+ALTER TABLE posts
+ADD COLUMN IF NOT EXISTS latitude DOUBLE PRECISION,
+ADD COLUMN IF NOT EXISTS longitude DOUBLE PRECISION;
+
+-- 3. Constraints
+ALTER TABLE posts DROP CONSTRAINT IF EXISTS posts_coordinates_check;
+ALTER TABLE posts ADD CONSTRAINT posts_coordinates_check CHECK (
+    (latitude IS NULL AND longitude IS NULL) OR
+    (latitude BETWEEN -90 AND 90 AND longitude BETWEEN -180 AND 180)
+);
+
+-- 4. Indexes
+CREATE INDEX IF NOT EXISTS idx_posts_earth ON posts USING gist (ll_to_earth(latitude, longitude))
+    WHERE latitude IS NOT NULL AND deleted_at IS NULL;
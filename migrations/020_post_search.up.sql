@@ -0,0 +1,32 @@
+-- Post Search Migration
+-- This migration adds a full-text search vector to posts, kept up to date by
+-- a trigger. Each post records the text search configuration it was indexed
+-- with so the language can change without reindexing older posts.
+
+-- 1. Columns
+ALTER TABLE posts
+ADD COLUMN IF NOT EXISTS search_language REGCONFIG NOT NULL DEFAULT 'simple',
+ADD COLUMN IF NOT EXISTS search_vector TSVECTOR;
+
+-- 2. Trigger
+CREATE OR REPLACE FUNCTION posts_search_vector_update() RETURNS trigger AS $$
+BEGIN
+    NEW.search_vector :=
+        setweight(to_tsvector(NEW.search_language, coalesce(NEW.title, '')), 'A') ||
+        setweight(to_tsvector(NEW.search_language, coalesce(NEW.caption, '')), 'B') ||
+        setweight(to_tsvector(NEW.search_language, coalesce(NEW.content, '')), 'C');
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS posts_search_vector_trigger ON posts;
+CREATE TRIGGER posts_search_vector_trigger
+    BEFORE INSERT OR UPDATE OF title, content, caption, search_language ON posts
+    FOR EACH ROW EXECUTE FUNCTION posts_search_vector_update();
+
+-- 3. Backfill
+UPDATE posts SET search_language = search_language WHERE search_vector IS NULL;
+
+-- 4. Indexes
+CREATE INDEX IF NOT EXISTS idx_posts_search_vector ON posts USING gin (search_vector)
+    WHERE deleted_at IS NULL;
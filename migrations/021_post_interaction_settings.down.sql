@@ -0,0 +1,5 @@
+-- Rollback post interaction settings migration
+-- comments_disabled predates this migration and is kept
+
+ALTER TABLE posts
+DROP COLUMN IF EXISTS hide_like_count;
@@ -0,0 +1,8 @@
+-- Post Interaction Settings Migration
+-- This migration adds the per-post setting that hides the like count from
+-- everyone but the author; comments_disabled already exists on posts
+
+-- 1. Columns
+ALTER TABLE posts
+ADD COLUMN IF NOT EXISTS comments_disabled BOOLEAN NOT NULL DEFAULT FALSE,
+ADD COLUMN IF NOT EXISTS hide_like_count BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,7 @@
+-- Rollback media direct uploads migration
+
+UPDATE media SET status = 'failed' WHERE status = 'awaiting_upload';
+
+ALTER TABLE media DROP CONSTRAINT IF EXISTS media_status_check;
+ALTER TABLE media ADD CONSTRAINT media_status_check
+    CHECK (status IN ('pending', 'ready', 'failed'));
@@ -0,0 +1,8 @@
+-- Media Direct Uploads Migration
+-- This migration lets media rows be reserved before their file exists, for
+-- clients that upload straight to storage with a presigned URL
+
+-- 1. Status
+ALTER TABLE media DROP CONSTRAINT IF EXISTS media_status_check;
+ALTER TABLE media ADD CONSTRAINT media_status_check
+    CHECK (status IN ('awaiting_upload', 'pending', 'ready', 'failed'));
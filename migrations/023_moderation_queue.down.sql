@@ -0,0 +1,6 @@
+-- Rollback moderation queue migration
+
+DROP INDEX IF EXISTS idx_moderation_queue_pending;
+DROP INDEX IF EXISTS idx_moderation_queue_pending_content;
+
+DROP TABLE IF EXISTS moderation_queue;
@@ -0,0 +1,20 @@
+-- Moderation Queue Migration
+-- This migration adds the queue of flagged posts and comments awaiting review
+
+-- 1. Table
+CREATE TABLE IF NOT EXISTS moderation_queue (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    content_type VARCHAR(20) NOT NULL CHECK (content_type IN ('post', 'comment')),
+    content_id UUID NOT NULL,
+    author_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    reason TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'removed')),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    reviewed_at TIMESTAMP WITH TIME ZONE
+);
+
+-- 2. Indexes
+CREATE UNIQUE INDEX IF NOT EXISTS idx_moderation_queue_pending_content
+    ON moderation_queue(content_type, content_id) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_moderation_queue_pending
+    ON moderation_queue(created_at) WHERE status = 'pending';
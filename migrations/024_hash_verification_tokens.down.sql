@@ -0,0 +1,6 @@
+-- Rollback hash verification tokens migration
+
+-- Hashes can't be turned back into tokens. Outstanding ones are revoked so
+-- users request a new link from the previous release.
+UPDATE email_verifications SET used_at = NOW() WHERE used_at IS NULL;
+UPDATE password_resets SET used_at = NOW() WHERE used_at IS NULL;
@@ -0,0 +1,13 @@
+-- Hash Verification Tokens Migration
+-- This migration replaces stored email verification and password reset tokens
+-- with their SHA-256 hex digests; the application now stores and looks up hashes
+
+-- 1. Email verification tokens
+UPDATE email_verifications
+SET token = encode(sha256(convert_to(token, 'UTF8')), 'hex')
+WHERE length(token) <> 64 OR token !~ '^[0-9a-f]+$';
+
+-- 2. Password reset tokens
+UPDATE password_resets
+SET token = encode(sha256(convert_to(token, 'UTF8')), 'hex')
+WHERE length(token) <> 64 OR token !~ '^[0-9a-f]+$';
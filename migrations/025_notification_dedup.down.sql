@@ -0,0 +1,7 @@
+-- Rollback notification de-duplication migration
+
+DROP TABLE IF EXISTS mutes;
+
+DROP INDEX IF EXISTS idx_notifications_dedup;
+
+ALTER TABLE notifications DROP COLUMN IF EXISTS read_at;
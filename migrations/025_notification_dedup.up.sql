@@ -0,0 +1,30 @@
+-- Notification De-duplication Migration
+-- This migration records when notifications were read, collapses repeated
+-- like and follow notifications from the same actor and adds muting
+
+-- 1. Read timestamps
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS read_at TIMESTAMP WITH TIME ZONE;
+UPDATE notifications SET read_at = created_at WHERE is_read = true AND read_at IS NULL;
+
+-- 2. Collapse duplicates, keeping the earliest notification of each
+DELETE FROM notifications n
+USING notifications earlier
+WHERE n.type IN ('like', 'follow')
+    AND earlier.type = n.type
+    AND earlier.user_id = n.user_id
+    AND earlier.actor_id = n.actor_id
+    AND earlier.entity_id = n.entity_id
+    AND (earlier.created_at, earlier.id) < (n.created_at, n.id);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_notifications_dedup
+    ON notifications(user_id, actor_id, type, entity_id) WHERE type IN ('like', 'follow');
+
+-- 3. Mutes: a muted account's actions don't notify the muter
+CREATE TABLE IF NOT EXISTS mutes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    muter_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    muted_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    UNIQUE(muter_id, muted_id),
+    CHECK (muter_id != muted_id)
+);
@@ -0,0 +1,5 @@
+-- Rollback login history migration
+
+DROP INDEX IF EXISTS idx_login_history_user_created;
+
+DROP TABLE IF EXISTS login_history;
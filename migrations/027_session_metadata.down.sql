@@ -0,0 +1,6 @@
+-- Rollback session metadata migration
+
+DROP INDEX IF EXISTS idx_refresh_tokens_user_active;
+
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS user_agent;
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS ip_address;
@@ -0,0 +1,11 @@
+-- Session Metadata Migration
+-- This migration records the client each refresh token was issued to, so
+-- users can review and revoke their sessions
+
+-- 1. Columns
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS ip_address VARCHAR(45);
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS user_agent TEXT;
+
+-- 2. Indexes
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_active
+    ON refresh_tokens(user_id, created_at DESC) WHERE revoked_at IS NULL;
@@ -0,0 +1,8 @@
+-- Rollback direct messages migration
+
+DROP INDEX IF EXISTS idx_conversation_participants_user_id;
+
+ALTER TABLE conversation_participants DROP COLUMN IF EXISTS last_read_at;
+
+DROP INDEX IF EXISTS idx_conversations_direct_key;
+ALTER TABLE conversations DROP COLUMN IF EXISTS direct_key;
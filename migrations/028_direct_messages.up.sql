@@ -0,0 +1,15 @@
+-- Direct Messages Migration
+-- This migration keeps a single conversation per pair of users and tracks
+-- how far each participant has read
+
+-- 1. One direct conversation per pair of users, keyed by both user IDs in order
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS direct_key VARCHAR(73);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_conversations_direct_key
+    ON conversations(direct_key) WHERE direct_key IS NOT NULL;
+
+-- 2. Read receipts
+ALTER TABLE conversation_participants ADD COLUMN IF NOT EXISTS last_read_at TIMESTAMP WITH TIME ZONE;
+
+-- 3. Indexes
+CREATE INDEX IF NOT EXISTS idx_conversation_participants_user_id ON conversation_participants(user_id);
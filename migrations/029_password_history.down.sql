@@ -0,0 +1,3 @@
+-- Rollback password history migration
+
+DROP TABLE IF EXISTS password_history;
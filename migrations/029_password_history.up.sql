@@ -0,0 +1,15 @@
+-- Password History Migration
+-- This migration keeps users' previous password hashes so recently used
+-- passwords can't be set again
+
+-- 1. Password history
+CREATE TABLE IF NOT EXISTS password_history (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    hashed_password VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- 2. Indexes
+CREATE INDEX IF NOT EXISTS idx_password_history_user_created
+    ON password_history(user_id, created_at DESC);
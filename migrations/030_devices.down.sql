@@ -0,0 +1,3 @@
+-- Rollback devices migration
+
+DROP TABLE IF EXISTS devices;
@@ -0,0 +1,3 @@
+-- Rollback email changes migration
+
+DROP TABLE IF EXISTS email_changes;
@@ -0,0 +1,17 @@
+-- Email Changes Migration
+-- This migration stores pending email changes until the new address is
+-- confirmed through the link sent to it
+
+-- 1. Email changes
+CREATE TABLE IF NOT EXISTS email_changes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    new_email VARCHAR(255) NOT NULL,
+    token VARCHAR(255) NOT NULL UNIQUE,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- 2. Indexes
+CREATE INDEX IF NOT EXISTS idx_email_changes_user_id ON email_changes(user_id);
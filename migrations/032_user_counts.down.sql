@@ -0,0 +1,21 @@
+-- Rollback user counts migration
+
+DROP TRIGGER IF EXISTS trigger_update_user_posts_count_on_change ON posts;
+DROP TRIGGER IF EXISTS trigger_update_user_posts_count ON posts;
+DROP FUNCTION IF EXISTS update_user_posts_count();
+
+CREATE OR REPLACE FUNCTION update_follower_counts()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        UPDATE users SET following_count = following_count + 1 WHERE id = NEW.follower_id;
+        UPDATE users SET followers_count = followers_count + 1 WHERE id = NEW.following_id;
+        RETURN NEW;
+    ELSIF TG_OP = 'DELETE' THEN
+        UPDATE users SET following_count = following_count - 1 WHERE id = OLD.follower_id;
+        UPDATE users SET followers_count = followers_count - 1 WHERE id = OLD.following_id;
+        RETURN OLD;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
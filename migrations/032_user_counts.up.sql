@@ -0,0 +1,57 @@
+-- User Counts Migration
+-- This migration keeps users.posts_count in step with published posts, stops
+-- follower counts from going negative and recomputes every count once
+
+-- 1. Follower counts
+CREATE OR REPLACE FUNCTION update_follower_counts()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        UPDATE users SET following_count = following_count + 1 WHERE id = NEW.follower_id;
+        UPDATE users SET followers_count = followers_count + 1 WHERE id = NEW.following_id;
+        RETURN NEW;
+    ELSIF TG_OP = 'DELETE' THEN
+        UPDATE users SET following_count = GREATEST(following_count - 1, 0) WHERE id = OLD.follower_id;
+        UPDATE users SET followers_count = GREATEST(followers_count - 1, 0) WHERE id = OLD.following_id;
+        RETURN OLD;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- 2. Posts count: published posts that aren't deleted
+CREATE OR REPLACE FUNCTION update_user_posts_count()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') AND OLD.status = 'published' AND OLD.deleted_at IS NULL THEN
+        UPDATE users SET posts_count = GREATEST(posts_count - 1, 0) WHERE id = OLD.user_id;
+    END IF;
+    IF TG_OP IN ('INSERT', 'UPDATE') AND NEW.status = 'published' AND NEW.deleted_at IS NULL THEN
+        UPDATE users SET posts_count = posts_count + 1 WHERE id = NEW.user_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trigger_update_user_posts_count ON posts;
+CREATE TRIGGER trigger_update_user_posts_count
+    AFTER INSERT OR DELETE ON posts
+    FOR EACH ROW EXECUTE FUNCTION update_user_posts_count();
+
+DROP TRIGGER IF EXISTS trigger_update_user_posts_count_on_change ON posts;
+CREATE TRIGGER trigger_update_user_posts_count_on_change
+    AFTER UPDATE OF status, deleted_at, user_id ON posts
+    FOR EACH ROW
+    WHEN (OLD.status IS DISTINCT FROM NEW.status
+       OR OLD.deleted_at IS DISTINCT FROM NEW.deleted_at
+       OR OLD.user_id IS DISTINCT FROM NEW.user_id)
+    EXECUTE FUNCTION update_user_posts_count();
+
+-- 3. Recompute counts that drifted before the triggers existed
+UPDATE users u SET
+    followers_count = (SELECT COUNT(*) FROM followers f WHERE f.following_id = u.id),
+    following_count = (SELECT COUNT(*) FROM followers f WHERE f.follower_id = u.id),
+    posts_count = (
+        SELECT COUNT(*) FROM posts p
+        WHERE p.user_id = u.id AND p.status = 'published' AND p.deleted_at IS NULL
+    );
@@ -0,0 +1,3 @@
+-- Rollback user roles migration
+
+ALTER TABLE users DROP COLUMN IF EXISTS roles;
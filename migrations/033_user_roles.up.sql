@@ -0,0 +1,8 @@
+-- User Roles Migration
+-- This migration gives users roles, such as admin, that grant permissions
+-- beyond their own content. Grant one with:
+--   UPDATE users SET roles = array_append(roles, 'admin') WHERE username = '...';
+
+-- 1. Columns
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS roles TEXT[] NOT NULL DEFAULT '{}';
@@ -0,0 +1,3 @@
+-- Rollback mentions migration
+
+DROP TABLE IF EXISTS mentions;
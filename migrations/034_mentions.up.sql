@@ -0,0 +1,17 @@
+-- Mentions Migration
+-- This migration stores the users @-mentioned in post captions and comments
+
+-- 1. Mentions
+CREATE TABLE IF NOT EXISTS mentions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    post_id UUID NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    comment_id UUID REFERENCES comments(id) ON DELETE CASCADE, -- Set for mentions in comments
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- 2. Indexes
+-- A user is mentioned at most once per caption and once per comment
+CREATE UNIQUE INDEX IF NOT EXISTS idx_mentions_post_user ON mentions(post_id, user_id) WHERE comment_id IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_mentions_comment_user ON mentions(comment_id, user_id) WHERE comment_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_mentions_user_id ON mentions(user_id, created_at DESC);
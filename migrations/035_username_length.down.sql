@@ -0,0 +1,4 @@
+-- Rollback username length migration
+-- Fails while any username is longer than 30 characters
+
+ALTER TABLE users ALTER COLUMN username TYPE VARCHAR(30);
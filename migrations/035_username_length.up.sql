@@ -0,0 +1,5 @@
+-- Username Length Migration
+-- Usernames may be as long as signup allows, which is also the longest
+-- @-mention recognised
+
+ALTER TABLE users ALTER COLUMN username TYPE VARCHAR(50);
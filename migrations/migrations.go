@@ -0,0 +1,11 @@
+// Package migrations embeds the SQL migrations, so the server binary can
+// apply them without the files at hand
+package migrations
+
+import "embed"
+
+// FS holds the migration files, named as golang-migrate reads them:
+// NNN_name.up.sql applies migration NNN and NNN_name.down.sql rolls it back
+//
+//go:embed *.sql
+var FS embed.FS
@@ -2,6 +2,7 @@ package auth
 
 import (
 	"context"
+	"slices"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -26,10 +27,25 @@ type User struct {
 	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
 	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
 	LastLoginAt    *time.Time `json:"last_login_at,omitempty" db:"last_login_at"`
-	Roles          []string   `json:"roles,omitempty"`
+	DeactivatedAt  *time.Time `json:"deactivated_at,omitempty" db:"deactivated_at"`
+	Version        int        `json:"version" db:"version"` // Incremented on every profile update
+	Roles          []string   `json:"roles,omitempty"`      // Such as RoleAdmin
+
+	// SessionID is the session the request was authenticated with, set by
+	// ValidateSession. It is uuid.Nil for tokens issued before sessions had IDs.
+	SessionID uuid.UUID `json:"-"`
+}
+
+// RoleAdmin lets a user moderate content they don't own
+const RoleAdmin = "admin"
+
+// HasRole reports whether the user has been granted role
+func (u *User) HasRole(role string) bool {
+	return slices.Contains(u.Roles, role)
 }
 
-// RefreshToken represents a refresh token in the database
+// RefreshToken represents a refresh token in the database. Each one is a
+// session, identified by its ID.
 type RefreshToken struct {
 	ID        uuid.UUID  `json:"id" db:"id"`
 	UserID    uuid.UUID  `json:"user_id" db:"user_id"`
@@ -37,6 +53,8 @@ type RefreshToken struct {
 	ExpiresAt time.Time  `json:"expires_at" db:"expires_at"`
 	CreatedAt time.Time  `json:"created_at" db:"created_at"`
 	RevokedAt *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	IPAddress string     `json:"ip_address,omitempty" db:"ip_address"` // Client that signed in
+	UserAgent string     `json:"user_agent,omitempty" db:"user_agent"`
 }
 
 // AuthService defines the interface for authentication services
@@ -50,8 +68,10 @@ type AuthService interface {
 	// CreateUser creates a new user account
 	CreateUser(ctx context.Context, email, password, username string) (*User, error)
 
-	// SignIn authenticates a user with email and password
-	SignIn(ctx context.Context, email, password string) (*User, string, error)
+	// SignIn authenticates a user with email and password, starting a
+	// session. Successful sign-ins are recorded in the login history with
+	// client.
+	SignIn(ctx context.Context, email, password string, client ClientInfo) (*User, *Tokens, error)
 
 	// SignOut logs out a user
 	SignOut(ctx context.Context, sessionHandle string) error
@@ -62,7 +82,12 @@ type AuthService interface {
 	// ValidateSession validates a session token
 	ValidateSession(ctx context.Context, accessToken string) (*User, error)
 
-	// DeleteUser removes a user account
+	// RevokeAccessToken rejects an access token from now on, before it
+	// expires, and ends the session it belongs to
+	RevokeAccessToken(ctx context.Context, accessToken string) error
+
+	// DeleteUser deactivates a user account; signing in within the
+	// reactivation grace period restores it
 	DeleteUser(ctx context.Context, userID uuid.UUID) error
 
 	// UpdateUserMetadata updates user metadata
@@ -76,10 +101,38 @@ type AuthService interface {
 	RequestPasswordReset(ctx context.Context, email string) error
 	ResetPassword(ctx context.Context, token, newPassword string) error
 
+	// ChangePassword replaces a signed-in user's password after checking
+	// their current one
+	ChangePassword(ctx context.Context, userID uuid.UUID, currentPassword, newPassword string) error
+
+	// Email change: the new address only replaces the current one once the
+	// link sent to it is followed
+	RequestEmailChange(ctx context.Context, userID uuid.UUID, password, newEmail string) error
+	ConfirmEmailChange(ctx context.Context, token string) error
+
+	// Sessions
+	ListSessions(ctx context.Context, userID uuid.UUID) ([]*RefreshToken, error)
+	RevokeSession(ctx context.Context, userID, sessionID uuid.UUID) error
+	// RevokeOtherSessions revokes every session of the user but currentID
+	RevokeOtherSessions(ctx context.Context, userID, currentID uuid.UUID) error
+
 	// Close closes any resources used by the auth service
 	Close() error
 }
 
+// Tokens are the tokens a sign-in issues: the access token authenticating
+// requests, and the refresh token getting new access tokens for the session
+type Tokens struct {
+	AccessToken  string
+	RefreshToken string
+}
+
+// ClientInfo identifies the client a sign-in came from, for auditing
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+}
+
 // UserRepository defines the interface for user data operations
 type UserRepository interface {
 	CreateUser(ctx context.Context, user *User) error
@@ -87,11 +140,21 @@ type UserRepository interface {
 	GetUserByUsername(ctx context.Context, username string) (*User, error)
 	GetUserByID(ctx context.Context, id uuid.UUID) (*User, error)
 	UpdateUser(ctx context.Context, user *User) error
-	UpdatePassword(ctx context.Context, userID uuid.UUID, hashedPassword string) error
-	UpdateLastLogin(ctx context.Context, userID uuid.UUID) error
-	StoreRefreshToken(ctx context.Context, userID uuid.UUID, tokenHash string, expiresAt time.Time) error
+	// UpdatePassword replaces the password, moving the replaced hash into the
+	// password history, which is trimmed to its keep most recent entries
+	UpdatePassword(ctx context.Context, userID uuid.UUID, hashedPassword string, keep int) error
+	// GetPasswordHistory returns up to limit previous password hashes, newest first
+	GetPasswordHistory(ctx context.Context, userID uuid.UUID, limit int) ([]string, error)
+	UpdateLastLogin(ctx context.Context, userID uuid.UUID, client ClientInfo) error
+	StoreRefreshToken(ctx context.Context, token *RefreshToken) error
 	ValidateRefreshToken(ctx context.Context, tokenHash string) (*User, error)
 	RevokeRefreshToken(ctx context.Context, tokenHash string) error
+	ListRefreshTokens(ctx context.Context, userID uuid.UUID) ([]*RefreshToken, error)
+	RevokeSession(ctx context.Context, userID, sessionID uuid.UUID) (bool, error)
+	RevokeOtherSessions(ctx context.Context, userID, keepID uuid.UUID) error
+	IsSessionActive(ctx context.Context, sessionID uuid.UUID) (bool, error)
+	DeactivateUser(ctx context.Context, userID uuid.UUID) error
+	ReactivateUser(ctx context.Context, userID uuid.UUID) error
 	GetFollowers(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*User, error)
 	GetFollowing(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*User, error)
 }
@@ -101,15 +164,29 @@ type VerificationRepository interface {
 	StoreVerificationToken(ctx context.Context, userID uuid.UUID, token string, expiresAt time.Time) error
 	ValidateVerificationToken(ctx context.Context, token string) (*User, error)
 	MarkEmailVerified(ctx context.Context, userID uuid.UUID) error
+	// StorePasswordResetToken revokes the user's unused reset tokens before storing the new one
 	StorePasswordResetToken(ctx context.Context, userID uuid.UUID, token string, expiresAt time.Time) error
 	ValidatePasswordResetToken(ctx context.Context, token string) (*User, error)
 	RevokePasswordResetToken(ctx context.Context, token string) error
+	// StoreEmailChangeToken revokes the user's unconfirmed email changes before storing the new one
+	StoreEmailChangeToken(ctx context.Context, userID uuid.UUID, newEmail, token string, expiresAt time.Time) error
+	// ConfirmEmailChange moves the user to the email a valid change token was
+	// issued for, returning ErrEmailTaken if another account took it meanwhile
+	ConfirmEmailChange(ctx context.Context, token string) error
+}
+
+// TokenDenylist records revoked access tokens by their jti claim until they
+// expire
+type TokenDenylist interface {
+	Deny(ctx context.Context, jti string, ttl time.Duration) error
+	IsDenied(ctx context.Context, jti string) (bool, error)
 }
 
 // EmailService defines the interface for email operations
 type EmailService interface {
 	SendVerificationEmail(ctx context.Context, email, token string) error
 	SendPasswordResetEmail(ctx context.Context, email, token string) error
+	SendEmailChangeEmail(ctx context.Context, email, token string) error
 }
 
 // Provider represents different authentication providers
@@ -139,6 +216,13 @@ var (
 	ErrInvalidToken       = &AuthError{Code: "INVALID_TOKEN", Message: "Invalid or expired token"}
 	ErrUnauthorized       = &AuthError{Code: "UNAUTHORIZED", Message: "Unauthorized access"}
 	ErrSessionExpired     = &AuthError{Code: "SESSION_EXPIRED", Message: "Session has expired"}
+	ErrTokenRevoked       = &AuthError{Code: "TOKEN_REVOKED", Message: "Token has been revoked"}
 	ErrEmailNotVerified   = &AuthError{Code: "EMAIL_NOT_VERIFIED", Message: "Email not verified"}
 	ErrInvalidResetToken  = &AuthError{Code: "INVALID_RESET_TOKEN", Message: "Invalid or expired reset token"}
+	ErrAccountDeactivated = &AuthError{Code: "ACCOUNT_DEACTIVATED", Message: "Account is deactivated"}
+	ErrSessionNotFound    = &AuthError{Code: "SESSION_NOT_FOUND", Message: "Session not found"}
+	ErrIncorrectPassword  = &AuthError{Code: "INCORRECT_PASSWORD", Message: "Current password is incorrect"}
+	ErrPasswordReused     = &AuthError{Code: "PASSWORD_REUSED", Message: "Password was used recently, choose a different one"}
+	ErrEmailTaken         = &AuthError{Code: "EMAIL_TAKEN", Message: "Email is already in use"}
+	ErrEmailUnchanged     = &AuthError{Code: "EMAIL_UNCHANGED", Message: "New email matches the current one"}
 )
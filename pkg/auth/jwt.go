@@ -5,21 +5,41 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
+	"fowergram-backend/pkg/logger"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// ReactivationGracePeriod is how long a deactivated account can be restored
+// simply by signing in again
+const ReactivationGracePeriod = 30 * 24 * time.Hour
+
+// loginRecordTimeout bounds recording a sign-in, which runs after the
+// response so it doesn't add latency
+const loginRecordTimeout = 10 * time.Second
+
+// Token types, set in the typ claim so a refresh token, signed with the
+// same key, issuer and audience, can't be used as an access token
+const (
+	tokenTypeAccess  = "access"
+	tokenTypeRefresh = "refresh"
+)
+
 // Claims represents JWT claims
 type Claims struct {
-	UserID   uuid.UUID `json:"user_id"`
-	Email    string    `json:"email"`
-	Username string    `json:"username"`
+	UserID    uuid.UUID `json:"user_id"`
+	Email     string    `json:"email"`
+	Username  string    `json:"username"`
+	SessionID uuid.UUID `json:"sid,omitempty"`
+	TokenType string    `json:"typ"`
 	jwt.RegisteredClaims
 }
 
@@ -27,31 +47,56 @@ type Claims struct {
 type RefreshClaims struct {
 	UserID    uuid.UUID `json:"user_id"`
 	TokenHash string    `json:"token_hash"`
+	SessionID uuid.UUID `json:"sid,omitempty"`
+	TokenType string    `json:"typ"`
 	jwt.RegisteredClaims
 }
 
 // JWTAuth implements JWT-based authentication
 type JWTAuth struct {
-	secretKey        []byte
+	keys             *SigningKeys
 	accessTokenTTL   time.Duration
 	refreshTokenTTL  time.Duration
+	passwordHistory  int // Recent passwords, the current one included, that can't be reused
 	userRepo         UserRepository
 	verificationRepo VerificationRepository
 	emailService     EmailService
+	denylist         TokenDenylist
+	logger           logger.Logger
+
+	// cookieAuth lets requests without an Authorization header authenticate
+	// with the AccessTokenCookie
+	cookieAuth bool
 }
 
+// Cookies holding the tokens in cookie mode, for browser clients
+const (
+	AccessTokenCookie  = "access_token"
+	RefreshTokenCookie = "refresh_token"
+)
+
 // NewJWTAuth creates a new JWT authentication service
-func NewJWTAuth(secretKey string, accessTokenTTL, refreshTokenTTL time.Duration, userRepo UserRepository, verificationRepo VerificationRepository, emailService EmailService) *JWTAuth {
+func NewJWTAuth(keys *SigningKeys, accessTokenTTL, refreshTokenTTL time.Duration, passwordHistory int, userRepo UserRepository, verificationRepo VerificationRepository, emailService EmailService, denylist TokenDenylist, logger logger.Logger) *JWTAuth {
 	return &JWTAuth{
-		secretKey:        []byte(secretKey),
+		keys:             keys,
 		accessTokenTTL:   accessTokenTTL,
 		refreshTokenTTL:  refreshTokenTTL,
+		passwordHistory:  passwordHistory,
 		userRepo:         userRepo,
 		verificationRepo: verificationRepo,
 		emailService:     emailService,
+		denylist:         denylist,
+		logger:           logger,
 	}
 }
 
+// EnableCookieAuth makes Middleware accept the access token from the
+// AccessTokenCookie when a request carries no Authorization header. Requests
+// authenticated by cookie must be protected from CSRF separately.
+func (j *JWTAuth) EnableCookieAuth() {
+	j.cookieAuth = true
+}
+
 // CreateUser creates a new user with hashed password
 func (j *JWTAuth) CreateUser(ctx context.Context, email, password, username string) (*User, error) {
 	// Check if user already exists
@@ -86,46 +131,66 @@ func (j *JWTAuth) CreateUser(ctx context.Context, email, password, username stri
 }
 
 // SignIn authenticates user and returns JWT tokens
-func (j *JWTAuth) SignIn(ctx context.Context, email, password string) (*User, string, error) {
+func (j *JWTAuth) SignIn(ctx context.Context, email, password string, client ClientInfo) (*User, *Tokens, error) {
 	// Get user by email
 	user, err := j.userRepo.GetUserByEmail(ctx, email)
 	if err != nil {
-		return nil, "", ErrInvalidCredentials
-	}
-
-	// Check if user is active
-	if !user.IsActive {
-		return nil, "", fmt.Errorf("account is deactivated")
+		return nil, nil, ErrInvalidCredentials
 	}
 
 	// Verify password
 	if err := bcrypt.CompareHashAndPassword([]byte(user.HashedPassword), []byte(password)); err != nil {
-		return nil, "", ErrInvalidCredentials
+		return nil, nil, ErrInvalidCredentials
+	}
+
+	// Signing in to a deactivated account reactivates it within the grace period
+	if !user.IsActive {
+		if !canReactivate(user, time.Now()) {
+			return nil, nil, ErrAccountDeactivated
+		}
+		if err := j.userRepo.ReactivateUser(ctx, user.ID); err != nil {
+			return nil, nil, fmt.Errorf("failed to reactivate account: %w", err)
+		}
+		user.IsActive = true
+		user.DeactivatedAt = nil
 	}
 
+	// Each sign-in starts a session, named by its refresh token
+	sessionID := uuid.New()
+
 	// Generate access token
-	accessToken, err := j.generateAccessToken(user)
+	accessToken, err := j.generateAccessToken(user, sessionID)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to generate access token: %w", err)
+		return nil, nil, fmt.Errorf("failed to generate access token: %w", err)
 	}
 
 	// Generate refresh token
-	_, tokenHash, err := j.generateRefreshToken(user)
+	refreshToken, tokenHash, err := j.generateRefreshToken(user, sessionID)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to generate refresh token: %w", err)
+		return nil, nil, fmt.Errorf("failed to generate refresh token: %w", err)
 	}
 
 	// Store refresh token in database
-	expiresAt := time.Now().Add(j.refreshTokenTTL)
-	if err := j.userRepo.StoreRefreshToken(ctx, user.ID, tokenHash, expiresAt); err != nil {
-		return nil, "", fmt.Errorf("failed to store refresh token: %w", err)
+	now := time.Now()
+	err = j.userRepo.StoreRefreshToken(ctx, &RefreshToken{
+		ID:        sessionID,
+		UserID:    user.ID,
+		TokenHash: tokenHash,
+		ExpiresAt: now.Add(j.refreshTokenTTL),
+		CreatedAt: now,
+		IPAddress: client.IP,
+		UserAgent: client.UserAgent,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to store refresh token: %w", err)
 	}
 
+	go j.recordLogin(user.ID, client)
+
 	// Remove password from response
 	user.HashedPassword = ""
 
-	// Return access token
-	return user, accessToken, nil
+	return user, &Tokens{AccessToken: accessToken, RefreshToken: refreshToken}, nil
 }
 
 // SignOut revokes refresh token
@@ -145,7 +210,7 @@ func (j *JWTAuth) RefreshSession(ctx context.Context, refreshToken string) (*Use
 	// Parse refresh token
 	claims, err := j.parseRefreshToken(refreshToken)
 	if err != nil {
-		return nil, "", err
+		return nil, "", ErrInvalidToken
 	}
 
 	// Validate refresh token in database
@@ -155,7 +220,7 @@ func (j *JWTAuth) RefreshSession(ctx context.Context, refreshToken string) (*Use
 	}
 
 	// Generate new access token
-	accessToken, err := j.generateAccessToken(user)
+	accessToken, err := j.generateAccessToken(user, claims.SessionID)
 	if err != nil {
 		return nil, "", fmt.Errorf("failed to generate access token: %w", err)
 	}
@@ -173,6 +238,18 @@ func (j *JWTAuth) ValidateSession(ctx context.Context, accessToken string) (*Use
 		return nil, err
 	}
 
+	// Signed-out tokens are rejected before they expire. Tokens issued
+	// before tokens had IDs can't be denylisted.
+	if claims.ID != "" {
+		denied, err := j.denylist.IsDenied(ctx, claims.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check token denylist: %w", err)
+		}
+		if denied {
+			return nil, ErrTokenRevoked
+		}
+	}
+
 	// Get user from database to ensure they still exist and are active
 	user, err := j.userRepo.GetUserByID(ctx, claims.UserID)
 	if err != nil {
@@ -180,14 +257,77 @@ func (j *JWTAuth) ValidateSession(ctx context.Context, accessToken string) (*Use
 	}
 
 	if !user.IsActive {
-		return nil, fmt.Errorf("account is deactivated")
+		return nil, ErrAccountDeactivated
+	}
+
+	// Revoking a session signs it out immediately, not once its access token expires
+	if claims.SessionID != uuid.Nil {
+		active, err := j.userRepo.IsSessionActive(ctx, claims.SessionID)
+		if err != nil {
+			return nil, err
+		}
+		if !active {
+			return nil, ErrSessionExpired
+		}
 	}
+	user.SessionID = claims.SessionID
 
 	// Remove password from response
 	user.HashedPassword = ""
 	return user, nil
 }
 
+// RevokeAccessToken denylists an access token for the rest of its lifetime
+// and revokes its session, so neither it nor a token refreshed from the
+// session is accepted again. Expired tokens are ignored.
+func (j *JWTAuth) RevokeAccessToken(ctx context.Context, accessToken string) error {
+	claims, err := j.parseAccessToken(accessToken)
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil
+		}
+		return ErrInvalidToken
+	}
+
+	if claims.ID != "" && claims.ExpiresAt != nil {
+		if err := j.denylist.Deny(ctx, claims.ID, time.Until(claims.ExpiresAt.Time)); err != nil {
+			return fmt.Errorf("failed to denylist token: %w", err)
+		}
+	}
+
+	if claims.SessionID != uuid.Nil {
+		if _, err := j.userRepo.RevokeSession(ctx, claims.UserID, claims.SessionID); err != nil {
+			return fmt.Errorf("failed to revoke session: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// ListSessions lists the user's active sessions, newest first
+func (j *JWTAuth) ListSessions(ctx context.Context, userID uuid.UUID) ([]*RefreshToken, error) {
+	return j.userRepo.ListRefreshTokens(ctx, userID)
+}
+
+// RevokeSession signs one of the user's sessions out
+func (j *JWTAuth) RevokeSession(ctx context.Context, userID, sessionID uuid.UUID) error {
+	revoked, err := j.userRepo.RevokeSession(ctx, userID, sessionID)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// RevokeOtherSessions signs out every session of the user but currentID.
+// With uuid.Nil, from a token issued before sessions had IDs, all of them are
+// signed out.
+func (j *JWTAuth) RevokeOtherSessions(ctx context.Context, userID, currentID uuid.UUID) error {
+	return j.userRepo.RevokeOtherSessions(ctx, userID, currentID)
+}
+
 // GetUserFromContext extracts user from context (set by middleware)
 func (j *JWTAuth) GetUserFromContext(ctx context.Context) (*User, error) {
 	// Try to get user from context
@@ -199,17 +339,26 @@ func (j *JWTAuth) GetUserFromContext(ctx context.Context) (*User, error) {
 	return nil, ErrUnauthorized
 }
 
-// DeleteUser deactivates user account (soft delete)
+// DeleteUser deactivates user account (soft delete) and revokes all refresh tokens
 func (j *JWTAuth) DeleteUser(ctx context.Context, userID uuid.UUID) error {
-	user, err := j.userRepo.GetUserByID(ctx, userID)
-	if err != nil {
-		return err
-	}
+	return j.userRepo.DeactivateUser(ctx, userID)
+}
 
-	user.IsActive = false
-	user.UpdatedAt = time.Now()
+// recordLogin updates the user's last login and login history in the
+// background. It doesn't use the request's context, which ends with the
+// response.
+func (j *JWTAuth) recordLogin(userID uuid.UUID, client ClientInfo) {
+	ctx, cancel := context.WithTimeout(context.Background(), loginRecordTimeout)
+	defer cancel()
 
-	return j.userRepo.UpdateUser(ctx, user)
+	if err := j.userRepo.UpdateLastLogin(ctx, userID, client); err != nil {
+		j.logger.Error("Failed to record login", "user_id", userID, "error", err)
+	}
+}
+
+// canReactivate reports whether a deactivated account is still within the grace period
+func canReactivate(user *User, now time.Time) bool {
+	return user.DeactivatedAt != nil && now.Sub(*user.DeactivatedAt) <= ReactivationGracePeriod
 }
 
 // UpdateUserMetadata updates user metadata
@@ -242,6 +391,10 @@ func (j *JWTAuth) SendVerificationEmail(ctx context.Context, email string) error
 		return fmt.Errorf("failed to get user: %w", err)
 	}
 
+	if !user.IsActive {
+		return ErrAccountDeactivated
+	}
+
 	if user.IsVerified {
 		return &AuthError{Code: "EMAIL_ALREADY_VERIFIED", Message: "Email already verified"}
 	}
@@ -267,10 +420,11 @@ func (j *JWTAuth) SendVerificationEmail(ctx context.Context, email string) error
 	return nil
 }
 
-// RequestPasswordReset sends a password reset email
+// RequestPasswordReset sends a password reset email. Links sent earlier stop
+// working once a new one is issued.
 func (j *JWTAuth) RequestPasswordReset(ctx context.Context, email string) error {
 	user, err := j.userRepo.GetUserByEmail(ctx, email)
-	if err != nil {
+	if err != nil || !user.IsActive {
 		// Don't expose whether email exists or not
 		return nil
 	}
@@ -296,22 +450,21 @@ func (j *JWTAuth) RequestPasswordReset(ctx context.Context, email string) error
 	return nil
 }
 
-// ResetPassword resets a user's password
+// ResetPassword resets a user's password and signs out all of their
+// sessions. Recently used passwords are rejected with ErrPasswordReused.
 func (j *JWTAuth) ResetPassword(ctx context.Context, token, newPassword string) error {
 	user, err := j.verificationRepo.ValidatePasswordResetToken(ctx, token)
 	if err != nil {
 		return fmt.Errorf("failed to validate password reset token: %w", err)
 	}
 
-	// Hash new password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
-	if err != nil {
-		return fmt.Errorf("failed to hash password: %w", err)
+	if err := j.setPassword(ctx, user, newPassword); err != nil {
+		return err
 	}
 
-	// Update password
-	if err := j.userRepo.UpdatePassword(ctx, user.ID, string(hashedPassword)); err != nil {
-		return fmt.Errorf("failed to update password: %w", err)
+	// Whoever knew the old password is signed out everywhere
+	if err := j.userRepo.RevokeOtherSessions(ctx, user.ID, uuid.Nil); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
 	}
 
 	// Revoke token
@@ -322,6 +475,100 @@ func (j *JWTAuth) ResetPassword(ctx context.Context, token, newPassword string)
 	return nil
 }
 
+// ChangePassword replaces a signed-in user's password. The current password
+// must be given, and the new one may not match a recently used password.
+func (j *JWTAuth) ChangePassword(ctx context.Context, userID uuid.UUID, currentPassword, newPassword string) error {
+	user, err := j.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.HashedPassword), []byte(currentPassword)); err != nil {
+		return ErrIncorrectPassword
+	}
+
+	return j.setPassword(ctx, user, newPassword)
+}
+
+// RequestEmailChange sends a confirmation link to newEmail after checking the
+// user's password. The account keeps its current email until the link is
+// followed; requesting another change invalidates earlier links.
+func (j *JWTAuth) RequestEmailChange(ctx context.Context, userID uuid.UUID, password, newEmail string) error {
+	user, err := j.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.HashedPassword), []byte(password)); err != nil {
+		return ErrIncorrectPassword
+	}
+
+	if strings.EqualFold(user.Email, newEmail) {
+		return ErrEmailUnchanged
+	}
+
+	if _, err := j.userRepo.GetUserByEmail(ctx, newEmail); err == nil {
+		return ErrEmailTaken
+	} else if !errors.Is(err, ErrUserNotFound) {
+		return fmt.Errorf("failed to check email: %w", err)
+	}
+
+	// Generate confirmation token
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return fmt.Errorf("failed to generate token: %w", err)
+	}
+	tokenStr := base64.URLEncoding.EncodeToString(token)
+
+	// Store token
+	expiresAt := time.Now().Add(24 * time.Hour)
+	if err := j.verificationRepo.StoreEmailChangeToken(ctx, user.ID, newEmail, tokenStr, expiresAt); err != nil {
+		return fmt.Errorf("failed to store email change token: %w", err)
+	}
+
+	// Send email to the new address
+	if err := j.emailService.SendEmailChangeEmail(ctx, newEmail, tokenStr); err != nil {
+		return fmt.Errorf("failed to send email change email: %w", err)
+	}
+
+	return nil
+}
+
+// ConfirmEmailChange replaces the user's email with the address the token
+// was sent to
+func (j *JWTAuth) ConfirmEmailChange(ctx context.Context, token string) error {
+	return j.verificationRepo.ConfirmEmailChange(ctx, token)
+}
+
+// setPassword hashes and stores a new password for the user, returning
+// ErrPasswordReused when it matches the current password or one kept in the
+// password history
+func (j *JWTAuth) setPassword(ctx context.Context, user *User, newPassword string) error {
+	if j.passwordHistory > 0 {
+		previous, err := j.userRepo.GetPasswordHistory(ctx, user.ID, j.passwordHistory-1)
+		if err != nil {
+			return fmt.Errorf("failed to get password history: %w", err)
+		}
+
+		for _, hash := range append([]string{user.HashedPassword}, previous...) {
+			if bcrypt.CompareHashAndPassword([]byte(hash), []byte(newPassword)) == nil {
+				return ErrPasswordReused
+			}
+		}
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	if err := j.userRepo.UpdatePassword(ctx, user.ID, string(hashedPassword), max(j.passwordHistory-1, 0)); err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+
+	return nil
+}
+
 // Close closes any resources used by the auth service
 func (j *JWTAuth) Close() error {
 	return nil
@@ -330,35 +577,42 @@ func (j *JWTAuth) Close() error {
 // Middleware returns Fiber middleware for JWT authentication
 func (j *JWTAuth) Middleware() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		// Skip auth for health check and public endpoints
-		path := c.Path()
+		// Skip auth for health check and public endpoints, under /api/v1 or
+		// their unversioned aliases
+		path := strings.Replace(c.Path(), "/api/v1/", "/api/", 1)
 		if path == "/health" || path == "/metrics" || path == "/playground" ||
 			path == "/api/auth/signup" || path == "/api/auth/signin" ||
 			path == "/api/auth/verify-email" || path == "/api/auth/request-password-reset" ||
-			path == "/api/auth/reset-password" {
+			path == "/api/auth/reset-password" || path == "/api/auth/confirm-email-change" ||
+			path == "/api/auth/refresh" {
 			return c.Next()
 		}
 
-		// Get Authorization header
+		// Get the token from the Authorization header, or the cookie in cookie mode
 		authHeader := c.Get("Authorization")
-		if authHeader == "" {
-			return c.Status(401).JSON(fiber.Map{"error": "Authorization header required"})
-		}
-
-		// Extract token from "Bearer <token>"
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		if tokenString == authHeader {
-			return c.Status(401).JSON(fiber.Map{"error": "Invalid authorization format"})
+		var tokenString string
+		switch {
+		case authHeader != "":
+			// Extract token from "Bearer <token>"
+			tokenString = strings.TrimPrefix(authHeader, "Bearer ")
+			if tokenString == authHeader {
+				return fiber.NewError(fiber.StatusUnauthorized, "Invalid authorization format")
+			}
+		case j.cookieAuth && c.Cookies(AccessTokenCookie) != "":
+			tokenString = c.Cookies(AccessTokenCookie)
+		default:
+			return fiber.NewError(fiber.StatusUnauthorized, "Authorization header required")
 		}
 
 		// Validate token
 		user, err := j.ValidateSession(c.Context(), tokenString)
 		if err != nil {
-			return c.Status(401).JSON(fiber.Map{"error": "Invalid token"})
+			return fiber.NewError(fiber.StatusUnauthorized, "Invalid token")
 		}
 
 		// Set user in context
 		c.Locals("user", user)
+		c.Locals(logger.UserIDKey, user.ID.String())
 		return c.Next()
 	}
 }
@@ -366,26 +620,24 @@ func (j *JWTAuth) Middleware() fiber.Handler {
 // Helper methods
 
 // generateAccessToken creates a new access token
-func (j *JWTAuth) generateAccessToken(user *User) (string, error) {
+func (j *JWTAuth) generateAccessToken(user *User, sessionID uuid.UUID) (string, error) {
 	expirationTime := time.Now().Add(j.accessTokenTTL)
 
 	claims := &Claims{
-		UserID:   user.ID,
-		Email:    user.Email,
-		Username: user.Username,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			Subject:   user.ID.String(),
-		},
+		UserID:           user.ID,
+		Email:            user.Email,
+		Username:         user.Username,
+		SessionID:        sessionID,
+		TokenType:        tokenTypeAccess,
+		RegisteredClaims: j.keys.registeredClaims(user.ID.String(), expirationTime),
 	}
+	claims.ID = uuid.New().String() // jti, the key the token is denylisted by
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(j.secretKey)
+	return j.keys.sign(claims)
 }
 
 // generateRefreshToken creates a new refresh token
-func (j *JWTAuth) generateRefreshToken(user *User) (string, string, error) {
+func (j *JWTAuth) generateRefreshToken(user *User, sessionID uuid.UUID) (string, string, error) {
 	// Generate random token hash
 	randomBytes := make([]byte, 32)
 	if _, err := rand.Read(randomBytes); err != nil {
@@ -396,17 +648,14 @@ func (j *JWTAuth) generateRefreshToken(user *User) (string, string, error) {
 	expirationTime := time.Now().Add(j.refreshTokenTTL)
 
 	claims := &RefreshClaims{
-		UserID:    user.ID,
-		TokenHash: tokenHash,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			Subject:   user.ID.String(),
-		},
+		UserID:           user.ID,
+		TokenHash:        tokenHash,
+		SessionID:        sessionID,
+		TokenType:        tokenTypeRefresh,
+		RegisteredClaims: j.keys.registeredClaims(user.ID.String(), expirationTime),
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(j.secretKey)
+	tokenString, err := j.keys.sign(claims)
 	return tokenString, tokenHash, err
 }
 
@@ -414,12 +663,7 @@ func (j *JWTAuth) generateRefreshToken(user *User) (string, string, error) {
 func (j *JWTAuth) parseAccessToken(tokenString string) (*Claims, error) {
 	claims := &Claims{}
 
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return j.secretKey, nil
-	})
+	token, err := j.keys.parse(tokenString, claims)
 
 	if err != nil {
 		return nil, fmt.Errorf("invalid token: %w", err)
@@ -429,6 +673,13 @@ func (j *JWTAuth) parseAccessToken(tokenString string) (*Claims, error) {
 		return nil, fmt.Errorf("invalid token")
 	}
 
+	// Untyped tokens, issued before tokens had types, are rejected too, as
+	// untyped refresh tokens would pass; clients refresh them as they would
+	// an expired one
+	if claims.TokenType != tokenTypeAccess {
+		return nil, fmt.Errorf("invalid token: %w", ErrInvalidToken)
+	}
+
 	return claims, nil
 }
 
@@ -436,12 +687,7 @@ func (j *JWTAuth) parseAccessToken(tokenString string) (*Claims, error) {
 func (j *JWTAuth) parseRefreshToken(tokenString string) (*RefreshClaims, error) {
 	claims := &RefreshClaims{}
 
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return j.secretKey, nil
-	})
+	token, err := j.keys.parse(tokenString, claims)
 
 	if err != nil {
 		return nil, fmt.Errorf("invalid refresh token: %w", err)
@@ -451,5 +697,12 @@ func (j *JWTAuth) parseRefreshToken(tokenString string) (*RefreshClaims, error)
 		return nil, fmt.Errorf("invalid refresh token")
 	}
 
+	// Refresh tokens issued before tokens had types are told apart from
+	// access tokens by their hash, so their sessions aren't signed out
+	legacy := claims.TokenType == "" && claims.TokenHash != ""
+	if claims.TokenType != tokenTypeRefresh && !legacy {
+		return nil, fmt.Errorf("invalid refresh token: %w", ErrInvalidToken)
+	}
+
 	return claims, nil
 }
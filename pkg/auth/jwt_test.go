@@ -0,0 +1,723 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"fowergram-backend/pkg/logger"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const testPassword = "correct horse battery staple"
+
+// fakeUserRepository keeps users and refresh tokens in memory. Methods the
+// tests don't reach are left to the embedded nil UserRepository.
+type fakeUserRepository struct {
+	UserRepository
+
+	mu     sync.Mutex
+	users  map[uuid.UUID]*User
+	tokens []*RefreshToken
+	logins chan ClientInfo // Receives each recorded login when set
+
+	history map[uuid.UUID][]string // Previous password hashes, newest first
+}
+
+func newFakeUserRepository() *fakeUserRepository {
+	return &fakeUserRepository{users: make(map[uuid.UUID]*User), history: make(map[uuid.UUID][]string)}
+}
+
+// addUser stores an active user with testPassword
+func (r *fakeUserRepository) addUser(t *testing.T, email string) *User {
+	t.Helper()
+	hashed, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("hashing password: %v", err)
+	}
+	user := &User{ID: uuid.New(), Email: email, Username: "user", HashedPassword: string(hashed), IsActive: true}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.users[user.ID] = user
+	return user
+}
+
+// user returns a copy of the stored user
+func (r *fakeUserRepository) user(id uuid.UUID) User {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return *r.users[id]
+}
+
+func (r *fakeUserRepository) GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, user := range r.users {
+		if user.Email == email {
+			copied := *user
+			return &copied, nil
+		}
+	}
+	return nil, ErrUserNotFound
+}
+
+func (r *fakeUserRepository) GetUserByID(ctx context.Context, id uuid.UUID) (*User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[id]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (r *fakeUserRepository) ReactivateUser(ctx context.Context, userID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.users[userID].IsActive = true
+	r.users[userID].DeactivatedAt = nil
+	return nil
+}
+
+func (r *fakeUserRepository) StoreRefreshToken(ctx context.Context, token *RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tokens = append(r.tokens, token)
+	return nil
+}
+
+func (r *fakeUserRepository) UpdateLastLogin(ctx context.Context, userID uuid.UUID, client ClientInfo) error {
+	if r.logins != nil {
+		r.logins <- client
+	}
+	return nil
+}
+
+func (r *fakeUserRepository) ValidateRefreshToken(ctx context.Context, tokenHash string) (*User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, token := range r.tokens {
+		if token.TokenHash == tokenHash && token.RevokedAt == nil {
+			copied := *r.users[token.UserID]
+			return &copied, nil
+		}
+	}
+	return nil, ErrInvalidToken
+}
+
+func (r *fakeUserRepository) ListRefreshTokens(ctx context.Context, userID uuid.UUID) ([]*RefreshToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var active []*RefreshToken
+	for _, token := range slices.Backward(r.tokens) {
+		if token.UserID == userID && token.RevokedAt == nil {
+			active = append(active, token)
+		}
+	}
+	return active, nil
+}
+
+func (r *fakeUserRepository) RevokeSession(ctx context.Context, userID, sessionID uuid.UUID) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, token := range r.tokens {
+		if token.ID == sessionID && token.UserID == userID && token.RevokedAt == nil {
+			now := time.Now()
+			token.RevokedAt = &now
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakeUserRepository) RevokeOtherSessions(ctx context.Context, userID, keepID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for _, token := range r.tokens {
+		if token.UserID == userID && token.ID != keepID && token.RevokedAt == nil {
+			token.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (r *fakeUserRepository) IsSessionActive(ctx context.Context, sessionID uuid.UUID) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, token := range r.tokens {
+		if token.ID == sessionID {
+			return token.RevokedAt == nil, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakeUserRepository) UpdatePassword(ctx context.Context, userID uuid.UUID, hashedPassword string, keep int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	history := append([]string{r.users[userID].HashedPassword}, r.history[userID]...)
+	r.history[userID] = history[:min(keep, len(history))]
+	r.users[userID].HashedPassword = hashedPassword
+	return nil
+}
+
+func (r *fakeUserRepository) GetPasswordHistory(ctx context.Context, userID uuid.UUID, limit int) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	history := r.history[userID]
+	return history[:min(limit, len(history))], nil
+}
+
+// sessions returns how many refresh tokens were stored
+func (r *fakeUserRepository) sessions() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.tokens)
+}
+
+// fakeDenylist keeps denied token IDs along with how long they are denied for
+type fakeDenylist struct {
+	mu     sync.Mutex
+	denied map[string]time.Duration
+}
+
+func (d *fakeDenylist) Deny(ctx context.Context, jti string, ttl time.Duration) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.denied == nil {
+		d.denied = make(map[string]time.Duration)
+	}
+	d.denied[jti] = ttl
+	return nil
+}
+
+func (d *fakeDenylist) IsDenied(ctx context.Context, jti string) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, ok := d.denied[jti]
+	return ok, nil
+}
+
+// newTestAuth returns a JWTAuth signing HS256 tokens for repo's users
+func newTestAuth(repo *fakeUserRepository) *JWTAuth {
+	return NewJWTAuth(NewHMACKeys("test-secret"), 15*time.Minute, 24*time.Hour, 3, repo, nil, nil, &fakeDenylist{}, logger.NewZapLogger())
+}
+
+func TestSignInReactivation(t *testing.T) {
+	tests := []struct {
+		name            string
+		deactivatedAgo  time.Duration // Zero for an active account
+		password        string
+		wantErr         error
+		wantReactivated bool
+	}{
+		{name: "active account", password: testPassword},
+		{
+			name:            "deactivated yesterday",
+			deactivatedAgo:  24 * time.Hour,
+			password:        testPassword,
+			wantReactivated: true,
+		},
+		{
+			name:            "deactivated at the end of the grace period",
+			deactivatedAgo:  ReactivationGracePeriod - time.Minute,
+			password:        testPassword,
+			wantReactivated: true,
+		},
+		{
+			name:           "deactivated past the grace period",
+			deactivatedAgo: ReactivationGracePeriod + time.Minute,
+			password:       testPassword,
+			wantErr:        ErrAccountDeactivated,
+		},
+		{
+			name:           "wrong password doesn't reactivate",
+			deactivatedAgo: 24 * time.Hour,
+			password:       "wrong password",
+			wantErr:        ErrInvalidCredentials,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeUserRepository()
+			user := repo.addUser(t, "user@example.com")
+			if tt.deactivatedAgo != 0 {
+				deactivatedAt := time.Now().Add(-tt.deactivatedAgo)
+				user.IsActive = false
+				user.DeactivatedAt = &deactivatedAt
+			}
+
+			signedIn, tokens, err := newTestAuth(repo).SignIn(context.Background(), "user@example.com", tt.password, ClientInfo{})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SignIn error = %v, want %v", err, tt.wantErr)
+			}
+
+			stored := repo.user(user.ID)
+			wantActive := tt.deactivatedAgo == 0 || tt.wantReactivated
+			if stored.IsActive != wantActive || (stored.DeactivatedAt == nil) != wantActive {
+				t.Errorf("stored active = %v, deactivated at %v; want active %v", stored.IsActive, stored.DeactivatedAt, wantActive)
+			}
+
+			if tt.wantErr != nil {
+				if tokens != nil || repo.sessions() != 0 {
+					t.Errorf("SignIn failed with %v but started a session", err)
+				}
+				return
+			}
+			if !signedIn.IsActive || signedIn.DeactivatedAt != nil || signedIn.HashedPassword != "" {
+				t.Errorf("signed in as %+v, want an active user without the password hash", signedIn)
+			}
+			if tokens == nil || tokens.AccessToken == "" || repo.sessions() != 1 {
+				t.Errorf("tokens = %+v with %d sessions, want one session", tokens, repo.sessions())
+			}
+		})
+	}
+}
+
+func TestSignInRecordsLogin(t *testing.T) {
+	repo := newFakeUserRepository()
+	repo.logins = make(chan ClientInfo, 1)
+	repo.addUser(t, "user@example.com")
+	service := newTestAuth(repo)
+	client := ClientInfo{IP: "203.0.113.7", UserAgent: "Fowergram/1.0 (iOS)"}
+
+	if _, _, err := service.SignIn(context.Background(), "user@example.com", "wrong password", client); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("SignIn with the wrong password = %v, want ErrInvalidCredentials", err)
+	}
+	if _, _, err := service.SignIn(context.Background(), "user@example.com", testPassword, client); err != nil {
+		t.Fatalf("SignIn: %v", err)
+	}
+
+	// The login is recorded in the background, after SignIn returns
+	select {
+	case got := <-repo.logins:
+		if got != client {
+			t.Errorf("recorded login from %+v, want %+v", got, client)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("login not recorded")
+	}
+	select {
+	case got := <-repo.logins:
+		t.Errorf("recorded a second login from %+v; only one sign-in succeeded", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestSessions(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeUserRepository()
+	alice := repo.addUser(t, "alice@example.com")
+	bob := repo.addUser(t, "bob@example.com")
+	service := newTestAuth(repo)
+
+	signIn := func(email string, client ClientInfo) *Tokens {
+		t.Helper()
+		_, tokens, err := service.SignIn(ctx, email, testPassword, client)
+		if err != nil {
+			t.Fatalf("SignIn: %v", err)
+		}
+		return tokens
+	}
+	phone := signIn("alice@example.com", ClientInfo{IP: "203.0.113.7", UserAgent: "Fowergram/1.0 (iOS)"})
+	laptop := signIn("alice@example.com", ClientInfo{IP: "198.51.100.2", UserAgent: "Firefox"})
+	tablet := signIn("alice@example.com", ClientInfo{IP: "192.0.2.9", UserAgent: "Fowergram/1.0 (iPadOS)"})
+	bobs := signIn("bob@example.com", ClientInfo{})
+
+	sessionOf := func(tokens *Tokens) uuid.UUID {
+		t.Helper()
+		user, err := service.ValidateSession(ctx, tokens.AccessToken)
+		if err != nil {
+			t.Fatalf("ValidateSession: %v", err)
+		}
+		return user.SessionID
+	}
+	phoneID, laptopID := sessionOf(phone), sessionOf(laptop)
+
+	sessions, err := service.ListSessions(ctx, alice.ID)
+	if err != nil {
+		t.Fatalf("ListSessions: %v", err)
+	}
+	var agents []string
+	for _, session := range sessions {
+		agents = append(agents, session.UserAgent)
+	}
+	if want := []string{"Fowergram/1.0 (iPadOS)", "Firefox", "Fowergram/1.0 (iOS)"}; !slices.Equal(agents, want) {
+		t.Errorf("sessions from %v, want alice's three newest first", agents)
+	}
+	if sessions[2].ID != phoneID || sessions[2].IPAddress != "203.0.113.7" {
+		t.Errorf("phone session = %+v, want %s from its sign-in IP", sessions[2], phoneID)
+	}
+
+	// Another user's session can't be revoked
+	if err := service.RevokeSession(ctx, bob.ID, phoneID); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("revoking alice's session as bob = %v, want ErrSessionNotFound", err)
+	}
+
+	// Revoking the phone signs out only the phone, at once
+	if err := service.RevokeSession(ctx, alice.ID, phoneID); err != nil {
+		t.Fatalf("RevokeSession: %v", err)
+	}
+	if _, err := service.ValidateSession(ctx, phone.AccessToken); !errors.Is(err, ErrSessionExpired) {
+		t.Errorf("phone access token = %v, want ErrSessionExpired", err)
+	}
+	if _, _, err := service.RefreshSession(ctx, phone.RefreshToken); err == nil {
+		t.Errorf("refreshed the revoked phone session")
+	}
+	for name, tokens := range map[string]*Tokens{"laptop": laptop, "tablet": tablet, "bob's": bobs} {
+		if _, err := service.ValidateSession(ctx, tokens.AccessToken); err != nil {
+			t.Errorf("%s session signed out: %v", name, err)
+		}
+		if _, _, err := service.RefreshSession(ctx, tokens.RefreshToken); err != nil {
+			t.Errorf("refreshing the %s session: %v", name, err)
+		}
+	}
+	if err := service.RevokeSession(ctx, alice.ID, phoneID); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("revoking the phone again = %v, want ErrSessionNotFound", err)
+	}
+
+	// Signing out the others from the laptop keeps the laptop and bob signed in
+	if err := service.RevokeOtherSessions(ctx, alice.ID, laptopID); err != nil {
+		t.Fatalf("RevokeOtherSessions: %v", err)
+	}
+	if _, err := service.ValidateSession(ctx, tablet.AccessToken); !errors.Is(err, ErrSessionExpired) {
+		t.Errorf("tablet access token = %v, want ErrSessionExpired", err)
+	}
+	for name, tokens := range map[string]*Tokens{"laptop": laptop, "bob's": bobs} {
+		if _, err := service.ValidateSession(ctx, tokens.AccessToken); err != nil {
+			t.Errorf("%s session signed out: %v", name, err)
+		}
+	}
+	if sessions, err := service.ListSessions(ctx, alice.ID); err != nil || len(sessions) != 1 || sessions[0].ID != laptopID {
+		t.Errorf("sessions after signing out the others = %v, %v; want the laptop's", sessions, err)
+	}
+}
+
+// fakeVerificationRepository accepts the password reset tokens in resets and
+// records email changes.
+// Other methods are left to the embedded nil VerificationRepository.
+type fakeVerificationRepository struct {
+	VerificationRepository
+
+	users   *fakeUserRepository
+	resets  map[string]uuid.UUID
+	changes []string // New addresses of the email changes stored
+}
+
+func (r *fakeVerificationRepository) ValidatePasswordResetToken(ctx context.Context, token string) (*User, error) {
+	userID, ok := r.resets[token]
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	return r.users.GetUserByID(ctx, userID)
+}
+
+func (r *fakeVerificationRepository) StoreEmailChangeToken(ctx context.Context, userID uuid.UUID, newEmail, token string, expiresAt time.Time) error {
+	r.changes = append(r.changes, newEmail)
+	return nil
+}
+
+func (r *fakeVerificationRepository) RevokePasswordResetToken(ctx context.Context, token string) error {
+	delete(r.resets, token)
+	return nil
+}
+
+func TestPasswordHistory(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeUserRepository()
+	user := repo.addUser(t, "user@example.com")
+	verifications := &fakeVerificationRepository{users: repo, resets: make(map[string]uuid.UUID)}
+	// The current password and the two before it can't be reused
+	service := NewJWTAuth(NewHMACKeys("test-secret"), 15*time.Minute, 24*time.Hour, 3, repo, verifications, nil, &fakeDenylist{}, logger.NewZapLogger())
+
+	current := testPassword
+	steps := []struct {
+		name     string
+		current  string // Given as the current password; the actual one when empty
+		password string
+		reset    bool // Whether the password is reset by email rather than changed
+		wantErr  error
+	}{
+		{name: "wrong current password", current: "guess", password: "first new password", wantErr: ErrIncorrectPassword},
+		{name: "same as the current password", password: testPassword, wantErr: ErrPasswordReused},
+		{name: "new password", password: "first new password"},
+		{name: "another new password", password: "second new password"},
+		{name: "two passwords ago", password: testPassword, wantErr: ErrPasswordReused},
+		{name: "reset to the previous password", password: "first new password", reset: true, wantErr: ErrPasswordReused},
+		{name: "reset to a new password", password: "third new password", reset: true},
+		{name: "dropped out of the history", password: testPassword},
+	}
+
+	for _, step := range steps {
+		var err error
+		if step.reset {
+			verifications.resets["reset-token"] = user.ID
+			err = service.ResetPassword(ctx, "reset-token", step.password)
+		} else {
+			given := step.current
+			if given == "" {
+				given = current
+			}
+			err = service.ChangePassword(ctx, user.ID, given, step.password)
+		}
+		if !errors.Is(err, step.wantErr) {
+			t.Fatalf("%s: error = %v, want %v", step.name, err, step.wantErr)
+		}
+		if err == nil {
+			current = step.password
+		}
+
+		stored := repo.user(user.ID)
+		if bcrypt.CompareHashAndPassword([]byte(stored.HashedPassword), []byte(current)) != nil {
+			t.Fatalf("%s: stored password isn't %q", step.name, current)
+		}
+		if _, pending := verifications.resets["reset-token"]; step.reset && pending != (err != nil) {
+			t.Errorf("%s: reset token still valid = %v, want it revoked only by a successful reset", step.name, pending)
+		}
+	}
+}
+
+// fakeEmailService records the addresses email change links are sent to.
+// Other methods are left to the embedded nil EmailService.
+type fakeEmailService struct {
+	EmailService
+
+	sent []string
+}
+
+func (s *fakeEmailService) SendEmailChangeEmail(ctx context.Context, email, token string) error {
+	s.sent = append(s.sent, email)
+	return nil
+}
+
+func TestRequestEmailChange(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeUserRepository()
+	user := repo.addUser(t, "alice@example.com")
+	repo.addUser(t, "bob@example.com")
+	verifications := &fakeVerificationRepository{users: repo}
+	emails := &fakeEmailService{}
+	service := NewJWTAuth(NewHMACKeys("test-secret"), 15*time.Minute, 24*time.Hour, 3, repo, verifications, emails, &fakeDenylist{}, logger.NewZapLogger())
+
+	tests := []struct {
+		name     string
+		password string
+		newEmail string
+		wantErr  error
+	}{
+		{name: "wrong password", password: "guess", newEmail: "alice@example.org", wantErr: ErrIncorrectPassword},
+		{name: "same address", password: testPassword, newEmail: "Alice@Example.com", wantErr: ErrEmailUnchanged},
+		{name: "taken address", password: testPassword, newEmail: "bob@example.com", wantErr: ErrEmailTaken},
+		{name: "new address", password: testPassword, newEmail: "alice@example.org"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifications.changes, emails.sent = nil, nil
+			err := service.RequestEmailChange(ctx, user.ID, tt.password, tt.newEmail)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RequestEmailChange() error = %v, want %v", err, tt.wantErr)
+			}
+
+			var want []string
+			if tt.wantErr == nil {
+				want = []string{tt.newEmail}
+			}
+			if !slices.Equal(verifications.changes, want) || !slices.Equal(emails.sent, want) {
+				t.Errorf("stored changes to %v and sent links to %v, want %v", verifications.changes, emails.sent, want)
+			}
+			// The account keeps its address until the link is followed
+			if got := repo.user(user.ID).Email; got != "alice@example.com" {
+				t.Errorf("email = %s, want it unchanged", got)
+			}
+		})
+	}
+}
+
+func TestRevokeAccessToken(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeUserRepository()
+	repo.addUser(t, "user@example.com")
+	denylist := &fakeDenylist{}
+	service := NewJWTAuth(NewHMACKeys("test-secret"), 15*time.Minute, 24*time.Hour, 3, repo, nil, nil, denylist, logger.NewZapLogger())
+
+	_, signedOut, err := service.SignIn(ctx, "user@example.com", testPassword, ClientInfo{})
+	if err != nil {
+		t.Fatalf("SignIn: %v", err)
+	}
+	_, other, err := service.SignIn(ctx, "user@example.com", testPassword, ClientInfo{})
+	if err != nil {
+		t.Fatalf("SignIn: %v", err)
+	}
+
+	if err := service.RevokeAccessToken(ctx, signedOut.AccessToken); err != nil {
+		t.Fatalf("RevokeAccessToken: %v", err)
+	}
+
+	// The token is rejected long before it expires, while the other
+	// session's stays valid
+	if _, err := service.ValidateSession(ctx, signedOut.AccessToken); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("revoked token = %v, want ErrTokenRevoked", err)
+	}
+	if _, err := service.ValidateSession(ctx, other.AccessToken); err != nil {
+		t.Errorf("other session's token: %v", err)
+	}
+	if len(denylist.denied) != 1 {
+		t.Fatalf("denylisted %v, want the revoked token", denylist.denied)
+	}
+	for _, ttl := range denylist.denied {
+		if ttl <= 14*time.Minute || ttl > 15*time.Minute {
+			t.Errorf("denylisted for %v, want the token's remaining lifetime", ttl)
+		}
+	}
+
+	// Expired and invalid tokens need no denylisting
+	expired := NewJWTAuth(NewHMACKeys("test-secret"), -time.Minute, 24*time.Hour, 3, repo, nil, nil, denylist, logger.NewZapLogger())
+	_, stale, err := expired.SignIn(ctx, "user@example.com", testPassword, ClientInfo{})
+	if err != nil {
+		t.Fatalf("SignIn: %v", err)
+	}
+	if err := service.RevokeAccessToken(ctx, stale.AccessToken); err != nil || len(denylist.denied) != 1 {
+		t.Errorf("revoking an expired token = %v, denylisted %d; want it ignored", err, len(denylist.denied))
+	}
+	if err := service.RevokeAccessToken(ctx, "garbage"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("revoking garbage = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestMiddlewareTokenSources(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeUserRepository()
+	user := repo.addUser(t, "user@example.com")
+	service := newTestAuth(repo)
+	_, tokens, err := service.SignIn(ctx, "user@example.com", testPassword, ClientInfo{})
+	if err != nil {
+		t.Fatalf("SignIn: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		cookieAuth    bool
+		authorization string
+		cookie        string // AccessTokenCookie
+		wantStatus    int
+	}{
+		{name: "header", authorization: "Bearer " + tokens.AccessToken, wantStatus: fiber.StatusOK},
+		{name: "cookie outside cookie mode", cookie: tokens.AccessToken, wantStatus: fiber.StatusUnauthorized},
+		{name: "header in cookie mode", cookieAuth: true, authorization: "Bearer " + tokens.AccessToken, wantStatus: fiber.StatusOK},
+		{name: "cookie in cookie mode", cookieAuth: true, cookie: tokens.AccessToken, wantStatus: fiber.StatusOK},
+		{name: "invalid cookie", cookieAuth: true, cookie: "garbage", wantStatus: fiber.StatusUnauthorized},
+		{name: "header wins over the cookie", cookieAuth: true, authorization: "Bearer garbage", cookie: tokens.AccessToken, wantStatus: fiber.StatusUnauthorized},
+		{name: "neither", cookieAuth: true, wantStatus: fiber.StatusUnauthorized},
+		{name: "refresh token in the header", authorization: "Bearer " + tokens.RefreshToken, wantStatus: fiber.StatusUnauthorized},
+		{name: "refresh token in the cookie", cookieAuth: true, cookie: tokens.RefreshToken, wantStatus: fiber.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := newTestAuth(repo)
+			if tt.cookieAuth {
+				service.EnableCookieAuth()
+			}
+			app := fiber.New()
+			app.Use(service.Middleware())
+			app.Get("/api/v1/auth/me", func(c *fiber.Ctx) error {
+				if got := c.Locals("user").(*User); got.ID != user.ID {
+					t.Errorf("authenticated as %s, want %s", got.ID, user.ID)
+				}
+				return c.SendStatus(fiber.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/auth/me", nil)
+			if tt.authorization != "" {
+				req.Header.Set(fiber.HeaderAuthorization, tt.authorization)
+			}
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: AccessTokenCookie, Value: tt.cookie})
+			}
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestTokenTypes(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeUserRepository()
+	repo.addUser(t, "user@example.com")
+	service := newTestAuth(repo)
+	_, tokens, err := service.SignIn(ctx, "user@example.com", testPassword, ClientInfo{})
+	if err != nil {
+		t.Fatalf("SignIn: %v", err)
+	}
+
+	// Tokens as issued before tokens had types
+	untyped := func(token string, claims jwt.Claims) string {
+		t.Helper()
+		if _, err := service.keys.parse(token, claims); err != nil {
+			t.Fatalf("parsing: %v", err)
+		}
+		switch c := claims.(type) {
+		case *Claims:
+			c.TokenType = ""
+		case *RefreshClaims:
+			c.TokenType = ""
+		}
+		signed, err := service.keys.sign(claims)
+		if err != nil {
+			t.Fatalf("signing: %v", err)
+		}
+		return signed
+	}
+	untypedAccess := untyped(tokens.AccessToken, &Claims{})
+	untypedRefresh := untyped(tokens.RefreshToken, &RefreshClaims{})
+
+	tests := []struct {
+		name    string
+		use     func() error
+		wantErr bool
+	}{
+		{name: "access token as an access token", use: func() error { _, err := service.ValidateSession(ctx, tokens.AccessToken); return err }},
+		{name: "refresh token as an access token", use: func() error { _, err := service.ValidateSession(ctx, tokens.RefreshToken); return err }, wantErr: true},
+		{name: "untyped refresh token as an access token", use: func() error { _, err := service.ValidateSession(ctx, untypedRefresh); return err }, wantErr: true},
+		{name: "untyped access token as an access token", use: func() error { _, err := service.ValidateSession(ctx, untypedAccess); return err }, wantErr: true},
+		{name: "refresh token revoked as an access token", use: func() error { return service.RevokeAccessToken(ctx, tokens.RefreshToken) }, wantErr: true},
+		{name: "refresh token as a refresh token", use: func() error { _, _, err := service.RefreshSession(ctx, tokens.RefreshToken); return err }},
+		{name: "untyped refresh token as a refresh token", use: func() error { _, _, err := service.RefreshSession(ctx, untypedRefresh); return err }},
+		{name: "access token as a refresh token", use: func() error { _, _, err := service.RefreshSession(ctx, tokens.AccessToken); return err }, wantErr: true},
+		{name: "access token signing out", use: func() error { return service.SignOut(ctx, tokens.AccessToken) }, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.use()
+			if !tt.wantErr {
+				if err != nil {
+					t.Errorf("error = %v, want none", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("error = %v, want ErrInvalidToken", err)
+			}
+		})
+	}
+}
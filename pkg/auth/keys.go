@@ -0,0 +1,224 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Token signing algorithms
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+)
+
+// SigningConfig selects how tokens are signed and verified
+type SigningConfig struct {
+	Algorithm string // HS256 (default), RS256 or ES256
+	Secret    string // Shared secret for HS256
+
+	// PrivateKeyFile is the PEM key tokens are signed with under RS256/ES256
+	PrivateKeyFile string
+	KeyID          string // kid header; defaults to the key's JWK thumbprint
+
+	// Tokens are verified with the signing key's public half unless one of
+	// these is set, e.g. while rotating keys
+	PublicKeyFile string
+	JWKSURL       string
+
+	// Issuer and Audience are set on issued tokens and required on verified
+	// ones; empty values aren't checked
+	Issuer   string
+	Audience string
+	Leeway   time.Duration // Clock skew tolerated on exp, nbf and iat
+}
+
+// SigningKeys signs and verifies access and refresh tokens
+type SigningKeys struct {
+	method    jwt.SigningMethod
+	signKey   interface{} // []byte, *rsa.PrivateKey or *ecdsa.PrivateKey
+	verifyKey interface{} // []byte, *rsa.PublicKey or *ecdsa.PublicKey
+	keyID     string
+	jwks      *remoteJWKS // Verification keys by kid, when configured
+
+	issuer   string
+	audience string
+	leeway   time.Duration
+}
+
+// NewHMACKeys returns HS256 keys using a shared secret
+func NewHMACKeys(secret string) *SigningKeys {
+	return &SigningKeys{
+		method:    jwt.SigningMethodHS256,
+		signKey:   []byte(secret),
+		verifyKey: []byte(secret),
+	}
+}
+
+// NewSigningKeys loads the keys cfg describes
+func NewSigningKeys(cfg SigningConfig) (*SigningKeys, error) {
+	keys, err := loadSigningKeys(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	keys.issuer = cfg.Issuer
+	keys.audience = cfg.Audience
+	keys.leeway = cfg.Leeway
+	return keys, nil
+}
+
+// loadSigningKeys loads the signing and verification keys of cfg
+func loadSigningKeys(cfg SigningConfig) (*SigningKeys, error) {
+	switch cfg.Algorithm {
+	case "", AlgHS256:
+		return NewHMACKeys(cfg.Secret), nil
+	case AlgRS256, AlgES256:
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", cfg.Algorithm)
+	}
+
+	data, err := os.ReadFile(cfg.PrivateKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key: %w", err)
+	}
+
+	keys := &SigningKeys{keyID: cfg.KeyID}
+	if cfg.Algorithm == AlgRS256 {
+		key, err := jwt.ParseRSAPrivateKeyFromPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse RS256 signing key: %w", err)
+		}
+		keys.method, keys.signKey, keys.verifyKey = jwt.SigningMethodRS256, key, &key.PublicKey
+	} else {
+		key, err := jwt.ParseECPrivateKeyFromPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse ES256 signing key: %w", err)
+		}
+		if key.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("ES256 signing key must use the P-256 curve")
+		}
+		keys.method, keys.signKey, keys.verifyKey = jwt.SigningMethodES256, key, &key.PublicKey
+	}
+
+	if keys.keyID == "" {
+		if keys.keyID, err = thumbprint(keys.verifyKey); err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case cfg.JWKSURL != "":
+		keys.jwks = newRemoteJWKS(cfg.JWKSURL, 10*time.Second)
+	case cfg.PublicKeyFile != "":
+		if keys.verifyKey, err = loadPublicKey(cfg.Algorithm, cfg.PublicKeyFile); err != nil {
+			return nil, err
+		}
+	}
+
+	return keys, nil
+}
+
+// loadPublicKey reads a PEM public key for algorithm
+func loadPublicKey(algorithm, path string) (interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read verification key: %w", err)
+	}
+
+	var key interface{}
+	if algorithm == AlgRS256 {
+		key, err = jwt.ParseRSAPublicKeyFromPEM(data)
+	} else {
+		key, err = jwt.ParseECPublicKeyFromPEM(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse verification key: %w", err)
+	}
+	return key, nil
+}
+
+// Asymmetric reports whether tokens are signed with a private key, so the
+// public keys can be published as a JWKS
+func (k *SigningKeys) Asymmetric() bool {
+	_, hmac := k.method.(*jwt.SigningMethodHMAC)
+	return !hmac
+}
+
+// JWKS returns the public key tokens are signed with. It is empty for HS256.
+func (k *SigningKeys) JWKS() JWKS {
+	set := JWKS{Keys: []JWK{}}
+
+	var public interface{}
+	switch key := k.signKey.(type) {
+	case *rsa.PrivateKey:
+		public = &key.PublicKey
+	case *ecdsa.PrivateKey:
+		public = &key.PublicKey
+	default:
+		return set
+	}
+
+	if jwk, err := toJWK(public); err == nil {
+		jwk.Kid, jwk.Alg, jwk.Use = k.keyID, k.method.Alg(), "sig"
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+// registeredClaims returns the standard claims of a token for subject
+// expiring at expiresAt
+func (k *SigningKeys) registeredClaims(subject string, expiresAt time.Time) jwt.RegisteredClaims {
+	claims := jwt.RegisteredClaims{
+		Issuer:    k.issuer,
+		Subject:   subject,
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+	}
+	if k.audience != "" {
+		claims.Audience = jwt.ClaimStrings{k.audience}
+	}
+	return claims
+}
+
+// parse verifies tokenString into claims, checking the signature, expiry
+// within the leeway, and the issuer and audience when configured
+func (k *SigningKeys) parse(tokenString string, claims jwt.Claims) (*jwt.Token, error) {
+	opts := []jwt.ParserOption{jwt.WithLeeway(k.leeway), jwt.WithIssuedAt()}
+	if k.issuer != "" {
+		opts = append(opts, jwt.WithIssuer(k.issuer))
+	}
+	if k.audience != "" {
+		opts = append(opts, jwt.WithAudience(k.audience))
+	}
+	return jwt.ParseWithClaims(tokenString, claims, k.keyFunc, opts...)
+}
+
+// sign returns claims as a signed token
+func (k *SigningKeys) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(k.method, claims)
+	if k.keyID != "" {
+		token.Header["kid"] = k.keyID
+	}
+	return token.SignedString(k.signKey)
+}
+
+// keyFunc returns the key a token is verified with, rejecting tokens signed
+// with any other algorithm
+func (k *SigningKeys) keyFunc(token *jwt.Token) (interface{}, error) {
+	if token.Method.Alg() != k.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	if k.jwks != nil {
+		kid, _ := token.Header["kid"].(string)
+		return k.jwks.key(kid)
+	}
+	return k.verifyKey, nil
+}
@@ -0,0 +1,249 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// keyFiles holds the PEM files of a generated key pair
+type keyFiles struct {
+	private string
+	public  string
+}
+
+// writeKeyPair generates a key for algorithm and writes both halves to a
+// temporary directory
+func writeKeyPair(t *testing.T, algorithm string) keyFiles {
+	t.Helper()
+	var key crypto.Signer
+	var err error
+	if algorithm == AlgRS256 {
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+	} else {
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	}
+	if err != nil {
+		t.Fatalf("generating a key: %v", err)
+	}
+
+	private, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("encoding the private key: %v", err)
+	}
+	public, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		t.Fatalf("encoding the public key: %v", err)
+	}
+
+	dir := t.TempDir()
+	files := keyFiles{private: filepath.Join(dir, "private.pem"), public: filepath.Join(dir, "public.pem")}
+	for path, block := range map[string]*pem.Block{
+		files.private: {Type: "PRIVATE KEY", Bytes: private},
+		files.public:  {Type: "PUBLIC KEY", Bytes: public},
+	} {
+		if err := os.WriteFile(path, pem.EncodeToMemory(block), 0o600); err != nil {
+			t.Fatalf("writing %s: %v", path, err)
+		}
+	}
+	return files
+}
+
+// newKeys loads cfg's keys, failing the test on error
+func newKeys(t *testing.T, cfg SigningConfig) *SigningKeys {
+	t.Helper()
+	keys, err := NewSigningKeys(cfg)
+	if err != nil {
+		t.Fatalf("NewSigningKeys: %v", err)
+	}
+	return keys
+}
+
+// signedToken returns a token for a user, valid for an hour, signed by keys
+func signedToken(t *testing.T, keys *SigningKeys) string {
+	t.Helper()
+	return signedTokenExpiring(t, keys, time.Now().Add(time.Hour))
+}
+
+// signedTokenExpiring returns a token for a user expiring at expiresAt,
+// signed by keys
+func signedTokenExpiring(t *testing.T, keys *SigningKeys, expiresAt time.Time) string {
+	t.Helper()
+	token, err := keys.sign(&Claims{Email: "user@example.com", RegisteredClaims: keys.registeredClaims("user", expiresAt)})
+	if err != nil {
+		t.Fatalf("signing: %v", err)
+	}
+	return token
+}
+
+func TestAsymmetricSigning(t *testing.T) {
+	for _, algorithm := range []string{AlgRS256, AlgES256} {
+		t.Run(algorithm, func(t *testing.T) {
+			signer := writeKeyPair(t, algorithm)
+			other := writeKeyPair(t, algorithm)
+			signing := newKeys(t, SigningConfig{Algorithm: algorithm, PrivateKeyFile: signer.private, Issuer: "fowergram"})
+			token := signedToken(t, signing)
+
+			// The signer's JWKS publishes its public key under the token's kid
+			jwks := signing.JWKS()
+			if len(jwks.Keys) != 1 || jwks.Keys[0].Alg != algorithm || jwks.Keys[0].Kid == "" {
+				t.Fatalf("JWKS = %+v, want the signing key", jwks)
+			}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				json.NewEncoder(w).Encode(jwks)
+			}))
+			t.Cleanup(server.Close)
+
+			tests := []struct {
+				name    string
+				keys    *SigningKeys
+				token   string
+				wantErr bool
+			}{
+				{name: "own key", keys: signing, token: token},
+				{
+					name:  "public key file",
+					keys:  newKeys(t, SigningConfig{Algorithm: algorithm, PrivateKeyFile: other.private, PublicKeyFile: signer.public, Issuer: "fowergram"}),
+					token: token,
+				},
+				{
+					name:  "JWKS URL",
+					keys:  newKeys(t, SigningConfig{Algorithm: algorithm, PrivateKeyFile: other.private, JWKSURL: server.URL, Issuer: "fowergram"}),
+					token: token,
+				},
+				{
+					name:    "another key",
+					keys:    newKeys(t, SigningConfig{Algorithm: algorithm, PrivateKeyFile: other.private, Issuer: "fowergram"}),
+					token:   token,
+					wantErr: true,
+				},
+				{
+					name:    "another issuer",
+					keys:    newKeys(t, SigningConfig{Algorithm: algorithm, PrivateKeyFile: signer.private, Issuer: "elsewhere"}),
+					token:   token,
+					wantErr: true,
+				},
+				{
+					name:    "HS256 token",
+					keys:    signing,
+					token:   signedToken(t, NewHMACKeys("shared secret")),
+					wantErr: true,
+				},
+			}
+
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					claims := &Claims{}
+					_, err := tt.keys.parse(tt.token, claims)
+					if (err != nil) != tt.wantErr {
+						t.Fatalf("parse() error = %v, want error %v", err, tt.wantErr)
+					}
+					if err == nil && claims.Email != "user@example.com" {
+						t.Errorf("claims = %+v, want the signed ones", claims)
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestSigningKeysJWKS(t *testing.T) {
+	if jwks := NewHMACKeys("secret").JWKS(); len(jwks.Keys) != 0 {
+		t.Errorf("HS256 JWKS = %+v, want no keys published", jwks)
+	}
+
+	files := writeKeyPair(t, AlgRS256)
+	tests := []struct {
+		name    string
+		keyID   string
+		wantKid func(jwk JWK) string
+	}{
+		{name: "thumbprint kid", wantKid: func(jwk JWK) string {
+			key, err := jwk.publicKey()
+			if err != nil {
+				t.Fatalf("decoding the JWK: %v", err)
+			}
+			kid, err := thumbprint(key)
+			if err != nil {
+				t.Fatalf("thumbprint: %v", err)
+			}
+			return kid
+		}},
+		{name: "configured kid", keyID: "2024-05", wantKid: func(JWK) string { return "2024-05" }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := newKeys(t, SigningConfig{Algorithm: AlgRS256, PrivateKeyFile: files.private, KeyID: tt.keyID})
+			jwks := keys.JWKS()
+			if len(jwks.Keys) != 1 {
+				t.Fatalf("JWKS = %+v, want one key", jwks)
+			}
+			jwk := jwks.Keys[0]
+			if jwk.Kty != "RSA" || jwk.Use != "sig" || jwk.Kid != tt.wantKid(jwk) {
+				t.Errorf("JWK = %+v, want an RSA signing key with kid %s", jwk, tt.wantKid(jwk))
+			}
+		})
+	}
+
+	if _, err := NewSigningKeys(SigningConfig{Algorithm: "none"}); err == nil {
+		t.Errorf("NewSigningKeys accepted the none algorithm")
+	}
+}
+
+func TestClaimsValidation(t *testing.T) {
+	const secret = "shared secret"
+	verifier := SigningConfig{Secret: secret, Issuer: "fowergram", Audience: "fowergram-api", Leeway: 30 * time.Second}
+	withLeeway := func(leeway time.Duration) SigningConfig {
+		cfg := verifier
+		cfg.Leeway = leeway
+		return cfg
+	}
+
+	tests := []struct {
+		name      string
+		issuer    string
+		audience  string
+		expiresIn time.Duration
+		verifier  SigningConfig
+		wantErr   error
+	}{
+		{name: "matching claims", issuer: "fowergram", audience: "fowergram-api", expiresIn: time.Minute, verifier: verifier},
+		{name: "wrong audience", issuer: "fowergram", audience: "admin-api", expiresIn: time.Minute, verifier: verifier, wantErr: jwt.ErrTokenInvalidAudience},
+		{name: "no audience", issuer: "fowergram", expiresIn: time.Minute, verifier: verifier, wantErr: jwt.ErrTokenRequiredClaimMissing},
+		{name: "wrong issuer", issuer: "elsewhere", audience: "fowergram-api", expiresIn: time.Minute, verifier: verifier, wantErr: jwt.ErrTokenInvalidIssuer},
+		{name: "expired within the leeway", issuer: "fowergram", audience: "fowergram-api", expiresIn: -10 * time.Second, verifier: verifier},
+		{name: "expired past the leeway", issuer: "fowergram", audience: "fowergram-api", expiresIn: -time.Minute, verifier: verifier, wantErr: jwt.ErrTokenExpired},
+		{name: "expired without leeway", issuer: "fowergram", audience: "fowergram-api", expiresIn: -10 * time.Second, verifier: withLeeway(0), wantErr: jwt.ErrTokenExpired},
+		{name: "unchecked claims", issuer: "elsewhere", audience: "admin-api", expiresIn: time.Minute, verifier: SigningConfig{Secret: secret}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer := newKeys(t, SigningConfig{Secret: secret, Issuer: tt.issuer, Audience: tt.audience})
+			token := signedTokenExpiring(t, signer, time.Now().Add(tt.expiresIn))
+
+			_, err := newKeys(t, tt.verifier).parse(token, &Claims{})
+			if tt.wantErr == nil && err != nil {
+				t.Errorf("parse() error = %v, want the token accepted", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("parse() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
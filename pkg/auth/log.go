@@ -0,0 +1,13 @@
+package auth
+
+import "fowergram-backend/pkg/logger"
+
+// LogFields returns the fields to log u by: its ID, username and email, the
+// email masked when maskEmail is set. The password hash is never included.
+func (u *User) LogFields(maskEmail bool) []interface{} {
+	email := u.Email
+	if maskEmail {
+		email = logger.MaskEmail(email)
+	}
+	return []interface{}{"user_id", u.ID, "username", u.Username, "email", email}
+}
@@ -0,0 +1,27 @@
+package auth
+
+// Username limits shared by signup, profile edits and @-mentions. Usernames
+// are ASCII letters and digits.
+const (
+	MinUsernameLength = 3
+	MaxUsernameLength = 50
+)
+
+// IsValidUsername reports whether username is within the username limits
+// and made of letters and digits only
+func IsValidUsername(username string) bool {
+	if len(username) < MinUsernameLength || len(username) > MaxUsernameLength {
+		return false
+	}
+	for i := 0; i < len(username); i++ {
+		if !IsUsernameByte(username[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsUsernameByte reports whether c may appear in a username
+func IsUsernameByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
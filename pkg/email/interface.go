@@ -10,6 +10,9 @@ type EmailService interface {
 	// SendPasswordResetEmail sends a password reset link
 	SendPasswordResetEmail(ctx context.Context, to, token string) error
 
+	// SendEmailChangeEmail sends a link confirming a new email address
+	SendEmailChangeEmail(ctx context.Context, to, token string) error
+
 	// Close closes any resources used by the email service
 	Close() error
 }
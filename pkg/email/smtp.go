@@ -95,6 +95,42 @@ func (s *SMTPEmailService) SendPasswordResetEmail(ctx context.Context, to, token
 	return s.sendEmail(to, subject, body)
 }
 
+// SendEmailChangeEmail sends a link confirming a new email address
+func (s *SMTPEmailService) SendEmailChangeEmail(ctx context.Context, to, token string) error {
+	subject := "Confirm your new email"
+	confirmLink := fmt.Sprintf("%s/confirm-email-change?token=%s", s.config.BaseURL, token)
+
+	// HTML template for email change confirmation
+	tmpl := `
+	<!DOCTYPE html>
+	<html>
+	<head>
+		<title>Confirm your new email</title>
+	</head>
+	<body>
+		<h2>Email Change Request</h2>
+		<p>You asked to use this address for your Fowergram account. Click the link below to confirm:</p>
+		<p><a href="{{.Link}}">Confirm Email</a></p>
+		<p>This link will expire in 24 hours. Your current email stays active until you confirm.</p>
+		<p>If you didn't request this change, you can safely ignore this email.</p>
+	</body>
+	</html>
+	`
+
+	data := struct {
+		Link string
+	}{
+		Link: confirmLink,
+	}
+
+	body, err := s.renderTemplate(tmpl, data)
+	if err != nil {
+		return fmt.Errorf("failed to render email template: %w", err)
+	}
+
+	return s.sendEmail(to, subject, body)
+}
+
 // sendEmail sends an email using SMTP
 func (s *SMTPEmailService) sendEmail(to, subject, body string) error {
 	from := fmt.Sprintf("%s <%s>", s.config.FromName, s.config.FromEmail)
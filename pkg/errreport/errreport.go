@@ -0,0 +1,64 @@
+// Package errreport sends errors that need a developer's attention, such as
+// panics and 5xx responses, to an error tracker
+package errreport
+
+import (
+	"context"
+	"errors"
+)
+
+// Event is an error with the request it happened in
+type Event struct {
+	Err       error
+	Panic     bool   // Err was recovered from a panic
+	Method    string // Request method and path, such as GET /api/v1/posts/123
+	Path      string
+	Route     string // Route pattern the request matched, such as /api/v1/posts/:id
+	RequestID string
+	UserID    string // Empty for anonymous requests
+
+	// Fields are the log fields the error was raised with, such as
+	// "post_id", id
+	Fields []interface{}
+}
+
+// Reporter sends events to an error tracker. Capture must be safe for
+// concurrent use and must not block on the network.
+type Reporter interface {
+	Capture(event Event)
+
+	// Flush waits until the captured events are sent or ctx is done
+	Flush(ctx context.Context) error
+}
+
+// Nop returns a Reporter that drops every event, for when no error tracker
+// is configured
+func Nop() Reporter {
+	return nopReporter{}
+}
+
+type nopReporter struct{}
+
+func (nopReporter) Capture(Event)               {}
+func (nopReporter) Flush(context.Context) error { return nil }
+
+// reportedError marks an error already captured, so the error handler
+// rendering it further up doesn't capture it again
+type reportedError struct {
+	error
+}
+
+func (e reportedError) Unwrap() error {
+	return e.error
+}
+
+// MarkReported wraps err to tell Reported it was captured
+func MarkReported(err error) error {
+	return reportedError{err}
+}
+
+// Reported reports whether err, or an error it wraps, was marked by MarkReported
+func Reported(err error) bool {
+	var reported reportedError
+	return errors.As(err, &reported)
+}
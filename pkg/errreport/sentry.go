@@ -0,0 +1,95 @@
+package errreport
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"fowergram-backend/pkg/logger"
+
+	"github.com/getsentry/sentry-go"
+)
+
+// SentryConfig configures reporting to Sentry
+type SentryConfig struct {
+	DSN         string
+	Environment string
+	Release     string
+
+	// RedactKeys are field names whose values are sent as [REDACTED],
+	// besides logger.DefaultRedactedKeys
+	RedactKeys []string
+}
+
+// sentryReporter captures events with a Sentry client
+type sentryReporter struct {
+	client     *sentry.Client
+	redactKeys []string
+}
+
+// NewSentry creates a Reporter sending events to the Sentry project of the DSN
+func NewSentry(cfg SentryConfig) (Reporter, error) {
+	client, err := sentry.NewClient(sentry.ClientOptions{
+		Dsn:         cfg.DSN,
+		Environment: cfg.Environment,
+		Release:     cfg.Release,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Sentry client: %w", err)
+	}
+	return &sentryReporter{client: client, redactKeys: cfg.RedactKeys}, nil
+}
+
+// Capture sends event in the background. The stack trace attached is the
+// caller's, so panics must be captured from the deferred function that
+// recovered them, which still runs on the panicking stack.
+func (r *sentryReporter) Capture(event Event) {
+	scope := sentry.NewScope()
+	scope.SetTag("route", event.Route)
+	scope.SetTag("request_id", event.RequestID)
+	if event.UserID != "" {
+		scope.SetUser(sentry.User{ID: event.UserID})
+	}
+	scope.SetContext("request", sentry.Context{
+		"method": event.Method,
+		"path":   event.Path,
+	})
+	if len(event.Fields) > 0 {
+		scope.SetExtras(fieldsMap(logger.RedactFields(event.Fields, r.redactKeys...)))
+	}
+
+	level := sentry.LevelError
+	if event.Panic {
+		level = sentry.LevelFatal
+	}
+	scope.SetLevel(level)
+
+	sentry.NewHub(r.client, scope).CaptureException(event.Err)
+}
+
+// Flush waits until the captured events are sent or ctx is done
+func (r *sentryReporter) Flush(ctx context.Context) error {
+	timeout := 5 * time.Second
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+	if !r.client.Flush(timeout) {
+		return fmt.Errorf("failed to send all error reports within %s", timeout)
+	}
+	return nil
+}
+
+// fieldsMap turns alternating keys and values into a map. Entries that
+// aren't a string key, such as zap fields, are skipped.
+func fieldsMap(keysAndValues []interface{}) map[string]interface{} {
+	fields := make(map[string]interface{}, len(keysAndValues)/2)
+	for i := 0; i < len(keysAndValues); i++ {
+		key, ok := keysAndValues[i].(string)
+		if !ok || i+1 == len(keysAndValues) {
+			continue
+		}
+		fields[key] = fmt.Sprint(keysAndValues[i+1])
+		i++
+	}
+	return fields
+}
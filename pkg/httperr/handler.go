@@ -0,0 +1,193 @@
+package httperr
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"strings"
+
+	"fowergram-backend/pkg/auth"
+	"fowergram-backend/pkg/errreport"
+	"fowergram-backend/pkg/logger"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
+)
+
+// RequestIDKey is the Locals key the request ID middleware stores IDs under,
+// where logger.WithContext finds them
+const RequestIDKey = logger.RequestIDKey
+
+// internalErrorMessage is all clients learn about errors that aren't an
+// AppError, auth.AuthError or fiber.Error
+const internalErrorMessage = "Internal server error"
+
+// authErrorStatus maps auth.AuthError codes to HTTP statuses; unlisted codes are 400
+var authErrorStatus = map[string]int{
+	auth.ErrInvalidCredentials.Code: fiber.StatusUnauthorized,
+	auth.ErrUnauthorized.Code:       fiber.StatusUnauthorized,
+	auth.ErrSessionExpired.Code:     fiber.StatusUnauthorized,
+	auth.ErrTokenRevoked.Code:       fiber.StatusUnauthorized,
+	auth.ErrInvalidToken.Code:       fiber.StatusUnauthorized,
+	auth.ErrInvalidResetToken.Code:  fiber.StatusBadRequest,
+	auth.ErrEmailNotVerified.Code:   fiber.StatusForbidden,
+	auth.ErrAccountDeactivated.Code: fiber.StatusForbidden,
+	auth.ErrUserNotFound.Code:       fiber.StatusNotFound,
+	auth.ErrSessionNotFound.Code:    fiber.StatusNotFound,
+	auth.ErrUserExists.Code:         fiber.StatusConflict,
+	auth.ErrEmailTaken.Code:         fiber.StatusConflict,
+	"EMAIL_ALREADY_VERIFIED":        fiber.StatusConflict,
+}
+
+// RequestID returns middleware that gives every request an ID, taken from
+// the X-Request-ID header when the caller sends one, and echoes it back
+func RequestID() fiber.Handler {
+	return requestid.New(requestid.Config{
+		ContextKey: RequestIDKey,
+	})
+}
+
+// Recover returns middleware turning panics further down into 500 errors.
+// Each panic is reported as it is recovered, while its stack is at hand,
+// and logged with the stack by Handler.
+func Recover(reporter errreport.Reporter) fiber.Handler {
+	return func(c *fiber.Ctx) (err error) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+
+			panicErr, ok := recovered.(error)
+			if !ok {
+				panicErr = fmt.Errorf("%v", recovered)
+			}
+			panicErr = fmt.Errorf("panic: %w", panicErr)
+
+			event := requestEvent(c, panicErr)
+			event.Panic = true
+			reporter.Capture(event)
+
+			err = Internal(internalErrorMessage, errreport.MarkReported(panicErr), "stack", string(debug.Stack()))
+		}()
+
+		return c.Next()
+	}
+}
+
+// Handler renders errors returned by handlers and middleware as a Response.
+// Auth errors keep their code and get the matching status. 5xx errors are
+// logged with their cause and answered with the request ID only, so
+// internal details such as SQL errors never reach clients; 500s are also
+// sent to reporter, unless Recover already did. Other 5xx errors, such as
+// a 503 for a missing dependency, are expected and only logged.
+func Handler(log logger.Logger, reporter errreport.Reporter) fiber.ErrorHandler {
+	return func(c *fiber.Ctx, err error) error {
+		var appErr *AppError
+		if errors.As(err, &appErr) {
+			if appErr.Status >= fiber.StatusInternalServerError {
+				return internalError(c, log, reporter, appErr.Status, appErr.Code, appErr.Message, appErr.Err, appErr.LogFields...)
+			}
+			return c.Status(appErr.Status).JSON(Response{
+				Error:   appErr.Message,
+				Code:    appErr.Code,
+				Details: appErr.Details,
+			})
+		}
+
+		var authErr *auth.AuthError
+		if errors.As(err, &authErr) {
+			status, ok := authErrorStatus[authErr.Code]
+			if !ok {
+				status = fiber.StatusBadRequest
+			}
+			return c.Status(status).JSON(Response{
+				Error: authErr.Message,
+				Code:  authErr.Code,
+			})
+		}
+
+		var fiberErr *fiber.Error
+		if errors.As(err, &fiberErr) {
+			message := fiberErr.Message
+			if fiberErr.Code == fiber.StatusRequestEntityTooLarge {
+				message = "Request body is too large"
+			}
+			return c.Status(fiberErr.Code).JSON(Response{
+				Error: message,
+				Code:  statusCode(fiberErr.Code),
+			})
+		}
+
+		return internalError(c, log, reporter, fiber.StatusInternalServerError, CodeInternal, internalErrorMessage, err)
+	}
+}
+
+// internalError logs a server-side failure under the request and user IDs,
+// reports it if it's a 500, and answers with message and the request ID
+func internalError(c *fiber.Ctx, log logger.Logger, reporter errreport.Reporter, status int, code, message string, err error, keysAndValues ...interface{}) error {
+	requestID, _ := c.Locals(RequestIDKey).(string)
+	log.WithContext(c.Context()).Error(message, append(keysAndValues, "method", c.Method(), "path", c.Path(), "error", err)...)
+
+	if status == fiber.StatusInternalServerError && !errreport.Reported(err) {
+		cause := err
+		if cause == nil {
+			cause = errors.New(message)
+		}
+		event := requestEvent(c, cause)
+		event.Fields = keysAndValues
+		reporter.Capture(event)
+	}
+
+	return c.Status(status).JSON(Response{
+		Error:     message,
+		Code:      code,
+		RequestID: requestID,
+	})
+}
+
+// requestEvent describes err for the error reporter with the request it
+// happened in. Strings read from the request are copied, as reporters send
+// events after its buffers are reused.
+func requestEvent(c *fiber.Ctx, err error) errreport.Event {
+	requestID, _ := c.Locals(RequestIDKey).(string)
+	userID, _ := c.Locals(logger.UserIDKey).(string)
+	return errreport.Event{
+		Err:       err,
+		Method:    c.Method(),
+		Path:      strings.Clone(c.Path()),
+		Route:     c.Route().Path,
+		RequestID: strings.Clone(requestID),
+		UserID:    userID,
+	}
+}
+
+// statusCode picks the error code for a bare HTTP status
+func statusCode(status int) string {
+	switch status {
+	case fiber.StatusBadRequest:
+		return CodeBadRequest
+	case fiber.StatusUnauthorized:
+		return CodeUnauthenticated
+	case fiber.StatusForbidden:
+		return CodeForbidden
+	case fiber.StatusNotFound:
+		return CodeNotFound
+	case fiber.StatusMethodNotAllowed:
+		return CodeMethodNotAllowed
+	case fiber.StatusConflict:
+		return CodeConflict
+	case fiber.StatusRequestEntityTooLarge:
+		return CodeBodyTooLarge
+	case fiber.StatusUnsupportedMediaType:
+		return CodeUnsupportedMedia
+	case fiber.StatusTooManyRequests:
+		return CodeTooManyRequests
+	case fiber.StatusServiceUnavailable:
+		return CodeUnavailable
+	}
+	if status >= 500 {
+		return CodeInternal
+	}
+	return CodeBadRequest
+}
@@ -0,0 +1,225 @@
+package httperr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+
+	"fowergram-backend/pkg/auth"
+	"fowergram-backend/pkg/errreport"
+	"fowergram-backend/pkg/logger"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// fakeReporter records the events captured
+type fakeReporter struct {
+	mu     sync.Mutex
+	events []errreport.Event
+}
+
+func (r *fakeReporter) Capture(event errreport.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *fakeReporter) Flush(ctx context.Context) error {
+	return nil
+}
+
+func (r *fakeReporter) captured() []errreport.Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]errreport.Event(nil), r.events...)
+}
+
+func TestReporting(t *testing.T) {
+	errCause := errors.New("connection reset")
+	const (
+		requestID = "req-123"
+		userID    = "user-456"
+	)
+
+	tests := []struct {
+		name       string
+		handler    fiber.Handler
+		wantStatus int
+		wantReport bool
+		wantPanic  bool
+		wantErr    error         // Wrapped by the captured error, if set
+		wantFields []interface{} // Fields of the captured event
+	}{
+		{
+			name:       "panic with an error",
+			handler:    func(c *fiber.Ctx) error { panic(errCause) },
+			wantStatus: fiber.StatusInternalServerError,
+			wantReport: true,
+			wantPanic:  true,
+			wantErr:    errCause,
+		},
+		{
+			name:       "panic with a value",
+			handler:    func(c *fiber.Ctx) error { panic("index out of range") },
+			wantStatus: fiber.StatusInternalServerError,
+			wantReport: true,
+			wantPanic:  true,
+		},
+		{
+			name: "internal error",
+			handler: func(c *fiber.Ctx) error {
+				return Internal("Failed to get post", errCause, "post_id", "789")
+			},
+			wantStatus: fiber.StatusInternalServerError,
+			wantReport: true,
+			wantErr:    errCause,
+			wantFields: []interface{}{"post_id", "789"},
+		},
+		{
+			name:       "unknown error",
+			handler:    func(c *fiber.Ctx) error { return errCause },
+			wantStatus: fiber.StatusInternalServerError,
+			wantReport: true,
+			wantErr:    errCause,
+		},
+		{
+			name:       "unavailable dependency",
+			handler:    func(c *fiber.Ctx) error { return Unavailable("Search is unavailable") },
+			wantStatus: fiber.StatusServiceUnavailable,
+		},
+		{
+			name:       "client error",
+			handler:    func(c *fiber.Ctx) error { return NotFound("Post not found") },
+			wantStatus: fiber.StatusNotFound,
+		},
+		{
+			name:       "auth error",
+			handler:    func(c *fiber.Ctx) error { return auth.ErrUserNotFound },
+			wantStatus: fiber.StatusNotFound,
+		},
+		{
+			name:       "fiber error",
+			handler:    func(c *fiber.Ctx) error { return fiber.ErrRequestEntityTooLarge },
+			wantStatus: fiber.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reporter := &fakeReporter{}
+			app := fiber.New(fiber.Config{ErrorHandler: Handler(logger.NewZapLogger(), reporter)})
+			app.Use(RequestID())
+			app.Use(func(c *fiber.Ctx) error {
+				c.Locals(logger.UserIDKey, userID)
+				return c.Next()
+			})
+			app.Use(Recover(reporter))
+			app.Get("/posts/:id", tt.handler)
+
+			req := httptest.NewRequest(http.MethodGet, "/posts/789", nil)
+			req.Header.Set(fiber.HeaderXRequestID, requestID)
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+
+			events := reporter.captured()
+			if !tt.wantReport {
+				if len(events) != 0 {
+					t.Errorf("captured %d events, want none: %+v", len(events), events)
+				}
+				return
+			}
+			if len(events) != 1 {
+				t.Fatalf("captured %d events, want 1: %+v", len(events), events)
+			}
+
+			event := events[0]
+			if event.Panic != tt.wantPanic {
+				t.Errorf("Panic = %v, want %v", event.Panic, tt.wantPanic)
+			}
+			if event.Err == nil || (tt.wantErr != nil && !errors.Is(event.Err, tt.wantErr)) {
+				t.Errorf("Err = %v, want an error wrapping %v", event.Err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(event.Fields, tt.wantFields) {
+				t.Errorf("Fields = %v, want %v", event.Fields, tt.wantFields)
+			}
+			want := errreport.Event{Method: http.MethodGet, Path: "/posts/789", Route: "/posts/:id", RequestID: requestID, UserID: userID}
+			got := errreport.Event{Method: event.Method, Path: event.Path, Route: event.Route, RequestID: event.RequestID, UserID: event.UserID}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("request = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestAuthErrorStatus(t *testing.T) {
+	tests := []struct {
+		err        *auth.AuthError
+		wantStatus int
+	}{
+		{err: auth.ErrInvalidCredentials, wantStatus: fiber.StatusUnauthorized},
+		{err: auth.ErrUnauthorized, wantStatus: fiber.StatusUnauthorized},
+		{err: auth.ErrSessionExpired, wantStatus: fiber.StatusUnauthorized},
+		{err: auth.ErrTokenRevoked, wantStatus: fiber.StatusUnauthorized},
+		{err: auth.ErrInvalidToken, wantStatus: fiber.StatusUnauthorized},
+		{err: auth.ErrInvalidResetToken, wantStatus: fiber.StatusBadRequest},
+		{err: auth.ErrEmailNotVerified, wantStatus: fiber.StatusForbidden},
+		{err: auth.ErrAccountDeactivated, wantStatus: fiber.StatusForbidden},
+		{err: auth.ErrUserNotFound, wantStatus: fiber.StatusNotFound},
+		{err: auth.ErrSessionNotFound, wantStatus: fiber.StatusNotFound},
+		{err: auth.ErrUserExists, wantStatus: fiber.StatusConflict},
+		{err: auth.ErrEmailTaken, wantStatus: fiber.StatusConflict},
+		{err: &auth.AuthError{Code: "EMAIL_ALREADY_VERIFIED", Message: "Email already verified"}, wantStatus: fiber.StatusConflict},
+		{err: auth.ErrIncorrectPassword, wantStatus: fiber.StatusBadRequest},
+		{err: &auth.AuthError{Code: "SOMETHING_NEW", Message: "Something new"}, wantStatus: fiber.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		for _, wrapped := range []bool{false, true} {
+			name := tt.err.Code
+			var err error = tt.err
+			if wrapped {
+				name += " wrapped"
+				err = fmt.Errorf("signing in: %w", tt.err)
+			}
+
+			t.Run(name, func(t *testing.T) {
+				reporter := &fakeReporter{}
+				app := fiber.New(fiber.Config{ErrorHandler: Handler(logger.NewZapLogger(), reporter)})
+				app.Post("/auth/signin", func(c *fiber.Ctx) error { return err })
+
+				resp, err := app.Test(httptest.NewRequest(http.MethodPost, "/auth/signin", nil), -1)
+				if err != nil {
+					t.Fatalf("request failed: %v", err)
+				}
+				defer resp.Body.Close()
+
+				if resp.StatusCode != tt.wantStatus {
+					t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+				}
+				var body Response
+				if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+					t.Fatalf("decoding the error: %v", err)
+				}
+				want := Response{Error: tt.err.Message, Code: tt.err.Code}
+				if body != want {
+					t.Errorf("body = %+v, want %+v", body, want)
+				}
+				if events := reporter.captured(); len(events) != 0 {
+					t.Errorf("reported %d events for a client error", len(events))
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package httperr
+
+import "github.com/gofiber/fiber/v2"
+
+// Error codes returned in Response.Code
+const (
+	CodeInvalidBody      = "INVALID_BODY"
+	CodeValidationFailed = "VALIDATION_FAILED"
+	CodeBodyTooLarge     = "BODY_TOO_LARGE"
+	CodeUnsupportedMedia = "UNSUPPORTED_MEDIA_TYPE"
+	CodeUnauthenticated  = "UNAUTHENTICATED"
+	CodeForbidden        = "FORBIDDEN"
+	CodeCSRFTokenInvalid = "CSRF_TOKEN_INVALID"
+	CodeNotPostOwner     = "NOT_POST_OWNER"
+	CodeNotFound         = "NOT_FOUND"
+	CodeMethodNotAllowed = "METHOD_NOT_ALLOWED"
+	CodeConflict         = "CONFLICT"
+	CodeIdempotencyReuse = "IDEMPOTENCY_KEY_REUSED"
+	CodeTooManyRequests  = "TOO_MANY_REQUESTS"
+	CodeBadRequest       = "BAD_REQUEST"
+	CodeCommentsDisabled = "COMMENTS_DISABLED"
+	CodeContentRejected  = "CONTENT_REJECTED"
+	CodeUnavailable      = "SERVICE_UNAVAILABLE"
+	CodeInternal         = "INTERNAL_ERROR"
+)
+
+// Response is the JSON body of every error response
+type Response struct {
+	Error     string      `json:"error"`
+	Code      string      `json:"code,omitempty"` // Stable machine-readable error code
+	Details   interface{} `json:"details,omitempty"`
+	RequestID string      `json:"request_id,omitempty"` // Set on 5xx responses to find the logged cause
+}
+
+// AppError is an error that handlers and middleware return to have Handler
+// answer with a specific status, code and message. Err and LogFields are
+// logged for 5xx errors and never sent to clients.
+type AppError struct {
+	Status  int
+	Code    string
+	Message string
+	Details interface{}
+
+	Err       error
+	LogFields []interface{}
+}
+
+func (e *AppError) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *AppError) Unwrap() error {
+	return e.Err
+}
+
+// New returns an error answered with status, code and message
+func New(status int, code, message string) *AppError {
+	return &AppError{
+		Status:  status,
+		Code:    code,
+		Message: message,
+	}
+}
+
+// WithDetails sets the details sent along with the message
+func (e *AppError) WithDetails(details interface{}) *AppError {
+	e.Details = details
+	return e
+}
+
+// BadRequest returns a 400 error
+func BadRequest(message string) *AppError {
+	return New(fiber.StatusBadRequest, CodeBadRequest, message)
+}
+
+// Unauthenticated returns a 401 error
+func Unauthenticated(message string) *AppError {
+	return New(fiber.StatusUnauthorized, CodeUnauthenticated, message)
+}
+
+// Forbidden returns a 403 error
+func Forbidden(message string) *AppError {
+	return New(fiber.StatusForbidden, CodeForbidden, message)
+}
+
+// NotFound returns a 404 error
+func NotFound(message string) *AppError {
+	return New(fiber.StatusNotFound, CodeNotFound, message)
+}
+
+// Conflict returns a 409 error
+func Conflict(message string) *AppError {
+	return New(fiber.StatusConflict, CodeConflict, message)
+}
+
+// Unavailable returns a 503 error
+func Unavailable(message string) *AppError {
+	return New(fiber.StatusServiceUnavailable, CodeUnavailable, message)
+}
+
+// Internal returns a 500 error for an unexpected failure. Clients only see
+// message; err and keysAndValues are logged under the request ID.
+func Internal(message string, err error, keysAndValues ...interface{}) *AppError {
+	return &AppError{
+		Status:    fiber.StatusInternalServerError,
+		Code:      CodeInternal,
+		Message:   message,
+		Err:       err,
+		LogFields: keysAndValues,
+	}
+}
@@ -1,10 +1,27 @@
 package logger
 
 import (
+	"context"
+	"fmt"
+	"slices"
+
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
+// Context keys WithContext reads request fields from. Fiber's Locals are
+// values of the request's context, so middleware sets them with c.Locals.
+const (
+	RequestIDKey = "requestid"
+	UserIDKey    = "user_id"
+)
+
+// Log encodings
+const (
+	FormatJSON    = "json"
+	FormatConsole = "console"
+)
+
 // Logger interface
 type Logger interface {
 	Debug(msg string, keysAndValues ...interface{})
@@ -12,50 +29,118 @@ type Logger interface {
 	Warn(msg string, keysAndValues ...interface{})
 	Error(msg string, keysAndValues ...interface{})
 	Fatal(msg string, keysAndValues ...interface{})
+
+	// With returns a child logger adding keysAndValues to every entry
+	With(keysAndValues ...interface{}) Logger
+
+	// WithContext returns a child logger adding the request ID and user ID
+	// found in ctx to every entry
+	WithContext(ctx context.Context) Logger
+
 	Sync() error
 }
 
-// ZapLogger implements Logger using zap
+// ZapLogger implements Logger using zap. Values of the fields named in
+// DefaultRedactedKeys, and of any added to New, are logged as Redacted.
 type ZapLogger struct {
-	logger *zap.SugaredLogger
+	logger   *zap.SugaredLogger
+	redactor redactor
 }
 
-// NewZapLogger creates a new zap logger
+// NewZapLogger creates a new zap logger writing JSON at info level
 func NewZapLogger() Logger {
+	logger, _ := New("info", FormatJSON)
+	return logger
+}
+
+// New creates a zap logger writing entries at level and above ("debug",
+// "info", "warn" or "error") in format, FormatJSON or FormatConsole, and
+// redacting redactKeys besides DefaultRedactedKeys
+func New(level, format string, redactKeys ...string) (Logger, error) {
+	lvl, err := zapcore.ParseLevel(level)
+	if err != nil {
+		return nil, fmt.Errorf("invalid log level %q", level)
+	}
+	if format != FormatJSON && format != FormatConsole {
+		return nil, fmt.Errorf("invalid log format %q, want %s or %s", format, FormatJSON, FormatConsole)
+	}
+
 	config := zap.NewProductionConfig()
-	config.Level = zap.NewAtomicLevelAt(zapcore.InfoLevel)
+	config.Level = zap.NewAtomicLevelAt(lvl)
+	config.Encoding = format
 	config.EncoderConfig.TimeKey = "timestamp"
 	config.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	if format == FormatConsole {
+		config.EncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+	}
 
-	logger, _ := config.Build()
+	logger, err := config.Build()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build logger: %w", err)
+	}
+	return &ZapLogger{
+		logger:   logger.Sugar(),
+		redactor: newRedactor(slices.Concat(DefaultRedactedKeys, redactKeys)),
+	}, nil
+}
+
+// NewWithCore creates a logger writing to core, such as an observer in tests,
+// redacting redactKeys besides DefaultRedactedKeys
+func NewWithCore(core zapcore.Core, redactKeys ...string) Logger {
 	return &ZapLogger{
-		logger: logger.Sugar(),
+		logger:   zap.New(core).Sugar(),
+		redactor: newRedactor(slices.Concat(DefaultRedactedKeys, redactKeys)),
 	}
 }
 
 // Debug logs a debug message
 func (l *ZapLogger) Debug(msg string, keysAndValues ...interface{}) {
-	l.logger.Debugw(msg, keysAndValues...)
+	l.logger.Debugw(msg, l.redactor.redact(keysAndValues)...)
 }
 
 // Info logs an info message
 func (l *ZapLogger) Info(msg string, keysAndValues ...interface{}) {
-	l.logger.Infow(msg, keysAndValues...)
+	l.logger.Infow(msg, l.redactor.redact(keysAndValues)...)
 }
 
 // Warn logs a warning message
 func (l *ZapLogger) Warn(msg string, keysAndValues ...interface{}) {
-	l.logger.Warnw(msg, keysAndValues...)
+	l.logger.Warnw(msg, l.redactor.redact(keysAndValues)...)
 }
 
 // Error logs an error message
 func (l *ZapLogger) Error(msg string, keysAndValues ...interface{}) {
-	l.logger.Errorw(msg, keysAndValues...)
+	l.logger.Errorw(msg, l.redactor.redact(keysAndValues)...)
 }
 
 // Fatal logs a fatal message and exits
 func (l *ZapLogger) Fatal(msg string, keysAndValues ...interface{}) {
-	l.logger.Fatalw(msg, keysAndValues...)
+	l.logger.Fatalw(msg, l.redactor.redact(keysAndValues)...)
+}
+
+// With returns a child logger adding keysAndValues to every entry
+func (l *ZapLogger) With(keysAndValues ...interface{}) Logger {
+	return &ZapLogger{
+		logger:   l.logger.With(l.redactor.redact(keysAndValues)...),
+		redactor: l.redactor,
+	}
+}
+
+// WithContext returns a child logger adding the request ID and user ID
+// found in ctx to every entry, or l when ctx has neither
+func (l *ZapLogger) WithContext(ctx context.Context) Logger {
+	var fields []interface{}
+	if requestID, ok := ctx.Value(RequestIDKey).(string); ok && requestID != "" {
+		fields = append(fields, "request_id", requestID)
+	}
+	if userID := ctx.Value(UserIDKey); userID != nil {
+		fields = append(fields, "user_id", userID)
+	}
+
+	if len(fields) == 0 {
+		return l
+	}
+	return l.With(fields...)
 }
 
 // Sync flushes any buffered log entries
@@ -0,0 +1,154 @@
+package logger
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// observe redirects l's entries to an observer, keeping the level l was
+// built with
+func observe(t *testing.T, l Logger) *observer.ObservedLogs {
+	t.Helper()
+	zl, ok := l.(*ZapLogger)
+	if !ok {
+		t.Fatalf("logger is a %T, want *ZapLogger", l)
+	}
+	core, logs := observer.New(zl.logger.Desugar().Core())
+	zl.logger = zap.New(core).Sugar()
+	return logs
+}
+
+func TestNewLevel(t *testing.T) {
+	tests := []struct {
+		level string
+		want  []zapcore.Level // Of the entries logged at every level
+	}{
+		{level: "debug", want: []zapcore.Level{zapcore.DebugLevel, zapcore.InfoLevel, zapcore.WarnLevel, zapcore.ErrorLevel}},
+		{level: "info", want: []zapcore.Level{zapcore.InfoLevel, zapcore.WarnLevel, zapcore.ErrorLevel}},
+		{level: "WARN", want: []zapcore.Level{zapcore.WarnLevel, zapcore.ErrorLevel}},
+		{level: "error", want: []zapcore.Level{zapcore.ErrorLevel}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.level, func(t *testing.T) {
+			l, err := New(tt.level, FormatJSON)
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			logs := observe(t, l)
+
+			l.Debug("debug")
+			l.Info("info")
+			l.Warn("warn")
+			l.Error("error")
+
+			var got []zapcore.Level
+			for _, entry := range logs.All() {
+				got = append(got, entry.Level)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("logged levels %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("logged levels %v, want %v", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestNewRejectsInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		level  string
+		format string
+	}{
+		{name: "unknown level", level: "verbose", format: FormatJSON},
+		{name: "unknown format", level: "info", format: "xml"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if l, err := New(tt.level, tt.format); err == nil {
+				t.Errorf("New(%q, %q) = %v, want an error", tt.level, tt.format, l)
+			}
+		})
+	}
+
+	if _, err := New("info", FormatConsole); err != nil {
+		t.Errorf("New with the console format: %v", err)
+	}
+}
+
+func TestWithContext(t *testing.T) {
+	userID := uuid.New()
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want map[string]interface{}
+	}{
+		{
+			name: "request and user",
+			ctx:  context.WithValue(context.WithValue(context.Background(), RequestIDKey, "req-1"), UserIDKey, userID.String()),
+			want: map[string]interface{}{"request_id": "req-1", "user_id": userID.String(), "component": "posts"},
+		},
+		{
+			name: "request only",
+			ctx:  context.WithValue(context.Background(), RequestIDKey, "req-2"),
+			want: map[string]interface{}{"request_id": "req-2", "component": "posts"},
+		},
+		{
+			name: "empty request ID",
+			ctx:  context.WithValue(context.Background(), RequestIDKey, ""),
+			want: map[string]interface{}{"component": "posts"},
+		},
+		{
+			name: "neither",
+			ctx:  context.Background(),
+			want: map[string]interface{}{"component": "posts"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			core, logs := observer.New(zapcore.DebugLevel)
+			parent := NewWithCore(core).With("component", "posts")
+
+			// Fields carry over to every entry of the child, and not back to
+			// the parent
+			child := parent.WithContext(tt.ctx)
+			child.Info("first")
+			child.Warn("second", "post_id", "p-1")
+			parent.Info("parent")
+
+			entries := logs.All()
+			if len(entries) != 3 {
+				t.Fatalf("logged %d entries, want 3", len(entries))
+			}
+			for _, entry := range entries[:2] {
+				fields := entry.ContextMap()
+				delete(fields, "post_id")
+				if len(fields) != len(tt.want) {
+					t.Errorf("%q logged %v, want %v", entry.Message, fields, tt.want)
+					continue
+				}
+				for key, value := range tt.want {
+					if fields[key] != value {
+						t.Errorf("%q logged %s = %v, want %v", entry.Message, key, fields[key], value)
+					}
+				}
+			}
+			if fields := entries[2].ContextMap(); len(fields) != 1 || fields["component"] != "posts" {
+				t.Errorf("parent logged %v, want only its own fields", fields)
+			}
+		})
+	}
+}
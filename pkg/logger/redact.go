@@ -0,0 +1,156 @@
+package logger
+
+import (
+	"maps"
+	"slices"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Redacted replaces the values of sensitive fields
+const Redacted = "[REDACTED]"
+
+// DefaultRedactedKeys are the field names whose values are never logged,
+// compared case-insensitively and also as keys of map values. A field is
+// also redacted when its name ends in one of them after an underscore, such
+// as new_password or client_secret.
+var DefaultRedactedKeys = []string{"password", "token", "authorization", "refresh_token", "access_token", "secret", "cookie"}
+
+// RedactFields returns keysAndValues with the values of DefaultRedactedKeys
+// and redactKeys replaced by Redacted, for fields sent elsewhere than the log
+func RedactFields(keysAndValues []interface{}, redactKeys ...string) []interface{} {
+	return newRedactor(slices.Concat(DefaultRedactedKeys, redactKeys)).redact(keysAndValues)
+}
+
+// redactor masks the values of sensitive fields
+type redactor map[string]struct{}
+
+func newRedactor(keys []string) redactor {
+	r := make(redactor, len(keys))
+	for _, key := range keys {
+		if key = normalizeKey(key); key != "" {
+			r[key] = struct{}{}
+		}
+	}
+	return r
+}
+
+// redact returns keysAndValues with the values of sensitive keys replaced by
+// Redacted, also within map values, copying it only when one is found
+func (r redactor) redact(keysAndValues []interface{}) []interface{} {
+	copied := false
+	set := func(i int, value interface{}) {
+		if !copied {
+			keysAndValues = append([]interface{}(nil), keysAndValues...)
+			copied = true
+		}
+		keysAndValues[i] = value
+	}
+
+	for i := 0; i < len(keysAndValues); i++ {
+		switch key := keysAndValues[i].(type) {
+		case zap.Field:
+			// A field is a key and value in one
+			if r.sensitive(key.Key) {
+				set(i, zap.String(key.Key, Redacted))
+			} else if key.Type == zapcore.ReflectType {
+				if value, ok := r.redactValue(key.Interface); ok {
+					set(i, zap.Any(key.Key, value))
+				}
+			}
+		case string:
+			if i+1 < len(keysAndValues) {
+				if r.sensitive(key) {
+					set(i+1, Redacted)
+				} else if value, ok := r.redactValue(keysAndValues[i+1]); ok {
+					set(i+1, value)
+				}
+			}
+			i++
+		default:
+			i++
+		}
+	}
+	return keysAndValues
+}
+
+// redactValue returns a copy of a map, or a slice of maps, with the values
+// of sensitive keys replaced by Redacted at any depth. It reports false, and
+// leaves other values alone, when there is nothing to redact.
+func (r redactor) redactValue(value interface{}) (interface{}, bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		var redacted map[string]interface{}
+		for key, nested := range v {
+			replacement, ok := interface{}(Redacted), r.sensitive(key)
+			if !ok {
+				replacement, ok = r.redactValue(nested)
+			}
+			if !ok {
+				continue
+			}
+			if redacted == nil {
+				redacted = maps.Clone(v)
+			}
+			redacted[key] = replacement
+		}
+		return redacted, redacted != nil
+	case map[string]string:
+		var redacted map[string]string
+		for key := range v {
+			if !r.sensitive(key) {
+				continue
+			}
+			if redacted == nil {
+				redacted = maps.Clone(v)
+			}
+			redacted[key] = Redacted
+		}
+		return redacted, redacted != nil
+	case []interface{}:
+		var redacted []interface{}
+		for i, nested := range v {
+			replacement, ok := r.redactValue(nested)
+			if !ok {
+				continue
+			}
+			if redacted == nil {
+				redacted = slices.Clone(v)
+			}
+			redacted[i] = replacement
+		}
+		return redacted, redacted != nil
+	}
+	return value, false
+}
+
+func (r redactor) sensitive(key string) bool {
+	key = normalizeKey(key)
+	if _, ok := r[key]; ok {
+		return true
+	}
+	for redacted := range r {
+		if strings.HasSuffix(key, "_"+redacted) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeKey lowercases key and treats - and _ alike, so X-Refresh-Token
+// and refresh_token match
+func normalizeKey(key string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(key)), "-", "_")
+}
+
+// MaskEmail keeps the first letter and the domain of an email address, so
+// logs can tell accounts apart without holding the address
+func MaskEmail(email string) string {
+	local, domain, ok := strings.Cut(email, "@")
+	if !ok || local == "" {
+		return Redacted
+	}
+	return local[:1] + "***@" + domain
+}
@@ -0,0 +1,119 @@
+package logger
+
+import (
+	"reflect"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRedaction(t *testing.T) {
+	tests := []struct {
+		name          string
+		redactKeys    []string
+		keysAndValues []interface{}
+		want          map[string]interface{}
+	}{
+		{
+			name:          "password",
+			keysAndValues: []interface{}{"password", "hunter2", "email", "alice@example.com"},
+			want:          map[string]interface{}{"password": Redacted, "email": "alice@example.com"},
+		},
+		{
+			name:          "tokens",
+			keysAndValues: []interface{}{"token", "t", "refresh_token", "r", "access_token", "a"},
+			want:          map[string]interface{}{"token": Redacted, "refresh_token": Redacted, "access_token": Redacted},
+		},
+		{
+			name:          "authorization and cookie headers",
+			keysAndValues: []interface{}{"Authorization", "Bearer t", "Cookie", "session=1", "Set-Cookie", "session=2"},
+			want:          map[string]interface{}{"Authorization": Redacted, "Cookie": Redacted, "Set-Cookie": Redacted},
+		},
+		{
+			name:          "case-insensitive keys",
+			keysAndValues: []interface{}{"PASSWORD", "p", "X-Refresh-Token", "r", "Client_Secret", "s"},
+			want:          map[string]interface{}{"PASSWORD": Redacted, "X-Refresh-Token": Redacted, "Client_Secret": Redacted},
+		},
+		{
+			name:          "suffixed keys",
+			keysAndValues: []interface{}{"new_password", "p", "tokens", 3},
+			want:          map[string]interface{}{"new_password": Redacted, "tokens": int64(3)},
+		},
+		{
+			name: "nested maps",
+			keysAndValues: []interface{}{"body", map[string]interface{}{
+				"email":    "alice@example.com",
+				"Password": "hunter2",
+				"device":   map[string]interface{}{"name": "phone", "push_token": "pt"},
+				"headers":  map[string]string{"Authorization": "Bearer t", "Accept": "*/*"},
+				"sessions": []interface{}{map[string]interface{}{"id": "s1", "refresh_token": "r"}},
+			}},
+			want: map[string]interface{}{"body": map[string]interface{}{
+				"email":    "alice@example.com",
+				"Password": Redacted,
+				"device":   map[string]interface{}{"name": "phone", "push_token": Redacted},
+				"headers":  map[string]string{"Authorization": Redacted, "Accept": "*/*"},
+				"sessions": []interface{}{map[string]interface{}{"id": "s1", "refresh_token": Redacted}},
+			}},
+		},
+		{
+			name: "zap fields",
+			keysAndValues: []interface{}{
+				zap.String("token", "t"),
+				zap.Any("body", map[string]interface{}{"cookie": "c", "page": 2}),
+				zap.String("path", "/signin"),
+			},
+			want: map[string]interface{}{
+				"token": Redacted,
+				"body":  map[string]interface{}{"cookie": Redacted, "page": 2},
+				"path":  "/signin",
+			},
+		},
+		{
+			name:          "configured keys",
+			redactKeys:    []string{"ssn"},
+			keysAndValues: []interface{}{"SSN", "123", "name", "alice"},
+			want:          map[string]interface{}{"SSN": Redacted, "name": "alice"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			core, logs := observer.New(zapcore.DebugLevel)
+			l := NewWithCore(core, tt.redactKeys...)
+
+			original := append([]interface{}(nil), tt.keysAndValues...)
+			l.Info("entry", tt.keysAndValues...)
+			l.With(tt.keysAndValues...).Warn("child")
+
+			entries := logs.All()
+			if len(entries) != 2 {
+				t.Fatalf("logged %d entries, want 2", len(entries))
+			}
+			for _, entry := range entries {
+				if got := entry.ContextMap(); !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("%q logged %v, want %v", entry.Message, got, tt.want)
+				}
+			}
+
+			// The caller's fields, maps included, are left as they were
+			if !reflect.DeepEqual(tt.keysAndValues, original) {
+				t.Errorf("fields changed to %v, want %v", tt.keysAndValues, original)
+			}
+		})
+	}
+}
+
+func TestRedactFields(t *testing.T) {
+	fields := []interface{}{"password", "hunter2", "headers", map[string]string{"Cookie": "c"}, "status", 401}
+	got := RedactFields(fields)
+	want := []interface{}{"password", Redacted, "headers", map[string]string{"Cookie": Redacted}, "status", 401}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("RedactFields = %v, want %v", got, want)
+	}
+	if fields[1] != "hunter2" || fields[3].(map[string]string)["Cookie"] != "c" {
+		t.Errorf("RedactFields changed its argument to %v", fields)
+	}
+}
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"time"
+
+	"fowergram-backend/pkg/auth"
+	"fowergram-backend/pkg/httperr"
+	"fowergram-backend/pkg/logger"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// AccessLogConfig holds access log configuration
+type AccessLogConfig struct {
+	Logger    logger.Logger
+	SkipPaths []string // Paths not logged, such as probes scraped every few seconds
+}
+
+// AccessLogger logs one line per request
+type AccessLogger struct {
+	logger logger.Logger
+	skip   map[string]struct{}
+}
+
+// NewAccessLogger creates a new access logger
+func NewAccessLogger(config AccessLogConfig) *AccessLogger {
+	skip := make(map[string]struct{}, len(config.SkipPaths))
+	for _, path := range config.SkipPaths {
+		skip[path] = struct{}{}
+	}
+
+	return &AccessLogger{
+		logger: config.Logger,
+		skip:   skip,
+	}
+}
+
+// Middleware returns the access log middleware. 5xx responses are logged as
+// errors, 4xx as warnings and the rest as info.
+func (a *AccessLogger) Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if _, ok := a.skip[c.Path()]; ok {
+			return c.Next()
+		}
+
+		start := time.Now()
+		if err := renderError(c, c.Next()); err != nil {
+			return err
+		}
+
+		status := c.Response().StatusCode()
+		fields := []interface{}{
+			"method", c.Method(),
+			"path", c.Path(),
+			"route", routePattern(c),
+			"status", status,
+			"latency", time.Since(start),
+			"bytes", len(c.Response().Body()),
+			"ip", ClientIP(c),
+		}
+		if requestID, ok := c.Locals(httperr.RequestIDKey).(string); ok {
+			fields = append(fields, "request_id", requestID)
+		}
+		if user, ok := c.Locals("user").(*auth.User); ok {
+			fields = append(fields, "user_id", user.ID)
+		}
+
+		switch {
+		case status >= fiber.StatusInternalServerError:
+			a.logger.Error("Request handled", fields...)
+		case status >= fiber.StatusBadRequest:
+			a.logger.Warn("Request handled", fields...)
+		default:
+			a.logger.Info("Request handled", fields...)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"fowergram-backend/pkg/auth"
+	"fowergram-backend/pkg/errreport"
+	"fowergram-backend/pkg/httperr"
+	"fowergram-backend/pkg/logger"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestAccessLog(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	alice := &auth.User{ID: uuid.New(), Username: "alice"}
+
+	app := fiber.New(fiber.Config{ErrorHandler: httperr.Handler(logger.NewZapLogger(), errreport.Nop())})
+	app.Use(httperr.RequestID())
+	app.Use(NewAccessLogger(AccessLogConfig{
+		Logger:    logger.NewWithCore(core),
+		SkipPaths: []string{"/health"},
+	}).Middleware())
+	app.Use(func(c *fiber.Ctx) error {
+		if c.Get(fiber.HeaderAuthorization) == "Bearer alice-token" {
+			c.Locals("user", alice)
+		}
+		return c.Next()
+	})
+	app.Get("/posts/:id", func(c *fiber.Ctx) error {
+		return c.SendString("post " + c.Params("id"))
+	})
+	app.Post("/posts/:id/like", func(c *fiber.Ctx) error {
+		return httperr.NotFound("Post not found")
+	})
+	app.Get("/fail", func(c *fiber.Ctx) error {
+		return httperr.Internal("Failed to load", errors.New("connection reset"))
+	})
+	app.Get("/health", func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		signedIn   bool
+		wantLevel  zapcore.Level
+		wantRoute  string
+		wantStatus int
+	}{
+		{name: "success", method: http.MethodGet, path: "/posts/42", signedIn: true, wantLevel: zapcore.InfoLevel, wantRoute: "/posts/:id", wantStatus: fiber.StatusOK},
+		{name: "client error", method: http.MethodPost, path: "/posts/42/like", signedIn: true, wantLevel: zapcore.WarnLevel, wantRoute: "/posts/:id/like", wantStatus: fiber.StatusNotFound},
+		{name: "server error", method: http.MethodGet, path: "/fail", wantLevel: zapcore.ErrorLevel, wantRoute: "/fail", wantStatus: fiber.StatusInternalServerError},
+		{name: "skipped path", method: http.MethodGet, path: "/health"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs.TakeAll()
+
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.signedIn {
+				req.Header.Set(fiber.HeaderAuthorization, "Bearer alice-token")
+			}
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			resp.Body.Close()
+
+			entries := logs.TakeAll()
+			if tt.wantStatus == 0 {
+				if len(entries) != 0 {
+					t.Errorf("logged %d entries for a skipped path, want none", len(entries))
+				}
+				return
+			}
+			if len(entries) != 1 {
+				t.Fatalf("logged %d entries, want 1", len(entries))
+			}
+			entry := entries[0]
+			if entry.Level != tt.wantLevel {
+				t.Errorf("level = %s, want %s", entry.Level, tt.wantLevel)
+			}
+
+			fields := entry.ContextMap()
+			want := map[string]interface{}{
+				"method": tt.method,
+				"path":   tt.path,
+				"route":  tt.wantRoute,
+				"status": int64(tt.wantStatus),
+			}
+			for key, value := range want {
+				if fields[key] != value {
+					t.Errorf("%s = %v (%T), want %v", key, fields[key], fields[key], value)
+				}
+			}
+			if latency, ok := fields["latency"].(time.Duration); !ok || latency <= 0 {
+				t.Errorf("latency = %v, want a positive duration", fields["latency"])
+			}
+			if requestID, _ := fields["request_id"].(string); requestID == "" || requestID != resp.Header.Get(fiber.HeaderXRequestID) {
+				t.Errorf("request_id = %v, want the response's %q", fields["request_id"], resp.Header.Get(fiber.HeaderXRequestID))
+			}
+			userID, logged := fields["user_id"]
+			if tt.signedIn && userID != alice.ID.String() {
+				t.Errorf("user_id = %v, want %s", userID, alice.ID)
+			}
+			if !tt.signedIn && logged {
+				t.Errorf("user_id = %v, want none when signed out", userID)
+			}
+		})
+	}
+}
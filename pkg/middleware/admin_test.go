@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"fowergram-backend/pkg/auth"
+	"fowergram-backend/pkg/errreport"
+	"fowergram-backend/pkg/httperr"
+	"fowergram-backend/pkg/logger"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// fakeAuthenticate stands in for the JWT middleware: "Bearer admin" and
+// "Bearer member" sign in a user with and without the admin role
+func fakeAuthenticate(c *fiber.Ctx) error {
+	user := &auth.User{ID: uuid.New()}
+	switch c.Get(fiber.HeaderAuthorization) {
+	case "Bearer admin":
+		user.Roles = []string{auth.RoleAdmin}
+	case "Bearer member":
+	default:
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid token")
+	}
+	c.Locals("user", user)
+	return c.Next()
+}
+
+func TestAdminAuth(t *testing.T) {
+	const token = "a-long-admin-token-for-operators-scripts"
+
+	tests := []struct {
+		name          string
+		token         string // Configured admin token
+		authorization string
+		adminToken    string
+		wantStatus    int
+	}{
+		{name: "admin user", authorization: "Bearer admin", wantStatus: fiber.StatusOK},
+		{name: "user without the admin role", authorization: "Bearer member", wantStatus: fiber.StatusForbidden},
+		{name: "no credentials", wantStatus: fiber.StatusUnauthorized},
+		{name: "invalid session", authorization: "Bearer expired", wantStatus: fiber.StatusUnauthorized},
+		{
+			name:       "admin token ignored when the fallback is disabled",
+			adminToken: token,
+			wantStatus: fiber.StatusUnauthorized,
+		},
+		{
+			name:          "admin token ignored for a member when the fallback is disabled",
+			authorization: "Bearer member",
+			adminToken:    token,
+			wantStatus:    fiber.StatusForbidden,
+		},
+		{
+			name:       "admin token when the fallback is enabled",
+			token:      token,
+			adminToken: token,
+			wantStatus: fiber.StatusOK,
+		},
+		{
+			name:       "wrong admin token",
+			token:      token,
+			adminToken: strings.Repeat("x", len(token)),
+			wantStatus: fiber.StatusUnauthorized,
+		},
+		{
+			name:          "wrong admin token with an admin session",
+			token:         token,
+			authorization: "Bearer admin",
+			adminToken:    "wrong",
+			wantStatus:    fiber.StatusUnauthorized,
+		},
+		{
+			name:          "admin user when the fallback is enabled",
+			token:         token,
+			authorization: "Bearer admin",
+			wantStatus:    fiber.StatusOK,
+		},
+		{
+			name:          "member when the fallback is enabled",
+			token:         token,
+			authorization: "Bearer member",
+			wantStatus:    fiber.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New(fiber.Config{ErrorHandler: httperr.Handler(logger.NewZapLogger(), errreport.Nop())})
+			app.Use(AuthenticateAdmin(fakeAuthenticate, tt.token))
+			app.Use(RequireAdmin())
+			app.Get("/admin", func(c *fiber.Ctx) error {
+				return c.SendStatus(fiber.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+			if tt.authorization != "" {
+				req.Header.Set(fiber.HeaderAuthorization, tt.authorization)
+			}
+			if tt.adminToken != "" {
+				req.Header.Set(AdminTokenHeader, tt.adminToken)
+			}
+
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
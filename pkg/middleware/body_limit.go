@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"io"
+	"strings"
+
+	"fowergram-backend/pkg/httperr"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// BodyLimit returns middleware capping request bodies at limit bytes, or at
+// the limit overrides sets for a path, such as a larger one for uploads.
+// Bodies declaring a larger Content-Length are answered with 413 before any
+// of them is read; chunked bodies are read up to the limit at most. Either
+// way the rest of the body is left unread, so the connection is closed after
+// the 413 rather than kept alive with it misread as the next request.
+//
+// It relies on fiber.Config.StreamRequestBody: bodies larger than the
+// server's BodyLimit are then streamed to handlers instead of being buffered
+// or rejected, so BodyLimit, not the server, enforces the limits.
+func BodyLimit(limit int, overrides map[string]int) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		max := limit
+		if override, ok := overrides[strings.TrimSuffix(c.Path(), "/")]; ok {
+			max = override
+		}
+
+		// -1 is a chunked body, -2 a request without one
+		length := c.Request().Header.ContentLength()
+		if length > max {
+			return tooLarge(c)
+		}
+		if length != -1 {
+			return c.Next()
+		}
+
+		if stream := c.Request().BodyStream(); stream != nil {
+			body, err := io.ReadAll(io.LimitReader(stream, int64(max)+1))
+			if err != nil {
+				return httperr.BadRequest("Failed to read request body")
+			}
+			if len(body) > max {
+				return tooLarge(c)
+			}
+			c.Request().SetBody(body)
+		}
+		return c.Next()
+	}
+}
+
+// tooLarge rejects a body over the limit, closing the connection since the
+// rest of the body is never read
+func tooLarge(c *fiber.Ctx) error {
+	c.Context().SetConnectionClose()
+	return fiber.ErrRequestEntityTooLarge
+}
@@ -0,0 +1,158 @@
+package middleware
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"fowergram-backend/pkg/errreport"
+	"fowergram-backend/pkg/httperr"
+	"fowergram-backend/pkg/logger"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// bodyLimitServer serves the body length of POSTs to /echo and /upload
+// under BodyLimit, configured as cmd/server configures the app, with a
+// larger limit for /upload. It returns the server's address.
+func bodyLimitServer(t *testing.T, limit, uploadLimit int) string {
+	t.Helper()
+	app := fiber.New(fiber.Config{
+		BodyLimit:             limit,
+		StreamRequestBody:     true,
+		ErrorHandler:          httperr.Handler(logger.NewZapLogger(), errreport.Nop()),
+		DisableStartupMessage: true,
+	})
+	app.Use(BodyLimit(limit, map[string]int{"/upload": uploadLimit}))
+	length := func(c *fiber.Ctx) error {
+		return c.SendString(strconv.Itoa(len(c.Body())))
+	}
+	app.Post("/echo", length)
+	app.Post("/upload", length)
+
+	// Rejected bodies are left unread, which app.Test can't recover from,
+	// so requests go to a listening server
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening: %v", err)
+	}
+	go app.Listener(listener)
+	t.Cleanup(func() { app.ShutdownWithTimeout(time.Second) })
+	return listener.Addr().String()
+}
+
+func TestBodyLimit(t *testing.T) {
+	const limit, uploadLimit = 256, 1024
+	addr := bodyLimitServer(t, limit, uploadLimit)
+
+	// One client throughout, keeping connections alive between requests
+	client := &http.Client{Transport: &http.Transport{}}
+	t.Cleanup(client.CloseIdleConnections)
+
+	tests := []struct {
+		name       string
+		path       string
+		size       int
+		chunked    bool // Sent without a Content-Length
+		wantStatus int
+	}{
+		{name: "within the limit", path: "/echo", size: limit, wantStatus: fiber.StatusOK},
+		{name: "over the limit", path: "/echo", size: limit + 1, wantStatus: fiber.StatusRequestEntityTooLarge},
+		{name: "chunked within the limit", path: "/echo", size: limit, chunked: true, wantStatus: fiber.StatusOK},
+		{name: "chunked over the limit", path: "/echo", size: 4 * limit, chunked: true, wantStatus: fiber.StatusRequestEntityTooLarge},
+		{name: "upload over the default limit", path: "/upload", size: uploadLimit, wantStatus: fiber.StatusOK},
+		{name: "upload over its limit", path: "/upload", size: uploadLimit + 1, wantStatus: fiber.StatusRequestEntityTooLarge},
+		{name: "chunked upload over its limit", path: "/upload", size: uploadLimit + 1, chunked: true, wantStatus: fiber.StatusRequestEntityTooLarge},
+		{name: "after a rejected body", path: "/echo", size: 10, wantStatus: fiber.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, "http://"+addr+tt.path, strings.NewReader(strings.Repeat("a", tt.size)))
+			if err != nil {
+				t.Fatalf("NewRequest: %v", err)
+			}
+			if tt.chunked {
+				req.ContentLength = -1
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("reading the response: %v", err)
+			}
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantStatus == fiber.StatusOK {
+				if string(body) != strconv.Itoa(tt.size) {
+					t.Errorf("handler read %s bytes, want %d", body, tt.size)
+				}
+				return
+			}
+
+			var envelope struct {
+				Code string `json:"code"`
+			}
+			if err := json.Unmarshal(body, &envelope); err != nil || envelope.Code != httperr.CodeBodyTooLarge {
+				t.Errorf("body = %s, want the %s error envelope", body, httperr.CodeBodyTooLarge)
+			}
+			if !resp.Close {
+				t.Error("connection kept alive with the rejected body unread")
+			}
+		})
+	}
+}
+
+func TestBodyLimitClosesConnection(t *testing.T) {
+	const limit = 256
+	addr := bodyLimitServer(t, limit, limit)
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("dialing: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	// The oversized body is followed by a pipelined request, which must not
+	// be answered: the server can't tell where the unread body ends
+	oversized := strings.Repeat("a", 2*limit)
+	requests := "POST /echo HTTP/1.1\r\nHost: test\r\nContent-Length: " + strconv.Itoa(len(oversized)) + "\r\n\r\n" + oversized +
+		"POST /echo HTTP/1.1\r\nHost: test\r\nContent-Length: 2\r\n\r\nhi"
+	if _, err := io.WriteString(conn, requests); err != nil {
+		t.Fatalf("writing the requests: %v", err)
+	}
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatalf("reading the response: %v", err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != fiber.StatusRequestEntityTooLarge || !resp.Close {
+		t.Errorf("status = %d with Connection %q, want 413 and close", resp.StatusCode, resp.Header.Get(fiber.HeaderConnection))
+	}
+
+	if resp, err := http.ReadResponse(reader, nil); err == nil {
+		resp.Body.Close()
+		t.Errorf("answered %d after the rejected body, want the connection closed", resp.StatusCode)
+	} else if !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		var netErr net.Error
+		if !errors.As(err, &netErr) || netErr.Timeout() {
+			t.Errorf("reading after the 413: %v, want the connection closed", err)
+		}
+	}
+}
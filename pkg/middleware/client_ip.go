@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ClientIPKey is the Locals key RealIP stores the client IP under
+const ClientIPKey = "client_ip"
+
+// ParseTrustedProxies parses a list of proxy IPs and CIDR ranges
+func ParseTrustedProxies(entries []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if strings.Contains(entry, "/") {
+			prefix, err := netip.ParsePrefix(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy range %q: %w", entry, err)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+// RealIP returns middleware resolving the IP of the client behind the
+// trusted proxies, for ClientIP to return. Only a connection from a trusted
+// proxy has its X-Forwarded-For read, from the right: every proxy appends
+// the address it was reached from, so the first untrusted address is the
+// client. Anything left of it was sent by the client and is ignored, as it
+// could be spoofed.
+func RealIP(trusted []netip.Prefix) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Locals(ClientIPKey, clientIP(c, trusted))
+		return c.Next()
+	}
+}
+
+// ClientIP returns the client IP resolved by RealIP, or the address of the
+// connection when RealIP didn't run
+func ClientIP(c *fiber.Ctx) string {
+	if ip, ok := c.Locals(ClientIPKey).(string); ok {
+		return ip
+	}
+	return c.IP()
+}
+
+func clientIP(c *fiber.Ctx, trusted []netip.Prefix) string {
+	remote, ok := netip.AddrFromSlice(c.Context().RemoteIP())
+	if !ok {
+		return c.IP()
+	}
+	client := remote.Unmap()
+	if !isTrusted(client, trusted) {
+		return client.String()
+	}
+
+	var hops []string
+	for _, header := range c.Request().Header.PeekAll(fiber.HeaderXForwardedFor) {
+		hops = append(hops, strings.Split(string(header), ",")...)
+	}
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			// Stop at the nearest address known to be genuine
+			break
+		}
+		client = hop.Unmap()
+		if !isTrusted(client, trusted) {
+			break
+		}
+	}
+	return client.String()
+}
+
+func isTrusted(addr netip.Addr, trusted []netip.Prefix) bool {
+	for _, prefix := range trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestClientIP(t *testing.T) {
+	// app.Test connects from 0.0.0.0
+	const peer = "0.0.0.0"
+
+	tests := []struct {
+		name      string
+		trusted   []string
+		forwarded []string // X-Forwarded-For headers
+		want      string
+	}{
+		{
+			name:      "untrusted peer's header is ignored",
+			trusted:   []string{"10.0.0.0/8"},
+			forwarded: []string{"203.0.113.7"},
+			want:      peer,
+		},
+		{
+			name:      "no trusted proxies",
+			forwarded: []string{"203.0.113.7"},
+			want:      peer,
+		},
+		{
+			name:    "trusted peer without the header",
+			trusted: []string{peer},
+			want:    peer,
+		},
+		{
+			name:      "trusted peer",
+			trusted:   []string{peer},
+			forwarded: []string{"203.0.113.7"},
+			want:      "203.0.113.7",
+		},
+		{
+			name:      "chain of trusted proxies",
+			trusted:   []string{peer, "10.0.0.0/8"},
+			forwarded: []string{"203.0.113.7, 10.0.0.2, 10.1.2.3"},
+			want:      "203.0.113.7",
+		},
+		{
+			name:      "chain across several headers",
+			trusted:   []string{peer, "10.0.0.0/8"},
+			forwarded: []string{"203.0.113.7", "10.0.0.2"},
+			want:      "203.0.113.7",
+		},
+		{
+			name:      "addresses left of the client are spoofable",
+			trusted:   []string{peer, "10.0.0.0/8"},
+			forwarded: []string{"198.51.100.1, 203.0.113.7, 10.0.0.2"},
+			want:      "203.0.113.7",
+		},
+		{
+			name:      "malformed hop stops at the nearest genuine address",
+			trusted:   []string{peer, "10.0.0.0/8"},
+			forwarded: []string{"203.0.113.7, garbage, 10.0.0.2"},
+			want:      "10.0.0.2",
+		},
+		{
+			name:      "IPv6 client",
+			trusted:   []string{peer},
+			forwarded: []string{"2001:db8::1"},
+			want:      "2001:db8::1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trusted, err := ParseTrustedProxies(tt.trusted)
+			if err != nil {
+				t.Fatalf("ParseTrustedProxies: %v", err)
+			}
+
+			app := fiber.New()
+			app.Use(RealIP(trusted))
+			app.Get("/", func(c *fiber.Ctx) error {
+				return c.SendString(ClientIP(c))
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			for _, header := range tt.forwarded {
+				req.Header.Add(fiber.HeaderXForwardedFor, header)
+			}
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			defer resp.Body.Close()
+			got, _ := io.ReadAll(resp.Body)
+
+			if string(got) != tt.want {
+				t.Errorf("ClientIP = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []string
+		want    []string
+		wantErr bool
+	}{
+		{name: "addresses and ranges", entries: []string{"10.0.0.1", " 172.16.0.0/12 ", "::1"}, want: []string{"10.0.0.1/32", "172.16.0.0/12", "::1/128"}},
+		{name: "range is masked", entries: []string{"192.168.1.7/24"}, want: []string{"192.168.1.0/24"}},
+		{name: "IPv4-mapped address", entries: []string{"::ffff:10.0.0.1"}, want: []string{"10.0.0.1/32"}},
+		{name: "blank entries are skipped", entries: []string{"", " "}, want: []string{}},
+		{name: "invalid address", entries: []string{"proxy.internal"}, wantErr: true},
+		{name: "invalid range", entries: []string{"10.0.0.0/33"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefixes, err := ParseTrustedProxies(tt.entries)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTrustedProxies error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			got := make([]string, len(prefixes))
+			for i, prefix := range prefixes {
+				got[i] = prefix.String()
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("prefixes = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("prefixes = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"mime"
+	"strings"
+
+	"fowergram-backend/pkg/httperr"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequireJSON returns middleware answering 415 to requests whose body isn't
+// declared as application/json, so a form post is refused up front rather
+// than failing to parse in the handler. Requests without a body, such as a
+// like, pass, as do the exempt paths, such as the multipart uploads.
+func RequireJSON(exempt []string) fiber.Handler {
+	skip := make(map[string]bool, len(exempt))
+	for _, path := range exempt {
+		skip[path] = true
+	}
+
+	return func(c *fiber.Ctx) error {
+		// -1 is a chunked body, -2 a request without one
+		length := c.Request().Header.ContentLength()
+		if length == 0 || length == -2 || skip[strings.TrimSuffix(c.Path(), "/")] {
+			return c.Next()
+		}
+
+		mediaType, _, err := mime.ParseMediaType(c.Get(fiber.HeaderContentType))
+		if err != nil || mediaType != fiber.MIMEApplicationJSON {
+			return httperr.New(fiber.StatusUnsupportedMediaType, httperr.CodeUnsupportedMedia, "Content-Type must be application/json")
+		}
+		return c.Next()
+	}
+}
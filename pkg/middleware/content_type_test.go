@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"fowergram-backend/pkg/errreport"
+	"fowergram-backend/pkg/httperr"
+	"fowergram-backend/pkg/logger"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestRequireJSON(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: httperr.Handler(logger.NewZapLogger(), errreport.Nop())})
+	app.Use("/api", RequireJSON([]string{"/api/media"}))
+	accepted := func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusNoContent) }
+	app.Post("/api/posts", accepted)
+	app.Post("/api/media", accepted)
+	app.Post("/webhooks", accepted)
+
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+		body        string
+		chunked     bool // Sent without a Content-Length
+		wantStatus  int
+	}{
+		{name: "JSON", path: "/api/posts", contentType: "application/json", body: `{}`, wantStatus: fiber.StatusNoContent},
+		{name: "JSON with a charset", path: "/api/posts", contentType: "application/json; charset=utf-8", body: `{}`, wantStatus: fiber.StatusNoContent},
+		{name: "no body", path: "/api/posts", wantStatus: fiber.StatusNoContent},
+		{name: "plain text", path: "/api/posts", contentType: "text/plain", body: `{}`, wantStatus: fiber.StatusUnsupportedMediaType},
+		{name: "form", path: "/api/posts", contentType: "application/x-www-form-urlencoded", body: "title=Tulips", wantStatus: fiber.StatusUnsupportedMediaType},
+		{name: "no Content-Type", path: "/api/posts", body: `{}`, wantStatus: fiber.StatusUnsupportedMediaType},
+		{name: "chunked plain text", path: "/api/posts", contentType: "text/plain", body: `{}`, chunked: true, wantStatus: fiber.StatusUnsupportedMediaType},
+		{name: "exempt upload", path: "/api/media", contentType: "multipart/form-data; boundary=x", body: "--x--", wantStatus: fiber.StatusNoContent},
+		{name: "exempt upload with a trailing slash", path: "/api/media/", contentType: "multipart/form-data; boundary=x", body: "--x--", wantStatus: fiber.StatusNoContent},
+		{name: "outside the API", path: "/webhooks", contentType: "text/plain", body: "ping", wantStatus: fiber.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set(fiber.HeaderContentType, tt.contentType)
+			}
+			if tt.chunked {
+				req.ContentLength, req.TransferEncoding = -1, []string{"chunked"}
+			}
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantStatus != fiber.StatusUnsupportedMediaType {
+				return
+			}
+			var body httperr.Response
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("decoding the error: %v", err)
+			}
+			if body.Code != httperr.CodeUnsupportedMedia {
+				t.Errorf("code = %q, want %q", body.Code, httperr.CodeUnsupportedMedia)
+			}
+		})
+	}
+}
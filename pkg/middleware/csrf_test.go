@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"fowergram-backend/pkg/errreport"
+	"fowergram-backend/pkg/httperr"
+	"fowergram-backend/pkg/logger"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestCSRF(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: httperr.Handler(logger.NewZapLogger(), errreport.Nop())})
+	app.Use(CSRF("access_token", "refresh_token"))
+	app.All("/posts", func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusNoContent) })
+
+	tests := []struct {
+		name          string
+		method        string
+		authorization string
+		cookies       map[string]string
+		header        string // X-CSRF-Token
+		wantStatus    int
+	}{
+		{name: "read with cookies", method: http.MethodGet, cookies: map[string]string{"access_token": "a"}, wantStatus: fiber.StatusNoContent},
+		{
+			name:       "write echoing the token",
+			method:     http.MethodPost,
+			cookies:    map[string]string{"access_token": "a", CSRFCookie: "csrf"},
+			header:     "csrf",
+			wantStatus: fiber.StatusNoContent,
+		},
+		{
+			name:       "refresh cookie alone",
+			method:     http.MethodPost,
+			cookies:    map[string]string{"refresh_token": "r", CSRFCookie: "csrf"},
+			wantStatus: fiber.StatusForbidden,
+		},
+		{
+			name:       "write without the header",
+			method:     http.MethodDelete,
+			cookies:    map[string]string{"access_token": "a", CSRFCookie: "csrf"},
+			wantStatus: fiber.StatusForbidden,
+		},
+		{
+			name:       "write with another token",
+			method:     http.MethodPut,
+			cookies:    map[string]string{"access_token": "a", CSRFCookie: "csrf"},
+			header:     "guessed",
+			wantStatus: fiber.StatusForbidden,
+		},
+		{
+			name:       "write without the CSRF cookie",
+			method:     http.MethodPatch,
+			cookies:    map[string]string{"access_token": "a"},
+			wantStatus: fiber.StatusForbidden,
+		},
+		{
+			name:          "write with a bearer token",
+			method:        http.MethodPost,
+			authorization: "Bearer token",
+			cookies:       map[string]string{"access_token": "a"},
+			wantStatus:    fiber.StatusNoContent,
+		},
+		{
+			name:       "write without session cookies",
+			method:     http.MethodPost,
+			cookies:    map[string]string{"theme": "dark"},
+			wantStatus: fiber.StatusNoContent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/posts", nil)
+			for name, value := range tt.cookies {
+				req.AddCookie(&http.Cookie{Name: name, Value: value})
+			}
+			if tt.header != "" {
+				req.Header.Set(CSRFHeader, tt.header)
+			}
+			if tt.authorization != "" {
+				req.Header.Set(fiber.HeaderAuthorization, tt.authorization)
+			}
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantStatus != fiber.StatusForbidden {
+				return
+			}
+			var body httperr.Response
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("decoding the error: %v", err)
+			}
+			if body.Code != httperr.CodeCSRFTokenInvalid {
+				t.Errorf("code = %q, want %q", body.Code, httperr.CodeCSRFTokenInvalid)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"strings"
+	"time"
+
+	"fowergram-backend/pkg/httperr"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// IdempotencyKeyHeader is the request header carrying the client's idempotency key
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// maxIdempotencyKeyLength bounds the key to keep Redis keys reasonable
+const maxIdempotencyKeyLength = 255
+
+// IdempotencyConfig holds idempotency middleware configuration
+type IdempotencyConfig struct {
+	RedisClient *redis.Client
+	TTL         time.Duration             // How long a stored response is replayed; defaults to 24h
+	LockTimeout time.Duration             // How long an in-flight request holds its key; defaults to 1m
+	KeyPrefix   string                    // Redis key prefix; defaults to "idempotency"
+	KeyFunc     func(c *fiber.Ctx) string // Identifies the caller; requests without a caller are not deduplicated
+}
+
+// Idempotency replays the first response for requests repeated with the same Idempotency-Key
+type Idempotency struct {
+	config IdempotencyConfig
+}
+
+// storedResponse is the Redis record for a key; a record without a status is still in flight
+type storedResponse struct {
+	Method      string            `json:"method"`
+	Route       string            `json:"route"` // Route pattern below the mount the request came through
+	Params      map[string]string `json:"params,omitempty"`
+	BodyHash    string            `json:"body_hash"` // SHA-256 of the request body, to tell a retry from a different request
+	Status      int               `json:"status,omitempty"`
+	ContentType string            `json:"content_type,omitempty"`
+	Body        []byte            `json:"body,omitempty"`
+}
+
+// NewIdempotency creates a new idempotency middleware
+func NewIdempotency(config IdempotencyConfig) *Idempotency {
+	if config.TTL <= 0 {
+		config.TTL = 24 * time.Hour
+	}
+	if config.LockTimeout <= 0 {
+		config.LockTimeout = time.Minute
+	}
+	if config.KeyPrefix == "" {
+		config.KeyPrefix = "idempotency"
+	}
+	return &Idempotency{
+		config: config,
+	}
+}
+
+// Middleware returns the idempotency middleware for routes mounted under
+// mount. Records name routes relative to it, so a retry through another
+// mount of the same routes, such as an unversioned alias, is replayed.
+// Requests without the header pass through.
+func (i *Idempotency) Middleware(mount string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		idempotencyKey := c.Get(IdempotencyKeyHeader)
+		if idempotencyKey == "" {
+			return c.Next()
+		}
+
+		if len(idempotencyKey) > maxIdempotencyKeyLength {
+			return httperr.BadRequest("Idempotency key is too long")
+		}
+
+		caller := ""
+		if i.config.KeyFunc != nil {
+			caller = i.config.KeyFunc(c)
+		}
+		if caller == "" {
+			return c.Next()
+		}
+
+		ctx := c.Context()
+		key := fmt.Sprintf("%s:%s:%s", i.config.KeyPrefix, caller, idempotencyKey)
+		bodyHash := sha256.Sum256(c.Body())
+		pending := storedResponse{
+			Method:   c.Method(),
+			Route:    strings.TrimPrefix(c.Route().Path, mount),
+			Params:   c.AllParams(),
+			BodyHash: hex.EncodeToString(bodyHash[:]),
+		}
+
+		// Claim the key; only the first request gets to run the handler
+		marker, err := json.Marshal(pending)
+		if err != nil {
+			return httperr.Internal("Failed to encode idempotency record", err)
+		}
+		claimed, err := i.config.RedisClient.SetNX(ctx, key, marker, i.config.LockTimeout).Result()
+		if err != nil {
+			return httperr.Internal("Failed to claim idempotency key", err)
+		}
+
+		if !claimed {
+			return i.replay(c, key, pending)
+		}
+
+		// Error responses are stored like any other
+		if err := renderError(c, c.Next()); err != nil {
+			// Release the key so the client can retry
+			i.config.RedisClient.Del(ctx, key)
+			return err
+		}
+
+		status := c.Response().StatusCode()
+		if status >= 500 {
+			i.config.RedisClient.Del(ctx, key)
+			return nil
+		}
+
+		pending.Status = status
+		pending.ContentType = string(c.Response().Header.ContentType())
+		pending.Body = append([]byte(nil), c.Response().Body()...)
+
+		record, err := json.Marshal(pending)
+		if err != nil {
+			i.config.RedisClient.Del(ctx, key)
+			return nil
+		}
+		if err := i.config.RedisClient.Set(ctx, key, record, i.config.TTL).Err(); err != nil {
+			i.config.RedisClient.Del(ctx, key)
+		}
+
+		return nil
+	}
+}
+
+// replay answers a repeated request from the stored record for its key
+func (i *Idempotency) replay(c *fiber.Ctx, key string, request storedResponse) error {
+	data, err := i.config.RedisClient.Get(c.Context(), key).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			// The original request finished and released its key in the meantime
+			return httperr.Conflict("A request with this idempotency key was just processed, please retry")
+		}
+		return httperr.Internal("Failed to load idempotency record", err)
+	}
+
+	var stored storedResponse
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return httperr.Internal("Failed to decode idempotency record", err)
+	}
+
+	if stored.Method != request.Method || stored.Route != request.Route ||
+		!maps.Equal(stored.Params, request.Params) || stored.BodyHash != request.BodyHash {
+		return httperr.New(fiber.StatusConflict, httperr.CodeIdempotencyReuse, "Idempotency key was already used for a different request")
+	}
+
+	if stored.Status == 0 {
+		return httperr.Conflict("A request with this idempotency key is already in progress")
+	}
+
+	c.Set("Idempotent-Replayed", "true")
+	if stored.ContentType != "" {
+		c.Set(fiber.HeaderContentType, stored.ContentType)
+	}
+	return c.Status(stored.Status).Send(stored.Body)
+}
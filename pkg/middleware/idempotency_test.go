@@ -0,0 +1,240 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"fowergram-backend/pkg/errreport"
+	"fowergram-backend/pkg/httperr"
+	"fowergram-backend/pkg/logger"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// idempotencyApp serves POST /posts/:id behind the idempotency middleware
+// under /api/v1 and its alias /api, counting how many times the handler
+// runs. The caller is the X-User header, and the handler waits on release
+// when it isn't nil.
+func idempotencyApp(t *testing.T, release <-chan struct{}) (*fiber.App, *atomic.Int32) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	idempotency := NewIdempotency(IdempotencyConfig{
+		RedisClient: client,
+		KeyFunc:     func(c *fiber.Ctx) string { return c.Get("X-User") },
+	})
+
+	var calls atomic.Int32
+	app := fiber.New(fiber.Config{ErrorHandler: httperr.Handler(logger.NewZapLogger(), errreport.Nop())})
+	handler := func(c *fiber.Ctx) error {
+		n := calls.Add(1)
+		if release != nil {
+			<-release
+		}
+		if strings.Contains(string(c.Body()), "fail") {
+			return httperr.BadRequest("Caption is not allowed")
+		}
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{"post": n})
+	}
+	for _, mount := range []string{"/api/v1", "/api"} {
+		app.Group(mount).Post("/posts/:id", idempotency.Middleware(mount), handler)
+	}
+	return app, &calls
+}
+
+type idempotentRequest struct {
+	path string // Defaults to /api/v1/posts/1
+	user string
+	key  string
+	body string
+}
+
+func (r idempotentRequest) send(t *testing.T, app *fiber.App) (status int, body string, replayed bool) {
+	t.Helper()
+	resp, err := app.Test(r.request(), -1)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(data), resp.Header.Get("Idempotent-Replayed") == "true"
+}
+
+func (r idempotentRequest) request() *http.Request {
+	path := r.path
+	if path == "" {
+		path = "/api/v1/posts/1"
+	}
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(r.body))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	if r.user != "" {
+		req.Header.Set("X-User", r.user)
+	}
+	if r.key != "" {
+		req.Header.Set(IdempotencyKeyHeader, r.key)
+	}
+	return req
+}
+
+func TestIdempotencyReplaysResponses(t *testing.T) {
+	tests := []struct {
+		name       string
+		first      idempotentRequest
+		second     idempotentRequest
+		wantCalls  int32
+		wantStatus int // Of the second request
+		wantReplay bool
+	}{
+		{
+			name:       "same key replays the first response",
+			first:      idempotentRequest{user: "alice", key: "k1", body: `{"caption":"hi"}`},
+			second:     idempotentRequest{user: "alice", key: "k1", body: `{"caption":"hi"}`},
+			wantCalls:  1,
+			wantStatus: fiber.StatusCreated,
+			wantReplay: true,
+		},
+		{
+			name:       "client errors are replayed too",
+			first:      idempotentRequest{user: "alice", key: "k1", body: `{"caption":"fail"}`},
+			second:     idempotentRequest{user: "alice", key: "k1", body: `{"caption":"fail"}`},
+			wantCalls:  1,
+			wantStatus: fiber.StatusBadRequest,
+			wantReplay: true,
+		},
+		{
+			name:       "different keys both run",
+			first:      idempotentRequest{user: "alice", key: "k1", body: `{"caption":"hi"}`},
+			second:     idempotentRequest{user: "alice", key: "k2", body: `{"caption":"hi"}`},
+			wantCalls:  2,
+			wantStatus: fiber.StatusCreated,
+		},
+		{
+			name:       "keys are scoped to the caller",
+			first:      idempotentRequest{user: "alice", key: "k1", body: `{"caption":"hi"}`},
+			second:     idempotentRequest{user: "bob", key: "k1", body: `{"caption":"hi"}`},
+			wantCalls:  2,
+			wantStatus: fiber.StatusCreated,
+		},
+		{
+			name:       "requests without a key aren't deduplicated",
+			first:      idempotentRequest{user: "alice", body: `{"caption":"hi"}`},
+			second:     idempotentRequest{user: "alice", body: `{"caption":"hi"}`},
+			wantCalls:  2,
+			wantStatus: fiber.StatusCreated,
+		},
+		{
+			name:       "anonymous requests aren't deduplicated",
+			first:      idempotentRequest{key: "k1", body: `{"caption":"hi"}`},
+			second:     idempotentRequest{key: "k1", body: `{"caption":"hi"}`},
+			wantCalls:  2,
+			wantStatus: fiber.StatusCreated,
+		},
+		{
+			name:       "retries through an alias mount are replayed",
+			first:      idempotentRequest{user: "alice", key: "k1", body: `{"caption":"hi"}`},
+			second:     idempotentRequest{path: "/api/posts/1", user: "alice", key: "k1", body: `{"caption":"hi"}`},
+			wantCalls:  1,
+			wantStatus: fiber.StatusCreated,
+			wantReplay: true,
+		},
+		{
+			name:       "reusing a key for another resource is a conflict",
+			first:      idempotentRequest{user: "alice", key: "k1", body: `{"caption":"hi"}`},
+			second:     idempotentRequest{path: "/api/v1/posts/2", user: "alice", key: "k1", body: `{"caption":"hi"}`},
+			wantCalls:  1,
+			wantStatus: fiber.StatusConflict,
+		},
+		{
+			name:       "reusing a key for another body is a conflict",
+			first:      idempotentRequest{user: "alice", key: "k1", body: `{"caption":"hi"}`},
+			second:     idempotentRequest{user: "alice", key: "k1", body: `{"caption":"bye"}`},
+			wantCalls:  1,
+			wantStatus: fiber.StatusConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, calls := idempotencyApp(t, nil)
+
+			firstStatus, firstBody, _ := tt.first.send(t, app)
+			status, body, replayed := tt.second.send(t, app)
+
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Errorf("handler ran %d times, want %d", got, tt.wantCalls)
+			}
+			if status != tt.wantStatus {
+				t.Errorf("second status = %d, want %d (body %s)", status, tt.wantStatus, body)
+			}
+			if replayed != tt.wantReplay {
+				t.Errorf("second replayed = %v, want %v", replayed, tt.wantReplay)
+			}
+			if tt.wantReplay && (status != firstStatus || body != firstBody) {
+				t.Errorf("replayed %d %s, want the first response %d %s", status, body, firstStatus, firstBody)
+			}
+		})
+	}
+}
+
+func TestIdempotencyInFlightConflict(t *testing.T) {
+	release := make(chan struct{})
+	app, calls := idempotencyApp(t, release)
+	request := idempotentRequest{user: "alice", key: "k1", body: `{"caption":"hi"}`}
+
+	done := make(chan int)
+	go func() {
+		resp, err := app.Test(request.request(), -1)
+		if err != nil {
+			done <- 0
+			return
+		}
+		resp.Body.Close()
+		done <- resp.StatusCode
+	}()
+
+	// Wait for the first request to reach the handler
+	deadline := time.Now().Add(5 * time.Second)
+	for calls.Load() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("first request never reached the handler")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if status, body, _ := request.send(t, app); status != fiber.StatusConflict {
+		t.Errorf("in-flight duplicate status = %d, want %d (body %s)", status, fiber.StatusConflict, body)
+	}
+
+	close(release)
+	if status := <-done; status != fiber.StatusCreated {
+		t.Errorf("first status = %d, want %d", status, fiber.StatusCreated)
+	}
+
+	if status, _, replayed := request.send(t, app); status != fiber.StatusCreated || !replayed {
+		t.Errorf("retry after completion = %d replayed %v, want %d replayed", status, replayed, fiber.StatusCreated)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("handler ran %d times, want 1", got)
+	}
+}
+
+func TestIdempotencyKeyTooLong(t *testing.T) {
+	app, calls := idempotencyApp(t, nil)
+
+	request := idempotentRequest{user: "alice", key: strings.Repeat("k", maxIdempotencyKeyLength+1), body: `{}`}
+	if status, _, _ := request.send(t, app); status != fiber.StatusBadRequest {
+		t.Errorf("status = %d, want %d", status, fiber.StatusBadRequest)
+	}
+	if calls.Load() != 0 {
+		t.Error("handler ran for a rejected key")
+	}
+}
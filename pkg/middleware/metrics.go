@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"time"
+
+	"fowergram-backend/pkg/telemetry"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Metrics returns middleware recording the count and duration of requests
+// by method, route pattern and status
+func Metrics(t *telemetry.Telemetry) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+
+		if err := renderError(c, c.Next()); err != nil {
+			return err
+		}
+
+		t.RecordRequest(c.Method(), routePattern(c), c.Response().StatusCode(), time.Since(start))
+		return nil
+	}
+}
@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"fowergram-backend/pkg/errreport"
+	"fowergram-backend/pkg/httperr"
+	"fowergram-backend/pkg/logger"
+	"fowergram-backend/pkg/telemetry"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	telemetryOnce sync.Once
+	testTelemetry *telemetry.Telemetry
+	telemetryErr  error
+)
+
+// newTestTelemetry returns the process's only Telemetry, since its metrics
+// register with the global telemetry.Registry, with a pool's gauges. The
+// pool connects lazily, so they read without a database.
+func newTestTelemetry(t *testing.T) *telemetry.Telemetry {
+	t.Helper()
+	telemetryOnce.Do(func() {
+		testTelemetry, telemetryErr = telemetry.NewTelemetry(context.Background(), "fowergram-test", "test", telemetry.TracingConfig{})
+		if telemetryErr != nil {
+			return
+		}
+		var pool *pgxpool.Pool
+		pool, telemetryErr = pgxpool.New(context.Background(), "postgres://localhost:1/fowergram")
+		if telemetryErr != nil {
+			return
+		}
+		telemetryErr = testTelemetry.RegisterPool(pool)
+	})
+	if telemetryErr != nil {
+		t.Fatalf("setting up telemetry: %v", telemetryErr)
+	}
+	return testTelemetry
+}
+
+func TestMetricsScrape(t *testing.T) {
+	tel := newTestTelemetry(t)
+
+	app := fiber.New(fiber.Config{ErrorHandler: httperr.Handler(logger.NewZapLogger(), errreport.Nop())})
+	app.Use(Metrics(tel))
+	app.Get("/posts/:id", func(c *fiber.Ctx) error {
+		tel.RecordCacheLookup("post", telemetry.CacheHit)
+		return c.SendStatus(fiber.StatusOK)
+	})
+	app.Get("/private", func(c *fiber.Ctx) error {
+		return httperr.Forbidden("Not allowed")
+	})
+	app.Get("/metrics", adaptor.HTTPHandler(tel.PrometheusHandler()))
+
+	for _, path := range []string{"/posts/1", "/posts/2", "/private", "/missing"} {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, path, nil), -1)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		resp.Body.Close()
+	}
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/metrics", nil), -1)
+	if err != nil {
+		t.Fatalf("GET /metrics: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading /metrics: %v", err)
+	}
+	scrape := string(body)
+
+	tests := []struct {
+		name   string
+		series string
+	}{
+		{name: "requests by route pattern", series: `http_requests_total{method="GET",route="/posts/:id",status="200"} `},
+		{name: "error responses", series: `http_requests_total{method="GET",route="/private",status="403"} `},
+		{name: "unmatched routes", series: `http_requests_total{method="GET",route="unmatched",status="404"} `},
+		{name: "request durations", series: `http_request_duration_seconds_count{method="GET",route="/posts/:id",status="200"} `},
+		{name: "cache lookups", series: `cache_lookups_total{cache="post",result="hit"} `},
+		{name: "pool connections in use", series: `db_pool_acquired_connections `},
+		{name: "pool connections", series: `db_pool_total_connections `},
+		{name: "Go runtime", series: `go_goroutines `},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !strings.Contains(scrape, tt.series) {
+				t.Errorf("/metrics is missing %s", tt.series)
+			}
+		})
+	}
+
+	if strings.Contains(scrape, `route="/posts/1"`) {
+		t.Error("/metrics labels a series with a raw path instead of its route pattern")
+	}
+}
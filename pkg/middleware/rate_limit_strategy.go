@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// RateLimitStrategy chooses how a RateLimiter counts requests
+type RateLimitStrategy string
+
+const (
+	// SlidingWindow allows MaxRequests in any Window, keeping the time of
+	// each request in a sorted set. It is the default.
+	SlidingWindow RateLimitStrategy = "sliding_window"
+
+	// FixedWindow allows MaxRequests per Window from a caller's first
+	// request, in a single counter. It is cheaper, but a caller can make
+	// twice MaxRequests across the end of one window and the start of the
+	// next.
+	FixedWindow RateLimitStrategy = "fixed_window"
+)
+
+// limitDecision is the outcome of counting a request
+type limitDecision struct {
+	allowed   bool
+	remaining int64
+	reset     time.Duration // Until the caller may make another request once at the limit
+}
+
+// slidingWindowScript drops the requests older than the window, then adds
+// this one unless the limit is reached. Times are taken from the Redis clock
+// so instances with skewed clocks count alike.
+//
+// KEYS[1] sorted set of request times in milliseconds
+// ARGV[1] limit, ARGV[2] window in milliseconds, ARGV[3] unique member
+// Returns {allowed, count, milliseconds until the oldest request expires}
+var slidingWindowScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+
+local allowed = 0
+local count = redis.call('ZCARD', KEYS[1])
+if count < limit then
+	redis.call('ZADD', KEYS[1], now, ARGV[3])
+	redis.call('PEXPIRE', KEYS[1], window)
+	allowed = 1
+	count = count + 1
+end
+
+local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+local reset = window
+if oldest[2] then
+	reset = tonumber(oldest[2]) + window - now
+end
+return {allowed, count, reset}
+`)
+
+// fixedWindowScript counts this request unless the limit is reached, starting
+// the window on the first one
+//
+// KEYS[1] request counter
+// ARGV[1] limit, ARGV[2] window in milliseconds
+// Returns {allowed, count, milliseconds until the window ends}
+var fixedWindowScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local count = tonumber(redis.call('GET', KEYS[1]) or '0')
+
+local allowed = 0
+if count < limit then
+	count = redis.call('INCR', KEYS[1])
+	allowed = 1
+end
+
+local ttl = redis.call('PTTL', KEYS[1])
+if ttl < 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+	ttl = tonumber(ARGV[2])
+end
+return {allowed, count, ttl}
+`)
+
+// count atomically checks the caller's count against the limit and counts
+// the request if it is allowed
+func (r *RateLimiter) count(ctx context.Context, p *policy, caller string) (limitDecision, error) {
+	window := p.Window.Milliseconds()
+
+	var (
+		result []int64
+		err    error
+	)
+	if p.Strategy == FixedWindow {
+		key := fmt.Sprintf("%s:%s", p.keyPrefix, caller)
+		result, err = fixedWindowScript.Run(ctx, r.config.RedisClient, []string{key}, p.MaxRequests, window).Int64Slice()
+	} else {
+		// Kept apart from the fixed window counters, which are strings
+		key := fmt.Sprintf("%s:sliding:%s", p.keyPrefix, caller)
+		result, err = slidingWindowScript.Run(ctx, r.config.RedisClient, []string{key}, p.MaxRequests, window, uuid.NewString()).Int64Slice()
+	}
+	if err != nil {
+		return limitDecision{}, fmt.Errorf("failed to count request: %w", err)
+	}
+	if len(result) != 3 {
+		return limitDecision{}, fmt.Errorf("unexpected rate limit script result %v", result)
+	}
+
+	return limitDecision{
+		allowed:   result[0] == 1,
+		remaining: max(p.MaxRequests-result[1], 0),
+		reset:     time.Duration(result[2]) * time.Millisecond,
+	}, nil
+}
@@ -2,8 +2,16 @@ package middleware
 
 import (
 	"fmt"
+	"math"
+	"strconv"
+	"sync/atomic"
 	"time"
 
+	"fowergram-backend/pkg/auth"
+	"fowergram-backend/pkg/httperr"
+	"fowergram-backend/pkg/logger"
+	"fowergram-backend/pkg/telemetry"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/redis/go-redis/v9"
 )
@@ -11,67 +19,190 @@ import (
 // RateLimiterConfig holds rate limiter configuration
 type RateLimiterConfig struct {
 	RedisClient *redis.Client
-	MaxRequests int64         // Maximum number of requests
-	Window      time.Duration // Time window for rate limiting
+	MaxRequests int64                     // Maximum number of requests
+	Window      time.Duration             // Time window for rate limiting
+	KeyPrefix   string                    // Redis key prefix; defaults to "rate_limit"
+	KeyFunc     func(c *fiber.Ctx) string // Identifies the caller; defaults to the client IP
+	Strategy    RateLimitStrategy         // How requests are counted; defaults to SlidingWindow
+
+	// FailOpen lets requests through while Redis can't count them, limited
+	// per instance by an in-memory token bucket instead; otherwise they are
+	// answered with 503
+	FailOpen  bool
+	Logger    logger.Logger        // Reports Redis failing and recovering; optional
+	Telemetry *telemetry.Telemetry // Counts requests decided without Redis; optional
+}
+
+// RateLimitPolicy limits one kind of request, such as sign ins or follows
+type RateLimitPolicy struct {
+	MaxRequests int64
+	Window      time.Duration
+	Strategy    RateLimitStrategy // Defaults to SlidingWindow
+}
+
+// policy is a RateLimitPolicy ready to count requests
+type policy struct {
+	RateLimitPolicy
+	name      string // Names the policy in logs and metrics
+	keyPrefix string
+	fallback  *tokenBuckets
 }
 
-// RateLimiter implements rate limiting using Redis
+func newPolicy(name, keyPrefix string, limits RateLimitPolicy) *policy {
+	return &policy{
+		RateLimitPolicy: limits,
+		name:            name,
+		keyPrefix:       keyPrefix,
+		fallback:        newTokenBuckets(limits.MaxRequests, limits.Window),
+	}
+}
+
+// RateLimiter implements rate limiting using Redis. Each request is checked
+// and counted by a single script, so concurrent requests can't both take the
+// last one left.
 type RateLimiter struct {
-	config RateLimiterConfig
+	config   RateLimiterConfig
+	base     *policy // The config's own limits, applied by Middleware
+	policies map[string]*policy
+
+	// degraded is set while Redis is failing, so the failure and the
+	// recovery are logged once rather than on every request
+	degraded atomic.Bool
 }
 
 // NewRateLimiter creates a new rate limiter
 func NewRateLimiter(config RateLimiterConfig) *RateLimiter {
-	return &RateLimiter{
-		config: config,
+	r := &RateLimiter{
+		config:   config,
+		policies: make(map[string]*policy),
+	}
+	r.base = newPolicy(r.keyPrefix(), r.keyPrefix(), RateLimitPolicy{
+		MaxRequests: config.MaxRequests,
+		Window:      config.Window,
+		Strategy:    config.Strategy,
+	})
+	return r
+}
+
+// SetPolicy registers the limits Handle applies under name. Requests are
+// counted under the key prefix followed by name, apart from every other
+// policy. Policies must be set before Handle is called for them.
+func (r *RateLimiter) SetPolicy(name string, limits RateLimitPolicy) {
+	r.policies[name] = newPolicy(name, r.keyPrefix()+":"+name, limits)
+}
+
+// Handle returns middleware limiting requests by the policy set under name,
+// counting them per caller as keyFunc identifies them; callers it returns ""
+// for are counted by IP. A name with no policy gets the limiter's own
+// MaxRequests and Window.
+func (r *RateLimiter) Handle(name string, keyFunc func(c *fiber.Ctx) string) fiber.Handler {
+	p, ok := r.policies[name]
+	if !ok {
+		p = newPolicy(name, r.keyPrefix()+":"+name, r.base.RateLimitPolicy)
+		r.policies[name] = p
 	}
+	return r.handler(p, keyFunc)
 }
 
-// Middleware returns a rate limiting middleware
+// Middleware returns a rate limiting middleware applying the limiter's own
+// limits, per caller as KeyFunc identifies them
 func (r *RateLimiter) Middleware() fiber.Handler {
+	return r.handler(r.base, r.config.KeyFunc)
+}
+
+func (r *RateLimiter) handler(p *policy, keyFunc func(c *fiber.Ctx) string) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		// Get client IP
-		ip := c.IP()
-		if ip == "" {
-			ip = "unknown"
-		}
+		caller := callerKey(c, keyFunc)
 
-		// Create Redis key for this IP
-		key := fmt.Sprintf("rate_limit:%s", ip)
+		decision, err := r.count(c.Context(), p, caller)
+		if err != nil {
+			return r.withoutRedis(c, p, caller, err)
+		}
 
-		// Get current count
-		ctx := c.Context()
-		count, err := r.config.RedisClient.Get(ctx, key).Int64()
-		if err != nil && err != redis.Nil {
-			return c.Status(500).JSON(fiber.Map{
-				"error": "Internal server error",
-			})
+		if r.degraded.CompareAndSwap(true, false) && r.config.Logger != nil {
+			r.config.Logger.Info("Rate limiter reached Redis again", "limiter", r.keyPrefix())
 		}
 
-		// If count exceeds limit, return error
-		if count >= r.config.MaxRequests {
-			return c.Status(429).JSON(fiber.Map{
-				"error":       "Too many requests",
-				"retry_after": r.config.Window.Seconds(),
-			})
+		setRateLimitHeaders(c, p, decision)
+		if !decision.allowed {
+			return tooManyRequests(c, decision)
 		}
+		return c.Next()
+	}
+}
 
-		// Increment counter
-		pipe := r.config.RedisClient.Pipeline()
-		incr := pipe.Incr(ctx, key)
-		pipe.Expire(ctx, key, r.config.Window)
-		_, err = pipe.Exec(ctx)
-		if err != nil {
-			return c.Status(500).JSON(fiber.Map{
-				"error": "Internal server error",
-			})
+// withoutRedis decides on a request Redis failed to count: with FailOpen it
+// is limited by the in-memory fallback, otherwise it is refused
+func (r *RateLimiter) withoutRedis(c *fiber.Ctx, p *policy, caller string, err error) error {
+	if r.degraded.CompareAndSwap(false, true) && r.config.Logger != nil {
+		r.config.Logger.Warn("Rate limiter can't reach Redis", "limiter", r.keyPrefix(), "fail_open", r.config.FailOpen, "error", err)
+	}
+
+	if !r.config.FailOpen {
+		r.config.Telemetry.RecordRateLimitDegraded(p.name, telemetry.RateLimitRejected)
+		return httperr.Unavailable("Rate limiting is temporarily unavailable")
+	}
+
+	decision := p.fallback.take(caller, time.Now())
+	setRateLimitHeaders(c, p, decision)
+	if !decision.allowed {
+		r.config.Telemetry.RecordRateLimitDegraded(p.name, telemetry.RateLimitLimited)
+		return tooManyRequests(c, decision)
+	}
+
+	r.config.Telemetry.RecordRateLimitDegraded(p.name, telemetry.RateLimitAllowed)
+	return c.Next()
+}
+
+// tooManyRequests refuses a caller over the limit, telling them in
+// Retry-After when they may try again
+func tooManyRequests(c *fiber.Ctx, decision limitDecision) error {
+	retryAfter := int64(math.Ceil(decision.reset.Seconds()))
+	c.Set(fiber.HeaderRetryAfter, strconv.FormatInt(retryAfter, 10))
+	return httperr.New(fiber.StatusTooManyRequests, httperr.CodeTooManyRequests, "Too many requests").
+		WithDetails(fiber.Map{"retry_after": retryAfter})
+}
+
+// setRateLimitHeaders adds the rate limit headers of the policy applied. When
+// several apply to a route, the last one to run sets them.
+func setRateLimitHeaders(c *fiber.Ctx, p *policy, decision limitDecision) {
+	c.Set("X-RateLimit-Limit", fmt.Sprintf("%d", p.MaxRequests))
+	c.Set("X-RateLimit-Remaining", fmt.Sprintf("%d", decision.remaining))
+	c.Set("X-RateLimit-Reset", fmt.Sprintf("%d", time.Now().Add(decision.reset).Unix()))
+}
+
+// keyPrefix returns the configured Redis key prefix
+func (r *RateLimiter) keyPrefix() string {
+	if r.config.KeyPrefix == "" {
+		return "rate_limit"
+	}
+	return r.config.KeyPrefix
+}
+
+// callerKey identifies the caller by keyFunc, falling back to the client IP
+func callerKey(c *fiber.Ctx, keyFunc func(c *fiber.Ctx) string) string {
+	if keyFunc != nil {
+		if key := keyFunc(c); key != "" {
+			return key
 		}
+	}
+	return ByIP(c)
+}
 
-		// Add rate limit headers
-		c.Set("X-RateLimit-Limit", fmt.Sprintf("%d", r.config.MaxRequests))
-		c.Set("X-RateLimit-Remaining", fmt.Sprintf("%d", r.config.MaxRequests-incr.Val()))
-		c.Set("X-RateLimit-Reset", fmt.Sprintf("%d", time.Now().Add(r.config.Window).Unix()))
+// ByIP identifies callers by their IP, for rate limiting anonymous requests
+func ByIP(c *fiber.Ctx) string {
+	ip := ClientIP(c)
+	if ip == "" {
+		ip = "unknown"
+	}
+	return ip
+}
 
-		return c.Next()
+// ByUserID identifies callers by the signed in user, for rate limiting
+// authenticated requests. It returns "" before authentication.
+func ByUserID(c *fiber.Ctx) string {
+	if user, ok := c.Locals("user").(*auth.User); ok {
+		return user.ID.String()
 	}
+	return ""
 }
@@ -0,0 +1,248 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"fowergram-backend/pkg/errreport"
+	"fowergram-backend/pkg/httperr"
+	"fowergram-backend/pkg/logger"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// rateLimitedApp serves GET /limited behind limiter's own limits, keyed by
+// the X-User header
+func rateLimitedApp(limiter *RateLimiter) *fiber.App {
+	app := fiber.New(fiber.Config{ErrorHandler: httperr.Handler(logger.NewZapLogger(), errreport.Nop())})
+	app.Get("/limited", limiter.Middleware(), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+	return app
+}
+
+// limitedRequest sends GET /limited as user and returns the status
+func limitedRequest(t *testing.T, app *fiber.App, user string) int {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/limited", nil)
+	req.Header.Set("X-User", user)
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Errorf("request failed: %v", err)
+		return 0
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func byUserHeader(c *fiber.Ctx) string {
+	return c.Get("X-User")
+}
+
+func TestRateLimitConcurrentRequests(t *testing.T) {
+	const limit, requests = 10, 50
+
+	tests := []struct {
+		strategy RateLimitStrategy
+		key      string
+		count    func(server *miniredis.Miniredis, key string) int
+	}{
+		{
+			strategy: SlidingWindow,
+			key:      "rate_limit:sliding:alice",
+			count: func(server *miniredis.Miniredis, key string) int {
+				members, _ := server.ZMembers(key)
+				return len(members)
+			},
+		},
+		{
+			strategy: FixedWindow,
+			key:      "rate_limit:alice",
+			count: func(server *miniredis.Miniredis, key string) int {
+				value, _ := server.Get(key)
+				n, _ := strconv.Atoi(value)
+				return n
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.strategy), func(t *testing.T) {
+			server := miniredis.RunT(t)
+			client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+			t.Cleanup(func() { client.Close() })
+
+			app := rateLimitedApp(NewRateLimiter(RateLimiterConfig{
+				RedisClient: client,
+				MaxRequests: limit,
+				Window:      time.Minute,
+				KeyFunc:     byUserHeader,
+				Strategy:    tt.strategy,
+			}))
+
+			var (
+				wg       sync.WaitGroup
+				mu       sync.Mutex
+				statuses = make(map[int]int)
+			)
+			for range requests {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					status := limitedRequest(t, app, "alice")
+					mu.Lock()
+					statuses[status]++
+					mu.Unlock()
+				}()
+			}
+			wg.Wait()
+
+			if statuses[fiber.StatusNoContent] != limit || statuses[fiber.StatusTooManyRequests] != requests-limit {
+				t.Errorf("statuses = %v, want %d allowed and %d limited", statuses, limit, requests-limit)
+			}
+			if got := tt.count(server, tt.key); got != limit {
+				t.Errorf("Redis counted %d requests, want %d", got, limit)
+			}
+		})
+	}
+}
+
+func TestRateLimitSlidingWindow(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	app := rateLimitedApp(NewRateLimiter(RateLimiterConfig{
+		RedisClient: client,
+		MaxRequests: 2,
+		Window:      time.Minute,
+		KeyFunc:     byUserHeader,
+	}))
+
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	steps := []struct {
+		name       string
+		at         time.Duration // Since start
+		user       string
+		wantStatus int
+	}{
+		{name: "first request", at: 0, user: "alice", wantStatus: fiber.StatusNoContent},
+		{name: "second request", at: 30 * time.Second, user: "alice", wantStatus: fiber.StatusNoContent},
+		{name: "over the limit", at: 45 * time.Second, user: "alice", wantStatus: fiber.StatusTooManyRequests},
+		{name: "another caller", at: 45 * time.Second, user: "bob", wantStatus: fiber.StatusNoContent},
+		{name: "first request left the window", at: 61 * time.Second, user: "alice", wantStatus: fiber.StatusNoContent},
+		{name: "a fixed window would have reset", at: 75 * time.Second, user: "alice", wantStatus: fiber.StatusTooManyRequests},
+		{name: "second request left the window", at: 91 * time.Second, user: "alice", wantStatus: fiber.StatusNoContent},
+	}
+
+	for _, step := range steps {
+		server.SetTime(start.Add(step.at))
+		if status := limitedRequest(t, app, step.user); status != step.wantStatus {
+			t.Errorf("%s: status = %d, want %d", step.name, status, step.wantStatus)
+		}
+	}
+}
+
+func TestRateLimitWithoutRedis(t *testing.T) {
+	limited := []int{
+		fiber.StatusNoContent, fiber.StatusNoContent,
+		fiber.StatusTooManyRequests, fiber.StatusTooManyRequests,
+	}
+	refused := []int{
+		fiber.StatusServiceUnavailable, fiber.StatusServiceUnavailable,
+		fiber.StatusServiceUnavailable, fiber.StatusServiceUnavailable,
+	}
+
+	tests := []struct {
+		name        string
+		failOpen    bool
+		closeClient bool // Close the client rather than stop the server
+		// wantStatuses are those of four requests by one caller, allowed
+		// two per window
+		wantStatuses []int
+	}{
+		{name: "unreachable, fail open limits by the in-memory fallback", failOpen: true, wantStatuses: limited},
+		{name: "unreachable, fail closed refuses every request", wantStatuses: refused},
+		{name: "closed client, fail open", failOpen: true, closeClient: true, wantStatuses: limited},
+		{name: "closed client, fail closed", closeClient: true, wantStatuses: refused},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := miniredis.RunT(t)
+			client := redis.NewClient(&redis.Options{Addr: server.Addr(), MaxRetries: -1})
+			t.Cleanup(func() { client.Close() })
+
+			app := rateLimitedApp(NewRateLimiter(RateLimiterConfig{
+				RedisClient: client,
+				MaxRequests: 2,
+				Window:      time.Minute,
+				KeyFunc:     byUserHeader,
+				FailOpen:    tt.failOpen,
+			}))
+
+			if tt.closeClient {
+				client.Close()
+			} else {
+				server.Close()
+			}
+			var statuses []int
+			for range tt.wantStatuses {
+				statuses = append(statuses, limitedRequest(t, app, "alice"))
+			}
+			if !slices.Equal(statuses, tt.wantStatuses) {
+				t.Errorf("statuses = %v, want %v", statuses, tt.wantStatuses)
+			}
+			if status := limitedRequest(t, app, "bob"); status != tt.wantStatuses[0] {
+				t.Errorf("another caller's status = %d, want %d", status, tt.wantStatuses[0])
+			}
+
+			if tt.closeClient {
+				return
+			}
+
+			// Redis counts again once it's back, starting afresh
+			if err := server.Restart(); err != nil {
+				t.Fatalf("restarting Redis: %v", err)
+			}
+			if status := limitedRequest(t, app, "alice"); status != fiber.StatusNoContent {
+				t.Errorf("status once Redis is back = %d, want %d", status, fiber.StatusNoContent)
+			}
+		})
+	}
+}
+
+func TestTokenBucketsRefill(t *testing.T) {
+	buckets := newTokenBuckets(2, time.Minute)
+	start := time.Now()
+
+	steps := []struct {
+		name          string
+		key           string
+		at            time.Duration // Since start
+		wantAllowed   bool
+		wantRemaining int64
+	}{
+		{name: "full bucket", key: "alice", at: 0, wantAllowed: true, wantRemaining: 1},
+		{name: "last token", key: "alice", at: 0, wantAllowed: true, wantRemaining: 0},
+		{name: "empty bucket", key: "alice", at: 10 * time.Second, wantAllowed: false, wantRemaining: 0},
+		{name: "buckets are per key", key: "bob", at: 10 * time.Second, wantAllowed: true, wantRemaining: 1},
+		{name: "refilled one token", key: "alice", at: 30 * time.Second, wantAllowed: true, wantRemaining: 0},
+		{name: "refill is capped at capacity", key: "alice", at: 10 * time.Minute, wantAllowed: true, wantRemaining: 1},
+	}
+
+	for _, step := range steps {
+		decision := buckets.take(step.key, start.Add(step.at))
+		if decision.allowed != step.wantAllowed || decision.remaining != step.wantRemaining {
+			t.Errorf("%s: allowed = %v, remaining = %d; want %v, %d",
+				step.name, decision.allowed, decision.remaining, step.wantAllowed, step.wantRemaining)
+		}
+	}
+}
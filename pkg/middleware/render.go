@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// renderedErrorKey holds the error renderError rendered, for middleware
+// further out that needs to know what the handlers returned
+const renderedErrorKey = "rendered_error"
+
+// unmatchedRoute stands for the route of requests no route matched, so
+// probes of random paths don't each add a series or span name
+const unmatchedRoute = "unmatched"
+
+// renderError renders err, as returned by the rest of the chain, with the
+// app's ErrorHandler. Middleware recording the response calls it first so
+// the status it records is the one sent. A rendered error isn't passed on,
+// so the innermost such middleware renders it and the others see nil; only
+// a failure to render is returned.
+func renderError(c *fiber.Ctx, err error) error {
+	if err == nil {
+		return nil
+	}
+	c.Locals(renderedErrorKey, err)
+	return c.App().ErrorHandler(c, err)
+}
+
+// routePattern returns the pattern of the route that handled the request.
+// When no route matches, Fiber reports the last middleware passed through
+// as the route and returns a 404 *fiber.Error, which handlers don't use:
+// they return httperr errors.
+func routePattern(c *fiber.Ctx) string {
+	err, _ := c.Locals(renderedErrorKey).(error)
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) && fiberErr.Code == fiber.StatusNotFound {
+		return unmatchedRoute
+	}
+	return c.Route().Path
+}
@@ -0,0 +1,167 @@
+package middleware
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"fowergram-backend/pkg/errreport"
+	"fowergram-backend/pkg/httperr"
+	"fowergram-backend/pkg/logger"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+)
+
+// recordingLogger keeps the level and status of each access log line.
+// Other methods are left to the embedded nil Logger.
+type recordingLogger struct {
+	logger.Logger
+
+	mu      sync.Mutex
+	entries []string // Such as "warn 404"
+}
+
+func (l *recordingLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.record("info", keysAndValues)
+}
+
+func (l *recordingLogger) Warn(msg string, keysAndValues ...interface{}) {
+	l.record("warn", keysAndValues)
+}
+
+func (l *recordingLogger) Error(msg string, keysAndValues ...interface{}) {
+	l.record("error", keysAndValues)
+}
+
+func (l *recordingLogger) record(level string, keysAndValues []interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		if keysAndValues[i] == "status" {
+			l.entries = append(l.entries, fmt.Sprintf("%s %v", level, keysAndValues[i+1]))
+		}
+	}
+}
+
+func (l *recordingLogger) logged() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.entries...)
+}
+
+func TestErrorsRenderedOnce(t *testing.T) {
+	tel := newTestTelemetry(t)
+	handler := httperr.Handler(logger.NewZapLogger(), errreport.Nop())
+	var renders atomic.Int32
+
+	// The chain routes.SetupRoutes builds, with Recover innermost
+	app := fiber.New(fiber.Config{ErrorHandler: func(c *fiber.Ctx, err error) error {
+		renders.Add(1)
+		return handler(c, err)
+	}})
+	accessLog := &recordingLogger{}
+	app.Use(Tracing())
+	app.Use(Metrics(tel))
+	app.Use(NewAccessLogger(AccessLogConfig{Logger: accessLog}).Middleware())
+	app.Use(httperr.Recover(errreport.Nop()))
+	app.Get("/render/posts/:id", func(c *fiber.Ctx) error {
+		return httperr.NotFound("Post not found")
+	})
+	app.Get("/render/panic", func(c *fiber.Ctx) error {
+		panic("nil map")
+	})
+	app.Get("/render/ok", func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantLog    string
+		wantSeries string
+		wantRender int32
+	}{
+		{
+			name:       "handler error",
+			path:       "/render/posts/1",
+			wantStatus: fiber.StatusNotFound,
+			wantLog:    "warn 404",
+			wantSeries: `http_requests_total{method="GET",route="/render/posts/:id",status="404"} `,
+			wantRender: 1,
+		},
+		{
+			name:       "panic",
+			path:       "/render/panic",
+			wantStatus: fiber.StatusInternalServerError,
+			wantLog:    "error 500",
+			wantSeries: `http_requests_total{method="GET",route="/render/panic",status="500"} `,
+			wantRender: 1,
+		},
+		{
+			name:       "unmatched route",
+			path:       "/render/missing",
+			wantStatus: fiber.StatusNotFound,
+			wantLog:    "warn 404",
+			wantSeries: `http_requests_total{method="GET",route="unmatched",status="404"} `,
+			wantRender: 1,
+		},
+		{
+			name:       "success",
+			path:       "/render/ok",
+			wantStatus: fiber.StatusNoContent,
+			wantLog:    "info 204",
+			wantSeries: `http_requests_total{method="GET",route="/render/ok",status="204"} `,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			renders.Store(0)
+			before := len(accessLog.logged())
+
+			resp, err := app.Test(httptest.NewRequest(http.MethodGet, tt.path, nil), -1)
+			if err != nil {
+				t.Fatalf("GET %s: %v", tt.path, err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if got := renders.Load(); got != tt.wantRender {
+				t.Errorf("ErrorHandler ran %d times, want %d", got, tt.wantRender)
+			}
+			if got := accessLog.logged()[before:]; !reflect.DeepEqual(got, []string{tt.wantLog}) {
+				t.Errorf("access log = %q, want %q", got, tt.wantLog)
+			}
+			if scrape := scrapeMetrics(t, tel.PrometheusHandler()); !strings.Contains(scrape, tt.wantSeries) {
+				t.Errorf("/metrics is missing %s", tt.wantSeries)
+			}
+		})
+	}
+}
+
+// scrapeMetrics returns the text handler serves
+func scrapeMetrics(t *testing.T, handler http.Handler) string {
+	t.Helper()
+	app := fiber.New()
+	app.Get("/metrics", adaptor.HTTPHandler(handler))
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/metrics", nil), -1)
+	if err != nil {
+		t.Fatalf("GET /metrics: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading /metrics: %v", err)
+	}
+	return string(body)
+}
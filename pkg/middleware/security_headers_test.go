@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	config := SecurityHeadersConfig{
+		ContentTypeOptions:    "nosniff",
+		FrameOptions:          "DENY",
+		ReferrerPolicy:        "no-referrer",
+		ContentSecurityPolicy: "default-src 'self'",
+	}
+
+	tests := []struct {
+		name   string
+		config SecurityHeadersConfig
+		path   string
+		want   map[string]string // Empty values for headers left out
+	}{
+		{
+			name:   "JSON",
+			config: config,
+			path:   "/json",
+			want: map[string]string{
+				fiber.HeaderXContentTypeOptions:   "nosniff",
+				fiber.HeaderXFrameOptions:         "DENY",
+				fiber.HeaderReferrerPolicy:        "no-referrer",
+				fiber.HeaderContentSecurityPolicy: "",
+			},
+		},
+		{
+			name:   "HTML page",
+			config: config,
+			path:   "/docs",
+			want: map[string]string{
+				fiber.HeaderXContentTypeOptions:   "nosniff",
+				fiber.HeaderXFrameOptions:         "DENY",
+				fiber.HeaderReferrerPolicy:        "no-referrer",
+				fiber.HeaderContentSecurityPolicy: "default-src 'self'",
+			},
+		},
+		{
+			name:   "error",
+			config: config,
+			path:   "/missing",
+			want: map[string]string{
+				fiber.HeaderXContentTypeOptions: "nosniff",
+				fiber.HeaderXFrameOptions:       "DENY",
+				fiber.HeaderReferrerPolicy:      "no-referrer",
+			},
+		},
+		{
+			name:   "configured values",
+			config: SecurityHeadersConfig{FrameOptions: "SAMEORIGIN", ContentSecurityPolicy: "default-src 'none'"},
+			path:   "/docs",
+			want: map[string]string{
+				fiber.HeaderXContentTypeOptions:   "",
+				fiber.HeaderXFrameOptions:         "SAMEORIGIN",
+				fiber.HeaderReferrerPolicy:        "",
+				fiber.HeaderContentSecurityPolicy: "default-src 'none'",
+			},
+		},
+		{
+			name: "nothing configured",
+			path: "/docs",
+			want: map[string]string{
+				fiber.HeaderXContentTypeOptions:   "",
+				fiber.HeaderXFrameOptions:         "",
+				fiber.HeaderReferrerPolicy:        "",
+				fiber.HeaderContentSecurityPolicy: "",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Use(SecurityHeaders(tt.config))
+			app.Get("/json", func(c *fiber.Ctx) error {
+				return c.JSON(fiber.Map{"ok": true})
+			})
+			app.Get("/docs", func(c *fiber.Ctx) error {
+				c.Type("html", "utf-8")
+				return c.SendString("<!doctype html><title>Docs</title>")
+			})
+
+			resp, err := app.Test(httptest.NewRequest(http.MethodGet, tt.path, nil), -1)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			defer resp.Body.Close()
+
+			for header, want := range tt.want {
+				if got := resp.Header.Get(header); got != want {
+					t.Errorf("%s = %q, want %q", header, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// tokenBuckets is an in-memory rate limiter keeping a token bucket per key.
+// Each bucket holds up to capacity tokens and refills at capacity per
+// window, so it allows the same average rate as the Redis limiter, counted
+// on this instance only.
+type tokenBuckets struct {
+	capacity float64
+	window   time.Duration
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+func newTokenBuckets(capacity int64, window time.Duration) *tokenBuckets {
+	return &tokenBuckets{
+		capacity: float64(capacity),
+		window:   window,
+		buckets:  make(map[string]*tokenBucket),
+	}
+}
+
+// take takes a token from key's bucket if one is left
+func (t *tokenBuckets) take(key string, now time.Time) limitDecision {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.sweep(now)
+
+	bucket, exists := t.buckets[key]
+	if !exists {
+		bucket = &tokenBucket{tokens: t.capacity, updated: now}
+		t.buckets[key] = bucket
+	}
+
+	elapsed := now.Sub(bucket.updated)
+	bucket.tokens = min(t.capacity, bucket.tokens+t.capacity*elapsed.Seconds()/t.window.Seconds())
+	bucket.updated = now
+
+	allowed := bucket.tokens >= 1
+	if allowed {
+		bucket.tokens--
+	}
+
+	// The time until the next whole token
+	reset := time.Duration((1 - (bucket.tokens - math.Floor(bucket.tokens))) / t.capacity * float64(t.window))
+	return limitDecision{
+		allowed:   allowed,
+		remaining: int64(bucket.tokens),
+		reset:     reset,
+	}
+}
+
+// sweep drops the buckets that have refilled completely, at most once per
+// window, so callers seen once don't stay in memory
+func (t *tokenBuckets) sweep(now time.Time) {
+	if now.Sub(t.lastSweep) < t.window {
+		return
+	}
+	t.lastSweep = now
+
+	for key, bucket := range t.buckets {
+		if now.Sub(bucket.updated) >= t.window {
+			delete(t.buckets, key)
+		}
+	}
+}
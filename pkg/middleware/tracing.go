@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"fowergram-backend/pkg/logger"
+	"fowergram-backend/pkg/telemetry"
+
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracing returns middleware recording a server span per request, named
+// after the matched route and continuing the caller's trace when it sends a
+// traceparent header. The span is set on the user context and, for the
+// handlers passing on c.Context(), under telemetry.SpanKey, so spans started
+// further down become its children.
+func Tracing() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := otel.GetTextMapPropagator().Extract(c.UserContext(), headerCarrier{c})
+		ctx, span := telemetry.StartSpan(ctx, c.Method(),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(c.Method()),
+				semconv.URLPath(c.Path()),
+				semconv.ClientAddress(ClientIP(c)),
+			),
+		)
+		defer span.End()
+
+		c.SetUserContext(ctx)
+		c.Locals(telemetry.SpanKey, span)
+
+		if err := renderError(c, c.Next()); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return err
+		}
+
+		route := routePattern(c)
+		status := c.Response().StatusCode()
+		span.SetName(c.Method() + " " + route)
+		span.SetAttributes(
+			semconv.HTTPRoute(route),
+			semconv.HTTPResponseStatusCode(status),
+		)
+		if userID, ok := c.Locals(logger.UserIDKey).(string); ok {
+			span.SetAttributes(semconv.UserID(userID))
+		}
+		if status >= fiber.StatusInternalServerError {
+			span.SetStatus(codes.Error, "")
+		}
+		return nil
+	}
+}
+
+// headerCarrier reads the propagated trace context from request headers
+type headerCarrier struct {
+	c *fiber.Ctx
+}
+
+func (h headerCarrier) Get(key string) string {
+	return h.c.Get(key)
+}
+
+// Set is unused, as the context is only extracted
+func (h headerCarrier) Set(key, value string) {}
+
+func (h headerCarrier) Keys() []string {
+	var keys []string
+	h.c.Request().Header.VisitAll(func(key, _ []byte) {
+		keys = append(keys, string(key))
+	})
+	return keys
+}
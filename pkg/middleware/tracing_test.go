@@ -0,0 +1,146 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"fowergram-backend/pkg/logger"
+	"fowergram-backend/pkg/telemetry"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// spanAttributes returns the attributes of a recorded span by key
+func spanAttributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func TestTracingSpanHierarchy(t *testing.T) {
+	server := miniredis.RunT(t)
+	server.Set("post:1", "cached")
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	client.AddHook(telemetry.RedisHook{})
+	t.Cleanup(func() { client.Close() })
+	// Connect before recording, so the handshake's commands aren't recorded
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previousProvider, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		provider.Shutdown(context.Background())
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+
+	app := fiber.New()
+	app.Use(Tracing())
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals(logger.UserIDKey, "user-1") // As the auth middleware sets it
+		return c.Next()
+	})
+	app.Get("/posts/:id", func(c *fiber.Ctx) error {
+		// Handlers pass c.Context() on to services, as the routes do
+		value, err := client.Get(c.Context(), "post:"+c.Params("id")).Result()
+		if err != nil {
+			return err
+		}
+		pipe := client.Pipeline()
+		pipe.Incr(c.Context(), "views:"+c.Params("id"))
+		pipe.Expire(c.Context(), "views:"+c.Params("id"), 0)
+		if _, err := pipe.Exec(c.Context()); err != nil {
+			return err
+		}
+		return c.SendString(value)
+	})
+
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	req := httptest.NewRequest(http.MethodGet, "/posts/1", nil)
+	req.Header.Set("traceparent", traceparent)
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+
+	spans := recorder.Ended()
+	var serverSpan sdktrace.ReadOnlySpan
+	children := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range spans {
+		if span.SpanKind() == trace.SpanKindServer {
+			serverSpan = span
+		} else {
+			children[span.Name()] = span
+		}
+	}
+	if serverSpan == nil || len(children) != 2 {
+		names := make([]string, 0, len(spans))
+		for _, span := range spans {
+			names = append(names, span.Name())
+		}
+		t.Fatalf("recorded spans %v, want a server span, redis.get and redis.pipeline", names)
+	}
+
+	// The server span continues the caller's trace and describes the request
+	if got := serverSpan.SpanContext().TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("server span trace ID = %s, want the traceparent's", got)
+	}
+	if got := serverSpan.Parent().SpanID().String(); got != "00f067aa0ba902b7" || !serverSpan.Parent().IsRemote() {
+		t.Errorf("server span parent = %s, want the remote caller's span", got)
+	}
+	if got := serverSpan.Name(); got != "GET /posts/:id" {
+		t.Errorf("server span name = %q, want %q", got, "GET /posts/:id")
+	}
+	attrs := spanAttributes(serverSpan)
+	wantAttrs := map[attribute.Key]attribute.Value{
+		semconv.HTTPRouteKey:              attribute.StringValue("/posts/:id"),
+		semconv.HTTPResponseStatusCodeKey: attribute.IntValue(fiber.StatusOK),
+		semconv.HTTPRequestMethodKey:      attribute.StringValue(http.MethodGet),
+		semconv.UserIDKey:                 attribute.StringValue("user-1"),
+	}
+	for key, want := range wantAttrs {
+		if got, ok := attrs[key]; !ok || got != want {
+			t.Errorf("server span %s = %v, want %v", key, got.Emit(), want.Emit())
+		}
+	}
+
+	// Redis commands issued by the handler are children of the server span
+	for _, name := range []string{"redis.get", "redis.pipeline"} {
+		span, ok := children[name]
+		if !ok {
+			t.Errorf("no %s span recorded", name)
+			continue
+		}
+		if span.Parent().SpanID() != serverSpan.SpanContext().SpanID() || span.SpanContext().TraceID() != serverSpan.SpanContext().TraceID() {
+			t.Errorf("%s parent = %s, want the server span %s", name, span.Parent().SpanID(), serverSpan.SpanContext().SpanID())
+		}
+		if span.SpanKind() != trace.SpanKindClient {
+			t.Errorf("%s kind = %v, want client", name, span.SpanKind())
+		}
+		if got := spanAttributes(span)[semconv.DBSystemNameKey]; got != semconv.DBSystemNameRedis.Value {
+			t.Errorf("%s db.system.name = %v, want redis", name, got.Emit())
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Backoff sets the delays between attempts. The delay doubles after every
+// failure, from Initial up to Max.
+type Backoff struct {
+	Initial time.Duration
+	Max     time.Duration
+}
+
+// DefaultBackoff suits waiting for a dependency that is still starting
+var DefaultBackoff = Backoff{
+	Initial: 500 * time.Millisecond,
+	Max:     5 * time.Second,
+}
+
+// permanentError marks a failure that retrying can't fix
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not worth retrying, such as a malformed URL, so Do
+// returns it right away
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// Do calls fn until it succeeds, fails permanently or ctx ends, waiting
+// between attempts as b sets. onRetry, if set, is told about each failed
+// attempt before the wait. The last failure is returned when Do gives up.
+func Do(ctx context.Context, b Backoff, fn func(ctx context.Context) error, onRetry func(attempt int, err error, delay time.Duration)) error {
+	delay := b.Initial
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil {
+			return nil
+		}
+
+		var permanent *permanentError
+		if errors.As(err, &permanent) {
+			return permanent.err
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+
+		if onRetry != nil {
+			onRetry(attempt, err, delay)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+
+		delay = min(delay*2, b.Max)
+	}
+}
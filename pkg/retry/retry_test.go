@@ -0,0 +1,85 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestDo(t *testing.T) {
+	errDown := errors.New("connection refused")
+	errBadURL := errors.New("invalid URL")
+	backoff := Backoff{Initial: time.Millisecond, Max: 4 * time.Millisecond}
+
+	tests := []struct {
+		name         string
+		failures     int   // Attempts failing before one succeeds, -1 for all
+		err          error // Returned by failing attempts
+		timeout      time.Duration
+		wantErr      error
+		wantAttempts int // Zero to only require retries
+		wantDelays   []time.Duration
+	}{
+		{name: "first attempt", failures: 0, err: errDown, timeout: time.Second, wantAttempts: 1},
+		{
+			name:         "after failures",
+			failures:     4,
+			err:          errDown,
+			timeout:      time.Second,
+			wantAttempts: 5,
+			wantDelays:   []time.Duration{time.Millisecond, 2 * time.Millisecond, 4 * time.Millisecond, 4 * time.Millisecond},
+		},
+		{name: "gives up at the timeout", failures: -1, err: errDown, timeout: 50 * time.Millisecond, wantErr: errDown},
+		{name: "permanent", failures: -1, err: Permanent(errBadURL), timeout: time.Second, wantErr: errBadURL, wantAttempts: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+
+			attempts := 0
+			var delays []time.Duration
+			start := time.Now()
+			err := Do(ctx, backoff, func(ctx context.Context) error {
+				attempts++
+				if tt.failures < 0 || attempts <= tt.failures {
+					return tt.err
+				}
+				return nil
+			}, func(attempt int, err error, delay time.Duration) {
+				if attempt != len(delays)+1 {
+					t.Errorf("told about attempt %d, want %d", attempt, len(delays)+1)
+				}
+				delays = append(delays, delay)
+			})
+
+			// The cause is returned, not the permanent wrapper
+			if err != tt.wantErr {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantAttempts != 0 && attempts != tt.wantAttempts {
+				t.Errorf("%d attempts, want %d", attempts, tt.wantAttempts)
+			}
+			if tt.wantAttempts == 0 {
+				if attempts < 2 {
+					t.Errorf("%d attempts, want retries until the timeout", attempts)
+				}
+				if elapsed := time.Since(start); elapsed > tt.timeout+100*time.Millisecond {
+					t.Errorf("gave up after %v, want about %v", elapsed, tt.timeout)
+				}
+			}
+			if tt.wantDelays != nil && !slices.Equal(delays, tt.wantDelays) {
+				t.Errorf("delays = %v, want %v", delays, tt.wantDelays)
+			}
+		})
+	}
+}
+
+func TestPermanentNil(t *testing.T) {
+	if err := Permanent(nil); err != nil {
+		t.Errorf("Permanent(nil) = %v, want nil", err)
+	}
+}
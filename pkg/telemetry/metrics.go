@@ -0,0 +1,92 @@
+package telemetry
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry holds every metric served on /metrics. Packages register their
+// own collectors with it, such as with promauto.With(telemetry.Registry).
+var Registry = newRegistry()
+
+func newRegistry() *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return registry
+}
+
+// PrometheusHandler returns the handler serving the metrics in Registry
+func (t *Telemetry) PrometheusHandler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// httpMetrics are the rate, errors and duration of HTTP requests
+type httpMetrics struct {
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+func newHTTPMetrics() (*httpMetrics, error) {
+	labels := []string{"method", "route", "status"}
+	m := &httpMetrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "HTTP requests by method, route pattern and status code.",
+		}, labels),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "Time taken to answer HTTP requests, by method, route pattern and status code.",
+			Buckets: prometheus.DefBuckets,
+		}, labels),
+	}
+
+	for _, c := range []prometheus.Collector{m.requests, m.duration} {
+		if err := Registry.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// RecordRequest counts an answered HTTP request and observes its duration.
+// route is the pattern the request matched, such as /api/v1/posts/:id, so
+// the series don't grow with every ID requested.
+func (t *Telemetry) RecordRequest(method, route string, status int, duration time.Duration) {
+	code := strconv.Itoa(status)
+	t.http.requests.WithLabelValues(method, route, code).Inc()
+	t.http.duration.WithLabelValues(method, route, code).Observe(duration.Seconds())
+}
+
+// RegisterPool exposes the connection counts of a Postgres pool as gauges,
+// read from the pool on every scrape
+func (t *Telemetry) RegisterPool(pool *pgxpool.Pool) error {
+	gauges := []struct {
+		name, help string
+		value      func(*pgxpool.Stat) int32
+	}{
+		{"db_pool_acquired_connections", "Postgres connections in use.", (*pgxpool.Stat).AcquiredConns},
+		{"db_pool_idle_connections", "Idle Postgres connections.", (*pgxpool.Stat).IdleConns},
+		{"db_pool_total_connections", "Open Postgres connections, in use, idle or being opened.", (*pgxpool.Stat).TotalConns},
+		{"db_pool_max_connections", "Most Postgres connections the pool opens.", (*pgxpool.Stat).MaxConns},
+	}
+
+	for _, g := range gauges {
+		value := g.value
+		gauge := prometheus.NewGaugeFunc(prometheus.GaugeOpts{Name: g.name, Help: g.help}, func() float64 {
+			return float64(value(pool.Stat()))
+		})
+		if err := Registry.Register(gauge); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -2,41 +2,106 @@ package telemetry
 
 import (
 	"context"
-	"net/http"
+	"fmt"
 
-	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Cache lookup results recorded by RecordCacheLookup
+const (
+	CacheHit  = "hit"
+	CacheMiss = "miss"
 )
 
 // Telemetry holds telemetry configuration
 type Telemetry struct {
 	appName    string
 	appVersion string
-}
 
-// NewTelemetry creates a new telemetry instance
-func NewTelemetry(appName, appVersion string) (*Telemetry, error) {
-	return &Telemetry{
-		appName:    appName,
-		appVersion: appVersion,
-	}, nil
+	tracerProvider *sdktrace.TracerProvider // Nil unless tracing is configured
+
+	http              *httpMetrics
+	cacheLookups      *prometheus.CounterVec
+	rateLimitDegraded *prometheus.CounterVec
 }
 
-// PrometheusHandler returns the Prometheus metrics handler
-func (t *Telemetry) PrometheusHandler() http.Handler {
-	return promhttp.Handler()
+// NewTelemetry creates a new telemetry instance registering its metrics with
+// Registry, and exporting traces when tracing has an endpoint
+func NewTelemetry(ctx context.Context, appName, appVersion string, tracing TracingConfig) (*Telemetry, error) {
+	requests, err := newHTTPMetrics()
+	if err != nil {
+		return nil, err
+	}
+
+	cacheLookups := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cache_lookups_total",
+		Help: "Cache lookups by cache name and result (hit or miss).",
+	}, []string{"cache", "result"})
+	if err := Registry.Register(cacheLookups); err != nil {
+		return nil, err
+	}
+
+	rateLimitDegraded := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "rate_limit_degraded_total",
+		Help: "Requests rate limited without Redis, by limiter and outcome (allowed, limited or rejected).",
+	}, []string{"limiter", "outcome"})
+	if err := Registry.Register(rateLimitDegraded); err != nil {
+		return nil, err
+	}
+
+	t := &Telemetry{
+		appName:           appName,
+		appVersion:        appVersion,
+		http:              requests,
+		cacheLookups:      cacheLookups,
+		rateLimitDegraded: rateLimitDegraded,
+	}
+
+	if tracing.Endpoint != "" {
+		provider, err := startTracing(ctx, appName, appVersion, tracing)
+		if err != nil {
+			return nil, err
+		}
+		t.tracerProvider = provider
+	}
+
+	return t, nil
 }
 
-// Shutdown gracefully shuts down telemetry
-func (t *Telemetry) Shutdown() error {
-	return nil
+// RecordCacheLookup counts a lookup in the named cache; result is CacheHit
+// or CacheMiss. It is safe to call on a nil Telemetry.
+func (t *Telemetry) RecordCacheLookup(cache, result string) {
+	if t == nil {
+		return
+	}
+	t.cacheLookups.WithLabelValues(cache, result).Inc()
 }
 
-// StartTrace starts a new trace (placeholder)
-func (t *Telemetry) StartTrace(ctx context.Context, name string) context.Context {
-	return ctx
+// Outcomes of requests rate limited while Redis is unavailable, recorded by
+// RecordRateLimitDegraded
+const (
+	RateLimitAllowed  = "allowed"  // Let through by the in-memory fallback
+	RateLimitLimited  = "limited"  // Refused by the in-memory fallback
+	RateLimitRejected = "rejected" // Refused because the limiter fails closed
+)
+
+// RecordRateLimitDegraded counts a request the named limiter decided on
+// without Redis. It is safe to call on a nil Telemetry.
+func (t *Telemetry) RecordRateLimitDegraded(limiter, outcome string) {
+	if t == nil {
+		return
+	}
+	t.rateLimitDegraded.WithLabelValues(limiter, outcome).Inc()
 }
 
-// EndTrace ends a trace (placeholder)
-func (t *Telemetry) EndTrace(ctx context.Context) {
-	// Implementation would handle trace ending
+// Shutdown exports the spans not exported yet and stops tracing
+func (t *Telemetry) Shutdown(ctx context.Context) error {
+	if t.tracerProvider == nil {
+		return nil
+	}
+	if err := t.tracerProvider.Shutdown(ctx); err != nil {
+		return fmt.Errorf("failed to flush traces: %w", err)
+	}
+	return nil
 }
@@ -0,0 +1,26 @@
+package telemetry
+
+import "testing"
+
+func TestNilTelemetryRecorders(t *testing.T) {
+	var tel *Telemetry
+
+	tests := []struct {
+		name   string
+		record func()
+	}{
+		{name: "cache lookup", record: func() { tel.RecordCacheLookup("post", CacheHit) }},
+		{name: "degraded rate limit", record: func() { tel.RecordRateLimitDegraded("api", RateLimitAllowed) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if r := recover(); r != nil {
+					t.Fatalf("recording on a nil Telemetry panicked: %v", r)
+				}
+			}()
+			tt.record()
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName names the tracer every span of the application comes from
+const tracerName = "fowergram-backend"
+
+// SpanKey is the context key the tracing middleware also stores the
+// request's span under. Handlers pass the request's fasthttp context on to
+// services, and its values are the request's Locals rather than what
+// trace.ContextWithSpan sets, so StartSpan looks the parent up here too.
+const SpanKey = "otel_span"
+
+// TracingConfig configures exporting traces over OTLP/HTTP
+type TracingConfig struct {
+	Endpoint    string            // Collector URL, such as http://localhost:4318; empty disables tracing
+	Headers     map[string]string // Sent with every export, such as an API key
+	Environment string            // deployment.environment.name of the spans
+}
+
+// startTracing sets a global TracerProvider exporting spans in batches to
+// the collector, and the W3C trace context propagator
+func startTracing(ctx context.Context, appName, appVersion string, cfg TracingConfig) (*sdktrace.TracerProvider, error) {
+	exporter, err := otlptracehttp.New(ctx,
+		otlptracehttp.WithEndpointURL(cfg.Endpoint),
+		otlptracehttp.WithHeaders(cfg.Headers),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace exporter: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(appName),
+		semconv.ServiceVersion(appVersion),
+		semconv.DeploymentEnvironmentName(cfg.Environment),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return provider, nil
+}
+
+// StartSpan starts a span as a child of the span in ctx, set either the
+// standard way or by the tracing middleware under SpanKey. The caller must
+// end it. Without tracing configured the span does nothing.
+func StartSpan(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(withParent(ctx), name, opts...)
+}
+
+// withParent returns ctx carrying the span the tracing middleware stored
+// under SpanKey, unless it already carries one
+func withParent(ctx context.Context) context.Context {
+	if trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx
+	}
+	if parent, ok := ctx.Value(SpanKey).(trace.Span); ok {
+		return trace.ContextWithSpan(ctx, parent)
+	}
+	return ctx
+}
+
+// InjectTrace returns the trace context of ctx as a carrier to send along
+// with a message, or nil when ctx isn't traced
+func InjectTrace(ctx context.Context) map[string]string {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(withParent(ctx), carrier)
+	if len(carrier) == 0 {
+		return nil
+	}
+	return carrier
+}
+
+// ExtractTrace returns ctx carrying the trace context InjectTrace put in
+// carrier, so spans started from it continue the sender's trace
+func ExtractTrace(ctx context.Context, carrier map[string]string) context.Context {
+	if len(carrier) == 0 {
+		return ctx
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(carrier))
+}
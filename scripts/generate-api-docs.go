@@ -8,9 +8,15 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"reflect"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 
+	"fowergram-backend/internal/routes"
+	"fowergram-backend/pkg/auth"
+
 	"gopkg.in/yaml.v2"
 )
 
@@ -31,8 +37,44 @@ type RouteInfo struct {
 	Description string
 	Tags        []string
 	Handler     string
+	Params      []ParamInfo
+	Responses   []ResponseInfo
+	Security    []string
+	Produces    string // Content type of successful responses; defaults to JSON
+}
+
+// ParamInfo is a parsed @Param annotation:
+// @Param name in type required "description" [default(value)]
+type ParamInfo struct {
+	Name        string
+	In          string // path, query, header, body or formData
+	Type        string // Go type name for body params, otherwise a basic type
+	Required    bool
+	Description string
+	Default     string
+}
+
+// ResponseInfo is a parsed @Success or @Failure annotation:
+// @Success code [{object} Type] ["description"]
+type ResponseInfo struct {
+	Code        string
+	Type        string // Empty when the response has no body
+	Description string
 }
 
+// Annotation patterns
+var (
+	routerRegex      = regexp.MustCompile(`@Router\s+(\S+)\s+\[(\w+)\]`)
+	summaryRegex     = regexp.MustCompile(`@Summary\s+(.+)`)
+	descriptionRegex = regexp.MustCompile(`@Description\s+(.+)`)
+	tagsRegex        = regexp.MustCompile(`@Tags\s+(.+)`)
+	paramRegex       = regexp.MustCompile(`@Param\s+(\S+)\s+(\S+)\s+(\S+)\s+(true|false)\s+"([^"]*)"(.*)`)
+	responseRegex    = regexp.MustCompile(`@(?:Success|Failure)\s+(\d+)(?:\s+\{\w+\}\s+(\S+))?(?:\s+"([^"]*)")?`)
+	securityRegex    = regexp.MustCompile(`@Security\s+(\S+)`)
+	produceRegex     = regexp.MustCompile(`@Produce\s+(\S+)`)
+	defaultRegex     = regexp.MustCompile(`default\(([^)]*)\)`)
+)
+
 func main() {
 	// Parse handlers directory for route annotations
 	routes, err := parseHandlers("internal/handlers")
@@ -40,14 +82,20 @@ func main() {
 		log.Fatal("Error parsing handlers:", err)
 	}
 
+	// Collect struct types that annotations can reference
+	types, err := parseTypes("handlers", "internal/handlers", "internal/domain", "pkg")
+	if err != nil {
+		log.Fatal("Error parsing types:", err)
+	}
+
 	// Load existing OpenAPI spec
 	spec, err := loadOpenAPISpec("api/openapi.yaml")
 	if err != nil {
 		log.Fatal("Error loading OpenAPI spec:", err)
 	}
 
-	// Update paths in the spec
-	updateOpenAPISpec(spec, routes)
+	// Update paths and schemas in the spec
+	updateOpenAPISpec(spec, routes, types)
 
 	// Save updated spec
 	err = saveOpenAPISpec("api/openapi.yaml", spec)
@@ -110,11 +158,6 @@ func parseDocComments(comments string) RouteInfo {
 	var route RouteInfo
 
 	// Parse swagger-style comments
-	routerRegex := regexp.MustCompile(`@Router\s+(\S+)\s+\[(\w+)\]`)
-	summaryRegex := regexp.MustCompile(`@Summary\s+(.+)`)
-	descriptionRegex := regexp.MustCompile(`@Description\s+(.+)`)
-	tagsRegex := regexp.MustCompile(`@Tags\s+(.+)`)
-
 	if match := routerRegex.FindStringSubmatch(comments); len(match) > 2 {
 		route.Path = match[1]
 		route.Method = strings.ToLower(match[2])
@@ -136,9 +179,341 @@ func parseDocComments(comments string) RouteInfo {
 		route.Tags = tags
 	}
 
+	for _, match := range paramRegex.FindAllStringSubmatch(comments, -1) {
+		param := ParamInfo{
+			Name:        match[1],
+			In:          match[2],
+			Type:        match[3],
+			Required:    match[4] == "true",
+			Description: match[5],
+		}
+		if def := defaultRegex.FindStringSubmatch(match[6]); len(def) > 1 {
+			param.Default = def[1]
+		}
+		route.Params = append(route.Params, param)
+	}
+
+	for _, match := range responseRegex.FindAllStringSubmatch(comments, -1) {
+		route.Responses = append(route.Responses, ResponseInfo{
+			Code:        match[1],
+			Type:        match[2],
+			Description: match[3],
+		})
+	}
+
+	for _, match := range securityRegex.FindAllStringSubmatch(comments, -1) {
+		route.Security = append(route.Security, match[1])
+	}
+
+	if match := produceRegex.FindStringSubmatch(comments); len(match) > 1 && strings.Contains(match[1], "/") {
+		route.Produces = match[1]
+	}
+
 	return route
 }
 
+// parseTypes collects struct declarations keyed by "package.Name" from the
+// given directories. Types in defaultPkg are also reachable by their bare name.
+func parseTypes(defaultPkg string, dirs ...string) (map[string]*TypeInfo, error) {
+	types := make(map[string]*TypeInfo)
+
+	for _, dir := range dirs {
+		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+				return nil
+			}
+
+			file, err := parser.ParseFile(token.NewFileSet(), path, nil, parser.ParseComments)
+			if err != nil {
+				return err
+			}
+			collectTypes(file, types)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for key, t := range types {
+		if t.Package == defaultPkg {
+			types[strings.TrimPrefix(key, defaultPkg+".")] = t
+		}
+	}
+
+	return types, nil
+}
+
+// TypeInfo is a struct declaration found while parsing
+type TypeInfo struct {
+	Package string
+	Name    string
+	Struct  *ast.StructType
+}
+
+// collectTypes adds the struct declarations of a parsed file to types
+func collectTypes(file *ast.File, types map[string]*TypeInfo) {
+	pkg := file.Name.Name
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			ts := spec.(*ast.TypeSpec)
+			if st, ok := ts.Type.(*ast.StructType); ok {
+				types[pkg+"."+ts.Name.Name] = &TypeInfo{Package: pkg, Name: ts.Name.Name, Struct: st}
+			}
+		}
+	}
+}
+
+// schemaBuilder turns Go types into OpenAPI schemas, recording every struct it
+// references so the matching components can be emitted
+type schemaBuilder struct {
+	types      map[string]*TypeInfo
+	defaultPkg string
+	schemas    map[string]interface{}
+}
+
+func newSchemaBuilder(types map[string]*TypeInfo, defaultPkg string) *schemaBuilder {
+	return &schemaBuilder{
+		types:      types,
+		defaultPkg: defaultPkg,
+		schemas:    make(map[string]interface{}),
+	}
+}
+
+// annotationSchema returns the schema for a type named in an annotation,
+// e.g. "PostResponse", "export.Export", "map[string]string" or "[]TagResponse"
+func (b *schemaBuilder) annotationSchema(typ string) map[string]interface{} {
+	expr, err := parser.ParseExpr(typ)
+	if err != nil {
+		return map[string]interface{}{"type": "object"}
+	}
+	return b.exprSchema(expr, b.defaultPkg)
+}
+
+// exprSchema returns the schema for a type expression declared in pkg
+func (b *schemaBuilder) exprSchema(expr ast.Expr, pkg string) map[string]interface{} {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		if schema, ok := basicSchema(t.Name); ok {
+			return schema
+		}
+		return b.refSchema(pkg, t.Name)
+	case *ast.StarExpr:
+		return b.exprSchema(t.X, pkg)
+	case *ast.ArrayType:
+		if ident, ok := t.Elt.(*ast.Ident); ok && ident.Name == "byte" {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": b.exprSchema(t.Elt, pkg)}
+	case *ast.MapType:
+		return map[string]interface{}{"type": "object", "additionalProperties": b.exprSchema(t.Value, pkg)}
+	case *ast.SelectorExpr:
+		qualifier, _ := t.X.(*ast.Ident)
+		if qualifier == nil {
+			return map[string]interface{}{}
+		}
+		switch qualifier.Name + "." + t.Sel.Name {
+		case "time.Time":
+			return map[string]interface{}{"type": "string", "format": "date-time"}
+		case "time.Duration":
+			return map[string]interface{}{"type": "integer"}
+		case "uuid.UUID":
+			return map[string]interface{}{"type": "string", "format": "uuid"}
+		case "fiber.Map":
+			return map[string]interface{}{"type": "object"}
+		}
+		return b.refSchema(qualifier.Name, t.Sel.Name)
+	case *ast.StructType:
+		return b.structSchema(t, pkg)
+	}
+
+	// Interfaces and anything else accept any value
+	return map[string]interface{}{}
+}
+
+// refSchema references a named struct, generating its component on first use
+func (b *schemaBuilder) refSchema(pkg, name string) map[string]interface{} {
+	info, ok := b.types[pkg+"."+name]
+	if !ok {
+		return map[string]interface{}{"type": "object"}
+	}
+
+	component := b.componentName(info)
+	if _, done := b.schemas[component]; !done {
+		// Reserve the name first so self-referencing types terminate
+		b.schemas[component] = nil
+		b.schemas[component] = b.structSchema(info.Struct, info.Package)
+	}
+
+	return map[string]interface{}{"$ref": "#/components/schemas/" + component}
+}
+
+// componentName names the component for a struct; handler types keep their bare name
+func (b *schemaBuilder) componentName(info *TypeInfo) string {
+	if info.Package == b.defaultPkg {
+		return info.Name
+	}
+	return info.Package + "." + info.Name
+}
+
+// structSchema builds an object schema from a struct's exported, json-tagged fields
+func (b *schemaBuilder) structSchema(st *ast.StructType, pkg string) map[string]interface{} {
+	properties := make(map[string]interface{})
+	var required []string
+	var allOf []interface{} // Embedded structs that are still being built
+
+	for _, field := range st.Fields.List {
+		tag := reflect.StructTag("")
+		if field.Tag != nil {
+			if unquoted, err := strconv.Unquote(field.Tag.Value); err == nil {
+				tag = reflect.StructTag(unquoted)
+			}
+		}
+
+		jsonName, jsonOpts, _ := strings.Cut(tag.Get("json"), ",")
+		if jsonName == "-" {
+			continue
+		}
+
+		// Embedded structs contribute their fields inline, or by reference
+		// when the embedded type is part of a cycle and not built yet
+		if len(field.Names) == 0 {
+			embedded := b.exprSchema(field.Type, pkg)
+			if ref, ok := embedded["$ref"].(string); ok {
+				resolved, _ := b.schemas[strings.TrimPrefix(ref, "#/components/schemas/")].(map[string]interface{})
+				if resolved == nil {
+					allOf = append(allOf, embedded)
+					continue
+				}
+				embedded = resolved
+			}
+			if props, ok := embedded["properties"].(map[string]interface{}); ok {
+				for name, schema := range props {
+					properties[name] = schema
+				}
+			}
+			continue
+		}
+
+		for _, name := range field.Names {
+			if !name.IsExported() {
+				continue
+			}
+
+			propName := jsonName
+			if propName == "" {
+				propName = name.Name
+			}
+
+			schema := b.exprSchema(field.Type, pkg)
+			applyValidation(schema, tag.Get("validate"))
+			if doc := fieldDoc(field); doc != "" && schema["$ref"] == nil {
+				schema["description"] = doc
+			}
+			properties[propName] = schema
+
+			if hasRule(tag.Get("validate"), "required") && !strings.Contains(jsonOpts, "omitempty") {
+				required = append(required, propName)
+			}
+		}
+	}
+
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		sort.Strings(required)
+		schema["required"] = required
+	}
+	if len(allOf) > 0 {
+		return map[string]interface{}{"allOf": append(allOf, schema)}
+	}
+	return schema
+}
+
+// basicSchema maps Go builtin types to OpenAPI types
+func basicSchema(name string) (map[string]interface{}, bool) {
+	switch name {
+	case "string":
+		return map[string]interface{}{"type": "string"}, true
+	case "bool":
+		return map[string]interface{}{"type": "boolean"}, true
+	case "int", "int8", "int16", "int32", "uint", "uint8", "uint16", "uint32":
+		return map[string]interface{}{"type": "integer"}, true
+	case "int64", "uint64":
+		return map[string]interface{}{"type": "integer", "format": "int64"}, true
+	case "float32", "float64", "number":
+		return map[string]interface{}{"type": "number"}, true
+	case "any":
+		return map[string]interface{}{}, true
+	}
+	return nil, false
+}
+
+// applyValidation copies go-playground validate rules that OpenAPI can express
+func applyValidation(schema map[string]interface{}, rules string) {
+	for _, rule := range strings.Split(rules, ",") {
+		key, value, _ := strings.Cut(rule, "=")
+		n, err := strconv.Atoi(value)
+		switch {
+		case key == "email":
+			schema["format"] = "email"
+		case key == "url":
+			schema["format"] = "uri"
+		case key == "min" && err == nil:
+			schema[boundKey(schema, "min")] = n
+		case key == "max" && err == nil:
+			schema[boundKey(schema, "max")] = n
+		case key == "username":
+			schema["minLength"] = auth.MinUsernameLength
+			schema["maxLength"] = auth.MaxUsernameLength
+			schema["pattern"] = "^[A-Za-z0-9]+$"
+		}
+	}
+}
+
+// boundKey picks the OpenAPI keyword for a min/max rule on the schema's type
+func boundKey(schema map[string]interface{}, bound string) string {
+	switch schema["type"] {
+	case "string":
+		return bound + "Length"
+	case "array":
+		return bound + "Items"
+	}
+	if bound == "min" {
+		return "minimum"
+	}
+	return "maximum"
+}
+
+// hasRule reports whether a validate tag contains the given rule
+func hasRule(rules, name string) bool {
+	for _, rule := range strings.Split(rules, ",") {
+		if rule == name {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldDoc returns a field's doc or trailing comment as a single line
+func fieldDoc(field *ast.Field) string {
+	for _, group := range []*ast.CommentGroup{field.Doc, field.Comment} {
+		if group != nil {
+			return strings.Join(strings.Fields(group.Text()), " ")
+		}
+	}
+	return ""
+}
+
 func loadOpenAPISpec(filename string) (*OpenAPISpec, error) {
 	data, err := os.ReadFile(filename)
 	if err != nil {
@@ -154,29 +529,267 @@ func loadOpenAPISpec(filename string) (*OpenAPISpec, error) {
 	return &spec, nil
 }
 
-func updateOpenAPISpec(spec *OpenAPISpec, routes []RouteInfo) {
-	if spec.Paths == nil {
-		spec.Paths = make(map[string]interface{})
-	}
+func updateOpenAPISpec(spec *OpenAPISpec, routes []RouteInfo, types map[string]*TypeInfo) {
+	spec.Paths = stringMap(spec.Paths)
+	spec.Components = stringMap(spec.Components)
+
+	builder := newSchemaBuilder(types, "handlers")
+	securitySchemes := stringMap(spec.Components["securitySchemes"])
 
 	for _, route := range routes {
-		pathItem, exists := spec.Paths[route.Path]
-		if !exists {
-			pathItem = make(map[string]interface{})
-			spec.Paths[route.Path] = pathItem
+		path := versionedPath(route.Path)
+		if path != route.Path {
+			// Unversioned aliases aren't documented, only their successors
+			delete(spec.Paths, route.Path)
 		}
-
-		pathMap := pathItem.(map[string]interface{})
+		pathMap := stringMap(spec.Paths[path])
+		spec.Paths[path] = pathMap
 
 		operation := map[string]interface{}{
 			"summary":     route.Summary,
 			"description": route.Description,
 			"operationId": route.Handler,
 			"tags":        route.Tags,
+			"responses":   buildResponses(builder, route.Responses, route.Produces),
+		}
+
+		if params := buildParameters(route.Params); len(params) > 0 {
+			operation["parameters"] = params
+		}
+		if body := buildRequestBody(builder, route.Params); body != nil {
+			operation["requestBody"] = body
+		}
+		// Operations without @Security are public and carry no requirement
+		if len(route.Security) > 0 {
+			var security []map[string][]string
+			for _, name := range route.Security {
+				security = append(security, map[string][]string{
+					ensureSecurityScheme(securitySchemes, name): {},
+				})
+			}
+			operation["security"] = security
 		}
 
 		pathMap[route.Method] = operation
 	}
+
+	if len(securitySchemes) > 0 {
+		spec.Components["securitySchemes"] = securitySchemes
+	}
+
+	schemas := stringMap(spec.Components["schemas"])
+	for name, schema := range builder.schemas {
+		schemas[name] = schema
+	}
+	spec.Components["schemas"] = schemas
+}
+
+// versionedPath returns the path an @Router annotation's route is served
+// under: /api paths move to the current API version, others are kept
+func versionedPath(path string) string {
+	if rest, ok := strings.CutPrefix(path, "/api/"); ok {
+		return routes.APIPrefix + "/" + rest
+	}
+	return path
+}
+
+// buildParameters converts path, query and header params to OpenAPI parameters
+func buildParameters(params []ParamInfo) []map[string]interface{} {
+	var result []map[string]interface{}
+	for _, p := range params {
+		if p.In != "path" && p.In != "query" && p.In != "header" {
+			continue
+		}
+
+		schema, ok := basicSchema(p.Type)
+		if !ok {
+			schema = map[string]interface{}{"type": "string"}
+		}
+		if p.Default != "" {
+			schema["default"] = p.Default
+			if n, err := strconv.Atoi(p.Default); err == nil {
+				schema["default"] = n
+			}
+		}
+
+		param := map[string]interface{}{
+			"name":     p.Name,
+			"in":       p.In,
+			"required": p.Required || p.In == "path",
+			"schema":   schema,
+		}
+		if p.Description != "" {
+			param["description"] = p.Description
+		}
+		result = append(result, param)
+	}
+	return result
+}
+
+// buildRequestBody converts a body param, or formData params, to a request body
+func buildRequestBody(builder *schemaBuilder, params []ParamInfo) map[string]interface{} {
+	formProperties := make(map[string]interface{})
+	var formRequired []string
+
+	for _, p := range params {
+		switch p.In {
+		case "body":
+			return map[string]interface{}{
+				"required":    p.Required,
+				"description": p.Description,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{
+						"schema": builder.annotationSchema(p.Type),
+					},
+				},
+			}
+		case "formData":
+			schema := map[string]interface{}{"type": "string"}
+			if p.Type == "file" {
+				schema["format"] = "binary"
+			}
+			if p.Description != "" {
+				schema["description"] = p.Description
+			}
+			formProperties[p.Name] = schema
+			if p.Required {
+				formRequired = append(formRequired, p.Name)
+			}
+		}
+	}
+
+	if len(formProperties) == 0 {
+		return nil
+	}
+
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": formProperties,
+	}
+	if len(formRequired) > 0 {
+		schema["required"] = formRequired
+	}
+	return map[string]interface{}{
+		"required": len(formRequired) > 0,
+		"content": map[string]interface{}{
+			"multipart/form-data": map[string]interface{}{
+				"schema": schema,
+			},
+		},
+	}
+}
+
+// buildResponses converts @Success/@Failure annotations to OpenAPI responses.
+// Successful responses use the @Produce content type; errors are always JSON.
+func buildResponses(builder *schemaBuilder, responses []ResponseInfo, produces string) map[string]interface{} {
+	result := make(map[string]interface{})
+	for _, r := range responses {
+		contentType := "application/json"
+		if produces != "" && strings.HasPrefix(r.Code, "2") {
+			contentType = produces
+		}
+
+		description := r.Description
+		if description == "" {
+			description = defaultDescription(r.Code)
+		}
+
+		response := map[string]interface{}{
+			"description": description,
+		}
+		if r.Type != "" {
+			response["content"] = map[string]interface{}{
+				contentType: map[string]interface{}{
+					"schema": builder.annotationSchema(r.Type),
+				},
+			}
+		}
+		result[r.Code] = response
+	}
+
+	if len(result) == 0 {
+		result["200"] = map[string]interface{}{"description": "OK"}
+	}
+	return result
+}
+
+// defaultDescription returns the standard status text for a response code
+func defaultDescription(code string) string {
+	n, err := strconv.Atoi(code)
+	if err != nil {
+		return code
+	}
+	if text := httpStatusText[n]; text != "" {
+		return text
+	}
+	return code
+}
+
+// httpStatusText covers the status codes the handlers document
+var httpStatusText = map[int]string{
+	200: "OK",
+	201: "Created",
+	204: "No Content",
+	400: "Bad Request",
+	401: "Unauthorized",
+	403: "Forbidden",
+	404: "Not Found",
+	409: "Conflict",
+	422: "Unprocessable Entity",
+	429: "Too Many Requests",
+	500: "Internal Server Error",
+	503: "Service Unavailable",
+}
+
+// knownSecuritySchemes defines the schemes that @Security annotations may name
+var knownSecuritySchemes = map[string]map[string]interface{}{
+	"BearerAuth": {
+		"type":         "http",
+		"scheme":       "bearer",
+		"bearerFormat": "JWT",
+	},
+	"AdminToken": {
+		"type": "apiKey",
+		"in":   "header",
+		"name": "X-Admin-Token",
+	},
+}
+
+// ensureSecurityScheme returns the declared scheme matching an annotation,
+// ignoring case (the spec historically declares bearerAuth), and declares
+// known schemes that are missing
+func ensureSecurityScheme(schemes map[string]interface{}, name string) string {
+	for declared := range schemes {
+		if strings.EqualFold(declared, name) {
+			return declared
+		}
+	}
+
+	if scheme, ok := knownSecuritySchemes[name]; ok {
+		schemes[name] = scheme
+	} else {
+		log.Printf("Warning: @Security %s does not match a known security scheme", name)
+	}
+	return name
+}
+
+// stringMap converts a decoded YAML mapping, which yaml.v2 produces with
+// interface{} keys, into a map[string]interface{}. Missing mappings come back
+// empty, ready to fill.
+func stringMap(v interface{}) map[string]interface{} {
+	switch m := v.(type) {
+	case map[string]interface{}:
+		if m != nil {
+			return m
+		}
+	case map[interface{}]interface{}:
+		result := make(map[string]interface{}, len(m))
+		for k, val := range m {
+			result[fmt.Sprint(k)] = val
+		}
+		return result
+	}
+	return make(map[string]interface{})
 }
 
 func saveOpenAPISpec(filename string, spec *OpenAPISpec) error {
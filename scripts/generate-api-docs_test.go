@@ -0,0 +1,334 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// widgetHandlers is an annotated handlers file like those in internal/handlers
+const widgetHandlers = `package handlers
+
+// CreateWidgetRequest represents the request to create a widget
+type CreateWidgetRequest struct {
+	Name   string   ` + "`json:\"name\" validate:\"required,min=1,max=50\"`" + `
+	Email  string   ` + "`json:\"email,omitempty\" validate:\"omitempty,email\"`" + `
+	Tags   []string ` + "`json:\"tags,omitempty\" validate:\"max=5\"`" + `
+	Owner  *Owner   ` + "`json:\"owner,omitempty\"`" + `
+	Secret string   ` + "`json:\"-\"`" + `
+	hidden string
+}
+
+// Owner is referenced by widget requests
+type Owner struct {
+	ID        uuid.UUID ` + "`json:\"id\"`" + `
+	CreatedAt time.Time ` + "`json:\"created_at\"`" + `
+}
+
+// WidgetResponse represents a widget in API responses
+type WidgetResponse struct {
+	ID    string ` + "`json:\"id\"`" + `
+	Count int64  ` + "`json:\"count\"`" + ` // Times the widget was used
+}
+
+// ErrorResponse represents an error
+type ErrorResponse struct {
+	Error string ` + "`json:\"error\"`" + `
+}
+
+// CreateWidget creates a widget
+// @Summary Create widget
+// @Description Create a widget owned by the current user
+// @Tags Widgets
+// @Accept json
+// @Produce json
+// @Param request body CreateWidgetRequest true "Widget to create"
+// @Success 201 {object} WidgetResponse
+// @Failure 400 {object} ErrorResponse "Invalid widget"
+// @Security BearerAuth
+// @Router /api/widgets [post]
+func (h *WidgetHandler) CreateWidget(c *fiber.Ctx) error {
+	return nil
+}
+
+// ListWidgets lists widgets
+// @Summary List widgets
+// @Tags Widgets
+// @Produce json
+// @Param owner_id query string false "Owner ID"
+// @Param limit query int false "Page size" default(20)
+// @Success 200 {object} []WidgetResponse
+// @Router /api/widgets [get]
+func (h *WidgetHandler) ListWidgets(c *fiber.Ctx) error {
+	return nil
+}
+
+// GetWidget retrieves a widget
+// @Summary Get widget
+// @Tags Widgets
+// @Param id path string true "Widget ID"
+// @Success 200 {object} WidgetResponse
+// @Failure 404
+// @Security BearerAuth
+// @Router /api/widgets/{id} [get]
+func (h *WidgetHandler) GetWidget(c *fiber.Ctx) error {
+	return nil
+}
+
+// helper has no annotations and isn't a route
+func helper() {}
+`
+
+// generateWidgetSpec runs the generator over widgetHandlers, starting from
+// spec, and returns the saved spec as it would be loaded again
+func generateWidgetSpec(t *testing.T, spec *OpenAPISpec) *OpenAPISpec {
+	t.Helper()
+	dir := t.TempDir()
+	handlers := filepath.Join(dir, "handlers")
+	if err := os.Mkdir(handlers, 0o755); err != nil {
+		t.Fatalf("creating the handlers dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(handlers, "widgets.go"), []byte(widgetHandlers), 0o644); err != nil {
+		t.Fatalf("writing the handlers: %v", err)
+	}
+
+	routes, err := parseHandlers(handlers)
+	if err != nil {
+		t.Fatalf("parseHandlers: %v", err)
+	}
+	types, err := parseTypes("handlers", handlers)
+	if err != nil {
+		t.Fatalf("parseTypes: %v", err)
+	}
+	updateOpenAPISpec(spec, routes, types)
+
+	file := filepath.Join(dir, "openapi.yaml")
+	if err := saveOpenAPISpec(file, spec); err != nil {
+		t.Fatalf("saveOpenAPISpec: %v", err)
+	}
+	saved, err := loadOpenAPISpec(file)
+	if err != nil {
+		t.Fatalf("loadOpenAPISpec: %v", err)
+	}
+	return saved
+}
+
+// lookup follows keys through the nested mappings of a loaded spec
+func lookup(t *testing.T, v interface{}, keys ...string) interface{} {
+	t.Helper()
+	for i, key := range keys {
+		m := stringMap(v)
+		next, ok := m[key]
+		if !ok {
+			t.Fatalf("no %q at %v", key, keys[:i])
+		}
+		v = next
+	}
+	return v
+}
+
+func TestParseDocComments(t *testing.T) {
+	comments := `CreateWidget creates a widget
+@Summary Create widget
+@Tags Widgets, Admin
+@Produce text/csv
+@Param id path string true "Widget ID"
+@Param limit query int false "Page size" default(20)
+@Param request body CreateWidgetRequest true "Widget to create"
+@Success 201 {object} WidgetResponse
+@Failure 400 {object} ErrorResponse "Invalid widget"
+@Success 204
+@Security BearerAuth
+@Router /api/widgets/{id} [PUT]
+`
+
+	want := RouteInfo{
+		Method:  "put",
+		Path:    "/api/widgets/{id}",
+		Summary: "Create widget",
+		Tags:    []string{"Widgets", "Admin"},
+		Params: []ParamInfo{
+			{Name: "id", In: "path", Type: "string", Required: true, Description: "Widget ID"},
+			{Name: "limit", In: "query", Type: "int", Description: "Page size", Default: "20"},
+			{Name: "request", In: "body", Type: "CreateWidgetRequest", Required: true, Description: "Widget to create"},
+		},
+		Responses: []ResponseInfo{
+			{Code: "201", Type: "WidgetResponse"},
+			{Code: "400", Type: "ErrorResponse", Description: "Invalid widget"},
+			{Code: "204"},
+		},
+		Security: []string{"BearerAuth"},
+		Produces: "text/csv",
+	}
+	if got := parseDocComments(comments); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseDocComments =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestGeneratedSchemas(t *testing.T) {
+	spec := generateWidgetSpec(t, &OpenAPISpec{OpenAPI: "3.0.0"})
+	widgets := versionedPath("/api/widgets")
+
+	if _, ok := stringMap(spec.Paths)["/api/widgets"]; ok {
+		t.Error("documented the unversioned path")
+	}
+	if got := len(stringMap(spec.Paths)); got != 2 {
+		t.Errorf("documented %d paths, want 2", got)
+	}
+
+	t.Run("request body", func(t *testing.T) {
+		body := lookup(t, spec.Paths, widgets, "post", "requestBody")
+		if got := lookup(t, body, "required"); got != true {
+			t.Errorf("required = %v, want true", got)
+		}
+		ref := lookup(t, body, "content", "application/json", "schema", "$ref")
+		if ref != "#/components/schemas/CreateWidgetRequest" {
+			t.Errorf("schema $ref = %v", ref)
+		}
+	})
+
+	t.Run("responses", func(t *testing.T) {
+		responses := lookup(t, spec.Paths, widgets, "post", "responses")
+		if ref := lookup(t, responses, "201", "content", "application/json", "schema", "$ref"); ref != "#/components/schemas/WidgetResponse" {
+			t.Errorf("201 schema $ref = %v", ref)
+		}
+		if description := lookup(t, responses, "400", "description"); description != "Invalid widget" {
+			t.Errorf("400 description = %v", description)
+		}
+
+		list := lookup(t, spec.Paths, widgets, "get", "responses", "200", "content", "application/json", "schema")
+		if lookup(t, list, "type") != "array" || lookup(t, list, "items", "$ref") != "#/components/schemas/WidgetResponse" {
+			t.Errorf("list schema = %v, want an array of WidgetResponse", list)
+		}
+
+		notFound := stringMap(lookup(t, spec.Paths, versionedPath("/api/widgets/{id}"), "get", "responses", "404"))
+		if notFound["description"] != "Not Found" || notFound["content"] != nil {
+			t.Errorf("404 = %v, want the status text and no body", notFound)
+		}
+	})
+
+	t.Run("parameters", func(t *testing.T) {
+		params, _ := lookup(t, spec.Paths, widgets, "get", "parameters").([]interface{})
+		if len(params) != 2 {
+			t.Fatalf("parameters = %v, want owner_id and limit", params)
+		}
+		limit := params[1]
+		if lookup(t, limit, "name") != "limit" || lookup(t, limit, "in") != "query" || lookup(t, limit, "required") != false {
+			t.Errorf("limit = %v", limit)
+		}
+		if lookup(t, limit, "schema", "type") != "integer" || lookup(t, limit, "schema", "default") != 20 {
+			t.Errorf("limit schema = %v, want an integer defaulting to 20", lookup(t, limit, "schema"))
+		}
+
+		id, _ := lookup(t, spec.Paths, versionedPath("/api/widgets/{id}"), "get", "parameters").([]interface{})
+		if len(id) != 1 || lookup(t, id[0], "in") != "path" || lookup(t, id[0], "required") != true {
+			t.Errorf("parameters = %v, want the required id path parameter", id)
+		}
+	})
+
+	t.Run("component schemas", func(t *testing.T) {
+		schemas := lookup(t, spec.Components, "schemas")
+		for _, name := range []string{"CreateWidgetRequest", "Owner", "WidgetResponse", "ErrorResponse"} {
+			lookup(t, schemas, name)
+		}
+
+		request := lookup(t, schemas, "CreateWidgetRequest")
+		properties := stringMap(lookup(t, request, "properties"))
+		var names []string
+		for name := range properties {
+			names = append(names, name)
+		}
+		if len(names) != 4 || properties["Secret"] != nil || properties["hidden"] != nil {
+			t.Errorf("properties = %v, want name, email, tags and owner", names)
+		}
+		if got := lookup(t, request, "required"); !reflect.DeepEqual(got, []interface{}{"name"}) {
+			t.Errorf("required = %v, want [name]", got)
+		}
+		if lookup(t, properties, "name", "minLength") != 1 || lookup(t, properties, "name", "maxLength") != 50 {
+			t.Errorf("name = %v, want its length bounds", properties["name"])
+		}
+		if lookup(t, properties, "email", "format") != "email" || lookup(t, properties, "tags", "maxItems") != 5 {
+			t.Errorf("email = %v, tags = %v; want the validation rules", properties["email"], properties["tags"])
+		}
+		if lookup(t, properties, "owner", "$ref") != "#/components/schemas/Owner" {
+			t.Errorf("owner = %v, want a reference", properties["owner"])
+		}
+
+		owner := lookup(t, schemas, "Owner", "properties")
+		if lookup(t, owner, "id", "format") != "uuid" || lookup(t, owner, "created_at", "format") != "date-time" {
+			t.Errorf("owner properties = %v", owner)
+		}
+		count := lookup(t, schemas, "WidgetResponse", "properties", "count")
+		if lookup(t, count, "format") != "int64" || lookup(t, count, "description") != "Times the widget was used" {
+			t.Errorf("count = %v, want an int64 with the field comment", count)
+		}
+	})
+}
+
+func TestOperationSecurity(t *testing.T) {
+	widget := versionedPath("/api/widgets/{id}")
+	widgets := versionedPath("/api/widgets")
+
+	tests := []struct {
+		name        string
+		schemes     map[string]interface{} // Declared before generating
+		wantScheme  string                 // Name the requirement uses
+		wantSchemes []string
+	}{
+		{
+			name:        "scheme declared by the generator",
+			wantScheme:  "BearerAuth",
+			wantSchemes: []string{"BearerAuth"},
+		},
+		{
+			name: "scheme already declared in another case",
+			schemes: map[string]interface{}{
+				"bearerAuth": map[string]interface{}{"type": "http", "scheme": "bearer"},
+				"AdminToken": knownSecuritySchemes["AdminToken"],
+			},
+			wantScheme:  "bearerAuth",
+			wantSchemes: []string{"AdminToken", "bearerAuth"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := &OpenAPISpec{OpenAPI: "3.0.0"}
+			if tt.schemes != nil {
+				spec.Components = map[string]interface{}{"securitySchemes": tt.schemes}
+			}
+			spec = generateWidgetSpec(t, spec)
+
+			schemes := stringMap(lookup(t, spec.Components, "securitySchemes"))
+			var names []string
+			for name := range schemes {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			if !reflect.DeepEqual(names, tt.wantSchemes) {
+				t.Errorf("security schemes = %v, want %v", names, tt.wantSchemes)
+			}
+			scheme := schemes[tt.wantScheme]
+			if lookup(t, scheme, "type") != "http" || lookup(t, scheme, "scheme") != "bearer" {
+				t.Errorf("%s = %v, want HTTP bearer auth", tt.wantScheme, scheme)
+			}
+
+			for _, protected := range []interface{}{
+				lookup(t, spec.Paths, widgets, "post"),
+				lookup(t, spec.Paths, widget, "get"),
+			} {
+				security, _ := lookup(t, protected, "security").([]interface{})
+				if len(security) != 1 || len(stringMap(security[0])) != 1 || lookup(t, security[0], tt.wantScheme) == nil {
+					t.Errorf("%s security = %v, want %s", lookup(t, protected, "operationId"), security, tt.wantScheme)
+				}
+			}
+
+			public := stringMap(lookup(t, spec.Paths, widgets, "get"))
+			if _, ok := public["security"]; ok {
+				t.Errorf("public ListWidgets has security %v", public["security"])
+			}
+		})
+	}
+}
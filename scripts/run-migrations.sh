@@ -148,8 +148,8 @@ run_migration() {
 # Function to rollback a migration
 rollback_migration() {
     local migration_file="$1"
-    local filename=$(basename "$migration_file" .sql)
-    local down_file="${migration_file%.*}.down.sql"
+    local filename=$(basename "$migration_file" .up.sql)
+    local down_file="${migration_file%.up.sql}.down.sql"
     
     if [ ! -f "$down_file" ]; then
         print_error "Rollback file not found: $down_file"
@@ -174,11 +174,41 @@ run_all_migrations() {
     
     # Define migration order
     local migrations=(
-        "001_initial_schema.sql"
-        "002_jwt_auth_schema.sql"
+        "001_initial_schema.up.sql"
+        "002_jwt_auth_schema.up.sql"
         "000003_create_verification_tables.up.sql"
-        "004_instagram_optimization.sql"
-        "005_instagram_advanced_features.sql"
+        "004_instagram_optimization.up.sql"
+        "005_instagram_advanced_features.up.sql"
+        "006_media_variants.up.sql"
+        "007_post_likes.up.sql"
+        "008_comments_threading.up.sql"
+        "009_account_deactivation.up.sql"
+        "010_post_tags.up.sql"
+        "011_follow_requests.up.sql"
+        "012_saved_posts_order.up.sql"
+        "013_optimistic_locking.up.sql"
+        "014_posts_keyset.up.sql"
+        "015_post_views.up.sql"
+        "016_post_drafts.up.sql"
+        "017_reposts.up.sql"
+        "018_notifications_listing.up.sql"
+        "019_post_coordinates.up.sql"
+        "020_post_search.up.sql"
+        "021_post_interaction_settings.up.sql"
+        "022_media_direct_uploads.up.sql"
+        "023_moderation_queue.up.sql"
+        "024_hash_verification_tokens.up.sql"
+        "025_notification_dedup.up.sql"
+        "026_login_history.up.sql"
+        "027_session_metadata.up.sql"
+        "028_direct_messages.up.sql"
+        "029_password_history.up.sql"
+        "030_devices.up.sql"
+        "031_email_changes.up.sql"
+        "032_user_counts.up.sql"
+        "033_user_roles.up.sql"
+        "034_mentions.up.sql"
+        "035_username_length.up.sql"
     )
     
     local success_count=0